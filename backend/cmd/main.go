@@ -1,11 +1,25 @@
 package main
 
 import (
+	"context"
 	"log"
+	"path/filepath"
+	"smart-analysis/internal/agents"
+	"smart-analysis/internal/audit"
+	"smart-analysis/internal/auth"
 	"smart-analysis/internal/config"
 	"smart-analysis/internal/handler"
+	"smart-analysis/internal/manager"
 	"smart-analysis/internal/middleware"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/notify"
+	"smart-analysis/internal/oauth"
+	"smart-analysis/internal/planstore"
+	"smart-analysis/internal/prompts"
+	"smart-analysis/internal/scheduler"
 	"smart-analysis/internal/service"
+	"smart-analysis/internal/tools"
+	"smart-analysis/internal/utils/sanbox"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -15,15 +29,131 @@ func main() {
 	// 加载配置
 	cfg := config.Load()
 
+	// 初始化提示词模板库
+	if err := prompts.InitializeGlobalLibrary(cfg); err != nil {
+		log.Fatal("Failed to load prompt templates:", err)
+	}
+
+	// 注册配置定义的专家智能体
+	if err := agents.RegisterConfigExperts(cfg.ExpertDir); err != nil {
+		log.Fatal("Failed to load expert definitions:", err)
+	}
+
+	// 初始化任务调度器
+	if err := scheduler.InitializeGlobal(cfg); err != nil {
+		log.Fatal("Failed to configure scheduler:", err)
+	}
+
+	// 初始化审计日志
+	if err := audit.InitializeGlobal(cfg); err != nil {
+		log.Fatal("Failed to open audit log:", err)
+	}
+
+	// 初始化令牌签发和校验
+	if err := auth.InitializeGlobal(cfg); err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
+
+	// 初始化执行计划存储
+	planStore, err := planstore.NewStore(cfg.PlanStoreDir)
+	if err != nil {
+		log.Fatal("Failed to open plan store:", err)
+	}
+
 	// 初始化服务
 	analysisService := service.NewAnalysisService()
+	analysisService.SetPlanStore(planStore)
 	userService := service.NewUserService()
+	userService.SetAdminEmails(cfg.AdminEmails)
+	workspaceService := service.NewWorkspaceService(userService)
+	analysisService.SetWorkspaces(workspaceService)
+	dashboardService := service.NewDashboardService(workspaceService)
+	privacyService := service.NewPrivacyService(cfg.PIIHashSecret)
 	fileService := service.NewFileService()
+	fileService.SetPrivacy(privacyService)
+	fileService.SetWorkspaces(workspaceService)
+	// 图表在Python沙箱中生成，沙箱只能读取经过访问策略过滤和脱敏的数据视图
+	sandbox := sanbox.NewPythonSandbox("")
+	if cfg.PythonPath != "" {
+		sandbox.SetPythonPath(cfg.PythonPath)
+	}
+	// 沙箱在隔离的命名空间中运行，看不到原始上传文件和服务自己的数据（执行计划、SQLite数据源、审计日志、密钥）
+	// 所有用户的数据视图也在受限目录中，每次执行只开放发起请求的用户自己的视图目录
+	deniedPaths := []string{cfg.UploadPath, fileService.ViewPath(), cfg.PlanStoreDir, cfg.DataSourceSQLiteDir, cfg.JWTKeyDir}
+	if cfg.AuditLogPath != "" {
+		deniedPaths = append(deniedPaths, filepath.Dir(cfg.AuditLogPath))
+	}
+	sandbox.SetDeniedPaths(deniedPaths...)
+	sandbox.SetUserPaths(func(userID int) []string {
+		return []string{fileService.UserViewDir(userID)}
+	})
+	analysisService.SetChartRenderer(tools.NewEChartsVisualizationTool(sandbox))
+
+	// 多智能体系统回答查询：使用用户或团队空间的LLM配置，只能读取上下文中的用户可见的数据视图，
+	// 执行计划保存在计划存储中，按查询ID查看、恢复和重新执行
+	agentSystem, err := manager.NewAgentSystemBuilder().
+		WithChatModel(analysisService.ChatModel()).
+		WithPythonSandbox(sandbox).
+		WithPrompts(prompts.GetGlobalLibrary()).
+		WithPlanStore(planStore).
+		WithScheduler(scheduler.GetGlobal()).
+		WithPIIMasker(privacyService.Masker()).
+		WithDatasets(fileService.Datasets()).
+		Build(context.Background())
+	if err != nil {
+		log.Fatal("Failed to build agent system:", err)
+	}
+	analysisService.SetAgentSystem(agentSystem)
+	analysisService.SetPlanRunner(agentSystem)
+	sqlService := service.NewSQLService(fileService)
+	dataSourceService := service.NewDataSourceService(cfg.DataSourceSecret, cfg.DataSourceSQLiteDir)
+	dataSourceService.SetPrivacy(privacyService)
+	dataSourceService.StartScheduler(context.Background())
+	apiKeyService := service.NewAPIKeyService(userService)
+	apiKeyService.SetWorkspaces(workspaceService)
+	middleware.SetAPIKeyAuthenticator(apiKeyService)
+	oauthService := service.NewOAuthService(userService)
+	oauthService.SetWorkspaces(workspaceService)
+	if cfg.OAuthConfigFile != "" {
+		providers, err := oauth.LoadConfig(cfg.OAuthConfigFile)
+		if err != nil {
+			log.Fatal("Failed to load OAuth providers:", err)
+		}
+		for _, provider := range providers {
+			if err := oauthService.AddProvider(provider); err != nil {
+				log.Fatal("Failed to configure OAuth provider:", err)
+			}
+		}
+	}
+
+	accountService := service.NewAccountService(userService, cfg.AppURL)
+	if cfg.SMTPAddr != "" {
+		accountService.SetMailer(&notify.SMTPSender{
+			Addr:     cfg.SMTPAddr,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+	}
+	if cfg.SMSWebhookURL != "" {
+		accountService.SetSMS(&notify.WebhookSender{URL: cfg.SMSWebhookURL})
+	}
+	// 团队空间放在最前面：唯一所有者未转让空间时拒绝注销，不删除任何数据
+	accountService.SetCascade(workspaceService, fileService, analysisService, dataSourceService, dashboardService, apiKeyService, oauthService)
 
 	// 初始化处理器
-	analysisHandler := handler.NewAnalysisHandler(analysisService)
-	userHandler := handler.NewUserHandler(userService)
+	analysisHandler := handler.NewAnalysisHandler(analysisService, fileService)
+	userHandler := handler.NewUserHandler(userService, accountService, auth.GetGlobal())
+	oauthHandler := handler.NewOAuthHandler(oauthService, auth.GetGlobal())
 	fileHandler := handler.NewFileHandler(fileService)
+	sqlHandler := handler.NewSQLHandler(sqlService)
+	dataSourceHandler := handler.NewDataSourceHandler(dataSourceService)
+	systemHandler := handler.NewSystemHandler(scheduler.GetGlobal())
+	privacyHandler := handler.NewPrivacyHandler(privacyService)
+	workspaceHandler := handler.NewWorkspaceHandler(workspaceService, fileService)
+	dashboardHandler := handler.NewDashboardHandler(dashboardService)
+	auditHandler := handler.NewAuditHandler(audit.GetGlobal())
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
 
 	// 创建Gin路由
 	r := gin.Default()
@@ -32,46 +162,115 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "http://127.0.0.1:3000", "http://localhost:3001", "http://127.0.0.1:3001"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 	}))
 
-	// 静态文件服务
-	r.Static("/uploads", "./uploads")
-
 	// API路由组
 	api := r.Group("/api/v1")
 	{
+		// OpenAPI 文档
+		api.GET("/openapi.json", handler.OpenAPISpec)
+
 		// 用户相关路由
 		user := api.Group("/user")
 		{
 			user.POST("/register", userHandler.Register)
 			user.POST("/login", userHandler.Login)
+			user.POST("/refresh", userHandler.Refresh)
+			user.POST("/logout", middleware.AuthMiddleware(), userHandler.Logout)
+			user.PUT("/password", middleware.AuthMiddleware(), userHandler.ChangePassword)
+			user.GET("/oauth/providers", oauthHandler.Providers)
+			user.GET("/oauth/:provider/authorize", oauthHandler.Authorize)
+			user.POST("/oauth/:provider/callback", oauthHandler.Callback)
+			user.GET("/oauth/:provider/link", middleware.AuthMiddleware(), oauthHandler.Link)
+			user.GET("/oauth/identities", middleware.AuthMiddleware(), oauthHandler.Identities)
+			user.DELETE("/oauth/identities/:provider", middleware.AuthMiddleware(), oauthHandler.Unlink)
 			user.GET("/profile", middleware.AuthMiddleware(), userHandler.GetProfile)
 			user.PUT("/profile", middleware.AuthMiddleware(), userHandler.UpdateProfile)
+			user.POST("/email/verification", middleware.AuthMiddleware(), userHandler.SendEmailVerification)
+			user.POST("/email/verify", userHandler.VerifyEmail)
+			user.POST("/password/forgot", userHandler.ForgotPassword)
+			user.POST("/password/reset", userHandler.ResetPassword)
+			user.POST("/phone/code", userHandler.SendPhoneCode)
+			user.POST("/phone/register", userHandler.RegisterPhone)
+			user.POST("/phone/login", userHandler.LoginPhone)
+			user.PUT("/phone", middleware.AuthMiddleware(), userHandler.BindPhone)
+			user.DELETE("/account", middleware.AuthMiddleware(), userHandler.DeleteAccount)
 		}
 
 		// 文件上传相关路由
 		file := api.Group("/file")
-		file.Use(middleware.AuthMiddleware())
 		{
-			file.POST("/upload", fileHandler.Upload)
-			file.GET("/list", fileHandler.List)
-			file.DELETE("/:id", fileHandler.Delete)
-			file.GET("/:id/preview", fileHandler.Preview)
+			// 只读接口可以使用具有 files:read 权限的 API Key 访问
+			readonly := file.Group("", middleware.AuthMiddleware(model.ScopeFilesRead))
+			readonly.GET("/list", fileHandler.List)
+			readonly.GET("/shared", fileHandler.Shared)
+			readonly.GET("/:id/preview", fileHandler.Preview)
+
+			manage := file.Group("", middleware.AuthMiddleware())
+			manage.POST("/upload", fileHandler.Upload)
+			manage.DELETE("/:id", fileHandler.Delete)
+			manage.GET("/:id/sensitive", fileHandler.GetSensitiveColumns)
+			manage.PUT("/:id/sensitive", fileHandler.UpdateSensitiveColumns)
+			manage.GET("/:id/policies", fileHandler.ListAccessPolicies)
+			manage.POST("/:id/policies", fileHandler.CreateAccessPolicy)
+			manage.PUT("/:id/policies/:policy_id", fileHandler.UpdateAccessPolicy)
+			manage.DELETE("/:id/policies/:policy_id", fileHandler.DeleteAccessPolicy)
+			manage.PUT("/:id/workspace", fileHandler.Share)
+		}
+
+		// SQL查询相关路由
+		sql := api.Group("/sql")
+		{
+			sql.POST("/query", middleware.AuthMiddleware(model.ScopeQueriesRun), sqlHandler.Query)
+			sql.GET("/tables", middleware.AuthMiddleware(model.ScopeFilesRead), sqlHandler.Tables)
+		}
+
+		// 数据源相关路由
+		datasource := api.Group("/datasource")
+		datasource.Use(middleware.AuthMiddleware())
+		{
+			datasource.POST("", dataSourceHandler.Create)
+			datasource.GET("", dataSourceHandler.List)
+			datasource.GET("/:id", dataSourceHandler.Get)
+			datasource.PUT("/:id", dataSourceHandler.Update)
+			datasource.DELETE("/:id", dataSourceHandler.Delete)
+			datasource.POST("/:id/scan", dataSourceHandler.Scan)
+			datasource.GET("/:id/schema", dataSourceHandler.Schema)
+			datasource.GET("/:id/semantic", dataSourceHandler.GetSemantic)
+			datasource.PUT("/:id/semantic", dataSourceHandler.UpdateSemantic)
+			datasource.POST("/:id/semantic/suggest", dataSourceHandler.SuggestSemantic)
+			datasource.GET("/:id/sensitive", dataSourceHandler.GetSensitiveColumns)
+			datasource.PUT("/:id/sensitive", dataSourceHandler.UpdateSensitiveColumns)
 		}
 
 		// 数据分析相关路由
 		analysis := api.Group("/analysis")
-		analysis.Use(middleware.AuthMiddleware())
 		{
-			analysis.POST("/query", analysisHandler.Query)
-			//analysis.POST("/visualize", analysisHandler.Visualize)
-			//analysis.POST("/report", analysisHandler.GenerateReport)
-			analysis.GET("/history", analysisHandler.GetHistory)
-			analysis.POST("/session", analysisHandler.CreateSession)
-			analysis.GET("/session/:id", analysisHandler.GetSession)
+			queries := analysis.Group("", middleware.AuthMiddleware(model.ScopeQueriesRun))
+			queries.POST("/query", analysisHandler.Query)
+			queries.POST("/visualize", analysisHandler.Visualize)
+			queries.GET("/chart/:id", analysisHandler.GetChart)
+			//queries.POST("/report", analysisHandler.GenerateReport)
+			queries.GET("/history", analysisHandler.GetHistory)
+			queries.GET("/query/:id/plan", analysisHandler.GetQueryPlan)
+			queries.POST("/query/:id/plan/resume", analysisHandler.ResumeQueryPlan)
+			queries.POST("/query/:id/plan/tasks/:task_id/rerun", analysisHandler.RerunQueryTask)
+
+			sessions := analysis.Group("", middleware.AuthMiddleware(model.ScopeSessionsManage))
+			sessions.POST("/session", analysisHandler.CreateSession)
+			sessions.GET("/session/:id", analysisHandler.GetSession)
+		}
+
+		// API Key 管理，只能使用登录令牌访问
+		apikey := api.Group("/apikey")
+		apikey.Use(middleware.AuthMiddleware())
+		{
+			apikey.POST("", apiKeyHandler.Create)
+			apikey.GET("", apiKeyHandler.List)
+			apikey.DELETE("/:id", apiKeyHandler.Revoke)
 		}
 
 		// LLM配置相关路由
@@ -82,6 +281,74 @@ func main() {
 			llm.GET("/config", analysisHandler.GetLLMConfig)
 			llm.GET("/usage", analysisHandler.GetUsage)
 		}
+
+		// 组织相关路由
+		org := api.Group("/org")
+		org.Use(middleware.AuthMiddleware())
+		{
+			org.POST("", workspaceHandler.CreateOrganization)
+			org.GET("", workspaceHandler.ListOrganizations)
+			org.POST("/:id/workspaces", workspaceHandler.Create)
+		}
+
+		// 团队空间相关路由
+		workspace := api.Group("/workspace")
+		workspace.Use(middleware.AuthMiddleware())
+		{
+			workspace.GET("", workspaceHandler.List)
+			workspace.GET("/:id", workspaceHandler.Get)
+			workspace.PUT("/:id", workspaceHandler.Update)
+			workspace.GET("/:id/files", workspaceHandler.Files)
+			workspace.GET("/:id/members", workspaceHandler.Members)
+			workspace.PUT("/:id/members/:user_id", workspaceHandler.UpdateMember)
+			workspace.DELETE("/:id/members/:user_id", workspaceHandler.RemoveMember)
+			workspace.POST("/:id/invitations", workspaceHandler.Invite)
+			workspace.GET("/:id/invitations", workspaceHandler.Invitations)
+			workspace.DELETE("/:id/invitations/:invitation_id", workspaceHandler.RevokeInvitation)
+		}
+
+		// 团队空间邀请路由
+		invitation := api.Group("/invitation")
+		invitation.Use(middleware.AuthMiddleware())
+		{
+			invitation.GET("", workspaceHandler.MyInvitations)
+			invitation.POST("/:token/accept", workspaceHandler.AcceptInvitation)
+			invitation.POST("/:token/decline", workspaceHandler.DeclineInvitation)
+		}
+
+		// 看板相关路由
+		dashboard := api.Group("/dashboard")
+		dashboard.Use(middleware.AuthMiddleware())
+		{
+			dashboard.POST("", dashboardHandler.Create)
+			dashboard.GET("", dashboardHandler.List)
+			dashboard.GET("/:id", dashboardHandler.Get)
+			dashboard.PUT("/:id", dashboardHandler.Update)
+			dashboard.DELETE("/:id", dashboardHandler.Delete)
+		}
+
+		// 敏感数据脱敏审计路由
+		privacy := api.Group("/privacy")
+		privacy.Use(middleware.AuthMiddleware())
+		{
+			privacy.GET("/masking", privacyHandler.MaskingRecords)
+		}
+
+		// 管理员路由
+		admin := api.Group("/admin")
+		admin.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware(userService.IsAdmin))
+		{
+			admin.GET("/audit", auditHandler.Query)
+			admin.GET("/audit/export", auditHandler.Export)
+			admin.GET("/audit/verify", auditHandler.Verify)
+		}
+
+		// 系统状态相关路由，调度器指标包含各用户的排队情况，仅管理员可用
+		system := api.Group("/system")
+		system.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware(userService.IsAdmin))
+		{
+			system.GET("/scheduler", systemHandler.SchedulerStats)
+		}
 	}
 
 	// 启动服务器
@@ -0,0 +1,750 @@
+// Command openapi 从 internal/handler 的接口注释生成 OpenAPI 3 文档。
+//
+// 在 internal/handler 目录下运行 go generate 更新 openapi.json：
+//
+//	go run ../../cmd/openapi -o openapi.json
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// securityScheme 接口注释中 @Security 使用的认证方式名称
+const securityScheme = "ApiKeyAuth"
+
+func main() {
+	dir := flag.String("dir", ".", "处理器代码所在目录")
+	output := flag.String("o", "", "输出文件，为空时输出到标准输出")
+	flag.Parse()
+
+	spec, err := generate(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *output == "" {
+		os.Stdout.Write(spec)
+		return
+	}
+	if err := os.WriteFile(*output, spec, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate 解析目录中处理器的注释，返回格式化的 OpenAPI 文档
+func generate(dir string) ([]byte, error) {
+	root, module, err := findModule(dir)
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{
+		root:     root,
+		module:   module,
+		fset:     token.NewFileSet(),
+		packages: make(map[string]*goPackage),
+		schemas:  make(map[string]interface{}),
+		paths:    make(map[string]map[string]interface{}),
+	}
+
+	files, err := g.parseDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if err := g.collect(file); err != nil {
+			return nil, err
+		}
+	}
+
+	spec := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Smart Analysis API",
+			"description": "智能数据分析平台接口。除登录注册外的接口需要在 Authorization 请求头中携带登录返回的访问令牌；标注了 API Key 权限的接口也可以使用 API Key。",
+			"version":     "1.0",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": "/api/v1"}},
+		"tags":    g.tags,
+		"paths":   g.paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				securityScheme: map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "登录返回的访问令牌（JWT），或以 sa_ 开头的 API Key。API Key 也可以放在 X-API-Key 请求头中",
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(spec); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// goPackage 已解析的包，用于查找注释和结构体中引用的类型
+type goPackage struct {
+	name  string
+	types map[string]*typeDecl
+}
+
+// typeDecl 类型声明及其所在文件，文件的导入用于解析字段中其他包的类型
+type typeDecl struct {
+	spec *ast.TypeSpec
+	doc  *ast.CommentGroup
+	file *ast.File
+}
+
+type generator struct {
+	root     string // 模块根目录
+	module   string // 模块路径
+	fset     *token.FileSet
+	packages map[string]*goPackage // 导入路径 -> 包
+	schemas  map[string]interface{}
+	paths    map[string]map[string]interface{}
+	tags     []interface{}
+	tagNames map[string]bool
+}
+
+// findModule 从目录向上查找 go.mod，返回模块根目录和模块路径
+func findModule(dir string) (string, string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	for current := abs; ; current = filepath.Dir(current) {
+		data, err := os.ReadFile(filepath.Join(current, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "module" {
+					return current, fields[1], nil
+				}
+			}
+			return "", "", fmt.Errorf("%s/go.mod 中没有模块路径", current)
+		}
+		if filepath.Dir(current) == current {
+			return "", "", fmt.Errorf("%s 不在 Go 模块中", dir)
+		}
+	}
+}
+
+// parseDir 解析目录中的非测试文件，按文件名排序
+func (g *generator) parseDir(dir string) ([]*ast.File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(g.fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// loadPackage 按导入路径加载模块内的包，模块外的包返回 nil
+func (g *generator) loadPackage(importPath string) (*goPackage, error) {
+	if pkg, loaded := g.packages[importPath]; loaded {
+		return pkg, nil
+	}
+	if importPath != g.module && !strings.HasPrefix(importPath, g.module+"/") {
+		return nil, nil
+	}
+	files, err := g.parseDir(filepath.Join(g.root, strings.TrimPrefix(importPath, g.module)))
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &goPackage{types: make(map[string]*typeDecl)}
+	for _, file := range files {
+		pkg.name = file.Name.Name
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				doc := typeSpec.Doc
+				if doc == nil && len(gen.Specs) == 1 {
+					doc = gen.Doc
+				}
+				pkg.types[typeSpec.Name.Name] = &typeDecl{spec: typeSpec, doc: doc, file: file}
+			}
+		}
+	}
+	g.packages[importPath] = pkg
+	return pkg, nil
+}
+
+// imports 返回文件中导入的包名到导入路径的映射
+func imports(file *ast.File) map[string]string {
+	result := make(map[string]string)
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		name := path.Base(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		result[name] = importPath
+	}
+	return result
+}
+
+// collect 收集文件中处理器方法的接口注释，以及处理器类型上的分组说明
+func (g *generator) collect(file *ast.File) error {
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			if decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				doc := typeSpec.Doc
+				if doc == nil {
+					doc = decl.Doc
+				}
+				g.collectTag(doc)
+			}
+		case *ast.FuncDecl:
+			if decl.Doc == nil {
+				continue
+			}
+			if err := g.collectOperation(file, decl); err != nil {
+				return fmt.Errorf("%s: %w", g.fset.Position(decl.Pos()), err)
+			}
+		}
+	}
+	return nil
+}
+
+// collectTag 从处理器类型的 @Tags 和 @Description 注释生成标签说明，同名标签使用第一个说明
+func (g *generator) collectTag(doc *ast.CommentGroup) {
+	annotations := parseAnnotations(doc)
+	if len(annotations["@Tags"]) == 0 {
+		return
+	}
+	if g.tagNames == nil {
+		g.tagNames = make(map[string]bool)
+	}
+	name := annotations["@Tags"][0]
+	if g.tagNames[name] {
+		return
+	}
+	g.tagNames[name] = true
+	tag := map[string]interface{}{"name": name}
+	if description := strings.Join(annotations["@Description"], "\n"); description != "" {
+		tag["description"] = description
+	}
+	g.tags = append(g.tags, tag)
+}
+
+// parseAnnotations 按注解名分组返回 @ 开头的注释行的内容
+func parseAnnotations(doc *ast.CommentGroup) map[string][]string {
+	annotations := make(map[string][]string)
+	if doc == nil {
+		return annotations
+	}
+	for _, comment := range doc.List {
+		text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+		if !strings.HasPrefix(text, "@") {
+			continue
+		}
+		name, value, _ := strings.Cut(text, " ")
+		annotations[name] = append(annotations[name], strings.TrimSpace(value))
+	}
+	return annotations
+}
+
+var (
+	routerPattern = regexp.MustCompile(`^(\S+)\s+\[(\w+)\]$`)
+	paramPattern  = regexp.MustCompile(`^(\S+)\s+(\w+)\s+(\S+)\s+(true|false)(?:\s+"(.*)")?$`)
+	resultPattern = regexp.MustCompile(`^(\d{3})\s+\{(\w+)\}\s+(\S+)(?:\s+"?(.*?)"?)?$`)
+)
+
+// collectOperation 将处理器方法的 @Router 等注释转换为接口定义
+func (g *generator) collectOperation(file *ast.File, fn *ast.FuncDecl) error {
+	annotations := parseAnnotations(fn.Doc)
+	routers := annotations["@Router"]
+	if len(routers) == 0 {
+		return nil
+	}
+	router := routerPattern.FindStringSubmatch(routers[0])
+	if router == nil {
+		return fmt.Errorf("无法解析 @Router %q", routers[0])
+	}
+	method := strings.ToLower(router[2])
+
+	operationID := fn.Name.Name
+	if receiver := strings.TrimSuffix(receiverName(fn), "Handler"); receiver != "" {
+		operationID = receiver + "." + operationID
+	}
+	operation := map[string]interface{}{"operationId": operationID}
+	if summary := annotations["@Summary"]; len(summary) > 0 {
+		operation["summary"] = summary[0]
+	}
+	if description := strings.Join(annotations["@Description"], "\n"); description != "" {
+		operation["description"] = description
+	}
+	if tags := annotations["@Tags"]; len(tags) > 0 {
+		operation["tags"] = strings.Split(tags[0], ",")
+	}
+	if len(annotations["@Security"]) > 0 {
+		operation["security"] = []interface{}{map[string]interface{}{securityScheme: []string{}}}
+	}
+
+	accept := mimeType(first(annotations["@Accept"], "json"))
+	produce := mimeType(first(annotations["@Produce"], "json"))
+	importMap := imports(file)
+
+	var parameters []interface{}
+	form := map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	var formRequired []string
+	for _, value := range annotations["@Param"] {
+		param := paramPattern.FindStringSubmatch(value)
+		if param == nil {
+			return fmt.Errorf("无法解析 @Param %q", value)
+		}
+		name, in, typeName, required, description := param[1], param[2], param[3], param[4] == "true", param[5]
+		schema, err := g.annotationSchema(importMap, typeName)
+		if err != nil {
+			return err
+		}
+		switch in {
+		case "body":
+			body := map[string]interface{}{
+				"required": required,
+				"content":  map[string]interface{}{accept: map[string]interface{}{"schema": schema}},
+			}
+			if description != "" {
+				body["description"] = description
+			}
+			operation["requestBody"] = body
+		case "formData":
+			if description != "" {
+				schema["description"] = description
+			}
+			form["properties"].(map[string]interface{})[name] = schema
+			if required {
+				formRequired = append(formRequired, name)
+			}
+		case "path", "query", "header":
+			parameter := map[string]interface{}{
+				"name":     name,
+				"in":       in,
+				"required": required || in == "path",
+				"schema":   schema,
+			}
+			if description != "" {
+				parameter["description"] = description
+			}
+			parameters = append(parameters, parameter)
+		default:
+			return fmt.Errorf("不支持的参数位置 %q", in)
+		}
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	if len(form["properties"].(map[string]interface{})) > 0 {
+		if len(formRequired) > 0 {
+			form["required"] = formRequired
+		}
+		operation["requestBody"] = map[string]interface{}{
+			"required": len(formRequired) > 0,
+			"content":  map[string]interface{}{accept: map[string]interface{}{"schema": form}},
+		}
+	}
+
+	responses := make(map[string]interface{})
+	for _, value := range append(annotations["@Success"], annotations["@Failure"]...) {
+		result := resultPattern.FindStringSubmatch(value)
+		if result == nil {
+			return fmt.Errorf("无法解析响应 %q", value)
+		}
+		code, kind, typeName, description := result[1], result[2], result[3], result[4]
+		if description == "" {
+			status, _ := strconv.Atoi(code)
+			description = http.StatusText(status)
+		}
+
+		var schema map[string]interface{}
+		switch kind {
+		case "file":
+			schema = map[string]interface{}{"type": "string", "format": "binary"}
+		case "object", "array":
+			var err error
+			if schema, err = g.annotationSchema(importMap, typeName); err != nil {
+				return err
+			}
+			if kind == "array" {
+				schema = map[string]interface{}{"type": "array", "items": schema}
+			}
+		default:
+			return fmt.Errorf("不支持的响应类型 {%s}", kind)
+		}
+		responses[code] = map[string]interface{}{
+			"description": description,
+			"content":     map[string]interface{}{produce: map[string]interface{}{"schema": schema}},
+		}
+	}
+	operation["responses"] = responses
+
+	// 路径参数由 :id 或 {id} 统一为 {id}
+	segments := strings.Split(router[1], "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	route := strings.Join(segments, "/")
+	if g.paths[route] == nil {
+		g.paths[route] = make(map[string]interface{})
+	}
+	if _, exists := g.paths[route][method]; exists {
+		return fmt.Errorf("重复的接口 %s %s", strings.ToUpper(method), route)
+	}
+	g.paths[route][method] = operation
+	return nil
+}
+
+// receiverName 返回方法接收者的类型名，函数返回空字符串
+func receiverName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil {
+		return ""
+	}
+	expr := fn.Recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// mimeType 将注释中的简写转换为媒体类型
+func mimeType(value string) string {
+	switch value {
+	case "json":
+		return "application/json"
+	case "mpfd":
+		return "multipart/form-data"
+	case "plain":
+		return "text/plain"
+	}
+	return value
+}
+
+func first(values []string, fallback string) string {
+	if len(values) == 0 {
+		return fallback
+	}
+	return values[0]
+}
+
+// annotationSchema 解析注释中的类型，如 int、[]model.File、model.Response{data=model.User}
+func (g *generator) annotationSchema(importMap map[string]string, typeName string) (map[string]interface{}, error) {
+	if strings.HasPrefix(typeName, "[]") {
+		items, err := g.annotationSchema(importMap, typeName[2:])
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	}
+
+	base, overrides, hasOverrides := strings.Cut(typeName, "{")
+	if base == "interface" && overrides == "}" {
+		return map[string]interface{}{}, nil
+	}
+	var schema map[string]interface{}
+	switch base {
+	case "int", "integer":
+		schema = map[string]interface{}{"type": "integer"}
+	case "number", "float64":
+		schema = map[string]interface{}{"type": "number"}
+	case "bool", "boolean":
+		schema = map[string]interface{}{"type": "boolean"}
+	case "string":
+		schema = map[string]interface{}{"type": "string"}
+	case "file":
+		schema = map[string]interface{}{"type": "string", "format": "binary"}
+	default:
+		pkgName, name, ok := strings.Cut(base, ".")
+		if !ok {
+			return nil, fmt.Errorf("类型 %q 需要包名", base)
+		}
+		importPath, imported := importMap[pkgName]
+		if !imported {
+			importPath = g.module + "/internal/" + pkgName
+		}
+		var err error
+		if schema, err = g.namedSchema(importPath, name); err != nil {
+			return nil, err
+		}
+	}
+	if !hasOverrides {
+		return schema, nil
+	}
+
+	// model.Response{data=model.User} 表示将 Response 的 data 字段替换为指定类型
+	properties := make(map[string]interface{})
+	for _, field := range splitOverrides(strings.TrimSuffix(overrides, "}")) {
+		name, fieldType, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("无法解析类型 %q", typeName)
+		}
+		fieldSchema, err := g.annotationSchema(importMap, fieldType)
+		if err != nil {
+			return nil, err
+		}
+		properties[name] = fieldSchema
+	}
+	return map[string]interface{}{
+		"allOf": []interface{}{schema, map[string]interface{}{"type": "object", "properties": properties}},
+	}, nil
+}
+
+// splitOverrides 按不在花括号中的逗号拆分字段替换
+func splitOverrides(value string) []string {
+	var fields []string
+	depth, start := 0, 0
+	for i, r := range value {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				fields = append(fields, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(fields, value[start:])
+}
+
+// namedSchema 返回命名类型的引用，结构体在 components.schemas 中生成一次
+func (g *generator) namedSchema(importPath, name string) (map[string]interface{}, error) {
+	switch importPath + "." + name {
+	case "time.Time":
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	case "time.Duration":
+		return map[string]interface{}{"type": "integer", "description": "纳秒"}, nil
+	}
+	pkg, err := g.loadPackage(importPath)
+	if err != nil {
+		return nil, err
+	}
+	if pkg == nil {
+		// 模块外的类型无法获得字段，按任意值处理
+		return map[string]interface{}{}, nil
+	}
+	decl, exists := pkg.types[name]
+	if !exists {
+		return nil, fmt.Errorf("找不到类型 %s.%s", importPath, name)
+	}
+	if decl.spec.TypeParams != nil {
+		return map[string]interface{}{}, nil
+	}
+	if _, isStruct := decl.spec.Type.(*ast.StructType); !isStruct {
+		// 基于基本类型的命名类型（如字符串枚举）直接展开
+		return g.typeSchema(imports(decl.file), importPath, decl.spec.Type)
+	}
+
+	schemaName := pkg.name + "." + name
+	ref := map[string]interface{}{"$ref": "#/components/schemas/" + schemaName}
+	if _, exists := g.schemas[schemaName]; exists {
+		return ref, nil
+	}
+	g.schemas[schemaName] = map[string]interface{}{} // 占位，处理递归引用
+	schema, err := g.typeSchema(imports(decl.file), importPath, decl.spec.Type)
+	if err != nil {
+		return nil, err
+	}
+	if description := docText(decl.doc, name); description != "" {
+		schema["description"] = description
+	}
+	g.schemas[schemaName] = schema
+	return ref, nil
+}
+
+// typeSchema 将类型表达式转换为 schema，importPath 为类型所在的包
+func (g *generator) typeSchema(importMap map[string]string, importPath string, expr ast.Expr) (map[string]interface{}, error) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		switch expr.Name {
+		case "string":
+			return map[string]interface{}{"type": "string"}, nil
+		case "bool":
+			return map[string]interface{}{"type": "boolean"}, nil
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+			return map[string]interface{}{"type": "integer"}, nil
+		case "float32", "float64":
+			return map[string]interface{}{"type": "number"}, nil
+		case "any", "error":
+			return map[string]interface{}{}, nil
+		}
+		return g.namedSchema(importPath, expr.Name)
+	case *ast.SelectorExpr:
+		pkgName, ok := expr.X.(*ast.Ident)
+		if !ok {
+			return map[string]interface{}{}, nil
+		}
+		return g.namedSchema(importMap[pkgName.Name], expr.Sel.Name)
+	case *ast.StarExpr:
+		return g.typeSchema(importMap, importPath, expr.X)
+	case *ast.ArrayType:
+		if ident, ok := expr.Elt.(*ast.Ident); ok && ident.Name == "byte" {
+			return map[string]interface{}{"type": "string", "format": "byte"}, nil
+		}
+		items, err := g.typeSchema(importMap, importPath, expr.Elt)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case *ast.MapType:
+		values, err := g.typeSchema(importMap, importPath, expr.Value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case *ast.StructType:
+		return g.structSchema(importMap, importPath, expr)
+	}
+	// 接口、函数、通道等类型按任意值处理
+	return map[string]interface{}{}, nil
+}
+
+// structSchema 按 json 标签生成结构体的 schema，嵌入的结构体字段展开到外层，
+// binding:"required" 的字段为必填，binding 中的 oneof 生成枚举
+func (g *generator) structSchema(importMap map[string]string, importPath string, st *ast.StructType) (map[string]interface{}, error) {
+	properties := make(map[string]interface{})
+	var required []string
+	var allOf []interface{}
+	for _, field := range st.Fields.List {
+		tag := reflect.StructTag("")
+		if field.Tag != nil {
+			value, _ := strconv.Unquote(field.Tag.Value)
+			tag = reflect.StructTag(value)
+		}
+		jsonName, jsonOptions, _ := strings.Cut(tag.Get("json"), ",")
+		if jsonName == "-" && jsonOptions == "" {
+			continue
+		}
+
+		names := field.Names
+		if len(names) == 0 {
+			if jsonName == "" {
+				embedded, err := g.typeSchema(importMap, importPath, field.Type)
+				if err != nil {
+					return nil, err
+				}
+				allOf = append(allOf, embedded)
+				continue
+			}
+			names = []*ast.Ident{ast.NewIdent(jsonName)}
+		}
+
+		for _, ident := range names {
+			if !ident.IsExported() && jsonName == "" {
+				continue
+			}
+			name := ident.Name
+			if jsonName != "" {
+				name = jsonName
+			}
+			schema, err := g.typeSchema(importMap, importPath, field.Type)
+			if err != nil {
+				return nil, err
+			}
+			if description := fieldDoc(field); description != "" {
+				if _, isRef := schema["$ref"]; isRef {
+					schema = map[string]interface{}{"allOf": []interface{}{schema}, "description": description}
+				} else {
+					schema["description"] = description
+				}
+			}
+
+			// dive 之后的规则作用于数组元素
+			target, dived := schema, false
+			for _, rule := range strings.Split(tag.Get("binding"), ",") {
+				if rule == "dive" {
+					if items, ok := target["items"].(map[string]interface{}); ok {
+						target, dived = items, true
+						continue
+					}
+					break
+				}
+				if rule == "required" && !dived {
+					required = append(required, name)
+				}
+				if values, ok := strings.CutPrefix(rule, "oneof="); ok {
+					target["enum"] = strings.Fields(values)
+				}
+			}
+			properties[name] = schema
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	if len(allOf) > 0 {
+		return map[string]interface{}{"allOf": append(allOf, schema)}, nil
+	}
+	return schema, nil
+}
+
+// docText 返回类型注释去掉开头类型名后的说明
+func docText(doc *ast.CommentGroup, name string) string {
+	if doc == nil {
+		return ""
+	}
+	text := strings.TrimSpace(doc.Text())
+	text = strings.TrimSpace(strings.TrimPrefix(text, name))
+	return text
+}
+
+// fieldDoc 返回字段的行尾注释或字段前的注释
+func fieldDoc(field *ast.Field) string {
+	if field.Comment != nil {
+		return strings.TrimSpace(field.Comment.Text())
+	}
+	if field.Doc != nil {
+		return strings.TrimSpace(field.Doc.Text())
+	}
+	return ""
+}
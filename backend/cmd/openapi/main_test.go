@@ -0,0 +1,22 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// TestGenerate_UpToDate 修改接口注释后需要在 internal/handler 下运行 go generate 更新文档
+func TestGenerate_UpToDate(t *testing.T) {
+	spec, err := generate("../../internal/handler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := os.ReadFile("../../internal/handler/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(spec, committed) {
+		t.Error("internal/handler/openapi.json 不是最新的，请在 internal/handler 下运行 go generate")
+	}
+}
@@ -0,0 +1,193 @@
+// Package access 实现共享数据集的行级和列级访问控制：按访问规则过滤用户可见的行，
+// 并隐藏未授权的列。预览、SQL查询、智能体读取的数据视图都通过同一个 Restriction 处理
+package access
+
+import (
+	"fmt"
+	"strings"
+
+	"smart-analysis/internal/analytics"
+)
+
+// Rule 一条访问规则：满足 RowFilter 的行中 Columns 列可见。
+// RowFilter 为空表示所有行，Columns 为空表示所有列
+type Rule struct {
+	RowFilter string
+	Columns   []string
+}
+
+// Restriction 用户对一个数据集的有效访问限制。多条规则取并集：
+// 一行满足任一规则的条件即可见，行中的单元格只有在该行满足的规则授权了这一列时才可见，否则置空。
+// nil 表示不受限制
+type Restriction struct {
+	grants []grant
+}
+
+// grant 解析后的规则，columns 为空表示所有列
+type grant struct {
+	filter  *Filter
+	columns map[string]bool
+}
+
+// Combine 解析访问规则并合并为访问限制，规则为空时返回的限制不允许访问任何数据
+func Combine(rules []Rule) (*Restriction, error) {
+	restriction := &Restriction{}
+	for _, rule := range rules {
+		g := grant{}
+		if strings.TrimSpace(rule.RowFilter) != "" {
+			filter, err := ParseFilter(rule.RowFilter)
+			if err != nil {
+				return nil, err
+			}
+			g.filter = filter
+		}
+		if len(rule.Columns) > 0 {
+			g.columns = make(map[string]bool, len(rule.Columns))
+			for _, column := range rule.Columns {
+				g.columns[columnKey(column)] = true
+			}
+		}
+		restriction.grants = append(restriction.grants, g)
+	}
+	return restriction, nil
+}
+
+// Table 返回按访问限制过滤后的数据表副本
+func (r *Restriction) Table(table *analytics.Table) (*analytics.Table, error) {
+	if r == nil {
+		return table, nil
+	}
+	columns, rows, err := apply(r, table.Columns, table.Rows, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &analytics.Table{Columns: columns, Rows: rows}, nil
+}
+
+// Strings 按访问限制过滤字符串表格，不可见的单元格置为空字符串
+func (r *Restriction) Strings(headers []string, rows [][]string) ([]string, [][]string, error) {
+	if r == nil {
+		return headers, rows, nil
+	}
+	return apply(r, headers, rows, "")
+}
+
+// Records 按访问限制过滤JSON对象数组，不可见的字段被删除，对象中缺少的字段按 NULL 参与条件判断。
+// 数组中的非对象元素只有在存在不限行和列的规则时才可见
+func (r *Restriction) Records(records []interface{}) []interface{} {
+	if r == nil {
+		return records
+	}
+
+	unrestricted := false
+	for _, g := range r.grants {
+		unrestricted = unrestricted || g.filter == nil && g.columns == nil
+	}
+
+	output := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		item, ok := record.(map[string]interface{})
+		if !ok {
+			if unrestricted {
+				output = append(output, record)
+			}
+			continue
+		}
+
+		fields := make(map[string]interface{}, len(item))
+		for key, v := range item {
+			fields[columnKey(key)] = v
+		}
+		matched := r.matches(func(column string) interface{} { return fields[columnKey(column)] })
+		if len(matched) == 0 {
+			continue
+		}
+
+		visible := make(map[string]interface{}, len(item))
+		for key, v := range item {
+			if granted(matched, key) {
+				visible[key] = v
+			}
+		}
+		output = append(output, visible)
+	}
+	return output
+}
+
+// matches 返回一行满足的规则
+func (r *Restriction) matches(value func(string) interface{}) []grant {
+	var matched []grant
+	for _, g := range r.grants {
+		if g.filter == nil || g.filter.Match(value) {
+			matched = append(matched, g)
+		}
+	}
+	return matched
+}
+
+// granted 判断列是否被任一规则授权
+func granted(grants []grant, column string) bool {
+	for _, g := range grants {
+		if g.columns == nil || g.columns[columnKey(column)] {
+			return true
+		}
+	}
+	return false
+}
+
+// apply 过滤行并只保留被授权的列，条件引用表中不存在的列时返回错误，避免按错误的条件放行数据
+func apply[T any](r *Restriction, headers []string, rows [][]T, empty T) ([]string, [][]T, error) {
+	index := make(map[string]int, len(headers))
+	for i, header := range headers {
+		if _, exists := index[columnKey(header)]; !exists {
+			index[columnKey(header)] = i
+		}
+	}
+	for _, g := range r.grants {
+		if g.filter == nil {
+			continue
+		}
+		for _, column := range g.filter.columns {
+			if _, ok := index[columnKey(column)]; !ok {
+				return nil, nil, fmt.Errorf("行过滤条件引用了不存在的列 %s", column)
+			}
+		}
+	}
+
+	var keep []int
+	visibleHeaders := make([]string, 0, len(headers))
+	for i, header := range headers {
+		if granted(r.grants, header) {
+			keep = append(keep, i)
+			visibleHeaders = append(visibleHeaders, header)
+		}
+	}
+
+	visibleRows := make([][]T, 0, len(rows))
+	for _, row := range rows {
+		matched := r.matches(func(column string) interface{} {
+			if i := index[columnKey(column)]; i < len(row) {
+				return row[i]
+			}
+			return nil
+		})
+		if len(matched) == 0 {
+			continue
+		}
+
+		visible := make([]T, len(keep))
+		for j, i := range keep {
+			visible[j] = empty
+			if i < len(row) && granted(matched, headers[i]) {
+				visible[j] = row[i]
+			}
+		}
+		visibleRows = append(visibleRows, visible)
+	}
+	return visibleHeaders, visibleRows, nil
+}
+
+// columnKey 列名比较时忽略大小写和首尾空白
+func columnKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,115 @@
+package access
+
+import (
+	"reflect"
+	"testing"
+
+	"smart-analysis/internal/analytics"
+)
+
+func TestParseFilter(t *testing.T) {
+	valid := []string{
+		"region = 'East'",
+		"amount >= 100 AND NOT (region IN ('West', 'North'))",
+		"name LIKE '张%' OR created_at BETWEEN '2024-01-01' AND '2024-06-30'",
+		"manager IS NOT NULL",
+	}
+	for _, expr := range valid {
+		if _, err := ParseFilter(expr); err != nil {
+			t.Errorf("%s: %v", expr, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"region = 'East' UNION SELECT * FROM secrets",
+		"region = 'East' LIMIT 1",
+		"region IN (SELECT region FROM t)",
+		"lower(region) = 'east'",
+		"EXISTS (SELECT 1)",
+		"amount + 1 > 2",
+		"region = ?",
+	}
+	for _, expr := range invalid {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("应拒绝过滤条件: %s", expr)
+		}
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	row := map[string]interface{}{"region": "East", "amount": "120", "manager": "", "name": "张三"}
+	value := func(column string) interface{} { return row[column] }
+
+	cases := map[string]bool{
+		"region = 'East'":                     true,
+		"region = 'east'":                     false,
+		"amount > 99":                         true, // 按数值比较，而不是字符串
+		"amount BETWEEN 100 AND 200":          true,
+		"region IN ('West', 'North')":         false,
+		"region NOT IN ('West', 'North')":     true,
+		"name LIKE '张%'":                      true,
+		"manager IS NULL":                     true, // 空单元格视为 NULL
+		"manager = 'x' OR region = 'East'":    true,
+		"NOT (manager = 'x')":                 false, // NULL 的否定仍为 NULL
+		"manager NOT IN ('x') AND amount > 0": false,
+	}
+	for expr, want := range cases {
+		filter, err := ParseFilter(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if got := filter.Match(value); got != want {
+			t.Errorf("%s: 期望 %v，实际 %v", expr, want, got)
+		}
+	}
+}
+
+func TestRestriction(t *testing.T) {
+	table := &analytics.Table{
+		Columns: []string{"region", "product", "amount", "cost"},
+		Rows: [][]interface{}{
+			{"East", "A", "100", "60"},
+			{"West", "B", "200", "150"},
+			{"North", "C", "300", "100"},
+		},
+	}
+
+	// 东区可以看到除成本外的所有列，西区只能看到区域和产品，北区不可见
+	restriction, err := Combine([]Rule{
+		{RowFilter: "region = 'East'", Columns: []string{"region", "product", "amount"}},
+		{RowFilter: "Region = 'West'", Columns: []string{"REGION", "product"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restricted, err := restriction.Table(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &analytics.Table{
+		Columns: []string{"region", "product", "amount"},
+		Rows:    [][]interface{}{{"East", "A", "100"}, {"West", "B", nil}},
+	}
+	if !reflect.DeepEqual(restricted, want) {
+		t.Errorf("过滤结果不正确: %+v", restricted)
+	}
+
+	if _, err := restriction.Table(&analytics.Table{Columns: []string{"product"}}); err == nil {
+		t.Error("条件引用不存在的列时应返回错误")
+	}
+
+	none, _ := Combine(nil)
+	if headers, rows, _ := none.Strings([]string{"region"}, [][]string{{"East"}}); len(headers) != 0 || len(rows) != 0 {
+		t.Errorf("没有规则时不应返回数据: %v %v", headers, rows)
+	}
+
+	records := restriction.Records([]interface{}{
+		map[string]interface{}{"region": "East", "amount": 1.0, "cost": 2.0},
+		map[string]interface{}{"region": "North", "amount": 3.0},
+		"scalar",
+	})
+	if !reflect.DeepEqual(records, []interface{}{map[string]interface{}{"region": "East", "amount": 1.0}}) {
+		t.Errorf("JSON记录过滤不正确: %v", records)
+	}
+}
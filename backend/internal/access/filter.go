@@ -0,0 +1,321 @@
+package access
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"smart-analysis/internal/sqlguard"
+)
+
+// Filter 行过滤条件，语法为SQL布尔表达式（如 region = 'East' AND amount > 100），
+// 只支持比较、AND/OR/NOT、IN、BETWEEN、LIKE 和 IS NULL，不支持函数和子查询。
+// 条件在服务端按行求值，不会拼接到SQL中执行
+type Filter struct {
+	expr    string
+	root    sqlguard.Node
+	columns []string
+}
+
+// ParseFilter 解析并校验行过滤条件
+func ParseFilter(expr string) (*Filter, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("行过滤条件为空")
+	}
+
+	query, err := sqlguard.Parse("SELECT * FROM t WHERE "+expr, sqlguard.DialectSQLite)
+	if err != nil {
+		return nil, fmt.Errorf("行过滤条件语法错误: %w", err)
+	}
+	sel, ok := query.Body.(*sqlguard.Select)
+	if !ok || query.With != nil || query.OrderBy != nil || query.Limit != nil || query.Locking != "" ||
+		sel.Where == nil || sel.GroupBy != nil || sel.Having != nil || sel.Windows != nil {
+		return nil, fmt.Errorf("行过滤条件只能是单个布尔表达式")
+	}
+
+	filter := &Filter{expr: expr, root: sel.Where}
+	seen := make(map[string]bool)
+	var invalid error
+	sqlguard.Walk(sel.Where, func(node sqlguard.Node) bool {
+		if invalid != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *sqlguard.ColumnRef:
+			name := n.Parts[len(n.Parts)-1]
+			if !seen[columnKey(name)] {
+				seen[columnKey(name)] = true
+				filter.columns = append(filter.columns, name)
+			}
+		case *sqlguard.BinaryExpr:
+			if !binaryOps[n.Op] {
+				invalid = fmt.Errorf("行过滤条件不支持运算符 %s", n.Op)
+			}
+		case *sqlguard.UnaryExpr:
+			if n.Op != "NOT" && n.Op != "-" {
+				invalid = fmt.Errorf("行过滤条件不支持运算符 %s", n.Op)
+			}
+		case *sqlguard.IsExpr:
+			if n.Value != "NULL" {
+				invalid = fmt.Errorf("行过滤条件只支持 IS [NOT] NULL")
+			}
+		case *sqlguard.InExpr:
+			if n.Subquery != nil {
+				invalid = fmt.Errorf("行过滤条件不支持子查询")
+			}
+		case *sqlguard.LikeExpr:
+			if n.Op != "LIKE" || n.Escape != nil {
+				invalid = fmt.Errorf("行过滤条件只支持不带 ESCAPE 的 LIKE")
+			}
+		case *sqlguard.Literal, *sqlguard.BetweenExpr:
+		case *sqlguard.ListExpr:
+			if len(n.Items) != 1 {
+				invalid = fmt.Errorf("行过滤条件不支持行构造器")
+			}
+		default:
+			invalid = fmt.Errorf("行过滤条件不支持 %T", node)
+		}
+		return invalid == nil
+	})
+	if invalid != nil {
+		return nil, invalid
+	}
+	return filter, nil
+}
+
+// String 返回过滤条件的原始表达式
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// Columns 返回条件引用的列
+func (f *Filter) Columns() []string {
+	return append([]string{}, f.columns...)
+}
+
+// Match 判断一行是否满足条件，value 按列名返回单元格的值。
+// 按SQL三值逻辑求值，结果为 NULL 时视为不满足
+func (f *Filter) Match(value func(column string) interface{}) bool {
+	result, _ := eval(f.root, value).(bool)
+	return result
+}
+
+// binaryOps 行过滤条件支持的二元运算符
+var binaryOps = map[string]bool{
+	"AND": true, "OR": true,
+	"=": true, "==": true, "!=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true,
+}
+
+// eval 求值表达式，返回 nil（NULL）、bool、float64 或 string
+func eval(node sqlguard.Node, value func(string) interface{}) interface{} {
+	switch n := node.(type) {
+	case *sqlguard.Literal:
+		switch n.Kind {
+		case "number":
+			if f, err := strconv.ParseFloat(n.Value, 64); err == nil {
+				return f
+			}
+			return n.Value
+		case "string":
+			return n.Value
+		case "bool":
+			return n.Value == "TRUE"
+		}
+		return nil
+	case *sqlguard.ColumnRef:
+		return cellValue(value(n.Parts[len(n.Parts)-1]))
+	case *sqlguard.ListExpr:
+		return eval(n.Items[0], value)
+	case *sqlguard.UnaryExpr:
+		operand := eval(n.Expr, value)
+		if n.Op == "-" {
+			if f, ok := number(operand); ok {
+				return -f
+			}
+			return nil
+		}
+		return not(truth(operand))
+	case *sqlguard.BinaryExpr:
+		switch n.Op {
+		case "AND":
+			left, right := truth(eval(n.Left, value)), truth(eval(n.Right, value))
+			if left == false || right == false {
+				return false
+			}
+			if left == nil || right == nil {
+				return nil
+			}
+			return true
+		case "OR":
+			left, right := truth(eval(n.Left, value)), truth(eval(n.Right, value))
+			if left == true || right == true {
+				return true
+			}
+			if left == nil || right == nil {
+				return nil
+			}
+			return false
+		}
+		cmp, ok := compare(eval(n.Left, value), eval(n.Right, value))
+		if !ok {
+			return nil
+		}
+		switch n.Op {
+		case "=", "==":
+			return cmp == 0
+		case "!=", "<>":
+			return cmp != 0
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		case ">":
+			return cmp > 0
+		default:
+			return cmp >= 0
+		}
+	case *sqlguard.IsExpr:
+		isNull := eval(n.Expr, value) == nil
+		return isNull != n.Not
+	case *sqlguard.InExpr:
+		left := eval(n.Expr, value)
+		var result interface{} = false
+		for _, item := range n.List {
+			cmp, ok := compare(left, eval(item, value))
+			if !ok {
+				result = nil
+				continue
+			}
+			if cmp == 0 {
+				result = true
+				break
+			}
+		}
+		if n.Not {
+			return not(result)
+		}
+		return result
+	case *sqlguard.BetweenExpr:
+		left := eval(n.Expr, value)
+		low, lowOK := compare(left, eval(n.Low, value))
+		high, highOK := compare(left, eval(n.High, value))
+		if !lowOK || !highOK {
+			return nil
+		}
+		return (low >= 0 && high <= 0) != n.Not
+	case *sqlguard.LikeExpr:
+		left, pattern := eval(n.Expr, value), eval(n.Pattern, value)
+		if left == nil || pattern == nil {
+			return nil
+		}
+		return likePattern(text(pattern)).MatchString(text(left)) != n.Not
+	}
+	return nil
+}
+
+// cellValue 统一单元格的值，空字符串视为 NULL
+func cellValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case string:
+		if strings.TrimSpace(x) == "" {
+			return nil
+		}
+		return x
+	case bool, float64:
+		return x
+	case int:
+		return float64(x)
+	case int64:
+		return float64(x)
+	default:
+		return fmt.Sprintf("%v", x)
+	}
+}
+
+// truth 将值转换为三值逻辑的结果
+func truth(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case bool:
+		return x
+	}
+	if f, ok := number(v); ok {
+		return f != 0
+	}
+	return false
+}
+
+func not(v interface{}) interface{} {
+	if b, ok := v.(bool); ok {
+		return !b
+	}
+	return nil
+}
+
+// compare 比较两个值，两边都能解析为数值时按数值比较，否则按字符串比较，任一侧为 NULL 时无法比较
+func compare(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return strings.Compare(text(a), text(b)), true
+}
+
+func number(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func text(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return strings.TrimSpace(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// likePattern 将 LIKE 模式转换为正则表达式，与 SQLite 一致不区分大小写
+func likePattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
@@ -2,12 +2,15 @@ package agents
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/flow/agent/react"
 	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/prompts"
+	"smart-analysis/internal/scheduler"
 	"smart-analysis/internal/tools"
 	"smart-analysis/internal/types"
 )
@@ -59,12 +62,31 @@ func NewReactAgent(ctx context.Context, config *types.AgentConfig) (*ReactAgent,
 		toolsList = append(toolsList, reportTool)
 	}
 
+	// 添加SQL查询工具（不依赖Python沙盒）
+	toolsList = append(toolsList, tools.NewSQLQueryTool(config.Datasets))
+
 	// 添加用户提供的工具
 	toolsList = append(toolsList, config.Tools...)
 
+	// 读取数据文件的参数统一经过数据集解析
+	for i, t := range toolsList {
+		toolsList[i] = tools.WithDatasets(t, config.Datasets)
+	}
+
+	toolModel, ok := config.ChatModel.(model.ToolCallingChatModel)
+	if !ok {
+		return nil, errors.New("聊天模型不支持工具调用")
+	}
+
+	// 渲染系统提示
+	systemPrompt, err := promptLibrary(config).Render(prompts.TemplateReactSystem, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	// 创建React智能体配置
 	reactConfig := &react.AgentConfig{
-		ToolCallingModel: config.ChatModel.(model.ToolCallingChatModel),
+		ToolCallingModel: toolModel,
 		ToolsConfig: compose.ToolsNodeConfig{
 			Tools: toolsList,
 		},
@@ -72,26 +94,8 @@ func NewReactAgent(ctx context.Context, config *types.AgentConfig) (*ReactAgent,
 		MessageModifier: func(ctx context.Context, input []*schema.Message) []*schema.Message {
 			// 添加系统提示来增强数据分析能力
 			systemMessage := &schema.Message{
-				Role: schema.System,
-				Content: `你是一个专业的数据分析助手。你拥有以下能力：
-
-1. 使用python_analysis工具执行Python代码进行数据分析和统计计算
-2. 使用echarts_visualization工具创建ECharts格式的交互式图表
-3. 使用file_reader工具读取和预览数据文件
-4. 使用data_query工具进行数据查询和筛选
-5. 使用data_preprocessing工具进行数据预处理和特征工程
-6. 使用ml_analysis工具进行机器学习分析（分类、回归、聚类）
-
-当用户询问数据分析相关问题时：
-- 首先理解用户的需求和数据
-- 选择合适的工具来完成分析任务
-- 为用户提供清晰的分析结果和解释
-- 对于图表，优先使用echarts_visualization工具生成可交互的图表配置
-- 对于复杂的数据处理，可以先使用data_preprocessing工具预处理数据
-- 对于机器学习任务，使用ml_analysis工具进行建模和评估
-- 如果需要，可以建议进一步的分析方向
-
-请始终保持专业、准确，并提供有价值的洞察。`,
+				Role:    schema.System,
+				Content: systemPrompt,
 			}
 
 			// 检查是否已经有系统消息
@@ -198,3 +202,19 @@ func (a *MainAgent) Initialize(ctx context.Context) error {
 func (a *MainAgent) Shutdown(ctx context.Context) error {
 	return a.manager.Shutdown(ctx)
 }
+
+// promptLibrary 获取智能体使用的提示词模板库
+func promptLibrary(config *types.AgentConfig) *prompts.Library {
+	if config != nil && config.Prompts != nil {
+		return config.Prompts
+	}
+	return prompts.GetGlobalLibrary()
+}
+
+// taskScheduler 获取智能体使用的调度器
+func taskScheduler(config *types.AgentConfig) *scheduler.Scheduler {
+	if config != nil && config.Scheduler != nil {
+		return config.Scheduler
+	}
+	return scheduler.GetGlobal()
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/prompts"
 	"smart-analysis/internal/types"
 	"smart-analysis/internal/utils/sanbox"
 )
@@ -46,7 +47,7 @@ func (a *AnalysisAgent) Generate(ctx context.Context, messages []*schema.Message
 	}
 
 	// 执行Python代码
-	result, err := a.sandbox.ExecutePython(code)
+	result, err := a.sandbox.ExecuteCodeContext(ctx, code)
 	if err != nil {
 		return &schema.Message{
 			Role:    schema.Assistant,
@@ -107,16 +108,10 @@ func (a *AnalysisAgent) Shutdown(ctx context.Context) error {
 // generateAnalysisCode 生成分析代码
 func (a *AnalysisAgent) generateAnalysisCode(ctx context.Context, messages []*schema.Message) (string, error) {
 	// 构建代码生成的系统提示
-	systemPrompt := `你是一个专业的Python数据分析师。请根据用户的需求生成高质量的Python代码。
-
-代码要求：
-1. 使用pandas, numpy, matplotlib, seaborn等常用库
-2. 代码完整且可执行
-3. 包含适当的注释
-4. 处理可能的错误情况
-5. 如果生成图表，保存为'output.png'
-
-请只返回Python代码，不要添加其他解释。`
+	systemPrompt, err := promptLibrary(a.config).Render(prompts.TemplateAnalysisCode, nil)
+	if err != nil {
+		return "", err
+	}
 
 	// 构建代码生成消息
 	codeMessages := []*schema.Message{
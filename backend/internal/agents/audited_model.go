@@ -0,0 +1,111 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/audit"
+)
+
+// auditedChatModel 将每次LLM调用的提示词和响应摘要记录到审计日志的聊天模型
+type auditedChatModel struct {
+	model    model.BaseChatModel
+	provider string
+}
+
+// auditedToolCallingChatModel 支持工具调用的 auditedChatModel
+type auditedToolCallingChatModel struct {
+	*auditedChatModel
+}
+
+// NewAuditedChatModel 包装聊天模型，每次调用后记录提示词和响应的SHA-256摘要，不保存原文。
+// 应放在脱敏之后，使摘要对应实际发送给LLM的内容；被包装的模型支持工具调用时返回的模型同样支持
+func NewAuditedChatModel(chatModel model.BaseChatModel, provider string) model.BaseChatModel {
+	audited := &auditedChatModel{model: chatModel, provider: provider}
+	if _, ok := chatModel.(model.ToolCallingChatModel); ok {
+		return &auditedToolCallingChatModel{audited}
+	}
+	return audited
+}
+
+// Generate 生成响应并记录审计事件
+func (m *auditedChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	output, err := m.model.Generate(ctx, input, opts...)
+	m.record(ctx, input, output, false, err)
+	return output, err
+}
+
+// Stream 流式生成响应，流结束后按拼接的完整响应记录审计事件
+func (m *auditedChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	stream, err := m.model.Stream(ctx, input, opts...)
+	if err != nil {
+		m.record(ctx, input, nil, true, err)
+		return nil, err
+	}
+
+	sr, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer stream.Close()
+		defer sw.Close()
+
+		var chunks []*schema.Message
+		for {
+			msg, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				output, concatErr := schema.ConcatMessages(chunks)
+				m.record(ctx, input, output, true, concatErr)
+				return
+			}
+			if err != nil {
+				m.record(ctx, input, nil, true, err)
+			} else {
+				chunks = append(chunks, msg)
+			}
+			if closed := sw.Send(msg, err); closed || err != nil {
+				if closed && err == nil {
+					// 调用方提前关闭流时按已收到的部分记录
+					output, _ := schema.ConcatMessages(chunks)
+					m.record(ctx, input, output, true, nil)
+				}
+				return
+			}
+		}
+	}()
+
+	return sr, nil
+}
+
+// WithTools 绑定工具，返回的模型同样记录审计事件
+func (m *auditedToolCallingChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound, err := m.model.(model.ToolCallingChatModel).WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	return &auditedToolCallingChatModel{&auditedChatModel{model: bound, provider: m.provider}}, nil
+}
+
+// record 记录一次LLM调用
+func (m *auditedChatModel) record(ctx context.Context, input []*schema.Message, output *schema.Message, stream bool, err error) {
+	detail := map[string]interface{}{
+		"messages":      len(input),
+		"stream":        stream,
+		"prompt_sha256": messageDigest(input),
+	}
+	if output != nil {
+		detail["response_sha256"] = messageDigest(output)
+	}
+	audit.Record(ctx, audit.Event{Action: audit.ActionLLMCall, Resource: m.provider, Detail: detail}.WithError(err))
+}
+
+// messageDigest 返回消息序列化后的摘要
+func messageDigest(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return audit.Digest(string(data))
+}
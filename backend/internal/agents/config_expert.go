@@ -0,0 +1,341 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/flow/agent/react"
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/tools"
+	"smart-analysis/internal/types"
+)
+
+// expertTypePattern 配置专家的类型名格式
+var expertTypePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ConfigExpertSpec 通过配置文件声明的专家智能体：系统提示加上可用的工具子集，无需编写Go代码
+type ConfigExpertSpec struct {
+	Type         string          `json:"type"`
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	Capabilities []string        `json:"capabilities"`
+	TaskTypes    []string        `json:"task_types,omitempty"`
+	InputSchema  json.RawMessage `json:"input_schema,omitempty"`
+	Prompt       string          `json:"prompt"`
+	Tools        []string        `json:"tools,omitempty"`     // 工具名称，与 tools.ToolRegistry 中的名称一致
+	MaxSteps     int             `json:"max_steps,omitempty"` // 使用工具时的最大推理步数，0表示使用全局配置
+}
+
+// Definition 校验配置并生成专家注册信息
+func (s *ConfigExpertSpec) Definition() (*ExpertDefinition, error) {
+	if !expertTypePattern.MatchString(s.Type) {
+		return nil, fmt.Errorf("专家类型 %q 不合法，只能包含小写字母、数字和下划线", s.Type)
+	}
+	if strings.TrimSpace(s.Prompt) == "" {
+		return nil, fmt.Errorf("专家 %s 缺少 prompt", s.Type)
+	}
+	if len(s.Capabilities) == 0 {
+		return nil, fmt.Errorf("专家 %s 缺少 capabilities", s.Type)
+	}
+
+	knownTools := tools.NewToolRegistry(nil)
+	knownTools.RegisterAllTools()
+	for _, name := range s.Tools {
+		if _, ok := knownTools.GetTool(name); !ok {
+			return nil, fmt.Errorf("专家 %s 引用了未知的工具: %s", s.Type, name)
+		}
+	}
+
+	agentType := types.AgentType(s.Type)
+	def := &ExpertDefinition{
+		Type:         agentType,
+		Name:         s.Name,
+		Description:  s.Description,
+		Capabilities: s.Capabilities,
+		TaskTypes:    s.TaskTypes,
+	}
+	if len(s.InputSchema) > 0 {
+		inputSchema, err := types.NewJSONSchema(s.Type+"Input", s.InputSchema)
+		if err != nil {
+			return nil, fmt.Errorf("专家 %s 的 input_schema 不合法: %w", s.Type, err)
+		}
+		def.InputSchema = inputSchema
+	}
+	def.Factory = func(ctx context.Context, config *types.AgentConfig) (types.ExpertAgent, error) {
+		return NewConfigExpertAgent(ctx, config, s, def)
+	}
+
+	return def, nil
+}
+
+// LoadConfigExperts 读取目录下所有 *.json 专家定义，目录不存在时返回空列表
+func LoadConfigExperts(dir string) ([]*ExpertDefinition, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	defs := make([]*ExpertDefinition, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取专家定义 %s 失败: %w", path, err)
+		}
+
+		var spec ConfigExpertSpec
+		if err := json.Unmarshal(data, &spec); err != nil {
+			return nil, fmt.Errorf("解析专家定义 %s 失败: %w", path, err)
+		}
+
+		def, err := spec.Definition()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		defs = append(defs, def)
+	}
+
+	return defs, nil
+}
+
+// RegisterConfigExperts 加载目录中的专家定义并注册到专家注册表
+func RegisterConfigExperts(dir string) error {
+	defs, err := LoadConfigExperts(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, def := range defs {
+		if err := RegisterExpert(def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConfigExpertAgent 配置定义的专家智能体。未配置工具时直接调用聊天模型，
+// 配置了工具时使用React智能体在工具子集内推理
+type ConfigExpertAgent struct {
+	spec      *ConfigExpertSpec
+	def       *ExpertDefinition
+	chatModel model.BaseChatModel
+	agent     *react.Agent
+	config    *types.AgentConfig
+}
+
+// NewConfigExpertAgent 创建配置定义的专家智能体
+func NewConfigExpertAgent(ctx context.Context, config *types.AgentConfig, spec *ConfigExpertSpec, def *ExpertDefinition) (*ConfigExpertAgent, error) {
+	agent := &ConfigExpertAgent{
+		spec:      spec,
+		def:       def,
+		chatModel: config.ChatModel,
+		config:    config,
+	}
+
+	if len(spec.Tools) == 0 {
+		return agent, nil
+	}
+
+	if config.PythonSandbox == nil {
+		return nil, fmt.Errorf("专家 %s 使用了工具，但Python沙盒未配置", spec.Type)
+	}
+	toolModel, ok := config.ChatModel.(model.ToolCallingChatModel)
+	if !ok {
+		return nil, fmt.Errorf("专家 %s 使用了工具，但聊天模型不支持工具调用", spec.Type)
+	}
+
+	registry := tools.NewToolRegistry(config.PythonSandbox)
+	registry.SetDataSources(config.DataSources)
+	registry.SetDatasets(config.Datasets)
+	registry.RegisterAllTools()
+	toolsList := make([]tool.BaseTool, 0, len(spec.Tools))
+	for _, name := range spec.Tools {
+		t, _ := registry.GetTool(name)
+		toolsList = append(toolsList, t)
+	}
+
+	maxSteps := spec.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = config.MaxSteps
+	}
+
+	reactAgent, err := react.NewAgent(ctx, &react.AgentConfig{
+		ToolCallingModel: toolModel,
+		ToolsConfig: compose.ToolsNodeConfig{
+			Tools: toolsList,
+		},
+		MaxStep: maxSteps,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建专家 %s 失败: %w", spec.Type, err)
+	}
+	agent.agent = reactAgent
+
+	return agent, nil
+}
+
+// GetType 获取智能体类型
+func (a *ConfigExpertAgent) GetType() types.AgentType {
+	return a.def.Type
+}
+
+// GetCapabilities 获取能力描述
+func (a *ConfigExpertAgent) GetCapabilities() []string {
+	return a.def.Capabilities
+}
+
+// CanHandle 判断是否能处理特定任务
+func (a *ConfigExpertAgent) CanHandle(task *types.Task) bool {
+	return a.def.CanHandle(task)
+}
+
+// Generate 生成响应
+func (a *ConfigExpertAgent) Generate(ctx context.Context, messages []*schema.Message, opts ...interface{}) (*schema.Message, error) {
+	content, err := a.run(ctx, messages)
+	if err != nil {
+		return &schema.Message{
+			Role:    schema.Assistant,
+			Content: fmt.Sprintf("%s执行失败: %v", a.displayName(), err),
+		}, nil
+	}
+
+	return &schema.Message{
+		Role:    schema.Assistant,
+		Content: content,
+	}, nil
+}
+
+// Stream 流式生成响应
+func (a *ConfigExpertAgent) Stream(ctx context.Context, messages []*schema.Message, opts ...interface{}) (*schema.StreamReader[*schema.Message], error) {
+	response, err := a.Generate(ctx, messages, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	sr, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer sw.Close()
+		sw.Send(response, nil)
+	}()
+
+	return sr, nil
+}
+
+// Initialize 初始化智能体
+func (a *ConfigExpertAgent) Initialize(ctx context.Context) error {
+	return nil
+}
+
+// Shutdown 关闭智能体
+func (a *ConfigExpertAgent) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ExecuteTask 执行任务
+func (a *ConfigExpertAgent) ExecuteTask(ctx context.Context, task *types.Task) (*types.TaskResult, error) {
+	if !a.CanHandle(task) {
+		return &types.TaskResult{
+			Success:    false,
+			Error:      "无法处理此类型的任务",
+			ExecutedBy: a.def.Type,
+		}, nil
+	}
+
+	content, err := a.run(ctx, []*schema.Message{
+		{
+			Role:    schema.User,
+			Content: a.buildTaskDescription(task),
+		},
+	})
+	if err != nil {
+		return &types.TaskResult{
+			Success:    false,
+			Error:      fmt.Sprintf("%s执行失败: %v", a.displayName(), err),
+			ExecutedBy: a.def.Type,
+		}, nil
+	}
+
+	return &types.TaskResult{
+		Success:    true,
+		Output:     content,
+		ExecutedBy: a.def.Type,
+		Metadata: map[string]interface{}{
+			"task_type": task.Type,
+		},
+	}, nil
+}
+
+// run 在配置的系统提示下执行对话
+func (a *ConfigExpertAgent) run(ctx context.Context, messages []*schema.Message) (string, error) {
+	input := append([]*schema.Message{
+		{
+			Role:    schema.System,
+			Content: a.spec.Prompt,
+		},
+	}, messages...)
+
+	var (
+		response *schema.Message
+		err      error
+	)
+	if a.agent != nil {
+		response, err = a.agent.Generate(ctx, input)
+	} else if a.chatModel != nil {
+		response, err = a.chatModel.Generate(ctx, input)
+	} else {
+		return "", fmt.Errorf("未配置聊天模型")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(response.Content), nil
+}
+
+// buildTaskDescription 将任务描述和输入整理为用户消息，data_source 替换为上游数据的说明
+func (a *ConfigExpertAgent) buildTaskDescription(task *types.Task) string {
+	var desc []string
+	if task.Description != "" {
+		desc = append(desc, "任务: "+task.Description)
+	}
+
+	input := task.Input
+	if inputMap, ok := task.Input.(map[string]interface{}); ok {
+		copied := make(map[string]interface{}, len(inputMap))
+		for key, value := range inputMap {
+			copied[key] = value
+		}
+		if dataSource, ok := copied["data_source"]; ok {
+			desc = append(desc, "基于数据源: "+describeDataSource(dataSource))
+			delete(copied, "data_source")
+		}
+		input = copied
+	}
+
+	if data, err := json.MarshalIndent(input, "", "  "); err == nil && string(data) != "null" && string(data) != "{}" {
+		desc = append(desc, "任务输入:\n"+string(data))
+	}
+
+	return strings.Join(desc, "\n")
+}
+
+// displayName 返回专家的显示名称
+func (a *ConfigExpertAgent) displayName() string {
+	if a.def.Name != "" {
+		return a.def.Name
+	}
+	return string(a.def.Type)
+}
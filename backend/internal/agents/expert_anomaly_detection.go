@@ -7,26 +7,65 @@ import (
 	"strings"
 
 	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/prompts"
 	"smart-analysis/internal/tools"
 	"smart-analysis/internal/types"
 	"smart-analysis/internal/utils/sanbox"
 )
 
+// anomalyDetectionExpert 异动检测专家的注册信息
+var anomalyDetectionExpert = &ExpertDefinition{
+	Type:        types.AgentTypeAnomalyDetection,
+	Name:        "异动检测",
+	Description: "识别指标中的异常值和异常波动",
+	Capabilities: []string{
+		"异常值检测",
+		"离群点分析",
+		"时间序列异常检测",
+		"统计异常检测",
+		"机器学习异常检测",
+		"异动根因分析",
+		"异常模式识别",
+		"实时异常监控",
+	},
+	TaskTypes: []string{"anomaly_detection", "outlier_detection", "anomaly"},
+	InputSchema: mustInputSchema(types.AgentTypeAnomalyDetection, `{
+  "type": "object",
+  "properties": {
+    "data_source": {"type": "string", "description": "上游任务ID"},
+    "target_variables": {"description": "检测的变量"},
+    "method": {"type": "string", "description": "检测方法，如 zscore、iqr、isolation_forest"},
+    "time_field": {"type": "string", "description": "时间字段"},
+    "threshold": {"type": "number", "description": "异常阈值"},
+    "group_by": {"description": "分组字段"},
+    "requirements": {"description": "特殊要求"}
+  }
+}`),
+	Factory: func(ctx context.Context, config *types.AgentConfig) (types.ExpertAgent, error) {
+		return NewAnomalyDetectionAgent(ctx, config)
+	},
+}
+
+func init() {
+	MustRegisterExpert(anomalyDetectionExpert)
+}
+
 // AnomalyDetectionAgent 异动分析专家智能体
 type AnomalyDetectionAgent struct {
 	chatModel model.BaseChatModel
 	sandbox   *sanbox.PythonSandbox
 	config    *types.AgentConfig
 	agentType types.AgentType
-	mlTool    *tools.MLAnalysisTool
+	mlTool    tool.BaseTool
 }
 
 // NewAnomalyDetectionAgent 创建异动检测专家智能体
 func NewAnomalyDetectionAgent(ctx context.Context, config *types.AgentConfig) (*AnomalyDetectionAgent, error) {
-	var mlTool *tools.MLAnalysisTool
+	var mlTool tool.BaseTool
 	if config.PythonSandbox != nil {
-		mlTool = tools.NewMLAnalysisTool(config.PythonSandbox)
+		mlTool = tools.WithDatasets(tools.NewMLAnalysisTool(config.PythonSandbox), config.Datasets)
 	}
 
 	return &AnomalyDetectionAgent{
@@ -45,24 +84,12 @@ func (a *AnomalyDetectionAgent) GetType() types.AgentType {
 
 // GetCapabilities 获取能力描述
 func (a *AnomalyDetectionAgent) GetCapabilities() []string {
-	return []string{
-		"异常值检测",
-		"离群点分析",
-		"时间序列异常检测",
-		"统计异常检测",
-		"机器学习异常检测",
-		"异动根因分析",
-		"异常模式识别",
-		"实时异常监控",
-	}
+	return anomalyDetectionExpert.Capabilities
 }
 
 // CanHandle 判断是否能处理特定任务
 func (a *AnomalyDetectionAgent) CanHandle(task *types.Task) bool {
-	return task.Type == "anomaly_detection" ||
-		task.Type == "outlier_detection" ||
-		task.Type == "anomaly" ||
-		task.AgentType == types.AgentTypeAnomalyDetection
+	return anomalyDetectionExpert.CanHandle(task)
 }
 
 // Generate 生成响应
@@ -77,7 +104,7 @@ func (a *AnomalyDetectionAgent) Generate(ctx context.Context, messages []*schema
 	}
 
 	// 执行异动检测
-	result, err := a.executeAnomalyDetection(anomalyCode)
+	result, _, err := a.executeAnomalyDetection(ctx, anomalyCode)
 	if err != nil {
 		return &schema.Message{
 			Role:    schema.Assistant,
@@ -148,7 +175,7 @@ func (a *AnomalyDetectionAgent) ExecuteTask(ctx context.Context, task *types.Tas
 	}
 
 	// 执行异动检测
-	result, err := a.executeAnomalyDetection(anomalyCode)
+	result, artifacts, err := a.executeAnomalyDetection(ctx, anomalyCode)
 	if err != nil {
 		return &types.TaskResult{
 			Success:    false,
@@ -161,6 +188,7 @@ func (a *AnomalyDetectionAgent) ExecuteTask(ctx context.Context, task *types.Tas
 		Success:    true,
 		Output:     result,
 		ExecutedBy: a.agentType,
+		Artifacts:  artifacts,
 		Metadata: map[string]interface{}{
 			"anomaly_code": anomalyCode,
 			"task_type":    task.Type,
@@ -191,28 +219,10 @@ func (a *AnomalyDetectionAgent) parseTaskInput(input interface{}) (map[string]in
 // generateAnomalyCode 从消息生成异动检测代码
 func (a *AnomalyDetectionAgent) generateAnomalyCode(ctx context.Context, messages []*schema.Message) (string, error) {
 	// 构建异动检测的系统提示
-	systemPrompt := `你是一个专业的异常检测和异动分析专家。请根据用户需求生成高质量的Python异动检测代码。
-
-代码要求：
-1. 使用pandas, numpy, matplotlib, seaborn, scikit-learn, scipy等专业库
-2. 包含完整的异动检测流程：
-   - 数据预处理和清洗
-   - 异常值检测和识别
-   - 异常模式分析
-   - 异动原因分析
-   - 结果可视化
-3. 支持多种异常检测方法：
-   - 统计方法（Z-score、IQR、Grubbs测试）
-   - 时间序列异常检测
-   - 机器学习方法（Isolation Forest、Local Outlier Factor、One-Class SVM）
-   - 基于密度的方法（DBSCAN）
-4. 提供异常程度评分
-5. 生成异常检测图表和报告
-6. 包含异动根因分析
-7. 包含适当的注释和错误处理
-8. 保存图表为'anomaly_output.png'
-
-请只返回Python代码，不要添加其他解释。`
+	systemPrompt, err := promptLibrary(a.config).Render(prompts.TemplateExpertAnomalyDetection, nil)
+	if err != nil {
+		return "", err
+	}
 
 	anomalyMessages := []*schema.Message{
 		{
@@ -251,7 +261,7 @@ func (a *AnomalyDetectionAgent) buildAnomalyDescription(anomalyReq map[string]in
 
 	// 检查数据源
 	if dataSource, ok := anomalyReq["data_source"]; ok {
-		desc = append(desc, fmt.Sprintf("基于数据源: %v", dataSource))
+		desc = append(desc, "基于数据源: "+describeDataSource(dataSource))
 	}
 
 	// 检查检测目标
@@ -294,18 +304,18 @@ func (a *AnomalyDetectionAgent) buildAnomalyDescription(anomalyReq map[string]in
 }
 
 // executeAnomalyDetection 执行异动检测
-func (a *AnomalyDetectionAgent) executeAnomalyDetection(code string) (string, error) {
+func (a *AnomalyDetectionAgent) executeAnomalyDetection(ctx context.Context, code string) (string, map[string]*types.Artifact, error) {
 	if a.sandbox == nil {
-		return "", fmt.Errorf("Python沙盒未配置")
+		return "", nil, fmt.Errorf("Python沙盒未配置")
 	}
 
-	result, err := a.sandbox.ExecutePython(code)
+	result, err := a.sandbox.ExecuteCodeContext(ctx, code)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	if !result.Success {
-		return "", fmt.Errorf("异动检测执行失败: %s", result.Error)
+		return "", nil, fmt.Errorf("异动检测执行失败: %s", result.Error)
 	}
 
 	response := "异动检测分析完成！\n\n"
@@ -318,7 +328,7 @@ func (a *AnomalyDetectionAgent) executeAnomalyDetection(code string) (string, er
 		response += "生成的异动分析图表: " + result.ImagePath + "\n"
 	}
 
-	return response, nil
+	return response, sandboxArtifacts(result), nil
 }
 
 // extractPythonCode 从响应中提取Python代码
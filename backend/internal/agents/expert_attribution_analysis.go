@@ -7,26 +7,66 @@ import (
 	"strings"
 
 	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/prompts"
 	"smart-analysis/internal/tools"
 	"smart-analysis/internal/types"
 	"smart-analysis/internal/utils/sanbox"
 )
 
+// attributionAnalysisExpert 归因分析专家的注册信息
+var attributionAnalysisExpert = &ExpertDefinition{
+	Type:        types.AgentTypeAttributionAnalysis,
+	Name:        "归因分析",
+	Description: "分析指标变化的原因和各因素的贡献度",
+	Capabilities: []string{
+		"因果关系分析",
+		"根因分析",
+		"贡献度分析",
+		"影响因子识别",
+		"特征重要性分析",
+		"相关性与因果性分析",
+		"回归分析",
+		"变化归因分析",
+	},
+	TaskTypes: []string{"attribution_analysis", "root_cause", "causal_analysis", "contribution_analysis"},
+	InputSchema: mustInputSchema(types.AgentTypeAttributionAnalysis, `{
+  "type": "object",
+  "properties": {
+    "data_source": {"type": "string", "description": "上游任务ID"},
+    "target_variable": {"type": "string", "description": "归因的目标指标"},
+    "factors": {"description": "候选影响因素"},
+    "method": {"type": "string", "description": "归因方法"},
+    "analysis_period": {"description": "分析周期"},
+    "group_by": {"description": "分组字段"},
+    "change_event": {"description": "需要解释的变化事件"},
+    "requirements": {"description": "特殊要求"}
+  }
+}`),
+	Factory: func(ctx context.Context, config *types.AgentConfig) (types.ExpertAgent, error) {
+		return NewAttributionAnalysisAgent(ctx, config)
+	},
+}
+
+func init() {
+	MustRegisterExpert(attributionAnalysisExpert)
+}
+
 // AttributionAnalysisAgent 归因分析专家智能体
 type AttributionAnalysisAgent struct {
 	chatModel model.BaseChatModel
 	sandbox   *sanbox.PythonSandbox
 	config    *types.AgentConfig
 	agentType types.AgentType
-	mlTool    *tools.MLAnalysisTool
+	mlTool    tool.BaseTool
 }
 
 // NewAttributionAnalysisAgent 创建归因分析专家智能体
 func NewAttributionAnalysisAgent(ctx context.Context, config *types.AgentConfig) (*AttributionAnalysisAgent, error) {
-	var mlTool *tools.MLAnalysisTool
+	var mlTool tool.BaseTool
 	if config.PythonSandbox != nil {
-		mlTool = tools.NewMLAnalysisTool(config.PythonSandbox)
+		mlTool = tools.WithDatasets(tools.NewMLAnalysisTool(config.PythonSandbox), config.Datasets)
 	}
 
 	return &AttributionAnalysisAgent{
@@ -45,25 +85,12 @@ func (a *AttributionAnalysisAgent) GetType() types.AgentType {
 
 // GetCapabilities 获取能力描述
 func (a *AttributionAnalysisAgent) GetCapabilities() []string {
-	return []string{
-		"因果关系分析",
-		"根因分析",
-		"贡献度分析",
-		"影响因子识别",
-		"特征重要性分析",
-		"相关性与因果性分析",
-		"回归分析",
-		"变化归因分析",
-	}
+	return attributionAnalysisExpert.Capabilities
 }
 
 // CanHandle 判断是否能处理特定任务
 func (a *AttributionAnalysisAgent) CanHandle(task *types.Task) bool {
-	return task.Type == "attribution_analysis" ||
-		task.Type == "root_cause" ||
-		task.Type == "causal_analysis" ||
-		task.Type == "contribution_analysis" ||
-		task.AgentType == types.AgentTypeAttributionAnalysis
+	return attributionAnalysisExpert.CanHandle(task)
 }
 
 // Generate 生成响应
@@ -78,7 +105,7 @@ func (a *AttributionAnalysisAgent) Generate(ctx context.Context, messages []*sch
 	}
 
 	// 执行归因分析
-	result, err := a.executeAttributionAnalysis(attributionCode)
+	result, _, err := a.executeAttributionAnalysis(ctx, attributionCode)
 	if err != nil {
 		return &schema.Message{
 			Role:    schema.Assistant,
@@ -149,7 +176,7 @@ func (a *AttributionAnalysisAgent) ExecuteTask(ctx context.Context, task *types.
 	}
 
 	// 执行归因分析
-	result, err := a.executeAttributionAnalysis(attributionCode)
+	result, artifacts, err := a.executeAttributionAnalysis(ctx, attributionCode)
 	if err != nil {
 		return &types.TaskResult{
 			Success:    false,
@@ -162,6 +189,7 @@ func (a *AttributionAnalysisAgent) ExecuteTask(ctx context.Context, task *types.
 		Success:    true,
 		Output:     result,
 		ExecutedBy: a.agentType,
+		Artifacts:  artifacts,
 		Metadata: map[string]interface{}{
 			"attribution_code": attributionCode,
 			"task_type":        task.Type,
@@ -192,31 +220,10 @@ func (a *AttributionAnalysisAgent) parseTaskInput(input interface{}) (map[string
 // generateAttributionCode 从消息生成归因分析代码
 func (a *AttributionAnalysisAgent) generateAttributionCode(ctx context.Context, messages []*schema.Message) (string, error) {
 	// 构建归因分析的系统提示
-	systemPrompt := `你是一个专业的归因分析和根因分析专家。请根据用户需求生成高质量的Python归因分析代码。
-
-代码要求：
-1. 使用pandas, numpy, matplotlib, seaborn, scikit-learn, scipy, statsmodels等专业库
-2. 包含完整的归因分析流程：
-   - 数据预处理和特征工程
-   - 相关性分析
-   - 因果关系分析
-   - 贡献度计算
-   - 影响因子排序
-   - 结果可视化
-3. 支持多种归因分析方法：
-   - 线性回归分析
-   - 特征重要性分析（随机森林、XGBoost）
-   - 相关性分析（皮尔逊、斯皮尔曼）
-   - 主成分分析（PCA）
-   - 方差分析（ANOVA）
-   - 沙普利值分析（SHAP）
-4. 提供量化的贡献度评分
-5. 生成归因分析图表和报告
-6. 包含统计显著性检验
-7. 包含适当的注释和错误处理
-8. 保存图表为'attribution_output.png'
-
-请只返回Python代码，不要添加其他解释。`
+	systemPrompt, err := promptLibrary(a.config).Render(prompts.TemplateExpertAttributionAnalysis, nil)
+	if err != nil {
+		return "", err
+	}
 
 	attributionMessages := []*schema.Message{
 		{
@@ -255,7 +262,7 @@ func (a *AttributionAnalysisAgent) buildAttributionDescription(attributionReq ma
 
 	// 检查数据源
 	if dataSource, ok := attributionReq["data_source"]; ok {
-		desc = append(desc, fmt.Sprintf("基于数据源: %v", dataSource))
+		desc = append(desc, "基于数据源: "+describeDataSource(dataSource))
 	}
 
 	// 检查目标变量
@@ -303,18 +310,18 @@ func (a *AttributionAnalysisAgent) buildAttributionDescription(attributionReq ma
 }
 
 // executeAttributionAnalysis 执行归因分析
-func (a *AttributionAnalysisAgent) executeAttributionAnalysis(code string) (string, error) {
+func (a *AttributionAnalysisAgent) executeAttributionAnalysis(ctx context.Context, code string) (string, map[string]*types.Artifact, error) {
 	if a.sandbox == nil {
-		return "", fmt.Errorf("Python沙盒未配置")
+		return "", nil, fmt.Errorf("Python沙盒未配置")
 	}
 
-	result, err := a.sandbox.ExecutePython(code)
+	result, err := a.sandbox.ExecuteCodeContext(ctx, code)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	if !result.Success {
-		return "", fmt.Errorf("归因分析执行失败: %s", result.Error)
+		return "", nil, fmt.Errorf("归因分析执行失败: %s", result.Error)
 	}
 
 	response := "归因分析完成！\n\n"
@@ -327,7 +334,7 @@ func (a *AttributionAnalysisAgent) executeAttributionAnalysis(code string) (stri
 		response += "生成的归因分析图表: " + result.ImagePath + "\n"
 	}
 
-	return response, nil
+	return response, sandboxArtifacts(result), nil
 }
 
 // extractPythonCode 从响应中提取Python代码
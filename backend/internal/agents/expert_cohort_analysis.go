@@ -0,0 +1,361 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/analytics"
+	"smart-analysis/internal/types"
+)
+
+// 用户行为分析的类型
+const (
+	cohortAnalysisRetention = "retention"
+	cohortAnalysisFunnel    = "funnel"
+	cohortAnalysisUserPath  = "user_path"
+)
+
+// cohortAnalysisExpert 用户行为分析专家的注册信息
+var cohortAnalysisExpert = &ExpertDefinition{
+	Type:        types.AgentTypeCohortAnalysis,
+	Name:        "用户行为分析",
+	Description: "基于用户事件明细（用户、事件、时间）计算同期群留存、有序漏斗转化和用户路径，结果确定可复现",
+	Capabilities: []string{
+		"同期群留存矩阵",
+		"漏斗转化分析",
+		"用户路径分析",
+		"留存热力图",
+		"漏斗图与桑基图",
+	},
+	TaskTypes: []string{"cohort_analysis", cohortAnalysisRetention, cohortAnalysisFunnel, cohortAnalysisUserPath},
+	InputSchema: mustInputSchema(types.AgentTypeCohortAnalysis, `{
+  "type": "object",
+  "required": ["data_source"],
+  "properties": {
+    "data_source": {"type": "string", "description": "上游任务ID或事件数据文件（csv/json），数据需包含用户、事件和时间列"},
+    "analysis": {"type": "string", "enum": ["retention", "funnel", "user_path"], "description": "分析类型，未指定时根据任务类型推断"},
+    "user_field": {"type": "string", "description": "用户字段，默认自动识别 user_id 等列"},
+    "event_field": {"type": "string", "description": "事件字段，默认自动识别 event 等列"},
+    "time_field": {"type": "string", "description": "时间字段，默认自动识别 event_time 等列"},
+    "period": {"type": "string", "enum": ["day", "week", "month"], "description": "留存的时间粒度，默认 week"},
+    "periods": {"type": "integer", "minimum": 1, "description": "留存计算的周期数，默认8"},
+    "cohort_event": {"type": "string", "description": "进入同期群的事件，如 signup，默认为用户首个事件"},
+    "return_event": {"type": "string", "description": "视为留存的事件，默认任意事件"},
+    "steps": {"type": "array", "items": {"type": "string"}, "minItems": 2, "description": "漏斗的有序事件"},
+    "window": {"type": "string", "description": "漏斗转化窗口，如 24h、7d，默认不限"},
+    "start_event": {"type": "string", "description": "路径分析的起点事件，默认为用户首个事件"},
+    "depth": {"type": "integer", "minimum": 2, "description": "路径包含的步数，默认4"},
+    "top_n": {"type": "integer", "minimum": 1, "description": "返回的最常见路径数，默认10"}
+  }
+}`),
+	Factory: func(ctx context.Context, config *types.AgentConfig) (types.ExpertAgent, error) {
+		return NewCohortAnalysisAgent(ctx, config)
+	},
+}
+
+func init() {
+	MustRegisterExpert(cohortAnalysisExpert)
+}
+
+// CohortAnalysisAgent 用户行为分析专家智能体。计算在Go中完成，不依赖LLM和Python沙盒
+type CohortAnalysisAgent struct {
+	config    *types.AgentConfig
+	agentType types.AgentType
+}
+
+// cohortRequest 用户行为分析请求
+type cohortRequest struct {
+	DataSource  interface{} `json:"data_source"`
+	Analysis    string      `json:"analysis"`
+	UserField   string      `json:"user_field"`
+	EventField  string      `json:"event_field"`
+	TimeField   string      `json:"time_field"`
+	Period      string      `json:"period"`
+	Periods     int         `json:"periods"`
+	CohortEvent string      `json:"cohort_event"`
+	ReturnEvent string      `json:"return_event"`
+	Steps       []string    `json:"steps"`
+	Window      string      `json:"window"`
+	StartEvent  string      `json:"start_event"`
+	Depth       int         `json:"depth"`
+	TopN        int         `json:"top_n"`
+}
+
+// NewCohortAnalysisAgent 创建用户行为分析专家智能体
+func NewCohortAnalysisAgent(ctx context.Context, config *types.AgentConfig) (*CohortAnalysisAgent, error) {
+	return &CohortAnalysisAgent{
+		config:    config,
+		agentType: types.AgentTypeCohortAnalysis,
+	}, nil
+}
+
+// GetType 获取智能体类型
+func (a *CohortAnalysisAgent) GetType() types.AgentType {
+	return a.agentType
+}
+
+// GetCapabilities 获取能力描述
+func (a *CohortAnalysisAgent) GetCapabilities() []string {
+	return cohortAnalysisExpert.Capabilities
+}
+
+// CanHandle 判断是否能处理特定任务
+func (a *CohortAnalysisAgent) CanHandle(task *types.Task) bool {
+	return cohortAnalysisExpert.CanHandle(task)
+}
+
+// Generate 生成响应。最后一条用户消息需为JSON格式的分析请求
+func (a *CohortAnalysisAgent) Generate(ctx context.Context, messages []*schema.Message, opts ...interface{}) (*schema.Message, error) {
+	question := lastUserQuestion(messages)
+
+	var input map[string]interface{}
+	if err := json.Unmarshal([]byte(question), &input); err != nil {
+		return &schema.Message{
+			Role:    schema.Assistant,
+			Content: "用户行为分析需要JSON格式的请求，例如 {\"analysis\": \"funnel\", \"data_source\": \"events.csv\", \"steps\": [\"view\", \"order\"]}",
+		}, nil
+	}
+
+	output, _, _, err := a.analyze(ctx, input, "")
+	if err != nil {
+		return &schema.Message{
+			Role:    schema.Assistant,
+			Content: fmt.Sprintf("用户行为分析失败: %v", err),
+		}, nil
+	}
+
+	return &schema.Message{
+		Role:    schema.Assistant,
+		Content: output,
+	}, nil
+}
+
+// Stream 流式生成响应
+func (a *CohortAnalysisAgent) Stream(ctx context.Context, messages []*schema.Message, opts ...interface{}) (*schema.StreamReader[*schema.Message], error) {
+	response, err := a.Generate(ctx, messages, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	sr, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer sw.Close()
+		sw.Send(response, nil)
+	}()
+
+	return sr, nil
+}
+
+// Initialize 初始化智能体
+func (a *CohortAnalysisAgent) Initialize(ctx context.Context) error {
+	return nil
+}
+
+// Shutdown 关闭智能体
+func (a *CohortAnalysisAgent) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ExecuteTask 执行任务
+func (a *CohortAnalysisAgent) ExecuteTask(ctx context.Context, task *types.Task) (*types.TaskResult, error) {
+	if !a.CanHandle(task) {
+		return &types.TaskResult{
+			Success:    false,
+			Error:      "无法处理此类型的任务",
+			ExecutedBy: a.agentType,
+		}, nil
+	}
+
+	output, artifacts, analysis, err := a.analyze(ctx, task.Input, task.Type)
+	if err != nil {
+		return &types.TaskResult{
+			Success:    false,
+			Error:      fmt.Sprintf("用户行为分析失败: %v", err),
+			ExecutedBy: a.agentType,
+		}, nil
+	}
+
+	return &types.TaskResult{
+		Success:    true,
+		Output:     output,
+		ExecutedBy: a.agentType,
+		Artifacts:  artifacts,
+		Metadata: map[string]interface{}{
+			"analysis":  analysis,
+			"task_type": task.Type,
+		},
+	}, nil
+}
+
+// analyze 加载事件数据并执行分析，返回文字结论、产物和实际执行的分析类型
+func (a *CohortAnalysisAgent) analyze(ctx context.Context, input interface{}, taskType string) (string, map[string]*types.Artifact, string, error) {
+	req := &cohortRequest{}
+	if err := decodeTaskInput(input, req); err != nil {
+		return "", nil, "", err
+	}
+
+	analysis := req.analysisType(taskType)
+	events, err := loadEvents(ctx, a.config.Datasets, req)
+	if err != nil {
+		return "", nil, analysis, err
+	}
+
+	var (
+		output string
+		table  *analytics.Table
+		chart  map[string]interface{}
+	)
+	switch analysis {
+	case cohortAnalysisFunnel:
+		window, err := parseWindow(req.Window)
+		if err != nil {
+			return "", nil, analysis, err
+		}
+		result, err := analytics.Funnel(events, analytics.FunnelOptions{Steps: req.Steps, Window: window})
+		if err != nil {
+			return "", nil, analysis, err
+		}
+		output, table, chart = describeFunnel(result), result.Table(), analytics.FunnelChart(result, "漏斗转化")
+	case cohortAnalysisUserPath:
+		result, err := analytics.Paths(events, analytics.PathOptions{StartEvent: req.StartEvent, Depth: req.Depth, TopN: req.TopN})
+		if err != nil {
+			return "", nil, analysis, err
+		}
+		output, table, chart = describePaths(result), result.Table(), analytics.PathSankey(result, "用户路径")
+	default:
+		result, err := analytics.Retention(events, analytics.RetentionOptions{
+			Period:      analytics.Period(req.Period),
+			Periods:     req.Periods,
+			CohortEvent: req.CohortEvent,
+			ReturnEvent: req.ReturnEvent,
+		})
+		if err != nil {
+			return "", nil, analysis, err
+		}
+		output, table, chart = describeRetention(result), result.Table(), analytics.RetentionHeatmap(result, "同期群留存")
+	}
+
+	artifacts := map[string]*types.Artifact{
+		types.ArtifactTypeDataFrame: {Type: types.ArtifactTypeDataFrame, Data: table.Frame()},
+		types.ArtifactTypeChart:     {Type: types.ArtifactTypeChart, Data: chart},
+	}
+	return output, artifacts, analysis, nil
+}
+
+// analysisType 确定分析类型：优先使用显式指定的类型，其次是任务类型，最后根据参数推断
+func (r *cohortRequest) analysisType(taskType string) string {
+	switch {
+	case r.Analysis != "":
+		return r.Analysis
+	case taskType == cohortAnalysisFunnel || taskType == cohortAnalysisUserPath || taskType == cohortAnalysisRetention:
+		return taskType
+	case len(r.Steps) > 0:
+		return cohortAnalysisFunnel
+	case r.StartEvent != "":
+		return cohortAnalysisUserPath
+	default:
+		return cohortAnalysisRetention
+	}
+}
+
+// loadEvents 从数据源加载事件
+func loadEvents(ctx context.Context, datasets types.DatasetResolver, req *cohortRequest) ([]analytics.Event, error) {
+	path, err := dataSourcePath(ctx, datasets, req.DataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := analytics.LoadTable(path)
+	if err != nil {
+		return nil, err
+	}
+	return table.Events(analytics.FieldMapping{User: req.UserField, Event: req.EventField, Time: req.TimeField})
+}
+
+// parseWindow 解析转化窗口，支持Go时长格式（如 24h）和按天表示（如 7d）
+func parseWindow(window string) (time.Duration, error) {
+	window = strings.TrimSpace(window)
+	if window == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(window, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("转化窗口格式不正确: %s", window)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("转化窗口格式不正确: %s", window)
+	}
+	return d, nil
+}
+
+// describeRetention 生成留存分析的文字结论
+func describeRetention(m *analytics.RetentionMatrix) string {
+	users := 0
+	for _, size := range m.Sizes {
+		users += size
+	}
+
+	unit := m.Period.Unit()
+	lines := []string{fmt.Sprintf("同期群留存分析（按%s）：共 %d 个同期群，%d 名用户。", unit, len(m.Cohorts), users)}
+	for k := 1; ; k++ {
+		rate, ok := m.AverageRate(k)
+		if !ok {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("- 第%d%s平均留存率 %.1f%%", k, unit, rate*100))
+	}
+	if len(lines) == 1 {
+		lines = append(lines, "- 数据仅覆盖首个周期，暂无法计算后续留存")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// describeFunnel 生成漏斗分析的文字结论
+func describeFunnel(f *analytics.FunnelResult) string {
+	title := "漏斗转化分析"
+	if f.Window != "" {
+		title += "（转化窗口 " + f.Window + "）"
+	}
+	lines := []string{title + "："}
+
+	worst := 1
+	for i, step := range f.Steps {
+		if i == 0 {
+			lines = append(lines, fmt.Sprintf("%d. %s：%d 人", i+1, step.Event, step.Users))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s：%d 人，较上一步转化 %.1f%%，整体转化 %.1f%%",
+			i+1, step.Event, step.Users, step.Conversion*100, step.Overall*100))
+		if step.Conversion < f.Steps[worst].Conversion {
+			worst = i
+		}
+	}
+	lines = append(lines, fmt.Sprintf("流失最严重的环节是 %s → %s，流失 %d 人。",
+		f.Steps[worst-1].Event, f.Steps[worst].Event, f.Steps[worst].DropOff))
+	return strings.Join(lines, "\n")
+}
+
+// describePaths 生成路径分析的文字结论
+func describePaths(p *analytics.PathResult) string {
+	title := "用户路径分析"
+	if p.StartEvent != "" {
+		title += "（起点 " + p.StartEvent + "）"
+	}
+	lines := []string{fmt.Sprintf("%s：共 %d 名用户，最常见的路径：", title, p.Users)}
+	for i, path := range p.Paths {
+		if i == 5 {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s（%d 人，%.1f%%）", i+1, strings.Join(path.Path, " → "), path.Users, path.Share*100))
+	}
+	return strings.Join(lines, "\n")
+}
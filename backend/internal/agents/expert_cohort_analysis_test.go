@@ -0,0 +1,54 @@
+package agents
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"smart-analysis/internal/types"
+)
+
+func TestCohortAnalysisAgent_Funnel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.csv")
+	csv := "uid,action,ts\n1,view,2024-03-01 10:00:00\n1,cart,2024-03-01 10:05:00\n1,pay,2024-03-01 10:06:00\n2,view,2024-03-02\n2,cart,2024-03-10\n3,view,2024-03-03\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := map[string]interface{}{
+		"data_source": path,
+		"steps":       []interface{}{"view", "cart", "pay"},
+		"window":      "7d",
+	}
+	if err := cohortAnalysisExpert.ValidateInput(input); err != nil {
+		t.Fatalf("输入校验失败: %v", err)
+	}
+
+	expert, err := NewAgentFactory().CreateExpertAgent(context.Background(), types.AgentTypeCohortAnalysis, &types.AgentConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, _ := expert.ExecuteTask(context.Background(), &types.Task{Type: "funnel", Input: input})
+	if !result.Success {
+		t.Fatalf("执行失败: %s", result.Error)
+	}
+
+	output := result.Output.(string)
+	// 用户2超过7天才加购，只有用户1完成转化
+	for _, want := range []string{"view：3 人", "cart：1 人", "pay：1 人", "view → cart"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("结论未包含 %q:\n%s", want, output)
+		}
+	}
+
+	frame := result.Artifacts[types.ArtifactTypeDataFrame].Data.(map[string]interface{})
+	if rows := frame["data"].([]interface{}); len(rows) != 3 {
+		t.Errorf("漏斗表行数不正确: %v", rows)
+	}
+	chart := result.Artifacts[types.ArtifactTypeChart].Data.(map[string]interface{})
+	if series := chart["series"].([]interface{})[0].(map[string]interface{}); series["type"] != "funnel" {
+		t.Errorf("图表类型不正确: %v", series["type"])
+	}
+}
@@ -7,33 +7,69 @@ import (
 	"strings"
 
 	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/prompts"
 	"smart-analysis/internal/tools"
 	"smart-analysis/internal/types"
 	"smart-analysis/internal/utils/sanbox"
 )
 
+// dataAnalysisExpert 数据分析专家的注册信息
+var dataAnalysisExpert = &ExpertDefinition{
+	Type:        types.AgentTypeDataAnalysis,
+	Name:        "数据分析",
+	Description: "对查询结果做统计分析、对比分析和可视化",
+	Capabilities: []string{
+		"描述性统计分析",
+		"相关性分析",
+		"分布分析",
+		"对比分析",
+		"数据可视化",
+		"数据预处理",
+		"特征工程",
+	},
+	TaskTypes: []string{"analysis", "data_analysis", "visualization"},
+	InputSchema: mustInputSchema(types.AgentTypeDataAnalysis, `{
+  "type": "object",
+  "properties": {
+    "data_source": {"type": "string", "description": "上游任务ID"},
+    "analysis_type": {"type": "string", "description": "分析类型，如描述性统计、相关性、对比"},
+    "target": {"description": "分析目标"},
+    "fields": {"description": "关注字段"},
+    "requirements": {"description": "特殊要求"}
+  }
+}`),
+	Factory: func(ctx context.Context, config *types.AgentConfig) (types.ExpertAgent, error) {
+		return NewDataAnalysisAgent(ctx, config)
+	},
+}
+
+func init() {
+	MustRegisterExpert(dataAnalysisExpert)
+}
+
 // DataAnalysisAgent 数据分析专家智能体
 type DataAnalysisAgent struct {
 	chatModel      model.BaseChatModel
 	sandbox        *sanbox.PythonSandbox
 	config         *types.AgentConfig
 	agentType      types.AgentType
-	pythonTool     *tools.PythonAnalysisTool
-	vizTool        *tools.EChartsVisualizationTool
-	preprocessTool *tools.DataPreprocessingTool
+	pythonTool     tool.BaseTool
+	vizTool        tool.BaseTool
+	preprocessTool tool.BaseTool
 }
 
 // NewDataAnalysisAgent 创建数据分析专家智能体
 func NewDataAnalysisAgent(ctx context.Context, config *types.AgentConfig) (*DataAnalysisAgent, error) {
-	var pythonTool *tools.PythonAnalysisTool
-	var vizTool *tools.EChartsVisualizationTool
-	var preprocessTool *tools.DataPreprocessingTool
+	var pythonTool tool.BaseTool
+	var vizTool tool.BaseTool
+	var preprocessTool tool.BaseTool
 
 	if config.PythonSandbox != nil {
-		pythonTool = tools.NewPythonAnalysisTool(config.PythonSandbox)
-		vizTool = tools.NewEChartsVisualizationTool(config.PythonSandbox)
-		preprocessTool = tools.NewDataPreprocessingTool(config.PythonSandbox)
+		pythonTool = tools.WithDatasets(tools.NewPythonAnalysisTool(config.PythonSandbox), config.Datasets)
+		vizTool = tools.WithDatasets(tools.NewEChartsVisualizationTool(config.PythonSandbox), config.Datasets)
+		preprocessTool = tools.WithDatasets(tools.NewDataPreprocessingTool(config.PythonSandbox), config.Datasets)
 	}
 
 	return &DataAnalysisAgent{
@@ -54,23 +90,12 @@ func (a *DataAnalysisAgent) GetType() types.AgentType {
 
 // GetCapabilities 获取能力描述
 func (a *DataAnalysisAgent) GetCapabilities() []string {
-	return []string{
-		"描述性统计分析",
-		"相关性分析",
-		"分布分析",
-		"对比分析",
-		"数据可视化",
-		"数据预处理",
-		"特征工程",
-	}
+	return dataAnalysisExpert.Capabilities
 }
 
 // CanHandle 判断是否能处理特定任务
 func (a *DataAnalysisAgent) CanHandle(task *types.Task) bool {
-	return task.Type == "analysis" ||
-		task.Type == "data_analysis" ||
-		task.Type == "visualization" ||
-		task.AgentType == types.AgentTypeDataAnalysis
+	return dataAnalysisExpert.CanHandle(task)
 }
 
 // Generate 生成响应
@@ -85,7 +110,7 @@ func (a *DataAnalysisAgent) Generate(ctx context.Context, messages []*schema.Mes
 	}
 
 	// 执行分析
-	result, err := a.executeAnalysis(analysisCode)
+	result, _, err := a.executeAnalysis(ctx, analysisCode)
 	if err != nil {
 		return &schema.Message{
 			Role:    schema.Assistant,
@@ -156,7 +181,7 @@ func (a *DataAnalysisAgent) ExecuteTask(ctx context.Context, task *types.Task) (
 	}
 
 	// 执行分析
-	result, err := a.executeAnalysis(analysisCode)
+	result, artifacts, err := a.executeAnalysis(ctx, analysisCode)
 	if err != nil {
 		return &types.TaskResult{
 			Success:    false,
@@ -169,6 +194,7 @@ func (a *DataAnalysisAgent) ExecuteTask(ctx context.Context, task *types.Task) (
 		Success:    true,
 		Output:     result,
 		ExecutedBy: a.agentType,
+		Artifacts:  artifacts,
 		Metadata: map[string]interface{}{
 			"analysis_code": analysisCode,
 			"task_type":     task.Type,
@@ -199,25 +225,10 @@ func (a *DataAnalysisAgent) parseTaskInput(input interface{}) (map[string]interf
 // generateAnalysisCode 从消息生成分析代码
 func (a *DataAnalysisAgent) generateAnalysisCode(ctx context.Context, messages []*schema.Message) (string, error) {
 	// 构建分析生成的系统提示
-	systemPrompt := `你是一个专业的数据分析专家。请根据用户需求生成高质量的Python数据分析代码。
-
-代码要求：
-1. 使用pandas, numpy, matplotlib, seaborn等专业库
-2. 包含完整的数据分析流程：数据加载、清洗、分析、可视化
-3. 提供统计分析结果和洞察
-4. 生成清晰的图表和报告
-5. 包含适当的注释和错误处理
-6. 保存图表为'analysis_output.png'
-
-分析类型包括但不限于：
-- 描述性统计
-- 相关性分析
-- 分布分析
-- 趋势分析
-- 对比分析
-- 聚类分析
-
-请只返回Python代码，不要添加其他解释。`
+	systemPrompt, err := promptLibrary(a.config).Render(prompts.TemplateExpertDataAnalysis, nil)
+	if err != nil {
+		return "", err
+	}
 
 	analysisMessages := []*schema.Message{
 		{
@@ -256,7 +267,7 @@ func (a *DataAnalysisAgent) buildAnalysisDescription(analysisReq map[string]inte
 
 	// 检查数据源
 	if dataSource, ok := analysisReq["data_source"]; ok {
-		desc = append(desc, fmt.Sprintf("基于数据源: %v", dataSource))
+		desc = append(desc, "基于数据源: "+describeDataSource(dataSource))
 	}
 
 	// 检查分析类型
@@ -289,18 +300,18 @@ func (a *DataAnalysisAgent) buildAnalysisDescription(analysisReq map[string]inte
 }
 
 // executeAnalysis 执行分析
-func (a *DataAnalysisAgent) executeAnalysis(code string) (string, error) {
+func (a *DataAnalysisAgent) executeAnalysis(ctx context.Context, code string) (string, map[string]*types.Artifact, error) {
 	if a.sandbox == nil {
-		return "", fmt.Errorf("Python沙盒未配置")
+		return "", nil, fmt.Errorf("Python沙盒未配置")
 	}
 
-	result, err := a.sandbox.ExecutePython(code)
+	result, err := a.sandbox.ExecuteCodeContext(ctx, code)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	if !result.Success {
-		return "", fmt.Errorf("分析执行失败: %s", result.Error)
+		return "", nil, fmt.Errorf("分析执行失败: %s", result.Error)
 	}
 
 	response := "数据分析完成！\n\n"
@@ -313,7 +324,7 @@ func (a *DataAnalysisAgent) executeAnalysis(code string) (string, error) {
 		response += "生成的图表: " + result.ImagePath + "\n"
 	}
 
-	return response, nil
+	return response, sandboxArtifacts(result), nil
 }
 
 // extractPythonCode 从响应中提取Python代码
@@ -7,26 +7,70 @@ import (
 	"strings"
 
 	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/analytics"
+	"smart-analysis/internal/prompts"
+	"smart-analysis/internal/query"
 	"smart-analysis/internal/tools"
 	"smart-analysis/internal/types"
 	"smart-analysis/internal/utils/sanbox"
 )
 
+// nativeQueryMaxRows 内置查询引擎在文字结果中展示的最大行数，完整结果见数据表产物
+const nativeQueryMaxRows = 20
+
+// dataQueryExpert 数据查询专家的注册信息
+var dataQueryExpert = &ExpertDefinition{
+	Type:        types.AgentTypeDataQuery,
+	Name:        "数据查询",
+	Description: "按事件、维度、指标和过滤条件查询与聚合数据，通常作为其它分析任务的数据来源",
+	Capabilities: []string{
+		"数据查询与筛选",
+		"SQL查询生成",
+		"数据过滤和聚合",
+		"多表关联查询",
+		"数据预览和统计",
+	},
+	TaskTypes: []string{"data_query"},
+	InputSchema: mustInputSchema(types.AgentTypeDataQuery, `{
+  "type": "object",
+  "properties": {
+    "events": {"type": "array", "items": {"type": "string"}, "description": "查询的事件"},
+    "dimensions": {"type": "array", "items": {"type": "string"}, "description": "维度字段"},
+    "metrics": {"type": "array", "items": {"type": "string"}, "description": "度量字段"},
+    "filters": {"type": "array", "items": {"type": "object"}, "description": "过滤条件 {column, operator, value}"},
+    "time_range": {"type": "object", "description": "时间范围"},
+    "group_by": {"type": "array", "items": {"type": "string"}},
+    "order_by": {"type": "array", "items": {"type": "object"}},
+    "limit": {"type": "integer", "minimum": 0},
+    "data_source": {"type": "string", "description": "数据来源：上游任务ID或数据文件路径（csv/json/xlsx），指定后由内置查询引擎直接执行"},
+    "metadata": {"type": "object", "description": "查询元数据，可指定 time_column、event_column、file_path"}
+  }
+}`),
+	Factory: func(ctx context.Context, config *types.AgentConfig) (types.ExpertAgent, error) {
+		return NewDataQueryAgent(ctx, config)
+	},
+}
+
+func init() {
+	MustRegisterExpert(dataQueryExpert)
+}
+
 // DataQueryAgent 数据查询专家智能体
 type DataQueryAgent struct {
 	chatModel model.BaseChatModel
 	sandbox   *sanbox.PythonSandbox
 	config    *types.AgentConfig
 	agentType types.AgentType
-	tools     *tools.DataQueryTool
+	tools     tool.BaseTool
 }
 
 // NewDataQueryAgent 创建数据查询专家智能体
 func NewDataQueryAgent(ctx context.Context, config *types.AgentConfig) (*DataQueryAgent, error) {
-	var queryTool *tools.DataQueryTool
+	var queryTool tool.BaseTool
 	if config.PythonSandbox != nil {
-		queryTool = tools.NewDataQueryTool(config.PythonSandbox)
+		queryTool = tools.WithDatasets(tools.NewDataQueryTool(config.PythonSandbox), config.Datasets)
 	}
 
 	return &DataQueryAgent{
@@ -45,18 +89,12 @@ func (a *DataQueryAgent) GetType() types.AgentType {
 
 // GetCapabilities 获取能力描述
 func (a *DataQueryAgent) GetCapabilities() []string {
-	return []string{
-		"数据查询与筛选",
-		"SQL查询生成",
-		"数据过滤和聚合",
-		"多表关联查询",
-		"数据预览和统计",
-	}
+	return dataQueryExpert.Capabilities
 }
 
 // CanHandle 判断是否能处理特定任务
 func (a *DataQueryAgent) CanHandle(task *types.Task) bool {
-	return task.Type == "data_query" || task.AgentType == types.AgentTypeDataQuery
+	return dataQueryExpert.CanHandle(task)
 }
 
 // Generate 生成响应
@@ -71,7 +109,7 @@ func (a *DataQueryAgent) Generate(ctx context.Context, messages []*schema.Messag
 	}
 
 	// 执行查询
-	result, err := a.executeQuery(queryCode)
+	result, _, err := a.executeQuery(ctx, queryCode)
 	if err != nil {
 		return &schema.Message{
 			Role:    schema.Assistant,
@@ -131,6 +169,25 @@ func (a *DataQueryAgent) ExecuteTask(ctx context.Context, task *types.Task) (*ty
 		}, nil
 	}
 
+	// 能定位到数据文件时优先使用内置查询引擎，执行失败再回退到生成代码
+	var nativeErr error
+	if path := nativeQuerySource(ctx, a.config.Datasets, task.Input, queryObject); path != "" {
+		output, artifacts, err := a.executeNative(path, queryObject)
+		if err == nil {
+			return &types.TaskResult{
+				Success:    true,
+				Output:     output,
+				ExecutedBy: a.agentType,
+				Artifacts:  artifacts,
+				Metadata: map[string]interface{}{
+					"engine":    "native",
+					"task_type": task.Type,
+				},
+			}, nil
+		}
+		nativeErr = err
+	}
+
 	// 生成查询代码
 	queryCode, err := a.generateQueryCodeFromObject(ctx, queryObject)
 	if err != nil {
@@ -142,7 +199,7 @@ func (a *DataQueryAgent) ExecuteTask(ctx context.Context, task *types.Task) (*ty
 	}
 
 	// 执行查询
-	result, err := a.executeQuery(queryCode)
+	result, artifacts, err := a.executeQuery(ctx, queryCode)
 	if err != nil {
 		return &types.TaskResult{
 			Success:    false,
@@ -151,17 +208,60 @@ func (a *DataQueryAgent) ExecuteTask(ctx context.Context, task *types.Task) (*ty
 		}, nil
 	}
 
+	metadata := map[string]interface{}{
+		"query_code": queryCode,
+		"task_type":  task.Type,
+	}
+	if nativeErr != nil {
+		metadata["native_error"] = nativeErr.Error()
+	}
+
 	return &types.TaskResult{
 		Success:    true,
 		Output:     result,
 		ExecutedBy: a.agentType,
-		Metadata: map[string]interface{}{
-			"query_code": queryCode,
-			"task_type":  task.Type,
-		},
+		Artifacts:  artifacts,
+		Metadata:   metadata,
 	}, nil
 }
 
+// nativeQuerySource 返回内置查询引擎读取的数据文件：任务输入的 data_source，
+// 或查询对象 metadata 中的 file_path / data_source，找不到或无权访问时返回空字符串
+func nativeQuerySource(ctx context.Context, datasets types.DatasetResolver, input interface{}, queryObj *types.QueryObject) string {
+	var candidates []interface{}
+	if inputMap, ok := input.(map[string]interface{}); ok {
+		candidates = append(candidates, inputMap[dataSourceKey])
+	}
+	if queryObj.Metadata != nil {
+		candidates = append(candidates, queryObj.Metadata["file_path"], queryObj.Metadata[dataSourceKey])
+	}
+
+	for _, candidate := range candidates {
+		if path, err := dataSourcePath(ctx, datasets, candidate); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// executeNative 使用内置查询引擎执行查询对象
+func (a *DataQueryAgent) executeNative(path string, queryObj *types.QueryObject) (string, map[string]*types.Artifact, error) {
+	table, err := analytics.LoadTable(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	result, err := query.Execute(table, queryObj)
+	if err != nil {
+		return "", nil, err
+	}
+
+	frame := &types.Artifact{Type: types.ArtifactTypeDataFrame, Data: result.Frame()}
+	response := fmt.Sprintf("数据查询完成！\n\n查询结果（%d 行）:\n%s\n", len(result.Rows), markdownTable(frame, nativeQueryMaxRows))
+
+	return response, map[string]*types.Artifact{types.ArtifactTypeDataFrame: frame}, nil
+}
+
 // parseTaskInput 解析任务输入
 func (a *DataQueryAgent) parseTaskInput(input interface{}) (*types.QueryObject, error) {
 	// 尝试直接转换
@@ -191,16 +291,10 @@ func (a *DataQueryAgent) parseTaskInput(input interface{}) (*types.QueryObject,
 // generateQueryCode 从消息生成查询代码
 func (a *DataQueryAgent) generateQueryCode(ctx context.Context, messages []*schema.Message) (string, error) {
 	// 构建查询生成的系统提示
-	systemPrompt := `你是一个专业的数据查询专家。请根据用户需求生成高质量的Python数据查询代码。
-
-代码要求：
-1. 使用pandas进行数据操作
-2. 支持数据筛选、聚合、排序等操作
-3. 处理各种数据类型和格式
-4. 包含错误处理
-5. 输出清晰的查询结果
-
-请只返回Python代码，不要添加其他解释。代码应该可以直接执行。`
+	systemPrompt, err := promptLibrary(a.config).Render(prompts.TemplateExpertDataQuery, nil)
+	if err != nil {
+		return "", err
+	}
 
 	queryMessages := []*schema.Message{
 		{
@@ -281,18 +375,18 @@ func (a *DataQueryAgent) buildQueryDescription(queryObj *types.QueryObject) stri
 }
 
 // executeQuery 执行查询
-func (a *DataQueryAgent) executeQuery(code string) (string, error) {
+func (a *DataQueryAgent) executeQuery(ctx context.Context, code string) (string, map[string]*types.Artifact, error) {
 	if a.sandbox == nil {
-		return "", fmt.Errorf("Python沙盒未配置")
+		return "", nil, fmt.Errorf("Python沙盒未配置")
 	}
 
-	result, err := a.sandbox.ExecutePython(code)
+	result, err := a.sandbox.ExecuteCodeContext(ctx, code)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	if !result.Success {
-		return "", fmt.Errorf("查询执行失败: %s", result.Error)
+		return "", nil, fmt.Errorf("查询执行失败: %s", result.Error)
 	}
 
 	response := "数据查询完成！\n\n"
@@ -300,7 +394,7 @@ func (a *DataQueryAgent) executeQuery(code string) (string, error) {
 		response += "查询结果:\n" + result.Stdout + "\n"
 	}
 
-	return response, nil
+	return response, sandboxArtifacts(result), nil
 }
 
 // extractPythonCode 从响应中提取Python代码
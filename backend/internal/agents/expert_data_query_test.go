@@ -0,0 +1,52 @@
+package agents
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"smart-analysis/internal/types"
+)
+
+func TestDataQueryAgent_NativeEngine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sales.csv")
+	csv := "order_date,region,sales\n2024-01-03,华东,100\n2024-01-20,华北,300\n2024-02-01,华东,200\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := map[string]interface{}{
+		"data_source": path,
+		"dimensions":  []interface{}{"region"},
+		"metrics":     []interface{}{"sum(sales)"},
+		"time_range":  map[string]interface{}{"start_time": "2024-01-01", "end_time": "2024-01-31"},
+		"order_by":    []interface{}{map[string]interface{}{"column": "sum(sales)", "direction": "DESC"}},
+	}
+	if err := dataQueryExpert.ValidateInput(input); err != nil {
+		t.Fatalf("输入校验失败: %v", err)
+	}
+
+	// 未配置模型和沙盒，只能由内置查询引擎完成
+	expert, err := NewAgentFactory().CreateExpertAgent(context.Background(), types.AgentTypeDataQuery, &types.AgentConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, _ := expert.ExecuteTask(context.Background(), &types.Task{Type: "data_query", Input: input})
+	if !result.Success {
+		t.Fatalf("执行失败: %s", result.Error)
+	}
+	if result.Metadata["engine"] != "native" {
+		t.Errorf("应使用内置查询引擎: %v", result.Metadata)
+	}
+
+	output := result.Output.(string)
+	if !strings.Contains(output, "| 华北 | 300 |\n| 华东 | 100 |") {
+		t.Errorf("查询结果不正确:\n%s", output)
+	}
+	frame := result.Artifacts[types.ArtifactTypeDataFrame].Data.(map[string]interface{})
+	if rows := frame["data"].([]interface{}); len(rows) != 2 {
+		t.Errorf("数据表行数不正确: %v", rows)
+	}
+}
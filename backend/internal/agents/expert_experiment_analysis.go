@@ -0,0 +1,206 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/analytics"
+	"smart-analysis/internal/tools"
+	"smart-analysis/internal/types"
+)
+
+// abTestExpert 实验分析专家的注册信息
+var abTestExpert = &ExpertDefinition{
+	Type:        types.AgentTypeABTest,
+	Name:        "实验分析",
+	Description: "对A/B实验明细做显著性检验，给出置信区间、统计功效、最小可检测效应和是否采用的结论",
+	Capabilities: []string{
+		"A/B实验显著性检验",
+		"双比例z检验与卡方检验",
+		"Welch t检验与Mann-Whitney U检验",
+		"置信区间与统计功效",
+		"最小可检测效应",
+		"多重比较校正",
+	},
+	TaskTypes: []string{"ab_test", "experiment_analysis", "significance_test"},
+	InputSchema: mustInputSchema(types.AgentTypeABTest, `{
+  "type": "object",
+  "required": ["data_source", "metric_field"],
+  "properties": {
+    "data_source": {"type": "string", "description": "上游任务ID或实验明细数据文件（csv/json），每行一个用户或一次观测"},
+    "metric_field": {"type": "string", "description": "指标字段，0/1取值按转化率检验，其余按均值检验"},
+    "variant_field": {"type": "string", "description": "分组字段，默认自动识别 variant、group 等列"},
+    "control": {"type": "string", "description": "对照组名称，默认识别 control、baseline、A"},
+    "metric_type": {"type": "string", "enum": ["binary", "continuous"], "description": "指标类型，默认根据取值判断"},
+    "method": {"type": "string", "enum": ["z_test", "welch_t", "mann_whitney", "chi_square"], "description": "检验方法，默认二值指标用 z_test，连续指标用 welch_t"},
+    "alpha": {"type": "number", "minimum": 0, "maximum": 1, "description": "显著性水平，默认0.05"},
+    "power": {"type": "number", "minimum": 0, "maximum": 1, "description": "计算最小可检测效应的目标功效，默认0.8"},
+    "correction": {"type": "string", "enum": ["none", "bonferroni", "holm"], "description": "多重比较校正，多组时默认 holm"}
+  }
+}`),
+	Factory: func(ctx context.Context, config *types.AgentConfig) (types.ExpertAgent, error) {
+		return NewABTestAgent(ctx, config)
+	},
+}
+
+func init() {
+	MustRegisterExpert(abTestExpert)
+}
+
+// ABTestAgent 实验分析专家智能体。检验在Go中完成，不依赖LLM和Python沙盒
+type ABTestAgent struct {
+	config    *types.AgentConfig
+	agentType types.AgentType
+}
+
+// abTestRequest 实验分析请求
+type abTestRequest struct {
+	DataSource   interface{} `json:"data_source"`
+	MetricField  string      `json:"metric_field"`
+	VariantField string      `json:"variant_field"`
+	Control      string      `json:"control"`
+	MetricType   string      `json:"metric_type"`
+	Method       string      `json:"method"`
+	Alpha        float64     `json:"alpha"`
+	Power        float64     `json:"power"`
+	Correction   string      `json:"correction"`
+}
+
+// NewABTestAgent 创建实验分析专家智能体
+func NewABTestAgent(ctx context.Context, config *types.AgentConfig) (*ABTestAgent, error) {
+	return &ABTestAgent{
+		config:    config,
+		agentType: types.AgentTypeABTest,
+	}, nil
+}
+
+// GetType 获取智能体类型
+func (a *ABTestAgent) GetType() types.AgentType {
+	return a.agentType
+}
+
+// GetCapabilities 获取能力描述
+func (a *ABTestAgent) GetCapabilities() []string {
+	return abTestExpert.Capabilities
+}
+
+// CanHandle 判断是否能处理特定任务
+func (a *ABTestAgent) CanHandle(task *types.Task) bool {
+	return abTestExpert.CanHandle(task)
+}
+
+// Generate 生成响应。最后一条用户消息需为JSON格式的实验分析请求
+func (a *ABTestAgent) Generate(ctx context.Context, messages []*schema.Message, opts ...interface{}) (*schema.Message, error) {
+	var input map[string]interface{}
+	if err := json.Unmarshal([]byte(lastUserQuestion(messages)), &input); err != nil {
+		return &schema.Message{
+			Role:    schema.Assistant,
+			Content: "实验分析需要JSON格式的请求，例如 {\"data_source\": \"experiment.csv\", \"metric_field\": \"converted\", \"variant_field\": \"group\"}",
+		}, nil
+	}
+
+	output, _, _, err := a.analyze(ctx, input)
+	if err != nil {
+		return &schema.Message{
+			Role:    schema.Assistant,
+			Content: fmt.Sprintf("实验分析失败: %v", err),
+		}, nil
+	}
+
+	return &schema.Message{
+		Role:    schema.Assistant,
+		Content: output,
+	}, nil
+}
+
+// Stream 流式生成响应
+func (a *ABTestAgent) Stream(ctx context.Context, messages []*schema.Message, opts ...interface{}) (*schema.StreamReader[*schema.Message], error) {
+	response, err := a.Generate(ctx, messages, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	sr, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer sw.Close()
+		sw.Send(response, nil)
+	}()
+
+	return sr, nil
+}
+
+// Initialize 初始化智能体
+func (a *ABTestAgent) Initialize(ctx context.Context) error {
+	return nil
+}
+
+// Shutdown 关闭智能体
+func (a *ABTestAgent) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ExecuteTask 执行任务
+func (a *ABTestAgent) ExecuteTask(ctx context.Context, task *types.Task) (*types.TaskResult, error) {
+	if !a.CanHandle(task) {
+		return &types.TaskResult{
+			Success:    false,
+			Error:      "无法处理此类型的任务",
+			ExecutedBy: a.agentType,
+		}, nil
+	}
+
+	output, artifacts, result, err := a.analyze(ctx, task.Input)
+	if err != nil {
+		return &types.TaskResult{
+			Success:    false,
+			Error:      fmt.Sprintf("实验分析失败: %v", err),
+			ExecutedBy: a.agentType,
+		}, nil
+	}
+
+	return &types.TaskResult{
+		Success:    true,
+		Output:     output,
+		ExecutedBy: a.agentType,
+		Artifacts:  artifacts,
+		Metadata: map[string]interface{}{
+			"ab_test":   result,
+			"task_type": task.Type,
+		},
+	}, nil
+}
+
+// analyze 执行显著性检验，返回文字结论、结果表产物和完整的检验结果
+func (a *ABTestAgent) analyze(ctx context.Context, input interface{}) (string, map[string]*types.Artifact, *analytics.ABTestResult, error) {
+	req := &abTestRequest{}
+	if err := decodeTaskInput(input, req); err != nil {
+		return "", nil, nil, err
+	}
+
+	path, err := dataSourcePath(ctx, a.config.Datasets, req.DataSource)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	result, err := tools.RunABTest(tools.ABTestArgs{
+		FilePath:      path,
+		MetricColumn:  req.MetricField,
+		VariantColumn: req.VariantField,
+		Control:       req.Control,
+		MetricType:    req.MetricType,
+		Method:        req.Method,
+		Alpha:         req.Alpha,
+		Power:         req.Power,
+		Correction:    req.Correction,
+	})
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	artifacts := map[string]*types.Artifact{
+		types.ArtifactTypeDataFrame: {Type: types.ArtifactTypeDataFrame, Data: result.Table().Frame()},
+	}
+	return result.Verdict(), artifacts, result, nil
+}
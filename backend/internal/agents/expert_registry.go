@@ -0,0 +1,130 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"smart-analysis/internal/types"
+)
+
+// ExpertFactory 创建专家智能体
+type ExpertFactory func(ctx context.Context, config *types.AgentConfig) (types.ExpertAgent, error)
+
+// ExpertDefinition 专家智能体的注册信息，规划提示中的专家列表由注册信息生成
+type ExpertDefinition struct {
+	Type         types.AgentType
+	Name         string            // 显示名称
+	Description  string            // 适用场景说明
+	Capabilities []string          // 能力描述
+	TaskTypes    []string          // 可处理的任务类型
+	InputSchema  *types.JSONSchema // 任务输入的JSON Schema，为空时不校验
+	Factory      ExpertFactory
+}
+
+// CanHandle 判断任务是否由该专家处理：指定了该专家，或任务类型在声明的范围内
+func (d *ExpertDefinition) CanHandle(task *types.Task) bool {
+	if task.AgentType == d.Type {
+		return true
+	}
+	for _, taskType := range d.TaskTypes {
+		if task.Type == taskType {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateInput 校验任务输入是否符合声明的输入Schema
+func (d *ExpertDefinition) ValidateInput(input interface{}) error {
+	if d.InputSchema == nil {
+		return nil
+	}
+
+	// 兼容以JSON字符串形式提供的输入
+	if text, ok := input.(string); ok && json.Valid([]byte(text)) {
+		return d.InputSchema.Validate([]byte(text))
+	}
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("任务输入无法序列化: %w", err)
+	}
+	// 未提供输入等同于空对象
+	if string(data) == "null" {
+		data = []byte("{}")
+	}
+	return d.InputSchema.Validate(data)
+}
+
+var (
+	expertRegistry   = make(map[types.AgentType]*ExpertDefinition)
+	expertRegistryMu sync.RWMutex
+)
+
+// RegisterExpert 注册专家智能体，类型重复时返回错误
+func RegisterExpert(def *ExpertDefinition) error {
+	if def.Type == "" {
+		return fmt.Errorf("专家智能体类型不能为空")
+	}
+	if def.Factory == nil {
+		return fmt.Errorf("专家智能体 %s 未提供创建函数", def.Type)
+	}
+
+	expertRegistryMu.Lock()
+	defer expertRegistryMu.Unlock()
+
+	if _, exists := expertRegistry[def.Type]; exists {
+		return fmt.Errorf("专家智能体 %s 已注册", def.Type)
+	}
+	expertRegistry[def.Type] = def
+	return nil
+}
+
+// MustRegisterExpert 注册专家智能体，失败时panic，供内置专家在 init 中使用
+func MustRegisterExpert(def *ExpertDefinition) {
+	if err := RegisterExpert(def); err != nil {
+		panic(err)
+	}
+}
+
+// UnregisterExpert 注销专家智能体
+func UnregisterExpert(agentType types.AgentType) {
+	expertRegistryMu.Lock()
+	defer expertRegistryMu.Unlock()
+	delete(expertRegistry, agentType)
+}
+
+// LookupExpert 获取专家智能体的注册信息
+func LookupExpert(agentType types.AgentType) (*ExpertDefinition, bool) {
+	expertRegistryMu.RLock()
+	defer expertRegistryMu.RUnlock()
+	def, ok := expertRegistry[agentType]
+	return def, ok
+}
+
+// ExpertDefinitions 返回所有已注册的专家智能体，按类型排序
+func ExpertDefinitions() []*ExpertDefinition {
+	expertRegistryMu.RLock()
+	defs := make([]*ExpertDefinition, 0, len(expertRegistry))
+	for _, def := range expertRegistry {
+		defs = append(defs, def)
+	}
+	expertRegistryMu.RUnlock()
+
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Type < defs[j].Type
+	})
+	return defs
+}
+
+// mustInputSchema 解析内置专家的输入Schema
+func mustInputSchema(agentType types.AgentType, raw string) *types.JSONSchema {
+	schema, err := types.NewJSONSchema(string(agentType)+"Input", []byte(raw))
+	if err != nil {
+		panic(fmt.Sprintf("invalid input schema for expert %s: %v", agentType, err))
+	}
+	return schema
+}
@@ -0,0 +1,90 @@
+package agents
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"smart-analysis/internal/types"
+)
+
+func TestRegisterConfigExperts(t *testing.T) {
+	dir := t.TempDir()
+	spec := `{
+  "type": "cohort_review",
+  "name": "同期群复盘",
+  "description": "按注册周复盘用户留存",
+  "capabilities": ["留存复盘"],
+  "task_types": ["cohort_review"],
+  "input_schema": {"type": "object", "required": ["cohort_field"], "properties": {"cohort_field": {"type": "string"}}},
+  "prompt": "你是增长分析师。"
+}`
+	if err := os.WriteFile(filepath.Join(dir, "cohort_review.json"), []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RegisterConfigExperts(dir); err != nil {
+		t.Fatalf("注册配置专家失败: %v", err)
+	}
+	defer UnregisterExpert("cohort_review")
+
+	// 与已注册的类型重复时报错
+	if err := RegisterConfigExperts(dir); err == nil {
+		t.Error("重复注册应返回错误")
+	}
+
+	def, ok := LookupExpert("cohort_review")
+	if !ok {
+		t.Fatal("配置专家未注册")
+	}
+	if err := def.ValidateInput(map[string]interface{}{}); err == nil {
+		t.Error("缺少必填字段时输入校验应失败")
+	}
+
+	chatModel := &scriptedChatModel{responses: []string{"第1周留存 42%"}}
+	expert, err := NewAgentFactory().CreateExpertAgent(context.Background(), "cohort_review", &types.AgentConfig{ChatModel: chatModel})
+	if err != nil {
+		t.Fatalf("创建配置专家失败: %v", err)
+	}
+
+	result, _ := expert.ExecuteTask(context.Background(), &types.Task{
+		Type:        "cohort_review",
+		Description: "复盘注册留存",
+		Input:       map[string]interface{}{"cohort_field": "signup_week"},
+	})
+	if !result.Success || result.Output != "第1周留存 42%" {
+		t.Fatalf("执行结果不正确: %+v", result)
+	}
+	sent := chatModel.calls[0]
+	if sent[0].Content != "你是增长分析师。" || !strings.Contains(sent[1].Content, "signup_week") {
+		t.Errorf("发送给模型的消息不正确: %v", sent)
+	}
+
+	// 注册后的专家自动出现在规划提示中
+	planner, _ := NewPlannerAgent(context.Background(), &types.AgentConfig{})
+	planner.RegisterExpertAgent(expert)
+	prompt, err := planner.buildPlanningPrompt(&types.QueryIntent{IntentType: "analysis"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"cohort_review", "同期群复盘", "按注册周复盘用户留存", `"cohort_field"`} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("规划提示未包含 %q", want)
+		}
+	}
+}
+
+func TestConfigExpertSpec_Invalid(t *testing.T) {
+	cases := map[string]ConfigExpertSpec{
+		"bad type":     {Type: "Cohort Review", Prompt: "p", Capabilities: []string{"c"}},
+		"no prompt":    {Type: "cohort", Capabilities: []string{"c"}},
+		"unknown tool": {Type: "cohort", Prompt: "p", Capabilities: []string{"c"}, Tools: []string{"shell"}},
+	}
+	for name, spec := range cases {
+		if _, err := spec.Definition(); err == nil {
+			t.Errorf("%s: 期望校验失败", name)
+		}
+	}
+}
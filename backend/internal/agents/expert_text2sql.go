@@ -0,0 +1,396 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/analytics"
+	"smart-analysis/internal/prompts"
+	"smart-analysis/internal/semantic"
+	"smart-analysis/internal/sqlengine"
+	"smart-analysis/internal/sqlguard"
+	"smart-analysis/internal/tools"
+	"smart-analysis/internal/types"
+)
+
+// text2SQLMaxAttempts 生成SQL的最大尝试次数，校验或执行失败时把错误反馈给模型重新生成
+const text2SQLMaxAttempts = 3
+
+// text2SQLExpert Text2SQL专家的注册信息
+var text2SQLExpert = &ExpertDefinition{
+	Type:        types.AgentTypeText2SQL,
+	Name:        "Text2SQL",
+	Description: "根据问题和表结构生成SQL，校验为单条只读 SELECT 后在数据库数据源或上传的数据文件上执行",
+	Capabilities: []string{
+		"自然语言生成SQL",
+		"MySQL、PostgreSQL、SQLite 方言",
+		"SQL语法树安全校验",
+		"表访问白名单与行数上限",
+		"执行前代价估算",
+		"根据错误自动修正SQL",
+	},
+	TaskTypes: []string{"text2sql", "sql_query"},
+	InputSchema: mustInputSchema(types.AgentTypeText2SQL, `{
+  "type": "object",
+  "required": ["question"],
+  "properties": {
+    "question": {"type": "string", "minLength": 1, "description": "要用SQL回答的问题"},
+    "data_source_id": {"type": "integer", "minimum": 1, "description": "已注册的数据库数据源ID"},
+    "data_source": {"description": "上游任务ID或数据文件路径（csv/json/xlsx），可以是数组，未指定 data_source_id 时使用"},
+    "max_rows": {"type": "integer", "minimum": 1, "maximum": 10000, "description": "返回的最大行数，默认1000"}
+  }
+}`),
+	Factory: func(ctx context.Context, config *types.AgentConfig) (types.ExpertAgent, error) {
+		return NewText2SQLAgent(ctx, config)
+	},
+}
+
+func init() {
+	MustRegisterExpert(text2SQLExpert)
+}
+
+// Text2SQLAgent Text2SQL专家智能体。SQL由LLM生成，在Go中解析校验后执行
+type Text2SQLAgent struct {
+	chatModel model.BaseChatModel
+	config    *types.AgentConfig
+	agentType types.AgentType
+}
+
+// text2SQLRequest Text2SQL请求
+type text2SQLRequest struct {
+	Question     string      `json:"question"`
+	DataSourceID int         `json:"data_source_id"`
+	DataSource   interface{} `json:"data_source"`
+	MaxRows      int         `json:"max_rows"`
+}
+
+// sqlTarget 执行SQL的目标：数据库数据源或由数据文件加载的内存数据库
+type sqlTarget struct {
+	engine   string
+	dialect  string
+	tables   []types.DataSchema
+	semantic *types.SemanticModel
+	query    func(ctx context.Context, query string, maxRows int) (*sqlengine.Result, error)
+	close    func()
+}
+
+// text2SQLRun 一次Text2SQL的执行记录
+type text2SQLRun struct {
+	sql      string
+	attempts int
+	result   *sqlengine.Result
+}
+
+// NewText2SQLAgent 创建Text2SQL专家智能体
+func NewText2SQLAgent(ctx context.Context, config *types.AgentConfig) (*Text2SQLAgent, error) {
+	return &Text2SQLAgent{
+		chatModel: config.ChatModel,
+		config:    config,
+		agentType: types.AgentTypeText2SQL,
+	}, nil
+}
+
+// GetType 获取智能体类型
+func (a *Text2SQLAgent) GetType() types.AgentType {
+	return a.agentType
+}
+
+// GetCapabilities 获取能力描述
+func (a *Text2SQLAgent) GetCapabilities() []string {
+	return text2SQLExpert.Capabilities
+}
+
+// CanHandle 判断是否能处理特定任务
+func (a *Text2SQLAgent) CanHandle(task *types.Task) bool {
+	return text2SQLExpert.CanHandle(task)
+}
+
+// Generate 生成响应。最后一条用户消息需为JSON格式的Text2SQL请求
+func (a *Text2SQLAgent) Generate(ctx context.Context, messages []*schema.Message, opts ...interface{}) (*schema.Message, error) {
+	var input map[string]interface{}
+	if err := json.Unmarshal([]byte(lastUserQuestion(messages)), &input); err != nil {
+		return &schema.Message{
+			Role:    schema.Assistant,
+			Content: "Text2SQL需要JSON格式的请求，例如 {\"question\": \"上个月各地区的销售额\", \"data_source_id\": 1}",
+		}, nil
+	}
+
+	output, _, _, err := a.answer(ctx, input)
+	if err != nil {
+		return &schema.Message{
+			Role:    schema.Assistant,
+			Content: fmt.Sprintf("Text2SQL失败: %v", err),
+		}, nil
+	}
+
+	return &schema.Message{
+		Role:    schema.Assistant,
+		Content: output,
+	}, nil
+}
+
+// Stream 流式生成响应
+func (a *Text2SQLAgent) Stream(ctx context.Context, messages []*schema.Message, opts ...interface{}) (*schema.StreamReader[*schema.Message], error) {
+	response, err := a.Generate(ctx, messages, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	sr, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer sw.Close()
+		sw.Send(response, nil)
+	}()
+
+	return sr, nil
+}
+
+// Initialize 初始化智能体
+func (a *Text2SQLAgent) Initialize(ctx context.Context) error {
+	return nil
+}
+
+// Shutdown 关闭智能体
+func (a *Text2SQLAgent) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ExecuteTask 执行任务
+func (a *Text2SQLAgent) ExecuteTask(ctx context.Context, task *types.Task) (*types.TaskResult, error) {
+	if !a.CanHandle(task) {
+		return &types.TaskResult{
+			Success:    false,
+			Error:      "无法处理此类型的任务",
+			ExecutedBy: a.agentType,
+		}, nil
+	}
+
+	output, artifacts, metadata, err := a.answer(ctx, task.Input)
+	metadata["task_type"] = task.Type
+	if err != nil {
+		return &types.TaskResult{
+			Success:    false,
+			Error:      fmt.Sprintf("Text2SQL失败: %v", err),
+			ExecutedBy: a.agentType,
+			Metadata:   metadata,
+		}, nil
+	}
+
+	return &types.TaskResult{
+		Success:    true,
+		Output:     output,
+		ExecutedBy: a.agentType,
+		Artifacts:  artifacts,
+		Metadata:   metadata,
+	}, nil
+}
+
+// answer 生成并执行SQL，返回文字结果、数据表产物和执行元数据（失败时也包含已尝试的SQL）
+func (a *Text2SQLAgent) answer(ctx context.Context, input interface{}) (string, map[string]*types.Artifact, map[string]interface{}, error) {
+	metadata := make(map[string]interface{})
+
+	req := &text2SQLRequest{}
+	if err := decodeTaskInput(input, req); err != nil {
+		return "", nil, metadata, err
+	}
+	if strings.TrimSpace(req.Question) == "" {
+		return "", nil, metadata, fmt.Errorf("未指定问题")
+	}
+	if a.chatModel == nil {
+		return "", nil, metadata, fmt.Errorf("未配置语言模型")
+	}
+
+	target, err := a.openTarget(ctx, req)
+	if err != nil {
+		return "", nil, metadata, err
+	}
+	defer target.close()
+	metadata["engine"] = target.engine
+	metadata["dialect"] = target.dialect
+
+	run, err := a.run(ctx, target, req.Question, req.MaxRows)
+	if run != nil {
+		metadata["sql"] = run.sql
+		metadata["attempts"] = run.attempts
+	}
+	if err != nil {
+		return "", nil, metadata, err
+	}
+
+	result := run.result
+	metadata["truncated"] = result.Truncated
+	frame := &types.Artifact{
+		Type: types.ArtifactTypeDataFrame,
+		Data: (&analytics.Table{Columns: result.Columns, Rows: result.Rows}).Frame(),
+	}
+
+	note := ""
+	if result.Truncated {
+		note = "，已达到行数上限"
+	}
+	output := fmt.Sprintf("SQL:\n```sql\n%s\n```\n\n查询结果（%d 行%s）:\n%s\n",
+		run.sql, result.RowCount, note, markdownTable(frame, nativeQueryMaxRows))
+	return output, map[string]*types.Artifact{types.ArtifactTypeDataFrame: frame}, metadata, nil
+}
+
+// run 生成SQL并执行，校验或执行失败时把错误反馈给模型重试
+func (a *Text2SQLAgent) run(ctx context.Context, target *sqlTarget, question string, maxRows int) (*text2SQLRun, error) {
+	library := promptLibrary(a.config)
+	systemPrompt, err := library.Render(prompts.TemplateExpertText2SQL, map[string]interface{}{
+		"Dialect":  target.dialect,
+		"Schema":   tools.DescribeSchemas(target.tables),
+		"Semantic": semantic.Describe(target.semantic, question),
+		"MaxRows":  sqlengine.RowLimit(maxRows),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make([]string, len(target.tables))
+	for i, table := range target.tables {
+		allowed[i] = table.TableName
+	}
+
+	conversation := []*schema.Message{
+		{Role: schema.System, Content: systemPrompt},
+		{Role: schema.User, Content: question},
+	}
+
+	run := &text2SQLRun{}
+	var lastErr error
+	for run.attempts < text2SQLMaxAttempts {
+		run.attempts++
+		response, err := a.chatModel.Generate(ctx, conversation)
+		if err != nil {
+			return run, fmt.Errorf("调用LLM失败: %w", err)
+		}
+
+		run.sql = extractSQL(response.Content)
+		// 先在本地校验，未通过时不访问数据库
+		_, lastErr = sqlguard.Check(run.sql, sqlguard.Options{Dialect: target.dialect, AllowedTables: allowed})
+		if lastErr == nil {
+			run.result, lastErr = target.query(ctx, run.sql, maxRows)
+			if lastErr == nil {
+				return run, nil
+			}
+		}
+		if ctx.Err() != nil {
+			return run, ctx.Err()
+		}
+
+		repairPrompt, err := library.Render(prompts.TemplateExpertText2SQLRepair, map[string]interface{}{
+			"Error": lastErr.Error(),
+		})
+		if err != nil {
+			return run, err
+		}
+		conversation = append(conversation,
+			&schema.Message{Role: schema.Assistant, Content: response.Content},
+			&schema.Message{Role: schema.User, Content: repairPrompt},
+		)
+	}
+	return run, fmt.Errorf("尝试 %d 次后SQL仍无法执行，最后一次错误: %w", run.attempts, lastErr)
+}
+
+// openTarget 打开执行SQL的目标，优先使用数据库数据源
+func (a *Text2SQLAgent) openTarget(ctx context.Context, req *text2SQLRequest) (*sqlTarget, error) {
+	if req.DataSourceID > 0 {
+		sources := a.config.DataSources
+		if sources == nil {
+			return nil, fmt.Errorf("未配置数据库数据源")
+		}
+		tables, err := sources.Tables(ctx, req.DataSourceID)
+		if err != nil {
+			return nil, err
+		}
+		dialect, err := sources.Dialect(ctx, req.DataSourceID)
+		if err != nil {
+			return nil, err
+		}
+		semanticModel, err := sources.Semantic(ctx, req.DataSourceID)
+		if err != nil {
+			return nil, err
+		}
+		return &sqlTarget{
+			engine:   "datasource",
+			dialect:  dialect,
+			tables:   tables,
+			semantic: semanticModel,
+			query: func(ctx context.Context, query string, maxRows int) (*sqlengine.Result, error) {
+				return sources.Query(ctx, req.DataSourceID, query, maxRows)
+			},
+			close: func() {},
+		}, nil
+	}
+
+	values := dataSourceValues(req.DataSource)
+	if len(values) == 0 {
+		return nil, fmt.Errorf("需要指定 data_source_id 或 data_source")
+	}
+	files := make([]sqlengine.Source, len(values))
+	for i, value := range values {
+		path, err := dataSourcePath(ctx, a.config.Datasets, value)
+		if err != nil {
+			return nil, err
+		}
+		files[i] = sqlengine.Source{Path: path}
+	}
+
+	db, err := sqlengine.Open(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTarget{
+		engine:  "files",
+		dialect: sqlguard.DialectSQLite,
+		tables:  fileSchemas(db.Tables()),
+		query:   db.Query,
+		close:   func() { db.Close() },
+	}, nil
+}
+
+// fileSchemas 将数据文件加载得到的表转换为表结构描述
+func fileSchemas(tables []sqlengine.TableInfo) []types.DataSchema {
+	schemas := make([]types.DataSchema, len(tables))
+	for i, table := range tables {
+		columns := make([]types.ColumnInfo, len(table.Columns))
+		for j, column := range table.Columns {
+			columns[j] = types.ColumnInfo{Name: column.Name, Type: column.Type}
+		}
+		source := filepath.Base(table.Path)
+		if table.Sheet != "" {
+			source += " / " + table.Sheet
+		}
+		schemas[i] = types.DataSchema{
+			TableName: table.Name,
+			Columns:   columns,
+			Metadata:  map[string]interface{}{"comment": fmt.Sprintf("%d 行，来自 %s", table.Rows, source)},
+		}
+	}
+	return schemas
+}
+
+// extractSQL 从响应中提取SQL代码块，没有代码块时返回整个响应
+func extractSQL(content string) string {
+	start := strings.Index(content, "```sql")
+	if start == -1 {
+		start = strings.Index(content, "```")
+		if start == -1 {
+			return strings.TrimSpace(content)
+		}
+		start += len("```")
+	} else {
+		start += len("```sql")
+	}
+
+	end := strings.Index(content[start:], "```")
+	if end == -1 {
+		return strings.TrimSpace(content[start:])
+	}
+	return strings.TrimSpace(content[start : start+end])
+}
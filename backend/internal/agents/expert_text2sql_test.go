@@ -0,0 +1,89 @@
+package agents
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"smart-analysis/internal/types"
+)
+
+func TestText2SQLAgent_RetryWithValidatorError(t *testing.T) {
+	dir := t.TempDir()
+	orders := filepath.Join(dir, "orders.csv")
+	users := filepath.Join(dir, "users.csv")
+	if err := os.WriteFile(orders, []byte("order_id,user_id,amount\n1,1,100\n2,2,50\n3,1,30\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(users, []byte("user_id,city\n1,上海\n2,北京\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	chatModel := &scriptedChatModel{responses: []string{
+		"```sql\nDELETE FROM orders\n```",
+		"```sql\nSELECT u.city, SUM(o.amount) AS total FROM orders o JOIN users u ON u.user_id = o.user_id GROUP BY u.city ORDER BY total DESC;\n```",
+	}}
+	expert, err := NewAgentFactory().CreateExpertAgent(context.Background(), types.AgentTypeText2SQL, &types.AgentConfig{ChatModel: chatModel})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := map[string]interface{}{
+		"question":    "各城市的订单金额",
+		"data_source": []interface{}{orders, users},
+	}
+	if err := text2SQLExpert.ValidateInput(input); err != nil {
+		t.Fatalf("输入校验失败: %v", err)
+	}
+
+	result, _ := expert.ExecuteTask(context.Background(), &types.Task{Type: "text2sql", Input: input})
+	if !result.Success {
+		t.Fatalf("执行失败: %s", result.Error)
+	}
+	if result.Metadata["attempts"] != 2 || result.Metadata["dialect"] != "sqlite" {
+		t.Errorf("元数据不正确: %v", result.Metadata)
+	}
+
+	// 系统提示包含表结构，第二次调用包含校验错误
+	if system := chatModel.calls[0][0].Content; !strings.Contains(system, "orders") || !strings.Contains(system, "city") {
+		t.Errorf("系统提示缺少表结构:\n%s", system)
+	}
+	if last := chatModel.calls[1][len(chatModel.calls[1])-1]; !strings.Contains(last.Content, "DELETE") {
+		t.Errorf("重试提示未包含校验错误: %s", last.Content)
+	}
+
+	output := result.Output.(string)
+	if !strings.Contains(output, "| 上海 | 130 |\n| 北京 | 50 |") {
+		t.Errorf("查询结果不正确:\n%s", output)
+	}
+	frame := result.Artifacts[types.ArtifactTypeDataFrame].Data.(map[string]interface{})
+	if rows := frame["data"].([]interface{}); len(rows) != 2 {
+		t.Errorf("数据表行数不正确: %v", rows)
+	}
+}
+
+func TestText2SQLAgent_GivesUpAfterMaxAttempts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.csv")
+	if err := os.WriteFile(path, []byte("order_id,amount\n1,100\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	chatModel := &scriptedChatModel{responses: []string{
+		"SELECT * FROM secrets",
+		"SELECT * FROM orders; DROP TABLE orders",
+		"SELECT * FROM sqlite_master",
+	}}
+	expert, _ := NewText2SQLAgent(context.Background(), &types.AgentConfig{ChatModel: chatModel})
+	result, _ := expert.ExecuteTask(context.Background(), &types.Task{
+		Type:  "sql_query",
+		Input: map[string]interface{}{"question": "所有数据", "data_source": path},
+	})
+	if result.Success {
+		t.Fatal("不安全的SQL不应执行成功")
+	}
+	if len(chatModel.calls) != text2SQLMaxAttempts || !strings.Contains(result.Error, "系统表") {
+		t.Errorf("应重试 %d 次后失败: %d 次, %s", text2SQLMaxAttempts, len(chatModel.calls), result.Error)
+	}
+}
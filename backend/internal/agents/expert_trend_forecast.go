@@ -7,26 +7,65 @@ import (
 	"strings"
 
 	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/prompts"
 	"smart-analysis/internal/tools"
 	"smart-analysis/internal/types"
 	"smart-analysis/internal/utils/sanbox"
 )
 
+// trendForecastExpert 趋势预测专家的注册信息
+var trendForecastExpert = &ExpertDefinition{
+	Type:        types.AgentTypeTrendForecast,
+	Name:        "趋势预测",
+	Description: "对时间序列做趋势、季节性分析并预测未来取值",
+	Capabilities: []string{
+		"时间序列分析",
+		"趋势预测",
+		"季节性分析",
+		"周期性检测",
+		"回归分析",
+		"ARIMA建模",
+		"指数平滑",
+		"机器学习预测",
+	},
+	TaskTypes: []string{"trend_forecast", "time_series", "forecast"},
+	InputSchema: mustInputSchema(types.AgentTypeTrendForecast, `{
+  "type": "object",
+  "properties": {
+    "data_source": {"type": "string", "description": "上游任务ID"},
+    "target_variable": {"type": "string", "description": "预测目标变量"},
+    "time_field": {"type": "string", "description": "时间字段"},
+    "forecast_periods": {"type": "integer", "minimum": 1, "description": "预测期数"},
+    "method": {"type": "string", "description": "预测方法，如 arima、prophet"},
+    "seasonal": {"type": "boolean"},
+    "requirements": {"description": "特殊要求"}
+  }
+}`),
+	Factory: func(ctx context.Context, config *types.AgentConfig) (types.ExpertAgent, error) {
+		return NewTrendForecastAgent(ctx, config)
+	},
+}
+
+func init() {
+	MustRegisterExpert(trendForecastExpert)
+}
+
 // TrendForecastAgent 趋势分析与预测专家智能体
 type TrendForecastAgent struct {
 	chatModel model.BaseChatModel
 	sandbox   *sanbox.PythonSandbox
 	config    *types.AgentConfig
 	agentType types.AgentType
-	mlTool    *tools.MLAnalysisTool
+	mlTool    tool.BaseTool
 }
 
 // NewTrendForecastAgent 创建趋势预测专家智能体
 func NewTrendForecastAgent(ctx context.Context, config *types.AgentConfig) (*TrendForecastAgent, error) {
-	var mlTool *tools.MLAnalysisTool
+	var mlTool tool.BaseTool
 	if config.PythonSandbox != nil {
-		mlTool = tools.NewMLAnalysisTool(config.PythonSandbox)
+		mlTool = tools.WithDatasets(tools.NewMLAnalysisTool(config.PythonSandbox), config.Datasets)
 	}
 
 	return &TrendForecastAgent{
@@ -45,24 +84,12 @@ func (a *TrendForecastAgent) GetType() types.AgentType {
 
 // GetCapabilities 获取能力描述
 func (a *TrendForecastAgent) GetCapabilities() []string {
-	return []string{
-		"时间序列分析",
-		"趋势预测",
-		"季节性分析",
-		"周期性检测",
-		"回归分析",
-		"ARIMA建模",
-		"指数平滑",
-		"机器学习预测",
-	}
+	return trendForecastExpert.Capabilities
 }
 
 // CanHandle 判断是否能处理特定任务
 func (a *TrendForecastAgent) CanHandle(task *types.Task) bool {
-	return task.Type == "trend_forecast" ||
-		task.Type == "time_series" ||
-		task.Type == "forecast" ||
-		task.AgentType == types.AgentTypeTrendForecast
+	return trendForecastExpert.CanHandle(task)
 }
 
 // Generate 生成响应
@@ -77,7 +104,7 @@ func (a *TrendForecastAgent) Generate(ctx context.Context, messages []*schema.Me
 	}
 
 	// 执行趋势分析
-	result, err := a.executeForecast(forecastCode)
+	result, _, err := a.executeForecast(ctx, forecastCode)
 	if err != nil {
 		return &schema.Message{
 			Role:    schema.Assistant,
@@ -148,7 +175,7 @@ func (a *TrendForecastAgent) ExecuteTask(ctx context.Context, task *types.Task)
 	}
 
 	// 执行趋势分析
-	result, err := a.executeForecast(forecastCode)
+	result, artifacts, err := a.executeForecast(ctx, forecastCode)
 	if err != nil {
 		return &types.TaskResult{
 			Success:    false,
@@ -161,6 +188,7 @@ func (a *TrendForecastAgent) ExecuteTask(ctx context.Context, task *types.Task)
 		Success:    true,
 		Output:     result,
 		ExecutedBy: a.agentType,
+		Artifacts:  artifacts,
 		Metadata: map[string]interface{}{
 			"forecast_code": forecastCode,
 			"task_type":     task.Type,
@@ -191,27 +219,10 @@ func (a *TrendForecastAgent) parseTaskInput(input interface{}) (map[string]inter
 // generateForecastCode 从消息生成趋势分析代码
 func (a *TrendForecastAgent) generateForecastCode(ctx context.Context, messages []*schema.Message) (string, error) {
 	// 构建趋势分析的系统提示
-	systemPrompt := `你是一个专业的时间序列分析和趋势预测专家。请根据用户需求生成高质量的Python趋势分析代码。
-
-代码要求：
-1. 使用pandas, numpy, matplotlib, seaborn, scikit-learn, statsmodels等专业库
-2. 包含完整的时间序列分析流程：
-   - 数据预处理和清洗
-   - 趋势和季节性分析
-   - 平稳性检验
-   - 模型拟合和预测
-   - 预测结果可视化
-3. 支持多种预测方法：
-   - 线性回归
-   - ARIMA模型
-   - 指数平滑
-   - 机器学习方法
-4. 提供预测精度评估
-5. 生成趋势图表和预测结果
-6. 包含适当的注释和错误处理
-7. 保存图表为'forecast_output.png'
-
-请只返回Python代码，不要添加其他解释。`
+	systemPrompt, err := promptLibrary(a.config).Render(prompts.TemplateExpertTrendForecast, nil)
+	if err != nil {
+		return "", err
+	}
 
 	forecastMessages := []*schema.Message{
 		{
@@ -250,7 +261,7 @@ func (a *TrendForecastAgent) buildForecastDescription(forecastReq map[string]int
 
 	// 检查数据源
 	if dataSource, ok := forecastReq["data_source"]; ok {
-		desc = append(desc, fmt.Sprintf("基于数据源: %v", dataSource))
+		desc = append(desc, "基于数据源: "+describeDataSource(dataSource))
 	}
 
 	// 检查预测目标
@@ -295,18 +306,18 @@ func (a *TrendForecastAgent) buildForecastDescription(forecastReq map[string]int
 }
 
 // executeForecast 执行趋势分析
-func (a *TrendForecastAgent) executeForecast(code string) (string, error) {
+func (a *TrendForecastAgent) executeForecast(ctx context.Context, code string) (string, map[string]*types.Artifact, error) {
 	if a.sandbox == nil {
-		return "", fmt.Errorf("Python沙盒未配置")
+		return "", nil, fmt.Errorf("Python沙盒未配置")
 	}
 
-	result, err := a.sandbox.ExecutePython(code)
+	result, err := a.sandbox.ExecuteCodeContext(ctx, code)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	if !result.Success {
-		return "", fmt.Errorf("趋势分析执行失败: %s", result.Error)
+		return "", nil, fmt.Errorf("趋势分析执行失败: %s", result.Error)
 	}
 
 	response := "趋势分析和预测完成！\n\n"
@@ -319,7 +330,7 @@ func (a *TrendForecastAgent) executeForecast(code string) (string, error) {
 		response += "生成的趋势图表: " + result.ImagePath + "\n"
 	}
 
-	return response, nil
+	return response, sandboxArtifacts(result), nil
 }
 
 // extractPythonCode 从响应中提取Python代码
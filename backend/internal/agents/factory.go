@@ -15,80 +15,61 @@ func NewAgentFactory() *AgentFactory {
 	return &AgentFactory{}
 }
 
-// CreateAgent 创建智能体
+// CreateAgent 创建智能体，专家智能体通过注册表创建
 func (f *AgentFactory) CreateAgent(ctx context.Context, agentType types.AgentType, config *types.AgentConfig) (types.Agent, error) {
 	switch agentType {
 	case types.AgentTypeMaster:
 		return NewMasterAgent(ctx, config)
 	case types.AgentTypePlanner:
 		return NewPlannerAgent(ctx, config)
-	case types.AgentTypeDataQuery:
-		return NewDataQueryAgent(ctx, config)
-	case types.AgentTypeDataAnalysis:
-		return NewDataAnalysisAgent(ctx, config)
-	case types.AgentTypeTrendForecast:
-		return NewTrendForecastAgent(ctx, config)
-	case types.AgentTypeAnomalyDetection:
-		return NewAnomalyDetectionAgent(ctx, config)
-	case types.AgentTypeAttributionAnalysis:
-		return NewAttributionAnalysisAgent(ctx, config)
 	case types.AgentTypeReact:
 		return NewReactAgent(ctx, config)
 	case types.AgentTypeAnalysis:
 		return NewAnalysisAgent(ctx, config)
 	case types.AgentTypeMulti:
 		return NewMultiAgentManager(ctx, config)
-	default:
-		return nil, fmt.Errorf("不支持的智能体类型: %s", agentType)
 	}
+
+	if _, ok := LookupExpert(agentType); ok {
+		return f.CreateExpertAgent(ctx, agentType, config)
+	}
+	return nil, fmt.Errorf("不支持的智能体类型: %s", agentType)
 }
 
-// CreateExpertAgent 创建专家智能体
+// CreateExpertAgent 创建已注册的专家智能体
 func (f *AgentFactory) CreateExpertAgent(ctx context.Context, agentType types.AgentType, config *types.AgentConfig) (types.ExpertAgent, error) {
-	switch agentType {
-	case types.AgentTypeDataQuery:
-		return NewDataQueryAgent(ctx, config)
-	case types.AgentTypeDataAnalysis:
-		return NewDataAnalysisAgent(ctx, config)
-	case types.AgentTypeTrendForecast:
-		return NewTrendForecastAgent(ctx, config)
-	case types.AgentTypeAnomalyDetection:
-		return NewAnomalyDetectionAgent(ctx, config)
-	case types.AgentTypeAttributionAnalysis:
-		return NewAttributionAnalysisAgent(ctx, config)
-	default:
+	def, ok := LookupExpert(agentType)
+	if !ok {
 		return nil, fmt.Errorf("不支持的专家智能体类型: %s", agentType)
 	}
+	return def.Factory(ctx, config)
 }
 
 // GetAvailableAgentTypes 获取可用的智能体类型
 func (f *AgentFactory) GetAvailableAgentTypes() []types.AgentType {
-	return []types.AgentType{
+	agentTypes := []types.AgentType{
 		types.AgentTypeMaster,
 		types.AgentTypePlanner,
-		types.AgentTypeDataQuery,
-		types.AgentTypeDataAnalysis,
-		types.AgentTypeTrendForecast,
-		types.AgentTypeAnomalyDetection,
-		types.AgentTypeAttributionAnalysis,
+	}
+	agentTypes = append(agentTypes, f.GetExpertAgentTypes()...)
+	return append(agentTypes,
 		types.AgentTypeReact,
 		types.AgentTypeAnalysis,
 		types.AgentTypeMulti,
-	}
+	)
 }
 
-// GetExpertAgentTypes 获取专家智能体类型
+// GetExpertAgentTypes 获取已注册的专家智能体类型
 func (f *AgentFactory) GetExpertAgentTypes() []types.AgentType {
-	return []types.AgentType{
-		types.AgentTypeDataQuery,
-		types.AgentTypeDataAnalysis,
-		types.AgentTypeTrendForecast,
-		types.AgentTypeAnomalyDetection,
-		types.AgentTypeAttributionAnalysis,
+	defs := ExpertDefinitions()
+	agentTypes := make([]types.AgentType, 0, len(defs))
+	for _, def := range defs {
+		agentTypes = append(agentTypes, def.Type)
 	}
+	return agentTypes
 }
 
-// GetAgentCapabilities 获取智能体能力描述
+// GetAgentCapabilities 获取智能体能力描述，专家智能体的能力来自注册表
 func (f *AgentFactory) GetAgentCapabilities(agentType types.AgentType) []string {
 	switch agentType {
 	case types.AgentTypeMaster:
@@ -105,57 +86,6 @@ func (f *AgentFactory) GetAgentCapabilities(agentType types.AgentType) []string
 			"任务调度和管理",
 			"专家智能体协调",
 		}
-	case types.AgentTypeDataQuery:
-		return []string{
-			"数据查询与筛选",
-			"SQL查询生成",
-			"数据过滤和聚合",
-			"多表关联查询",
-			"数据预览和统计",
-		}
-	case types.AgentTypeDataAnalysis:
-		return []string{
-			"描述性统计分析",
-			"相关性分析",
-			"分布分析",
-			"对比分析",
-			"数据可视化",
-			"数据预处理",
-			"特征工程",
-		}
-	case types.AgentTypeTrendForecast:
-		return []string{
-			"时间序列分析",
-			"趋势预测",
-			"季节性分析",
-			"周期性检测",
-			"回归分析",
-			"ARIMA建模",
-			"指数平滑",
-			"机器学习预测",
-		}
-	case types.AgentTypeAnomalyDetection:
-		return []string{
-			"异常值检测",
-			"离群点分析",
-			"时间序列异常检测",
-			"统计异常检测",
-			"机器学习异常检测",
-			"异动根因分析",
-			"异常模式识别",
-			"实时异常监控",
-		}
-	case types.AgentTypeAttributionAnalysis:
-		return []string{
-			"因果关系分析",
-			"根因分析",
-			"贡献度分析",
-			"影响因子识别",
-			"特征重要性分析",
-			"相关性与因果性分析",
-			"回归分析",
-			"变化归因分析",
-		}
 	case types.AgentTypeReact:
 		return []string{
 			"工具调用和执行",
@@ -176,7 +106,10 @@ func (f *AgentFactory) GetAgentCapabilities(agentType types.AgentType) []string
 			"流程自动化",
 			"综合分析能力",
 		}
-	default:
-		return []string{"未知智能体类型"}
 	}
+
+	if def, ok := LookupExpert(agentType); ok {
+		return def.Capabilities
+	}
+	return []string{"未知智能体类型"}
 }
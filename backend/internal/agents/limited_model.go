@@ -0,0 +1,95 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/scheduler"
+)
+
+// limitedChatModel 按LLM提供商限制并发调用的聊天模型
+type limitedChatModel struct {
+	model     model.BaseChatModel
+	scheduler *scheduler.Scheduler
+	resource  string
+}
+
+// limitedToolCallingChatModel 支持工具调用的 limitedChatModel
+type limitedToolCallingChatModel struct {
+	*limitedChatModel
+}
+
+// NewLimitedChatModel 包装聊天模型，每次调用前在调度器中获取提供商的并发槽位。
+// 流式调用在流读取结束或关闭后才释放槽位；被包装的模型支持工具调用时返回的模型同样支持
+func NewLimitedChatModel(chatModel model.BaseChatModel, s *scheduler.Scheduler, provider string) model.BaseChatModel {
+	limited := &limitedChatModel{
+		model:     chatModel,
+		scheduler: s,
+		resource:  scheduler.LLMResource(provider),
+	}
+	if _, ok := chatModel.(model.ToolCallingChatModel); ok {
+		return &limitedToolCallingChatModel{limited}
+	}
+	return limited
+}
+
+// Generate 获取并发槽位后生成响应
+func (m *limitedChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	release, err := m.scheduler.Acquire(ctx, m.resource)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return m.model.Generate(ctx, input, opts...)
+}
+
+// Stream 获取并发槽位后流式生成响应
+func (m *limitedChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	release, err := m.scheduler.Acquire(ctx, m.resource)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := m.model.Stream(ctx, input, opts...)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	sr, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer release()
+		defer stream.Close()
+		defer sw.Close()
+
+		for {
+			msg, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if closed := sw.Send(msg, err); closed || err != nil {
+				return
+			}
+		}
+	}()
+
+	return sr, nil
+}
+
+// WithTools 绑定工具，返回的模型同样受并发限制
+func (m *limitedToolCallingChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound, err := m.model.(model.ToolCallingChatModel).WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+
+	return &limitedToolCallingChatModel{&limitedChatModel{
+		model:     bound,
+		scheduler: m.scheduler,
+		resource:  m.resource,
+	}}, nil
+}
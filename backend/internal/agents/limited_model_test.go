@@ -0,0 +1,52 @@
+package agents
+
+import (
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/scheduler"
+)
+
+// toolChatModel 支持工具调用的 scriptedChatModel
+type toolChatModel struct {
+	scriptedChatModel
+	tools []*schema.ToolInfo
+}
+
+func (m *toolChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return &toolChatModel{tools: tools}, nil
+}
+
+func TestLimitedChatModel_ToolCalling(t *testing.T) {
+	sched := scheduler.New(scheduler.Limits{})
+	if _, ok := NewLimitedChatModel(&scriptedChatModel{}, sched, "openai").(model.ToolCallingChatModel); ok {
+		t.Error("被包装的模型不支持工具调用时不应声明支持")
+	}
+
+	limited, ok := NewLimitedChatModel(&toolChatModel{}, sched, "openai").(model.ToolCallingChatModel)
+	if !ok {
+		t.Fatal("被包装的模型支持工具调用时应声明支持")
+	}
+	bound, err := limited.WithTools([]*schema.ToolInfo{{Name: "sql_query"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := bound.(*limitedToolCallingChatModel).model.(*toolChatModel)
+	if len(inner.tools) != 1 || inner.tools[0].Name != "sql_query" {
+		t.Errorf("工具未绑定到被包装的模型: %+v", inner.tools)
+	}
+}
+
+func TestAuditedChatModel_ToolCalling(t *testing.T) {
+	if _, ok := NewAuditedChatModel(&scriptedChatModel{}, "openai").(model.ToolCallingChatModel); ok {
+		t.Error("被包装的模型不支持工具调用时不应声明支持")
+	}
+	audited, ok := NewAuditedChatModel(&toolChatModel{}, "openai").(model.ToolCallingChatModel)
+	if !ok {
+		t.Fatal("被包装的模型支持工具调用时应声明支持")
+	}
+	if _, err := audited.WithTools([]*schema.ToolInfo{{Name: "sql_query"}}); err != nil {
+		t.Fatal(err)
+	}
+}
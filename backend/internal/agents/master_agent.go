@@ -3,11 +3,13 @@ package agents
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/prompts"
+	"smart-analysis/internal/semantic"
 	"smart-analysis/internal/types"
 )
 
@@ -34,16 +36,20 @@ func (a *MasterAgent) GetType() types.AgentType {
 
 // Generate 生成响应 - 主要用于意图识别和查询重写
 func (a *MasterAgent) Generate(ctx context.Context, messages []*schema.Message, opts ...interface{}) (*schema.Message, error) {
-	// 解析数据模式（如果提供）
+	// 解析数据模式和业务语义模型（如果提供）
 	var dataSchema *types.DataSchema
-	if len(opts) > 0 {
-		if schema, ok := opts[0].(*types.DataSchema); ok {
-			dataSchema = schema
+	var semanticModel *types.SemanticModel
+	for _, opt := range opts {
+		switch v := opt.(type) {
+		case *types.DataSchema:
+			dataSchema = v
+		case *types.SemanticModel:
+			semanticModel = v
 		}
 	}
 
 	// 进行意图识别和查询重写
-	queryIntent, err := a.identifyIntent(ctx, messages, dataSchema)
+	queryIntent, err := a.identifyIntent(ctx, messages, dataSchema, semanticModel)
 	if err != nil {
 		return &schema.Message{
 			Role:    schema.Assistant,
@@ -93,8 +99,8 @@ func (a *MasterAgent) Shutdown(ctx context.Context) error {
 	return nil
 }
 
-// identifyIntent 意图识别和查询重写
-func (a *MasterAgent) identifyIntent(ctx context.Context, messages []*schema.Message, dataSchema *types.DataSchema) (*types.QueryIntent, error) {
+// identifyIntent 意图识别和查询重写，语义模型用于把问题中的业务术语对应到表、列和指标
+func (a *MasterAgent) identifyIntent(ctx context.Context, messages []*schema.Message, dataSchema *types.DataSchema, semanticModel *types.SemanticModel) (*types.QueryIntent, error) {
 	if len(messages) == 0 {
 		return nil, fmt.Errorf("没有输入消息")
 	}
@@ -112,37 +118,21 @@ func (a *MasterAgent) identifyIntent(ctx context.Context, messages []*schema.Mes
 		return nil, fmt.Errorf("未找到用户查询")
 	}
 
-	// 构建数据模式信息
-	var schemaInfo string
-	if dataSchema != nil {
-		schemaJSON, _ := json.MarshalIndent(dataSchema, "", "  ")
-		schemaInfo = string(schemaJSON)
-	} else {
-		schemaInfo = "数据模式未提供"
+	// 构建意图识别的提示
+	library := promptLibrary(a.config)
+	systemPrompt, err := library.Render(prompts.TemplateMasterIntentSystem, map[string]interface{}{
+		"DataSchema": dataSchema,
+		"Semantic":   semantic.Describe(semanticModel, userQuery),
+		"Query":      userQuery,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// 构建意图识别的系统提示
-	systemPrompt := fmt.Sprintf(`你是一个专业的数据分析意图识别专家。请分析用户的查询，基于数据模式信息，识别其数据查询或分析诉求中的重点信息。
-
-数据模式信息:
-%s
-
-请分析用户查询并提取以下信息：
-1. 意图类型：确定用户是想要数据查询、数据分析、可视化、趋势预测、异动检测还是归因分析
-2. 事件(Events)：用户关心的业务事件或指标
-3. 维度(Dimensions)：用户想要分析的维度，如时间、地区、产品类型等
-4. 度量(Metrics)：用户关心的度量指标，如数量、金额、比率等
-5. 过滤条件(Filters)：用户提到的筛选条件
-6. 时间范围(TimeRange)：用户指定的时间范围
-7. 分组和排序要求
-8. 其他特殊要求
-
-请返回标准的JSON格式结果，包含以下字段：
-- intent_type: 意图类型 (data_query/analysis/visualization/trend_forecast/anomaly_detection/attribution_analysis)
-- query_object: 包含events, dimensions, metrics, filters, time_range, group_by, order_by等
-- requirements: 用户的具体要求列表
-
-用户查询: %s`, schemaInfo, userQuery)
+	userPrompt, err := library.Render(prompts.TemplateMasterIntentUser, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	// 使用LLM进行意图识别
 	intentMessages := []*schema.Message{
@@ -152,19 +142,19 @@ func (a *MasterAgent) identifyIntent(ctx context.Context, messages []*schema.Mes
 		},
 		{
 			Role:    schema.User,
-			Content: "请分析并提取这个查询的意图和结构化信息。",
+			Content: userPrompt,
 		},
 	}
 
-	response, err := a.chatModel.Generate(ctx, intentMessages)
-	if err != nil {
-		return nil, fmt.Errorf("调用LLM失败: %w", err)
-	}
+	// 生成并校验结构化的意图结果
+	var queryIntent types.QueryIntent
+	if err := generateStructured(ctx, a.config, a.chatModel, intentMessages, types.QueryIntentSchema(), &queryIntent); err != nil {
+		var structuredErr *StructuredOutputError
+		if !errors.As(err, &structuredErr) {
+			return nil, err
+		}
 
-	// 解析LLM返回的JSON结果
-	queryIntent, err := a.parseIntentResponse(response.Content)
-	if err != nil {
-		// 如果解析失败，创建一个基本的意图对象
+		// 多轮修复后仍不合法，创建一个基本的意图对象
 		return &types.QueryIntent{
 			IntentType: "analysis",
 			DataSchema: dataSchema,
@@ -172,69 +162,20 @@ func (a *MasterAgent) identifyIntent(ctx context.Context, messages []*schema.Mes
 				Events: []string{userQuery},
 			},
 			Requirements: []string{userQuery},
+			Metadata: map[string]interface{}{
+				"intent_fallback_reason": structuredErr.Error(),
+			},
 		}, nil
 	}
 
-	// 设置数据模式
+	// 设置数据模式，并记录问题中识别到的业务术语供后续任务使用
 	queryIntent.DataSchema = dataSchema
-
-	return queryIntent, nil
-}
-
-// parseIntentResponse 解析意图识别响应
-func (a *MasterAgent) parseIntentResponse(content string) (*types.QueryIntent, error) {
-	// 尝试提取JSON内容
-	jsonStr := a.extractJSON(content)
-	if jsonStr == "" {
-		return nil, fmt.Errorf("未找到有效的JSON内容")
-	}
-
-	var intent types.QueryIntent
-	err := json.Unmarshal([]byte(jsonStr), &intent)
-	if err != nil {
-		return nil, fmt.Errorf("JSON解析失败: %w", err)
-	}
-
-	return &intent, nil
-}
-
-// extractJSON 从文本中提取JSON内容
-func (a *MasterAgent) extractJSON(content string) string {
-	// 查找JSON代码块
-	start := strings.Index(content, "```json")
-	if start != -1 {
-		start += len("```json")
-		end := strings.Index(content[start:], "```")
-		if end != -1 {
-			return strings.TrimSpace(content[start : start+end])
-		}
-	}
-
-	// 查找普通代码块
-	start = strings.Index(content, "```")
-	if start != -1 {
-		start += 3
-		end := strings.Index(content[start:], "```")
-		if end != -1 {
-			return strings.TrimSpace(content[start : start+end])
-		}
-	}
-
-	// 尝试查找JSON对象
-	start = strings.Index(content, "{")
-	if start != -1 {
-		braceCount := 0
-		for i := start; i < len(content); i++ {
-			if content[i] == '{' {
-				braceCount++
-			} else if content[i] == '}' {
-				braceCount--
-				if braceCount == 0 {
-					return strings.TrimSpace(content[start : i+1])
-				}
-			}
+	if terms := semantic.Resolve(semanticModel, userQuery); len(terms) > 0 {
+		if queryIntent.Metadata == nil {
+			queryIntent.Metadata = make(map[string]interface{})
 		}
+		queryIntent.Metadata["semantic_terms"] = terms
 	}
 
-	return ""
+	return &queryIntent, nil
 }
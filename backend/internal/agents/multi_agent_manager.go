@@ -53,15 +53,8 @@ func (m *MultiAgentManager) GetType() types.AgentType {
 
 // Generate 生成响应
 func (m *MultiAgentManager) Generate(ctx context.Context, messages []*schema.Message, opts ...interface{}) (*schema.Message, error) {
-	// 第一步：使用MasterAgent进行意图识别和查询重写
-	var dataSchema *types.DataSchema
-	if len(opts) > 0 {
-		if schema, ok := opts[0].(*types.DataSchema); ok {
-			dataSchema = schema
-		}
-	}
-
-	intentResponse, err := m.masterAgent.Generate(ctx, messages, dataSchema)
+	// 第一步：使用MasterAgent进行意图识别和查询重写，数据模式和业务语义模型原样传入
+	intentResponse, err := m.masterAgent.Generate(ctx, messages, opts...)
 	if err != nil {
 		return &schema.Message{
 			Role:    schema.Assistant,
@@ -109,14 +102,7 @@ func (m *MultiAgentManager) Stream(ctx context.Context, messages []*schema.Messa
 			Content: "🧠 MasterAgent 正在分析您的查询意图...",
 		}, nil)
 
-		var dataSchema *types.DataSchema
-		if len(opts) > 0 {
-			if schema, ok := opts[0].(*types.DataSchema); ok {
-				dataSchema = schema
-			}
-		}
-
-		intentResponse, err := m.masterAgent.Generate(ctx, messages, dataSchema)
+		intentResponse, err := m.masterAgent.Generate(ctx, messages, opts...)
 		if err != nil {
 			sw.Send(&schema.Message{
 				Role:    schema.Assistant,
@@ -169,6 +155,16 @@ func (m *MultiAgentManager) Stream(ctx context.Context, messages []*schema.Messa
 	return sr, nil
 }
 
+// ResumePlan 恢复执行已持久化的计划
+func (m *MultiAgentManager) ResumePlan(ctx context.Context, planID string) (*types.ExecutionPlan, error) {
+	return m.plannerAgent.ResumePlan(ctx, planID)
+}
+
+// RerunTask 重新执行计划中的单个任务及其下游任务
+func (m *MultiAgentManager) RerunTask(ctx context.Context, planID, taskID string, input interface{}) (*types.ExecutionPlan, error) {
+	return m.plannerAgent.RerunTask(ctx, planID, taskID, input)
+}
+
 // Initialize 初始化智能体
 func (m *MultiAgentManager) Initialize(ctx context.Context) error {
 	// 初始化主控智能体
@@ -199,42 +195,15 @@ func (m *MultiAgentManager) Shutdown(ctx context.Context) error {
 	return nil
 }
 
-// registerExpertAgents 注册专家智能体
+// registerExpertAgents 创建并注册所有已注册的专家智能体
 func (m *MultiAgentManager) registerExpertAgents(ctx context.Context) error {
-	// 创建并注册数据查询专家智能体
-	dataQueryAgent, err := NewDataQueryAgent(ctx, m.config)
-	if err != nil {
-		return fmt.Errorf("创建数据查询专家智能体失败: %w", err)
-	}
-	m.plannerAgent.RegisterExpertAgent(dataQueryAgent)
-
-	// 创建并注册数据分析专家智能体
-	dataAnalysisAgent, err := NewDataAnalysisAgent(ctx, m.config)
-	if err != nil {
-		return fmt.Errorf("创建数据分析专家智能体失败: %w", err)
-	}
-	m.plannerAgent.RegisterExpertAgent(dataAnalysisAgent)
-
-	// 创建并注册趋势预测专家智能体
-	trendForecastAgent, err := NewTrendForecastAgent(ctx, m.config)
-	if err != nil {
-		return fmt.Errorf("创建趋势预测专家智能体失败: %w", err)
-	}
-	m.plannerAgent.RegisterExpertAgent(trendForecastAgent)
-
-	// 创建并注册异动检测专家智能体
-	anomalyDetectionAgent, err := NewAnomalyDetectionAgent(ctx, m.config)
-	if err != nil {
-		return fmt.Errorf("创建异动检测专家智能体失败: %w", err)
-	}
-	m.plannerAgent.RegisterExpertAgent(anomalyDetectionAgent)
-
-	// 创建并注册归因分析专家智能体
-	attributionAnalysisAgent, err := NewAttributionAnalysisAgent(ctx, m.config)
-	if err != nil {
-		return fmt.Errorf("创建归因分析专家智能体失败: %w", err)
+	for _, def := range ExpertDefinitions() {
+		expert, err := def.Factory(ctx, m.config)
+		if err != nil {
+			return fmt.Errorf("创建专家智能体 %s 失败: %w", def.Type, err)
+		}
+		m.plannerAgent.RegisterExpertAgent(expert)
 	}
-	m.plannerAgent.RegisterExpertAgent(attributionAnalysisAgent)
 
 	return nil
 }
@@ -0,0 +1,177 @@
+package agents
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"smart-analysis/internal/types"
+)
+
+// PlanValidationError 执行计划校验错误
+type PlanValidationError struct {
+	PlanID string
+	Issues []string
+}
+
+// Error 实现error接口
+func (e *PlanValidationError) Error() string {
+	return fmt.Sprintf("执行计划 %s 不合法: %s", e.PlanID, strings.Join(e.Issues, "; "))
+}
+
+// mergePlanDependencies 合并计划级依赖、任务依赖以及输入中 data_source 引用的任务，
+// 合并后 Task.Dependencies 与 ExecutionPlan.Dependencies 保持一致
+func mergePlanDependencies(plan *types.ExecutionPlan) {
+	if plan.Dependencies == nil {
+		plan.Dependencies = map[string][]string{}
+	}
+
+	taskIDs := make(map[string]bool, len(plan.Tasks))
+	for _, task := range plan.Tasks {
+		if task != nil && task.ID != "" {
+			taskIDs[task.ID] = true
+		}
+	}
+
+	for _, task := range plan.Tasks {
+		if task != nil {
+			mergeTaskDependencies(task, plan.Dependencies[task.ID], taskIDs, plan.Dependencies)
+		}
+	}
+}
+
+// mergeTaskDependencies 合并单个任务的依赖并写回计划级依赖映射
+func mergeTaskDependencies(task *types.Task, planDeps []string, taskIDs map[string]bool, dependencies map[string][]string) {
+	seen := make(map[string]bool)
+	var merged []string
+	add := func(ids ...string) {
+		for _, id := range ids {
+			if id != "" && !seen[id] {
+				seen[id] = true
+				merged = append(merged, id)
+			}
+		}
+	}
+
+	add(task.Dependencies...)
+	add(planDeps...)
+	add(dataSourceRefs(task.Input, taskIDs)...)
+
+	task.Dependencies = merged
+	if len(merged) > 0 {
+		dependencies[task.ID] = merged
+	}
+}
+
+// validateExecutionPlan 在执行前校验计划：任务ID、专家智能体、依赖引用以及循环依赖
+func validateExecutionPlan(plan *types.ExecutionPlan, agents map[types.AgentType]bool) error {
+	var issues []string
+
+	if len(plan.Tasks) == 0 {
+		issues = append(issues, "计划不包含任何任务")
+	}
+
+	tasks := make(map[string]*types.Task, len(plan.Tasks))
+	for i, task := range plan.Tasks {
+		switch {
+		case task == nil:
+			issues = append(issues, fmt.Sprintf("第 %d 个任务为空", i+1))
+			continue
+		case task.ID == "":
+			issues = append(issues, fmt.Sprintf("第 %d 个任务缺少ID", i+1))
+			continue
+		case tasks[task.ID] != nil:
+			issues = append(issues, fmt.Sprintf("任务ID重复: %s", task.ID))
+			continue
+		}
+		tasks[task.ID] = task
+
+		if !agents[task.AgentType] {
+			issues = append(issues, fmt.Sprintf("任务 %s 使用了未知的专家智能体: %s", task.ID, task.AgentType))
+		}
+	}
+
+	for _, task := range plan.Tasks {
+		if task == nil || tasks[task.ID] != task {
+			continue
+		}
+		for _, depID := range task.Dependencies {
+			if depID == task.ID {
+				issues = append(issues, fmt.Sprintf("任务 %s 依赖自身", task.ID))
+			} else if tasks[depID] == nil {
+				issues = append(issues, fmt.Sprintf("任务 %s 依赖不存在的任务: %s", task.ID, depID))
+			}
+		}
+	}
+
+	for taskID := range plan.Dependencies {
+		if tasks[taskID] == nil {
+			issues = append(issues, fmt.Sprintf("依赖关系引用了不存在的任务: %s", taskID))
+		}
+	}
+
+	if len(issues) == 0 {
+		if cycle := findDependencyCycle(plan.Tasks); len(cycle) > 0 {
+			issues = append(issues, fmt.Sprintf("存在循环依赖: %s", strings.Join(cycle, " -> ")))
+		}
+	}
+
+	if len(issues) > 0 {
+		sort.Strings(issues)
+		return &PlanValidationError{PlanID: plan.ID, Issues: issues}
+	}
+
+	return nil
+}
+
+// findDependencyCycle 使用深度优先搜索查找循环依赖，返回环上的任务ID（首尾相同）
+func findDependencyCycle(tasks []*types.Task) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	deps := make(map[string][]string, len(tasks))
+	for _, task := range tasks {
+		deps[task.ID] = task.Dependencies
+	}
+
+	state := make(map[string]int, len(tasks))
+	var stack []string
+
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		state[id] = visiting
+		stack = append(stack, id)
+
+		for _, depID := range deps[id] {
+			switch state[depID] {
+			case visiting:
+				for i, stackID := range stack {
+					if stackID == depID {
+						return append(append([]string{}, stack[i:]...), depID)
+					}
+				}
+			case unvisited:
+				if cycle := visit(depID); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[id] = visited
+		return nil
+	}
+
+	for _, task := range tasks {
+		if state[task.ID] == unvisited {
+			if cycle := visit(task.ID); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
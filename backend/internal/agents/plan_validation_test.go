@@ -0,0 +1,164 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/types"
+)
+
+// stubExpertAgent 返回固定结果并记录收到的任务输入和产物目录
+type stubExpertAgent struct {
+	agentType    types.AgentType
+	result       *types.TaskResult
+	execute      func(task *types.Task) *types.TaskResult // 设置后优先于result
+	inputs       []interface{}
+	artifactDirs []string
+	mu           sync.Mutex
+}
+
+func (a *stubExpertAgent) GetType() types.AgentType { return a.agentType }
+func (a *stubExpertAgent) Generate(ctx context.Context, messages []*schema.Message, opts ...interface{}) (*schema.Message, error) {
+	return nil, nil
+}
+func (a *stubExpertAgent) Stream(ctx context.Context, messages []*schema.Message, opts ...interface{}) (*schema.StreamReader[*schema.Message], error) {
+	return nil, nil
+}
+func (a *stubExpertAgent) Initialize(ctx context.Context) error { return nil }
+func (a *stubExpertAgent) Shutdown(ctx context.Context) error   { return nil }
+func (a *stubExpertAgent) GetCapabilities() []string            { return nil }
+func (a *stubExpertAgent) CanHandle(task *types.Task) bool      { return task.AgentType == a.agentType }
+func (a *stubExpertAgent) ExecuteTask(ctx context.Context, task *types.Task) (*types.TaskResult, error) {
+	a.mu.Lock()
+	a.inputs = append(a.inputs, task.Input)
+	a.artifactDirs = append(a.artifactDirs, types.ArtifactDirFromContext(ctx))
+	a.mu.Unlock()
+	if a.execute != nil {
+		return a.execute(task), nil
+	}
+	return a.result, nil
+}
+
+func TestValidateExecutionPlan(t *testing.T) {
+	agents := map[types.AgentType]bool{types.AgentTypeDataQuery: true, types.AgentTypeDataAnalysis: true}
+
+	cases := []struct {
+		name  string
+		plan  *types.ExecutionPlan
+		issue string
+	}{
+		{
+			name: "cycle",
+			plan: &types.ExecutionPlan{Tasks: []*types.Task{
+				{ID: "a", AgentType: types.AgentTypeDataQuery, Dependencies: []string{"c"}},
+				{ID: "b", AgentType: types.AgentTypeDataQuery, Dependencies: []string{"a"}},
+				{ID: "c", AgentType: types.AgentTypeDataQuery},
+			}, Dependencies: map[string][]string{"c": {"b"}}},
+			issue: "循环依赖",
+		},
+		{
+			name:  "unknown agent",
+			plan:  &types.ExecutionPlan{Tasks: []*types.Task{{ID: "a", AgentType: "fortune_teller"}}},
+			issue: "未知的专家智能体",
+		},
+		{
+			name:  "missing dependency",
+			plan:  &types.ExecutionPlan{Tasks: []*types.Task{{ID: "a", AgentType: types.AgentTypeDataQuery, Dependencies: []string{"ghost"}}}},
+			issue: "不存在的任务: ghost",
+		},
+		{
+			name: "duplicate id",
+			plan: &types.ExecutionPlan{Tasks: []*types.Task{
+				{ID: "a", AgentType: types.AgentTypeDataQuery},
+				{ID: "a", AgentType: types.AgentTypeDataAnalysis},
+			}},
+			issue: "任务ID重复",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mergePlanDependencies(tc.plan)
+			err := validateExecutionPlan(tc.plan, agents)
+
+			var validationErr *PlanValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("期望返回PlanValidationError，实际: %v", err)
+			}
+			if !strings.Contains(err.Error(), tc.issue) {
+				t.Errorf("错误信息 %q 未包含 %q", err.Error(), tc.issue)
+			}
+		})
+	}
+}
+
+func TestPlannerAgent_DataSourceWiring(t *testing.T) {
+	frame := map[string]interface{}{
+		"columns": []interface{}{"region", "sales"},
+		"data":    []interface{}{[]interface{}{"east", 10.0}, []interface{}{"west", 7.0}},
+		"index":   []interface{}{0.0, 1.0},
+		"shape":   []interface{}{2.0, 2.0},
+	}
+	query := &stubExpertAgent{agentType: types.AgentTypeDataQuery, result: &types.TaskResult{
+		Success:   true,
+		Output:    "查询完成",
+		Artifacts: map[string]*types.Artifact{types.ArtifactTypeDataFrame: {Type: types.ArtifactTypeDataFrame, Data: frame}},
+	}}
+
+	var dataFile string
+	analysis := &stubExpertAgent{agentType: types.AgentTypeDataAnalysis, result: &types.TaskResult{Success: true}}
+
+	planner, _ := NewPlannerAgent(context.Background(), &types.AgentConfig{})
+	planner.RegisterExpertAgent(query)
+	planner.RegisterExpertAgent(analysis)
+
+	// 依赖只通过 data_source 声明，执行前应自动补全
+	plan := &types.ExecutionPlan{
+		ID: "p1",
+		Tasks: []*types.Task{
+			{ID: "task_2", AgentType: types.AgentTypeDataAnalysis, Input: map[string]interface{}{"data_source": "task_1", "analysis_type": "对比"}},
+			{ID: "task_1", AgentType: types.AgentTypeDataQuery, Input: map[string]interface{}{}},
+		},
+	}
+
+	results, err := planner.executePlan(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("执行计划失败: %v", err)
+	}
+	if !results["task_2"].Success {
+		t.Fatalf("task_2 执行失败: %s", results["task_2"].Error)
+	}
+
+	if len(analysis.inputs) != 1 {
+		t.Fatalf("分析智能体应执行1次，实际 %d 次", len(analysis.inputs))
+	}
+	input := analysis.inputs[0].(map[string]interface{})
+	ref, ok := input["data_source"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("data_source 未解析为上游产物: %#v", input["data_source"])
+	}
+	if ref["task_id"] != "task_1" || ref["type"] != types.ArtifactTypeDataFrame || ref["rows"] != 2 {
+		t.Errorf("数据引用不正确: %#v", ref)
+	}
+	dataFile, _ = ref["path"].(string)
+	if !strings.Contains(describeDataSource(ref), dataFile) {
+		t.Errorf("数据源描述未包含文件路径: %s", describeDataSource(ref))
+	}
+	if dir := analysis.artifactDirs[0]; dir == "" || filepath.Dir(dataFile) != dir {
+		t.Errorf("下游任务的上下文应带有本次执行的产物目录: %q", dir)
+	}
+
+	// 原始任务定义保持不变，执行结束后临时文件被清理
+	if plan.Tasks[0].Input.(map[string]interface{})["data_source"] != "task_1" {
+		t.Error("原始任务输入不应被修改")
+	}
+	if _, err := os.Stat(dataFile); !os.IsNotExist(err) {
+		t.Errorf("临时数据文件未清理: %s", dataFile)
+	}
+}
@@ -1,15 +1,23 @@
 package agents
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 	"github.com/google/uuid"
+	"smart-analysis/internal/prompts"
+	"smart-analysis/internal/scheduler"
 	"smart-analysis/internal/types"
 )
 
@@ -20,6 +28,8 @@ type PlannerAgent struct {
 	agentType    types.AgentType
 	expertAgents map[types.AgentType]types.ExpertAgent
 	agentMutex   sync.RWMutex
+	running      map[string]bool // 正在执行的计划ID
+	runningMutex sync.Mutex
 }
 
 // NewPlannerAgent 创建新的规划智能体
@@ -29,6 +39,7 @@ func NewPlannerAgent(ctx context.Context, config *types.AgentConfig) (*PlannerAg
 		config:       config,
 		agentType:    types.AgentTypePlanner,
 		expertAgents: make(map[types.AgentType]types.ExpertAgent),
+		running:      make(map[string]bool),
 	}, nil
 }
 
@@ -79,13 +90,8 @@ func (a *PlannerAgent) Generate(ctx context.Context, messages []*schema.Message,
 		}, nil
 	}
 
-	// 整合结果
-	finalResponse := a.consolidateResults(results)
-
-	return &schema.Message{
-		Role:    schema.Assistant,
-		Content: finalResponse,
-	}, nil
+	// 汇总结果
+	return a.summarize(ctx, messages, queryIntent, plan, results), nil
 }
 
 // Stream 流式生成响应
@@ -144,11 +150,11 @@ func (a *PlannerAgent) Stream(ctx context.Context, messages []*schema.Message, o
 		}
 
 		// 发送最终结果
-		finalResponse := a.consolidateResults(results)
 		sw.Send(&schema.Message{
 			Role:    schema.Assistant,
-			Content: finalResponse,
+			Content: "正在汇总分析结果...",
 		}, nil)
+		sw.Send(a.summarize(ctx, messages, queryIntent, plan, results), nil)
 	}()
 
 	return sr, nil
@@ -184,10 +190,30 @@ func (a *PlannerAgent) Shutdown(ctx context.Context) error {
 	return nil
 }
 
-// createExecutionPlan 创建执行计划
+// createExecutionPlan 创建执行计划，并关联上下文中的查询ID和用户
 func (a *PlannerAgent) createExecutionPlan(ctx context.Context, queryIntent *types.QueryIntent) (*types.ExecutionPlan, error) {
+	plan, err := a.generateExecutionPlan(ctx, queryIntent)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.QueryID = types.QueryIDFromContext(ctx)
+	plan.UserID, _ = strconv.Atoi(scheduler.UserFromContext(ctx))
+	return plan, nil
+}
+
+// generateExecutionPlan 使用LLM生成执行计划，失败时回退到默认计划
+func (a *PlannerAgent) generateExecutionPlan(ctx context.Context, queryIntent *types.QueryIntent) (*types.ExecutionPlan, error) {
 	// 构建任务规划的系统提示
-	systemPrompt := a.buildPlanningPrompt(queryIntent)
+	systemPrompt, err := a.buildPlanningPrompt(queryIntent)
+	if err != nil {
+		return nil, err
+	}
+
+	userPrompt, err := promptLibrary(a.config).Render(prompts.TemplatePlannerUser, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	// 使用LLM生成执行计划
 	planMessages := []*schema.Message{
@@ -197,140 +223,119 @@ func (a *PlannerAgent) createExecutionPlan(ctx context.Context, queryIntent *typ
 		},
 		{
 			Role:    schema.User,
-			Content: "请为这个查询意图创建详细的执行计划。",
+			Content: userPrompt,
 		},
 	}
 
-	response, err := a.chatModel.Generate(ctx, planMessages)
-	if err != nil {
-		return nil, fmt.Errorf("生成执行计划失败: %w", err)
+	// 生成并校验结构化的执行计划
+	var plan types.ExecutionPlan
+	if err := generateStructured(ctx, a.config, a.chatModel, planMessages, types.ExecutionPlanSchema(), &plan); err != nil {
+		var structuredErr *StructuredOutputError
+		if !errors.As(err, &structuredErr) {
+			return nil, fmt.Errorf("生成执行计划失败: %w", err)
+		}
+
+		// 多轮修复后仍不合法，创建一个简单的默认计划
+		return a.fallbackPlan(queryIntent, err), nil
 	}
 
-	// 解析LLM返回的执行计划
-	plan, err := a.parseExecutionPlan(response.Content, queryIntent)
-	if err != nil {
-		// 如果解析失败，创建一个简单的默认计划
-		return a.createDefaultPlan(queryIntent), nil
+	normalized := a.normalizeExecutionPlan(&plan, queryIntent)
+	if err := validateExecutionPlan(normalized, a.registeredAgentTypes()); err != nil {
+		// 计划结构合法但无法执行（循环依赖、未知智能体等），同样回退到默认计划
+		return a.fallbackPlan(queryIntent, err), nil
 	}
 
-	return plan, nil
+	return normalized, nil
 }
 
-// buildPlanningPrompt 构建任务规划提示
-func (a *PlannerAgent) buildPlanningPrompt(queryIntent *types.QueryIntent) string {
-	// 获取可用的专家智能体信息
-	a.agentMutex.RLock()
-	availableAgents := make([]string, 0, len(a.expertAgents))
-	for agentType, agent := range a.expertAgents {
-		capabilities := agent.GetCapabilities()
-		agentInfo := fmt.Sprintf("- %s: %s", string(agentType), strings.Join(capabilities, ", "))
-		availableAgents = append(availableAgents, agentInfo)
+// fallbackPlan 创建默认计划并记录回退原因
+func (a *PlannerAgent) fallbackPlan(queryIntent *types.QueryIntent, reason error) *types.ExecutionPlan {
+	plan := a.createDefaultPlan(queryIntent)
+	plan.Metadata = map[string]interface{}{
+		"plan_fallback_reason": reason.Error(),
 	}
-	a.agentMutex.RUnlock()
+	return plan
+}
 
-	intentJSON, _ := json.MarshalIndent(queryIntent, "", "  ")
-
-	return fmt.Sprintf(`你是一个专业的任务规划专家。请根据查询意图创建详细的执行计划。
-
-查询意图:
-%s
-
-可用的专家智能体:
-%s
-
-请创建一个执行计划，包含以下信息：
-1. 将复杂任务分解为多个可执行的子任务
-2. 确定任务之间的依赖关系
-3. 为每个任务分配合适的专家智能体
-4. 确定任务的执行顺序（串行或并行）
-
-返回JSON格式的执行计划，包含：
-- id: 计划ID
-- tasks: 任务列表，每个任务包含:
-  - id: 任务ID
-  - type: 任务类型
-  - description: 任务描述
-  - agent_type: 负责执行的智能体类型
-  - input: 任务输入
-  - dependencies: 依赖的任务ID列表
-- dependencies: 任务依赖关系映射
-
-示例:
-{
-  "id": "plan_xxx",
-  "tasks": [
-    {
-      "id": "task_1",
-      "type": "data_query",
-      "description": "查询基础数据",
-      "agent_type": "data_query",
-      "input": {"query": "..."},
-      "dependencies": []
-    },
-    {
-      "id": "task_2", 
-      "type": "analysis",
-      "description": "数据分析",
-      "agent_type": "data_analysis",
-      "input": {"data_source": "task_1"},
-      "dependencies": ["task_1"]
-    }
-  ],
-  "dependencies": {
-    "task_2": ["task_1"]
-  }
-}`, intentJSON, strings.Join(availableAgents, "\n"))
-}
-
-// parseExecutionPlan 解析执行计划
-func (a *PlannerAgent) parseExecutionPlan(content string, queryIntent *types.QueryIntent) (*types.ExecutionPlan, error) {
-	// 提取JSON内容
-	jsonStr := a.extractJSON(content)
-	if jsonStr == "" {
-		return nil, fmt.Errorf("未找到有效的JSON内容")
-	}
-
-	var planData struct {
-		ID           string                   `json:"id"`
-		Tasks        []map[string]interface{} `json:"tasks"`
-		Dependencies map[string][]string      `json:"dependencies"`
-	}
-
-	err := json.Unmarshal([]byte(jsonStr), &planData)
-	if err != nil {
-		return nil, fmt.Errorf("JSON解析失败: %w", err)
-	}
+// registeredAgentTypes 返回已注册的专家智能体类型
+func (a *PlannerAgent) registeredAgentTypes() map[types.AgentType]bool {
+	a.agentMutex.RLock()
+	defer a.agentMutex.RUnlock()
 
-	// 构建执行计划
-	plan := &types.ExecutionPlan{
-		ID:           planData.ID,
-		QueryIntent:  queryIntent,
-		Tasks:        make([]*types.Task, 0, len(planData.Tasks)),
-		Dependencies: planData.Dependencies,
+	agentTypes := make(map[types.AgentType]bool, len(a.expertAgents))
+	for agentType := range a.expertAgents {
+		agentTypes[agentType] = true
 	}
+	return agentTypes
+}
 
-	// 转换任务
-	for _, taskData := range planData.Tasks {
-		task := &types.Task{
-			ID:          taskData["id"].(string),
-			Type:        taskData["type"].(string),
-			Description: taskData["description"].(string),
-			AgentType:   types.AgentType(taskData["agent_type"].(string)),
-			Input:       taskData["input"],
-			Status:      types.TaskStatusPending,
-		}
+// prepareExecutionPlan 合并依赖关系并在执行前校验计划
+func (a *PlannerAgent) prepareExecutionPlan(plan *types.ExecutionPlan) error {
+	mergePlanDependencies(plan)
+	return validateExecutionPlan(plan, a.registeredAgentTypes())
+}
+
+// plannerAgentInfo 规划提示中的专家智能体信息
+type plannerAgentInfo struct {
+	Type         types.AgentType
+	Name         string
+	Description  string
+	Capabilities []string
+	TaskTypes    []string
+	InputSchema  string
+}
 
-		if deps, ok := taskData["dependencies"].([]interface{}); ok {
-			task.Dependencies = make([]string, len(deps))
-			for i, dep := range deps {
-				task.Dependencies[i] = dep.(string)
+// buildPlanningPrompt 构建任务规划提示
+func (a *PlannerAgent) buildPlanningPrompt(queryIntent *types.QueryIntent) (string, error) {
+	// 获取可用的专家智能体信息，已在注册表中声明的专家附带说明和输入Schema
+	a.agentMutex.RLock()
+	availableAgents := make([]plannerAgentInfo, 0, len(a.expertAgents))
+	for agentType, agent := range a.expertAgents {
+		info := plannerAgentInfo{
+			Type:         agentType,
+			Capabilities: agent.GetCapabilities(),
+		}
+		if def, ok := LookupExpert(agentType); ok {
+			info.Name = def.Name
+			info.Description = def.Description
+			info.TaskTypes = def.TaskTypes
+			if def.InputSchema != nil {
+				var compact bytes.Buffer
+				if err := json.Compact(&compact, []byte(def.InputSchema.String())); err == nil {
+					info.InputSchema = compact.String()
+				}
 			}
 		}
+		availableAgents = append(availableAgents, info)
+	}
+	a.agentMutex.RUnlock()
+
+	// 保证提示内容稳定，便于比较不同版本的提示效果
+	sort.Slice(availableAgents, func(i, j int) bool {
+		return availableAgents[i].Type < availableAgents[j].Type
+	})
 
-		plan.Tasks = append(plan.Tasks, task)
+	return promptLibrary(a.config).Render(prompts.TemplatePlannerSystem, map[string]interface{}{
+		"QueryIntent": queryIntent,
+		"Agents":      availableAgents,
+	})
+}
+
+// normalizeExecutionPlan 规范化LLM生成的执行计划，重置由执行过程维护的字段。
+// 计划ID总是重新生成，LLM返回的ID可能重复，会覆盖已保存的其他计划
+func (a *PlannerAgent) normalizeExecutionPlan(plan *types.ExecutionPlan, queryIntent *types.QueryIntent) *types.ExecutionPlan {
+	plan.ID = uuid.New().String()
+	plan.QueryIntent = queryIntent
+	for _, task := range plan.Tasks {
+		if task == nil {
+			continue
+		}
+		task.Status = types.TaskStatusPending
+		task.Result = nil
 	}
+	mergePlanDependencies(plan)
 
-	return plan, nil
+	return plan
 }
 
 // createDefaultPlan 创建默认执行计划
@@ -389,87 +394,477 @@ func (a *PlannerAgent) createDefaultPlan(queryIntent *types.QueryIntent) *types.
 		}
 	}
 
-	return &types.ExecutionPlan{
+	plan := &types.ExecutionPlan{
 		ID:           planID,
 		QueryIntent:  queryIntent,
 		Tasks:        tasks,
 		Dependencies: map[string][]string{},
 	}
+	mergePlanDependencies(plan)
+
+	return plan
+}
+
+// 默认执行策略
+const (
+	defaultTaskMaxRetries   = 1
+	defaultTaskRetryBackoff = 500 * time.Millisecond
+	defaultMaxReplanRounds  = 1
+)
+
+// executionPolicy 返回生效的执行策略：计划中的失败策略优先于配置
+func (a *PlannerAgent) executionPolicy(plan *types.ExecutionPlan) types.ExecutionPolicy {
+	policy := types.ExecutionPolicy{
+		MaxRetries:      defaultTaskMaxRetries,
+		RetryBackoff:    defaultTaskRetryBackoff,
+		FailurePolicy:   types.FailurePolicySkipDependents,
+		MaxReplanRounds: defaultMaxReplanRounds,
+	}
+	if a.config != nil && a.config.Execution != nil {
+		policy = *a.config.Execution
+	}
+	if plan.FailurePolicy != "" {
+		policy.FailurePolicy = plan.FailurePolicy
+	}
+	if policy.FailurePolicy == "" {
+		policy.FailurePolicy = types.FailurePolicySkipDependents
+	}
+	return policy
 }
 
 // executePlan 执行执行计划
 func (a *PlannerAgent) executePlan(ctx context.Context, plan *types.ExecutionPlan) (map[string]*types.TaskResult, error) {
-	results := make(map[string]*types.TaskResult)
-	completedTasks := make(map[string]bool)
+	finish, err := a.startRun(plan.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer finish()
+	return a.runPlan(ctx, plan, nil, func(string) {})
+}
 
-	// 执行任务直到所有任务完成
-	for len(completedTasks) < len(plan.Tasks) {
-		// 找到可以执行的任务（依赖已完成）
-		readyTasks := a.findReadyTasks(plan.Tasks, completedTasks)
+// executeStreamPlan 流式执行执行计划
+func (a *PlannerAgent) executeStreamPlan(ctx context.Context, plan *types.ExecutionPlan, sw *schema.StreamWriter[*schema.Message]) (map[string]*types.TaskResult, error) {
+	finish, err := a.startRun(plan.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer finish()
+	return a.runPlan(ctx, plan, nil, func(content string) {
+		sw.Send(&schema.Message{
+			Role:    schema.Assistant,
+			Content: content,
+		}, nil)
+	})
+}
+
+// runPlan 按依赖顺序执行计划，notify 用于报告执行进度。
+// 已成功完成的任务直接复用结果；scope 不为空时只执行其中的任务
+func (a *PlannerAgent) runPlan(ctx context.Context, plan *types.ExecutionPlan, scope map[string]bool, notify func(string)) (map[string]*types.TaskResult, error) {
+	if err := a.prepareExecutionPlan(plan); err != nil {
+		return nil, err
+	}
 
+	policy := a.executionPolicy(plan)
+	run := newPlanRun(a.config.Datasets)
+	defer run.cleanup()
+
+	results, finished := restoreProgress(plan, scope)
+	replanRounds := 0
+
+	a.savePlan(plan)
+	defer a.savePlan(plan)
+
+	// 执行任务直到所有任务结束
+	for len(finished) < len(plan.Tasks) {
+		if err := ctx.Err(); err != nil {
+			a.cancelPending(plan, finished)
+			return nil, err
+		}
+
+		// 找到可以执行的任务（依赖已结束）
+		readyTasks := a.findReadyTasks(plan.Tasks, finished)
 		if len(readyTasks) == 0 {
 			return nil, fmt.Errorf("没有可执行的任务，可能存在循环依赖")
 		}
 
+		var runnable []*types.Task
+		for _, task := range readyTasks {
+			if depID := failedDependency(task, results); depID != "" && policy.FailurePolicy != types.FailurePolicyContinue {
+				task.Status = types.TaskStatusSkipped
+				task.Result = &types.TaskResult{
+					Success:    false,
+					Error:      fmt.Sprintf("上游任务 %s 未成功完成，已跳过", depID),
+					ExecutedBy: task.AgentType,
+				}
+				results[task.ID] = task.Result
+				finished[task.ID] = true
+				notify(fmt.Sprintf("任务 %s 已跳过: 上游任务 %s 未成功完成", task.ID, depID))
+				continue
+			}
+
+			task.Status = types.TaskStatusRunning
+			runnable = append(runnable, task)
+			notify(fmt.Sprintf("开始执行任务: %s - %s", task.ID, task.Description))
+		}
+
+		if len(runnable) == 0 {
+			continue
+		}
+		a.savePlan(plan)
+
 		// 并行执行就绪的任务
-		taskResults, err := a.executeTasksBatch(ctx, readyTasks, results)
+		taskResults, err := a.executeTasksBatch(ctx, run, runnable, results, policy, notify)
 		if err != nil {
 			return nil, err
 		}
 
-		// 更新结果和完成状态
-		for taskID, result := range taskResults {
-			results[taskID] = result
-			completedTasks[taskID] = true
+		// 按计划顺序处理结果，保证重新规划的顺序稳定
+		for _, task := range runnable {
+			result := taskResults[task.ID]
+			results[task.ID] = result
+			finished[task.ID] = true
+
+			if result.Success {
+				notify(fmt.Sprintf("任务 %s 执行成功", task.ID))
+				continue
+			}
+			notify(fmt.Sprintf("任务 %s 执行失败: %s", task.ID, result.Error))
+
+			if replanRounds < policy.MaxReplanRounds {
+				replanRounds++
+				added, err := a.replan(ctx, plan, task, results, finished)
+				if err == nil {
+					notify(fmt.Sprintf("第 %d 轮重新规划: 使用 %d 个新任务替代失败的任务 %s", replanRounds, added, task.ID))
+					continue
+				}
+				notify(fmt.Sprintf("第 %d 轮重新规划失败: %v", replanRounds, err))
+			}
+
+			if policy.FailurePolicy == types.FailurePolicyFailFast {
+				a.cancelPending(plan, finished)
+				return nil, fmt.Errorf("任务 %s 执行失败: %s", task.ID, result.Error)
+			}
 		}
+		a.savePlan(plan)
 	}
 
 	return results, nil
 }
 
-// executeStreamPlan 流式执行执行计划
-func (a *PlannerAgent) executeStreamPlan(ctx context.Context, plan *types.ExecutionPlan, sw *schema.StreamWriter[*schema.Message]) (map[string]*types.TaskResult, error) {
+// restoreProgress 根据任务状态恢复执行进度：成功的任务和已被重新规划替代的任务视为已结束，
+// 其余任务重置为待执行；scope 之外的任务保持原状态且不会执行
+func restoreProgress(plan *types.ExecutionPlan, scope map[string]bool) (map[string]*types.TaskResult, map[string]bool) {
 	results := make(map[string]*types.TaskResult)
-	completedTasks := make(map[string]bool)
+	finished := make(map[string]bool)
+
+	for _, task := range plan.Tasks {
+		switch {
+		case task.Status == types.TaskStatusCompleted && task.Result != nil && task.Result.Success:
+			results[task.ID] = task.Result
+			finished[task.ID] = true
+		case task.IsReplaced():
+			if task.Result != nil {
+				results[task.ID] = task.Result
+			}
+			finished[task.ID] = true
+		case scope != nil && !scope[task.ID]:
+			if task.Result != nil {
+				results[task.ID] = task.Result
+			}
+			finished[task.ID] = true
+		default:
+			task.Status = types.TaskStatusPending
+			task.Result = nil
+		}
+	}
 
-	for len(completedTasks) < len(plan.Tasks) {
-		readyTasks := a.findReadyTasks(plan.Tasks, completedTasks)
+	return results, finished
+}
 
-		if len(readyTasks) == 0 {
-			return nil, fmt.Errorf("没有可执行的任务，可能存在循环依赖")
+// savePlan 持久化计划快照，存储失败不影响本次执行
+func (a *PlannerAgent) savePlan(plan *types.ExecutionPlan) {
+	if a.config == nil || a.config.PlanStore == nil {
+		return
+	}
+	if err := a.config.PlanStore.SavePlan(plan); err != nil {
+		log.Printf("保存执行计划 %s 失败: %v", plan.ID, err)
+	}
+}
+
+// loadPlan 从存储中读取计划
+func (a *PlannerAgent) loadPlan(planID string) (*types.ExecutionPlan, error) {
+	if a.config == nil || a.config.PlanStore == nil {
+		return nil, fmt.Errorf("未配置执行计划存储")
+	}
+	return a.config.PlanStore.GetPlan(planID)
+}
+
+// startRun 标记计划开始执行，返回结束标记的函数。计划已在执行时返回 types.ErrPlanRunning，
+// 避免两次执行基于同一份快照各自保存，互相覆盖任务结果
+func (a *PlannerAgent) startRun(planID string) (func(), error) {
+	a.runningMutex.Lock()
+	defer a.runningMutex.Unlock()
+	if a.running[planID] {
+		return nil, types.ErrPlanRunning
+	}
+	a.running[planID] = true
+	return func() {
+		a.runningMutex.Lock()
+		defer a.runningMutex.Unlock()
+		delete(a.running, planID)
+	}, nil
+}
+
+// ResumePlan 恢复执行中断或部分失败的计划，已成功的任务不会重复执行。计划正在执行时返回 types.ErrPlanRunning
+func (a *PlannerAgent) ResumePlan(ctx context.Context, planID string) (*types.ExecutionPlan, error) {
+	finish, err := a.startRun(planID)
+	if err != nil {
+		return nil, err
+	}
+	defer finish()
+
+	plan, err := a.loadPlan(planID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := a.runPlan(ctx, plan, nil, func(string) {}); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
+
+// RerunTask 使用新的输入（为空时沿用原输入）重新执行单个任务及其下游任务，上游任务的结果保持不变。
+// 计划正在执行时返回 types.ErrPlanRunning
+func (a *PlannerAgent) RerunTask(ctx context.Context, planID, taskID string, input interface{}) (*types.ExecutionPlan, error) {
+	finish, err := a.startRun(planID)
+	if err != nil {
+		return nil, err
+	}
+	defer finish()
+
+	plan, err := a.loadPlan(planID)
+	if err != nil {
+		return nil, err
+	}
+
+	var target *types.Task
+	for _, task := range plan.Tasks {
+		if task.ID == taskID {
+			target = task
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("任务 %s 不存在", taskID)
+	}
+	if target.IsReplaced() {
+		return nil, fmt.Errorf("任务 %s 已被重新规划替代", taskID)
+	}
+
+	if input != nil {
+		target.Input = input
+		mergePlanDependencies(plan)
+	}
+
+	// 上游任务必须已经成功完成
+	for _, depID := range target.Dependencies {
+		for _, task := range plan.Tasks {
+			if task.ID == depID && (task.Status != types.TaskStatusCompleted || task.Result == nil || !task.Result.Success) {
+				return nil, fmt.Errorf("上游任务 %s 尚未成功完成，请先恢复执行计划", depID)
+			}
 		}
+	}
 
-		// 发送任务开始消息
-		for _, task := range readyTasks {
-			sw.Send(&schema.Message{
-				Role:    schema.Assistant,
-				Content: fmt.Sprintf("开始执行任务: %s - %s", task.ID, task.Description),
-			}, nil)
+	// 重置目标任务及其全部下游任务
+	scope := map[string]bool{taskID: true}
+	target.Status = types.TaskStatusPending
+	target.Result = nil
+	for _, task := range pendingDependents(plan.Tasks, taskID, nil) {
+		if task.IsReplaced() {
+			continue
 		}
+		scope[task.ID] = true
+		task.Status = types.TaskStatusPending
+		task.Result = nil
+	}
 
-		// 执行任务
-		taskResults, err := a.executeTasksBatch(ctx, readyTasks, results)
-		if err != nil {
-			return nil, err
+	if _, err := a.runPlan(ctx, plan, scope, func(string) {}); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
+
+// failedDependency 返回第一个未成功完成的依赖任务ID
+func failedDependency(task *types.Task, results map[string]*types.TaskResult) string {
+	for _, depID := range task.Dependencies {
+		if result, ok := results[depID]; !ok || !result.Success {
+			return depID
+		}
+	}
+	return ""
+}
+
+// cancelPending 将尚未结束的任务标记为已取消
+func (a *PlannerAgent) cancelPending(plan *types.ExecutionPlan, finished map[string]bool) {
+	for _, task := range plan.Tasks {
+		if !finished[task.ID] {
+			task.Status = types.TaskStatusCancelled
+		}
+	}
+}
+
+// replanTaskInfo 重新规划提示中的任务信息
+type replanTaskInfo struct {
+	ID          string
+	AgentType   types.AgentType
+	Description string
+}
+
+// replan 将失败信息交给LLM生成修正后的子计划，替代失败任务及其尚未执行的下游任务，返回新增任务数
+func (a *PlannerAgent) replan(ctx context.Context, plan *types.ExecutionPlan, failed *types.Task, results map[string]*types.TaskResult, finished map[string]bool) (int, error) {
+	replaced := pendingDependents(plan.Tasks, failed.ID, finished)
+
+	var completed []replanTaskInfo
+	replacedInfo := []replanTaskInfo{{ID: failed.ID, AgentType: failed.AgentType, Description: failed.Description}}
+	for _, task := range plan.Tasks {
+		if result, ok := results[task.ID]; ok && result.Success {
+			completed = append(completed, replanTaskInfo{ID: task.ID, AgentType: task.AgentType, Description: task.Description})
+		}
+	}
+	for _, task := range replaced {
+		replacedInfo = append(replacedInfo, replanTaskInfo{ID: task.ID, AgentType: task.AgentType, Description: task.Description})
+	}
+
+	systemPrompt, err := a.buildPlanningPrompt(plan.QueryIntent)
+	if err != nil {
+		return 0, err
+	}
+	userPrompt, err := promptLibrary(a.config).Render(prompts.TemplatePlannerReplan, map[string]interface{}{
+		"FailedTask": failed,
+		"Error":      results[failed.ID].Error,
+		"Completed":  completed,
+		"Replaced":   replacedInfo,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var subPlan types.ExecutionPlan
+	err = generateStructured(ctx, a.config, a.chatModel, []*schema.Message{
+		{Role: schema.System, Content: systemPrompt},
+		{Role: schema.User, Content: userPrompt},
+	}, types.ExecutionPlanSchema(), &subPlan)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := a.mergeSubPlan(plan, &subPlan, results); err != nil {
+		return 0, err
+	}
+
+	// 被替代的任务不再执行
+	newIDs := make([]string, 0, len(subPlan.Tasks))
+	for _, task := range subPlan.Tasks {
+		newIDs = append(newIDs, task.ID)
+	}
+	for _, task := range replaced {
+		task.Status = types.TaskStatusCancelled
+		if task.Metadata == nil {
+			task.Metadata = make(map[string]interface{})
 		}
+		task.Metadata["cancel_reason"] = types.CancelReasonReplanned
+		finished[task.ID] = true
+	}
 
-		// 发送任务完成消息
-		for taskID, result := range taskResults {
-			status := "成功"
-			if !result.Success {
-				status = "失败: " + result.Error
+	failedResult := *results[failed.ID]
+	failedResult.Metadata = copyMetadata(failedResult.Metadata)
+	failedResult.Metadata["replaced_by"] = newIDs
+	results[failed.ID] = &failedResult
+	failed.Result = &failedResult
+
+	if plan.Metadata == nil {
+		plan.Metadata = make(map[string]interface{})
+	}
+	plan.Metadata["replanned_tasks"] = append(stringList(plan.Metadata["replanned_tasks"]), failed.ID)
+
+	return len(subPlan.Tasks), nil
+}
+
+// mergeSubPlan 校验子计划并追加到计划中；子计划只能依赖已成功的任务或自身的任务
+func (a *PlannerAgent) mergeSubPlan(plan *types.ExecutionPlan, subPlan *types.ExecutionPlan, results map[string]*types.TaskResult) error {
+	newTasks := make(map[string]bool, len(subPlan.Tasks))
+	for _, task := range subPlan.Tasks {
+		newTasks[task.ID] = true
+	}
+	for taskID := range subPlan.Dependencies {
+		if !newTasks[taskID] {
+			return fmt.Errorf("子计划不能修改已有任务 %s 的依赖", taskID)
+		}
+	}
+
+	taskIDs := make(map[string]bool, len(plan.Tasks)+len(subPlan.Tasks))
+	for _, task := range plan.Tasks {
+		taskIDs[task.ID] = true
+	}
+	for id := range newTasks {
+		taskIDs[id] = true
+	}
+
+	candidate := &types.ExecutionPlan{
+		ID:           plan.ID,
+		Tasks:        append(append([]*types.Task{}, plan.Tasks...), subPlan.Tasks...),
+		Dependencies: make(map[string][]string, len(plan.Dependencies)+len(subPlan.Dependencies)),
+	}
+	for taskID, deps := range plan.Dependencies {
+		candidate.Dependencies[taskID] = deps
+	}
+	for _, task := range subPlan.Tasks {
+		task.Status = types.TaskStatusPending
+		task.Result = nil
+		mergeTaskDependencies(task, subPlan.Dependencies[task.ID], taskIDs, candidate.Dependencies)
+	}
+
+	if err := validateExecutionPlan(candidate, a.registeredAgentTypes()); err != nil {
+		return err
+	}
+	for _, task := range subPlan.Tasks {
+		for _, depID := range task.Dependencies {
+			if result, ok := results[depID]; !newTasks[depID] && (!ok || !result.Success) {
+				return fmt.Errorf("子计划任务 %s 依赖了未成功完成的任务 %s", task.ID, depID)
 			}
-			sw.Send(&schema.Message{
-				Role:    schema.Assistant,
-				Content: fmt.Sprintf("任务 %s 执行%s", taskID, status),
-			}, nil)
+		}
+	}
 
-			results[taskID] = result
-			completedTasks[taskID] = true
+	plan.Tasks = candidate.Tasks
+	plan.Dependencies = candidate.Dependencies
+	return nil
+}
+
+// pendingDependents 返回依赖指定任务（直接或间接）且尚未结束的任务
+func pendingDependents(tasks []*types.Task, taskID string, finished map[string]bool) []*types.Task {
+	affected := map[string]bool{taskID: true}
+	var dependents []*types.Task
+
+	// 计划已通过校验不存在环，反复扫描直到没有新的下游任务
+	for changed := true; changed; {
+		changed = false
+		for _, task := range tasks {
+			if affected[task.ID] || finished[task.ID] {
+				continue
+			}
+			for _, depID := range task.Dependencies {
+				if affected[depID] {
+					affected[task.ID] = true
+					dependents = append(dependents, task)
+					changed = true
+					break
+				}
+			}
 		}
 	}
 
-	return results, nil
+	return dependents
 }
 
 // findReadyTasks 找到可以执行的任务
@@ -499,7 +894,7 @@ func (a *PlannerAgent) findReadyTasks(tasks []*types.Task, completed map[string]
 }
 
 // executeTasksBatch 批量执行任务
-func (a *PlannerAgent) executeTasksBatch(ctx context.Context, tasks []*types.Task, previousResults map[string]*types.TaskResult) (map[string]*types.TaskResult, error) {
+func (a *PlannerAgent) executeTasksBatch(ctx context.Context, run *planRun, tasks []*types.Task, previousResults map[string]*types.TaskResult, policy types.ExecutionPolicy, notify func(string)) (map[string]*types.TaskResult, error) {
 	results := make(map[string]*types.TaskResult)
 
 	// 使用goroutine并行执行任务
@@ -511,7 +906,7 @@ func (a *PlannerAgent) executeTasksBatch(ctx context.Context, tasks []*types.Tas
 		go func(t *types.Task) {
 			defer wg.Done()
 
-			result := a.executeTask(ctx, t, previousResults)
+			result := a.executeTaskWithRetry(ctx, run, t, previousResults, policy, notify)
 
 			mutex.Lock()
 			results[t.ID] = result
@@ -523,8 +918,56 @@ func (a *PlannerAgent) executeTasksBatch(ctx context.Context, tasks []*types.Tas
 	return results, nil
 }
 
-// executeTask 执行单个任务
-func (a *PlannerAgent) executeTask(ctx context.Context, task *types.Task, previousResults map[string]*types.TaskResult) *types.TaskResult {
+// executeTaskWithRetry 执行任务，失败时按指数退避重试
+func (a *PlannerAgent) executeTaskWithRetry(ctx context.Context, run *planRun, task *types.Task, previousResults map[string]*types.TaskResult, policy types.ExecutionPolicy, notify func(string)) *types.TaskResult {
+	maxRetries := policy.MaxRetries
+	if task.MaxRetries != nil {
+		maxRetries = *task.MaxRetries
+	}
+	backoff := policy.RetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		// 每次尝试前获取执行槽位，重试等待期间不占用槽位
+		release, err := taskScheduler(a.config).Acquire(ctx, scheduler.ResourceWorker)
+		if err != nil {
+			task.Status = types.TaskStatusCancelled
+			return &types.TaskResult{
+				Success:    false,
+				Error:      fmt.Sprintf("任务已取消: %v", err),
+				ExecutedBy: task.AgentType,
+				Metadata:   map[string]interface{}{"attempts": attempt - 1},
+			}
+		}
+		result, retryable := a.executeTask(ctx, run, task, previousResults)
+		release()
+
+		if result.Success || !retryable || attempt > maxRetries {
+			attempted := *result
+			attempted.Metadata = copyMetadata(result.Metadata)
+			attempted.Metadata["attempts"] = attempt
+			task.Result = &attempted
+			return &attempted
+		}
+
+		notify(fmt.Sprintf("任务 %s 第 %d 次执行失败，%v 后重试: %s", task.ID, attempt, backoff, result.Error))
+
+		select {
+		case <-ctx.Done():
+			task.Status = types.TaskStatusCancelled
+			return &types.TaskResult{
+				Success:    false,
+				Error:      fmt.Sprintf("任务已取消: %v", ctx.Err()),
+				ExecutedBy: task.AgentType,
+				Metadata:   map[string]interface{}{"attempts": attempt},
+			}
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// executeTask 执行单个任务，返回结果以及失败时是否值得重试
+func (a *PlannerAgent) executeTask(ctx context.Context, run *planRun, task *types.Task, previousResults map[string]*types.TaskResult) (*types.TaskResult, bool) {
 	task.Status = types.TaskStatusRunning
 
 	// 获取对应的专家智能体
@@ -533,101 +976,129 @@ func (a *PlannerAgent) executeTask(ctx context.Context, task *types.Task, previo
 	a.agentMutex.RUnlock()
 
 	if !exists {
+		task.Status = types.TaskStatusFailed
 		return &types.TaskResult{
 			Success:    false,
 			Error:      fmt.Sprintf("未找到类型为 %s 的专家智能体", task.AgentType),
 			ExecutedBy: task.AgentType,
-		}
+		}, false
 	}
 
 	// 检查智能体是否能处理此任务
 	if !agent.CanHandle(task) {
+		task.Status = types.TaskStatusFailed
 		return &types.TaskResult{
 			Success:    false,
 			Error:      fmt.Sprintf("智能体 %s 无法处理此任务", task.AgentType),
 			ExecutedBy: task.AgentType,
+		}, false
+	}
+
+	// 按专家声明的输入Schema校验任务输入
+	if def, ok := LookupExpert(task.AgentType); ok {
+		if err := def.ValidateInput(task.Input); err != nil {
+			task.Status = types.TaskStatusFailed
+			return &types.TaskResult{
+				Success:    false,
+				Error:      fmt.Sprintf("任务输入不合法: %v", err),
+				ExecutedBy: task.AgentType,
+			}, false
 		}
 	}
 
-	// 执行任务
-	result, err := agent.ExecuteTask(ctx, task)
+	// 将 data_source 引用替换为上游任务的产物，原任务定义保持不变
+	input, err := run.resolveTaskInput(ctx, task, previousResults)
+	if err != nil {
+		task.Status = types.TaskStatusFailed
+		return &types.TaskResult{
+			Success:    false,
+			Error:      fmt.Sprintf("解析任务输入失败: %v", err),
+			ExecutedBy: task.AgentType,
+		}, false
+	}
+	resolvedTask := *task
+	resolvedTask.Input = input
+
+	// 执行任务，任务和工具只能读取本次执行写出的上游产物
+	result, err := agent.ExecuteTask(types.WithArtifactDir(ctx, run.artifactDir()), &resolvedTask)
 	if err != nil {
 		task.Status = types.TaskStatusFailed
 		return &types.TaskResult{
 			Success:    false,
 			Error:      err.Error(),
 			ExecutedBy: task.AgentType,
-		}
+		}, true
 	}
 
-	task.Status = types.TaskStatusCompleted
-	task.Result = result
+	if !result.Success {
+		task.Status = types.TaskStatusFailed
+		return result, true
+	}
 
-	return result
+	task.Status = types.TaskStatusCompleted
+	return result, true
 }
 
-// consolidateResults 整合结果
-func (a *PlannerAgent) consolidateResults(results map[string]*types.TaskResult) string {
-	var response strings.Builder
-	response.WriteString("任务执行完成！\n\n")
-
-	successCount := 0
-	for taskID, result := range results {
-		if result.Success {
-			successCount++
-			response.WriteString(fmt.Sprintf("✅ 任务 %s 执行成功\n", taskID))
-			if result.Output != nil {
-				if outputStr, ok := result.Output.(string); ok {
-					response.WriteString(fmt.Sprintf("   结果: %s\n", outputStr))
-				}
+// stringList 读取元数据中的字符串列表，兼容从JSON恢复后的 []interface{}
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
 			}
-		} else {
-			response.WriteString(fmt.Sprintf("❌ 任务 %s 执行失败: %s\n", taskID, result.Error))
 		}
+		return list
+	default:
+		return nil
 	}
+}
 
-	response.WriteString(fmt.Sprintf("\n总计: %d/%d 任务成功完成", successCount, len(results)))
-
-	return response.String()
+// copyMetadata 复制元数据，避免修改专家智能体返回的原始结果
+func copyMetadata(metadata map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(metadata)+1)
+	for key, value := range metadata {
+		copied[key] = value
+	}
+	return copied
 }
 
-// extractJSON 从文本中提取JSON内容
-func (a *PlannerAgent) extractJSON(content string) string {
-	// 查找JSON代码块
-	start := strings.Index(content, "```json")
-	if start != -1 {
-		start += len("```json")
-		end := strings.Index(content[start:], "```")
-		if end != -1 {
-			return strings.TrimSpace(content[start : start+end])
+// consolidateResults 按计划顺序整合任务结果，作为LLM汇总不可用时的回答
+func (a *PlannerAgent) consolidateResults(plan *types.ExecutionPlan, results map[string]*types.TaskResult) string {
+	var response strings.Builder
+	response.WriteString("任务执行完成！\n\n")
+
+	successCount, replacedCount, total := 0, 0, 0
+	for _, task := range plan.Tasks {
+		result, ok := results[task.ID]
+		if !ok {
+			continue
 		}
-	}
+		total++
 
-	// 查找普通代码块
-	start = strings.Index(content, "```")
-	if start != -1 {
-		start += 3
-		end := strings.Index(content[start:], "```")
-		if end != -1 {
-			return strings.TrimSpace(content[start : start+end])
+		name := task.Description
+		if name == "" {
+			name = task.ID
 		}
-	}
 
-	// 尝试查找JSON对象
-	start = strings.Index(content, "{")
-	if start != -1 {
-		braceCount := 0
-		for i := start; i < len(content); i++ {
-			if content[i] == '{' {
-				braceCount++
-			} else if content[i] == '}' {
-				braceCount--
-				if braceCount == 0 {
-					return strings.TrimSpace(content[start : i+1])
-				}
+		if result.Success {
+			successCount++
+			response.WriteString(fmt.Sprintf("✅ %s\n", name))
+			if outputStr := outputText(result.Output); outputStr != "" {
+				response.WriteString(fmt.Sprintf("   结果: %s\n", outputStr))
 			}
+		} else if replacedBy := stringList(result.Metadata["replaced_by"]); len(replacedBy) > 0 {
+			replacedCount++
+			response.WriteString(fmt.Sprintf("🔁 %s 执行失败，已重新规划: %s\n", name, result.Error))
+		} else {
+			response.WriteString(fmt.Sprintf("❌ %s 执行失败: %s\n", name, result.Error))
 		}
 	}
 
-	return ""
+	response.WriteString(fmt.Sprintf("\n总计: %d/%d 任务成功完成", successCount, total-replacedCount))
+
+	return response.String()
 }
@@ -0,0 +1,362 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/planstore"
+	"smart-analysis/internal/scheduler"
+	"smart-analysis/internal/types"
+)
+
+// newTestPlanner 创建注册了查询和分析两个专家的规划智能体
+func newTestPlanner(policy *types.ExecutionPolicy, chatModel *scriptedChatModel, query, analysis *stubExpertAgent) *PlannerAgent {
+	config := &types.AgentConfig{Execution: policy}
+	if chatModel != nil {
+		config.ChatModel = chatModel
+	}
+	planner, _ := NewPlannerAgent(context.Background(), config)
+	planner.RegisterExpertAgent(query)
+	planner.RegisterExpertAgent(analysis)
+	return planner
+}
+
+// twoStepPlan 查询后分析的两步计划
+func twoStepPlan(policy types.FailurePolicy) *types.ExecutionPlan {
+	return &types.ExecutionPlan{
+		ID:            "p1",
+		FailurePolicy: policy,
+		Tasks: []*types.Task{
+			{ID: "task_1", AgentType: types.AgentTypeDataQuery, Description: "查询"},
+			{ID: "task_2", AgentType: types.AgentTypeDataAnalysis, Description: "分析", Dependencies: []string{"task_1"}},
+		},
+	}
+}
+
+func TestPlannerAgent_RetryWithBackoff(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	query := &stubExpertAgent{agentType: types.AgentTypeDataQuery, execute: func(task *types.Task) *types.TaskResult {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return &types.TaskResult{Success: calls >= 3, Error: "沙盒超时"}
+	}}
+	analysis := &stubExpertAgent{agentType: types.AgentTypeDataAnalysis, result: &types.TaskResult{Success: true}}
+
+	planner := newTestPlanner(&types.ExecutionPolicy{MaxRetries: 2, RetryBackoff: time.Millisecond}, nil, query, analysis)
+	results, err := planner.executePlan(context.Background(), twoStepPlan(""))
+	if err != nil {
+		t.Fatalf("执行计划失败: %v", err)
+	}
+
+	if !results["task_1"].Success || results["task_1"].Metadata["attempts"] != 3 {
+		t.Errorf("期望第3次尝试成功: %+v", results["task_1"])
+	}
+	if !results["task_2"].Success {
+		t.Error("下游任务应正常执行")
+	}
+}
+
+func TestPlannerAgent_FailurePolicies(t *testing.T) {
+	failing := func() *stubExpertAgent {
+		return &stubExpertAgent{agentType: types.AgentTypeDataQuery, result: &types.TaskResult{Success: false, Error: "数据源不可用"}}
+	}
+
+	t.Run("skip_dependents", func(t *testing.T) {
+		analysis := &stubExpertAgent{agentType: types.AgentTypeDataAnalysis, result: &types.TaskResult{Success: true}}
+		planner := newTestPlanner(&types.ExecutionPolicy{}, nil, failing(), analysis)
+
+		plan := twoStepPlan(types.FailurePolicySkipDependents)
+		results, err := planner.executePlan(context.Background(), plan)
+		if err != nil {
+			t.Fatalf("执行计划失败: %v", err)
+		}
+		if len(analysis.inputs) != 0 || plan.Tasks[1].Status != types.TaskStatusSkipped {
+			t.Error("下游任务应被跳过")
+		}
+		if !strings.Contains(results["task_2"].Error, "task_1") {
+			t.Errorf("跳过原因不正确: %s", results["task_2"].Error)
+		}
+	})
+
+	t.Run("continue", func(t *testing.T) {
+		analysis := &stubExpertAgent{agentType: types.AgentTypeDataAnalysis, result: &types.TaskResult{Success: true}}
+		planner := newTestPlanner(&types.ExecutionPolicy{}, nil, failing(), analysis)
+
+		results, err := planner.executePlan(context.Background(), twoStepPlan(types.FailurePolicyContinue))
+		if err != nil {
+			t.Fatalf("执行计划失败: %v", err)
+		}
+		if !results["task_2"].Success {
+			t.Error("continue 策略下下游任务应继续执行")
+		}
+	})
+
+	t.Run("fail_fast", func(t *testing.T) {
+		analysis := &stubExpertAgent{agentType: types.AgentTypeDataAnalysis, result: &types.TaskResult{Success: true}}
+		planner := newTestPlanner(&types.ExecutionPolicy{}, nil, failing(), analysis)
+
+		plan := twoStepPlan(types.FailurePolicyFailFast)
+		if _, err := planner.executePlan(context.Background(), plan); err == nil {
+			t.Fatal("fail_fast 策略下应返回错误")
+		}
+		if plan.Tasks[1].Status != types.TaskStatusCancelled {
+			t.Errorf("未执行的任务应被取消，实际状态: %s", plan.Tasks[1].Status)
+		}
+	})
+}
+
+func TestPlannerAgent_Replan(t *testing.T) {
+	query := &stubExpertAgent{agentType: types.AgentTypeDataQuery, execute: func(task *types.Task) *types.TaskResult {
+		if task.ID == "task_1" {
+			return &types.TaskResult{Success: false, Error: "列 revenue 不存在"}
+		}
+		return &types.TaskResult{Success: true, Output: "查询完成"}
+	}}
+	analysis := &stubExpertAgent{agentType: types.AgentTypeDataAnalysis, result: &types.TaskResult{Success: true}}
+
+	chatModel := &scriptedChatModel{responses: []string{`{"tasks": [
+		{"id": "task_1b", "type": "data_query", "description": "改用 sales 列查询", "agent_type": "data_query"},
+		{"id": "task_2b", "type": "analysis", "description": "分析", "agent_type": "data_analysis", "input": {"data_source": "task_1b"}}
+	]}`}}
+
+	planner := newTestPlanner(&types.ExecutionPolicy{MaxReplanRounds: 1, FailurePolicy: types.FailurePolicyFailFast}, chatModel, query, analysis)
+	plan := twoStepPlan("")
+	results, err := planner.executePlan(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("重新规划后应执行成功: %v", err)
+	}
+
+	if !results["task_1b"].Success || !results["task_2b"].Success {
+		t.Fatalf("子计划任务执行失败: %+v", results)
+	}
+	if _, ran := results["task_2"]; ran || plan.Tasks[1].Status != types.TaskStatusCancelled {
+		t.Error("被替代的下游任务不应执行")
+	}
+	if replacedBy, _ := results["task_1"].Metadata["replaced_by"].([]string); len(replacedBy) != 2 {
+		t.Errorf("失败任务应记录替代任务: %+v", results["task_1"].Metadata)
+	}
+
+	prompt := chatModel.calls[0][len(chatModel.calls[0])-2].Content
+	if !strings.Contains(prompt, "列 revenue 不存在") || !strings.Contains(prompt, "task_2") {
+		t.Errorf("重新规划提示未包含失败信息: %s", prompt)
+	}
+}
+
+func TestPlannerAgent_ResumeAndRerun(t *testing.T) {
+	store := planstore.NewMemoryStore()
+
+	var mu sync.Mutex
+	queryCalls := 0
+	analysisFails := true
+	query := &stubExpertAgent{agentType: types.AgentTypeDataQuery, execute: func(task *types.Task) *types.TaskResult {
+		mu.Lock()
+		defer mu.Unlock()
+		queryCalls++
+		return &types.TaskResult{Success: true, Output: "查询完成"}
+	}}
+	analysis := &stubExpertAgent{agentType: types.AgentTypeDataAnalysis, execute: func(task *types.Task) *types.TaskResult {
+		mu.Lock()
+		defer mu.Unlock()
+		if analysisFails {
+			return &types.TaskResult{Success: false, Error: "内存不足"}
+		}
+		return &types.TaskResult{Success: true, Output: task.Input}
+	}}
+
+	planner := newTestPlanner(&types.ExecutionPolicy{}, nil, query, analysis)
+	planner.config.PlanStore = store
+
+	plan := twoStepPlan("")
+	plan.QueryID = 42
+	if _, err := planner.executePlan(context.Background(), plan); err != nil {
+		t.Fatalf("执行计划失败: %v", err)
+	}
+
+	saved, err := store.GetPlanByQuery(42)
+	if err != nil {
+		t.Fatalf("计划未持久化: %v", err)
+	}
+	if saved.Tasks[0].Status != types.TaskStatusCompleted || saved.Tasks[1].Status != types.TaskStatusFailed {
+		t.Fatalf("持久化的任务状态不正确: %s %s", saved.Tasks[0].Status, saved.Tasks[1].Status)
+	}
+
+	// 恢复执行只重跑失败的任务
+	analysisFails = false
+	resumed, err := planner.ResumePlan(context.Background(), saved.ID)
+	if err != nil {
+		t.Fatalf("恢复执行失败: %v", err)
+	}
+	if queryCalls != 1 || resumed.Tasks[1].Status != types.TaskStatusCompleted {
+		t.Errorf("恢复执行不应重跑上游任务: queryCalls=%d status=%s", queryCalls, resumed.Tasks[1].Status)
+	}
+
+	// 修改输入后单独重跑分析任务
+	rerun, err := planner.RerunTask(context.Background(), saved.ID, "task_2", map[string]interface{}{"analysis_type": "同比"})
+	if err != nil {
+		t.Fatalf("重跑任务失败: %v", err)
+	}
+	if queryCalls != 1 {
+		t.Errorf("重跑任务不应执行上游任务: queryCalls=%d", queryCalls)
+	}
+	output, _ := rerun.Tasks[1].Result.Output.(map[string]interface{})
+	if output["analysis_type"] != "同比" {
+		t.Errorf("重跑任务未使用新的输入: %#v", rerun.Tasks[1].Result.Output)
+	}
+}
+
+func TestPlannerAgent_ConcurrentResume(t *testing.T) {
+	store := planstore.NewMemoryStore()
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var analysisCalls atomic.Int32
+	query := &stubExpertAgent{agentType: types.AgentTypeDataQuery, result: &types.TaskResult{Success: true, Output: "查询完成"}}
+	analysis := &stubExpertAgent{agentType: types.AgentTypeDataAnalysis, execute: func(task *types.Task) *types.TaskResult {
+		if analysisCalls.Add(1) == 1 {
+			return &types.TaskResult{Success: false, Error: "内存不足"}
+		}
+		started <- struct{}{}
+		<-release
+		return &types.TaskResult{Success: true}
+	}}
+	planner := newTestPlanner(&types.ExecutionPolicy{}, nil, query, analysis)
+	planner.config.PlanStore = store
+
+	plan := twoStepPlan("")
+	if _, err := planner.executePlan(context.Background(), plan); err != nil {
+		t.Fatal(err)
+	}
+
+	// 恢复执行进行中时，同一计划的恢复和重跑都被拒绝
+	done := make(chan error, 1)
+	go func() {
+		_, err := planner.ResumePlan(context.Background(), plan.ID)
+		done <- err
+	}()
+	<-started
+	if _, err := planner.ResumePlan(context.Background(), plan.ID); !errors.Is(err, types.ErrPlanRunning) {
+		t.Errorf("计划执行中时恢复应返回 ErrPlanRunning: %v", err)
+	}
+	if _, err := planner.RerunTask(context.Background(), plan.ID, "task_2", nil); !errors.Is(err, types.ErrPlanRunning) {
+		t.Errorf("计划执行中时重跑应返回 ErrPlanRunning: %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("恢复执行失败: %v", err)
+	}
+
+	// 执行结束后可以再次重跑
+	if _, err := planner.RerunTask(context.Background(), plan.ID, "task_2", nil); err != nil {
+		t.Errorf("执行结束后应允许重跑: %v", err)
+	}
+	if n := analysisCalls.Load(); n != 3 {
+		t.Errorf("分析任务应执行3次，实际 %d 次", n)
+	}
+}
+
+func TestPlannerAgent_WorkerLimit(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+	execute := func(task *types.Task) *types.TaskResult {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return &types.TaskResult{Success: true}
+	}
+	query := &stubExpertAgent{agentType: types.AgentTypeDataQuery, execute: execute}
+	analysis := &stubExpertAgent{agentType: types.AgentTypeDataAnalysis, execute: execute}
+
+	planner := newTestPlanner(&types.ExecutionPolicy{}, nil, query, analysis)
+	planner.config.Scheduler = scheduler.New(scheduler.Limits{Workers: 2})
+
+	// 4个相互独立的任务同时就绪，但最多只能同时执行2个
+	plan := &types.ExecutionPlan{ID: "p1"}
+	for _, id := range []string{"t1", "t2", "t3", "t4"} {
+		plan.Tasks = append(plan.Tasks, &types.Task{ID: id, AgentType: types.AgentTypeDataQuery})
+	}
+
+	if _, err := planner.executePlan(context.Background(), plan); err != nil {
+		t.Fatalf("执行计划失败: %v", err)
+	}
+	if peak != 2 {
+		t.Errorf("期望最多同时执行2个任务，实际 %d", peak)
+	}
+	if stats := planner.config.Scheduler.Stats(); stats.Resources[scheduler.ResourceWorker].Acquired != 4 {
+		t.Errorf("调度指标不正确: %+v", stats.Resources[scheduler.ResourceWorker])
+	}
+}
+
+// llmExpertAgent 执行任务时调用一次聊天模型的专家，before 在调用模型前执行
+type llmExpertAgent struct {
+	*stubExpertAgent
+	chatModel model.BaseChatModel
+	before    func()
+}
+
+func (a *llmExpertAgent) ExecuteTask(ctx context.Context, task *types.Task) (*types.TaskResult, error) {
+	a.before()
+	msg, err := a.chatModel.Generate(ctx, []*schema.Message{schema.UserMessage(task.Description)})
+	if err != nil {
+		return &types.TaskResult{Success: false, Error: err.Error()}, nil
+	}
+	return &types.TaskResult{Success: true, Output: msg.Content}, nil
+}
+
+func TestPlannerAgent_MoreReadyTasksThanWorkers(t *testing.T) {
+	sched := scheduler.New(scheduler.Limits{Workers: 1, LLMConcurrency: 1})
+	chatModel := NewLimitedChatModel(&scriptedChatModel{responses: []string{"a", "b", "c"}}, sched, "openai")
+	query := &stubExpertAgent{agentType: types.AgentTypeDataQuery}
+	analysis := &stubExpertAgent{agentType: types.AgentTypeDataAnalysis}
+
+	planner := newTestPlanner(&types.ExecutionPolicy{}, nil, query, analysis)
+	// 每个任务等其余任务都在排队等待执行槽位后再调用LLM
+	var mu sync.Mutex
+	pending := 3
+	before := func() {
+		mu.Lock()
+		pending--
+		waiting := pending
+		mu.Unlock()
+		deadline := time.Now().Add(time.Second)
+		for sched.Stats().Resources[scheduler.ResourceWorker].Waiting != waiting && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	planner.RegisterExpertAgent(&llmExpertAgent{stubExpertAgent: query, chatModel: chatModel, before: before})
+	planner.config.Scheduler = sched
+
+	// 同一用户的3个任务同时就绪，持有执行槽位的任务调用LLM时不应被排队等待执行槽位的任务阻塞
+	plan := &types.ExecutionPlan{ID: "p1"}
+	for _, id := range []string{"t1", "t2", "t3"} {
+		plan.Tasks = append(plan.Tasks, &types.Task{ID: id, AgentType: types.AgentTypeDataQuery, Description: id})
+	}
+	ctx, cancel := context.WithTimeout(scheduler.WithUser(context.Background(), "alice"), 5*time.Second)
+	defer cancel()
+
+	if _, err := planner.executePlan(ctx, plan); err != nil {
+		t.Fatalf("执行计划失败: %v", err)
+	}
+	for _, task := range plan.Tasks {
+		if task.Result == nil || !task.Result.Success {
+			t.Errorf("任务 %s 执行失败: %+v", task.ID, task.Result)
+		}
+	}
+	if stats := sched.Stats(); stats.Resources[scheduler.LLMResource("openai")].Acquired != 3 || stats.Resources[scheduler.LLMResource("openai")].Cancelled != 0 {
+		t.Errorf("调度指标不正确: %+v", stats.Resources)
+	}
+}
@@ -0,0 +1,66 @@
+package agents
+
+import (
+	"context"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/pii"
+)
+
+// redactingChatModel 发送给LLM前脱敏消息中的敏感信息的聊天模型
+type redactingChatModel struct {
+	model  model.BaseChatModel
+	masker *pii.Masker
+}
+
+// redactingToolCallingChatModel 支持工具调用的 redactingChatModel
+type redactingToolCallingChatModel struct {
+	*redactingChatModel
+}
+
+// NewRedactingChatModel 包装聊天模型，每次调用前脱敏消息文本中的手机号、身份证号、银行卡号和邮箱。
+// 这是提示词的兜底检查，表格数据应在进入提示词前按列策略脱敏；被包装的模型支持工具调用时返回的模型同样支持
+func NewRedactingChatModel(chatModel model.BaseChatModel, masker *pii.Masker) model.BaseChatModel {
+	redacting := &redactingChatModel{model: chatModel, masker: masker}
+	if _, ok := chatModel.(model.ToolCallingChatModel); ok {
+		return &redactingToolCallingChatModel{redacting}
+	}
+	return redacting
+}
+
+// Generate 脱敏输入后生成响应
+func (m *redactingChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	return m.model.Generate(ctx, m.redact(input), opts...)
+}
+
+// Stream 脱敏输入后流式生成响应
+func (m *redactingChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return m.model.Stream(ctx, m.redact(input), opts...)
+}
+
+// WithTools 绑定工具，返回的模型同样会脱敏输入
+func (m *redactingToolCallingChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound, err := m.model.(model.ToolCallingChatModel).WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	return &redactingToolCallingChatModel{&redactingChatModel{model: bound, masker: m.masker}}, nil
+}
+
+// redact 返回脱敏后的消息副本，不修改调用方的消息
+func (m *redactingChatModel) redact(input []*schema.Message) []*schema.Message {
+	output := make([]*schema.Message, len(input))
+	for i, msg := range input {
+		output[i] = msg
+		if msg == nil || msg.Content == "" {
+			continue
+		}
+		if content, masked := m.masker.Text(msg.Content); len(masked) > 0 {
+			redacted := *msg
+			redacted.Content = content
+			output[i] = &redacted
+		}
+	}
+	return output
+}
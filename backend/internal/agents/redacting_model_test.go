@@ -0,0 +1,49 @@
+package agents
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/pii"
+	"smart-analysis/internal/types"
+)
+
+func TestRedactingChatModel(t *testing.T) {
+	inner := &scriptedChatModel{responses: []string{"ok"}}
+	chatModel := NewRedactingChatModel(inner, pii.NewMasker(""))
+
+	messages := []*schema.Message{
+		{Role: schema.System, Content: "你是数据分析助手"},
+		{Role: schema.User, Content: "客户 13812345678 的订单"},
+	}
+	if _, err := chatModel.Generate(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := inner.calls[0]
+	if sent[0] != messages[0] || sent[1].Content != "客户 138****5678 的订单" {
+		t.Errorf("发送的消息不正确: %v", sent)
+	}
+	if messages[1].Content != "客户 13812345678 的订单" {
+		t.Error("不应修改调用方的消息")
+	}
+}
+
+func TestRedactingChatModel_ToolCalling(t *testing.T) {
+	if _, ok := NewRedactingChatModel(&scriptedChatModel{}, pii.NewMasker("")).(model.ToolCallingChatModel); ok {
+		t.Error("被包装的模型不支持工具调用时不应声明支持")
+	}
+	if _, err := NewReactAgent(context.Background(), &types.AgentConfig{ChatModel: NewRedactingChatModel(&scriptedChatModel{}, pii.NewMasker(""))}); err == nil {
+		t.Error("聊天模型不支持工具调用时React智能体应返回错误")
+	}
+
+	redacting, ok := NewRedactingChatModel(&toolChatModel{}, pii.NewMasker("")).(model.ToolCallingChatModel)
+	if !ok {
+		t.Fatal("被包装的模型支持工具调用时应声明支持")
+	}
+	if _, err := redacting.WithTools([]*schema.ToolInfo{{Name: "sql_query"}}); err != nil {
+		t.Fatal(err)
+	}
+}
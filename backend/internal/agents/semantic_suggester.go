@@ -0,0 +1,45 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/prompts"
+	"smart-analysis/internal/semantic"
+	"smart-analysis/internal/tools"
+	"smart-analysis/internal/types"
+)
+
+// SemanticSuggester 根据表结构用LLM生成业务语义模型建议
+type SemanticSuggester struct {
+	config *types.AgentConfig
+}
+
+// NewSemanticSuggester 创建语义模型建议生成器
+func NewSemanticSuggester(config *types.AgentConfig) *SemanticSuggester {
+	return &SemanticSuggester{config: config}
+}
+
+// Suggest 生成语义模型建议，existing 为已有的模型，提示LLM只补充缺失的内容。
+// 返回的建议未经表结构校验，调用方需要用 semantic.Clean 去掉无效条目
+func (s *SemanticSuggester) Suggest(ctx context.Context, tables []types.DataSchema, existing *types.SemanticModel) (*types.SemanticModel, error) {
+	if s.config == nil || s.config.ChatModel == nil {
+		return nil, fmt.Errorf("未配置语言模型")
+	}
+
+	prompt, err := promptLibrary(s.config).Render(prompts.TemplateSemanticSuggest, map[string]interface{}{
+		"Schema":   tools.DescribeSchemas(tables),
+		"Existing": semantic.Describe(existing, ""),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var suggestion types.SemanticModel
+	messages := []*schema.Message{{Role: schema.User, Content: prompt}}
+	if err := generateStructured(ctx, s.config, s.config.ChatModel, messages, types.SemanticModelSchema(), &suggestion); err != nil {
+		return nil, err
+	}
+	return &suggestion, nil
+}
@@ -0,0 +1,70 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/semantic"
+	"smart-analysis/internal/types"
+)
+
+func TestSemanticSuggester_Suggest(t *testing.T) {
+	chatModel := &scriptedChatModel{responses: []string{
+		`{"joins": [{"from": "orders", "to": "users.id"}]}`,
+		`{"tables": [{"name": "orders", "description": "订单表", "columns": [{"name": "amt", "description": "订单金额", "synonyms": ["GMV"]}]}],
+		  "metrics": [{"name": "客单价", "formula": "SUM(amt) / COUNT(DISTINCT user_id)"}],
+		  "joins": [{"from": "orders.user_id", "to": "users.id"}]}`,
+	}}
+	tables := []types.DataSchema{{TableName: "orders", Columns: []types.ColumnInfo{{Name: "amt", Type: "REAL"}, {Name: "user_id", Type: "INTEGER"}}}}
+	existing := &types.SemanticModel{Metrics: []types.SemanticMetric{{Name: "订单数", Formula: "COUNT(*)"}}}
+
+	suggestion, err := NewSemanticSuggester(&types.AgentConfig{ChatModel: chatModel}).Suggest(context.Background(), tables, existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestion.Tables) != 1 || suggestion.Tables[0].Columns[0].Synonyms[0] != "GMV" || len(suggestion.Metrics) != 1 {
+		t.Errorf("建议解析不正确: %+v", suggestion)
+	}
+
+	// 提示包含表结构和已有的模型
+	prompt := chatModel.calls[0][0].Content
+	if !strings.Contains(prompt, "amt") || !strings.Contains(prompt, "订单数 = COUNT(*)") {
+		t.Errorf("提示缺少表结构或已有模型:\n%s", prompt)
+	}
+	if len(chatModel.calls) != 2 {
+		t.Errorf("不符合模式的输出应触发修复，调用次数: %d", len(chatModel.calls))
+	}
+}
+
+func TestMasterAgent_SemanticTerms(t *testing.T) {
+	chatModel := &scriptedChatModel{responses: []string{`{"intent_type": "data_query", "query_object": {"metrics": ["客单价"]}}`}}
+	agent, _ := NewMasterAgent(context.Background(), &types.AgentConfig{ChatModel: chatModel})
+	model := &types.SemanticModel{
+		Tables:  []types.SemanticTable{{Name: "orders", Columns: []types.SemanticColumn{{Name: "amt", Description: "订单金额"}}}},
+		Metrics: []types.SemanticMetric{{Name: "客单价", Formula: "SUM(amt) / COUNT(DISTINCT user_id)", Synonyms: []string{"ARPU"}}},
+	}
+
+	response, err := agent.Generate(context.Background(), []*schema.Message{{Role: schema.User, Content: "上月各城市的ARPU"}}, model)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if system := chatModel.calls[0][0].Content; !strings.Contains(system, "ARPU → 指标 客单价 = SUM(amt) / COUNT(DISTINCT user_id)") {
+		t.Errorf("系统提示缺少业务术语:\n%s", system)
+	}
+
+	var intent struct {
+		Metadata struct {
+			SemanticTerms []semantic.Term `json:"semantic_terms"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(response.Content), &intent); err != nil {
+		t.Fatal(err)
+	}
+	if terms := intent.Metadata.SemanticTerms; len(terms) != 1 || terms[0].Kind != semantic.TermMetric || terms[0].Target != "客单价" {
+		t.Errorf("识别的业务术语不正确: %+v", terms)
+	}
+}
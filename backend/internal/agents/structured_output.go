@@ -0,0 +1,198 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/prompts"
+	"smart-analysis/internal/types"
+)
+
+// defaultStructuredRepairAttempts 结构化输出校验失败后的默认修复轮数
+const defaultStructuredRepairAttempts = 2
+
+// StructuredOutputError 结构化输出在修复轮数耗尽后仍未通过校验
+type StructuredOutputError struct {
+	Schema   string
+	Attempts int
+	Issues   []string
+}
+
+// Error 实现error接口
+func (e *StructuredOutputError) Error() string {
+	return fmt.Sprintf("%s 结构化输出经过 %d 次尝试仍未通过校验: %s", e.Schema, e.Attempts, strings.Join(e.Issues, "; "))
+}
+
+// generateStructured 生成符合JSON Schema的结构化输出并解码到out。
+// 模型支持工具调用时通过强制调用提交工具获得JSON（即提供商的结构化输出模式），
+// 否则在提示中附加Schema并从文本中提取JSON；校验失败时把错误反馈给LLM进行有限轮数的修复。
+func generateStructured(ctx context.Context, config *types.AgentConfig, chatModel model.BaseChatModel, messages []*schema.Message, jsonSchema *types.JSONSchema, out interface{}) error {
+	library := promptLibrary(config)
+
+	maxRepairs := defaultStructuredRepairAttempts
+	if config != nil && config.StructuredRepairAttempts > 0 {
+		maxRepairs = config.StructuredRepairAttempts
+	}
+
+	conversation := make([]*schema.Message, 0, len(messages)+2*maxRepairs+1)
+	conversation = append(conversation, messages...)
+
+	generate, structuredMode := bindStructuredTool(ctx, chatModel, jsonSchema)
+	if !structuredMode {
+		formatPrompt, err := library.Render(prompts.TemplateStructuredOutputFormat, map[string]interface{}{
+			"Name":   jsonSchema.Name,
+			"Schema": jsonSchema.String(),
+		})
+		if err != nil {
+			return err
+		}
+		conversation = append(conversation, &schema.Message{
+			Role:    schema.System,
+			Content: formatPrompt,
+		})
+	}
+
+	var issues []string
+	for attempt := 0; attempt <= maxRepairs; attempt++ {
+		response, err := generate(conversation)
+		if err != nil {
+			return fmt.Errorf("调用LLM失败: %w", err)
+		}
+
+		raw := structuredContent(response)
+		issues = validateStructured(raw, jsonSchema, out)
+		if len(issues) == 0 {
+			return nil
+		}
+
+		repairPrompt, err := library.Render(prompts.TemplateStructuredOutputRepair, map[string]interface{}{
+			"Name":   jsonSchema.Name,
+			"Issues": issues,
+		})
+		if err != nil {
+			return err
+		}
+
+		// 以纯文本形式回放上一轮输出，避免工具调用消息缺少对应的工具结果
+		previous := raw
+		if previous == "" && response != nil {
+			previous = response.Content
+		}
+		conversation = append(conversation,
+			&schema.Message{Role: schema.Assistant, Content: previous},
+			&schema.Message{Role: schema.User, Content: repairPrompt},
+		)
+	}
+
+	return &StructuredOutputError{
+		Schema:   jsonSchema.Name,
+		Attempts: maxRepairs + 1,
+		Issues:   issues,
+	}
+}
+
+// bindStructuredTool 尝试将Schema绑定为强制调用的提交工具，返回生成函数以及是否处于结构化输出模式
+func bindStructuredTool(ctx context.Context, chatModel model.BaseChatModel, jsonSchema *types.JSONSchema) (func([]*schema.Message) (*schema.Message, error), bool) {
+	plain := func(msgs []*schema.Message) (*schema.Message, error) {
+		return chatModel.Generate(ctx, msgs)
+	}
+
+	toolModel, ok := chatModel.(model.ToolCallingChatModel)
+	if !ok {
+		return plain, false
+	}
+
+	bound, err := toolModel.WithTools([]*schema.ToolInfo{
+		{
+			Name:        "submit_" + strings.ToLower(jsonSchema.Name),
+			Desc:        fmt.Sprintf("提交%s结构化结果", jsonSchema.Name),
+			ParamsOneOf: schema.NewParamsOneOfByOpenAPIV3(jsonSchema.OpenAPIV3()),
+		},
+	})
+	if err != nil {
+		return plain, false
+	}
+
+	return func(msgs []*schema.Message) (*schema.Message, error) {
+		return bound.Generate(ctx, msgs, model.WithToolChoice(schema.ToolChoiceForced))
+	}, true
+}
+
+// structuredContent 从模型响应中取出JSON文本，优先使用工具调用参数
+func structuredContent(response *schema.Message) string {
+	if response == nil {
+		return ""
+	}
+	for _, call := range response.ToolCalls {
+		if args := strings.TrimSpace(call.Function.Arguments); args != "" {
+			return args
+		}
+	}
+	return extractJSON(response.Content)
+}
+
+// validateStructured 校验并解码JSON，返回问题列表（为空表示成功）
+func validateStructured(raw string, jsonSchema *types.JSONSchema, out interface{}) []string {
+	if raw == "" {
+		return []string{"未找到有效的JSON内容"}
+	}
+
+	if err := jsonSchema.Validate([]byte(raw)); err != nil {
+		if validationErr, ok := err.(*types.SchemaValidationError); ok {
+			return validationErr.Issues
+		}
+		return []string{err.Error()}
+	}
+
+	if err := json.Unmarshal([]byte(raw), out); err != nil {
+		return []string{fmt.Sprintf("JSON解析失败: %v", err)}
+	}
+
+	return nil
+}
+
+// extractJSON 从文本中提取第一个完整的JSON对象，支持代码块包裹以及字符串中包含花括号的情况
+func extractJSON(content string) string {
+	// 优先查找代码块
+	for _, fence := range []string{"```json", "```"} {
+		start := strings.Index(content, fence)
+		if start == -1 {
+			continue
+		}
+		start += len(fence)
+		end := strings.Index(content[start:], "```")
+		if end == -1 {
+			continue
+		}
+		if candidate := firstJSONObject(content[start : start+end]); candidate != "" {
+			return candidate
+		}
+	}
+
+	return firstJSONObject(content)
+}
+
+// firstJSONObject 依次尝试从每个 '{' 开始解码一个JSON对象
+func firstJSONObject(content string) string {
+	for offset := 0; offset < len(content); {
+		start := strings.IndexByte(content[offset:], '{')
+		if start == -1 {
+			return ""
+		}
+		start += offset
+
+		decoder := json.NewDecoder(strings.NewReader(content[start:]))
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err == nil {
+			return string(raw)
+		}
+
+		offset = start + 1
+	}
+
+	return ""
+}
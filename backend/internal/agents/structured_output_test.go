@@ -0,0 +1,111 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/types"
+)
+
+// scriptedChatModel 按顺序返回预设内容的聊天模型
+type scriptedChatModel struct {
+	responses []string
+	calls     [][]*schema.Message
+}
+
+func (m *scriptedChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.calls = append(m.calls, input)
+	if len(m.responses) == 0 {
+		return nil, errors.New("no more responses")
+	}
+	content := m.responses[0]
+	m.responses = m.responses[1:]
+	return &schema.Message{Role: schema.Assistant, Content: content}, nil
+}
+
+func (m *scriptedChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestGenerateStructured_RepairLoop(t *testing.T) {
+	chatModel := &scriptedChatModel{responses: []string{
+		"好的，计划如下：{\"tasks\": [{\"id\": 1}]}",
+		"```json\n{\"id\": \"p1\", \"tasks\": [{\"id\": \"t1\", \"type\": \"data_query\", \"description\": \"查询 {数据}\", \"agent_type\": \"data_query\"}]}\n```",
+	}}
+
+	var plan types.ExecutionPlan
+	err := generateStructured(context.Background(), &types.AgentConfig{}, chatModel, []*schema.Message{schema.UserMessage("plan")}, types.ExecutionPlanSchema(), &plan)
+	if err != nil {
+		t.Fatalf("期望修复成功，实际失败: %v", err)
+	}
+
+	if len(plan.Tasks) != 1 || plan.Tasks[0].Description != "查询 {数据}" {
+		t.Fatalf("解析结果不正确: %+v", plan.Tasks)
+	}
+
+	if len(chatModel.calls) != 2 {
+		t.Fatalf("期望调用LLM 2次，实际 %d 次", len(chatModel.calls))
+	}
+
+	// 第二次调用应包含校验错误反馈
+	last := chatModel.calls[1][len(chatModel.calls[1])-1]
+	if last.Role != schema.User || !strings.Contains(last.Content, "/tasks/0/id") {
+		t.Errorf("修复提示未包含校验错误: %s", last.Content)
+	}
+}
+
+func TestGenerateStructured_ExhaustedRepairs(t *testing.T) {
+	chatModel := &scriptedChatModel{responses: []string{
+		`{"intent_type": "unknown"}`,
+		`not json at all`,
+		`{"intent_type": "analysis", "query_object": {"order_by": [{"column": "x", "direction": "up"}]}}`,
+	}}
+
+	var intent types.QueryIntent
+	err := generateStructured(context.Background(), &types.AgentConfig{}, chatModel, []*schema.Message{schema.UserMessage("q")}, types.QueryIntentSchema(), &intent)
+
+	var structuredErr *StructuredOutputError
+	if !errors.As(err, &structuredErr) {
+		t.Fatalf("期望返回StructuredOutputError，实际: %v", err)
+	}
+	if structuredErr.Attempts != defaultStructuredRepairAttempts+1 {
+		t.Errorf("尝试次数不正确: %d", structuredErr.Attempts)
+	}
+}
+
+func TestPlannerAgent_MalformedPlanDoesNotPanic(t *testing.T) {
+	malformed := `{"id": 42, "tasks": [{"id": null, "type": ["x"], "agent_type": {}}], "dependencies": "none"}`
+	chatModel := &scriptedChatModel{responses: []string{malformed, malformed, malformed}}
+
+	planner, err := NewPlannerAgent(context.Background(), &types.AgentConfig{ChatModel: chatModel})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := planner.createExecutionPlan(context.Background(), &types.QueryIntent{IntentType: "data_query", QueryObject: &types.QueryObject{}})
+	if err != nil {
+		t.Fatalf("期望回退到默认计划，实际失败: %v", err)
+	}
+	if len(plan.Tasks) == 0 {
+		t.Error("默认计划不应为空")
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	cases := map[string]string{
+		"前缀 {\"a\": \"}\"} 后缀":             `{"a": "}"}`,
+		"```json\n{\"b\": 1}\n```":         `{"b": 1}`,
+		"{broken {\"c\": [1, 2]} trailing": `{"c": [1, 2]}`,
+		"no json":                          "",
+	}
+
+	for input, expected := range cases {
+		if got := extractJSON(input); got != expected {
+			t.Errorf("extractJSON(%q) = %q, 期望 %q", input, got, expected)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/prompts"
+	"smart-analysis/internal/types"
+)
+
+// 汇总提示中单个任务内容的上限，避免大结果撑爆上下文
+const (
+	summaryMaxOutputChars = 4000
+	summaryMaxTableRows   = 20
+)
+
+// summaryTask 汇总提示中的任务信息
+type summaryTask struct {
+	Description string
+	AgentType   types.AgentType
+	Success     bool
+	Error       string
+	Output      string
+	Table       string
+	Charts      []string
+}
+
+// summarize 根据原始问题、查询意图和全部任务结果生成最终回答，
+// 结构化结果放在消息的 Extra[types.AnswerExtraKey] 中；LLM不可用时使用模板汇总
+func (a *PlannerAgent) summarize(ctx context.Context, messages []*schema.Message, queryIntent *types.QueryIntent, plan *types.ExecutionPlan, results map[string]*types.TaskResult) *schema.Message {
+	answer := buildAnalysisAnswer(lastUserQuestion(messages), plan, results)
+
+	if content, err := a.generateSummary(ctx, answer, queryIntent, plan, results); err == nil && content != "" {
+		answer.Answer = content
+		answer.Summarized = true
+	} else {
+		answer.Answer = a.consolidateResults(plan, results)
+	}
+
+	return &schema.Message{
+		Role:    schema.Assistant,
+		Content: answer.Answer,
+		Extra:   map[string]interface{}{types.AnswerExtraKey: answer},
+	}
+}
+
+// generateSummary 调用LLM撰写最终回答
+func (a *PlannerAgent) generateSummary(ctx context.Context, answer *types.AnalysisAnswer, queryIntent *types.QueryIntent, plan *types.ExecutionPlan, results map[string]*types.TaskResult) (string, error) {
+	if a.chatModel == nil {
+		return "", fmt.Errorf("未配置聊天模型")
+	}
+
+	tasks := make([]summaryTask, 0, len(plan.Tasks))
+	for _, task := range plan.Tasks {
+		result, ok := results[task.ID]
+		if !ok || task.IsReplaced() {
+			continue
+		}
+
+		item := summaryTask{
+			Description: task.Description,
+			AgentType:   task.AgentType,
+			Success:     result.Success,
+			Error:       result.Error,
+			Output:      truncateText(outputText(result.Output), summaryMaxOutputChars),
+		}
+		if frame, ok := result.Artifacts[types.ArtifactTypeDataFrame]; ok {
+			item.Table = markdownTable(frame, summaryMaxTableRows)
+		}
+		if image, ok := result.Artifacts[types.ArtifactTypeImage]; ok && image.Path != "" {
+			item.Charts = append(item.Charts, image.Path)
+		}
+		tasks = append(tasks, item)
+	}
+
+	prompt, err := promptLibrary(a.config).Render(prompts.TemplatePlannerSummary, map[string]interface{}{
+		"Question":    answer.Question,
+		"QueryIntent": queryIntent,
+		"Tasks":       tasks,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	response, err := a.chatModel.Generate(ctx, []*schema.Message{
+		{Role: schema.User, Content: prompt},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(response.Content), nil
+}
+
+// buildAnalysisAnswer 构建机器可读的最终结果
+func buildAnalysisAnswer(question string, plan *types.ExecutionPlan, results map[string]*types.TaskResult) *types.AnalysisAnswer {
+	answer := &types.AnalysisAnswer{
+		PlanID:   plan.ID,
+		Question: question,
+		Tasks:    make([]types.TaskOutcome, 0, len(plan.Tasks)),
+	}
+
+	for _, task := range plan.Tasks {
+		outcome := types.TaskOutcome{
+			ID:          task.ID,
+			Description: task.Description,
+			AgentType:   task.AgentType,
+			Status:      task.Status,
+		}
+		if result, ok := results[task.ID]; ok {
+			outcome.Success = result.Success
+			outcome.Output = result.Output
+			outcome.Error = result.Error
+			outcome.Artifacts = result.Artifacts
+			if image, ok := result.Artifacts[types.ArtifactTypeImage]; ok && image.Path != "" {
+				answer.Charts = append(answer.Charts, image.Path)
+			}
+		}
+		answer.Tasks = append(answer.Tasks, outcome)
+	}
+
+	return answer
+}
+
+// lastUserQuestion 返回最后一条用户消息
+func lastUserQuestion(messages []*schema.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i] != nil && messages[i].Role == schema.User {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// outputText 将任务输出转换为文本
+func outputText(output interface{}) string {
+	switch v := output.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// truncateText 按字符数截断文本
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "\n...（已截断）"
+}
+
+// markdownTable 将数据表产物渲染为Markdown表格，最多保留 maxRows 行
+func markdownTable(frame *types.Artifact, maxRows int) string {
+	data, ok := frame.Data.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	columns, _ := data["columns"].([]interface{})
+	rows, _ := data["data"].([]interface{})
+	if len(columns) == 0 {
+		return ""
+	}
+
+	cell := func(v interface{}) string {
+		return strings.ReplaceAll(fmt.Sprintf("%v", v), "|", "\\|")
+	}
+
+	var b strings.Builder
+	header := make([]string, len(columns))
+	separator := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = cell(column)
+		separator[i] = "---"
+	}
+	b.WriteString("| " + strings.Join(header, " | ") + " |\n")
+	b.WriteString("| " + strings.Join(separator, " | ") + " |\n")
+
+	for i, row := range rows {
+		if i >= maxRows {
+			b.WriteString(fmt.Sprintf("\n（共 %d 行，仅显示前 %d 行）\n", len(rows), maxRows))
+			break
+		}
+		values, _ := row.([]interface{})
+		cells := make([]string, len(columns))
+		for j := range columns {
+			if j < len(values) {
+				cells[j] = cell(values[j])
+			}
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+
+	return strings.TrimSpace(b.String())
+}
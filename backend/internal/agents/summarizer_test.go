@@ -0,0 +1,57 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/types"
+)
+
+func TestPlannerAgent_Summarize(t *testing.T) {
+	frame := map[string]interface{}{
+		"columns": []interface{}{"region", "sales"},
+		"data":    []interface{}{[]interface{}{"east", 10.0}, []interface{}{"west", 7.0}},
+	}
+	plan := twoStepPlan("")
+	results := map[string]*types.TaskResult{
+		"task_1": {Success: true, Output: "查询完成", Artifacts: map[string]*types.Artifact{
+			types.ArtifactTypeDataFrame: {Type: types.ArtifactTypeDataFrame, Data: frame},
+		}},
+		"task_2": {Success: true, Output: "east 销售额最高", Artifacts: map[string]*types.Artifact{
+			types.ArtifactTypeImage: {Type: types.ArtifactTypeImage, Path: "/tmp/sales.png"},
+		}},
+	}
+	messages := []*schema.Message{schema.UserMessage("哪个地区销售额最高？")}
+	intent := &types.QueryIntent{IntentType: "analysis"}
+
+	chatModel := &scriptedChatModel{responses: []string{"东部地区销售额最高，为 10。"}}
+	planner := newTestPlanner(nil, chatModel, &stubExpertAgent{agentType: types.AgentTypeDataQuery}, &stubExpertAgent{agentType: types.AgentTypeDataAnalysis})
+
+	msg := planner.summarize(context.Background(), messages, intent, plan, results)
+	answer, ok := msg.Extra[types.AnswerExtraKey].(*types.AnalysisAnswer)
+	if !ok {
+		t.Fatalf("消息中缺少结构化结果: %#v", msg.Extra)
+	}
+	if !answer.Summarized || msg.Content != "东部地区销售额最高，为 10。" {
+		t.Errorf("期望使用LLM回答: %q", msg.Content)
+	}
+	if answer.Question != "哪个地区销售额最高？" || len(answer.Tasks) != 2 || len(answer.Charts) != 1 {
+		t.Errorf("结构化结果不正确: %+v", answer)
+	}
+
+	prompt := chatModel.calls[0][0].Content
+	for _, want := range []string{"哪个地区销售额最高？", "| east | 10 |", "/tmp/sales.png"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("汇总提示未包含 %q", want)
+		}
+	}
+
+	// LLM不可用时退回模板汇总
+	msg = planner.summarize(context.Background(), messages, intent, plan, results)
+	answer = msg.Extra[types.AnswerExtraKey].(*types.AnalysisAnswer)
+	if answer.Summarized || !strings.Contains(msg.Content, "总计: 2/2") {
+		t.Errorf("期望退回模板汇总: %q", msg.Content)
+	}
+}
@@ -0,0 +1,319 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"smart-analysis/internal/types"
+	"smart-analysis/internal/utils/sanbox"
+)
+
+// dataSourceKey 任务输入中引用上游任务输出的字段
+const dataSourceKey = "data_source"
+
+// sandboxArtifacts 从沙盒执行结果中提取任务产物
+func sandboxArtifacts(result *sanbox.PythonExecutionResult) map[string]*types.Artifact {
+	if result == nil || !result.Success {
+		return nil
+	}
+
+	artifacts := make(map[string]*types.Artifact)
+	switch result.OutputType {
+	case "", "none", "image":
+	case types.ArtifactTypeDataFrame:
+		artifacts[types.ArtifactTypeDataFrame] = &types.Artifact{Type: types.ArtifactTypeDataFrame, Data: result.Output}
+	default:
+		artifacts[types.ArtifactTypeValue] = &types.Artifact{Type: types.ArtifactTypeValue, Data: result.Output}
+	}
+
+	if result.ImagePath != "" {
+		artifacts[types.ArtifactTypeImage] = &types.Artifact{Type: types.ArtifactTypeImage, Path: result.ImagePath}
+	}
+
+	if len(artifacts) == 0 {
+		return nil
+	}
+	return artifacts
+}
+
+// dataSourceRefs 返回任务输入中 data_source 引用的计划内任务ID
+func dataSourceRefs(input interface{}, taskIDs map[string]bool) []string {
+	inputMap, ok := input.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var refs []string
+	for _, value := range dataSourceValues(inputMap[dataSourceKey]) {
+		if id, ok := value.(string); ok && taskIDs[id] {
+			refs = append(refs, id)
+		}
+	}
+	return refs
+}
+
+// dataSourceValues 将 data_source 统一为列表，支持单个值或数组
+func dataSourceValues(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	case []string:
+		values := make([]interface{}, len(v))
+		for i, s := range v {
+			values[i] = s
+		}
+		return values
+	default:
+		return []interface{}{v}
+	}
+}
+
+// planRun 单次计划执行的共享状态，负责将上游产物落盘供下游任务的代码读取
+type planRun struct {
+	mu       sync.Mutex
+	workDir  string
+	files    map[string]string     // task_id -> 数据文件路径
+	datasets types.DatasetResolver // 为空时文件类数据源保持原样
+}
+
+// newPlanRun 创建计划执行状态，datasets 用于将任务引用的上传文件解析为用户可见的数据视图
+func newPlanRun(datasets types.DatasetResolver) *planRun {
+	return &planRun{files: make(map[string]string), datasets: datasets}
+}
+
+// cleanup 删除执行过程中生成的临时文件
+func (r *planRun) cleanup() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.workDir != "" {
+		os.RemoveAll(r.workDir)
+		r.workDir = ""
+	}
+}
+
+// artifactDir 返回写出上游产物的目录，尚未写出产物时返回空字符串
+func (r *planRun) artifactDir() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.workDir
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// dataFile 将任务产出的数据表写入临时文件（split格式的JSON），同一任务只写一次
+func (r *planRun) dataFile(taskID string, artifact *types.Artifact) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if path, ok := r.files[taskID]; ok {
+		return path, nil
+	}
+
+	if r.workDir == "" {
+		dir, err := os.MkdirTemp("", "plan_data_*")
+		if err != nil {
+			return "", fmt.Errorf("创建临时目录失败: %w", err)
+		}
+		r.workDir = dir
+	}
+
+	// pandas 的 split 格式只接受 columns/index/data 三个字段
+	frame, _ := artifact.Data.(map[string]interface{})
+	data, err := json.Marshal(map[string]interface{}{
+		"columns": frame["columns"],
+		"index":   frame["index"],
+		"data":    frame["data"],
+	})
+	if err != nil {
+		return "", fmt.Errorf("序列化任务 %s 的数据失败: %w", taskID, err)
+	}
+
+	path := filepath.Join(r.workDir, unsafeFileChars.ReplaceAllString(taskID, "_")+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("写入任务 %s 的数据失败: %w", taskID, err)
+	}
+
+	r.files[taskID] = path
+	return path, nil
+}
+
+// resolveTaskInput 将输入中对上游任务的 data_source 引用替换为上游产物，文件类数据源替换为用户可见的数据视图
+func (r *planRun) resolveTaskInput(ctx context.Context, task *types.Task, previousResults map[string]*types.TaskResult) (interface{}, error) {
+	inputMap, ok := task.Input.(map[string]interface{})
+	if !ok {
+		return task.Input, nil
+	}
+
+	values := dataSourceValues(inputMap[dataSourceKey])
+	if len(values) == 0 {
+		return task.Input, nil
+	}
+
+	dependencies := make(map[string]bool, len(task.Dependencies))
+	for _, depID := range task.Dependencies {
+		dependencies[depID] = true
+	}
+
+	resolved := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		id, ok := value.(string)
+		if !ok || !dependencies[id] {
+			// 非任务引用（如文件名）在配置了数据集解析器时替换为数据视图，否则保持原样
+			if ok && r.datasets != nil {
+				path, err := r.datasets.ResolveDataset(ctx, id)
+				if err != nil {
+					return nil, fmt.Errorf("数据源 %s 不可用: %w", id, err)
+				}
+				value = path
+			}
+			resolved = append(resolved, value)
+			continue
+		}
+
+		upstream, exists := previousResults[id]
+		if !exists {
+			return nil, fmt.Errorf("上游任务 %s 尚未完成", id)
+		}
+		if !upstream.Success {
+			return nil, fmt.Errorf("上游任务 %s 执行失败: %s", id, upstream.Error)
+		}
+
+		ref, err := r.dataReference(id, upstream)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, ref)
+	}
+
+	// 复制输入，避免修改计划中的原始定义
+	input := make(map[string]interface{}, len(inputMap))
+	for key, value := range inputMap {
+		input[key] = value
+	}
+	if _, isList := inputMap[dataSourceKey].([]interface{}); isList || len(resolved) > 1 {
+		input[dataSourceKey] = resolved
+	} else {
+		input[dataSourceKey] = resolved[0]
+	}
+
+	return input, nil
+}
+
+// dataReference 构建指向上游任务产物的数据引用
+func (r *planRun) dataReference(taskID string, upstream *types.TaskResult) (map[string]interface{}, error) {
+	ref := map[string]interface{}{"task_id": taskID}
+
+	if image, ok := upstream.Artifacts[types.ArtifactTypeImage]; ok {
+		ref["image_path"] = image.Path
+	}
+
+	if frame, ok := upstream.Artifacts[types.ArtifactTypeDataFrame]; ok {
+		path, err := r.dataFile(taskID, frame)
+		if err != nil {
+			return nil, err
+		}
+		ref["type"] = types.ArtifactTypeDataFrame
+		ref["path"] = path
+		if data, ok := frame.Data.(map[string]interface{}); ok {
+			ref["columns"] = data["columns"]
+			if rows, ok := data["data"].([]interface{}); ok {
+				ref["rows"] = len(rows)
+			}
+		}
+		return ref, nil
+	}
+
+	if value, ok := upstream.Artifacts[types.ArtifactTypeValue]; ok {
+		ref["type"] = types.ArtifactTypeValue
+		ref["value"] = value.Data
+		return ref, nil
+	}
+
+	ref["type"] = "text"
+	ref["content"] = fmt.Sprintf("%v", upstream.Output)
+	return ref, nil
+}
+
+// decodeTaskInput 将任务输入解码为结构体，兼容JSON字符串形式的输入
+func decodeTaskInput(input interface{}, v interface{}) error {
+	var data []byte
+	if text, ok := input.(string); ok {
+		data = []byte(text)
+	} else {
+		var err error
+		if data, err = json.Marshal(input); err != nil {
+			return fmt.Errorf("任务输入无法序列化: %w", err)
+		}
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("解析任务输入失败: %w", err)
+	}
+	return nil
+}
+
+// dataSourcePath 返回数据源对应的数据文件：上游任务的数据表引用或直接给出的文件路径，多个数据源时取第一个。
+// datasets 不为空时，直接给出的文件路径解析为当前用户可见的数据视图
+func dataSourcePath(ctx context.Context, datasets types.DatasetResolver, value interface{}) (string, error) {
+	values := dataSourceValues(value)
+	if len(values) == 0 {
+		return "", fmt.Errorf("未指定数据源")
+	}
+
+	var path string
+	switch source := values[0].(type) {
+	case string:
+		path = source
+	case map[string]interface{}:
+		if source["type"] != types.ArtifactTypeDataFrame {
+			return "", fmt.Errorf("任务 %v 未输出数据表", source["task_id"])
+		}
+		path, _ = source["path"].(string)
+	}
+	if path == "" {
+		return "", fmt.Errorf("无法识别的数据源: %v", values[0])
+	}
+	if _, isFile := values[0].(string); isFile && datasets != nil {
+		return datasets.ResolveDataset(ctx, path)
+	}
+	return path, nil
+}
+
+// describeDataSource 生成数据源的文字描述，供专家智能体写入代码生成提示
+func describeDataSource(value interface{}) string {
+	values := dataSourceValues(value)
+	descs := make([]string, 0, len(values))
+
+	for _, v := range values {
+		ref, ok := v.(map[string]interface{})
+		if !ok || ref["task_id"] == nil {
+			descs = append(descs, fmt.Sprintf("%v", v))
+			continue
+		}
+
+		switch ref["type"] {
+		case types.ArtifactTypeDataFrame:
+			descs = append(descs, fmt.Sprintf("任务 %v 输出的数据表（%v 行，列: %v），使用 pd.read_json(%q, orient=\"split\") 加载",
+				ref["task_id"], ref["rows"], ref["columns"], ref["path"]))
+		case types.ArtifactTypeValue:
+			data, _ := json.Marshal(ref["value"])
+			descs = append(descs, fmt.Sprintf("任务 %v 的输出值: %s", ref["task_id"], data))
+		default:
+			descs = append(descs, fmt.Sprintf("任务 %v 的输出:\n%v", ref["task_id"], ref["content"]))
+		}
+
+		if imagePath, ok := ref["image_path"].(string); ok && imagePath != "" {
+			descs = append(descs, fmt.Sprintf("任务 %v 生成的图表: %s", ref["task_id"], imagePath))
+		}
+	}
+
+	return strings.Join(descs, "\n")
+}
@@ -0,0 +1,75 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"smart-analysis/internal/types"
+)
+
+// viewDatasets 将 sales.csv 解析为数据视图，拒绝其他上传文件
+type viewDatasets struct {
+	view string
+}
+
+func (d *viewDatasets) ResolveDataset(ctx context.Context, ref string) (string, error) {
+	switch {
+	case ref == "sales.csv":
+		return d.view, nil
+	case strings.HasPrefix(ref, "uploads/"):
+		return "", errors.New("permission denied")
+	}
+	return ref, nil
+}
+
+func (d *viewDatasets) ResolveUpload(ctx context.Context, ref string) (string, error) {
+	if ref == "sales.csv" || ref == d.view {
+		return d.view, nil
+	}
+	return "", errors.New("permission denied")
+}
+
+func TestDatasetResolution(t *testing.T) {
+	view := filepath.Join(t.TempDir(), "sales.csv")
+	if err := os.WriteFile(view, []byte("region,amount\nEast,100\nEast,50\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	datasets := &viewDatasets{view: view}
+	ctx := context.Background()
+
+	// 计划中的文件类数据源替换为数据视图，上游任务引用不受影响
+	run := newPlanRun(datasets)
+	task := &types.Task{ID: "t2", Dependencies: []string{"t1"}, Input: map[string]interface{}{"data_source": []interface{}{"sales.csv", "t1"}}}
+	previous := map[string]*types.TaskResult{"t1": {Success: true, Output: "ok"}}
+	input, err := run.resolveTaskInput(ctx, task, previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := input.(map[string]interface{})["data_source"].([]interface{})
+	if sources[0] != view || sources[1].(map[string]interface{})["task_id"] != "t1" {
+		t.Errorf("数据源解析不正确: %v", sources)
+	}
+	task.Input = map[string]interface{}{"data_source": "uploads/raw.csv"}
+	if _, err := run.resolveTaskInput(ctx, task, previous); err == nil {
+		t.Error("无权访问的文件应导致任务失败")
+	}
+
+	// 直接调用专家时同样只能读取数据视图
+	chatModel := &scriptedChatModel{responses: []string{"```sql\nSELECT region, SUM(amount) AS total FROM sales GROUP BY region\n```"}}
+	expert, err := NewAgentFactory().CreateExpertAgent(ctx, types.AgentTypeText2SQL, &types.AgentConfig{ChatModel: chatModel, Datasets: datasets})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, _ := expert.ExecuteTask(ctx, &types.Task{Type: "text2sql", Input: map[string]interface{}{"question": "各区域金额", "data_source": "sales.csv"}})
+	if !result.Success || !strings.Contains(result.Output.(string), "| East | 150 |") {
+		t.Errorf("应查询数据视图: %+v", result)
+	}
+	result, _ = expert.ExecuteTask(ctx, &types.Task{Type: "text2sql", Input: map[string]interface{}{"question": "各区域金额", "data_source": "uploads/raw.csv"}})
+	if result.Success || !strings.Contains(result.Error, "permission denied") {
+		t.Errorf("应拒绝读取原始文件: %+v", result)
+	}
+}
@@ -0,0 +1,587 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MetricType 实验指标类型
+type MetricType string
+
+const (
+	MetricBinary     MetricType = "binary"     // 0/1 指标，如是否转化
+	MetricContinuous MetricType = "continuous" // 连续指标，如客单价、停留时长
+)
+
+// TestMethod 显著性检验方法
+type TestMethod string
+
+const (
+	TestZ           TestMethod = "z_test"       // 双比例z检验
+	TestWelch       TestMethod = "welch_t"      // Welch t检验
+	TestMannWhitney TestMethod = "mann_whitney" // Mann-Whitney U检验
+	TestChiSquare   TestMethod = "chi_square"   // 卡方检验
+)
+
+// Correction 多重比较校正方法
+type Correction string
+
+const (
+	CorrectionNone       Correction = "none"
+	CorrectionBonferroni Correction = "bonferroni"
+	CorrectionHolm       Correction = "holm"
+)
+
+// 实验分组的常见列名和对照组名称
+var (
+	variantFieldCandidates = []string{"variant", "group", "bucket", "arm", "treatment", "experiment_group"}
+	controlNameCandidates  = []string{"control", "baseline", "ctrl", "a", "对照组"}
+)
+
+// ABTestOptions 实验分析参数
+type ABTestOptions struct {
+	VariantField string     // 分组字段，默认自动识别 variant、group 等列
+	MetricField  string     // 指标字段
+	Control      string     // 对照组名称，默认识别 control/baseline/A，否则取排序后的第一组
+	MetricType   MetricType // 为空时根据取值自动判断
+	Method       TestMethod // 为空时二值指标使用z检验，连续指标使用Welch t检验
+	Alpha        float64    // 显著性水平，默认0.05
+	Power        float64    // 计算最小可检测效应时的目标功效，默认0.8
+	Correction   Correction // 为空时多组比较使用Holm校正
+}
+
+// VariantStats 实验组的描述统计
+type VariantStats struct {
+	Name   string  `json:"name"`
+	N      int     `json:"n"`
+	Mean   float64 `json:"mean"` // 二值指标为转化率
+	StdDev float64 `json:"std_dev"`
+}
+
+// Comparison 实验组与对照组的比较结果
+type Comparison struct {
+	Variant        string  `json:"variant"`
+	Diff           float64 `json:"diff"`          // 绝对差值（实验组 - 对照组）
+	RelativeLift   float64 `json:"relative_lift"` // 相对提升，对照组均值为0时为0
+	CILower        float64 `json:"ci_lower"`      // 差值的置信区间
+	CIUpper        float64 `json:"ci_upper"`
+	Statistic      float64 `json:"statistic"`
+	PValue         float64 `json:"p_value"`
+	AdjustedPValue float64 `json:"adjusted_p_value"`
+	Significant    bool    `json:"significant"`
+	Power          float64 `json:"power"` // 在当前样本量下检测出观测效应的功效
+	MDE            float64 `json:"mde"`   // 当前样本量下在目标功效下的最小可检测绝对差值
+}
+
+// OmnibusTest 多组整体检验
+type OmnibusTest struct {
+	Method    TestMethod `json:"method"`
+	Statistic float64    `json:"statistic"`
+	DF        int        `json:"df"`
+	PValue    float64    `json:"p_value"`
+}
+
+// ABTestResult 实验分析结果
+type ABTestResult struct {
+	Metric      string         `json:"metric"`
+	MetricType  MetricType     `json:"metric_type"`
+	Method      TestMethod     `json:"method"`
+	Alpha       float64        `json:"alpha"`
+	Power       float64        `json:"target_power"`
+	Correction  Correction     `json:"correction"`
+	Control     string         `json:"control"`
+	Variants    []VariantStats `json:"variants"`
+	Comparisons []Comparison   `json:"comparisons"`
+	Omnibus     *OmnibusTest   `json:"omnibus,omitempty"`
+}
+
+// ABTest 对实验数据做显著性检验：每个实验组分别与对照组比较，计算置信区间、功效和最小可检测效应，
+// 多个实验组时对p值做多重比较校正
+func ABTest(table *Table, opts ABTestOptions) (*ABTestResult, error) {
+	if opts.MetricField == "" {
+		return nil, fmt.Errorf("未指定指标字段")
+	}
+	if opts.VariantField == "" {
+		opts.VariantField = pickColumn(table.Columns, variantFieldCandidates)
+	}
+	variantIdx, metricIdx := table.ColumnIndex(opts.VariantField), table.ColumnIndex(opts.MetricField)
+	if variantIdx < 0 {
+		return nil, fmt.Errorf("找不到分组字段 %q，可用列: %v", opts.VariantField, table.Columns)
+	}
+	if metricIdx < 0 {
+		return nil, fmt.Errorf("找不到指标字段 %q，可用列: %v", opts.MetricField, table.Columns)
+	}
+
+	groups := make(map[string][]float64)
+	for _, row := range table.Rows {
+		if len(row) <= variantIdx || len(row) <= metricIdx {
+			continue
+		}
+		variant := CellString(row[variantIdx])
+		value, ok := CellFloat(row[metricIdx])
+		if variant == "" || !ok {
+			continue
+		}
+		groups[variant] = append(groups[variant], value)
+	}
+	if len(groups) < 2 {
+		return nil, fmt.Errorf("实验至少需要两个分组，当前分组: %d", len(groups))
+	}
+	for name, values := range groups {
+		if len(values) < 2 {
+			return nil, fmt.Errorf("分组 %s 的样本量不足", name)
+		}
+	}
+
+	if err := opts.normalize(groups); err != nil {
+		return nil, err
+	}
+
+	result := &ABTestResult{
+		Metric:     opts.MetricField,
+		MetricType: opts.MetricType,
+		Method:     opts.Method,
+		Alpha:      opts.Alpha,
+		Power:      opts.Power,
+		Correction: opts.Correction,
+		Control:    opts.Control,
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		mean, variance := groupMoments(groups[name], opts.MetricType)
+		result.Variants = append(result.Variants, VariantStats{Name: name, N: len(groups[name]), Mean: mean, StdDev: math.Sqrt(variance)})
+	}
+
+	for _, name := range names {
+		if name == opts.Control {
+			continue
+		}
+		result.Comparisons = append(result.Comparisons, compare(groups[opts.Control], groups[name], name, opts))
+	}
+	adjustPValues(result.Comparisons, opts.Correction, opts.Alpha)
+
+	if opts.MetricType == MetricBinary && len(groups) > 2 {
+		result.Omnibus = chiSquareOmnibus(groups, names)
+	}
+
+	return result, nil
+}
+
+// normalize 补全默认参数并校验指标类型与检验方法是否匹配
+func (o *ABTestOptions) normalize(groups map[string][]float64) error {
+	if o.Alpha <= 0 || o.Alpha >= 1 {
+		o.Alpha = 0.05
+	}
+	if o.Power <= 0 || o.Power >= 1 {
+		o.Power = 0.8
+	}
+
+	if o.Control == "" {
+		o.Control = pickControl(groups)
+	} else if _, ok := groups[o.Control]; !ok {
+		return fmt.Errorf("找不到对照组 %q", o.Control)
+	}
+
+	binary := isBinary(groups)
+	switch o.MetricType {
+	case "":
+		o.MetricType = MetricContinuous
+		if binary {
+			o.MetricType = MetricBinary
+		}
+	case MetricBinary:
+		if !binary {
+			return fmt.Errorf("指标 %s 不是0/1取值，不能按二值指标检验", o.MetricField)
+		}
+	case MetricContinuous:
+	default:
+		return fmt.Errorf("不支持的指标类型: %s", o.MetricType)
+	}
+
+	switch o.Method {
+	case "":
+		o.Method = TestWelch
+		if o.MetricType == MetricBinary {
+			o.Method = TestZ
+		}
+	case TestZ, TestChiSquare:
+		if o.MetricType != MetricBinary {
+			return fmt.Errorf("%s 只适用于二值指标", o.Method)
+		}
+	case TestWelch, TestMannWhitney:
+	default:
+		return fmt.Errorf("不支持的检验方法: %s", o.Method)
+	}
+
+	switch o.Correction {
+	case "":
+		o.Correction = CorrectionNone
+		if len(groups) > 2 {
+			o.Correction = CorrectionHolm
+		}
+	case CorrectionNone, CorrectionBonferroni, CorrectionHolm:
+	default:
+		return fmt.Errorf("不支持的多重比较校正方法: %s", o.Correction)
+	}
+	return nil
+}
+
+// compare 比较一个实验组与对照组
+func compare(control, variant []float64, name string, opts ABTestOptions) Comparison {
+	nc, nv := float64(len(control)), float64(len(variant))
+	mc, vc := groupMoments(control, opts.MetricType)
+	mv, vv := groupMoments(variant, opts.MetricType)
+
+	cmp := Comparison{Variant: name, Diff: mv - mc}
+	if mc != 0 {
+		cmp.RelativeLift = cmp.Diff / mc
+	}
+
+	se := math.Sqrt(vc/nc + vv/nv)
+	zAlpha := normalQuantile(1 - opts.Alpha/2)
+	margin := zAlpha * se
+
+	switch opts.Method {
+	case TestZ, TestChiSquare:
+		pooled := (mc*nc + mv*nv) / (nc + nv)
+		z := zScore(cmp.Diff, math.Sqrt(pooled*(1-pooled)*(1/nc+1/nv)))
+		if opts.Method == TestChiSquare {
+			// 2x2列联表的Pearson卡方统计量等于合并方差z统计量的平方
+			cmp.Statistic = z * z
+			cmp.PValue = chiSquareSurvival(cmp.Statistic, 1)
+		} else {
+			cmp.Statistic = z
+			cmp.PValue = twoSidedNormal(z)
+		}
+	case TestMannWhitney:
+		cmp.Statistic, cmp.PValue = mannWhitney(control, variant)
+	default:
+		t := zScore(cmp.Diff, se)
+		df := welchDF(vc, nc, vv, nv)
+		cmp.Statistic = t
+		cmp.PValue = 1.0
+		if !math.IsNaN(t) {
+			cmp.PValue = studentTTwoSided(t, df)
+		}
+		if se > 0 {
+			margin = studentTQuantile(1-opts.Alpha/2, df) * se
+		}
+	}
+	if math.IsNaN(cmp.Statistic) {
+		cmp.Statistic, cmp.PValue = 0, 1
+	}
+	cmp.CILower, cmp.CIUpper = cmp.Diff-margin, cmp.Diff+margin
+
+	// 功效和最小可检测效应使用正态近似
+	if se > 0 {
+		effect := math.Abs(cmp.Diff) / se
+		cmp.Power = normalCDF(effect-zAlpha) + normalCDF(-effect-zAlpha)
+	}
+	cmp.MDE = (zAlpha + normalQuantile(opts.Power)) * math.Sqrt(vc*(1/nc+1/nv))
+
+	cmp.AdjustedPValue = cmp.PValue
+	return cmp
+}
+
+// adjustPValues 对p值做多重比较校正并判断显著性
+func adjustPValues(comparisons []Comparison, correction Correction, alpha float64) {
+	m := float64(len(comparisons))
+	switch correction {
+	case CorrectionBonferroni:
+		for i := range comparisons {
+			comparisons[i].AdjustedPValue = math.Min(1, comparisons[i].PValue*m)
+		}
+	case CorrectionHolm:
+		order := make([]int, len(comparisons))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return comparisons[order[i]].PValue < comparisons[order[j]].PValue
+		})
+		running := 0.0
+		for rank, idx := range order {
+			adjusted := math.Min(1, comparisons[idx].PValue*(m-float64(rank)))
+			running = math.Max(running, adjusted)
+			comparisons[idx].AdjustedPValue = running
+		}
+	}
+
+	for i := range comparisons {
+		comparisons[i].Significant = comparisons[i].AdjustedPValue < alpha
+	}
+}
+
+// chiSquareOmnibus 二值指标多组的卡方独立性检验
+func chiSquareOmnibus(groups map[string][]float64, names []string) *OmnibusTest {
+	total, successes := 0.0, 0.0
+	for _, name := range names {
+		for _, v := range groups[name] {
+			successes += v
+		}
+		total += float64(len(groups[name]))
+	}
+	rate := successes / total
+
+	stat := 0.0
+	for _, name := range names {
+		n := float64(len(groups[name]))
+		observed := 0.0
+		for _, v := range groups[name] {
+			observed += v
+		}
+		for _, cell := range [][2]float64{{observed, n * rate}, {n - observed, n * (1 - rate)}} {
+			if cell[1] > 0 {
+				stat += (cell[0] - cell[1]) * (cell[0] - cell[1]) / cell[1]
+			}
+		}
+	}
+
+	df := len(names) - 1
+	return &OmnibusTest{Method: TestChiSquare, Statistic: stat, DF: df, PValue: chiSquareSurvival(stat, float64(df))}
+}
+
+// mannWhitney Mann-Whitney U检验（正态近似，含结校正），返回实验组的U统计量和双侧p值
+func mannWhitney(control, variant []float64) (float64, float64) {
+	type sample struct {
+		value   float64
+		variant bool
+	}
+	samples := make([]sample, 0, len(control)+len(variant))
+	for _, v := range control {
+		samples = append(samples, sample{value: v})
+	}
+	for _, v := range variant {
+		samples = append(samples, sample{value: v, variant: true})
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].value < samples[j].value
+	})
+
+	n := float64(len(samples))
+	rankSum, tieTerm := 0.0, 0.0
+	for i := 0; i < len(samples); {
+		j := i
+		for j < len(samples) && samples[j].value == samples[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // 并列值取平均秩
+		for k := i; k < j; k++ {
+			if samples[k].variant {
+				rankSum += rank
+			}
+		}
+		ties := float64(j - i)
+		tieTerm += ties*ties*ties - ties
+		i = j
+	}
+
+	nc, nv := float64(len(control)), float64(len(variant))
+	u := rankSum - nv*(nv+1)/2
+	sigma := math.Sqrt(nc * nv / 12 * ((n + 1) - tieTerm/(n*(n-1))))
+	if sigma == 0 {
+		return u, 1
+	}
+	return u, twoSidedNormal((u - nc*nv/2) / sigma)
+}
+
+// welchDF Welch-Satterthwaite 自由度
+func welchDF(v1, n1, v2, n2 float64) float64 {
+	a, b := v1/n1, v2/n2
+	denominator := a*a/(n1-1) + b*b/(n2-1)
+	if denominator == 0 {
+		return n1 + n2 - 2
+	}
+	return (a + b) * (a + b) / denominator
+}
+
+// zScore 计算标准化统计量，标准误为0时差值为0返回NaN，否则返回无穷大
+func zScore(diff, se float64) float64 {
+	if se > 0 {
+		return diff / se
+	}
+	if diff == 0 {
+		return math.NaN()
+	}
+	return math.Inf(int(math.Copysign(1, diff)))
+}
+
+// twoSidedNormal 标准正态分布的双侧p值
+func twoSidedNormal(z float64) float64 {
+	return 2 * (1 - normalCDF(math.Abs(z)))
+}
+
+// groupMoments 分组的均值和方差，二值指标使用比例的方差 p(1-p)
+func groupMoments(values []float64, metricType MetricType) (float64, float64) {
+	mean, variance := meanAndVariance(values)
+	if metricType == MetricBinary {
+		return mean, mean * (1 - mean)
+	}
+	return mean, variance
+}
+
+// isBinary 判断所有取值是否都为0或1
+func isBinary(groups map[string][]float64) bool {
+	for _, values := range groups {
+		for _, v := range values {
+			if v != 0 && v != 1 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pickControl 识别对照组：优先匹配常见的对照组名称，否则取排序后的第一组
+func pickControl(groups map[string][]float64) string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, candidate := range controlNameCandidates {
+		for _, name := range names {
+			if strings.EqualFold(name, candidate) {
+				return name
+			}
+		}
+	}
+	return names[0]
+}
+
+// Table 将实验结果转换为数据表，对照组的比较字段为空
+func (r *ABTestResult) Table() *Table {
+	table := &Table{Columns: []string{"variant", "n", "mean", "diff", "relative_lift", "ci_lower", "ci_upper",
+		"p_value", "adjusted_p_value", "significant", "power", "mde"}}
+
+	comparisons := make(map[string]Comparison, len(r.Comparisons))
+	for _, cmp := range r.Comparisons {
+		comparisons[cmp.Variant] = cmp
+	}
+	for _, v := range r.Variants {
+		row := []interface{}{v.Name, v.N, v.Mean}
+		if cmp, ok := comparisons[v.Name]; ok {
+			row = append(row, cmp.Diff, cmp.RelativeLift, cmp.CILower, cmp.CIUpper,
+				cmp.PValue, cmp.AdjustedPValue, cmp.Significant, cmp.Power, cmp.MDE)
+		} else {
+			row = append(row, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table
+}
+
+// 检验方法的中文名称
+var testMethodNames = map[TestMethod]string{
+	TestZ:           "双比例z检验",
+	TestWelch:       "Welch t检验",
+	TestMannWhitney: "Mann-Whitney U检验",
+	TestChiSquare:   "卡方检验",
+}
+
+// 多重比较校正方法的显示名称
+var correctionNames = map[Correction]string{
+	CorrectionBonferroni: "Bonferroni",
+	CorrectionHolm:       "Holm",
+}
+
+// Verdict 生成面向业务的文字结论
+func (r *ABTestResult) Verdict() string {
+	metricDesc := "均值"
+	if r.MetricType == MetricBinary {
+		metricDesc = "转化率"
+	}
+	header := fmt.Sprintf("实验指标 %s（%s），对照组 %s，使用%s，显著性水平 α=%g", r.Metric, metricDesc, r.Control, testMethodNames[r.Method], r.Alpha)
+	if r.Correction != CorrectionNone {
+		header += "，p值经 " + correctionNames[r.Correction] + " 多重比较校正"
+	}
+	lines := []string{header + "。"}
+
+	for _, v := range r.Variants {
+		lines = append(lines, fmt.Sprintf("- %s：样本 %d，%s %s", v.Name, v.N, metricDesc, r.formatValue(v.Mean)))
+	}
+
+	var winners, losers []Comparison
+	underpowered := false
+	confidence := fmt.Sprintf("%g%%", (1-r.Alpha)*100)
+	for _, cmp := range r.Comparisons {
+		line := fmt.Sprintf("%s 相比 %s：差值 %s（%s 置信区间 [%s, %s]",
+			cmp.Variant, r.Control, r.formatDiff(cmp.Diff), confidence, r.formatDiff(cmp.CILower), r.formatDiff(cmp.CIUpper))
+		if cmp.RelativeLift != 0 {
+			line += fmt.Sprintf("，相对变化 %+.1f%%", cmp.RelativeLift*100)
+		}
+		line += fmt.Sprintf("），p=%.4f", cmp.PValue)
+		if r.Correction != CorrectionNone {
+			line += fmt.Sprintf("，校正后 p=%.4f", cmp.AdjustedPValue)
+		}
+
+		switch {
+		case cmp.Significant && cmp.Diff > 0:
+			line += "，显著提升。"
+			winners = append(winners, cmp)
+		case cmp.Significant && cmp.Diff < 0:
+			line += "，显著下降。"
+			losers = append(losers, cmp)
+		default:
+			line += fmt.Sprintf("，差异不显著；当前样本量下可检测的最小差值为 %s，观测效应的统计功效为 %.0f%%。",
+				r.formatDiff(cmp.MDE), cmp.Power*100)
+			if cmp.Power < r.Power {
+				underpowered = true
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	if r.Omnibus != nil {
+		lines = append(lines, fmt.Sprintf("多组整体卡方检验：χ²=%.3f，自由度 %d，p=%.4f。", r.Omnibus.Statistic, r.Omnibus.DF, r.Omnibus.PValue))
+	}
+
+	switch {
+	case len(winners) > 0:
+		best := winners[0]
+		for _, cmp := range winners[1:] {
+			if cmp.Diff > best.Diff {
+				best = cmp
+			}
+		}
+		lines = append(lines, fmt.Sprintf("结论：%s 显著优于对照组 %s，提升 %s，建议采用。", best.Variant, r.Control, r.formatDiff(best.Diff)))
+	case len(losers) > 0:
+		names := make([]string, len(losers))
+		for i, cmp := range losers {
+			names[i] = cmp.Variant
+		}
+		lines = append(lines, fmt.Sprintf("结论：没有实验组优于对照组，%s 显著更差，建议保留对照组方案。", strings.Join(names, "、")))
+	default:
+		conclusion := "结论：各实验组与对照组的差异均不显著，暂不能判断优劣。"
+		if underpowered {
+			conclusion += fmt.Sprintf("统计功效低于 %.0f%%，建议扩大样本量或延长实验时间。", r.Power*100)
+		}
+		lines = append(lines, conclusion)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// formatValue 格式化指标值，二值指标显示为百分比
+func (r *ABTestResult) formatValue(v float64) string {
+	if r.MetricType == MetricBinary {
+		return fmt.Sprintf("%.2f%%", v*100)
+	}
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
+
+// formatDiff 格式化差值，二值指标显示为百分点
+func (r *ABTestResult) formatDiff(v float64) string {
+	if r.MetricType == MetricBinary {
+		return fmt.Sprintf("%+.2f个百分点", v*100)
+	}
+	return fmt.Sprintf("%+.4g", v)
+}
@@ -0,0 +1,146 @@
+package analytics
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func approx(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+// conversionTable 按分组和转化人数生成0/1明细
+func conversionTable(groups map[string][2]int) *Table {
+	table := &Table{Columns: []string{"group", "converted"}}
+	for name, counts := range groups {
+		for i := 0; i < counts[0]; i++ {
+			converted := 0
+			if i < counts[1] {
+				converted = 1
+			}
+			table.Rows = append(table.Rows, []interface{}{name, float64(converted)})
+		}
+	}
+	return table
+}
+
+func TestDistributions(t *testing.T) {
+	cases := []struct {
+		name      string
+		got, want float64
+	}{
+		{"t双侧p值", studentTTwoSided(2.0, 10), 0.07339},
+		{"t分位数", studentTQuantile(0.975, 10), 2.22814},
+		{"卡方df=1", chiSquareSurvival(3.841459, 1), 0.05},
+		{"卡方df=2", chiSquareSurvival(5.991465, 2), 0.05},
+		{"正态分位数", normalQuantile(0.975), 1.95996},
+	}
+	for _, c := range cases {
+		if !approx(c.got, c.want, 1e-4) {
+			t.Errorf("%s: 期望 %v，实际 %v", c.name, c.want, c.got)
+		}
+	}
+}
+
+func TestABTest_Proportions(t *testing.T) {
+	table := conversionTable(map[string][2]int{"control": {1000, 200}, "B": {1000, 240}})
+
+	result, err := ABTest(table, ABTestOptions{MetricField: "converted"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MetricType != MetricBinary || result.Method != TestZ || result.Control != "control" || result.Correction != CorrectionNone {
+		t.Fatalf("默认参数不正确: %+v", result)
+	}
+
+	cmp := result.Comparisons[0]
+	if !approx(cmp.Statistic, 2.1592, 1e-3) || !approx(cmp.PValue, 0.0308, 1e-3) || !cmp.Significant {
+		t.Errorf("z检验结果不正确: %+v", cmp)
+	}
+	if !approx(cmp.RelativeLift, 0.2, 1e-9) || cmp.CILower <= 0 || cmp.CIUpper <= cmp.Diff {
+		t.Errorf("提升或置信区间不正确: %+v", cmp)
+	}
+	if cmp.MDE <= 0 || cmp.Power <= 0.5 || cmp.Power >= 1 {
+		t.Errorf("功效或最小可检测效应不正确: %+v", cmp)
+	}
+	if verdict := result.Verdict(); !strings.Contains(verdict, "结论：B 显著优于对照组 control") {
+		t.Errorf("结论不正确:\n%s", verdict)
+	}
+
+	// 卡方检验与z检验的p值一致
+	chi, err := ABTest(table, ABTestOptions{MetricField: "converted", Method: TestChiSquare})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approx(chi.Comparisons[0].PValue, cmp.PValue, 1e-6) {
+		t.Errorf("卡方检验p值 %v 与z检验 %v 不一致", chi.Comparisons[0].PValue, cmp.PValue)
+	}
+
+	if _, err := ABTest(table, ABTestOptions{MetricField: "converted", Control: "A"}); err == nil {
+		t.Error("对照组不存在时应返回错误")
+	}
+}
+
+func TestABTest_MultipleVariants(t *testing.T) {
+	table := conversionTable(map[string][2]int{"A": {500, 100}, "B": {500, 105}, "C": {500, 130}})
+
+	result, err := ABTest(table, ABTestOptions{MetricField: "converted"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Control != "A" || result.Correction != CorrectionHolm || result.Omnibus == nil || result.Omnibus.DF != 2 {
+		t.Fatalf("多组实验参数不正确: %+v", result)
+	}
+	for _, cmp := range result.Comparisons {
+		if cmp.AdjustedPValue < cmp.PValue {
+			t.Errorf("校正后的p值不应变小: %+v", cmp)
+		}
+	}
+}
+
+func TestAdjustPValues_Holm(t *testing.T) {
+	comparisons := []Comparison{{PValue: 0.01}, {PValue: 0.04}, {PValue: 0.03}}
+	adjustPValues(comparisons, CorrectionHolm, 0.05)
+
+	want := []float64{0.03, 0.06, 0.06}
+	for i, cmp := range comparisons {
+		if !approx(cmp.AdjustedPValue, want[i], 1e-9) {
+			t.Errorf("第%d个校正p值期望 %v，实际 %v", i, want[i], cmp.AdjustedPValue)
+		}
+	}
+	if !comparisons[0].Significant || comparisons[1].Significant {
+		t.Errorf("显著性判断不正确: %+v", comparisons)
+	}
+}
+
+func TestABTest_Continuous(t *testing.T) {
+	table := &Table{Columns: []string{"variant", "revenue"}}
+	for _, v := range []float64{1, 2, 3} {
+		table.Rows = append(table.Rows, []interface{}{"control", v})
+	}
+	for _, v := range []string{"4", "5", "6"} {
+		table.Rows = append(table.Rows, []interface{}{"test", v})
+	}
+
+	welch, err := ABTest(table, ABTestOptions{MetricField: "revenue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// t = 3 / sqrt(1/3 + 1/3) = 3.674，df = 4
+	if welch.Method != TestWelch || !approx(welch.Comparisons[0].Statistic, 3.6742, 1e-3) || !approx(welch.Comparisons[0].PValue, 0.02131, 1e-4) {
+		t.Errorf("Welch t检验结果不正确: %+v", welch.Comparisons[0])
+	}
+
+	mw, err := ABTest(table, ABTestOptions{MetricField: "revenue", Method: TestMannWhitney})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmp := mw.Comparisons[0]; cmp.Statistic != 9 || !approx(cmp.PValue, 0.0495, 1e-3) {
+		t.Errorf("Mann-Whitney检验结果不正确: %+v", cmp)
+	}
+
+	if _, err := ABTest(table, ABTestOptions{MetricField: "revenue", Method: TestZ}); err == nil {
+		t.Error("连续指标不应允许z检验")
+	}
+}
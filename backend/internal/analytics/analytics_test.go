@@ -0,0 +1,126 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sampleEvents 三个用户的注册、浏览和下单事件，2024-01-01 为周一
+func sampleEvents(t *testing.T) []Event {
+	t.Helper()
+	csv := `user_id,event,event_time
+u1,signup,2024-01-01 09:00:00
+u1,view,2024-01-01 09:05:00
+u1,order,2024-01-01 10:00:00
+u1,view,2024-01-09 08:00:00
+u2,signup,2024-01-02
+u2,view,2024-01-02 12:00:00
+u2,view,2024-01-02 12:01:00
+u2,order,2024-01-05
+u3,signup,1704700800
+u3,view,1704787200000
+u3,,2024-01-10
+,view,2024-01-10
+`
+	path := filepath.Join(t.TempDir(), "events.csv")
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := LoadTable(path)
+	if err != nil {
+		t.Fatalf("读取事件表失败: %v", err)
+	}
+	events, err := table.Events(FieldMapping{})
+	if err != nil {
+		t.Fatalf("解析事件失败: %v", err)
+	}
+	return events
+}
+
+func TestRetention(t *testing.T) {
+	events := sampleEvents(t)
+	if len(events) != 10 {
+		t.Fatalf("期望10条有效事件（缺少用户或事件名的行应跳过），实际 %d", len(events))
+	}
+
+	m, err := Retention(events, RetentionOptions{Period: PeriodWeek, CohortEvent: "signup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.Cohorts, []string{"2024-01-01", "2024-01-08"}) || !reflect.DeepEqual(m.Sizes, []int{2, 1}) {
+		t.Fatalf("同期群不正确: %v %v", m.Cohorts, m.Sizes)
+	}
+	// 第一周的同期群可以观测两周，u1在第二周回访；第二周的同期群只能观测一周
+	if !reflect.DeepEqual(m.Counts, [][]int{{2, 1}, {1}}) {
+		t.Errorf("留存人数不正确: %v", m.Counts)
+	}
+	if !reflect.DeepEqual(m.Rates, [][]float64{{1, 0.5}, {1}}) {
+		t.Errorf("留存率不正确: %v", m.Rates)
+	}
+	if rate, ok := m.AverageRate(1); !ok || rate != 0.5 {
+		t.Errorf("第1期平均留存不正确: %v %v", rate, ok)
+	}
+
+	table := m.Table()
+	if len(table.Columns) != 4 || table.Rows[1][3] != nil {
+		t.Errorf("留存表不正确: %v %v", table.Columns, table.Rows)
+	}
+}
+
+func TestFunnel(t *testing.T) {
+	events := sampleEvents(t)
+
+	f, err := Funnel(events, FunnelOptions{Steps: []string{"signup", "view", "order"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []int{f.Steps[0].Users, f.Steps[1].Users, f.Steps[2].Users}
+	if !reflect.DeepEqual(got, []int{3, 3, 2}) {
+		t.Errorf("漏斗人数不正确: %v", got)
+	}
+	if f.Steps[2].Conversion != 0.6667 || f.Steps[2].DropOff != 1 {
+		t.Errorf("转化率不正确: %+v", f.Steps[2])
+	}
+
+	// 一天的窗口内u2未下单
+	f, err = Funnel(events, FunnelOptions{Steps: []string{"signup", "view", "order"}, Window: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Steps[2].Users != 1 {
+		t.Errorf("窗口内下单人数应为1，实际 %d", f.Steps[2].Users)
+	}
+
+	if _, err := Funnel(events, FunnelOptions{Steps: []string{"signup"}}); err == nil {
+		t.Error("单步漏斗应返回错误")
+	}
+}
+
+func TestPaths(t *testing.T) {
+	events := sampleEvents(t)
+
+	p, err := Paths(events, PathOptions{StartEvent: "signup", Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Users != 3 {
+		t.Fatalf("起点用户数不正确: %d", p.Users)
+	}
+	// 连续重复的 view 合并为一步
+	if !reflect.DeepEqual(p.Paths[0].Path, []string{"signup", "view", "order"}) || p.Paths[0].Users != 2 {
+		t.Errorf("最常见路径不正确: %+v", p.Paths[0])
+	}
+	if p.Links[0] != (PathLink{Source: "1. signup", Target: "2. view", Value: 3}) {
+		t.Errorf("路径流量不正确: %+v", p.Links)
+	}
+
+	chart := PathSankey(p, "用户路径")
+	series := chart["series"].([]interface{})[0].(map[string]interface{})
+	if len(series["data"].([]interface{})) != 3 {
+		t.Errorf("桑基图节点数不正确: %v", series["data"])
+	}
+}
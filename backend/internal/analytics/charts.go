@@ -0,0 +1,98 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+)
+
+// RetentionHeatmap 生成留存矩阵的ECharts热力图配置，数值为百分比
+func RetentionHeatmap(m *RetentionMatrix, title string) map[string]interface{} {
+	width := 0
+	for _, rates := range m.Rates {
+		width = max(width, len(rates))
+	}
+
+	xAxis := make([]string, width)
+	for k := range xAxis {
+		xAxis[k] = fmt.Sprintf("第%d%s", k, m.Period.Unit())
+	}
+
+	yAxis := make([]string, len(m.Cohorts))
+	data := make([][]interface{}, 0)
+	for i, cohort := range m.Cohorts {
+		yAxis[i] = fmt.Sprintf("%s (%d)", cohort, m.Sizes[i])
+		for k, rate := range m.Rates[i] {
+			data = append(data, []interface{}{k, i, percent(rate)})
+		}
+	}
+
+	return map[string]interface{}{
+		"title":   map[string]interface{}{"text": title},
+		"tooltip": map[string]interface{}{"position": "top"},
+		"xAxis":   map[string]interface{}{"type": "category", "data": xAxis, "splitArea": map[string]interface{}{"show": true}},
+		"yAxis":   map[string]interface{}{"type": "category", "data": yAxis, "inverse": true, "splitArea": map[string]interface{}{"show": true}},
+		"visualMap": map[string]interface{}{
+			"min": 0, "max": 100, "calculable": true, "orient": "horizontal", "left": "center", "bottom": 0,
+		},
+		"series": []interface{}{map[string]interface{}{
+			"name":  "留存率(%)",
+			"type":  "heatmap",
+			"data":  data,
+			"label": map[string]interface{}{"show": true},
+		}},
+	}
+}
+
+// FunnelChart 生成漏斗的ECharts漏斗图配置
+func FunnelChart(f *FunnelResult, title string) map[string]interface{} {
+	data := make([]interface{}, len(f.Steps))
+	for i, step := range f.Steps {
+		data[i] = map[string]interface{}{"name": step.Event, "value": step.Users}
+	}
+
+	return map[string]interface{}{
+		"title":   map[string]interface{}{"text": title},
+		"tooltip": map[string]interface{}{"trigger": "item", "formatter": "{b}: {c}"},
+		"series": []interface{}{map[string]interface{}{
+			"name":  "用户数",
+			"type":  "funnel",
+			"sort":  "none",
+			"data":  data,
+			"label": map[string]interface{}{"show": true, "position": "inside"},
+		}},
+	}
+}
+
+// PathSankey 生成用户路径的ECharts桑基图配置
+func PathSankey(p *PathResult, title string) map[string]interface{} {
+	seen := make(map[string]bool)
+	nodes := make([]interface{}, 0)
+	links := make([]interface{}, 0, len(p.Links))
+	addNode := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			nodes = append(nodes, map[string]interface{}{"name": name})
+		}
+	}
+	for _, link := range p.Links {
+		addNode(link.Source)
+		addNode(link.Target)
+		links = append(links, map[string]interface{}{"source": link.Source, "target": link.Target, "value": link.Value})
+	}
+
+	return map[string]interface{}{
+		"title":   map[string]interface{}{"text": title},
+		"tooltip": map[string]interface{}{"trigger": "item"},
+		"series": []interface{}{map[string]interface{}{
+			"type":     "sankey",
+			"data":     nodes,
+			"links":    links,
+			"emphasis": map[string]interface{}{"focus": "adjacency"},
+		}},
+	}
+}
+
+// percent 将比例转换为保留一位小数的百分数
+func percent(rate float64) float64 {
+	return math.Round(rate*1000) / 10
+}
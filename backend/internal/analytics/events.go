@@ -0,0 +1,363 @@
+// Package analytics 基于用户行为事件的确定性分析：同期群留存、漏斗转化和用户路径。
+// 结果只取决于输入数据，不经过LLM，可直接用于图表和复核
+package analytics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"smart-analysis/internal/utils"
+)
+
+// Event 用户行为事件
+type Event struct {
+	User string
+	Name string
+	Time time.Time
+}
+
+// Table 二维数据表
+type Table struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// LoadTable 读取数据文件，支持CSV、Excel（第一个工作表）以及pandas导出的 split/records 格式JSON
+func LoadTable(path string) (*Table, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return loadCSV(path)
+	case ".json":
+		return loadJSON(path)
+	case ".xlsx":
+		return loadExcel(path)
+	default:
+		return nil, fmt.Errorf("不支持的数据文件格式: %s", path)
+	}
+}
+
+// loadCSV 读取带表头的CSV文件
+func loadCSV(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析CSV失败: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("CSV文件为空: %s", path)
+	}
+
+	return &Table{Columns: records[0], Rows: stringRows(records[1:])}, nil
+}
+
+// loadExcel 读取Excel文件的第一个工作表，首行为表头
+func loadExcel(path string) (*Table, error) {
+	data, err := utils.ParseExcel(path)
+	if err != nil {
+		return nil, fmt.Errorf("解析Excel失败: %w", err)
+	}
+
+	return &Table{Columns: data.Headers, Rows: stringRows(data.Rows)}, nil
+}
+
+// stringRows 将字符串记录转换为数据表的行
+func stringRows(records [][]string) [][]interface{} {
+	rows := make([][]interface{}, 0, len(records))
+	for _, record := range records {
+		row := make([]interface{}, len(record))
+		for i, value := range record {
+			row[i] = value
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// loadJSON 读取 split（{"columns","data"}）或 records（[{...}]）格式的JSON文件
+func loadJSON(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var frame map[string]interface{}
+	if err := json.Unmarshal(data, &frame); err == nil {
+		return TableFromFrame(frame)
+	}
+
+	var records []map[string]interface{}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("无法识别的JSON数据格式: %s", path)
+	}
+
+	columnSet := make(map[string]bool)
+	var columns []string
+	for _, record := range records {
+		for key := range record {
+			if !columnSet[key] {
+				columnSet[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+	sort.Strings(columns)
+
+	table := &Table{Columns: columns, Rows: make([][]interface{}, 0, len(records))}
+	for _, record := range records {
+		row := make([]interface{}, len(columns))
+		for i, column := range columns {
+			row[i] = record[column]
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table, nil
+}
+
+// TableFromFrame 从 split 格式的数据表（任务产物中的dataframe）构建Table
+func TableFromFrame(frame map[string]interface{}) (*Table, error) {
+	rawColumns, ok := frame["columns"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("数据表缺少 columns 字段")
+	}
+	rawRows, _ := frame["data"].([]interface{})
+
+	table := &Table{Columns: make([]string, len(rawColumns)), Rows: make([][]interface{}, 0, len(rawRows))}
+	for i, column := range rawColumns {
+		table.Columns[i] = fmt.Sprintf("%v", column)
+	}
+	for _, rawRow := range rawRows {
+		row, ok := rawRow.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("数据表的行格式不正确")
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table, nil
+}
+
+// Frame 将数据表转换为 split 格式（{"columns","index","data"}），与任务产物中的dataframe一致
+func (t *Table) Frame() map[string]interface{} {
+	columns := make([]interface{}, len(t.Columns))
+	for i, column := range t.Columns {
+		columns[i] = column
+	}
+	index := make([]interface{}, len(t.Rows))
+	data := make([]interface{}, len(t.Rows))
+	for i, row := range t.Rows {
+		index[i] = i
+		data[i] = row
+	}
+
+	return map[string]interface{}{
+		"columns": columns,
+		"index":   index,
+		"data":    data,
+	}
+}
+
+// FieldMapping 事件表中用户、事件名和时间所在的列
+type FieldMapping struct {
+	User  string `json:"user_field"`
+	Event string `json:"event_field"`
+	Time  string `json:"time_field"`
+}
+
+// 常见的列名，未指定字段时按顺序匹配
+var (
+	userFieldCandidates  = []string{"user_id", "uid", "distinct_id", "userid", "user", "customer_id", "account_id"}
+	eventFieldCandidates = []string{"event", "event_name", "event_type", "action", "name"}
+	timeFieldCandidates  = []string{"event_time", "timestamp", "time", "ts", "created_at", "date", "datetime"}
+)
+
+// Events 将数据表转换为事件列表，按用户和时间排序。
+// 未指定的字段按常见列名推断；缺少用户、事件名或时间无法解析的行会被跳过
+func (t *Table) Events(fields FieldMapping) ([]Event, error) {
+	fields = t.inferFields(fields)
+
+	userIdx, eventIdx, timeIdx := t.ColumnIndex(fields.User), t.ColumnIndex(fields.Event), t.ColumnIndex(fields.Time)
+	switch {
+	case userIdx < 0:
+		return nil, fmt.Errorf("找不到用户字段 %q，可用列: %v", fields.User, t.Columns)
+	case eventIdx < 0:
+		return nil, fmt.Errorf("找不到事件字段 %q，可用列: %v", fields.Event, t.Columns)
+	case timeIdx < 0:
+		return nil, fmt.Errorf("找不到时间字段 %q，可用列: %v", fields.Time, t.Columns)
+	}
+
+	events := make([]Event, 0, len(t.Rows))
+	for _, row := range t.Rows {
+		if len(row) <= userIdx || len(row) <= eventIdx || len(row) <= timeIdx {
+			continue
+		}
+		user, name := CellString(row[userIdx]), CellString(row[eventIdx])
+		ts, ok := ParseTime(row[timeIdx])
+		if user == "" || name == "" || !ok {
+			continue
+		}
+		events = append(events, Event{User: user, Name: name, Time: ts})
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("数据中没有有效的事件记录")
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].User != events[j].User {
+			return events[i].User < events[j].User
+		}
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events, nil
+}
+
+// inferFields 补全未指定的字段
+func (t *Table) inferFields(fields FieldMapping) FieldMapping {
+	pick := func(current string, candidates []string) string {
+		if current != "" {
+			return current
+		}
+		return pickColumn(t.Columns, candidates)
+	}
+
+	return FieldMapping{
+		User:  pick(fields.User, userFieldCandidates),
+		Event: pick(fields.Event, eventFieldCandidates),
+		Time:  pick(fields.Time, timeFieldCandidates),
+	}
+}
+
+// ColumnIndex 返回列的位置，不存在时返回-1
+func (t *Table) ColumnIndex(name string) int {
+	for i, column := range t.Columns {
+		if column == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// pickColumn 按候选列名的顺序匹配列，匹配不到时返回第一个候选名
+func pickColumn(columns []string, candidates []string) string {
+	for _, candidate := range candidates {
+		for _, column := range columns {
+			if strings.EqualFold(column, candidate) {
+				return column
+			}
+		}
+	}
+	return candidates[0]
+}
+
+// groupByUser 将已按用户排序的事件按用户分组
+func groupByUser(events []Event) [][]Event {
+	var groups [][]Event
+	start := 0
+	for i := 1; i <= len(events); i++ {
+		if i == len(events) || events[i].User != events[start].User {
+			groups = append(groups, events[start:i])
+			start = i
+		}
+	}
+	return groups
+}
+
+// CellString 将单元格转换为字符串，整数形式的浮点数不带小数部分
+func CellString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		if v == math.Trunc(v) {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// CellFloat 将单元格转换为数值，布尔值视为0/1
+func CellFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, !math.IsNaN(v)
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		s := strings.TrimSpace(v)
+		switch strings.ToLower(s) {
+		case "true":
+			return 1, true
+		case "false":
+			return 0, true
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil && !math.IsNaN(f)
+	}
+	return 0, false
+}
+
+// 支持的时间格式
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+}
+
+// ParseTime 解析时间，支持常见字符串格式以及秒或毫秒级的Unix时间戳
+func ParseTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC(), true
+	case float64:
+		return unixTime(v), true
+	case int64:
+		return unixTime(float64(v)), true
+	case int:
+		return unixTime(float64(v)), true
+	case string:
+		s := strings.TrimSpace(v)
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t.UTC(), true
+			}
+		}
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return unixTime(n), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// unixTime 将时间戳转换为时间，大于1e11的值视为毫秒
+func unixTime(ts float64) time.Time {
+	if math.Abs(ts) > 1e11 {
+		return time.UnixMilli(int64(ts)).UTC()
+	}
+	return time.Unix(int64(ts), 0).UTC()
+}
@@ -0,0 +1,106 @@
+package analytics
+
+import (
+	"fmt"
+	"time"
+)
+
+// FunnelOptions 漏斗分析参数
+type FunnelOptions struct {
+	Steps  []string      // 按顺序排列的事件
+	Window time.Duration // 从第一步开始完成后续步骤的时间窗口，0表示不限
+}
+
+// FunnelStep 漏斗中的一步
+type FunnelStep struct {
+	Event      string  `json:"event"`
+	Users      int     `json:"users"`
+	Conversion float64 `json:"conversion"` // 相对上一步的转化率
+	Overall    float64 `json:"overall"`    // 相对第一步的转化率
+	DropOff    int     `json:"drop_off"`   // 相对上一步流失的用户数
+}
+
+// FunnelResult 漏斗分析结果
+type FunnelResult struct {
+	Steps  []FunnelStep `json:"steps"`
+	Window string       `json:"window,omitempty"`
+}
+
+// Funnel 计算有序漏斗：用户在窗口内按顺序依次完成的最深步骤计入该步及之前所有步骤
+func Funnel(events []Event, opts FunnelOptions) (*FunnelResult, error) {
+	if len(opts.Steps) < 2 {
+		return nil, fmt.Errorf("漏斗至少需要两个步骤")
+	}
+
+	reached := make([]int, len(opts.Steps))
+	for _, userEvents := range groupByUser(events) {
+		depth := funnelDepth(userEvents, opts)
+		for i := 0; i < depth; i++ {
+			reached[i]++
+		}
+	}
+	if reached[0] == 0 {
+		return nil, fmt.Errorf("没有用户发生过漏斗的第一步 %q", opts.Steps[0])
+	}
+
+	result := &FunnelResult{Steps: make([]FunnelStep, len(opts.Steps))}
+	if opts.Window > 0 {
+		result.Window = formatWindow(opts.Window)
+	}
+	for i, event := range opts.Steps {
+		step := FunnelStep{Event: event, Users: reached[i], Conversion: 1, Overall: ratio(reached[i], reached[0])}
+		if i > 0 {
+			step.Conversion = ratio(reached[i], reached[i-1])
+			step.DropOff = reached[i-1] - reached[i]
+		}
+		result.Steps[i] = step
+	}
+	return result, nil
+}
+
+// funnelDepth 返回用户在任一起点开始、窗口内按顺序完成的最多步骤数
+func funnelDepth(userEvents []Event, opts FunnelOptions) int {
+	best := 0
+	for start, e := range userEvents {
+		if e.Name != opts.Steps[0] {
+			continue
+		}
+
+		depth := 1
+		for _, next := range userEvents[start+1:] {
+			if depth == len(opts.Steps) {
+				break
+			}
+			if opts.Window > 0 && next.Time.Sub(e.Time) > opts.Window {
+				break
+			}
+			if next.Name == opts.Steps[depth] {
+				depth++
+			}
+		}
+
+		best = max(best, depth)
+		if best == len(opts.Steps) {
+			break
+		}
+	}
+	return best
+}
+
+// formatWindow 格式化转化窗口，整天数显示为 7d 的形式
+func formatWindow(window time.Duration) string {
+	day := 24 * time.Hour
+	if window%day == 0 {
+		return fmt.Sprintf("%dd", window/day)
+	}
+	return window.String()
+}
+
+// Table 将漏斗结果转换为数据表
+func (f *FunnelResult) Table() *Table {
+	table := &Table{Columns: []string{"step", "event", "users", "conversion", "overall_conversion", "drop_off"}}
+	for i, step := range f.Steps {
+		table.Rows = append(table.Rows, []interface{}{i + 1, step.Event, step.Users, step.Conversion, step.Overall, step.DropOff})
+	}
+	return table
+}
@@ -0,0 +1,140 @@
+package analytics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// pathSeparator 路径中事件之间的分隔符
+const pathSeparator = " → "
+
+// PathOptions 用户路径分析参数
+type PathOptions struct {
+	StartEvent string // 路径起点事件，为空时从用户的首个事件开始
+	Depth      int    // 路径包含的事件数（含起点），默认4
+	TopN       int    // 返回的最常见路径数，默认10
+}
+
+// PathCount 一条路径及经过的用户数
+type PathCount struct {
+	Path  []string `json:"path"`
+	Users int      `json:"users"`
+	Share float64  `json:"share"` // 占全部起点用户的比例
+}
+
+// PathLink 路径图中相邻两步之间的流量，节点名称带步骤序号以保证图无环
+type PathLink struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Value  int    `json:"value"`
+}
+
+// PathResult 用户路径分析结果
+type PathResult struct {
+	StartEvent string      `json:"start_event,omitempty"`
+	Users      int         `json:"users"`
+	Paths      []PathCount `json:"paths"`
+	Links      []PathLink  `json:"links"`
+}
+
+// Paths 统计用户从起点事件出发的后续行为路径，连续重复的事件合并为一步
+func Paths(events []Event, opts PathOptions) (*PathResult, error) {
+	depth := opts.Depth
+	if depth <= 0 {
+		depth = 4
+	}
+	topN := opts.TopN
+	if topN <= 0 {
+		topN = 10
+	}
+
+	pathCounts := make(map[string]*PathCount)
+	linkValues := make(map[[2]string]int)
+	users := 0
+
+	for _, userEvents := range groupByUser(events) {
+		path := userPath(userEvents, opts.StartEvent, depth)
+		if len(path) == 0 {
+			continue
+		}
+		users++
+		key := strings.Join(path, pathSeparator)
+		if _, ok := pathCounts[key]; !ok {
+			pathCounts[key] = &PathCount{Path: path}
+		}
+		pathCounts[key].Users++
+		for i := 1; i < len(path); i++ {
+			linkValues[[2]string{pathNode(i-1, path[i-1]), pathNode(i, path[i])}]++
+		}
+	}
+	if users == 0 {
+		return nil, fmt.Errorf("没有用户发生过起点事件 %q", opts.StartEvent)
+	}
+
+	result := &PathResult{StartEvent: opts.StartEvent, Users: users}
+	for _, count := range pathCounts {
+		count.Share = ratio(count.Users, users)
+		result.Paths = append(result.Paths, *count)
+	}
+	sort.Slice(result.Paths, func(i, j int) bool {
+		if result.Paths[i].Users != result.Paths[j].Users {
+			return result.Paths[i].Users > result.Paths[j].Users
+		}
+		return strings.Join(result.Paths[i].Path, pathSeparator) < strings.Join(result.Paths[j].Path, pathSeparator)
+	})
+	if len(result.Paths) > topN {
+		result.Paths = result.Paths[:topN]
+	}
+
+	for key, value := range linkValues {
+		result.Links = append(result.Links, PathLink{Source: key[0], Target: key[1], Value: value})
+	}
+	sort.Slice(result.Links, func(i, j int) bool {
+		if result.Links[i].Source != result.Links[j].Source {
+			return result.Links[i].Source < result.Links[j].Source
+		}
+		return result.Links[i].Target < result.Links[j].Target
+	})
+
+	return result, nil
+}
+
+// userPath 截取用户从起点事件开始的路径
+func userPath(userEvents []Event, startEvent string, depth int) []string {
+	start := -1
+	for i, e := range userEvents {
+		if startEvent == "" || e.Name == startEvent {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	path := []string{userEvents[start].Name}
+	for _, e := range userEvents[start+1:] {
+		if len(path) == depth {
+			break
+		}
+		if e.Name != path[len(path)-1] {
+			path = append(path, e.Name)
+		}
+	}
+	return path
+}
+
+// pathNode 路径图的节点名称
+func pathNode(step int, event string) string {
+	return fmt.Sprintf("%d. %s", step+1, event)
+}
+
+// Table 将最常见路径转换为数据表
+func (p *PathResult) Table() *Table {
+	table := &Table{Columns: []string{"path", "steps", "users", "share"}}
+	for _, path := range p.Paths {
+		table.Rows = append(table.Rows, []interface{}{strings.Join(path.Path, pathSeparator), len(path.Path), path.Users, path.Share})
+	}
+	return table
+}
@@ -0,0 +1,227 @@
+package analytics
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Period 同期群的时间粒度
+type Period string
+
+const (
+	PeriodDay   Period = "day"
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+)
+
+// ParsePeriod 解析时间粒度，空值默认按周
+func ParsePeriod(s string) (Period, error) {
+	switch Period(s) {
+	case "":
+		return PeriodWeek, nil
+	case PeriodDay, PeriodWeek, PeriodMonth:
+		return Period(s), nil
+	default:
+		return "", fmt.Errorf("不支持的时间粒度: %s，可选 day/week/month", s)
+	}
+}
+
+// start 返回时间所在周期的起点（UTC），周从周一开始
+func (p Period) start(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch p {
+	case PeriodWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case PeriodMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// between 返回两个周期起点之间相隔的周期数
+func (p Period) between(from, to time.Time) int {
+	switch p {
+	case PeriodWeek:
+		return int(to.Sub(from).Hours()/24) / 7
+	case PeriodMonth:
+		return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+	default:
+		return int(to.Sub(from).Hours() / 24)
+	}
+}
+
+// label 返回周期起点的显示名称
+func (p Period) label(t time.Time) string {
+	if p == PeriodMonth {
+		return t.Format("2006-01")
+	}
+	return t.Format("2006-01-02")
+}
+
+// Unit 返回周期的中文单位
+func (p Period) Unit() string {
+	switch p {
+	case PeriodDay:
+		return "天"
+	case PeriodMonth:
+		return "月"
+	default:
+		return "周"
+	}
+}
+
+// RetentionOptions 留存分析参数
+type RetentionOptions struct {
+	Period      Period
+	Periods     int    // 计算的周期数（含第0期），默认8
+	CohortEvent string // 进入同期群的事件，为空时以用户的首个事件为准
+	ReturnEvent string // 视为留存的事件，为空时任意事件都算
+}
+
+// RetentionMatrix 同期群留存矩阵。第i个同期群只统计数据覆盖到的周期，因此各行长度可能不同
+type RetentionMatrix struct {
+	Period  Period      `json:"period"`
+	Cohorts []string    `json:"cohorts"`
+	Sizes   []int       `json:"sizes"`
+	Counts  [][]int     `json:"counts"`
+	Rates   [][]float64 `json:"rates"`
+}
+
+// Retention 计算同期群留存：按用户首次发生 CohortEvent 的周期分组，
+// 统计此后第k个周期发生 ReturnEvent 的用户数
+func Retention(events []Event, opts RetentionOptions) (*RetentionMatrix, error) {
+	period, err := ParsePeriod(string(opts.Period))
+	if err != nil {
+		return nil, err
+	}
+	periods := opts.Periods
+	if periods <= 0 {
+		periods = 8
+	}
+
+	// 数据覆盖的最后一个周期，决定每个同期群可观测的周期数
+	var last time.Time
+	for _, e := range events {
+		if e.Time.After(last) {
+			last = e.Time
+		}
+	}
+	lastStart := period.start(last)
+
+	type cohortStat struct {
+		size   int
+		counts []int
+	}
+	cohorts := make(map[time.Time]*cohortStat)
+
+	for _, userEvents := range groupByUser(events) {
+		var cohortStart time.Time
+		found := false
+		for _, e := range userEvents {
+			if opts.CohortEvent == "" || e.Name == opts.CohortEvent {
+				cohortStart = period.start(e.Time)
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		stat, ok := cohorts[cohortStart]
+		if !ok {
+			observed := min(periods, period.between(cohortStart, lastStart)+1)
+			stat = &cohortStat{counts: make([]int, observed)}
+			cohorts[cohortStart] = stat
+		}
+		stat.size++
+
+		active := make(map[int]bool)
+		for _, e := range userEvents {
+			if opts.ReturnEvent != "" && e.Name != opts.ReturnEvent {
+				continue
+			}
+			k := period.between(cohortStart, period.start(e.Time))
+			if k >= 0 && k < len(stat.counts) && !active[k] {
+				active[k] = true
+				stat.counts[k]++
+			}
+		}
+	}
+	if len(cohorts) == 0 {
+		return nil, fmt.Errorf("没有用户发生过事件 %q", opts.CohortEvent)
+	}
+
+	starts := make([]time.Time, 0, len(cohorts))
+	for start := range cohorts {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i].Before(starts[j])
+	})
+
+	matrix := &RetentionMatrix{Period: period}
+	for _, start := range starts {
+		stat := cohorts[start]
+		rates := make([]float64, len(stat.counts))
+		for k, count := range stat.counts {
+			rates[k] = ratio(count, stat.size)
+		}
+		matrix.Cohorts = append(matrix.Cohorts, period.label(start))
+		matrix.Sizes = append(matrix.Sizes, stat.size)
+		matrix.Counts = append(matrix.Counts, stat.counts)
+		matrix.Rates = append(matrix.Rates, rates)
+	}
+	return matrix, nil
+}
+
+// AverageRate 返回第k期留存率按同期群人数加权的平均值，没有同期群覆盖该周期时返回false
+func (m *RetentionMatrix) AverageRate(k int) (float64, bool) {
+	users, retained := 0, 0
+	for i, counts := range m.Counts {
+		if k < len(counts) {
+			users += m.Sizes[i]
+			retained += counts[k]
+		}
+	}
+	if users == 0 {
+		return 0, false
+	}
+	return ratio(retained, users), true
+}
+
+// Table 将留存矩阵转换为数据表，period_k 列为第k期留存率，未覆盖的周期为空
+func (m *RetentionMatrix) Table() *Table {
+	width := 0
+	for _, rates := range m.Rates {
+		width = max(width, len(rates))
+	}
+
+	table := &Table{Columns: []string{"cohort", "users"}}
+	for k := 0; k < width; k++ {
+		table.Columns = append(table.Columns, fmt.Sprintf("period_%d", k))
+	}
+	for i, cohort := range m.Cohorts {
+		row := []interface{}{cohort, m.Sizes[i]}
+		for k := 0; k < width; k++ {
+			if k < len(m.Rates[i]) {
+				row = append(row, m.Rates[i][k])
+			} else {
+				row = append(row, nil)
+			}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table
+}
+
+// ratio 计算比例并保留四位小数
+func ratio(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(int(float64(part)/float64(total)*10000+0.5)) / 10000
+}
@@ -0,0 +1,327 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 事件类型，按 类别.动作 命名，查询时可以只按类别过滤
+const (
+	ActionRegister        = "auth.register"
+	ActionLogin           = "auth.login"
+	ActionAuthDenied      = "auth.denied" // 缺少、无效或已撤销的令牌
+	ActionTokenRefresh    = "auth.refresh"
+	ActionLogout          = "auth.logout"
+	ActionPasswordChange  = "auth.password"
+	ActionPasswordReset   = "auth.password.reset"
+	ActionEmailVerify     = "auth.email"
+	ActionPhoneBind       = "auth.phone"
+	ActionAccountDelete   = "account.delete"
+	ActionOAuthLogin      = "auth.oauth"
+	ActionAPIKeyUse       = "auth.apikey" // 使用 API Key 的请求，Resource 为 apikey:ID
+	ActionAPIKeyCreate    = "apikey.create"
+	ActionAPIKeyRevoke    = "apikey.revoke"
+	ActionFileUpload      = "file.upload"
+	ActionFileDelete      = "file.delete"
+	ActionFilePreview     = "file.preview"
+	ActionFileRead        = "file.read" // 智能体或LLM读取文件数据
+	ActionLLMCall         = "llm.call"
+	ActionSandboxExecute  = "sandbox.execute"
+	ActionSQLQuery        = "sql.query"        // 在上传文件上执行的SQL
+	ActionDataSourceQuery = "datasource.query" // 在数据库数据源上执行的SQL
+)
+
+// 事件结果
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// maxMemoryEvents 仅内存模式下保留的事件上限，超出后丢弃最早的事件
+const maxMemoryEvents = 100000
+
+// Event 审计事件。每个事件记录前一个事件的哈希，构成哈希链，修改或删除任一事件都会使校验失败
+type Event struct {
+	ID       int64                  `json:"id"`
+	Time     time.Time              `json:"time"`
+	UserID   int                    `json:"user_id,omitempty"`
+	Action   string                 `json:"action"`
+	Resource string                 `json:"resource,omitempty"` // 如 file:1、datasource:2、LLM提供商
+	Status   string                 `json:"status"`
+	Error    string                 `json:"error,omitempty"`
+	IP       string                 `json:"ip,omitempty"`
+	Detail   map[string]interface{} `json:"detail,omitempty"`
+	PrevHash string                 `json:"prev_hash"`
+	Hash     string                 `json:"hash"`
+}
+
+// WithError 操作失败时将事件标记为失败并记录错误，err 为空时原样返回
+func (e Event) WithError(err error) Event {
+	if err != nil {
+		e.Status = StatusFailure
+		e.Error = err.Error()
+	}
+	return e
+}
+
+// Filter 审计事件的查询条件，零值表示不限
+type Filter struct {
+	UserID   int
+	Action   string // 精确匹配，或按类别前缀匹配（如 file 匹配 file.upload）
+	Resource string
+	Status   string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+	Offset   int
+}
+
+// Log 只追加的审计日志。指定文件时事件以JSONL格式追加写入文件，查询和导出都读取文件；
+// 否则只保存在内存中
+type Log struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	events   []Event
+	nextID   int64
+	lastHash string
+	now      func() time.Time
+}
+
+// NewMemory 创建只保存在内存中的审计日志
+func NewMemory() *Log {
+	return &Log{nextID: 1, now: time.Now}
+}
+
+// Open 打开或创建审计日志文件，读取已有事件以延续编号和哈希链
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建审计日志目录失败: %w", err)
+	}
+	l := &Log{path: path, nextID: 1, now: time.Now}
+	err := l.scan(func(e Event) error {
+		l.nextID = e.ID + 1
+		l.lastHash = e.Hash
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志失败: %w", err)
+	}
+	l.file = file
+	return l, nil
+}
+
+// Record 追加一个事件，填入编号、时间和哈希。写入失败只记录日志，不影响被审计的操作
+func (l *Log) Record(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.ID = l.nextID
+	if e.Time.IsZero() {
+		e.Time = l.now()
+	}
+	if e.Status == "" {
+		e.Status = StatusSuccess
+	}
+	e.PrevHash = l.lastHash
+	hash, err := eventHash(e)
+	if err != nil {
+		log.Printf("审计事件序列化失败: %v", err)
+		return
+	}
+	e.Hash = hash
+
+	if l.file != nil {
+		line, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("审计事件序列化失败: %v", err)
+			return
+		}
+		if _, err := l.file.Write(append(line, '\n')); err != nil {
+			log.Printf("写入审计日志失败: %v", err)
+			return
+		}
+	} else {
+		l.events = append(l.events, e)
+		if len(l.events) > maxMemoryEvents {
+			l.events = l.events[len(l.events)-maxMemoryEvents:]
+		}
+	}
+	l.nextID++
+	l.lastHash = e.Hash
+}
+
+// Query 按条件查询事件，按时间倒序返回一页事件和符合条件的总数
+func (l *Log) Query(filter Filter) ([]Event, int, error) {
+	var matched []Event
+	err := l.each(filter, func(e Event) error {
+		matched = append(matched, e)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].ID > matched[j].ID })
+	total := len(matched)
+	start := min(max(filter.Offset, 0), total)
+	end := total
+	if filter.Limit > 0 {
+		end = min(start+filter.Limit, total)
+	}
+	return matched[start:end], total, nil
+}
+
+// Export 将符合条件的事件按时间顺序以JSONL格式写出，忽略分页条件
+func (l *Log) Export(w io.Writer, filter Filter) error {
+	encoder := json.NewEncoder(w)
+	return l.each(filter, func(e Event) error {
+		return encoder.Encode(e)
+	})
+}
+
+// Verify 校验哈希链，返回第一个被篡改或缺失的事件
+func (l *Log) Verify() error {
+	prev, first := "", true
+	return l.each(Filter{}, func(e Event) error {
+		// 内存模式下最早的事件可能已被丢弃，从保留的第一个事件开始校验
+		if !first || l.path != "" {
+			if e.PrevHash != prev {
+				return fmt.Errorf("审计事件 %d 的前序哈希不匹配，日志可能被删改", e.ID)
+			}
+		}
+		hash, err := eventHash(e)
+		if err != nil {
+			return err
+		}
+		if hash != e.Hash {
+			return fmt.Errorf("审计事件 %d 的哈希不匹配，日志可能被删改", e.ID)
+		}
+		prev, first = e.Hash, false
+		return nil
+	})
+}
+
+// Close 关闭审计日志文件
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// each 按时间顺序遍历符合条件的事件
+func (l *Log) each(filter Filter, fn func(Event) error) error {
+	visit := func(e Event) error {
+		if !filter.match(e) {
+			return nil
+		}
+		return fn(e)
+	}
+
+	l.mu.Lock()
+	if l.path == "" {
+		events := append([]Event(nil), l.events...)
+		l.mu.Unlock()
+		for _, e := range events {
+			if err := visit(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	l.mu.Unlock()
+	return l.scan(visit)
+}
+
+// scan 按行读取审计日志文件
+func (l *Log) scan(fn func(Event) error) error {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var e Event
+			decoder := json.NewDecoder(bytes.NewReader(line))
+			decoder.UseNumber() // 保留数字的原始写法，使重新计算的哈希与写入时一致
+			if decodeErr := decoder.Decode(&e); decodeErr != nil {
+				return fmt.Errorf("解析审计日志第 %d 行失败: %w", lineNo, decodeErr)
+			}
+			if fnErr := fn(e); fnErr != nil {
+				return fnErr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// match 判断事件是否符合查询条件
+func (f Filter) match(e Event) bool {
+	if f.UserID != 0 && e.UserID != f.UserID {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action && !strings.HasPrefix(e.Action, f.Action+".") {
+		return false
+	}
+	if f.Resource != "" && e.Resource != f.Resource {
+		return false
+	}
+	if f.Status != "" && e.Status != f.Status {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// eventHash 计算事件（不含自身哈希）的SHA-256
+func eventHash(e Event) (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return Digest(string(data)), nil
+}
+
+// Digest 返回文本的SHA-256十六进制摘要，用于记录提示词和响应而不保存原文
+func Digest(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
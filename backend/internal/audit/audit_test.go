@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLog_QueryAndExport(t *testing.T) {
+	l := NewMemory()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tick := start
+	l.now = func() time.Time {
+		tick = tick.Add(time.Minute)
+		return tick
+	}
+
+	l.Record(Event{UserID: 1, Action: ActionLogin})
+	l.Record(Event{UserID: 1, Action: ActionFileUpload, Resource: "file:1"})
+	l.Record(Event{UserID: 2, Action: ActionFilePreview, Resource: "file:1"})
+	l.Record(Event{UserID: 2, Action: ActionSQLQuery}.WithError(errors.New("no such table")))
+
+	// 按类别前缀匹配，不匹配同前缀的其他类别
+	events, total, err := l.Query(Filter{Action: "file"})
+	if err != nil || total != 2 || events[0].Action != ActionFilePreview {
+		t.Fatalf("按类别查询不正确: %v %d %v", events, total, err)
+	}
+	if _, total, _ := l.Query(Filter{Action: "fil"}); total != 0 {
+		t.Errorf("类别应完整匹配: %d", total)
+	}
+	if events, _, _ := l.Query(Filter{Status: StatusFailure}); len(events) != 1 || events[0].Error != "no such table" {
+		t.Errorf("失败事件不正确: %v", events)
+	}
+	if events, total, _ := l.Query(Filter{UserID: 1, Limit: 1, Offset: 1}); total != 2 || len(events) != 1 || events[0].Action != ActionLogin {
+		t.Errorf("分页不正确: %v %d", events, total)
+	}
+	if _, total, _ := l.Query(Filter{Since: start.Add(2 * time.Minute), Until: start.Add(4 * time.Minute)}); total != 2 {
+		t.Errorf("时间范围不正确: %d", total)
+	}
+
+	// 导出按时间顺序，每行一个事件
+	var buf bytes.Buffer
+	if err := l.Export(&buf, Filter{Resource: "file:1"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("导出行数不正确: %q", buf.String())
+	}
+	var first Event
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil || first.Action != ActionFileUpload {
+		t.Errorf("导出内容不正确: %v %v", first, err)
+	}
+	if err := l.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLog_FileChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Record(Event{UserID: 1, Action: ActionSandboxExecute, Detail: map[string]interface{}{"code": "print(1)", "duration_ms": 12}})
+	l.Record(Event{UserID: 1, Action: ActionLLMCall, Detail: map[string]interface{}{"prompt_sha256": Digest("hi")}})
+	l.Close()
+
+	// 重新打开后延续编号和哈希链
+	l, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Record(Event{UserID: 2, Action: ActionDataSourceQuery, Resource: "datasource:1"})
+	defer l.Close()
+	events, total, err := l.Query(Filter{})
+	if err != nil || total != 3 || events[0].ID != 3 || events[0].PrevHash != events[1].Hash {
+		t.Fatalf("重新打开后的事件不正确: %v %v", events, err)
+	}
+	if err := l.Verify(); err != nil {
+		t.Fatalf("未修改的日志应通过校验: %v", err)
+	}
+
+	// 修改任一事件都会使校验失败
+	data, _ := os.ReadFile(path)
+	tampered := strings.Replace(string(data), "print(1)", "print(2)", 1)
+	if err := os.WriteFile(path, []byte(tampered), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Verify(); err == nil {
+		t.Error("被修改的日志应校验失败")
+	}
+
+	// 删除事件同样会被发现
+	lines := strings.SplitAfter(string(data), "\n")
+	if err := os.WriteFile(path, []byte(lines[0]+lines[2]), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Verify(); err == nil {
+		t.Error("删除事件后应校验失败")
+	}
+}
@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"smart-analysis/internal/config"
+	"smart-analysis/internal/scheduler"
+)
+
+var (
+	globalLog   = NewMemory()
+	globalLogMu sync.RWMutex
+)
+
+// GetGlobal 获取全局审计日志，未初始化时只保存在内存中
+func GetGlobal() *Log {
+	globalLogMu.RLock()
+	defer globalLogMu.RUnlock()
+	return globalLog
+}
+
+// InitializeGlobal 根据应用配置初始化全局审计日志，AuditLogPath 为空时只保存在内存中
+func InitializeGlobal(appConfig *config.Config) error {
+	l := NewMemory()
+	if appConfig.AuditLogPath != "" {
+		opened, err := Open(appConfig.AuditLogPath)
+		if err != nil {
+			return err
+		}
+		l = opened
+	}
+
+	globalLogMu.Lock()
+	globalLog = l
+	globalLogMu.Unlock()
+	return nil
+}
+
+// Record 向全局审计日志追加事件，未指定用户时使用上下文中发起请求的用户
+func Record(ctx context.Context, e Event) {
+	if e.UserID == 0 {
+		e.UserID = UserFromContext(ctx)
+	}
+	GetGlobal().Record(e)
+}
+
+// UserFromContext 返回上下文中发起请求的用户ID，匿名请求返回0
+func UserFromContext(ctx context.Context) int {
+	userID, err := strconv.Atoi(scheduler.UserFromContext(ctx))
+	if err != nil {
+		return 0
+	}
+	return userID
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"sync"
+
+	"smart-analysis/internal/config"
+)
+
+var (
+	globalManager     *Manager
+	globalManagerOnce sync.Once
+	globalManagerMu   sync.RWMutex
+)
+
+// GetGlobal 获取全局令牌管理器，未初始化时使用进程内随机生成的 HMAC 密钥
+func GetGlobal() *Manager {
+	globalManagerOnce.Do(func() {
+		globalManagerMu.Lock()
+		defer globalManagerMu.Unlock()
+		if globalManager == nil {
+			secret, err := randomToken()
+			if err != nil {
+				panic(err)
+			}
+			key, _ := HMACKey(config.DefaultJWTKeyID, []byte(secret))
+			keys, _ := NewKeySet(key.ID, key)
+			globalManager = NewManager(keys, DefaultAccessTTL, DefaultRefreshTTL)
+		}
+	})
+
+	globalManagerMu.RLock()
+	defer globalManagerMu.RUnlock()
+	return globalManager
+}
+
+// InitializeGlobal 根据应用配置初始化全局令牌管理器。
+// 配置了 JWTKeyDir 时从目录加载密钥，否则使用 JWTSecret 作为 HS256 密钥
+func InitializeGlobal(appConfig *config.Config) error {
+	var keys *KeySet
+	var err error
+	if appConfig.JWTKeyDir != "" {
+		keys, err = LoadKeyDir(appConfig.JWTKeyDir, appConfig.JWTKeyID)
+	} else {
+		var key *Key
+		if key, err = HMACKey(appConfig.JWTKeyID, []byte(appConfig.JWTSecret)); err == nil {
+			keys, err = NewKeySet(key.ID, key)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	m := NewManager(keys, appConfig.AccessTokenTTL, appConfig.RefreshTokenTTL)
+	globalManagerMu.Lock()
+	globalManager = m
+	globalManagerMu.Unlock()
+	return nil
+}
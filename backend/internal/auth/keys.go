@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 密钥目录中的文件类型，文件名（不含扩展名）即密钥ID（kid）
+const (
+	pemKeyExt    = ".pem"    // RSA 或 Ed25519 的私钥（可签发和校验）或公钥（只能校验）
+	secretKeyExt = ".secret" // HMAC 密钥（HS256）
+)
+
+// Key 签名密钥。没有签名私钥的密钥只用于校验轮换前签发的令牌
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+}
+
+// CanSign 判断密钥能否签发令牌
+func (k *Key) CanSign() bool {
+	return k.sign != nil
+}
+
+// KeySet 按 kid 索引的密钥集合，使用当前密钥签发令牌，集合中的任一密钥都可以校验令牌
+type KeySet struct {
+	active string
+	keys   map[string]*Key
+}
+
+// NewKeySet 创建密钥集合，active 为签发令牌使用的密钥ID
+func NewKeySet(active string, keys ...*Key) (*KeySet, error) {
+	set := &KeySet{active: active, keys: make(map[string]*Key)}
+	for _, key := range keys {
+		if _, exists := set.keys[key.ID]; exists {
+			return nil, fmt.Errorf("密钥ID重复: %s", key.ID)
+		}
+		set.keys[key.ID] = key
+	}
+
+	key, exists := set.keys[active]
+	if !exists {
+		return nil, fmt.Errorf("签发密钥 %s 不存在", active)
+	}
+	if !key.CanSign() {
+		return nil, fmt.Errorf("签发密钥 %s 缺少私钥", active)
+	}
+	return set, nil
+}
+
+// HMACKey 创建 HS256 密钥
+func HMACKey(id string, secret []byte) (*Key, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("密钥 %s 为空", id)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, sign: secret, verify: secret}, nil
+}
+
+// ParsePEMKey 解析PEM格式的 RSA（RS256）或 Ed25519（EdDSA）密钥，私钥可签发和校验，公钥只能校验
+func ParsePEMKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("密钥 %s 不是PEM格式", id)
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("密钥 %s 的PEM类型不受支持: %s", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析密钥 %s 失败: %w", id, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, sign: k, verify: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, verify: k}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, sign: k, verify: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, verify: k}, nil
+	default:
+		return nil, fmt.Errorf("密钥 %s 的类型不受支持，仅支持 RSA 和 Ed25519", id)
+	}
+}
+
+// LoadKeyDir 从目录加载密钥，*.pem 为 RSA/Ed25519 密钥，*.secret 为 HMAC 密钥，文件名即密钥ID。
+// 轮换时放入新密钥并切换 active，旧密钥保留到它签发的令牌全部过期，也可以只保留公钥
+func LoadKeyDir(dir, active string) (*KeySet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥目录失败: %w", err)
+	}
+
+	var keys []*Key
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != pemKeyExt && ext != secretKeyExt) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("读取密钥失败: %w", err)
+		}
+
+		id := strings.TrimSuffix(entry.Name(), ext)
+		var key *Key
+		if ext == pemKeyExt {
+			key, err = ParsePEMKey(id, data)
+		} else {
+			key, err = HMACKey(id, []byte(strings.TrimSpace(string(data))))
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("密钥目录中没有密钥")
+	}
+	return NewKeySet(active, keys...)
+}
+
+// signingKey 返回签发令牌使用的密钥
+func (s *KeySet) signingKey() *Key {
+	return s.keys[s.active]
+}
+
+// keyFunc 按令牌头部的 kid 选择校验密钥，并要求签名算法与密钥一致，防止算法混淆。
+// 没有 kid 的令牌使用当前签发密钥校验
+func (s *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+	if id == "" {
+		id = s.active
+	}
+	key, exists := s.keys[id]
+	if !exists {
+		return nil, fmt.Errorf("未知的密钥ID: %s", id)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("签名算法 %s 与密钥 %s 不匹配", token.Method.Alg(), id)
+	}
+	return key.verify, nil
+}
+
+// algorithms 返回集合中所有密钥的签名算法
+func (s *KeySet) algorithms() []string {
+	seen := make(map[string]bool)
+	var algs []string
+	for _, key := range s.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	sort.Strings(algs)
+	return algs
+}
@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// issuer 签发的访问令牌的 iss 声明
+const issuer = "smart-analysis"
+
+// 默认有效期
+const (
+	DefaultAccessTTL  = 15 * time.Minute
+	DefaultRefreshTTL = 7 * 24 * time.Hour
+)
+
+var (
+	// ErrRevoked 令牌已注销，或用户修改密码、注销全部会话后签发时间更早的令牌
+	ErrRevoked = errors.New("令牌已被撤销")
+	// ErrRefreshReused 已使用过的刷新令牌被再次使用，可能已泄露，同一登录的刷新令牌全部作废
+	ErrRefreshReused = errors.New("刷新令牌已被使用")
+	// ErrRateLimited API Key 超出每分钟请求数上限
+	ErrRateLimited = errors.New("API Key 请求过于频繁")
+)
+
+// Claims 访问令牌的声明
+type Claims struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Version  int    `json:"ver"` // 用户的令牌版本，修改密码或注销全部会话后递增，旧版本的令牌失效
+	jwt.RegisteredClaims
+}
+
+// TokenPair 登录或刷新后签发的令牌
+type TokenPair struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// refreshToken 服务端保存的刷新令牌，只保存令牌的哈希。
+// 每次刷新都换发新的刷新令牌，同一次登录换发的令牌属于同一 family
+type refreshToken struct {
+	userID    int
+	family    string
+	version   int
+	expiresAt time.Time
+	used      bool
+}
+
+// Manager 签发、校验和撤销令牌。访问令牌是短期JWT，刷新令牌是保存在服务端的随机串。
+//
+// 刷新令牌和撤销状态（令牌版本、已注销的访问令牌）只保存在内存中，不会持久化，重启后：
+// 刷新令牌全部失效，用户需要重新登录；撤销记录丢失，签名密钥不变时，已注销的访问令牌，
+// 以及修改密码或注销全部会话前签发的访问令牌，在过期前（最长为访问令牌有效期）重新可用。
+// 需要撤销在重启后仍然生效时，重启时更换签名密钥并且不保留旧密钥
+type Manager struct {
+	keys       *KeySet
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+
+	mu       sync.Mutex
+	refresh  map[string]*refreshToken // 刷新令牌哈希 -> 令牌
+	revoked  map[string]time.Time     // 已注销的访问令牌 jti -> 过期时间
+	versions map[int]int              // 用户ID -> 令牌版本
+
+	now    func() time.Time
+	random func() (string, error)
+}
+
+// NewManager 创建令牌管理器，有效期不大于0时使用默认值
+func NewManager(keys *KeySet, accessTTL, refreshTTL time.Duration) *Manager {
+	if accessTTL <= 0 {
+		accessTTL = DefaultAccessTTL
+	}
+	if refreshTTL <= 0 {
+		refreshTTL = DefaultRefreshTTL
+	}
+	return &Manager{
+		keys:       keys,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		refresh:    make(map[string]*refreshToken),
+		revoked:    make(map[string]time.Time),
+		versions:   make(map[int]int),
+		now:        time.Now,
+		random:     randomToken,
+	}
+}
+
+// Issue 用户登录后签发访问令牌和刷新令牌
+func (m *Manager) Issue(userID int, username string) (*TokenPair, error) {
+	family, err := m.random()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune()
+	return m.issue(userID, username, family)
+}
+
+// Verify 校验访问令牌，返回其中的声明
+func (m *Manager) Verify(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, m.keys.keyFunc,
+		jwt.WithValidMethods(m.keys.algorithms()),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(m.now),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID <= 0 || claims.ID == "" {
+		return nil, errors.New("令牌缺少用户或令牌ID")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, revoked := m.revoked[claims.ID]; revoked || claims.Version != m.versions[claims.UserID] {
+		return nil, ErrRevoked
+	}
+	return claims, nil
+}
+
+// Refresh 使用刷新令牌换发新的令牌，旧的刷新令牌随即失效。
+// lookup 返回用户当前的用户名，返回错误（如用户已删除）时同一登录的刷新令牌全部作废
+func (m *Manager) Refresh(token string, lookup func(userID int) (string, error)) (*TokenPair, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune()
+
+	stored, exists := m.refresh[tokenHash(token)]
+	if !exists || !m.now().Before(stored.expiresAt) || stored.version != m.versions[stored.userID] {
+		return nil, ErrRevoked
+	}
+	if stored.used {
+		m.revokeFamily(stored.family)
+		return nil, ErrRefreshReused
+	}
+
+	username, err := lookup(stored.userID)
+	if err != nil {
+		m.revokeFamily(stored.family)
+		return nil, err
+	}
+	stored.used = true
+	return m.issue(stored.userID, username, stored.family)
+}
+
+// Revoke 注销访问令牌，以及同一登录的刷新令牌（refresh 不为空时）
+func (m *Manager) Revoke(claims *Claims, refresh string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if claims != nil && claims.ExpiresAt != nil {
+		m.revoked[claims.ID] = claims.ExpiresAt.Time
+	}
+	if stored, exists := m.refresh[tokenHash(refresh)]; exists && claims != nil && stored.userID == claims.UserID {
+		m.revokeFamily(stored.family)
+	}
+}
+
+// RevokeUser 撤销用户的全部令牌，用于修改密码、注销全部会话等场景
+func (m *Manager) RevokeUser(userID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.versions[userID]++
+	for hash, stored := range m.refresh {
+		if stored.userID == userID {
+			delete(m.refresh, hash)
+		}
+	}
+}
+
+// issue 签发一对令牌，调用方需持有锁
+func (m *Manager) issue(userID int, username, family string) (*TokenPair, error) {
+	jti, err := m.random()
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := m.random()
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	key := m.keys.signingKey()
+	claims := &Claims{
+		UserID:   userID,
+		Username: username,
+		Version:  m.versions[userID],
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    issuer,
+			Subject:   fmt.Sprint(userID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.accessTTL)),
+		},
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	access, err := token.SignedString(key.sign)
+	if err != nil {
+		return nil, fmt.Errorf("签发令牌失败: %w", err)
+	}
+
+	pair := &TokenPair{
+		AccessToken:      access,
+		AccessExpiresAt:  claims.ExpiresAt.Time,
+		RefreshToken:     refresh,
+		RefreshExpiresAt: now.Add(m.refreshTTL),
+	}
+	m.refresh[tokenHash(refresh)] = &refreshToken{
+		userID:    userID,
+		family:    family,
+		version:   claims.Version,
+		expiresAt: pair.RefreshExpiresAt,
+	}
+	return pair, nil
+}
+
+// revokeFamily 作废同一登录的全部刷新令牌，调用方需持有锁
+func (m *Manager) revokeFamily(family string) {
+	for hash, stored := range m.refresh {
+		if stored.family == family {
+			delete(m.refresh, hash)
+		}
+	}
+}
+
+// prune 清理已过期的刷新令牌和注销记录，调用方需持有锁
+func (m *Manager) prune() {
+	now := m.now()
+	for hash, stored := range m.refresh {
+		if !now.Before(stored.expiresAt) {
+			delete(m.refresh, hash)
+		}
+	}
+	for jti, expiresAt := range m.revoked {
+		if !now.Before(expiresAt) {
+			delete(m.revoked, jti)
+		}
+	}
+}
+
+// randomToken 生成随机令牌
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成随机令牌失败: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// tokenHash 返回刷新令牌的哈希，服务端不保存令牌原文
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	key, err := HMACKey("k1", []byte("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewKeySet("k1", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewManager(keys, time.Minute, time.Hour)
+}
+
+func TestManager_RefreshAndRevoke(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	lookup := func(int) (string, error) { return "alice", nil }
+
+	pair, err := m.Issue(1, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := m.Verify(pair.AccessToken)
+	if err != nil || claims.UserID != 1 || claims.Username != "alice" {
+		t.Fatalf("令牌校验不正确: %+v %v", claims, err)
+	}
+
+	// 访问令牌过期后需要刷新，刷新令牌只能使用一次
+	m.now = func() time.Time { return now.Add(2 * time.Minute) }
+	if _, err := m.Verify(pair.AccessToken); err == nil {
+		t.Error("过期的访问令牌应校验失败")
+	}
+	refreshed, err := m.Refresh(pair.RefreshToken, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Verify(refreshed.AccessToken); err != nil {
+		t.Errorf("刷新后的访问令牌应有效: %v", err)
+	}
+	if _, err := m.Refresh(pair.RefreshToken, lookup); !errors.Is(err, ErrRefreshReused) {
+		t.Errorf("重复使用刷新令牌应被拒绝: %v", err)
+	}
+	if _, err := m.Refresh(refreshed.RefreshToken, lookup); err == nil {
+		t.Error("检测到重复使用后同一登录的刷新令牌应全部作废")
+	}
+
+	// 注销只影响当前登录，修改密码撤销全部令牌
+	first, _ := m.Issue(1, "alice")
+	second, _ := m.Issue(1, "alice")
+	firstClaims, _ := m.Verify(first.AccessToken)
+	m.Revoke(firstClaims, first.RefreshToken)
+	if _, err := m.Verify(first.AccessToken); !errors.Is(err, ErrRevoked) {
+		t.Errorf("注销后的访问令牌应失效: %v", err)
+	}
+	if _, err := m.Refresh(first.RefreshToken, lookup); err == nil {
+		t.Error("注销后的刷新令牌应失效")
+	}
+	if _, err := m.Verify(second.AccessToken); err != nil {
+		t.Errorf("其他登录不受影响: %v", err)
+	}
+	m.RevokeUser(1)
+	if _, err := m.Verify(second.AccessToken); !errors.Is(err, ErrRevoked) {
+		t.Errorf("撤销用户后访问令牌应失效: %v", err)
+	}
+	if _, err := m.Refresh(second.RefreshToken, lookup); err == nil {
+		t.Error("撤销用户后刷新令牌应失效")
+	}
+	third, _ := m.Issue(1, "alice")
+	if _, err := m.Verify(third.AccessToken); err != nil {
+		t.Errorf("撤销后重新登录的令牌应有效: %v", err)
+	}
+
+	// 缺少声明或没有令牌ID的令牌被拒绝而不是 panic
+	legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 1, "exp": now.Add(time.Hour).Unix(), "iss": issuer})
+	signed, _ := legacy.SignedString([]byte("test-secret"))
+	if _, err := m.Verify(signed); err == nil {
+		t.Error("没有令牌ID的旧令牌应被拒绝")
+	}
+	malformed := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": "x", "exp": now.Add(time.Hour).Unix()})
+	signed, _ = malformed.SignedString([]byte("test-secret"))
+	if _, err := m.Verify(signed); err == nil {
+		t.Error("声明类型错误的令牌应被拒绝")
+	}
+}
+
+func TestLoadKeyDir_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, "2024-rsa.pem"), "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	writePEM(t, filepath.Join(dir, "2025-ed.pem"), "PRIVATE KEY", der)
+	if err := os.WriteFile(filepath.Join(dir, "legacy.secret"), []byte("old-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadKeyDir(dir, "2024-rsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := NewManager(keys, time.Minute, time.Hour)
+	oldPair, err := old.Issue(1, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换到 Ed25519 密钥后，旧密钥签发的令牌仍可校验；旧私钥移除、只保留公钥也可以
+	pubDER, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err := os.Remove(filepath.Join(dir, "2024-rsa.pem")); err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, "2024-rsa.pem"), "PUBLIC KEY", pubDER)
+	keys, err = LoadKeyDir(dir, "2025-ed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := NewManager(keys, time.Minute, time.Hour)
+	if _, err := rotated.Verify(oldPair.AccessToken); err != nil {
+		t.Errorf("轮换前签发的令牌应仍有效: %v", err)
+	}
+	newPair, _ := rotated.Issue(1, "alice")
+	token, _, _ := jwt.NewParser().ParseUnverified(newPair.AccessToken, &Claims{})
+	if token.Header["kid"] != "2025-ed" || token.Method.Alg() != "EdDSA" {
+		t.Errorf("应使用新密钥签发: %v", token.Header)
+	}
+	if _, err := LoadKeyDir(dir, "2024-rsa"); err == nil {
+		t.Error("只有公钥的密钥不能作为签发密钥")
+	}
+
+	// 使用 HMAC 密钥伪造的 RS256 kid 令牌被拒绝
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: 1, RegisteredClaims: jwt.RegisteredClaims{
+		ID: "x", Issuer: issuer, ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}})
+	forged.Header["kid"] = "2024-rsa"
+	signed, _ := forged.SignedString(pubDER)
+	if _, err := rotated.Verify(signed); err == nil {
+		t.Error("签名算法与密钥不匹配的令牌应被拒绝")
+	}
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
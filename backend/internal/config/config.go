@@ -1,28 +1,104 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
 	"os"
+	"strconv"
+	"time"
 )
 
+// DefaultJWTKeyID 未配置 JWT_KEY_ID 时签发令牌使用的密钥ID
+const DefaultJWTKeyID = "default"
+
 type Config struct {
 	Port        string
 	DatabaseURL string
-	JWTSecret   string
+	JWTSecret   string // 未配置 JWT_SECRET 时为进程内随机生成的密钥，重启后已签发的令牌失效
 	UploadPath  string
 	MaxFileSize int64
 	OpenAIKey   string
 	HunyuanKey  string
+
+	PromptLanguage string // 提示词语言：zh / en
+	PromptDir      string // 提示词模板覆盖目录
+
+	PlanStoreDir string // 执行计划持久化目录，为空时只保存在内存中
+	ExpertDir    string // 配置定义的专家智能体目录（*.json），为空时只使用内置专家
+
+	SchedulerWorkers       int    // 同时执行的专家任务数
+	SandboxSlots           int    // 同时运行的Python沙箱进程数
+	PythonPath             string // Python沙箱使用的解释器路径，为空时使用 PATH 中的 python3
+	LLMConcurrency         int    // 每个LLM提供商的默认并发调用数
+	LLMProviderConcurrency string // 按提供商覆盖并发数，如 "openai=8,hunyuan=2"
+
+	DataSourceSecret    string // 数据源密码加密密钥，独立于JWT密钥，轮换JWT密钥不影响已保存的数据源密码
+	DataSourceSQLiteDir string // 允许注册为数据源的SQLite文件目录，为空时禁用SQLite数据源
+
+	PIIHashSecret string // 敏感字段哈希脱敏的密钥，独立于JWT密钥，轮换JWT密钥不改变已生成的假名
+
+	AuditLogPath string // 审计日志文件（JSONL，只追加），为空时只保存在内存中
+	AdminEmails  string // 管理员邮箱，逗号分隔，管理员可以查询和导出审计日志
+
+	JWTKeyDir       string        // JWT密钥目录，*.pem 为 RSA/Ed25519 密钥，*.secret 为 HMAC 密钥，文件名为 kid；为空时使用 JWTSecret
+	JWTKeyID        string        // 签发令牌使用的密钥ID，轮换时切换为新密钥，旧密钥继续用于校验
+	AccessTokenTTL  time.Duration // 访问令牌有效期。令牌撤销记录只保存在内存中，重启后已撤销的访问令牌在此期限内重新可用
+	RefreshTokenTTL time.Duration // 刷新令牌有效期。刷新令牌只保存在内存中，重启后全部失效
+
+	OAuthConfigFile string // 第三方登录提供商配置（JSON数组），为空时不启用第三方登录
+
+	AppURL        string // 前端地址，用于拼接邮件中的验证和重置密码链接
+	SMTPAddr      string // SMTP服务器地址（host:port），为空时邮件输出到日志
+	SMTPUsername  string
+	SMTPPassword  string
+	SMTPFrom      string // 发件人地址
+	SMSWebhookURL string // 短信网关Webhook地址，为空时短信输出到日志
 }
 
 func Load() *Config {
 	return &Config{
 		Port:        getEnv("PORT", "8080"),
 		DatabaseURL: getEnv("DATABASE_URL", "user:password@tcp(localhost:3306)/smart_analysis?charset=utf8mb4&parseTime=True&loc=Local"),
-		JWTSecret:   getEnv("JWT_SECRET", "your-secret-key"),
+		JWTSecret:   getSecretEnv("JWT_SECRET", os.Getenv("JWT_KEY_DIR") == "", "重启后已签发的令牌失效"),
 		UploadPath:  getEnv("UPLOAD_PATH", "./uploads"),
 		MaxFileSize: 500 * 1024 * 1024, // 500MB
 		OpenAIKey:   getEnv("OPENAI_API_KEY", ""),
 		HunyuanKey:  getEnv("HUNYUAN_API_KEY", ""),
+
+		PromptLanguage: getEnv("PROMPT_LANG", "zh"),
+		PromptDir:      getEnv("PROMPT_DIR", ""),
+
+		PlanStoreDir: getEnv("PLAN_STORE_DIR", "./data/plans"),
+		ExpertDir:    getEnv("EXPERT_DIR", ""),
+
+		SchedulerWorkers:       getEnvInt("SCHEDULER_WORKERS", 8),
+		SandboxSlots:           getEnvInt("SANDBOX_SLOTS", 4),
+		PythonPath:             getEnv("PYTHON_PATH", ""),
+		LLMConcurrency:         getEnvInt("LLM_CONCURRENCY", 4),
+		LLMProviderConcurrency: getEnv("LLM_PROVIDER_CONCURRENCY", ""),
+
+		DataSourceSecret:    getSecretEnv("DATASOURCE_SECRET", true, "重启后已保存的数据源密码无法解密"),
+		DataSourceSQLiteDir: getEnv("DATASOURCE_SQLITE_DIR", "./data/sqlite"),
+
+		PIIHashSecret: getSecretEnv("PII_HASH_SECRET", true, "重启后哈希脱敏生成的假名会改变"),
+
+		AuditLogPath: getEnv("AUDIT_LOG_PATH", "./data/audit.jsonl"),
+		AdminEmails:  getEnv("ADMIN_EMAILS", ""),
+
+		JWTKeyDir:       getEnv("JWT_KEY_DIR", ""),
+		JWTKeyID:        getEnv("JWT_KEY_ID", DefaultJWTKeyID),
+		AccessTokenTTL:  getEnvDuration("JWT_ACCESS_TTL", 15*time.Minute),
+		RefreshTokenTTL: getEnvDuration("JWT_REFRESH_TTL", 7*24*time.Hour),
+
+		OAuthConfigFile: getEnv("OAUTH_CONFIG", ""),
+
+		AppURL:        getEnv("APP_URL", "http://localhost:3000"),
+		SMTPAddr:      getEnv("SMTP_ADDR", ""),
+		SMTPUsername:  getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:  getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:      getEnv("SMTP_FROM", ""),
+		SMSWebhookURL: getEnv("SMS_WEBHOOK_URL", ""),
 	}
 }
 
@@ -32,3 +108,46 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getSecretEnv 读取密钥。未配置时生成进程内随机密钥，warn 为 true 时提示随机密钥的影响；
+// 不提供公开的默认密钥，否则任何人都可以伪造令牌或还原脱敏数据
+func getSecretEnv(key string, warn bool, consequence string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	secret, err := RandomSecret()
+	if err != nil {
+		log.Fatalf("生成 %s 失败: %v", key, err)
+	}
+	if warn {
+		log.Printf("警告: 未配置 %s，使用进程内随机生成的密钥，%s；生产环境请配置独立的密钥", key, consequence)
+	}
+	return secret
+}
+
+// RandomSecret 生成随机密钥
+func RandomSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,20 @@
+package config
+
+import "testing"
+
+func TestLoad_RandomSecretsWhenUnset(t *testing.T) {
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("DATASOURCE_SECRET", "")
+	t.Setenv("PII_HASH_SECRET", "configured")
+
+	first, second := Load(), Load()
+	if first.JWTSecret == "" || first.JWTSecret == second.JWTSecret {
+		t.Error("未配置 JWT_SECRET 时每个进程应使用不同的随机密钥")
+	}
+	if first.DataSourceSecret == "" || first.DataSourceSecret == first.JWTSecret {
+		t.Error("未配置 DATASOURCE_SECRET 时应使用独立的随机密钥")
+	}
+	if first.PIIHashSecret != "configured" {
+		t.Errorf("PII_HASH_SECRET = %q, want configured", first.PIIHashSecret)
+	}
+}
@@ -0,0 +1,79 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"smart-analysis/internal/model"
+)
+
+func TestScanAndQuerySQLite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "shop.db")
+
+	setup, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = setup.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+		CREATE TABLE orders (
+			id INTEGER PRIMARY KEY,
+			user_id INTEGER REFERENCES users(id),
+			amount REAL
+		);
+		INSERT INTO users VALUES (1, 'alice'), (2, 'bob');
+		INSERT INTO orders VALUES (1, 1, 10.5), (2, 1, 20), (3, 2, 5);`)
+	setup.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(ctx, &model.DataSource{Type: TypeSQLite, Database: path}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	schemas, err := Scan(ctx, db, TypeSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) != 2 || schemas[0].TableName != "orders" || schemas[1].TableName != "users" {
+		t.Fatalf("表扫描结果不正确: %+v", schemas)
+	}
+
+	orders := schemas[0]
+	if len(orders.Columns) != 3 || !orders.Columns[0].IsKey || orders.Columns[2].Type != "REAL" {
+		t.Errorf("列信息不正确: %+v", orders.Columns)
+	}
+	constraints := strings.Join(orders.Constraints, "; ")
+	if constraints != "PRIMARY KEY (id); FOREIGN KEY (user_id) REFERENCES users(id)" {
+		t.Errorf("约束不正确: %s", constraints)
+	}
+	if fks, ok := orders.Metadata["foreign_keys"].([]ForeignKey); !ok || len(fks) != 1 || fks[0].RefTable != "users" {
+		t.Errorf("外键元数据不正确: %+v", orders.Metadata)
+	}
+
+	allowed := []string{"orders", "users"}
+	result, err := Query(ctx, db, TypeSQLite, allowed, "SELECT u.name, SUM(o.amount) AS total FROM orders o JOIN users u ON u.id = o.user_id GROUP BY u.name ORDER BY total DESC", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RowCount != 1 || !result.Truncated || result.Rows[0][0] != "alice" {
+		t.Errorf("查询结果不正确: %+v", result)
+	}
+
+	if _, err := Query(ctx, db, TypeSQLite, allowed, "DELETE FROM orders", 0); err == nil {
+		t.Error("应拒绝非 SELECT 语句")
+	}
+	if _, err := Query(ctx, db, TypeSQLite, []string{"orders"}, "SELECT * FROM users", 0); err == nil {
+		t.Error("应拒绝访问不在允许列表中的表")
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM orders"); err == nil {
+		t.Error("SQLite 数据源应以只读方式打开")
+	}
+}
@@ -0,0 +1,42 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"smart-analysis/internal/sqlengine"
+	"smart-analysis/internal/sqlguard"
+)
+
+// Query 校验后在只读事务中执行单条 SELECT 查询。dialect 为数据源类型，tables 为允许访问的表，
+// 执行前用 EXPLAIN 估算代价，最多返回 maxRows 行
+func Query(ctx context.Context, db *sql.DB, dialect string, tables []string, query string, maxRows int) (*sqlengine.Result, error) {
+	// 多取一行用于判断结果是否被截断
+	checked, err := sqlguard.Check(query, sqlguard.Options{
+		Dialect:       dialect,
+		AllowedTables: tables,
+		MaxRows:       sqlengine.RowLimit(maxRows) + 1,
+		MaxOffset:     sqlengine.MaxOffset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("开启只读事务失败: %w", err)
+	}
+	// 只读查询不提交，结束后直接回滚
+	defer tx.Rollback()
+
+	if _, err := sqlguard.CheckCost(ctx, tx, dialect, checked.SQL, 0); err != nil {
+		return nil, err
+	}
+	rows, err := tx.QueryContext(ctx, checked.SQL)
+	if err != nil {
+		return nil, fmt.Errorf("SQL执行失败: %w", err)
+	}
+	defer rows.Close()
+	return sqlengine.ReadResult(rows, maxRows)
+}
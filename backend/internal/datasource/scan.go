@@ -0,0 +1,296 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"smart-analysis/internal/types"
+)
+
+// ForeignKey 外键引用
+type ForeignKey struct {
+	Column    string `json:"column"`
+	RefTable  string `json:"ref_table"`
+	RefColumn string `json:"ref_column"`
+}
+
+// table 扫描过程中的表结构
+type table struct {
+	name        string
+	comment     string
+	columns     []types.ColumnInfo
+	primaryKeys []string
+	foreignKeys []ForeignKey
+}
+
+// Scan 扫描数据源中的表、列、类型、注释以及主外键
+func Scan(ctx context.Context, db *sql.DB, dbType string) ([]types.DataSchema, error) {
+	var (
+		tables []*table
+		err    error
+	)
+	switch dbType {
+	case TypeSQLite:
+		tables, err = scanSQLite(ctx, db)
+	case TypeMySQL:
+		tables, err = scanInformationSchema(ctx, db, mysqlQueries)
+	case TypePostgres:
+		tables, err = scanInformationSchema(ctx, db, postgresQueries)
+	default:
+		return nil, fmt.Errorf("不支持的数据源类型: %s", dbType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("扫描表结构失败: %w", err)
+	}
+
+	sort.Slice(tables, func(i, j int) bool { return tables[i].name < tables[j].name })
+	schemas := make([]types.DataSchema, len(tables))
+	for i, t := range tables {
+		schemas[i] = t.schema()
+	}
+	return schemas, nil
+}
+
+// schema 转换为 types.DataSchema，主键列标记为 IsKey
+func (t *table) schema() types.DataSchema {
+	pk := make(map[string]bool, len(t.primaryKeys))
+	for _, name := range t.primaryKeys {
+		pk[name] = true
+	}
+	for i := range t.columns {
+		t.columns[i].IsKey = pk[t.columns[i].Name]
+	}
+
+	var constraints []string
+	if len(t.primaryKeys) > 0 {
+		constraints = append(constraints, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(t.primaryKeys, ", ")))
+	}
+	for _, fk := range t.foreignKeys {
+		constraints = append(constraints, fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s(%s)", fk.Column, fk.RefTable, fk.RefColumn))
+	}
+
+	metadata := map[string]interface{}{}
+	if t.comment != "" {
+		metadata["comment"] = t.comment
+	}
+	if len(t.foreignKeys) > 0 {
+		metadata["foreign_keys"] = t.foreignKeys
+	}
+	if len(metadata) == 0 {
+		metadata = nil
+	}
+
+	return types.DataSchema{
+		TableName:   t.name,
+		Columns:     t.columns,
+		Constraints: constraints,
+		Metadata:    metadata,
+	}
+}
+
+// scanSQLite 通过 sqlite_master 和 PRAGMA 扫描 SQLite
+func scanSQLite(ctx context.Context, db *sql.DB) ([]*table, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tables := make([]*table, 0, len(names))
+	for _, name := range names {
+		t := &table{name: name}
+		if err := sqliteColumns(ctx, db, t); err != nil {
+			return nil, err
+		}
+		if err := sqliteForeignKeys(ctx, db, t); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+func sqliteColumns(ctx context.Context, db *sql.DB, t *table) error {
+	rows, err := db.QueryContext(ctx, `SELECT name, type, pk FROM pragma_table_info(?) ORDER BY cid`, t.name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type pkColumn struct {
+		name string
+		seq  int
+	}
+	var pks []pkColumn
+	for rows.Next() {
+		var (
+			name, colType string
+			pk            int
+		)
+		if err := rows.Scan(&name, &colType, &pk); err != nil {
+			return err
+		}
+		t.columns = append(t.columns, types.ColumnInfo{Name: name, Type: colType})
+		if pk > 0 {
+			pks = append(pks, pkColumn{name: name, seq: pk})
+		}
+	}
+	sort.Slice(pks, func(i, j int) bool { return pks[i].seq < pks[j].seq })
+	for _, pk := range pks {
+		t.primaryKeys = append(t.primaryKeys, pk.name)
+	}
+	return rows.Err()
+}
+
+func sqliteForeignKeys(ctx context.Context, db *sql.DB, t *table) error {
+	rows, err := db.QueryContext(ctx, `SELECT "from", "table", COALESCE("to", '') FROM pragma_foreign_key_list(?) ORDER BY id, seq`, t.name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fk ForeignKey
+		if err := rows.Scan(&fk.Column, &fk.RefTable, &fk.RefColumn); err != nil {
+			return err
+		}
+		t.foreignKeys = append(t.foreignKeys, fk)
+	}
+	return rows.Err()
+}
+
+// schemaQueries 基于 information_schema 的扫描语句，结果列依次为：
+// tables: 表名, 表注释
+// columns: 表名, 列名, 类型, 列注释
+// primaryKeys: 表名, 列名
+// foreignKeys: 表名, 列名, 引用表, 引用列
+type schemaQueries struct {
+	tables      string
+	columns     string
+	primaryKeys string
+	foreignKeys string
+}
+
+var mysqlQueries = schemaQueries{
+	tables: `SELECT TABLE_NAME, COALESCE(TABLE_COMMENT, '') FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = DATABASE()`,
+	columns: `SELECT TABLE_NAME, COLUMN_NAME, COLUMN_TYPE, COALESCE(COLUMN_COMMENT, '') FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() ORDER BY TABLE_NAME, ORDINAL_POSITION`,
+	primaryKeys: `SELECT TABLE_NAME, COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = DATABASE() AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY TABLE_NAME, ORDINAL_POSITION`,
+	foreignKeys: `SELECT TABLE_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = DATABASE() AND REFERENCED_TABLE_NAME IS NOT NULL ORDER BY TABLE_NAME, ORDINAL_POSITION`,
+}
+
+var postgresQueries = schemaQueries{
+	tables: `SELECT c.relname, COALESCE(obj_description(c.oid, 'pg_class'), '') FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'v', 'm', 'p')`,
+	columns: `SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod), COALESCE(col_description(c.oid, a.attnum), '')
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'v', 'm', 'p') AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY c.relname, a.attnum`,
+	primaryKeys: `SELECT kcu.table_name, kcu.column_name FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_name = tc.constraint_name AND kcu.table_schema = tc.table_schema
+		WHERE tc.table_schema = current_schema() AND tc.constraint_type = 'PRIMARY KEY'
+		ORDER BY kcu.table_name, kcu.ordinal_position`,
+	foreignKeys: `SELECT kcu.table_name, kcu.column_name, ccu.table_name, ccu.column_name FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_name = tc.constraint_name AND kcu.table_schema = tc.table_schema
+		JOIN information_schema.constraint_column_usage ccu
+			ON ccu.constraint_name = tc.constraint_name AND ccu.constraint_schema = tc.table_schema
+		WHERE tc.table_schema = current_schema() AND tc.constraint_type = 'FOREIGN KEY'
+		ORDER BY kcu.table_name, kcu.ordinal_position`,
+}
+
+// scanInformationSchema 使用给定语句扫描 MySQL 或 PostgreSQL
+func scanInformationSchema(ctx context.Context, db *sql.DB, q schemaQueries) ([]*table, error) {
+	byName := make(map[string]*table)
+	var tables []*table
+
+	err := queryRows(ctx, db, q.tables, func(values []string) {
+		t := &table{name: values[0], comment: values[1]}
+		byName[t.name] = t
+		tables = append(tables, t)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = queryRows(ctx, db, q.columns, func(values []string) {
+		if t := byName[values[0]]; t != nil {
+			t.columns = append(t.columns, types.ColumnInfo{Name: values[1], Type: values[2], Description: values[3]})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = queryRows(ctx, db, q.primaryKeys, func(values []string) {
+		if t := byName[values[0]]; t != nil {
+			t.primaryKeys = append(t.primaryKeys, values[1])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = queryRows(ctx, db, q.foreignKeys, func(values []string) {
+		if t := byName[values[0]]; t != nil {
+			t.foreignKeys = append(t.foreignKeys, ForeignKey{Column: values[1], RefTable: values[2], RefColumn: values[3]})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// queryRows 执行查询并把每行结果按字符串传给 fn
+func queryRows(ctx context.Context, db *sql.DB, query string, fn func(values []string)) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = v.String
+		}
+		fn(row)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,369 @@
+package handler
+
+import (
+	"net/http"
+	"smart-analysis/internal/audit"
+	"smart-analysis/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SendEmailVerification 重新发送验证邮件
+// @Summary 发送验证邮件
+// @Description 向当前用户未验证的邮箱重新发送验证邮件，之前的验证链接失效
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Router /user/email/verification [post]
+func (h *UserHandler) SendEmailVerification(c *gin.Context) {
+	if err := h.accountService.SendEmailVerification(c.Request.Context(), c.GetInt("user_id")); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Verification email sent",
+	})
+}
+
+// VerifyEmail 验证邮箱
+// @Summary 验证邮箱
+// @Description 使用验证邮件中的令牌验证邮箱；修改邮箱时验证后替换账号邮箱
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param data body model.VerifyEmailRequest true "验证令牌"
+// @Success 200 {object} model.Response{data=model.User}
+// @Failure 400 {object} model.Response
+// @Router /user/email/verify [post]
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var req model.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := h.accountService.VerifyEmail(req.Token)
+	event := audit.Event{Action: audit.ActionEmailVerify, IP: c.ClientIP()}
+	if user != nil {
+		event.UserID = user.ID
+		event.Detail = map[string]interface{}{"email": user.Email}
+	}
+	audit.Record(c.Request.Context(), event.WithError(err))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Email verified",
+		Data:    user,
+	})
+}
+
+// ForgotPassword 申请重置密码
+// @Summary 申请重置密码
+// @Description 按邮箱发送重置密码链接，或按手机号发送短信验证码。邮箱或手机号未注册时同样返回成功
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param data body model.ForgotPasswordRequest true "邮箱或手机号"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Router /user/password/forgot [post]
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var req model.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.accountService.RequestPasswordReset(c.Request.Context(), &req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "If the account exists, password reset instructions have been sent",
+	})
+}
+
+// ResetPassword 重置密码
+// @Summary 重置密码
+// @Description 使用重置链接中的令牌，或手机号和短信验证码设置新密码。重置后账号解除锁定，已签发的令牌全部失效，需要重新登录
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param data body model.ResetPasswordRequest true "重置凭据和新密码"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Router /user/password/reset [post]
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var req model.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := h.accountService.ResetPassword(&req)
+	event := audit.Event{Action: audit.ActionPasswordReset, IP: c.ClientIP()}
+	if user != nil {
+		event.UserID = user.ID
+	}
+	audit.Record(c.Request.Context(), event.WithError(err))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.tokens.RevokeUser(user.ID)
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Password reset",
+	})
+}
+
+// SendPhoneCode 发送短信验证码
+// @Summary 发送短信验证码
+// @Description 发送用于注册、登录、重置密码、注销账号或绑定手机号的验证码，同一手机号同一用途每分钟只能发送一次。
+// @Description 11位手机号按中国大陆号码处理，其他地区需要带 + 和国家码
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param data body model.PhoneCodeRequest true "手机号和用途"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Router /user/phone/code [post]
+func (h *UserHandler) SendPhoneCode(c *gin.Context) {
+	var req model.PhoneCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.accountService.SendPhoneCode(c.Request.Context(), &req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Code sent",
+	})
+}
+
+// RegisterPhone 手机号注册
+// @Summary 手机号注册
+// @Description 使用手机号和短信验证码注册，不设置密码时只能通过短信验证码登录
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param data body model.PhoneRegisterRequest true "注册请求体"
+// @Success 201 {object} model.Response{data=model.LoginResponse}
+// @Failure 400 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /user/phone/register [post]
+func (h *UserHandler) RegisterPhone(c *gin.Context) {
+	var req model.PhoneRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := h.accountService.RegisterPhone(&req)
+	event := audit.Event{Action: audit.ActionRegister, IP: c.ClientIP(), Detail: map[string]interface{}{"method": "phone"}}
+	if user != nil {
+		event.UserID = user.ID
+	}
+	audit.Record(c.Request.Context(), event.WithError(err))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.issueTokens(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    500,
+			Message: "Failed to generate token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.Response{
+		Code:    201,
+		Message: "User registered successfully",
+		Data:    resp,
+	})
+}
+
+// LoginPhone 手机号验证码登录
+// @Summary 手机号验证码登录
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param data body model.PhoneCodeLoginRequest true "手机号和验证码"
+// @Success 200 {object} model.Response{data=model.LoginResponse}
+// @Failure 401 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /user/phone/login [post]
+func (h *UserHandler) LoginPhone(c *gin.Context) {
+	var req model.PhoneCodeLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := h.accountService.LoginPhone(&req)
+	event := audit.Event{Action: audit.ActionLogin, IP: c.ClientIP(), Detail: map[string]interface{}{"method": "phone"}}
+	if user != nil {
+		event.UserID = user.ID
+	}
+	audit.Record(c.Request.Context(), event.WithError(err))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:    401,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.issueTokens(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    500,
+			Message: "Failed to generate token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Login successful",
+		Data:    resp,
+	})
+}
+
+// BindPhone 绑定手机号
+// @Summary 绑定手机号
+// @Description 验证短信验证码后为当前用户绑定或更换手机号，之后可以用手机号登录
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param data body model.PhoneCodeLoginRequest true "手机号和验证码"
+// @Success 200 {object} model.Response{data=model.User}
+// @Failure 400 {object} model.Response
+// @Router /user/phone [put]
+func (h *UserHandler) BindPhone(c *gin.Context) {
+	var req model.PhoneCodeLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := h.accountService.BindPhone(c.GetInt("user_id"), &req)
+	audit.Record(c.Request.Context(), audit.Event{Action: audit.ActionPhoneBind, IP: c.ClientIP()}.WithError(err))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Phone bound",
+		Data:    user,
+	})
+}
+
+// DeleteAccount 注销账号
+// @Summary 注销账号
+// @Description 验证密码或短信验证码后注销账号，删除用户的文件、会话、数据源、个人看板和 API Key，并退出全部团队空间。
+// @Description 用户是仍有其他成员的团队空间的唯一所有者时需要先转让所有权
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param data body model.DeleteAccountRequest true "密码或短信验证码"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Router /user/account [delete]
+func (h *UserHandler) DeleteAccount(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	var req model.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	err := h.accountService.DeleteAccount(userID, &req)
+	audit.Record(c.Request.Context(), audit.Event{
+		Action:   audit.ActionAccountDelete,
+		Resource: "user:" + c.GetString("username"),
+		IP:       c.ClientIP(),
+	}.WithError(err))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.tokens.RevokeUser(userID)
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Account deleted",
+	})
+}
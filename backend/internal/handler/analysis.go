@@ -9,19 +9,33 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// AnalysisHandler 分析接口处理器
+// @Description 自然语言分析查询、会话和LLM配置
+// @Tags 分析
+// @Router /analysis [group]
 type AnalysisHandler struct {
 	analysisService *service.AnalysisService
 	fileService     *service.FileService
 }
 
-func NewAnalysisHandler(analysisService *service.AnalysisService) *AnalysisHandler {
+func NewAnalysisHandler(analysisService *service.AnalysisService, fileService *service.FileService) *AnalysisHandler {
 	return &AnalysisHandler{
 		analysisService: analysisService,
-		fileService:     service.NewFileService(), // 这应该通过依赖注入
+		fileService:     fileService,
 	}
 }
 
 // Query 处理分析查询
+// @Summary 分析查询
+// @Description 用自然语言查询上传的文件或数据源，智能体生成并执行分析计划。可以使用具有 queries:run 权限的 API Key
+// @Tags 分析
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body model.QueryRequest true "查询"
+// @Success 200 {object} model.Response{data=model.QueryResponse}
+// @Failure 400 {object} model.Response
+// @Router /analysis/query [post]
 func (h *AnalysisHandler) Query(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
@@ -34,7 +48,7 @@ func (h *AnalysisHandler) Query(c *gin.Context) {
 		return
 	}
 
-	response, err := h.analysisService.Query(userID, &req, h.fileService)
+	response, err := h.analysisService.Query(c.Request.Context(), userID, &req, h.fileService)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.Response{
 			Code:    400,
@@ -51,35 +65,84 @@ func (h *AnalysisHandler) Query(c *gin.Context) {
 }
 
 // Visualize 生成可视化图表
-//func (h *AnalysisHandler) Visualize(c *gin.Context) {
-//	userID := c.GetInt("user_id")
-//
-//	var req model.VisualizationRequest
-//	if err := c.ShouldBindJSON(&req); err != nil {
-//		c.JSON(http.StatusBadRequest, model.Response{
-//			Code:    400,
-//			Message: "Invalid request: " + err.Error(),
-//		})
-//		return
-//	}
-//
-//	response, err := h.analysisService.Visualize(userID, &req, h.fileService)
-//	if err != nil {
-//		c.JSON(http.StatusBadRequest, model.Response{
-//			Code:    400,
-//			Message: err.Error(),
-//		})
-//		return
-//	}
-//
-//	c.JSON(http.StatusOK, model.Response{
-//		Code:    200,
-//		Message: "Visualization generated successfully",
-//		Data:    response,
-//	})
-//}
-//
-//// GenerateReport 生成分析报告
+// @Summary 生成可视化图表
+// @Description 用自然语言描述要绘制的图表，LLM 根据文件推断出的表结构选择图表类型和数据列，生成可由前端直接渲染的 ECharts 配置。
+// @Description 图表保存到会话中，关联到本次可视化查询。可以使用具有 queries:run 权限的 API Key
+// @Tags 分析
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body model.VisualizationRequest true "可视化请求"
+// @Success 200 {object} model.Response{data=model.Chart}
+// @Failure 400 {object} model.Response
+// @Router /analysis/visualize [post]
+func (h *AnalysisHandler) Visualize(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	var req model.VisualizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	chart, err := h.analysisService.Visualize(c.Request.Context(), userID, &req, h.fileService)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Visualization generated successfully",
+		Data:    chart,
+	})
+}
+
+// GetChart 获取图表
+// @Summary 获取图表
+// @Description 获取可视化生成的图表，可以查看所在会话的用户都可以获取。可以使用具有 queries:run 权限的 API Key
+// @Tags 分析
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "图表ID"
+// @Success 200 {object} model.Response{data=model.Chart}
+// @Failure 400 {object} model.Response
+// @Router /analysis/chart/{id} [get]
+func (h *AnalysisHandler) GetChart(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	chartID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid chart ID",
+		})
+		return
+	}
+
+	chart, err := h.analysisService.GetChart(userID, chartID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    chart,
+	})
+}
+
+// GenerateReport 生成分析报告
 //func (h *AnalysisHandler) GenerateReport(c *gin.Context) {
 //	userID := c.GetInt("user_id")
 //
@@ -109,6 +172,15 @@ func (h *AnalysisHandler) Query(c *gin.Context) {
 //}
 
 // GetHistory 获取查询历史
+// @Summary 获取查询历史
+// @Description 可以使用具有 queries:run 权限的 API Key
+// @Tags 分析
+// @Produce json
+// @Security ApiKeyAuth
+// @Param session_id query int false "会话ID"
+// @Success 200 {object} model.Response{data=[]model.Query}
+// @Failure 400 {object} model.Response
+// @Router /analysis/history [get]
 func (h *AnalysisHandler) GetHistory(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
@@ -137,6 +209,16 @@ func (h *AnalysisHandler) GetHistory(c *gin.Context) {
 }
 
 // CreateSession 创建会话
+// @Summary 创建会话
+// @Description 可以使用具有 sessions:manage 权限的 API Key
+// @Tags 分析
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body model.CreateSessionRequest true "会话"
+// @Success 201 {object} model.Response{data=model.Session}
+// @Failure 400 {object} model.Response
+// @Router /analysis/session [post]
 func (h *AnalysisHandler) CreateSession(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
@@ -166,6 +248,15 @@ func (h *AnalysisHandler) CreateSession(c *gin.Context) {
 }
 
 // GetSession 获取会话详情
+// @Summary 获取会话详情
+// @Description 可以使用具有 sessions:manage 权限的 API Key
+// @Tags 分析
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "会话ID"
+// @Success 200 {object} model.Response{data=model.Session}
+// @Failure 400 {object} model.Response
+// @Router /analysis/session/{id} [get]
 func (h *AnalysisHandler) GetSession(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
@@ -196,6 +287,15 @@ func (h *AnalysisHandler) GetSession(c *gin.Context) {
 }
 
 // ConfigLLM 配置LLM
+// @Summary 配置LLM
+// @Tags LLM
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body model.LLMConfigRequest true "LLM配置"
+// @Success 201 {object} model.Response{data=model.LLMConfig}
+// @Failure 400 {object} model.Response
+// @Router /llm/config [post]
 func (h *AnalysisHandler) ConfigLLM(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
@@ -224,11 +324,33 @@ func (h *AnalysisHandler) ConfigLLM(c *gin.Context) {
 	})
 }
 
-// GetLLMConfig 获取LLM配置
+// GetLLMConfig 获取LLM配置，指定 workspace_id 时获取团队空间共享的配置
+// @Summary 获取LLM配置
+// @Tags LLM
+// @Produce json
+// @Security ApiKeyAuth
+// @Param workspace_id query int false "团队空间ID"
+// @Success 200 {object} model.Response{data=[]model.LLMConfig}
+// @Failure 400 {object} model.Response
+// @Router /llm/config [get]
 func (h *AnalysisHandler) GetLLMConfig(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
-	configs, err := h.analysisService.GetLLMConfig(userID)
+	workspaceID, err := strconv.Atoi(c.DefaultQuery("workspace_id", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid workspace ID",
+		})
+		return
+	}
+
+	var configs []*model.LLMConfig
+	if workspaceID != 0 {
+		configs, err = h.analysisService.GetWorkspaceLLMConfig(userID, workspaceID)
+	} else {
+		configs, err = h.analysisService.GetLLMConfig(userID)
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.Response{
 			Code:    400,
@@ -244,11 +366,33 @@ func (h *AnalysisHandler) GetLLMConfig(c *gin.Context) {
 	})
 }
 
-// GetUsage 获取使用量统计
+// GetUsage 获取使用量统计，指定 workspace_id 时获取团队空间本月的使用量和预算
+// @Summary 获取使用量统计
+// @Tags LLM
+// @Produce json
+// @Security ApiKeyAuth
+// @Param workspace_id query int false "团队空间ID"
+// @Success 200 {object} model.Response{data=model.UsageResponse}
+// @Failure 400 {object} model.Response
+// @Router /llm/usage [get]
 func (h *AnalysisHandler) GetUsage(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
-	usage, err := h.analysisService.GetUsage(userID)
+	workspaceID, err := strconv.Atoi(c.DefaultQuery("workspace_id", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid workspace ID",
+		})
+		return
+	}
+
+	var usage *model.UsageResponse
+	if workspaceID != 0 {
+		usage, err = h.analysisService.GetWorkspaceUsage(userID, workspaceID)
+	} else {
+		usage, err = h.analysisService.GetUsage(userID)
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.Response{
 			Code:    400,
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"smart-analysis/internal/audit"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler API Key 接口处理器
+// @Description 用于脚本和第三方系统访问的个人 API Key 和团队空间服务 Key
+// @Tags API Key
+// @Router /apikey [group]
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// Create 创建 API Key
+// @Summary 创建 API Key
+// @Description 创建个人 API Key，或在团队空间中创建服务 Key（需要 owner 权限，以新建的服务账号身份访问）。
+// @Description 完整的 Key 只在创建时返回一次，请求时放在 Authorization: Bearer 或 X-API-Key 请求头中
+// @Tags API Key
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body model.APIKeyRequest true "API Key"
+// @Success 201 {object} model.Response{data=model.APIKeyResponse}
+// @Failure 400 {object} model.Response
+// @Router /apikey [post]
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	var req model.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.apiKeyService.Create(userID, &req)
+	event := audit.Event{Action: audit.ActionAPIKeyCreate, IP: c.ClientIP(), Detail: map[string]interface{}{"type": req.Type, "scopes": req.Scopes}}
+	if resp != nil {
+		event.Resource = fmt.Sprintf("apikey:%d", resp.APIKey.ID)
+	}
+	audit.Record(c.Request.Context(), event.WithError(err))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.Response{
+		Code:    201,
+		Message: "API key created",
+		Data:    resp,
+	})
+}
+
+// List 获取 API Key 列表
+// @Summary 获取 API Key 列表
+// @Description 获取当前用户创建的 Key 和其作为所有者的团队空间的服务 Key，包含请求数和最后使用时间
+// @Tags API Key
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} model.Response{data=[]model.APIKey}
+// @Router /apikey [get]
+func (h *APIKeyHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    h.apiKeyService.List(c.GetInt("user_id")),
+	})
+}
+
+// Revoke 撤销 API Key
+// @Summary 撤销 API Key
+// @Description 撤销后 Key 立即失效，服务 Key 的服务账号同时离开团队空间
+// @Tags API Key
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "API Key ID"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Router /apikey/{id} [delete]
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid API key ID",
+		})
+		return
+	}
+
+	err = h.apiKeyService.Revoke(userID, id)
+	audit.Record(c.Request.Context(), audit.Event{
+		Action:   audit.ActionAPIKeyRevoke,
+		Resource: fmt.Sprintf("apikey:%d", id),
+		IP:       c.ClientIP(),
+	}.WithError(err))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "API key revoked",
+	})
+}
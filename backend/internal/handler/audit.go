@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"smart-analysis/internal/audit"
+	"smart-analysis/internal/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler 审计日志接口处理器
+// @Description 审计日志查询、导出和校验接口，仅管理员可用
+// @Tags 审计
+// @Router /admin/audit [group]
+type AuditHandler struct {
+	log *audit.Log
+}
+
+func NewAuditHandler(log *audit.Log) *AuditHandler {
+	return &AuditHandler{
+		log: log,
+	}
+}
+
+// Query 查询审计事件
+// @Summary 查询审计事件
+// @Description 按用户、事件类型、资源、结果和时间范围查询审计事件，按时间倒序分页返回
+// @Tags 审计
+// @Produce json
+// @Security ApiKeyAuth
+// @Param user_id query int false "用户ID"
+// @Param action query string false "事件类型，如 file.upload，或类别如 file"
+// @Param resource query string false "资源，如 file:1、datasource:2"
+// @Param status query string false "结果：success 或 failure"
+// @Param since query string false "起始时间（RFC3339）"
+// @Param until query string false "截止时间（RFC3339，不含）"
+// @Param limit query int false "返回条数，默认100"
+// @Param offset query int false "跳过条数"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Failure 403 {object} model.Response
+// @Router /admin/audit [get]
+func (h *AuditHandler) Query(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+
+	events, total, err := h.log.Query(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data: gin.H{
+			"events": events,
+			"total":  total,
+		},
+	})
+}
+
+// Export 导出审计事件
+// @Summary 导出审计事件
+// @Description 按查询条件以JSONL格式按时间顺序导出全部审计事件，忽略分页参数
+// @Tags 审计
+// @Produce application/x-ndjson
+// @Security ApiKeyAuth
+// @Param user_id query int false "用户ID"
+// @Param action query string false "事件类型或类别"
+// @Param resource query string false "资源"
+// @Param status query string false "结果"
+// @Param since query string false "起始时间（RFC3339）"
+// @Param until query string false "截止时间（RFC3339，不含）"
+// @Success 200 {file} file
+// @Failure 400 {object} model.Response
+// @Failure 403 {object} model.Response
+// @Router /admin/audit/export [get]
+func (h *AuditHandler) Export(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+	filter.Limit, filter.Offset = 0, 0
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.jsonl"`, time.Now().Format("20060102150405")))
+	c.Status(http.StatusOK)
+	if err := h.log.Export(c.Writer, filter); err != nil {
+		// 响应已开始写出，只能中断
+		c.Error(err)
+		c.Abort()
+	}
+}
+
+// Verify 校验审计日志
+// @Summary 校验审计日志
+// @Description 校验审计事件的哈希链，检测日志是否被修改或删除
+// @Tags 审计
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} model.Response
+// @Failure 409 {object} model.Response
+// @Failure 403 {object} model.Response
+// @Router /admin/audit/verify [get]
+func (h *AuditHandler) Verify(c *gin.Context) {
+	if err := h.log.Verify(); err != nil {
+		c.JSON(http.StatusConflict, model.Response{
+			Code:    409,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Audit log intact",
+	})
+}
+
+// auditFilter 从查询参数解析审计事件的查询条件
+func auditFilter(c *gin.Context) (audit.Filter, error) {
+	filter := audit.Filter{
+		Action:   c.Query("action"),
+		Resource: c.Query("resource"),
+		Status:   c.Query("status"),
+	}
+
+	for name, target := range map[string]*int{"user_id": &filter.UserID, "limit": &filter.Limit, "offset": &filter.Offset} {
+		if value := c.Query(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return filter, fmt.Errorf("invalid %s", name)
+			}
+			*target = n
+		}
+	}
+
+	for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s, expected RFC3339 time", name)
+			}
+			*target = t
+		}
+	}
+	return filter, nil
+}
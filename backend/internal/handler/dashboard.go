@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"net/http"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DashboardHandler 看板接口处理器
+// @Description 个人和团队空间的看板
+// @Tags 看板
+// @Router /dashboard [group]
+type DashboardHandler struct {
+	dashboardService *service.DashboardService
+}
+
+func NewDashboardHandler(dashboardService *service.DashboardService) *DashboardHandler {
+	return &DashboardHandler{
+		dashboardService: dashboardService,
+	}
+}
+
+// Create 创建看板
+// @Summary 创建看板
+// @Description 创建个人看板，指定 workspace_id 时在团队空间中创建（需要 editor 权限）
+// @Tags 看板
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body model.DashboardRequest true "看板"
+// @Success 201 {object} model.Response{data=model.Dashboard}
+// @Failure 400 {object} model.Response
+// @Router /dashboard [post]
+func (h *DashboardHandler) Create(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	var req model.DashboardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	dashboard, err := h.dashboardService.Create(userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.Response{
+		Code:    201,
+		Message: "Dashboard created",
+		Data:    dashboard,
+	})
+}
+
+// List 获取看板列表
+// @Summary 获取看板列表
+// @Description 获取个人看板，指定 workspace_id 时获取团队空间的看板
+// @Tags 看板
+// @Produce json
+// @Security ApiKeyAuth
+// @Param workspace_id query int false "团队空间ID"
+// @Success 200 {object} model.Response{data=[]model.Dashboard}
+// @Failure 400 {object} model.Response
+// @Router /dashboard [get]
+func (h *DashboardHandler) List(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	workspaceID := 0
+	if value := c.Query("workspace_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.Response{
+				Code:    400,
+				Message: "Invalid workspace ID",
+			})
+			return
+		}
+		workspaceID = id
+	}
+
+	dashboards, err := h.dashboardService.List(userID, workspaceID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    dashboards,
+	})
+}
+
+// Get 获取看板
+// @Summary 获取看板
+// @Tags 看板
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "看板ID"
+// @Success 200 {object} model.Response{data=model.Dashboard}
+// @Failure 400 {object} model.Response
+// @Router /dashboard/{id} [get]
+func (h *DashboardHandler) Get(c *gin.Context) {
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.dashboardService.Get(userID, id)
+	})
+}
+
+// Update 修改看板
+// @Summary 修改看板
+// @Description 修改看板的名称、描述和图表，团队空间的看板需要 editor 权限
+// @Tags 看板
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "看板ID"
+// @Param request body model.DashboardRequest true "看板"
+// @Success 200 {object} model.Response{data=model.Dashboard}
+// @Failure 400 {object} model.Response
+// @Router /dashboard/{id} [put]
+func (h *DashboardHandler) Update(c *gin.Context) {
+	var req model.DashboardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.dashboardService.Update(userID, id, &req)
+	})
+}
+
+// Delete 删除看板
+// @Summary 删除看板
+// @Description 团队空间的看板只能由创建者或空间所有者删除
+// @Tags 看板
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "看板ID"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Router /dashboard/{id} [delete]
+func (h *DashboardHandler) Delete(c *gin.Context) {
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return nil, h.dashboardService.Delete(userID, id)
+	})
+}
+
+// respond 解析看板ID并执行操作
+func (h *DashboardHandler) respond(c *gin.Context, fn func(userID, id int) (interface{}, error)) {
+	userID := c.GetInt("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid dashboard ID",
+		})
+		return
+	}
+
+	data, err := fn(userID, id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    data,
+	})
+}
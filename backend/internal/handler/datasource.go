@@ -0,0 +1,303 @@
+package handler
+
+import (
+	"net/http"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/service"
+	"smart-analysis/internal/types"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DataSourceHandler 数据源接口处理器
+// @Description 注册和管理数据库数据源
+// @Tags 数据源
+// @Router /datasource [group]
+type DataSourceHandler struct {
+	dataSourceService *service.DataSourceService
+}
+
+func NewDataSourceHandler(dataSourceService *service.DataSourceService) *DataSourceHandler {
+	return &DataSourceHandler{
+		dataSourceService: dataSourceService,
+	}
+}
+
+// Create 注册数据源
+// @Summary 注册数据源
+// @Description 注册 MySQL、PostgreSQL 或 SQLite 数据源并扫描表结构，密码加密保存且不会返回
+// @Tags 数据源
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body model.DataSourceRequest true "数据源配置"
+// @Success 201 {object} model.Response{data=model.DataSource}
+// @Failure 400 {object} model.Response
+// @Router /datasource [post]
+func (h *DataSourceHandler) Create(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	var req model.DataSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	ds, err := h.dataSourceService.Create(userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.Response{
+		Code:    201,
+		Message: "Data source created",
+		Data:    ds,
+	})
+}
+
+// List 获取数据源列表
+// @Summary 获取数据源列表
+// @Description 获取当前用户的数据源，不包含表结构
+// @Tags 数据源
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} model.Response{data=[]model.DataSource}
+// @Router /datasource [get]
+func (h *DataSourceHandler) List(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    h.dataSourceService.List(userID),
+	})
+}
+
+// Get 获取数据源
+// @Summary 获取数据源
+// @Description 获取数据源配置、状态和表结构
+// @Tags 数据源
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "数据源ID"
+// @Success 200 {object} model.Response{data=model.DataSource}
+// @Failure 400 {object} model.Response
+// @Router /datasource/{id} [get]
+func (h *DataSourceHandler) Get(c *gin.Context) {
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.dataSourceService.Get(userID, id)
+	})
+}
+
+// Update 修改数据源
+// @Summary 修改数据源
+// @Description 修改数据源配置并重新扫描，password 为空时保留原密码
+// @Tags 数据源
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "数据源ID"
+// @Param request body model.DataSourceRequest true "数据源配置"
+// @Success 200 {object} model.Response{data=model.DataSource}
+// @Failure 400 {object} model.Response
+// @Router /datasource/{id} [put]
+func (h *DataSourceHandler) Update(c *gin.Context) {
+	var req model.DataSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.dataSourceService.Update(userID, id, &req)
+	})
+}
+
+// Delete 删除数据源
+// @Summary 删除数据源
+// @Tags 数据源
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "数据源ID"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Router /datasource/{id} [delete]
+func (h *DataSourceHandler) Delete(c *gin.Context) {
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return nil, h.dataSourceService.Delete(userID, id)
+	})
+}
+
+// Scan 重新扫描数据源
+// @Summary 重新扫描数据源
+// @Description 立即重新扫描数据源的表、列、注释和主外键
+// @Tags 数据源
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "数据源ID"
+// @Success 200 {object} model.Response{data=model.DataSource}
+// @Failure 400 {object} model.Response
+// @Router /datasource/{id}/scan [post]
+func (h *DataSourceHandler) Scan(c *gin.Context) {
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.dataSourceService.Scan(userID, id)
+	})
+}
+
+// Schema 获取数据源表结构
+// @Summary 获取数据源表结构
+// @Description 获取最近一次扫描得到的表结构
+// @Tags 数据源
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "数据源ID"
+// @Success 200 {object} model.Response{data=[]types.DataSchema}
+// @Failure 400 {object} model.Response
+// @Router /datasource/{id}/schema [get]
+func (h *DataSourceHandler) Schema(c *gin.Context) {
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.dataSourceService.Schema(userID, id)
+	})
+}
+
+// GetSemantic 获取数据源的业务语义模型
+// @Summary 获取业务语义模型
+// @Description 获取字段同义词、业务定义、指标口径和表关联路径
+// @Tags 数据源
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "数据源ID"
+// @Success 200 {object} model.Response{data=types.SemanticModel}
+// @Failure 400 {object} model.Response
+// @Router /datasource/{id}/semantic [get]
+func (h *DataSourceHandler) GetSemantic(c *gin.Context) {
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.dataSourceService.Semantic(userID, id)
+	})
+}
+
+// UpdateSemantic 保存数据源的业务语义模型
+// @Summary 保存业务语义模型
+// @Description 整体替换业务语义模型，引用的表和列必须存在，指标公式必须是单个SQL表达式
+// @Tags 数据源
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "数据源ID"
+// @Param request body types.SemanticModel true "业务语义模型"
+// @Success 200 {object} model.Response{data=types.SemanticModel}
+// @Failure 400 {object} model.Response
+// @Router /datasource/{id}/semantic [put]
+func (h *DataSourceHandler) UpdateSemantic(c *gin.Context) {
+	var req types.SemanticModel
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.dataSourceService.UpdateSemantic(userID, id, &req)
+	})
+}
+
+// SuggestSemantic 生成业务语义模型建议
+// @Summary 生成业务语义模型建议
+// @Description 由LLM根据表结构生成建议并与已有模型合并，结果不会自动保存
+// @Tags 数据源
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "数据源ID"
+// @Success 200 {object} model.Response{data=types.SemanticModel}
+// @Failure 400 {object} model.Response
+// @Router /datasource/{id}/semantic/suggest [post]
+func (h *DataSourceHandler) SuggestSemantic(c *gin.Context) {
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.dataSourceService.SuggestSemantic(c.Request.Context(), userID, id)
+	})
+}
+
+// GetSensitiveColumns 获取数据源的敏感列策略
+// @Summary 获取敏感列策略
+// @Description 扫描时按列名和列注释自动识别敏感列，默认脱敏
+// @Tags 数据源
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "数据源ID"
+// @Success 200 {object} model.Response{data=[]pii.Column}
+// @Failure 400 {object} model.Response
+// @Router /datasource/{id}/sensitive [get]
+func (h *DataSourceHandler) GetSensitiveColumns(c *gin.Context) {
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.dataSourceService.SensitiveColumns(userID, id)
+	})
+}
+
+// UpdateSensitiveColumns 设置数据源的敏感列策略
+// @Summary 设置敏感列策略
+// @Description 整体替换敏感列策略，策略为 allow、mask、hash 或 drop，每条策略必须指向扫描到的表和列
+// @Tags 数据源
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "数据源ID"
+// @Param request body model.SensitivePolicyRequest true "敏感列策略"
+// @Success 200 {object} model.Response{data=[]pii.Column}
+// @Failure 400 {object} model.Response
+// @Router /datasource/{id}/sensitive [put]
+func (h *DataSourceHandler) UpdateSensitiveColumns(c *gin.Context) {
+	var req model.SensitivePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.dataSourceService.UpdateSensitiveColumns(userID, id, req.Columns)
+	})
+}
+
+// respond 解析数据源ID并执行操作
+func (h *DataSourceHandler) respond(c *gin.Context, fn func(userID, id int) (interface{}, error)) {
+	userID := c.GetInt("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid data source ID",
+		})
+		return
+	}
+
+	data, err := fn(userID, id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    data,
+	})
+}
@@ -33,7 +33,7 @@ func NewFileHandler(fileService *service.FileService) *FileHandler {
 // @Param file formData file true "文件"
 // @Success 201 {object} model.Response{data=model.FileUploadResponse}
 // @Failure 400 {object} model.Response
-// @Router /api/file/upload [post]
+// @Router /file/upload [post]
 func (h *FileHandler) Upload(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
@@ -70,7 +70,7 @@ func (h *FileHandler) Upload(c *gin.Context) {
 // @Produce json
 // @Security ApiKeyAuth
 // @Success 200 {object} model.Response{data=[]model.File}
-// @Router /api/file/list [get]
+// @Router /file/list [get]
 func (h *FileHandler) List(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
@@ -92,7 +92,7 @@ func (h *FileHandler) List(c *gin.Context) {
 // @Param id path int true "文件ID"
 // @Success 200 {object} model.Response
 // @Failure 400 {object} model.Response
-// @Router /api/file/{id} [delete]
+// @Router /file/{id} [delete]
 func (h *FileHandler) Delete(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
@@ -131,7 +131,7 @@ func (h *FileHandler) Delete(c *gin.Context) {
 // @Param limit query int false "预览行数，默认50"
 // @Success 200 {object} model.Response{data=interface{}}
 // @Failure 400 {object} model.Response
-// @Router /api/file/preview/{id} [get]
+// @Router /file/{id}/preview [get]
 func (h *FileHandler) Preview(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
@@ -167,3 +167,347 @@ func (h *FileHandler) Preview(c *gin.Context) {
 		Data:    data,
 	})
 }
+
+// GetSensitiveColumns 获取文件的敏感列策略
+// @Summary 获取敏感列策略
+// @Description 解析文件时按列名和数据自动识别敏感列，默认脱敏
+// @Tags 文件
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "文件ID"
+// @Success 200 {object} model.Response{data=[]pii.Column}
+// @Failure 400 {object} model.Response
+// @Router /file/{id}/sensitive [get]
+func (h *FileHandler) GetSensitiveColumns(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	fileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid file ID",
+		})
+		return
+	}
+
+	columns, err := h.fileService.SensitiveColumns(userID, fileID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    columns,
+	})
+}
+
+// UpdateSensitiveColumns 设置文件的敏感列策略
+// @Summary 设置敏感列策略
+// @Description 整体替换敏感列策略，策略为 allow、mask、hash 或 drop；未列出的列仍会按数据自动识别。Excel 文件可以通过 table 指定工作表，为空时适用于所有工作表
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "文件ID"
+// @Param request body model.SensitivePolicyRequest true "敏感列策略"
+// @Success 200 {object} model.Response{data=[]pii.Column}
+// @Failure 400 {object} model.Response
+// @Router /file/{id}/sensitive [put]
+func (h *FileHandler) UpdateSensitiveColumns(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	fileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid file ID",
+		})
+		return
+	}
+
+	var req model.SensitivePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	columns, err := h.fileService.UpdateSensitiveColumns(userID, fileID, req.Columns)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    columns,
+	})
+}
+
+// Shared 获取共享给当前用户的文件
+// @Summary 获取共享文件
+// @Description 获取其他用户通过访问策略共享给当前用户的文件，预览和查询时按策略过滤行和列
+// @Tags 文件
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} model.Response{data=[]model.File}
+// @Router /file/shared [get]
+func (h *FileHandler) Shared(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    h.fileService.SharedFiles(userID),
+	})
+}
+
+// ListAccessPolicies 获取文件的访问策略
+// @Summary 获取访问策略
+// @Description 获取文件共享给其他用户或团队的访问策略，只有文件所有者可以查看
+// @Tags 文件
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "文件ID"
+// @Success 200 {object} model.Response{data=[]model.AccessPolicy}
+// @Failure 400 {object} model.Response
+// @Router /file/{id}/policies [get]
+func (h *FileHandler) ListAccessPolicies(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	fileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid file ID",
+		})
+		return
+	}
+
+	policies, err := h.fileService.ListAccessPolicies(userID, fileID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    policies,
+	})
+}
+
+// CreateAccessPolicy 添加文件的访问策略
+// @Summary 添加访问策略
+// @Description 授权其他用户或团队查看文件中满足行过滤条件（如 region = 'East'）的行和指定的列，多条策略取并集
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "文件ID"
+// @Param request body model.AccessPolicyRequest true "访问策略"
+// @Success 201 {object} model.Response{data=model.AccessPolicy}
+// @Failure 400 {object} model.Response
+// @Router /file/{id}/policies [post]
+func (h *FileHandler) CreateAccessPolicy(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	fileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid file ID",
+		})
+		return
+	}
+
+	var req model.AccessPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	policy, err := h.fileService.CreateAccessPolicy(userID, fileID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.Response{
+		Code:    201,
+		Message: "Access policy created",
+		Data:    policy,
+	})
+}
+
+// UpdateAccessPolicy 修改文件的访问策略
+// @Summary 修改访问策略
+// @Description 修改访问策略的授权对象、行过滤条件和可见列
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "文件ID"
+// @Param policy_id path int true "策略ID"
+// @Param request body model.AccessPolicyRequest true "访问策略"
+// @Success 200 {object} model.Response{data=model.AccessPolicy}
+// @Failure 400 {object} model.Response
+// @Router /file/{id}/policies/{policy_id} [put]
+func (h *FileHandler) UpdateAccessPolicy(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	fileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid file ID",
+		})
+		return
+	}
+	policyID, err := strconv.Atoi(c.Param("policy_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid policy ID",
+		})
+		return
+	}
+
+	var req model.AccessPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	policy, err := h.fileService.UpdateAccessPolicy(userID, fileID, policyID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    policy,
+	})
+}
+
+// DeleteAccessPolicy 删除文件的访问策略
+// @Summary 删除访问策略
+// @Description 删除访问策略，被授权的用户或团队随即无法再访问文件
+// @Tags 文件
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "文件ID"
+// @Param policy_id path int true "策略ID"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Router /file/{id}/policies/{policy_id} [delete]
+func (h *FileHandler) DeleteAccessPolicy(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	fileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid file ID",
+		})
+		return
+	}
+	policyID, err := strconv.Atoi(c.Param("policy_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid policy ID",
+		})
+		return
+	}
+
+	if err := h.fileService.DeleteAccessPolicy(userID, fileID, policyID); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Access policy deleted",
+	})
+}
+
+// Share 将文件共享到团队空间
+// @Summary 共享文件到团队空间
+// @Description 将文件共享到团队空间，空间成员可以完整查看和查询；需要是文件所有者且在空间中为 editor 或 owner，workspace_id 为0时取消共享
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "文件ID"
+// @Param request body model.ShareFileRequest true "团队空间"
+// @Success 200 {object} model.Response{data=model.File}
+// @Failure 400 {object} model.Response
+// @Router /file/{id}/workspace [put]
+func (h *FileHandler) Share(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	fileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid file ID",
+		})
+		return
+	}
+
+	var req model.ShareFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	file, err := h.fileService.ShareFile(userID, fileID, req.WorkspaceID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    file,
+	})
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"net/http"
+	"smart-analysis/internal/audit"
+	"smart-analysis/internal/auth"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthHandler 第三方登录接口处理器
+// @Description 飞书、企业微信和 OpenID Connect 第三方登录
+// @Tags 用户
+// @Router /user/oauth [group]
+type OAuthHandler struct {
+	oauthService *service.OAuthService
+	tokens       *auth.Manager
+}
+
+func NewOAuthHandler(oauthService *service.OAuthService, tokens *auth.Manager) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService: oauthService,
+		tokens:       tokens,
+	}
+}
+
+// Providers 获取第三方登录提供商
+// @Summary 获取第三方登录提供商
+// @Description 获取已配置的第三方登录提供商，用于在登录页显示登录按钮
+// @Tags 用户
+// @Produce json
+// @Success 200 {object} model.Response{data=[]model.OAuthProvider}
+// @Router /user/oauth/providers [get]
+func (h *OAuthHandler) Providers(c *gin.Context) {
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    h.oauthService.Providers(),
+	})
+}
+
+// Authorize 发起第三方登录
+// @Summary 发起第三方登录
+// @Description 返回提供商的授权页地址和 state，前端跳转到授权页，回调时将 code 和 state 提交到 callback 接口
+// @Tags 用户
+// @Produce json
+// @Param provider path string true "提供商名称"
+// @Success 200 {object} model.Response{data=model.OAuthAuthorizeResponse}
+// @Failure 400 {object} model.Response
+// @Router /user/oauth/{provider}/authorize [get]
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	h.authorize(c, 0)
+}
+
+// Link 关联第三方账号
+// @Summary 关联第三方账号
+// @Description 为当前用户发起第三方登录，回调后第三方身份关联到当前用户，之后可以用第三方账号登录
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Param provider path string true "提供商名称"
+// @Success 200 {object} model.Response{data=model.OAuthAuthorizeResponse}
+// @Failure 400 {object} model.Response
+// @Router /user/oauth/{provider}/link [get]
+func (h *OAuthHandler) Link(c *gin.Context) {
+	h.authorize(c, c.GetInt("user_id"))
+}
+
+// Callback 完成第三方登录
+// @Summary 完成第三方登录
+// @Description 用授权码完成第三方登录，返回访问令牌和刷新令牌。没有关联账号时按已验证的邮箱关联，或按提供商配置自动创建账号。关联账号发起的回调必须携带发起关联的用户的访问令牌
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param provider path string true "提供商名称"
+// @Param data body model.OAuthCallbackRequest true "回调参数"
+// @Success 200 {object} model.Response{data=model.LoginResponse}
+// @Failure 400 {object} model.Response
+// @Failure 401 {object} model.Response
+// @Router /user/oauth/{provider}/callback [post]
+func (h *OAuthHandler) Callback(c *gin.Context) {
+	provider := c.Param("provider")
+
+	var req model.OAuthCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := h.oauthService.Callback(c.Request.Context(), provider, &req, h.callerID(c))
+	event := audit.Event{Action: audit.ActionOAuthLogin, Resource: provider, IP: c.ClientIP()}
+	if user != nil {
+		event.UserID = user.ID
+	}
+	audit.Record(c.Request.Context(), event.WithError(err))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:    401,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	pair, err := h.tokens.Issue(user.ID, user.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    500,
+			Message: "Failed to generate token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Login successful",
+		Data:    loginResponse(user, pair),
+	})
+}
+
+// Identities 获取关联的第三方账号
+// @Summary 获取关联的第三方账号
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} model.Response{data=[]model.ExternalIdentity}
+// @Router /user/oauth/identities [get]
+func (h *OAuthHandler) Identities(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    h.oauthService.Identities(userID),
+	})
+}
+
+// Unlink 取消关联第三方账号
+// @Summary 取消关联第三方账号
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Param provider path string true "提供商名称"
+// @Success 200 {object} model.Response
+// @Failure 404 {object} model.Response
+// @Router /user/oauth/identities/{provider} [delete]
+func (h *OAuthHandler) Unlink(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	if err := h.oauthService.Unlink(userID, c.Param("provider")); err != nil {
+		c.JSON(http.StatusNotFound, model.Response{
+			Code:    404,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Identity unlinked",
+	})
+}
+
+// callerID 返回请求携带的有效访问令牌对应的用户，没有或无效时返回0。回调接口不要求登录，
+// 只有完成账号关联时需要据此确认是发起关联的用户
+func (h *OAuthHandler) callerID(c *gin.Context) int {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return 0
+	}
+	claims, err := h.tokens.Verify(token)
+	if err != nil {
+		return 0
+	}
+	return claims.UserID
+}
+
+// authorize 返回授权页地址，linkUserID 不为0时为关联账号
+func (h *OAuthHandler) authorize(c *gin.Context, linkUserID int) {
+	resp, err := h.oauthService.Authorize(c.Request.Context(), c.Param("provider"), linkUserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    resp,
+	})
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec 由接口注释生成的 OpenAPI 文档，修改接口注释后运行 go generate 更新
+//
+//go:generate go run ../../cmd/openapi -o openapi.json
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec 获取 OpenAPI 文档
+// @Summary 获取 OpenAPI 文档
+// @Description 返回 OpenAPI 3 格式的接口文档，可导入 Postman 等工具或用于生成客户端
+// @Tags 系统
+// @Produce json
+// @Success 200 {object} interface{}
+// @Router /openapi.json [get]
+func OpenAPISpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openAPISpec)
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/service"
+	"smart-analysis/internal/types"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetQueryPlan 获取查询的执行计划图
+// @Summary 获取执行计划
+// @Description 可以使用具有 queries:run 权限的 API Key
+// @Tags 分析
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "查询ID"
+// @Success 200 {object} model.Response{data=model.PlanGraphResponse}
+// @Failure 400 {object} model.Response
+// @Failure 404 {object} model.Response
+// @Router /analysis/query/{id}/plan [get]
+func (h *AnalysisHandler) GetQueryPlan(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	queryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid query ID",
+		})
+		return
+	}
+
+	graph, err := h.analysisService.GetQueryPlan(userID, queryID)
+	if err != nil {
+		status := planErrorStatus(err)
+		c.JSON(status, model.Response{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    graph,
+	})
+}
+
+// ResumeQueryPlan 恢复执行查询的计划
+// @Summary 恢复执行计划
+// @Description 从失败或中断的任务继续执行，已完成的任务不再执行。可以使用具有 queries:run 权限的 API Key
+// @Tags 分析
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "查询ID"
+// @Success 200 {object} model.Response{data=model.PlanGraphResponse}
+// @Failure 400 {object} model.Response{data=model.PlanGraphResponse}
+// @Failure 404 {object} model.Response
+// @Failure 409 {object} model.Response
+// @Router /analysis/query/{id}/plan/resume [post]
+func (h *AnalysisHandler) ResumeQueryPlan(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	queryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid query ID",
+		})
+		return
+	}
+
+	graph, err := h.analysisService.ResumeQueryPlan(c.Request.Context(), userID, queryID)
+	if err != nil {
+		status := planErrorStatus(err)
+		c.JSON(status, model.Response{
+			Code:    status,
+			Message: err.Error(),
+			Data:    graph,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Plan resumed successfully",
+		Data:    graph,
+	})
+}
+
+// RerunQueryTask 重新执行计划中的单个任务
+// @Summary 重新执行任务
+// @Description 重新执行计划中的单个任务，可以替换任务的输入。可以使用具有 queries:run 权限的 API Key
+// @Tags 分析
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "查询ID"
+// @Param task_id path string true "任务ID"
+// @Param request body model.RerunTaskRequest false "任务输入"
+// @Success 200 {object} model.Response{data=model.PlanGraphResponse}
+// @Failure 400 {object} model.Response{data=model.PlanGraphResponse}
+// @Failure 404 {object} model.Response
+// @Failure 409 {object} model.Response
+// @Router /analysis/query/{id}/plan/tasks/{task_id}/rerun [post]
+func (h *AnalysisHandler) RerunQueryTask(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	queryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid query ID",
+		})
+		return
+	}
+
+	var req model.RerunTaskRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.Response{
+				Code:    400,
+				Message: "Invalid request: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	graph, err := h.analysisService.RerunQueryTask(c.Request.Context(), userID, queryID, c.Param("task_id"), &req)
+	if err != nil {
+		status := planErrorStatus(err)
+		c.JSON(status, model.Response{
+			Code:    status,
+			Message: err.Error(),
+			Data:    graph,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Task rerun successfully",
+		Data:    graph,
+	})
+}
+
+// planErrorStatus 查询没有执行计划时返回404，计划正在执行时返回409，其他错误返回400
+func planErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNoQueryPlan):
+		return http.StatusNotFound
+	case errors.Is(err, types.ErrPlanRunning):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrivacyHandler 敏感数据脱敏审计接口处理器
+// @Description 敏感数据脱敏审计接口
+// @Tags 隐私
+// @Router /privacy [group]
+type PrivacyHandler struct {
+	privacyService *service.PrivacyService
+}
+
+func NewPrivacyHandler(privacyService *service.PrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{
+		privacyService: privacyService,
+	}
+}
+
+// MaskingRecords 脱敏审计记录
+// @Summary 脱敏审计记录
+// @Description 获取当前用户最近的脱敏记录，包括数据来源、用途以及被处理的列和值数量
+// @Tags 隐私
+// @Produce json
+// @Security ApiKeyAuth
+// @Param limit query int false "返回条数，默认100"
+// @Success 200 {object} model.Response{data=[]model.MaskingRecord}
+// @Router /privacy/masking [get]
+func (h *PrivacyHandler) MaskingRecords(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil {
+		limit = 100
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    h.privacyService.Records(userID, limit),
+	})
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"net/http"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SQLHandler SQL查询接口处理器
+// @Description 在上传的文件上执行SQL查询
+// @Tags SQL
+// @Router /sql [group]
+type SQLHandler struct {
+	sqlService *service.SQLService
+}
+
+func NewSQLHandler(sqlService *service.SQLService) *SQLHandler {
+	return &SQLHandler{
+		sqlService: sqlService,
+	}
+}
+
+// Query 执行SQL查询
+// @Summary 执行SQL查询
+// @Description 将当前用户已就绪的文件（Excel的每个工作表）作为表，执行只读的 SELECT 查询，支持跨文件关联
+// @Tags SQL
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body model.SQLQueryRequest true "SQL查询"
+// @Success 200 {object} model.Response{data=model.SQLQueryResponse}
+// @Failure 400 {object} model.Response
+// @Router /sql/query [post]
+func (h *SQLHandler) Query(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	var req model.SQLQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	response, err := h.sqlService.Query(userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    response,
+	})
+}
+
+// Tables 获取可查询的表
+// @Summary 获取可查询的表
+// @Description 获取当前用户的文件对应的表名、列名和列类型
+// @Tags SQL
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} model.Response{data=[]sqlengine.TableInfo}
+// @Failure 400 {object} model.Response
+// @Router /sql/tables [get]
+func (h *SQLHandler) Tables(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	tables, err := h.sqlService.Tables(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    tables,
+	})
+}
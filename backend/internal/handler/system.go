@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SystemHandler 系统运行状态接口处理器
+// @Description 系统运行状态接口，仅管理员可用
+// @Tags 系统
+// @Router /system [group]
+type SystemHandler struct {
+	scheduler *scheduler.Scheduler
+}
+
+func NewSystemHandler(s *scheduler.Scheduler) *SystemHandler {
+	return &SystemHandler{
+		scheduler: s,
+	}
+}
+
+// SchedulerStats 调度器指标
+// @Summary 调度器指标
+// @Description 获取专家任务、沙箱和LLM调用的并发上限、队列深度和等待时间，以及各用户等待中的请求数，仅管理员可用
+// @Tags 系统
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} model.Response
+// @Failure 403 {object} model.Response
+// @Router /system/scheduler [get]
+func (h *SystemHandler) SchedulerStats(c *gin.Context) {
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data: gin.H{
+			"limits": h.scheduler.Limits(),
+			"stats":  h.scheduler.Stats(),
+		},
+	})
+}
@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"errors"
+	"log"
 	"net/http"
-	"smart-analysis/internal/config"
+	"smart-analysis/internal/audit"
+	"smart-analysis/internal/auth"
 	"smart-analysis/internal/model"
 	"smart-analysis/internal/service"
-	"smart-analysis/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	// UserHandler 用户相关接口处理器
@@ -15,20 +17,22 @@ import (
 )
 
 type UserHandler struct {
-	userService *service.UserService
-	config      *config.Config
+	userService    *service.UserService
+	accountService *service.AccountService
+	tokens         *auth.Manager
 }
 
-func NewUserHandler(userService *service.UserService) *UserHandler {
+func NewUserHandler(userService *service.UserService, accountService *service.AccountService, tokens *auth.Manager) *UserHandler {
 	return &UserHandler{
-		userService: userService,
-		config:      config.Load(),
+		userService:    userService,
+		accountService: accountService,
+		tokens:         tokens,
 	}
 }
 
 // Register 用户注册
 // @Summary 用户注册
-// @Description 注册新用户
+// @Description 注册新用户，并向注册邮箱发送验证邮件
 // @Tags 用户
 // @Accept json
 // @Produce json
@@ -36,7 +40,7 @@ func NewUserHandler(userService *service.UserService) *UserHandler {
 // @Success 201 {object} model.Response{data=model.LoginResponse}
 // @Failure 400 {object} model.Response
 // @Failure 500 {object} model.Response
-// @Router /api/register [post]
+// @Router /user/register [post]
 func (h *UserHandler) Register(c *gin.Context) {
 	var req model.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -48,6 +52,11 @@ func (h *UserHandler) Register(c *gin.Context) {
 	}
 
 	user, err := h.userService.Register(&req)
+	event := audit.Event{Action: audit.ActionRegister, IP: c.ClientIP(), Detail: map[string]interface{}{"email": req.Email}}
+	if user != nil {
+		event.UserID = user.ID
+	}
+	audit.Record(c.Request.Context(), event.WithError(err))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.Response{
 			Code:    400,
@@ -55,9 +64,12 @@ func (h *UserHandler) Register(c *gin.Context) {
 		})
 		return
 	}
+	if err := h.accountService.SendEmailVerification(c.Request.Context(), user.ID); err != nil {
+		log.Printf("发送验证邮件失败: %v", err)
+	}
 
-	// 生成JWT token
-	token, err := utils.GenerateToken(user.ID, user.Username, h.config.JWTSecret)
+	// 签发访问令牌和刷新令牌
+	resp, err := h.issueTokens(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Response{
 			Code:    500,
@@ -69,10 +81,7 @@ func (h *UserHandler) Register(c *gin.Context) {
 	c.JSON(http.StatusCreated, model.Response{
 		Code:    201,
 		Message: "User registered successfully",
-		Data: model.LoginResponse{
-			Token: token,
-			User:  *user,
-		},
+		Data:    resp,
 	})
 }
 
@@ -86,8 +95,9 @@ func (h *UserHandler) Register(c *gin.Context) {
 // @Success 200 {object} model.Response{data=model.LoginResponse}
 // @Failure 400 {object} model.Response
 // @Failure 401 {object} model.Response
+// @Failure 423 {object} model.Response "连续登录失败次数过多，账号暂时锁定"
 // @Failure 500 {object} model.Response
-// @Router /api/login [post]
+// @Router /user/login [post]
 func (h *UserHandler) Login(c *gin.Context) {
 	var req model.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -99,6 +109,18 @@ func (h *UserHandler) Login(c *gin.Context) {
 	}
 
 	user, err := h.userService.Login(&req)
+	event := audit.Event{Action: audit.ActionLogin, IP: c.ClientIP(), Detail: map[string]interface{}{"email": req.Email}}
+	if user != nil {
+		event.UserID = user.ID
+	}
+	audit.Record(c.Request.Context(), event.WithError(err))
+	if errors.Is(err, service.ErrAccountLocked) {
+		c.JSON(http.StatusLocked, model.Response{
+			Code:    423,
+			Message: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, model.Response{
 			Code:    401,
@@ -107,8 +129,8 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 生成JWT token
-	token, err := utils.GenerateToken(user.ID, user.Username, h.config.JWTSecret)
+	// 签发访问令牌和刷新令牌
+	resp, err := h.issueTokens(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.Response{
 			Code:    500,
@@ -120,10 +142,7 @@ func (h *UserHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, model.Response{
 		Code:    200,
 		Message: "Login successful",
-		Data: model.LoginResponse{
-			Token: token,
-			User:  *user,
-		},
+		Data:    resp,
 	})
 }
 
@@ -135,7 +154,7 @@ func (h *UserHandler) Login(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Success 200 {object} model.Response{data=model.User}
 // @Failure 404 {object} model.Response
-// @Router /api/profile [get]
+// @Router /user/profile [get]
 func (h *UserHandler) GetProfile(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
@@ -157,7 +176,7 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 
 // UpdateProfile 更新用户资料
 // @Summary 更新用户资料
-// @Description 更新当前登录用户的资料
+// @Description 更新当前登录用户的资料。修改邮箱时向新邮箱发送验证邮件，验证后才生效
 // @Tags 用户
 // @Accept json
 // @Produce json
@@ -165,7 +184,7 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 // @Param data body model.UpdateProfileRequest true "更新资料请求体"
 // @Success 200 {object} model.Response{data=model.User}
 // @Failure 400 {object} model.Response
-// @Router /api/profile [put]
+// @Router /user/profile [put]
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
@@ -178,6 +197,19 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	// 新邮箱需要验证，先发送验证邮件，邮箱已被使用时不修改其他资料
+	message := "Profile updated successfully"
+	if current, err := h.userService.GetUserByID(userID); err == nil && req.Email != "" && req.Email != current.Email {
+		if err := h.accountService.RequestEmailChange(c.Request.Context(), userID, req.Email); err != nil {
+			c.JSON(http.StatusBadRequest, model.Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		message = "Profile updated, verification email sent to the new address"
+	}
+
 	user, err := h.userService.UpdateProfile(userID, &req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.Response{
@@ -189,7 +221,180 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 
 	c.JSON(http.StatusOK, model.Response{
 		Code:    200,
-		Message: "Profile updated successfully",
+		Message: message,
 		Data:    user,
 	})
 }
+
+// Refresh 刷新令牌
+// @Summary 刷新令牌
+// @Description 使用刷新令牌换发新的访问令牌和刷新令牌，旧的刷新令牌随即失效；已使用过的刷新令牌再次使用时，同一登录的全部刷新令牌作废
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param data body model.RefreshTokenRequest true "刷新令牌"
+// @Success 200 {object} model.Response{data=model.LoginResponse}
+// @Failure 400 {object} model.Response
+// @Failure 401 {object} model.Response
+// @Router /user/refresh [post]
+func (h *UserHandler) Refresh(c *gin.Context) {
+	var req model.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	var user *model.User
+	pair, err := h.tokens.Refresh(req.RefreshToken, func(userID int) (string, error) {
+		var err error
+		user, err = h.userService.GetUserByID(userID)
+		if err != nil {
+			return "", err
+		}
+		return user.Username, nil
+	})
+	event := audit.Event{Action: audit.ActionTokenRefresh, IP: c.ClientIP()}
+	if user != nil {
+		event.UserID = user.ID
+	}
+	audit.Record(c.Request.Context(), event.WithError(err))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, model.Response{
+			Code:    401,
+			Message: "Invalid refresh token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Token refreshed",
+		Data:    loginResponse(user, pair),
+	})
+}
+
+// Logout 注销登录
+// @Summary 注销登录
+// @Description 撤销当前访问令牌和同一登录的刷新令牌，all 为 true 时撤销该用户的全部令牌
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param data body model.LogoutRequest false "注销选项"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Router /user/logout [post]
+func (h *UserHandler) Logout(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	var req model.LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.Response{
+				Code:    400,
+				Message: "Invalid request: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	value, _ := c.Get("token_claims")
+	claims, _ := value.(*auth.Claims)
+	if req.All {
+		h.tokens.RevokeUser(userID)
+	} else {
+		h.tokens.Revoke(claims, req.RefreshToken)
+	}
+	audit.Record(c.Request.Context(), audit.Event{
+		Action: audit.ActionLogout,
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{"all": req.All},
+	})
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Logged out",
+	})
+}
+
+// ChangePassword 修改密码
+// @Summary 修改密码
+// @Description 验证原密码后修改密码，撤销该用户已签发的全部令牌并返回新的令牌
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param data body model.ChangePasswordRequest true "原密码和新密码"
+// @Success 200 {object} model.Response{data=model.LoginResponse}
+// @Failure 400 {object} model.Response
+// @Router /user/password [put]
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	var req model.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	err := h.userService.ChangePassword(userID, &req)
+	audit.Record(c.Request.Context(), audit.Event{Action: audit.ActionPasswordChange, IP: c.ClientIP()}.WithError(err))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// 修改密码后其他设备上的登录全部失效
+	h.tokens.RevokeUser(userID)
+	user, err := h.userService.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.Response{
+			Code:    404,
+			Message: err.Error(),
+		})
+		return
+	}
+	resp, err := h.issueTokens(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.Response{
+			Code:    500,
+			Message: "Failed to generate token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Password changed",
+		Data:    resp,
+	})
+}
+
+// issueTokens 为用户签发访问令牌和刷新令牌
+func (h *UserHandler) issueTokens(user *model.User) (*model.LoginResponse, error) {
+	pair, err := h.tokens.Issue(user.ID, user.Username)
+	if err != nil {
+		return nil, err
+	}
+	return loginResponse(user, pair), nil
+}
+
+// loginResponse 组装登录响应
+func loginResponse(user *model.User, pair *auth.TokenPair) *model.LoginResponse {
+	return &model.LoginResponse{
+		Token:            pair.AccessToken,
+		ExpiresAt:        pair.AccessExpiresAt,
+		RefreshToken:     pair.RefreshToken,
+		RefreshExpiresAt: pair.RefreshExpiresAt,
+		User:             *user,
+	}
+}
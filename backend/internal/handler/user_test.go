@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"smart-analysis/internal/audit"
+	"smart-analysis/internal/auth"
+	"smart-analysis/internal/middleware"
+	"smart-analysis/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuditRecordsAuthenticatedUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	users := service.NewUserService()
+	userHandler := NewUserHandler(users, service.NewAccountService(users, ""), auth.GetGlobal())
+	apiKeyHandler := NewAPIKeyHandler(service.NewAPIKeyService(users))
+
+	router := gin.New()
+	router.POST("/user/logout", middleware.AuthMiddleware(), userHandler.Logout)
+	router.DELETE("/apikeys/:id", middleware.AuthMiddleware(), apiKeyHandler.Revoke)
+
+	// 处理器记录审计事件时不指定用户，由认证中间件写入请求上下文的用户ID补全
+	for _, tc := range []struct {
+		method, path, action string
+	}{
+		{http.MethodDelete, "/apikeys/99", audit.ActionAPIKeyRevoke},
+		{http.MethodPost, "/user/logout", audit.ActionLogout},
+	} {
+		pair, err := auth.GetGlobal().Issue(7, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		events, _, err := audit.GetGlobal().Query(audit.Filter{Action: tc.action})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) == 0 || events[len(events)-1].UserID != 7 {
+			t.Errorf("%s 审计事件的用户不正确: %+v", tc.action, events)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package handler
+
+import (
+	"net/http"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkspaceHandler 组织、团队空间和邀请接口处理器
+// @Description 组织和团队空间管理，成员共享文件、会话、看板和LLM配置
+// @Tags 团队空间
+// @Router /workspace [group]
+type WorkspaceHandler struct {
+	workspaceService *service.WorkspaceService
+	fileService      *service.FileService
+}
+
+func NewWorkspaceHandler(workspaceService *service.WorkspaceService, fileService *service.FileService) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		workspaceService: workspaceService,
+		fileService:      fileService,
+	}
+}
+
+// CreateOrganization 创建组织
+// @Summary 创建组织
+// @Description 创建组织，创建者为组织所有者，可以在组织下创建团队空间
+// @Tags 团队空间
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body model.OrganizationRequest true "组织"
+// @Success 201 {object} model.Response{data=model.Organization}
+// @Failure 400 {object} model.Response
+// @Router /org [post]
+func (h *WorkspaceHandler) CreateOrganization(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	var req model.OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	org, err := h.workspaceService.CreateOrganization(userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.Response{
+		Code:    201,
+		Message: "Organization created",
+		Data:    org,
+	})
+}
+
+// ListOrganizations 获取组织列表
+// @Summary 获取组织列表
+// @Description 获取当前用户拥有的组织和所在团队空间所属的组织
+// @Tags 团队空间
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} model.Response{data=[]model.Organization}
+// @Router /org [get]
+func (h *WorkspaceHandler) ListOrganizations(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    h.workspaceService.ListOrganizations(userID),
+	})
+}
+
+// Create 在组织下创建团队空间
+// @Summary 创建团队空间
+// @Description 在组织下创建团队空间，只有组织所有者可以创建，创建者成为空间所有者；token_budget 为每月LLM token预算，0表示不限
+// @Tags 团队空间
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "组织ID"
+// @Param request body model.WorkspaceRequest true "团队空间"
+// @Success 201 {object} model.Response{data=model.Workspace}
+// @Failure 400 {object} model.Response
+// @Router /org/{id}/workspaces [post]
+func (h *WorkspaceHandler) Create(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	orgID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid organization ID",
+		})
+		return
+	}
+
+	var req model.WorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	workspace, err := h.workspaceService.CreateWorkspace(userID, orgID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.Response{
+		Code:    201,
+		Message: "Workspace created",
+		Data:    workspace,
+	})
+}
+
+// List 获取团队空间列表
+// @Summary 获取团队空间列表
+// @Description 获取当前用户所在的团队空间及其角色
+// @Tags 团队空间
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} model.Response{data=[]model.Workspace}
+// @Router /workspace [get]
+func (h *WorkspaceHandler) List(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    h.workspaceService.ListWorkspaces(userID),
+	})
+}
+
+// Get 获取团队空间
+// @Summary 获取团队空间
+// @Tags 团队空间
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "团队空间ID"
+// @Success 200 {object} model.Response{data=model.Workspace}
+// @Failure 400 {object} model.Response
+// @Router /workspace/{id} [get]
+func (h *WorkspaceHandler) Get(c *gin.Context) {
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.workspaceService.GetWorkspace(userID, id)
+	})
+}
+
+// Update 修改团队空间
+// @Summary 修改团队空间
+// @Description 修改团队空间的名称、描述和每月LLM token预算，需要空间所有者权限
+// @Tags 团队空间
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "团队空间ID"
+// @Param request body model.WorkspaceRequest true "团队空间"
+// @Success 200 {object} model.Response{data=model.Workspace}
+// @Failure 400 {object} model.Response
+// @Router /workspace/{id} [put]
+func (h *WorkspaceHandler) Update(c *gin.Context) {
+	var req model.WorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.workspaceService.UpdateWorkspace(userID, id, &req)
+	})
+}
+
+// Files 获取共享到团队空间的文件
+// @Summary 获取团队空间的文件
+// @Tags 团队空间
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "团队空间ID"
+// @Success 200 {object} model.Response{data=[]model.File}
+// @Failure 400 {object} model.Response
+// @Router /workspace/{id}/files [get]
+func (h *WorkspaceHandler) Files(c *gin.Context) {
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.fileService.WorkspaceFiles(userID, id)
+	})
+}
+
+// Members 获取团队空间成员
+// @Summary 获取团队空间成员
+// @Tags 团队空间
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "团队空间ID"
+// @Success 200 {object} model.Response{data=[]model.WorkspaceMember}
+// @Failure 400 {object} model.Response
+// @Router /workspace/{id}/members [get]
+func (h *WorkspaceHandler) Members(c *gin.Context) {
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.workspaceService.ListMembers(userID, id)
+	})
+}
+
+// UpdateMember 修改成员角色
+// @Summary 修改成员角色
+// @Description 修改成员的角色（owner、editor、viewer），需要空间所有者权限，空间至少保留一名所有者
+// @Tags 团队空间
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "团队空间ID"
+// @Param user_id path int true "成员的用户ID"
+// @Param request body model.MemberRoleRequest true "角色"
+// @Success 200 {object} model.Response{data=model.WorkspaceMember}
+// @Failure 400 {object} model.Response
+// @Router /workspace/{id}/members/{user_id} [put]
+func (h *WorkspaceHandler) UpdateMember(c *gin.Context) {
+	var req model.MemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	h.respondWith(c, "user_id", func(userID, id, memberID int) (interface{}, error) {
+		return h.workspaceService.UpdateMemberRole(userID, id, memberID, req.Role)
+	})
+}
+
+// RemoveMember 移除成员
+// @Summary 移除成员
+// @Description 空间所有者可以移除成员，其他成员只能移除自己以退出空间
+// @Tags 团队空间
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "团队空间ID"
+// @Param user_id path int true "成员的用户ID"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Router /workspace/{id}/members/{user_id} [delete]
+func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
+	h.respondWith(c, "user_id", func(userID, id, memberID int) (interface{}, error) {
+		return nil, h.workspaceService.RemoveMember(userID, id, memberID)
+	})
+}
+
+// Invite 邀请用户加入团队空间
+// @Summary 邀请成员
+// @Description 按邮箱邀请用户加入团队空间，邀请7天内有效，被邀请人登录后用令牌接受；需要空间所有者权限
+// @Tags 团队空间
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "团队空间ID"
+// @Param request body model.InvitationRequest true "邀请"
+// @Success 201 {object} model.Response{data=model.Invitation}
+// @Failure 400 {object} model.Response
+// @Router /workspace/{id}/invitations [post]
+func (h *WorkspaceHandler) Invite(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	workspaceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid workspace ID",
+		})
+		return
+	}
+
+	var req model.InvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	invitation, err := h.workspaceService.Invite(userID, workspaceID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.Response{
+		Code:    201,
+		Message: "Invitation created",
+		Data:    invitation,
+	})
+}
+
+// Invitations 获取团队空间的邀请
+// @Summary 获取团队空间的邀请
+// @Tags 团队空间
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "团队空间ID"
+// @Success 200 {object} model.Response{data=[]model.Invitation}
+// @Failure 400 {object} model.Response
+// @Router /workspace/{id}/invitations [get]
+func (h *WorkspaceHandler) Invitations(c *gin.Context) {
+	h.respond(c, func(userID, id int) (interface{}, error) {
+		return h.workspaceService.ListInvitations(userID, id)
+	})
+}
+
+// RevokeInvitation 撤销邀请
+// @Summary 撤销邀请
+// @Tags 团队空间
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "团队空间ID"
+// @Param invitation_id path int true "邀请ID"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Router /workspace/{id}/invitations/{invitation_id} [delete]
+func (h *WorkspaceHandler) RevokeInvitation(c *gin.Context) {
+	h.respondWith(c, "invitation_id", func(userID, id, invitationID int) (interface{}, error) {
+		return nil, h.workspaceService.RevokeInvitation(userID, id, invitationID)
+	})
+}
+
+// MyInvitations 获取发给当前用户的邀请
+// @Summary 获取我的邀请
+// @Description 获取发给当前用户邮箱且未过期的待处理邀请
+// @Tags 团队空间
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} model.Response{data=[]model.Invitation}
+// @Failure 400 {object} model.Response
+// @Router /invitation [get]
+func (h *WorkspaceHandler) MyInvitations(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	invitations, err := h.workspaceService.PendingInvitations(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    invitations,
+	})
+}
+
+// AcceptInvitation 接受邀请
+// @Summary 接受邀请
+// @Description 用邀请令牌加入团队空间，当前用户的邮箱必须与邀请的邮箱一致
+// @Tags 团队空间
+// @Produce json
+// @Security ApiKeyAuth
+// @Param token path string true "邀请令牌"
+// @Success 200 {object} model.Response{data=model.WorkspaceMember}
+// @Failure 400 {object} model.Response
+// @Router /invitation/{token}/accept [post]
+func (h *WorkspaceHandler) AcceptInvitation(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	member, err := h.workspaceService.AcceptInvitation(userID, c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Invitation accepted",
+		Data:    member,
+	})
+}
+
+// DeclineInvitation 拒绝邀请
+// @Summary 拒绝邀请
+// @Tags 团队空间
+// @Produce json
+// @Security ApiKeyAuth
+// @Param token path string true "邀请令牌"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Router /invitation/{token}/decline [post]
+func (h *WorkspaceHandler) DeclineInvitation(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	if err := h.workspaceService.DeclineInvitation(userID, c.Param("token")); err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Invitation declined",
+	})
+}
+
+// respond 解析团队空间ID并执行操作
+func (h *WorkspaceHandler) respond(c *gin.Context, fn func(userID, id int) (interface{}, error)) {
+	h.respondWith(c, "", func(userID, id, _ int) (interface{}, error) {
+		return fn(userID, id)
+	})
+}
+
+// respondWith 解析团队空间ID和路径参数 param 中的ID并执行操作，param 为空时只解析团队空间ID
+func (h *WorkspaceHandler) respondWith(c *gin.Context, param string, fn func(userID, id, subID int) (interface{}, error)) {
+	userID := c.GetInt("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: "Invalid workspace ID",
+		})
+		return
+	}
+	subID := 0
+	if param != "" {
+		if subID, err = strconv.Atoi(c.Param(param)); err != nil {
+			c.JSON(http.StatusBadRequest, model.Response{
+				Code:    400,
+				Message: "Invalid " + param,
+			})
+			return
+		}
+	}
+
+	data, err := fn(userID, id, subID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Response{
+		Code:    200,
+		Message: "Success",
+		Data:    data,
+	})
+}
@@ -9,6 +9,9 @@ import (
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 	"smart-analysis/internal/agents"
+	"smart-analysis/internal/pii"
+	"smart-analysis/internal/prompts"
+	"smart-analysis/internal/scheduler"
 	"smart-analysis/internal/types"
 	"smart-analysis/internal/utils/sanbox"
 )
@@ -86,6 +89,43 @@ func (m *AgentManager) ProcessQuery(ctx context.Context, query string) (*schema.
 	return mainAgent.Generate(ctx, messages)
 }
 
+// planRunner 多智能体系统中恢复和重新执行计划的部分
+type planRunner interface {
+	ResumePlan(ctx context.Context, planID string) (*types.ExecutionPlan, error)
+	RerunTask(ctx context.Context, planID, taskID string, input interface{}) (*types.ExecutionPlan, error)
+}
+
+// ResumePlan 恢复执行已持久化的计划，需要多智能体系统
+func (m *AgentManager) ResumePlan(ctx context.Context, planID string) (*types.ExecutionPlan, error) {
+	runner, err := m.planRunner()
+	if err != nil {
+		return nil, err
+	}
+	return runner.ResumePlan(ctx, planID)
+}
+
+// RerunTask 重新执行计划中的单个任务及其下游任务，需要多智能体系统
+func (m *AgentManager) RerunTask(ctx context.Context, planID, taskID string, input interface{}) (*types.ExecutionPlan, error) {
+	runner, err := m.planRunner()
+	if err != nil {
+		return nil, err
+	}
+	return runner.RerunTask(ctx, planID, taskID, input)
+}
+
+// planRunner 返回多智能体系统
+func (m *AgentManager) planRunner() (planRunner, error) {
+	agent, err := m.GetAgent(types.AgentTypeMulti)
+	if err != nil {
+		return nil, err
+	}
+	runner, ok := agent.(planRunner)
+	if !ok {
+		return nil, fmt.Errorf("智能体 %s 不支持执行计划", agent.GetType())
+	}
+	return runner, nil
+}
+
 // ProcessQueryWithHistory 处理带历史记录的查询
 func (m *AgentManager) ProcessQueryWithHistory(ctx context.Context, messages []*schema.Message) (*schema.Message, error) {
 	mainAgent, err := m.GetMainAgent()
@@ -218,11 +258,21 @@ func (m *AgentManager) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// defaultModelProvider 未指定LLM提供商时使用的并发限制分组
+const defaultModelProvider = "default"
+
 // AgentSystemBuilder 智能体系统构建器
 type AgentSystemBuilder struct {
 	chatModel     model.BaseChatModel
 	pythonSandbox *sanbox.PythonSandbox
 	tools         []tool.BaseTool
+	prompts       *prompts.Library
+	execution     *types.ExecutionPolicy
+	planStore     types.PlanStore
+	scheduler     *scheduler.Scheduler
+	modelProvider string
+	masker        *pii.Masker
+	datasets      types.DatasetResolver
 	maxSteps      int
 	enableDebug   bool
 }
@@ -252,6 +302,50 @@ func (b *AgentSystemBuilder) WithTools(tools []tool.BaseTool) *AgentSystemBuilde
 	return b
 }
 
+// WithPrompts 设置提示词模板库
+func (b *AgentSystemBuilder) WithPrompts(library *prompts.Library) *AgentSystemBuilder {
+	b.prompts = library
+	return b
+}
+
+// WithExecutionPolicy 设置计划执行策略（重试、失败处理和重新规划）
+func (b *AgentSystemBuilder) WithExecutionPolicy(policy *types.ExecutionPolicy) *AgentSystemBuilder {
+	b.execution = policy
+	return b
+}
+
+// WithPlanStore 设置执行计划存储，用于恢复中断的计划
+func (b *AgentSystemBuilder) WithPlanStore(store types.PlanStore) *AgentSystemBuilder {
+	b.planStore = store
+	return b
+}
+
+// WithScheduler 设置调度器，限制专家任务、沙箱和LLM调用的并发数
+func (b *AgentSystemBuilder) WithScheduler(s *scheduler.Scheduler) *AgentSystemBuilder {
+	b.scheduler = s
+	return b
+}
+
+// WithModelProvider 设置聊天模型所属的LLM提供商，用于按提供商限制并发
+func (b *AgentSystemBuilder) WithModelProvider(provider string) *AgentSystemBuilder {
+	b.modelProvider = provider
+	return b
+}
+
+// WithPIIMasker 设置发送给LLM前脱敏提示词的脱敏器
+func (b *AgentSystemBuilder) WithPIIMasker(masker *pii.Masker) *AgentSystemBuilder {
+	b.masker = masker
+	return b
+}
+
+// WithDatasets 设置数据集解析器，智能体和工具只能读取用户可见的数据视图。
+// 智能体系统按用户构建时使用该用户的解析器，所有用户共用时使用按上下文中的用户解析的解析器；
+// 未设置时工具直接读取给定的文件路径
+func (b *AgentSystemBuilder) WithDatasets(datasets types.DatasetResolver) *AgentSystemBuilder {
+	b.datasets = datasets
+	return b
+}
+
 // WithMaxSteps 设置最大步数
 func (b *AgentSystemBuilder) WithMaxSteps(maxSteps int) *AgentSystemBuilder {
 	b.maxSteps = maxSteps
@@ -271,11 +365,32 @@ func (b *AgentSystemBuilder) Build(ctx context.Context) (*AgentManager, error) {
 		return nil, fmt.Errorf("chat model is required")
 	}
 
+	sched := b.scheduler
+	if sched == nil {
+		sched = scheduler.GetGlobal()
+	}
+	provider := b.modelProvider
+	if provider == "" {
+		provider = defaultModelProvider
+	}
+	if b.pythonSandbox != nil {
+		b.pythonSandbox.SetScheduler(sched)
+	}
+	masker := b.masker
+	if masker == nil {
+		masker = pii.NewMasker("")
+	}
+
 	// 创建配置
 	config := &types.AgentConfig{
-		ChatModel:     b.chatModel,
+		ChatModel:     agents.NewRedactingChatModel(agents.NewAuditedChatModel(agents.NewLimitedChatModel(b.chatModel, sched, provider), provider), masker),
 		PythonSandbox: b.pythonSandbox,
 		Tools:         b.tools,
+		Prompts:       b.prompts,
+		Execution:     b.execution,
+		PlanStore:     b.planStore,
+		Scheduler:     sched,
+		Datasets:      b.datasets,
 		MaxSteps:      b.maxSteps,
 		EnableDebug:   b.enableDebug,
 		Metadata:      make(map[string]interface{}),
@@ -307,12 +422,14 @@ func (b *AgentSystemBuilder) Build(ctx context.Context) (*AgentManager, error) {
 	// 为了向后兼容，也注册为Main类型
 	manager.RegisterAgent(types.AgentTypeMain, mainAgent)
 
-	// 创建React智能体
-	reactAgent, err := agents.NewReactAgent(ctx, config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create react agent: %w", err)
+	// 创建React智能体，需要支持工具调用的聊天模型
+	if _, ok := config.ChatModel.(model.ToolCallingChatModel); ok {
+		reactAgent, err := agents.NewReactAgent(ctx, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create react agent: %w", err)
+		}
+		manager.RegisterAgent(types.AgentTypeReact, reactAgent)
 	}
-	manager.RegisterAgent(types.AgentTypeReact, reactAgent)
 
 	// 创建分析智能体
 	if b.pythonSandbox != nil {
@@ -1,72 +1,151 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+
+	"smart-analysis/internal/audit"
+	"smart-analysis/internal/auth"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/scheduler"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-// AuthMiddleware JWT认证中间件
-func AuthMiddleware() gin.HandlerFunc {
+// APIKeyAuthenticator 校验 API Key，返回 Key 的信息，超出请求数上限时返回 auth.ErrRateLimited
+type APIKeyAuthenticator interface {
+	Authenticate(rawKey string) (*model.APIKey, error)
+}
+
+var (
+	apiKeys   APIKeyAuthenticator
+	apiKeysMu sync.RWMutex
+)
+
+// SetAPIKeyAuthenticator 设置 API Key 校验器，未设置时不接受 API Key
+func SetAPIKeyAuthenticator(authenticator APIKeyAuthenticator) {
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+	apiKeys = authenticator
+}
+
+// AuthMiddleware JWT认证中间件。指定 scopes 时同时接受具有全部这些权限的 API Key，
+// API Key 通过 Authorization: Bearer 或 X-API-Key 请求头传递；未指定时只接受JWT
+func AuthMiddleware(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := c.GetHeader("X-API-Key"); key != "" {
+			authenticateAPIKey(c, key, scopes)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code":    401,
-				"message": "Authorization header required",
-			})
-			c.Abort()
+			deny(c, http.StatusUnauthorized, "Authorization header required")
 			return
 		}
 
 		// 检查Bearer格式
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code":    401,
-				"message": "Authorization header format must be Bearer {token}",
-			})
-			c.Abort()
+			deny(c, http.StatusUnauthorized, "Authorization header format must be Bearer {token}")
 			return
 		}
-
-		tokenString := parts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// 验证签名方法
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte("your-secret-key"), nil // 这里应该从配置中获取
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code":    401,
-				"message": "Invalid token",
-			})
-			c.Abort()
+		if strings.HasPrefix(parts[1], model.APIKeyPrefix) {
+			authenticateAPIKey(c, parts[1], scopes)
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code":    401,
-				"message": "Invalid token claims",
-			})
-			c.Abort()
+		claims, err := auth.GetGlobal().Verify(parts[1])
+		if errors.Is(err, auth.ErrRevoked) {
+			deny(c, http.StatusUnauthorized, "Token revoked")
+			return
+		}
+		if err != nil {
+			deny(c, http.StatusUnauthorized, "Invalid token")
 			return
 		}
 
 		// 将用户ID存储到context中
-		c.Set("user_id", int(claims["user_id"].(float64)))
-		c.Set("username", claims["username"].(string))
+		setUser(c, claims.UserID, claims.Username)
+		c.Set("token_claims", claims)
+		c.Next()
+	}
+}
+
+// authenticateAPIKey 校验 API Key 及其权限，请求以 Key 的服务账号或创建者身份执行，并记录到审计日志
+func authenticateAPIKey(c *gin.Context, rawKey string, scopes []string) {
+	apiKeysMu.RLock()
+	authenticator := apiKeys
+	apiKeysMu.RUnlock()
+	if authenticator == nil || len(scopes) == 0 {
+		deny(c, http.StatusForbidden, "API keys are not accepted for this endpoint")
+		return
+	}
+
+	key, err := authenticator.Authenticate(rawKey)
+	if errors.Is(err, auth.ErrRateLimited) {
+		c.Header("Retry-After", "60")
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, model.Response{Code: http.StatusTooManyRequests, Message: "API key rate limit exceeded"})
+		return
+	}
+	if err != nil {
+		deny(c, http.StatusUnauthorized, "Invalid API key")
+		return
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(key.Scopes, scope) {
+			deny(c, http.StatusForbidden, "API key is missing scope "+scope)
+			return
+		}
+	}
+
+	audit.Record(c.Request.Context(), audit.Event{
+		UserID:   key.PrincipalID,
+		Action:   audit.ActionAPIKeyUse,
+		Resource: fmt.Sprintf("apikey:%d", key.ID),
+		IP:       c.ClientIP(),
+		Detail:   map[string]interface{}{"method": c.Request.Method, "path": c.Request.URL.Path},
+	})
+	setUser(c, key.PrincipalID, key.PrincipalName)
+	c.Set("api_key_id", key.ID)
+	c.Next()
+}
+
+// setUser 记录发起请求的用户。用户ID同时写入请求的上下文，审计日志和调度器等服务据此识别用户
+func setUser(c *gin.Context, userID int, username string) {
+	c.Set("user_id", userID)
+	c.Set("username", username)
+	c.Request = c.Request.WithContext(scheduler.WithUser(c.Request.Context(), strconv.Itoa(userID)))
+}
+
+// AdminMiddleware 管理员权限中间件，需在 AuthMiddleware 之后使用
+func AdminMiddleware(isAdmin func(userID int) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isAdmin(c.GetInt("user_id")) {
+			deny(c, http.StatusForbidden, "Admin privileges required")
+			return
+		}
 		c.Next()
 	}
 }
 
+// deny 拒绝未认证（401）或权限不足（403）的请求并记录审计事件
+func deny(c *gin.Context, status int, message string) {
+	audit.Record(c.Request.Context(), audit.Event{
+		Action: audit.ActionAuthDenied,
+		Status: audit.StatusFailure,
+		Error:  message,
+		IP:     c.ClientIP(),
+		Detail: map[string]interface{}{"method": c.Request.Method, "path": c.Request.URL.Path},
+	})
+	c.AbortWithStatusJSON(status, model.Response{Code: status, Message: message})
+}
+
 // CORSMiddleware CORS中间件
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -1,5 +1,12 @@
 package model
 
+import (
+	"smart-analysis/internal/pii"
+	"smart-analysis/internal/sqlengine"
+	"smart-analysis/internal/types"
+	"time"
+)
+
 // 用户相关请求结构
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required"`
@@ -17,6 +24,78 @@ type UpdateProfileRequest struct {
 	Email    string `json:"email"`
 }
 
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// VerifyEmailRequest 验证邮箱，Token 来自验证邮件中的链接
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ForgotPasswordRequest 申请重置密码，按邮箱发送重置链接或按手机号发送验证码
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"omitempty,email"`
+	Phone string `json:"phone"`
+}
+
+// ResetPasswordRequest 重置密码，使用邮件中的 Token，或手机号和短信验证码
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	Phone       string `json:"phone"`
+	Code        string `json:"code"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// PhoneCodeRequest 申请短信验证码
+type PhoneCodeRequest struct {
+	Phone   string `json:"phone" binding:"required"`
+	Purpose string `json:"purpose" binding:"required,oneof=register login reset_password delete_account bind_phone"`
+}
+
+// PhoneRegisterRequest 使用手机号注册，密码为空时只能通过短信验证码登录
+type PhoneRegisterRequest struct {
+	Phone    string `json:"phone" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"omitempty,min=6"`
+}
+
+// PhoneCodeLoginRequest 使用手机号和短信验证码登录，或绑定手机号
+type PhoneCodeLoginRequest struct {
+	Phone string `json:"phone" binding:"required"`
+	Code  string `json:"code" binding:"required"`
+}
+
+// DeleteAccountRequest 注销账号，需要验证密码或绑定手机号收到的短信验证码
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest 注销当前访问令牌；RefreshToken 不为空时同时作废同一登录的刷新令牌，All 为 true 时注销全部会话
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"`
+}
+
+// OAuthCallbackRequest 第三方登录回调，前端从回调地址的查询参数中取得 code 和 state
+type OAuthCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// OAuthAuthorizeResponse 第三方登录的授权页地址，前端跳转到 AuthURL，回调时原样提交 State
+type OAuthAuthorizeResponse struct {
+	AuthURL string `json:"auth_url"`
+	State   string `json:"state"`
+}
+
 // 分析相关请求结构
 type QueryRequest struct {
 	SessionID int    `json:"session_id"`
@@ -24,11 +103,17 @@ type QueryRequest struct {
 	FileID    *int   `json:"file_id"`
 }
 
+// RerunTaskRequest 重新执行计划中的单个任务，Input 为空时沿用原输入
+type RerunTaskRequest struct {
+	Input interface{} `json:"input"`
+}
+
+// VisualizationRequest 可视化请求，用自然语言描述要绘制的图表
 type VisualizationRequest struct {
-	SessionID int    `json:"session_id"`
+	SessionID int    `json:"session_id" binding:"required"`
 	Query     string `json:"query" binding:"required"`
 	FileID    int    `json:"file_id" binding:"required"`
-	ChartType string `json:"chart_type"` // bar, line, pie, scatter
+	ChartType string `json:"chart_type" binding:"omitempty,oneof=bar line area pie scatter heatmap radar"` // 为空时由LLM选择
 }
 
 type ReportRequest struct {
@@ -39,16 +124,100 @@ type ReportRequest struct {
 }
 
 type CreateSessionRequest struct {
-	Name   string `json:"name" binding:"required"`
-	FileID *int   `json:"file_id"`
+	Name        string `json:"name" binding:"required"`
+	FileID      *int   `json:"file_id"`
+	WorkspaceID int    `json:"workspace_id"` // 在团队空间中创建会话，空间成员均可查看
+}
+
+// SQLQueryRequest 在上传文件上执行SQL，Limit 为返回的最大行数
+type SQLQueryRequest struct {
+	SQL   string `json:"sql" binding:"required"`
+	Limit int    `json:"limit"`
+}
+
+// DataSourceRequest 注册或修改数据源，修改时 Password 为空表示不修改密码
+type DataSourceRequest struct {
+	Name         string `json:"name" binding:"required"`
+	Type         string `json:"type" binding:"required,oneof=mysql postgres sqlite"`
+	Host         string `json:"host"`
+	Port         int    `json:"port"`
+	Database     string `json:"database" binding:"required"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	SSLMode      string `json:"ssl_mode" binding:"omitempty,oneof=disable require verify-ca verify-full"`
+	SSLRootCert  string `json:"ssl_root_cert"`
+	ScanInterval int    `json:"scan_interval" binding:"min=0"`
+}
+
+// SensitivePolicyRequest 设置敏感列的脱敏策略，替换全部已有策略
+type SensitivePolicyRequest struct {
+	Columns []pii.Column `json:"columns"`
+}
+
+// AccessPolicyRequest 创建或修改文件的访问策略，user_id 和 team 必须指定其一
+type AccessPolicyRequest struct {
+	UserID    int      `json:"user_id"`
+	Team      string   `json:"team"`
+	RowFilter string   `json:"row_filter"`
+	Columns   []string `json:"columns"`
 }
 
 // LLM配置相关请求结构
 type LLMConfigRequest struct {
-	Provider  string `json:"provider" binding:"required"`
-	APIKey    string `json:"api_key" binding:"required"`
-	Model     string `json:"model" binding:"required"`
-	IsDefault bool   `json:"is_default"`
+	Provider    string `json:"provider" binding:"required"`
+	APIKey      string `json:"api_key" binding:"required"`
+	Model       string `json:"model" binding:"required"`
+	IsDefault   bool   `json:"is_default"`
+	WorkspaceID int    `json:"workspace_id"` // 设置团队空间共享的配置，需要空间所有者权限
+}
+
+// 团队空间相关请求结构
+type OrganizationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type WorkspaceRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	TokenBudget int    `json:"token_budget" binding:"min=0"`
+}
+
+// InvitationRequest 邀请用户加入团队空间，Role 为空时为 viewer
+type InvitationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"omitempty,oneof=owner editor viewer"`
+}
+
+type MemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner editor viewer"`
+}
+
+// ShareFileRequest 将文件共享到团队空间，WorkspaceID 为0时取消共享
+type ShareFileRequest struct {
+	WorkspaceID int `json:"workspace_id"`
+}
+
+// APIKeyRequest 创建 API Key。服务 Key 需要指定团队空间，由空间所有者创建
+type APIKeyRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Type          string   `json:"type" binding:"omitempty,oneof=personal service"`
+	WorkspaceID   int      `json:"workspace_id"`
+	Scopes        []string `json:"scopes" binding:"required,min=1,dive,oneof=files:read queries:run sessions:manage"`
+	RateLimit     int      `json:"rate_limit" binding:"min=0"`      // 每分钟请求数上限，为0时使用默认值
+	ExpiresInDays int      `json:"expires_in_days" binding:"min=0"` // 为0时不过期
+}
+
+// APIKeyResponse 创建 API Key 的结果，完整的 Key 只在创建时返回一次
+type APIKeyResponse struct {
+	APIKey *APIKey `json:"api_key"`
+	Key    string  `json:"key"`
+}
+
+type DashboardRequest struct {
+	Name        string            `json:"name" binding:"required"`
+	Description string            `json:"description"`
+	WorkspaceID int               `json:"workspace_id"`
+	Widgets     []DashboardWidget `json:"widgets"`
 }
 
 // 通用响应结构
@@ -59,8 +228,11 @@ type Response struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token            string    `json:"token"` // 短期访问令牌
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	User             User      `json:"user"`
 }
 
 type FileUploadResponse struct {
@@ -68,18 +240,13 @@ type FileUploadResponse struct {
 }
 
 type QueryResponse struct {
+	QueryID   int         `json:"query_id"`
 	Answer    string      `json:"answer"`
 	Data      interface{} `json:"data,omitempty"`
 	QueryType string      `json:"query_type"`
 	Status    string      `json:"status"`
 }
 
-type VisualizationResponse struct {
-	ChartData interface{} `json:"chart_data"`
-	ChartType string      `json:"chart_type"`
-	Title     string      `json:"title"`
-}
-
 type ReportResponse struct {
 	Content   string        `json:"content"`
 	Charts    []interface{} `json:"charts"`
@@ -88,7 +255,42 @@ type ReportResponse struct {
 }
 
 type UsageResponse struct {
+	TokenBudget int      `json:"token_budget,omitempty"` // 团队空间的每月预算
 	TotalTokens int      `json:"total_tokens"`
 	TotalCost   float64  `json:"total_cost"`
 	Usage       []*Usage `json:"usage"`
 }
+
+// SQLQueryResponse SQL查询结果及可查询的表
+type SQLQueryResponse struct {
+	*sqlengine.Result
+	Tables []sqlengine.TableInfo `json:"tables"`
+}
+
+// PlanGraphResponse 查询的执行计划图
+type PlanGraphResponse struct {
+	PlanID        string              `json:"plan_id"`
+	QueryID       int                 `json:"query_id"`
+	Status        string              `json:"status"` // pending, running, completed, failed
+	FailurePolicy types.FailurePolicy `json:"failure_policy,omitempty"`
+	Nodes         []PlanNode          `json:"nodes"`
+	Edges         []PlanEdge          `json:"edges"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+}
+
+// PlanNode 执行计划中的任务节点
+type PlanNode struct {
+	ID          string            `json:"id"`
+	Type        string            `json:"type"`
+	Description string            `json:"description"`
+	AgentType   types.AgentType   `json:"agent_type"`
+	Status      types.TaskStatus  `json:"status"`
+	Input       interface{}       `json:"input,omitempty"`
+	Result      *types.TaskResult `json:"result,omitempty"`
+}
+
+// PlanEdge 任务依赖边，From 完成后才能执行 To
+type PlanEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
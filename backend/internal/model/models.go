@@ -1,86 +1,171 @@
 package model
 
 import (
+	"smart-analysis/internal/pii"
+	"smart-analysis/internal/types"
 	"smart-analysis/internal/utils/llm"
 	"time"
 )
 
 // User 用户模型
 type User struct {
-	ID        int       `json:"id" gorm:"primaryKey"`
-	Username  string    `json:"username" gorm:"uniqueIndex"`
-	Email     string    `json:"email" gorm:"uniqueIndex"`
-	Password  string    `json:"-" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            int       `json:"id" gorm:"primaryKey"`
+	Username      string    `json:"username" gorm:"uniqueIndex"`
+	Email         string    `json:"email" gorm:"uniqueIndex"`
+	EmailVerified bool      `json:"email_verified"`
+	Password      string    `json:"-" gorm:"not null"`
+	Phone         string    `json:"phone,omitempty" gorm:"index"` // 已验证的手机号，如 +8613800000000
+	IsAdmin       bool      `json:"is_admin"`
+	IsService     bool      `json:"is_service,omitempty"` // 服务账号，只能通过服务 API Key 访问
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
+// 短信验证码的用途，验证码只能用于申请时的用途
+const (
+	OTPPurposeRegister      = "register"
+	OTPPurposeLogin         = "login"
+	OTPPurposeResetPassword = "reset_password"
+	OTPPurposeDeleteAccount = "delete_account"
+	OTPPurposeBindPhone     = "bind_phone"
+)
+
 // File 文件模型
 type File struct {
-	ID        int       `json:"id" gorm:"primaryKey"`
-	UserID    int       `json:"user_id"`
-	Name      string    `json:"name"`
-	OrigName  string    `json:"orig_name"`
-	Path      string    `json:"path"`
-	Size      int64     `json:"size"`
-	Type      string    `json:"type"`
-	Status    string    `json:"status"` // uploaded, processing, ready, error
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	User      User      `json:"user" gorm:"foreignKey:UserID"`
+	ID          int          `json:"id" gorm:"primaryKey"`
+	UserID      int          `json:"user_id"`
+	Name        string       `json:"-"` // 存储文件名和路径只在服务端使用，不返回给客户端
+	OrigName    string       `json:"orig_name"`
+	Path        string       `json:"-"`
+	Size        int64        `json:"size"`
+	Type        string       `json:"type"`
+	Status      string       `json:"status"`                               // uploaded, processing, ready, error
+	WorkspaceID int          `json:"workspace_id,omitempty"`               // 共享到的团队空间，0表示个人空间
+	Sheets      []string     `json:"sheets,omitempty" gorm:"-"`            // Excel 文件的工作表，第一个工作表用于预览和数据视图
+	Sensitive   []pii.Column `json:"sensitive_columns,omitempty" gorm:"-"` // 敏感列及其脱敏策略，解析文件时自动识别，Excel 文件按工作表区分
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	User        User         `json:"user" gorm:"foreignKey:UserID"`
 }
 
 // Session 会话模型
 type Session struct {
-	ID        int       `json:"id" gorm:"primaryKey"`
-	UserID    int       `json:"user_id"`
-	Name      string    `json:"name"`
-	FileID    *int      `json:"file_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	User      User      `json:"user" gorm:"foreignKey:UserID"`
-	File      *File     `json:"file" gorm:"foreignKey:FileID"`
+	ID          int       `json:"id" gorm:"primaryKey"`
+	UserID      int       `json:"user_id"`
+	WorkspaceID int       `json:"workspace_id,omitempty"` // 所属团队空间，0表示个人空间
+	Name        string    `json:"name"`
+	FileID      *int      `json:"file_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	User        User      `json:"user" gorm:"foreignKey:UserID"`
+	File        *File     `json:"file" gorm:"foreignKey:FileID"`
 }
 
 // Query 查询记录模型
 type Query struct {
-	ID        int       `json:"id" gorm:"primaryKey"`
-	SessionID int       `json:"session_id"`
-	UserID    int       `json:"user_id"`
-	Question  string    `json:"question"`
-	Answer    string    `json:"answer"`
-	QueryType string    `json:"query_type"` // analysis, visualization, report
-	Status    string    `json:"status"`     // processing, completed, error
-	CreatedAt time.Time `json:"created_at"`
-	Session   Session   `json:"session" gorm:"foreignKey:SessionID"`
-	User      User      `json:"user" gorm:"foreignKey:UserID"`
+	ID        int    `json:"id" gorm:"primaryKey"`
+	SessionID int    `json:"session_id"`
+	UserID    int    `json:"user_id"`
+	Question  string `json:"question"`
+	Answer    string `json:"answer"`
+	QueryType string `json:"query_type"` // analysis, visualization, report
+	Status    string `json:"status"`     // processing, completed, error
+	// PromptVersion 生成回答时使用的提示词模板版本，用于对比不同提示词的效果
+	PromptVersion string    `json:"prompt_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	Session       Session   `json:"session" gorm:"foreignKey:SessionID"`
+	User          User      `json:"user" gorm:"foreignKey:UserID"`
+}
+
+// Chart 可视化生成的图表，关联到会话和生成图表的查询记录，ChartData 可由前端直接渲染
+type Chart struct {
+	ID        int                  `json:"id"`
+	SessionID int                  `json:"session_id"`
+	QueryID   int                  `json:"query_id"`
+	UserID    int                  `json:"user_id"`
+	FileID    int                  `json:"file_id"`
+	Title     string               `json:"title"`
+	ChartType string               `json:"chart_type"`
+	Columns   []string             `json:"columns"`
+	ChartData *types.EChartsConfig `json:"chart_data" gorm:"-"`
+	CreatedAt time.Time            `json:"created_at"`
 }
 
 // LLMConfig LLM配置模型
 type LLMConfig struct {
-	ID        int       `json:"id" gorm:"primaryKey"`
-	UserID    int       `json:"user_id"`
-	Provider  string    `json:"provider"` // openai, hunyuan, tongyi
-	APIKey    string    `json:"api_key"`
-	Model     string    `json:"model"`
-	IsDefault bool      `json:"is_default"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	User      User      `json:"user" gorm:"foreignKey:UserID"`
+	ID          int       `json:"id" gorm:"primaryKey"`
+	UserID      int       `json:"user_id"`
+	WorkspaceID int       `json:"workspace_id,omitempty"` // 团队空间共享的配置，0表示个人配置
+	Provider    string    `json:"provider"`               // openai, hunyuan, tongyi
+	APIKey      string    `json:"api_key"`
+	Model       string    `json:"model"`
+	IsDefault   bool      `json:"is_default"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	User        User      `json:"user" gorm:"foreignKey:UserID"`
 }
 
 // Usage 使用量模型
 type Usage struct {
-	ID        int       `json:"id" gorm:"primaryKey"`
-	UserID    int       `json:"user_id"`
-	Provider  string    `json:"provider"`
-	Model     string    `json:"model"`
-	Tokens    int       `json:"tokens"`
-	Cost      float64   `json:"cost"`
-	QueryID   int       `json:"query_id"`
+	ID          int       `json:"id" gorm:"primaryKey"`
+	UserID      int       `json:"user_id"`
+	WorkspaceID int       `json:"workspace_id,omitempty"` // 计入的团队空间预算，0表示个人使用
+	Provider    string    `json:"provider"`
+	Model       string    `json:"model"`
+	Tokens      int       `json:"tokens"`
+	Cost        float64   `json:"cost"`
+	QueryID     int       `json:"query_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	User        User      `json:"user" gorm:"foreignKey:UserID"`
+	Query       Query     `json:"query" gorm:"foreignKey:QueryID"`
+}
+
+// DataSource 数据库数据源，密码加密后保存且不会返回给客户端
+type DataSource struct {
+	ID            int                  `json:"id" gorm:"primaryKey"`
+	UserID        int                  `json:"user_id"`
+	Name          string               `json:"name"`
+	Type          string               `json:"type"` // mysql, postgres, sqlite
+	Host          string               `json:"host,omitempty"`
+	Port          int                  `json:"port,omitempty"`
+	Database      string               `json:"database"` // SQLite 为数据库文件路径
+	Username      string               `json:"username,omitempty"`
+	Password      string               `json:"-"`                       // AES-GCM 加密后的密码
+	SSLMode       string               `json:"ssl_mode,omitempty"`      // disable, require, verify-ca, verify-full
+	SSLRootCert   string               `json:"ssl_root_cert,omitempty"` // CA证书文件路径
+	ScanInterval  int                  `json:"scan_interval"`           // 定时重新扫描的间隔（分钟），0表示不定时扫描
+	Status        string               `json:"status"`                  // pending, ready, error
+	LastError     string               `json:"last_error,omitempty"`
+	LastScannedAt *time.Time           `json:"last_scanned_at,omitempty"`
+	Tables        []types.DataSchema   `json:"tables,omitempty" gorm:"-"`
+	Semantic      *types.SemanticModel `json:"semantic,omitempty" gorm:"-"`          // 业务语义模型
+	Sensitive     []pii.Column         `json:"sensitive_columns,omitempty" gorm:"-"` // 敏感列及其脱敏策略，扫描时按列名自动识别
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+	User          User                 `json:"user" gorm:"foreignKey:UserID"`
+}
+
+// MaskingRecord 敏感数据脱敏的审计记录
+type MaskingRecord struct {
+	ID        int          `json:"id"`
+	UserID    int          `json:"user_id"`
+	Source    string       `json:"source"`  // 数据来源，如 file:1、datasource:2、sql
+	Purpose   string       `json:"purpose"` // 数据用途：preview, query, prompt
+	Columns   []pii.Masked `json:"columns"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// AccessPolicy 文件的访问策略，授权其他用户或团队查看文件中满足行过滤条件的行和指定的列。
+// UserID 和 Team 二选一；RowFilter 为空表示所有行，Columns 为空表示所有列
+type AccessPolicy struct {
+	ID        int       `json:"id"`
+	FileID    int       `json:"file_id"`
+	UserID    int       `json:"user_id,omitempty"`    // 被授权的用户
+	Team      string    `json:"team,omitempty"`       // 被授权的团队
+	RowFilter string    `json:"row_filter,omitempty"` // SQL布尔表达式，如 region = 'East'
+	Columns   []string  `json:"columns,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
-	User      User      `json:"user" gorm:"foreignKey:UserID"`
-	Query     Query     `json:"query" gorm:"foreignKey:QueryID"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // AnalysisResult LLM分析结果
@@ -112,3 +197,132 @@ type VisualizationSuggestion struct {
 	Suggestion   string    `json:"suggestion"`
 	CreatedAt    time.Time `json:"created_at"`
 }
+
+// 团队空间中的角色，权限依次递增
+const (
+	RoleViewer = "viewer" // 查看空间内的文件、会话和看板
+	RoleEditor = "editor" // 共享文件，创建会话和看板，使用空间的LLM配置
+	RoleOwner  = "owner"  // 管理成员、邀请、LLM配置和预算
+)
+
+// Organization 组织，组织所有者可以在组织下创建团队空间
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	OwnerID   int       `json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Workspace 团队空间，成员共享其中的文件、会话、看板和LLM配置
+type Workspace struct {
+	ID          int       `json:"id"`
+	OrgID       int       `json:"org_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	TokenBudget int       `json:"token_budget"`            // 每月LLM token预算，0表示不限
+	Role        string    `json:"role,omitempty" gorm:"-"` // 当前用户在空间中的角色
+	CreatedBy   int       `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WorkspaceMember 团队空间成员
+type WorkspaceMember struct {
+	WorkspaceID int       `json:"workspace_id"`
+	UserID      int       `json:"user_id"`
+	Username    string    `json:"username,omitempty" gorm:"-"`
+	Email       string    `json:"email,omitempty" gorm:"-"`
+	Role        string    `json:"role"`
+	JoinedAt    time.Time `json:"joined_at"`
+}
+
+// Invitation 团队空间的邀请，被邀请人用与 Email 相同的账号接受邀请后成为成员
+type Invitation struct {
+	ID          int        `json:"id"`
+	WorkspaceID int        `json:"workspace_id"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	Token       string     `json:"token,omitempty"`
+	Status      string     `json:"status"` // pending, accepted, declined, revoked
+	InvitedBy   int        `json:"invited_by"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Dashboard 看板，由若干图表组成；WorkspaceID 不为0时在团队空间内共享
+type Dashboard struct {
+	ID          int               `json:"id"`
+	UserID      int               `json:"user_id"`
+	WorkspaceID int               `json:"workspace_id,omitempty"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Widgets     []DashboardWidget `json:"widgets"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// DashboardWidget 看板中的图表，数据来自一次查询或一条SQL
+type DashboardWidget struct {
+	Title     string                 `json:"title"`
+	ChartType string                 `json:"chart_type"` // bar, line, pie, scatter, table
+	QueryID   int                    `json:"query_id,omitempty"`
+	SQL       string                 `json:"sql,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+}
+
+// ExternalIdentity 关联到用户的第三方登录身份，同一提供商的同一身份只能关联一个用户
+type ExternalIdentity struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	Provider    string    `json:"provider"`
+	Subject     string    `json:"subject"`
+	Email       string    `json:"email,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+// OAuthProvider 可用的第三方登录提供商
+type OAuthProvider struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	DisplayName string `json:"display_name"`
+}
+
+// APIKeyPrefix API Key 的前缀，用于区分 API Key 和 JWT
+const APIKeyPrefix = "sa_"
+
+// API Key 的权限范围
+const (
+	ScopeFilesRead      = "files:read"      // 列出和预览文件、查看SQL表
+	ScopeQueriesRun     = "queries:run"     // 执行SQL和分析查询，查看历史和执行计划
+	ScopeSessionsManage = "sessions:manage" // 创建和查看会话
+)
+
+// API Key 类型
+const (
+	APIKeyPersonal = "personal" // 以创建者的身份访问
+	APIKeyService  = "service"  // 以团队空间的服务账号身份访问，不依赖某个成员的账号
+)
+
+// APIKey 用于程序访问的 API Key，服务端只保存 Key 的哈希
+type APIKey struct {
+	ID            int        `json:"id"`
+	UserID        int        `json:"user_id"`        // 创建者
+	PrincipalID   int        `json:"principal_id"`   // 请求以该用户的身份执行：个人 Key 为创建者，服务 Key 为服务账号
+	PrincipalName string     `json:"principal_name"` // 执行请求的用户名
+	WorkspaceID   int        `json:"workspace_id,omitempty"`
+	Type          string     `json:"type"`
+	Name          string     `json:"name"`
+	Prefix        string     `json:"prefix"` // Key 的开头部分，用于识别
+	KeyHash       string     `json:"-"`
+	Scopes        []string   `json:"scopes"`
+	RateLimit     int        `json:"rate_limit"` // 每分钟请求数上限
+	RequestCount  int64      `json:"request_count"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
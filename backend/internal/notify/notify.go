@@ -0,0 +1,118 @@
+// Package notify 发送验证邮件和短信验证码。发送方式可替换：本地开发使用控制台输出，
+// 邮件通过 SMTP 发送，短信通过 Webhook 转发给短信网关
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Message 发送给用户的消息，短信没有标题
+type Message struct {
+	To      string `json:"to"` // 邮箱或手机号
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body"`
+}
+
+// Sender 发送消息
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// ConsoleSender 将消息输出到日志，用于本地开发
+type ConsoleSender struct {
+	Channel string // 日志中显示的渠道，如 email、sms
+}
+
+// Send 输出消息
+func (s ConsoleSender) Send(_ context.Context, msg Message) error {
+	log.Printf("[notify:%s] to=%s subject=%q\n%s", s.Channel, msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// SMTPSender 通过 SMTP 服务器发送邮件，Username 为空时不认证
+type SMTPSender struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string
+}
+
+// Send 发送邮件
+func (s *SMTPSender) Send(_ context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("邮件收件人或标题包含换行")
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return fmt.Errorf("SMTP 地址无效: %w", err)
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", s.From)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", mimeHeader(msg.Subject))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	if err := smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, body.Bytes()); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	return nil
+}
+
+// mimeHeader 按 RFC 2047 编码非 ASCII 的邮件头
+func mimeHeader(value string) string {
+	for _, r := range value {
+		if r > 127 {
+			return "=?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte(value)) + "?="
+		}
+	}
+	return value
+}
+
+// WebhookSender 将消息以 JSON 发送到 Webhook，由短信网关或内部通知服务投递
+type WebhookSender struct {
+	URL    string
+	Client *http.Client // 为空时使用10秒超时的默认客户端
+}
+
+// Send 发送消息，Webhook 返回非 2xx 状态码时视为失败
+func (s *WebhookSender) Send(ctx context.Context, msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送消息失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("发送消息失败: Webhook 返回 %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookSender(t *testing.T) {
+	var received Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil || received.To == "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	sender := &WebhookSender{URL: server.URL}
+	msg := Message{To: "+8613800000000", Body: "验证码 123456"}
+	if err := sender.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if received != msg {
+		t.Errorf("收到的消息不正确: %+v", received)
+	}
+	if err := sender.Send(context.Background(), Message{}); err == nil {
+		t.Error("Webhook 返回错误状态码时应失败")
+	}
+}
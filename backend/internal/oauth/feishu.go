@@ -0,0 +1,105 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 飞书开放平台默认地址
+const (
+	feishuAuthURL = "https://accounts.feishu.cn/open-apis/authen/v1/authorize"
+	feishuAPIURL  = "https://open.feishu.cn"
+)
+
+// feishuProvider 飞书网页应用登录
+type feishuProvider struct {
+	config Config
+	client *http.Client
+}
+
+// AuthCodeURL 返回飞书授权页地址
+func (p *feishuProvider) AuthCodeURL(_ context.Context, state, _, verifier string) (string, error) {
+	authURL := p.config.AuthURL
+	if authURL == "" {
+		authURL = feishuAuthURL
+	}
+	params := url.Values{
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"response_type":         {"code"},
+		"state":                 {state},
+		"code_challenge":        {CodeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	if len(p.config.Scopes) > 0 {
+		params.Set("scope", strings.Join(p.config.Scopes, " "))
+	}
+	return withQuery(authURL, params), nil
+}
+
+// Exchange 用授权码换取用户访问令牌，再获取登录用户信息
+func (p *feishuProvider) Exchange(ctx context.Context, code, _, verifier string) (*Identity, error) {
+	var token struct {
+		Code             int    `json:"code"`
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err := postJSON(ctx, p.client, p.apiURL("/open-apis/authen/v2/oauth/token"), map[string]string{
+		"grant_type":    "authorization_code",
+		"client_id":     p.config.ClientID,
+		"client_secret": p.config.ClientSecret,
+		"code":          code,
+		"redirect_uri":  p.config.RedirectURL,
+		"code_verifier": verifier,
+	}, &token)
+	if err != nil {
+		return nil, err
+	}
+	if token.Code != 0 || token.AccessToken == "" {
+		return nil, fmt.Errorf("飞书换取令牌失败: %d %s %s", token.Code, token.Error, token.ErrorDescription)
+	}
+
+	var info struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			OpenID          string `json:"open_id"`
+			UnionID         string `json:"union_id"`
+			Name            string `json:"name"`
+			Email           string `json:"email"`
+			EnterpriseEmail string `json:"enterprise_email"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, p.client, p.apiURL("/open-apis/authen/v1/user_info"), token.AccessToken, &info); err != nil {
+		return nil, err
+	}
+	if info.Code != 0 || info.Data.OpenID == "" {
+		return nil, fmt.Errorf("获取飞书用户信息失败: %d %s", info.Code, info.Msg)
+	}
+
+	// 企业邮箱由租户管理员分配，视为已验证；个人邮箱只在配置信任时视为已验证
+	identity := &Identity{
+		Provider: p.config.Name,
+		Subject:  info.Data.OpenID,
+		Name:     info.Data.Name,
+	}
+	if info.Data.EnterpriseEmail != "" {
+		identity.Email, identity.EmailVerified = info.Data.EnterpriseEmail, true
+	} else if info.Data.Email != "" {
+		identity.Email, identity.EmailVerified = info.Data.Email, p.config.TrustEmail
+	}
+	return identity, nil
+}
+
+// apiURL 返回接口地址
+func (p *feishuProvider) apiURL(path string) string {
+	base := p.config.APIBaseURL
+	if base == "" {
+		base = feishuAPIURL
+	}
+	return strings.TrimSuffix(base, "/") + path
+}
@@ -0,0 +1,289 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// defaultOIDCScopes 未配置 scopes 时请求的范围
+var defaultOIDCScopes = []string{"openid", "email", "profile"}
+
+// discovery OpenID Connect 发现文档
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// jwk JSON Web Key，支持 RSA 和 EC（P-256/P-384/P-521）公钥
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// idTokenClaims ID令牌的声明
+type idTokenClaims struct {
+	Nonce             string      `json:"nonce"`
+	Email             string      `json:"email"`
+	EmailVerified     interface{} `json:"email_verified"` // 部分提供商返回字符串 "true"
+	Name              string      `json:"name"`
+	PreferredUsername string      `json:"preferred_username"`
+	jwt.RegisteredClaims
+}
+
+// oidcProvider 标准 OpenID Connect 提供商，端点通过发现文档获取，ID令牌用提供商的公钥校验
+type oidcProvider struct {
+	config Config
+	client *http.Client
+
+	mu        sync.Mutex
+	endpoints *discovery
+	keys      map[string]interface{}
+}
+
+// AuthCodeURL 返回授权页地址，携带 nonce 和 PKCE code_challenge
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	endpoints, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	scopes := p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultOIDCScopes
+	}
+	return withQuery(endpoints.AuthorizationEndpoint, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}), nil
+}
+
+// Exchange 用授权码换取令牌并校验ID令牌，ID令牌中没有邮箱时从用户信息端点补充
+func (p *oidcProvider) Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error) {
+	endpoints, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = postForm(ctx, p.client, endpoints.TokenEndpoint, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {verifier},
+	}, &token)
+	if err != nil {
+		return nil, err
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("换取令牌失败: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("令牌响应中缺少 id_token")
+	}
+
+	claims := &idTokenClaims{}
+	_, err = jwt.ParseWithClaims(token.IDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(endpoints.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ID令牌校验失败: %w", err)
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("ID令牌的 nonce 不匹配")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("ID令牌缺少 sub")
+	}
+
+	identity := &Identity{
+		Provider:      p.config.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.Email != "" && (p.config.TrustEmail || isTrue(claims.EmailVerified)),
+		Name:          claims.Name,
+	}
+	if identity.Name == "" {
+		identity.Name = claims.PreferredUsername
+	}
+	if identity.Email == "" && endpoints.UserInfoEndpoint != "" && token.AccessToken != "" {
+		var info idTokenClaims
+		if err := getJSON(ctx, p.client, endpoints.UserInfoEndpoint, token.AccessToken, &info); err != nil {
+			return nil, err
+		}
+		// 用户信息必须属于ID令牌中的同一用户
+		if info.Subject != claims.Subject {
+			return nil, errors.New("用户信息的 sub 与ID令牌不一致")
+		}
+		identity.Email = info.Email
+		identity.EmailVerified = info.Email != "" && (p.config.TrustEmail || isTrue(info.EmailVerified))
+		if identity.Name == "" {
+			identity.Name = info.Name
+		}
+	}
+	return identity, nil
+}
+
+// discover 获取并缓存发现文档，配置中显式指定的端点优先
+func (p *oidcProvider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.endpoints != nil {
+		return p.endpoints, nil
+	}
+
+	issuer := strings.TrimSuffix(p.config.Issuer, "/")
+	doc := &discovery{}
+	if err := getJSON(ctx, p.client, issuer+"/.well-known/openid-configuration", "", doc); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("发现文档的 issuer %q 与配置不一致", doc.Issuer)
+	}
+	for target, override := range map[*string]string{
+		&doc.AuthorizationEndpoint: p.config.AuthURL,
+		&doc.TokenEndpoint:         p.config.TokenURL,
+		&doc.UserInfoEndpoint:      p.config.UserInfoURL,
+		&doc.JWKSURI:               p.config.JWKSURL,
+	} {
+		if override != "" {
+			*target = override
+		}
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("发现文档缺少授权、令牌或公钥端点")
+	}
+	p.endpoints = doc
+	return doc, nil
+}
+
+// key 按 kid 返回ID令牌的校验公钥，找不到时重新获取公钥集以支持提供商轮换密钥
+func (p *oidcProvider) key(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key := p.lookup(kid); key != nil {
+		return key, nil
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := getJSON(ctx, p.client, p.endpoints.JWKSURI, "", &set); err != nil {
+		return nil, err
+	}
+	p.keys = make(map[string]interface{})
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			p.keys[k.Kid] = key
+		}
+	}
+	if key := p.lookup(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("找不到ID令牌的签名公钥: %q", kid)
+}
+
+// lookup 查找已缓存的公钥，ID令牌没有 kid 且只有一个公钥时使用该公钥，调用方需持有锁
+func (p *oidcProvider) lookup(kid string) interface{} {
+	if key, ok := p.keys[kid]; ok {
+		return key
+	}
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return nil
+}
+
+// publicKey 将JWK转换为公钥
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("不支持的曲线: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("不支持的密钥类型: %s", k.Kty)
+	}
+}
+
+// isTrue 解析布尔或字符串形式的 email_verified
+func isTrue(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		return strings.EqualFold(b, "true")
+	default:
+		return false
+	}
+}
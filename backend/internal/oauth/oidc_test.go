@@ -0,0 +1,68 @@
+package oauth_test
+
+import (
+	"context"
+	"testing"
+
+	"smart-analysis/internal/oauth"
+	"smart-analysis/internal/oauth/oauthtest"
+)
+
+func TestOIDCProvider_Exchange(t *testing.T) {
+	server, err := oauthtest.NewServer("client", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	provider, err := oauth.New(oauth.Config{
+		Name:         "corp",
+		Type:         oauth.TypeOIDC,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:3000/oauth/callback",
+		Issuer:       server.Issuer(),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	user := oauthtest.User{Subject: "u-1", Email: "alice@corp.example", EmailVerified: true, Name: "Alice"}
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state, err := server.Authorize(authURL, user)
+	if err != nil || state != "state-1" {
+		t.Fatalf("授权请求不正确: %v %s", err, state)
+	}
+	identity, err := provider.Exchange(ctx, code, "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Provider != "corp" || identity.Subject != "u-1" || identity.Email != user.Email || !identity.EmailVerified {
+		t.Errorf("身份不正确: %+v", identity)
+	}
+
+	// nonce 不匹配、PKCE 校验失败或授权码重复使用都会被拒绝
+	code, _, _ = server.Authorize(authURL, user)
+	if _, err := provider.Exchange(ctx, code, "other-nonce", "verifier-1"); err == nil {
+		t.Error("nonce 不匹配时应拒绝")
+	}
+	code, _, _ = server.Authorize(authURL, user)
+	if _, err := provider.Exchange(ctx, code, "nonce-1", "other-verifier"); err == nil {
+		t.Error("code_verifier 不匹配时应拒绝")
+	}
+
+	// ID令牌不含邮箱时从用户信息端点获取
+	server.EmailInUserInfo = true
+	code, _, _ = server.Authorize(authURL, oauthtest.User{Subject: "u-2", Email: "bob@corp.example", Name: "Bob"})
+	identity, err = provider.Exchange(ctx, code, "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Email != "bob@corp.example" || identity.EmailVerified {
+		t.Errorf("用户信息中的邮箱不正确: %+v", identity)
+	}
+}
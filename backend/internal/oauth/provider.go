@@ -0,0 +1,179 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// 提供商类型
+const (
+	TypeOIDC   = "oidc"   // 标准 OpenID Connect
+	TypeFeishu = "feishu" // 飞书
+	TypeWeCom  = "wecom"  // 企业微信
+)
+
+// Identity 第三方登录返回的用户身份
+type Identity struct {
+	Provider      string
+	Subject       string // 用户在提供商内的唯一标识
+	Email         string
+	EmailVerified bool // 邮箱已由提供商验证，只有验证过的邮箱才会关联到已有账号
+	Name          string
+}
+
+// Provider 第三方登录提供商，实现授权码流程
+type Provider interface {
+	// AuthCodeURL 返回授权页地址。nonce 和 verifier（PKCE）由不支持的提供商忽略
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange 用授权码换取用户身份
+	Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error)
+}
+
+// Config 提供商配置
+type Config struct {
+	Name         string   `json:"name"` // 登录路由中的名称，如 feishu、corp-sso
+	Type         string   `json:"type"` // oidc / feishu / wecom
+	DisplayName  string   `json:"display_name"`
+	ClientID     string   `json:"client_id"` // 飞书为 App ID，企业微信为 CorpID
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+
+	Issuer      string `json:"issuer"`       // OIDC 签发者，用于发现端点和校验ID令牌
+	AuthURL     string `json:"auth_url"`     // 覆盖授权页地址
+	TokenURL    string `json:"token_url"`    // 覆盖OIDC令牌端点
+	UserInfoURL string `json:"userinfo_url"` // 覆盖OIDC用户信息端点
+	JWKSURL     string `json:"jwks_url"`     // 覆盖OIDC公钥端点
+	APIBaseURL  string `json:"api_base_url"` // 覆盖飞书、企业微信的接口地址（私有化部署或测试）
+	AgentID     string `json:"agent_id"`     // 企业微信应用的 AgentID
+
+	TrustEmail     bool     `json:"trust_email"`     // 将提供商返回的邮箱视为已验证
+	AutoProvision  bool     `json:"auto_provision"`  // 首次登录时自动创建账号
+	AllowedDomains []string `json:"allowed_domains"` // 自动创建账号只允许这些邮箱域名，为空时不限
+	WorkspaceID    int      `json:"workspace_id"`    // 自动创建的账号加入的团队空间
+	WorkspaceRole  string   `json:"workspace_role"`  // 加入团队空间的角色，默认 viewer
+}
+
+// LoadConfig 从JSON文件加载提供商配置，client_secret 以 $ 开头时从同名环境变量读取
+func LoadConfig(path string) ([]Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取第三方登录配置失败: %w", err)
+	}
+	var configs []Config
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("解析第三方登录配置失败: %w", err)
+	}
+	for i := range configs {
+		if name, ok := strings.CutPrefix(configs[i].ClientSecret, "$"); ok {
+			configs[i].ClientSecret = os.Getenv(name)
+		}
+	}
+	return configs, nil
+}
+
+// New 根据配置创建提供商
+func New(cfg Config, client *http.Client) (Provider, error) {
+	if cfg.Name == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("第三方登录配置缺少 name、client_id 或 redirect_url: %q", cfg.Name)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	switch cfg.Type {
+	case TypeOIDC:
+		if cfg.Issuer == "" {
+			return nil, fmt.Errorf("OIDC 提供商 %s 缺少 issuer", cfg.Name)
+		}
+		return &oidcProvider{config: cfg, client: client}, nil
+	case TypeFeishu:
+		return &feishuProvider{config: cfg, client: client}, nil
+	case TypeWeCom:
+		return &wecomProvider{config: cfg, client: client}, nil
+	default:
+		return nil, fmt.Errorf("不支持的第三方登录类型: %s", cfg.Type)
+	}
+}
+
+// CodeChallenge 返回 PKCE 的 S256 code_challenge
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// withQuery 在地址后追加查询参数
+func withQuery(base string, params url.Values) string {
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + params.Encode()
+}
+
+// doJSON 发送请求并解析JSON响应
+func doJSON(client *http.Client, req *http.Request, out interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求 %s 失败: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("读取 %s 响应失败: %w", req.URL.Host, err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("请求 %s 失败: HTTP %d %s", req.URL.Path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("解析 %s 响应失败: %w", req.URL.Path, err)
+	}
+	return nil
+}
+
+// getJSON 发送GET请求，token 不为空时作为 Bearer 令牌
+func getJSON(ctx context.Context, client *http.Client, target, token string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return doJSON(client, req, out)
+}
+
+// postForm 发送表单POST请求
+func postForm(ctx context.Context, client *http.Client, target string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doJSON(client, req, out)
+}
+
+// postJSON 发送JSON POST请求
+func postJSON(ctx context.Context, client *http.Client, target string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(string(data)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	return doJSON(client, req, out)
+}
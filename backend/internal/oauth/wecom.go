@@ -0,0 +1,139 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 企业微信默认地址
+const (
+	wecomAuthURL = "https://login.work.weixin.qq.com/wwlogin/sso/login"
+	wecomAPIURL  = "https://qyapi.weixin.qq.com"
+)
+
+// wecomProvider 企业微信网页扫码登录，只允许企业成员登录
+type wecomProvider struct {
+	config Config
+	client *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// wecomError 企业微信接口的错误码
+type wecomError struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// err 返回接口错误
+func (e wecomError) err(action string) error {
+	if e.ErrCode != 0 {
+		return fmt.Errorf("企业微信%s失败: %d %s", action, e.ErrCode, e.ErrMsg)
+	}
+	return nil
+}
+
+// AuthCodeURL 返回企业微信登录页地址
+func (p *wecomProvider) AuthCodeURL(_ context.Context, state, _, _ string) (string, error) {
+	authURL := p.config.AuthURL
+	if authURL == "" {
+		authURL = wecomAuthURL
+	}
+	return withQuery(authURL, url.Values{
+		"login_type":   {"CorpApp"},
+		"appid":        {p.config.ClientID},
+		"agentid":      {p.config.AgentID},
+		"redirect_uri": {p.config.RedirectURL},
+		"state":        {state},
+	}), nil
+}
+
+// Exchange 用授权码获取成员 UserID，再读取成员的姓名和邮箱
+func (p *wecomProvider) Exchange(ctx context.Context, code, _, _ string) (*Identity, error) {
+	token, err := p.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var login struct {
+		wecomError
+		UserID string `json:"userid"`
+	}
+	if err := getJSON(ctx, p.client, p.apiURL("/cgi-bin/auth/getuserinfo", url.Values{"access_token": {token}, "code": {code}}), "", &login); err != nil {
+		return nil, err
+	}
+	if err := login.err("获取登录成员"); err != nil {
+		return nil, err
+	}
+	if login.UserID == "" {
+		return nil, fmt.Errorf("企业微信登录用户不是企业成员")
+	}
+
+	var member struct {
+		wecomError
+		Name    string `json:"name"`
+		Email   string `json:"email"`
+		BizMail string `json:"biz_mail"`
+	}
+	if err := getJSON(ctx, p.client, p.apiURL("/cgi-bin/user/get", url.Values{"access_token": {token}, "userid": {login.UserID}}), "", &member); err != nil {
+		return nil, err
+	}
+	if err := member.err("读取成员信息"); err != nil {
+		return nil, err
+	}
+
+	// 企业邮箱由企业分配，视为已验证；成员资料中的邮箱只在配置信任时视为已验证
+	identity := &Identity{
+		Provider: p.config.Name,
+		Subject:  login.UserID,
+		Name:     member.Name,
+	}
+	if member.BizMail != "" {
+		identity.Email, identity.EmailVerified = member.BizMail, true
+	} else if member.Email != "" {
+		identity.Email, identity.EmailVerified = member.Email, p.config.TrustEmail
+	}
+	return identity, nil
+}
+
+// token 获取并缓存应用的 access_token
+func (p *wecomProvider) token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.accessToken != "" && time.Now().Before(p.expiresAt) {
+		return p.accessToken, nil
+	}
+
+	var resp struct {
+		wecomError
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := getJSON(ctx, p.client, p.apiURL("/cgi-bin/gettoken", url.Values{"corpid": {p.config.ClientID}, "corpsecret": {p.config.ClientSecret}}), "", &resp); err != nil {
+		return "", err
+	}
+	if err := resp.err("获取 access_token"); err != nil {
+		return "", err
+	}
+
+	// 提前一分钟过期，避免使用即将失效的令牌
+	p.accessToken = resp.AccessToken
+	p.expiresAt = time.Now().Add(time.Duration(resp.ExpiresIn)*time.Second - time.Minute)
+	return p.accessToken, nil
+}
+
+// apiURL 返回接口地址
+func (p *wecomProvider) apiURL(path string, params url.Values) string {
+	base := p.config.APIBaseURL
+	if base == "" {
+		base = wecomAPIURL
+	}
+	return withQuery(strings.TrimSuffix(base, "/")+path, params)
+}
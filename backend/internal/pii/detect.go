@@ -0,0 +1,265 @@
+// Package pii 识别数据中的个人敏感信息（手机号、身份证号、邮箱、银行卡号、姓名、地址），
+// 并按列策略在数据进入LLM提示词或通过接口返回前脱敏
+package pii
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Kind 敏感信息类型
+type Kind string
+
+const (
+	KindPhone    Kind = "phone"
+	KindIDCard   Kind = "id_card"
+	KindEmail    Kind = "email"
+	KindBankCard Kind = "bank_card"
+	KindName     Kind = "name"
+	KindAddress  Kind = "address"
+)
+
+// Policy 列的脱敏策略
+type Policy string
+
+const (
+	PolicyAllow Policy = "allow" // 原样保留
+	PolicyMask  Policy = "mask"  // 保留部分字符，其余替换为 *
+	PolicyHash  Policy = "hash"  // 替换为带密钥的哈希，相同值哈希相同，仍可用于分组和关联
+	PolicyDrop  Policy = "drop"  // 整列移除
+)
+
+// DefaultPolicy 自动识别出的敏感列默认采用的策略
+const DefaultPolicy = PolicyMask
+
+// detectSample 按值识别时最多检查的非空值数量
+const detectSample = 200
+
+// detectRatio 按值识别时需要匹配的非空值比例
+const detectRatio = 0.8
+
+// Column 列的敏感类型和脱敏策略，Table 为空时按列名匹配所有表
+type Column struct {
+	Table  string `json:"table,omitempty"`
+	Name   string `json:"name"`
+	Kind   Kind   `json:"kind,omitempty"`
+	Policy Policy `json:"policy"`
+}
+
+// ForTable 返回适用于指定表的列策略：Table 为空的策略和该表的策略
+func ForTable(columns []Column, table string) []Column {
+	var result []Column
+	for _, column := range columns {
+		if column.Table == "" || column.Table == table {
+			result = append(result, column)
+		}
+	}
+	return result
+}
+
+// ValidPolicy 判断策略是否合法
+func ValidPolicy(policy Policy) bool {
+	switch policy {
+	case PolicyAllow, PolicyMask, PolicyHash, PolicyDrop:
+		return true
+	}
+	return false
+}
+
+// Validate 校验列策略列表，列名不能为空且不能重复
+func Validate(columns []Column) error {
+	seen := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		if strings.TrimSpace(column.Name) == "" {
+			return fmt.Errorf("column name is required")
+		}
+		if !ValidPolicy(column.Policy) {
+			return fmt.Errorf("invalid policy %q for column %s", column.Policy, column.Name)
+		}
+		key := column.Table + "." + normalize(column.Name)
+		if seen[key] {
+			return fmt.Errorf("duplicate policy for column %s", column.Name)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// 列名关键词，中文按包含匹配，英文按完整列名匹配
+var (
+	headerContains = []struct {
+		kind     Kind
+		keywords []string
+	}{
+		{KindIDCard, []string{"身份证", "证件号"}},
+		{KindBankCard, []string{"银行卡", "银行账号", "卡号"}},
+		{KindPhone, []string{"手机", "电话", "联系方式"}},
+		{KindEmail, []string{"邮箱", "电子邮件"}},
+		{KindAddress, []string{"地址", "住址"}},
+		{KindName, []string{"姓名", "联系人", "收件人", "收货人"}},
+	}
+	headerExact = map[string]Kind{
+		"idcard": KindIDCard, "idno": KindIDCard, "idnumber": KindIDCard, "identitycard": KindIDCard, "identityno": KindIDCard,
+		"bankcard": KindBankCard, "bankcardno": KindBankCard, "cardno": KindBankCard, "cardnumber": KindBankCard, "bankaccount": KindBankCard,
+		"phone": KindPhone, "phoneno": KindPhone, "phonenumber": KindPhone, "mobile": KindPhone, "mobileno": KindPhone, "tel": KindPhone, "telephone": KindPhone, "cellphone": KindPhone,
+		"email": KindEmail, "mail": KindEmail, "emailaddress": KindEmail,
+		"address": KindAddress, "addr": KindAddress, "homeaddress": KindAddress, "shippingaddress": KindAddress, "street": KindAddress,
+		"fullname": KindName, "realname": KindName, "firstname": KindName, "lastname": KindName,
+		"customername": KindName, "contactname": KindName, "receivername": KindName,
+	}
+)
+
+// 按值识别的正则，匹配前去掉空格和连字符
+var (
+	phonePattern   = regexp.MustCompile(`^(\+?86)?1[3-9]\d{9}$`)
+	idCardPattern  = regexp.MustCompile(`^\d{17}[\dXx]$`)
+	emailPattern   = regexp.MustCompile(`^[\w.+-]+@[\w-]+(\.[\w-]+)+$`)
+	bankPattern    = regexp.MustCompile(`^\d{13,19}$`)
+	addressPattern = regexp.MustCompile(`[省市区县州].*[路街道巷弄号村镇]`)
+)
+
+// DetectHeader 根据列名识别敏感类型，无法识别时返回空
+func DetectHeader(header string) Kind {
+	lower := strings.ToLower(strings.TrimSpace(header))
+	for _, rule := range headerContains {
+		for _, keyword := range rule.keywords {
+			if strings.Contains(lower, keyword) {
+				return rule.kind
+			}
+		}
+	}
+	return headerExact[normalize(lower)]
+}
+
+// DetectValue 根据单个值识别敏感类型，姓名无法仅凭值可靠识别
+func DetectValue(value string) Kind {
+	value = strings.TrimSpace(value)
+	compact := strings.NewReplacer(" ", "", "-", "").Replace(value)
+	switch {
+	case idCardPattern.MatchString(compact) && validIDCard(compact):
+		return KindIDCard
+	case phonePattern.MatchString(compact):
+		return KindPhone
+	case bankPattern.MatchString(compact) && luhn(compact):
+		return KindBankCard
+	case emailPattern.MatchString(value):
+		return KindEmail
+	case addressPattern.MatchString(value) && len([]rune(value)) >= 6:
+		return KindAddress
+	}
+	return ""
+}
+
+// Detect 根据列名和样本值识别列的敏感类型。列名无法识别时，
+// 要求至少 detectRatio 比例的非空样本值属于同一类型
+func Detect(header string, values []string) Kind {
+	if kind := DetectHeader(header); kind != "" {
+		return kind
+	}
+
+	counts := make(map[Kind]int)
+	nonEmpty := 0
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		nonEmpty++
+		if kind := DetectValue(value); kind != "" {
+			counts[kind]++
+		}
+		if nonEmpty >= detectSample {
+			break
+		}
+	}
+	if nonEmpty == 0 {
+		return ""
+	}
+	for _, kind := range []Kind{KindIDCard, KindPhone, KindBankCard, KindEmail, KindAddress} {
+		if float64(counts[kind]) >= detectRatio*float64(nonEmpty) {
+			return kind
+		}
+	}
+	return ""
+}
+
+// DetectColumns 识别表格中的敏感列，返回的列采用默认策略
+func DetectColumns(table string, headers []string, rows [][]string) []Column {
+	var columns []Column
+	for i, header := range headers {
+		values := make([]string, 0, min(len(rows), detectSample))
+		for _, row := range rows {
+			if i < len(row) {
+				values = append(values, row[i])
+			}
+			if len(values) >= detectSample {
+				break
+			}
+		}
+		if kind := Detect(header, values); kind != "" {
+			columns = append(columns, Column{Table: table, Name: header, Kind: kind, Policy: DefaultPolicy})
+		}
+	}
+	return columns
+}
+
+// Reconcile 合并已有策略和新识别的敏感列：已有策略保持不变（包括手动设置的 allow），
+// 新识别的列采用默认策略。tables 非空时移除已不存在的表的策略
+func Reconcile(existing, detected []Column, tables map[string]bool) []Column {
+	result := make([]Column, 0, len(existing)+len(detected))
+	seen := make(map[string]bool, len(existing))
+	for _, column := range existing {
+		if tables != nil && column.Table != "" && !tables[column.Table] {
+			continue
+		}
+		seen[column.Table+"."+normalize(column.Name)] = true
+		result = append(result, column)
+	}
+	for _, column := range detected {
+		if !seen[column.Table+"."+normalize(column.Name)] {
+			result = append(result, column)
+		}
+	}
+	return result
+}
+
+// validIDCard 校验18位身份证号的校验码
+func validIDCard(id string) bool {
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	const checks = "10X98765432"
+	sum := 0
+	for i, weight := range weights {
+		sum += int(id[i]-'0') * weight
+	}
+	return checks[sum%11] == byte(unicode.ToUpper(rune(id[17])))
+}
+
+// luhn 银行卡号的 Luhn 校验
+func luhn(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// normalize 统一列名用于匹配：转小写并去掉非字母数字字符，如 "Phone_No" 和 "phone no" 视为同一列
+func normalize(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
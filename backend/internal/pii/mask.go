@@ -0,0 +1,313 @@
+package pii
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Masked 一列的脱敏记录，用于审计
+type Masked struct {
+	Column string `json:"column,omitempty"`
+	Kind   Kind   `json:"kind,omitempty"`
+	Policy Policy `json:"policy"`
+	Values int    `json:"values"` // 被处理的非空值数量
+}
+
+// Masker 按列策略脱敏数据，哈希使用带密钥的 HMAC-SHA256，相同的值得到相同的哈希
+type Masker struct {
+	key []byte
+}
+
+// NewMasker 创建脱敏器，secret 为哈希密钥
+func NewMasker(secret string) *Masker {
+	return &Masker{key: []byte(secret)}
+}
+
+// action 单列的处理方式
+type action struct {
+	kind   Kind
+	policy Policy
+}
+
+// Strings 脱敏字符串表格，返回新的表头和数据行，不修改输入。
+// 配置了策略的列按策略处理，其余列按列名和样本值自动识别，识别出的敏感列采用默认策略
+func (m *Masker) Strings(headers []string, rows [][]string, columns []Column) ([]string, [][]string, []Masked) {
+	return apply(m, headers, rows, columns,
+		func(value string) (string, bool) { return value, strings.TrimSpace(value) != "" },
+		func(value string) string { return value })
+}
+
+// Values 脱敏查询结果，规则同 Strings，脱敏后的值为字符串
+func (m *Masker) Values(headers []string, rows [][]interface{}, columns []Column) ([]string, [][]interface{}, []Masked) {
+	return apply(m, headers, rows, columns, stringValue, func(value string) interface{} { return value })
+}
+
+// Records 原地脱敏JSON对象数组（如上传的JSON文件）的顶层字段，规则同 Strings
+func (m *Masker) Records(records []interface{}, columns []Column) []Masked {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, record := range records {
+		if object, ok := record.(map[string]interface{}); ok {
+			for key := range object {
+				if !seen[key] {
+					seen[key] = true
+					keys = append(keys, key)
+				}
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	actions := plan(keys, func(i int) []string {
+		var values []string
+		for _, record := range records {
+			if object, ok := record.(map[string]interface{}); ok {
+				if value, ok := stringValue(object[keys[i]]); ok {
+					values = append(values, value)
+				}
+			}
+			if len(values) >= detectSample {
+				break
+			}
+		}
+		return values
+	}, columns)
+
+	counts := make([]int, len(keys))
+	for _, record := range records {
+		object, ok := record.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for i, key := range keys {
+			value, ok := object[key]
+			if !ok || actions[i].policy == PolicyAllow {
+				continue
+			}
+			text, nonEmpty := stringValue(value)
+			if nonEmpty {
+				counts[i]++
+			}
+			switch {
+			case actions[i].policy == PolicyDrop:
+				delete(object, key)
+			case nonEmpty:
+				object[key] = m.Value(actions[i].kind, actions[i].policy, text)
+			}
+		}
+	}
+	return report(keys, actions, counts)
+}
+
+// Value 按敏感类型和策略处理单个值
+func (m *Masker) Value(kind Kind, policy Policy, value string) string {
+	switch policy {
+	case PolicyHash:
+		mac := hmac.New(sha256.New, m.key)
+		mac.Write([]byte(value))
+		return "h_" + hex.EncodeToString(mac.Sum(nil))[:16]
+	case PolicyMask:
+		return mask(kind, value)
+	case PolicyDrop:
+		return ""
+	}
+	return value
+}
+
+// 自由文本中的敏感信息，身份证号和银行卡号还需通过校验
+var (
+	idCardText = regexp.MustCompile(`\b\d{17}[\dXx]\b`)
+	phoneText  = regexp.MustCompile(`\b1[3-9]\d{9}\b`)
+	bankText   = regexp.MustCompile(`\b\d{16,19}\b`)
+	emailText  = regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)+`)
+)
+
+// Text 脱敏自由文本中的身份证号、手机号、银行卡号和邮箱，用于发送给LLM前的兜底检查。
+// 姓名和地址无法从自由文本中可靠识别，需要依赖列策略
+func (m *Masker) Text(text string) (string, []Masked) {
+	var masked []Masked
+	for _, rule := range []struct {
+		kind    Kind
+		pattern *regexp.Regexp
+		valid   func(string) bool
+	}{
+		{KindIDCard, idCardText, validIDCard},
+		{KindPhone, phoneText, nil},
+		{KindBankCard, bankText, luhn},
+		{KindEmail, emailText, nil},
+	} {
+		count := 0
+		text = rule.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.valid != nil && !rule.valid(match) {
+				return match
+			}
+			count++
+			return mask(rule.kind, match)
+		})
+		if count > 0 {
+			masked = append(masked, Masked{Kind: rule.kind, Policy: PolicyMask, Values: count})
+		}
+	}
+	return text, masked
+}
+
+// apply 按列的处理方式生成新的表头和数据行
+func apply[T any](m *Masker, headers []string, rows [][]T, columns []Column, text func(T) (string, bool), wrap func(string) T) ([]string, [][]T, []Masked) {
+	actions := plan(headers, func(i int) []string {
+		values := make([]string, 0, min(len(rows), detectSample))
+		for _, row := range rows {
+			if i < len(row) {
+				if value, ok := text(row[i]); ok {
+					values = append(values, value)
+				}
+			}
+			if len(values) >= detectSample {
+				break
+			}
+		}
+		return values
+	}, columns)
+
+	changed := false
+	var kept []string
+	for i, header := range headers {
+		changed = changed || actions[i].policy != PolicyAllow
+		if actions[i].policy != PolicyDrop {
+			kept = append(kept, header)
+		}
+	}
+	if !changed {
+		return headers, rows, nil
+	}
+
+	counts := make([]int, len(headers))
+	masked := make([][]T, len(rows))
+	for r, row := range rows {
+		out := make([]T, 0, len(kept))
+		for i, value := range row {
+			if i >= len(actions) || actions[i].policy == PolicyAllow {
+				out = append(out, value)
+				continue
+			}
+			s, nonEmpty := text(value)
+			if nonEmpty {
+				counts[i]++
+			}
+			switch {
+			case actions[i].policy == PolicyDrop:
+			case nonEmpty:
+				out = append(out, wrap(m.Value(actions[i].kind, actions[i].policy, s)))
+			default:
+				out = append(out, value)
+			}
+		}
+		masked[r] = out
+	}
+	return kept, masked, report(headers, actions, counts)
+}
+
+// plan 确定每一列的处理方式。配置了策略的列使用配置（同名列有多条策略时取最严格的），
+// 其余列根据列名和样本值自动识别
+func plan(headers []string, sample func(i int) []string, columns []Column) []action {
+	configured := make(map[string]action, len(columns))
+	for _, column := range columns {
+		key := normalize(column.Name)
+		if current, ok := configured[key]; !ok || strictness(column.Policy) > strictness(current.policy) {
+			configured[key] = action{kind: column.Kind, policy: column.Policy}
+		}
+	}
+
+	actions := make([]action, len(headers))
+	for i, header := range headers {
+		if configuredAction, ok := configured[normalize(header)]; ok {
+			actions[i] = configuredAction
+			continue
+		}
+		if kind := Detect(header, sample(i)); kind != "" {
+			actions[i] = action{kind: kind, policy: DefaultPolicy}
+		} else {
+			actions[i] = action{policy: PolicyAllow}
+		}
+	}
+	return actions
+}
+
+// strictness 策略的严格程度
+func strictness(policy Policy) int {
+	switch policy {
+	case PolicyMask:
+		return 1
+	case PolicyHash:
+		return 2
+	case PolicyDrop:
+		return 3
+	}
+	return 0
+}
+
+// report 生成脱敏记录，只包含实际处理过的列
+func report(headers []string, actions []action, counts []int) []Masked {
+	var masked []Masked
+	for i, header := range headers {
+		if actions[i].policy != PolicyAllow {
+			masked = append(masked, Masked{Column: header, Kind: actions[i].kind, Policy: actions[i].policy, Values: counts[i]})
+		}
+	}
+	return masked
+}
+
+// mask 按敏感类型保留部分字符，如手机号 138****5678、邮箱 a***@example.com
+func mask(kind Kind, value string) string {
+	runes := []rune(value)
+	switch kind {
+	case KindEmail:
+		if at := strings.LastIndex(value, "@"); at > 0 {
+			local := []rune(value[:at])
+			return string(local[0]) + "***" + value[at:]
+		}
+	case KindPhone, KindIDCard:
+		return keep(runes, 3, 4)
+	case KindBankCard:
+		return keep(runes, 0, 4)
+	case KindName:
+		return keep(runes, 1, 0)
+	case KindAddress:
+		return keep(runes, 6, 0)
+	}
+	return keep(runes, 1, 1)
+}
+
+// keep 保留开头 head 个和结尾 tail 个字符，其余替换为 *，被遮盖的字符至少占三分之一
+func keep(runes []rune, head, tail int) string {
+	for head+tail > 0 && len(runes)-head-tail < (len(runes)+2)/3 {
+		if head >= tail {
+			head--
+		} else {
+			tail--
+		}
+	}
+	return string(runes[:head]) + strings.Repeat("*", len(runes)-head-tail) + string(runes[len(runes)-tail:])
+}
+
+// stringValue 将查询结果中的值转为字符串，空值返回 false
+func stringValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, strings.TrimSpace(v) != ""
+	case []byte:
+		return string(v), len(v) > 0
+	case float64:
+		// JSON 数字解析为 float64，大整数（如手机号）按整数格式输出
+		if v == float64(int64(v)) {
+			return fmt.Sprintf("%d", int64(v)), true
+		}
+	}
+	return fmt.Sprint(value), true
+}
@@ -0,0 +1,113 @@
+package pii
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		header string
+		values []string
+		want   Kind
+	}{
+		{"客户手机号", nil, KindPhone},
+		{"Phone_No", nil, KindPhone},
+		{"收货地址", nil, KindAddress},
+		{"first_name", nil, KindName},
+		{"product_name", []string{"手机壳", "耳机"}, ""},
+		{"c1", []string{"13812345678", "+86 139-0000-1111", ""}, KindPhone},
+		{"c2", []string{"11010519491231002X", "440301199003071230"}, KindIDCard},
+		{"c3", []string{"110105194912310021"}, ""}, // 校验码错误
+		{"c4", []string{"6222020200112233446", "6222 0202 0011 2233 446"}, KindBankCard},
+		{"c5", []string{"a@example.com", "b.c@mail.example.cn"}, KindEmail},
+		{"c6", []string{"广东省深圳市南山区科技园路1号", "上海市浦东新区世纪大道100号"}, KindAddress},
+		{"c7", []string{"13812345678", "abc", "def", "ghi"}, ""}, // 比例不足
+		{"amount", []string{"100", "200"}, ""},
+	}
+	for _, c := range cases {
+		if got := Detect(c.header, c.values); got != c.want {
+			t.Errorf("Detect(%q, %v) = %q, want %q", c.header, c.values, got, c.want)
+		}
+	}
+}
+
+func TestMasker_Strings(t *testing.T) {
+	m := NewMasker("secret")
+	headers := []string{"姓名", "mobile", "email", "card", "amount"}
+	rows := [][]string{
+		{"张三", "13812345678", "zhang@example.com", "6222020200112233446", "10"},
+		{"李四", "", "li@example.com", "6222020200112233446", "20"},
+	}
+	columns := []Column{{Name: "card", Kind: KindBankCard, Policy: PolicyHash}, {Name: "EMAIL", Policy: PolicyDrop}}
+
+	gotHeaders, gotRows, masked := m.Strings(headers, rows, columns)
+	if strings.Join(gotHeaders, ",") != "姓名,mobile,card,amount" {
+		t.Fatalf("表头不正确: %v", gotHeaders)
+	}
+	first := strings.Join(gotRows[0], ",")
+	if !strings.HasPrefix(first, "张*,138****5678,h_") || !strings.HasSuffix(first, ",10") {
+		t.Errorf("首行脱敏不正确: %s", first)
+	}
+	if gotRows[0][2] != gotRows[1][2] || gotRows[1][1] != "" {
+		t.Errorf("相同值的哈希应相同，空值应保留: %v", gotRows[1])
+	}
+	if rows[0][0] != "张三" || len(rows[0]) != 5 {
+		t.Error("不应修改输入数据")
+	}
+
+	want := map[string]Masked{
+		"姓名":     {Column: "姓名", Kind: KindName, Policy: PolicyMask, Values: 2},
+		"mobile": {Column: "mobile", Kind: KindPhone, Policy: PolicyMask, Values: 1},
+		"email":  {Column: "email", Policy: PolicyDrop, Values: 2},
+		"card":   {Column: "card", Kind: KindBankCard, Policy: PolicyHash, Values: 2},
+	}
+	if len(masked) != len(want) {
+		t.Fatalf("脱敏记录不正确: %+v", masked)
+	}
+	for _, record := range masked {
+		if want[record.Column] != record {
+			t.Errorf("脱敏记录 %+v, want %+v", record, want[record.Column])
+		}
+	}
+
+	// allow 策略覆盖自动识别
+	_, allowed, masked := m.Strings(headers[:2], [][]string{{"张三", "13812345678"}}, []Column{{Name: "姓名", Policy: PolicyAllow}, {Name: "mobile", Policy: PolicyAllow}})
+	if allowed[0][0] != "张三" || allowed[0][1] != "13812345678" || masked != nil {
+		t.Errorf("allow 策略不应脱敏: %v %+v", allowed, masked)
+	}
+}
+
+func TestMasker_ValuesAndRecords(t *testing.T) {
+	m := NewMasker("secret")
+	// 别名无法按列名匹配时按值识别，同名策略取最严格的
+	headers, rows, _ := m.Values([]string{"p", "id"}, [][]interface{}{{int64(13812345678), int64(1)}, {nil, int64(2)}},
+		[]Column{{Table: "a", Name: "id", Policy: PolicyAllow}, {Table: "b", Name: "id", Policy: PolicyHash}})
+	if len(headers) != 2 || rows[0][0] != "138****5678" || rows[1][0] != nil || !strings.HasPrefix(rows[0][1].(string), "h_") {
+		t.Errorf("查询结果脱敏不正确: %v", rows)
+	}
+
+	records := []interface{}{
+		map[string]interface{}{"phone": float64(13812345678), "city": "上海"},
+		map[string]interface{}{"phone": "13900001111", "city": "北京", "id_card": "11010519491231002X"},
+	}
+	masked := m.Records(records, []Column{{Name: "id_card", Policy: PolicyDrop}})
+	second := records[1].(map[string]interface{})
+	if records[0].(map[string]interface{})["phone"] != "138****5678" || second["city"] != "北京" || second["id_card"] != nil {
+		t.Errorf("JSON记录脱敏不正确: %v", records)
+	}
+	if len(masked) != 2 {
+		t.Errorf("脱敏记录不正确: %+v", masked)
+	}
+}
+
+func TestMasker_Text(t *testing.T) {
+	text, masked := NewMasker("").Text("客户13812345678（身份证11010519491231002X，卡号6222020200112233446，邮箱 zhang@example.com）下单金额 1234567890123")
+	want := "客户138****5678（身份证110***********002X，卡号***************3446，邮箱 z***@example.com）下单金额 1234567890123"
+	if text != want {
+		t.Errorf("文本脱敏不正确:\n%s\n%s", text, want)
+	}
+	if len(masked) != 4 {
+		t.Errorf("脱敏记录不正确: %+v", masked)
+	}
+}
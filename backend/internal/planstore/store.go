@@ -0,0 +1,186 @@
+// Package planstore 持久化执行计划、任务状态和任务结果，支持中断后恢复执行
+package planstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"smart-analysis/internal/types"
+)
+
+const planFileExt = ".json"
+
+// Store 执行计划存储。dir 为空时只保存在内存中，
+// 否则每个计划保存为 <dir>/<plan_id>.json，进程重启后可以继续读取
+type Store struct {
+	mu         sync.RWMutex
+	dir        string
+	plans      map[string][]byte // plan_id -> 计划快照
+	queryPlans map[int]string    // query_id -> 最近一次的 plan_id
+	updatedAt  map[string]time.Time
+	lastQuery  int // 计划关联的最大查询ID
+}
+
+// NewStore 创建执行计划存储，指定目录时加载目录中已有的计划
+func NewStore(dir string) (*Store, error) {
+	s := &Store{
+		dir:        dir,
+		plans:      make(map[string][]byte),
+		queryPlans: make(map[int]string),
+		updatedAt:  make(map[string]time.Time),
+	}
+
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create plan store directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan store directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != planFileExt {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plan %s: %w", entry.Name(), err)
+		}
+
+		var plan types.ExecutionPlan
+		if err := json.Unmarshal(data, &plan); err != nil {
+			return nil, fmt.Errorf("failed to parse plan %s: %w", entry.Name(), err)
+		}
+		s.index(&plan, data)
+	}
+
+	return s, nil
+}
+
+// NewMemoryStore 创建仅保存在内存中的执行计划存储
+func NewMemoryStore() *Store {
+	s, _ := NewStore("")
+	return s
+}
+
+// SavePlan 保存计划快照
+func (s *Store) SavePlan(plan *types.ExecutionPlan) error {
+	if plan.ID == "" {
+		return fmt.Errorf("plan id is required")
+	}
+
+	plan.UpdatedAt = time.Now()
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return fmt.Errorf("failed to encode plan %s: %w", plan.ID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir != "" {
+		if err := writeFileAtomic(s.planPath(plan.ID), data); err != nil {
+			return fmt.Errorf("failed to write plan %s: %w", plan.ID, err)
+		}
+	}
+
+	s.index(plan, data)
+	return nil
+}
+
+// GetPlan 按ID获取计划，每次返回独立的副本
+func (s *Store) GetPlan(planID string) (*types.ExecutionPlan, error) {
+	s.mu.RLock()
+	data, exists := s.plans[planID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil, types.ErrPlanNotFound
+	}
+
+	var plan types.ExecutionPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to decode plan %s: %w", planID, err)
+	}
+
+	return &plan, nil
+}
+
+// GetPlanByQuery 获取查询最近一次的计划
+func (s *Store) GetPlanByQuery(queryID int) (*types.ExecutionPlan, error) {
+	s.mu.RLock()
+	planID, exists := s.queryPlans[queryID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil, types.ErrPlanNotFound
+	}
+
+	return s.GetPlan(planID)
+}
+
+// LastQueryID 返回已保存的计划关联的最大查询ID，没有计划时返回0
+func (s *Store) LastQueryID() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastQuery
+}
+
+// index 更新内存索引，调用方需持有写锁（或处于初始化阶段）
+func (s *Store) index(plan *types.ExecutionPlan, data []byte) {
+	s.plans[plan.ID] = data
+	s.updatedAt[plan.ID] = plan.UpdatedAt
+
+	if plan.QueryID == 0 {
+		return
+	}
+	if plan.QueryID > s.lastQuery {
+		s.lastQuery = plan.QueryID
+	}
+
+	// 同一查询可能生成多个计划，只保留最近更新的一个
+	if current, ok := s.queryPlans[plan.QueryID]; ok && current != plan.ID && s.updatedAt[current].After(plan.UpdatedAt) {
+		return
+	}
+	s.queryPlans[plan.QueryID] = plan.ID
+}
+
+// planPath 返回计划文件路径，计划ID中的路径字符会被替换
+func (s *Store) planPath(planID string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(planID)
+	return filepath.Join(s.dir, name+planFileExt)
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免进程崩溃时留下不完整的计划文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".plan_*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
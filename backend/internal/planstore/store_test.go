@@ -0,0 +1,68 @@
+package planstore
+
+import (
+	"errors"
+	"testing"
+
+	"smart-analysis/internal/types"
+)
+
+func TestStore_PersistAndReload(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := &types.ExecutionPlan{
+		ID:      "plan/1",
+		QueryID: 7,
+		Tasks: []*types.Task{
+			{ID: "task_1", Status: types.TaskStatusCompleted, Result: &types.TaskResult{Success: true, Output: "ok"}},
+			{ID: "task_2", Status: types.TaskStatusRunning, Dependencies: []string{"task_1"}},
+		},
+	}
+	if err := store.SavePlan(plan); err != nil {
+		t.Fatalf("保存计划失败: %v", err)
+	}
+
+	// 读取到的是副本，修改不影响存储
+	loaded, _ := store.GetPlan("plan/1")
+	loaded.Tasks[0].Status = types.TaskStatusFailed
+	if again, _ := store.GetPlan("plan/1"); again.Tasks[0].Status != types.TaskStatusCompleted {
+		t.Error("GetPlan 应返回独立副本")
+	}
+
+	// 模拟进程重启
+	reopened, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := reopened.GetPlanByQuery(7)
+	if err != nil {
+		t.Fatalf("重启后按查询获取计划失败: %v", err)
+	}
+	if restored.ID != "plan/1" || restored.Tasks[1].Status != types.TaskStatusRunning || restored.Tasks[0].Result.Output != "ok" {
+		t.Errorf("恢复的计划不正确: %+v", restored)
+	}
+	if last := reopened.LastQueryID(); last != 7 {
+		t.Errorf("重启后最大查询ID应为7，实际 %d", last)
+	}
+
+	if _, err := reopened.GetPlan("missing"); !errors.Is(err, types.ErrPlanNotFound) {
+		t.Errorf("期望返回ErrPlanNotFound，实际: %v", err)
+	}
+}
+
+func TestStore_LatestPlanPerQuery(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.SavePlan(&types.ExecutionPlan{ID: "first", QueryID: 1})
+	store.SavePlan(&types.ExecutionPlan{ID: "second", QueryID: 1})
+
+	plan, err := store.GetPlanByQuery(1)
+	if err != nil || plan.ID != "second" {
+		t.Errorf("期望返回最近的计划 second，实际: %v %v", plan, err)
+	}
+}
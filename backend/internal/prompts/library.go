@@ -0,0 +1,271 @@
+// Package prompts 管理智能体使用的提示词模板，支持版本、多语言和部署级覆盖
+package prompts
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"smart-analysis/internal/config"
+)
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+// 支持的语言
+const (
+	LanguageZH = "zh"
+	LanguageEN = "en"
+
+	// DefaultLanguage 默认语言，其他语言缺失的模板会回退到该语言
+	DefaultLanguage = LanguageZH
+)
+
+// 模板名称
+const (
+	TemplateReactSystem               = "react_system"
+	TemplateMasterIntentSystem        = "master_intent_system"
+	TemplateMasterIntentUser          = "master_intent_user"
+	TemplatePlannerSystem             = "planner_system"
+	TemplatePlannerUser               = "planner_user"
+	TemplatePlannerReplan             = "planner_replan"
+	TemplatePlannerSummary            = "planner_summary"
+	TemplateAnalysisCode              = "analysis_code"
+	TemplateExpertDataQuery           = "expert_data_query"
+	TemplateExpertDataAnalysis        = "expert_data_analysis"
+	TemplateExpertTrendForecast       = "expert_trend_forecast"
+	TemplateExpertAnomalyDetection    = "expert_anomaly_detection"
+	TemplateExpertAttributionAnalysis = "expert_attribution_analysis"
+	TemplateExpertText2SQL            = "expert_text2sql"
+	TemplateExpertText2SQLRepair      = "expert_text2sql_repair"
+	TemplateSemanticSuggest           = "semantic_suggest"
+	TemplateChartSelect               = "chart_select"
+	TemplateReport                    = "report"
+	TemplateStructuredOutputFormat    = "structured_output_format"
+	TemplateStructuredOutputRepair    = "structured_output_repair"
+)
+
+const templateExt = ".tmpl"
+
+// Library 提示词模板库
+type Library struct {
+	language    string
+	version     string
+	fingerprint string
+	templates   map[string]*template.Template
+}
+
+// Options 模板库配置
+type Options struct {
+	Language    string // 语言：zh / en
+	OverrideDir string // 部署级覆盖目录，结构为 <dir>/VERSION 和 <dir>/<lang>/<name>.tmpl
+}
+
+// New 创建模板库：按 覆盖目录/当前语言 -> 内置/当前语言 -> 内置/默认语言 的顺序解析模板
+func New(opts Options) (*Library, error) {
+	lang := strings.ToLower(strings.TrimSpace(opts.Language))
+	if lang == "" {
+		lang = DefaultLanguage
+	}
+	if lang != LanguageZH && lang != LanguageEN {
+		return nil, fmt.Errorf("unsupported prompt language: %s", lang)
+	}
+
+	embedded, err := fs.Sub(embeddedTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := readVersion(embedded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded prompt version: %w", err)
+	}
+
+	// 按优先级从低到高叠加模板源
+	sources := make(map[string]string)
+	if err := loadDir(embedded, DefaultLanguage, sources); err != nil {
+		return nil, err
+	}
+	if lang != DefaultLanguage {
+		if err := loadDir(embedded, lang, sources); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.OverrideDir != "" {
+		override := os.DirFS(opts.OverrideDir)
+		if v, err := readVersion(override); err == nil {
+			version = v
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read override prompt version: %w", err)
+		}
+		if err := loadDir(override, lang, sources); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to load override prompts: %w", err)
+		}
+	}
+
+	lib := &Library{
+		language:  lang,
+		version:   version,
+		templates: make(map[string]*template.Template, len(sources)),
+	}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		tmpl, err := template.New(name).Funcs(funcMap).Option("missingkey=error").Parse(sources[name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse prompt template %s: %w", name, err)
+		}
+		lib.templates[name] = tmpl
+		hash.Write([]byte(name))
+		hash.Write([]byte{0})
+		hash.Write([]byte(sources[name]))
+		hash.Write([]byte{0})
+	}
+	lib.fingerprint = hex.EncodeToString(hash.Sum(nil))[:8]
+
+	return lib, nil
+}
+
+// Language 返回模板库语言
+func (l *Library) Language() string {
+	return l.language
+}
+
+// Version 返回模板库版本标识，格式为 <version>/<lang>#<fingerprint>，
+// fingerprint 由实际生效的模板内容计算，覆盖模板未更新VERSION时也能区分
+func (l *Library) Version() string {
+	return fmt.Sprintf("%s/%s#%s", l.version, l.language, l.fingerprint)
+}
+
+// Names 返回所有模板名称
+func (l *Library) Names() []string {
+	names := make([]string, 0, len(l.templates))
+	for name := range l.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render 渲染指定模板
+func (l *Library) Render(name string, data interface{}) (string, error) {
+	tmpl, exists := l.templates[name]
+	if !exists {
+		return "", fmt.Errorf("prompt template %s not found", name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template %s: %w", name, err)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// funcMap 模板可用函数
+var funcMap = template.FuncMap{
+	"join": strings.Join,
+	"json": func(v interface{}) (string, error) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	},
+}
+
+// readVersion 读取VERSION文件
+func readVersion(fsys fs.FS) (string, error) {
+	data, err := fs.ReadFile(fsys, "VERSION")
+	if err != nil {
+		return "", err
+	}
+	version := strings.TrimSpace(string(data))
+	if version == "" {
+		return "", fmt.Errorf("VERSION is empty")
+	}
+	return version, nil
+}
+
+// loadDir 读取指定语言目录下的模板，覆盖已有同名模板
+func loadDir(fsys fs.FS, lang string, sources map[string]string) error {
+	entries, err := fs.ReadDir(fsys, lang)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != templateExt {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, lang+"/"+entry.Name())
+		if err != nil {
+			return err
+		}
+		sources[strings.TrimSuffix(entry.Name(), templateExt)] = string(data)
+	}
+
+	return nil
+}
+
+// 全局模板库实例
+var (
+	globalLibrary     *Library
+	globalLibraryOnce sync.Once
+	globalLibraryMu   sync.RWMutex
+)
+
+// GetGlobalLibrary 获取全局模板库，未初始化时使用内置的默认语言模板
+func GetGlobalLibrary() *Library {
+	globalLibraryOnce.Do(func() {
+		globalLibraryMu.Lock()
+		defer globalLibraryMu.Unlock()
+		if globalLibrary != nil {
+			return
+		}
+		lib, err := New(Options{Language: DefaultLanguage})
+		if err != nil {
+			// 内置模板在编译期确定，解析失败属于程序错误
+			panic(fmt.Sprintf("failed to load embedded prompts: %v", err))
+		}
+		globalLibrary = lib
+	})
+
+	globalLibraryMu.RLock()
+	defer globalLibraryMu.RUnlock()
+	return globalLibrary
+}
+
+// InitializeGlobalLibrary 根据应用配置初始化全局模板库
+func InitializeGlobalLibrary(appConfig *config.Config) error {
+	lib, err := New(Options{
+		Language:    appConfig.PromptLanguage,
+		OverrideDir: appConfig.PromptDir,
+	})
+	if err != nil {
+		return err
+	}
+
+	globalLibraryMu.Lock()
+	globalLibrary = lib
+	globalLibraryMu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLibrary_RenderAllLanguages(t *testing.T) {
+	data := map[string]interface{}{
+		"DataSchema":  nil,
+		"Query":       "各地区销售额",
+		"QueryIntent": map[string]interface{}{"intent_type": "analysis"},
+		"Agents": []struct {
+			Type         string
+			Name         string
+			Description  string
+			Capabilities []string
+			TaskTypes    []string
+			InputSchema  string
+		}{{Type: "data_query", Name: "数据查询", Capabilities: []string{"数据查询"}, TaskTypes: []string{"data_query"}, InputSchema: `{"type":"object"}`}},
+		"Name":   "QueryIntent",
+		"Schema": `{"type": "object"}`,
+		"Issues": []string{"/intent_type: value is not one of the allowed values"},
+		"FailedTask": map[string]interface{}{
+			"ID": "task_1", "AgentType": "data_query", "Description": "查询数据", "Input": nil,
+		},
+		"Error":     "执行超时",
+		"Completed": nil,
+		"Replaced":  []map[string]string{{"ID": "task_2", "AgentType": "data_analysis", "Description": "分析"}},
+		"Question":  "哪个地区销售额最高？",
+		"Dialect":   "sqlite",
+		"MaxRows":   1000,
+		"Semantic":  "指标口径:\n- 退货率 = SUM(returns) / COUNT(id)",
+		"Existing":  "",
+		"Sample":    "| region | sales |\n| --- | --- |\n| east | 10 |",
+		"ChartType": "bar",
+		"Tasks": []map[string]interface{}{{
+			"Description": "查询各地区销售额", "AgentType": "data_query", "Success": true,
+			"Output": "查询完成", "Table": "| region | sales |\n| --- | --- |\n| east | 10 |",
+			"Charts": []string{"/tmp/chart.png"}, "Error": "",
+		}},
+		"Description": "2024年销售数据",
+		"Dimensions":  []string{"地区", "月份"},
+		"Data":        []map[string]interface{}{{"region": "east", "sales": 10}},
+	}
+
+	for _, lang := range []string{LanguageZH, LanguageEN} {
+		lib, err := New(Options{Language: lang})
+		if err != nil {
+			t.Fatalf("加载 %s 模板失败: %v", lang, err)
+		}
+
+		for _, name := range lib.Names() {
+			out, err := lib.Render(name, data)
+			if err != nil {
+				t.Errorf("渲染 %s/%s 失败: %v", lang, name, err)
+				continue
+			}
+			if out == "" {
+				t.Errorf("渲染 %s/%s 结果为空", lang, name)
+			}
+		}
+
+		if !strings.Contains(lib.Version(), "/"+lang+"#") {
+			t.Errorf("版本号未包含语言: %s", lib.Version())
+		}
+	}
+}
+
+func TestLibrary_Override(t *testing.T) {
+	base, err := New(Options{Language: LanguageEN})
+	if err != nil {
+		t.Fatalf("加载模板失败: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, LanguageEN), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "VERSION"), []byte("v9.9.9-exp\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, LanguageEN, TemplatePlannerUser+".tmpl"), []byte("Plan it, {{.Name}}."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lib, err := New(Options{Language: LanguageEN, OverrideDir: dir})
+	if err != nil {
+		t.Fatalf("加载覆盖模板失败: %v", err)
+	}
+
+	out, err := lib.Render(TemplatePlannerUser, map[string]string{"Name": "planner"})
+	if err != nil {
+		t.Fatalf("渲染覆盖模板失败: %v", err)
+	}
+	if out != "Plan it, planner." {
+		t.Errorf("覆盖模板未生效: %q", out)
+	}
+
+	if !strings.HasPrefix(lib.Version(), "v9.9.9-exp/en#") {
+		t.Errorf("覆盖版本未生效: %s", lib.Version())
+	}
+	if lib.Version() == base.Version() {
+		t.Error("覆盖模板后版本号应发生变化")
+	}
+
+	// 未覆盖的模板仍使用内置版本
+	baseOut, _ := base.Render(TemplateReactSystem, nil)
+	libOut, _ := lib.Render(TemplateReactSystem, nil)
+	if baseOut != libOut {
+		t.Error("未覆盖的模板不应变化")
+	}
+}
+
+func TestNew_UnsupportedLanguage(t *testing.T) {
+	if _, err := New(Options{Language: "fr"}); err == nil {
+		t.Error("期望不支持的语言返回错误")
+	}
+}
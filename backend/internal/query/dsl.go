@@ -0,0 +1,574 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"smart-analysis/internal/types"
+)
+
+// 管道查询支持的操作
+const (
+	OpFilter  = "filter"
+	OpSelect  = "select"
+	OpGroupBy = "groupby"
+	OpAgg     = "agg"
+	OpSort    = "sort"
+	OpHead    = "head"
+	OpPivot   = "pivot"
+)
+
+// PipelineSyntax 管道查询语法说明，用于工具描述和语法错误提示
+const PipelineSyntax = `查询由若干步骤组成，步骤之间用 | 分隔，按顺序执行：
+  filter 条件 [and 条件...]     条件: 列 ==|!=|>|>=|<|<= 值, 列 [not] in [值1, 值2], 列 [not] like "模式%"
+  select 列1, 列2               保留指定列
+  groupby 列1, 列2              分组，后面通常紧跟 agg，省略时统计行数
+  agg 函数(列) [as 别名], ...    函数: sum, avg, min, max, count, count_distinct, median；count(*) 统计行数
+  sort 列 [asc|desc], ...        排序
+  head N                        取前N行
+  pivot index=列 columns=列 [values=列] [agg=函数]   透视表，默认 sum（未指定 values 时为 count）
+字符串用引号，包含空格或特殊字符的列名用反引号，例如:
+  filter region == "华东" and sales > 100 | groupby product | agg sum(sales) as total, count(*) | sort total desc | head 10`
+
+// Pipeline 解析后的管道查询
+type Pipeline struct {
+	Stages []Stage
+}
+
+// Stage 管道中的一个步骤
+type Stage struct {
+	Op         string
+	Conditions []types.FilterCondition // filter
+	Columns    []string                // select, groupby
+	Aggs       []AggSpec               // agg
+	Orders     []types.OrderCondition  // sort
+	Limit      int                     // head
+	Pivot      *PivotSpec              // pivot
+}
+
+// AggSpec 聚合表达式，Func 已规范为标准函数名
+type AggSpec struct {
+	Func   string
+	Column string // count(*) 为空
+	Alias  string
+}
+
+// String 返回聚合表达式的文本形式
+func (a AggSpec) String() string {
+	column := a.Column
+	if column == "" {
+		column = "*"
+	}
+	return fmt.Sprintf("%s(%s)", a.Func, column)
+}
+
+// PivotSpec 透视表参数
+type PivotSpec struct {
+	Index   string
+	Columns string
+	Values  string
+	Func    string
+}
+
+// ParsePipeline 解析管道查询，语法见 PipelineSyntax
+func ParsePipeline(text string) (*Pipeline, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := &Pipeline{}
+	var current []token
+	flush := func() error {
+		n := len(pipeline.Stages) + 1
+		if len(current) == 0 {
+			return fmt.Errorf("第%d步为空", n)
+		}
+		p := &stageParser{tokens: current}
+		stage, err := p.parse()
+		if err != nil {
+			return fmt.Errorf("第%d步: %w", n, err)
+		}
+		pipeline.Stages = append(pipeline.Stages, *stage)
+		current = nil
+		return nil
+	}
+
+	for _, tok := range tokens {
+		if tok.kind == tokenPunct && tok.text == "|" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		current = append(current, tok)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+// token 词法单元
+type token struct {
+	kind string
+	text string
+	pos  int // 在原始文本中的字符位置，从1开始
+}
+
+const (
+	tokenIdent  = "ident"
+	tokenString = "string"
+	tokenNumber = "number"
+	tokenOp     = "op"
+	tokenPunct  = "punct"
+)
+
+// tokenize 将查询文本切分为词法单元
+func tokenize(text string) ([]token, error) {
+	runes := []rune(text)
+	var tokens []token
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'' || r == '`':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) && r != '`' {
+					j++
+				}
+				b.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("位置 %d: 引号 %c 未闭合", pos, r)
+			}
+			kind := tokenString
+			if r == '`' {
+				kind = tokenIdent
+			}
+			tokens = append(tokens, token{kind: kind, text: b.String(), pos: pos})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' || r == '.') && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || strings.ContainsRune(".eE", runes[j]) ||
+				(runes[j] == '-' || runes[j] == '+') && (runes[j-1] == 'e' || runes[j-1] == 'E')) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[i:j]), pos: pos})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[i:j]), pos: pos})
+			i = j
+		case strings.ContainsRune("=!<>&", r):
+			j := i + 1
+			if j < len(runes) && strings.ContainsRune("=>&", runes[j]) {
+				j++
+			}
+			op := string(runes[i:j])
+			switch op {
+			case "=", "==", "!=", "<>", ">", ">=", "<", "<=", "&&":
+			default:
+				return nil, fmt.Errorf("位置 %d: 无法识别的运算符 %s", pos, op)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: pos})
+			i = j
+		case strings.ContainsRune("|,()[]*", r):
+			tokens = append(tokens, token{kind: tokenPunct, text: string(r), pos: pos})
+			i++
+		default:
+			return nil, fmt.Errorf("位置 %d: 无法识别的字符 %q", pos, r)
+		}
+	}
+	return tokens, nil
+}
+
+// stageParser 解析单个步骤
+type stageParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *stageParser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *stageParser) next() *token {
+	tok := p.peek()
+	if tok != nil {
+		p.pos++
+	}
+	return tok
+}
+
+// keyword 判断下一个词是否为指定关键字（不区分大小写），是则消费
+func (p *stageParser) keyword(word string) bool {
+	if tok := p.peek(); tok != nil && tok.kind == tokenIdent && strings.EqualFold(tok.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// punct 判断下一个词是否为指定符号，是则消费
+func (p *stageParser) punct(text string) bool {
+	if tok := p.peek(); tok != nil && tok.kind == tokenPunct && tok.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// errorf 生成带位置信息的错误
+func (p *stageParser) errorf(format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if tok := p.peek(); tok != nil {
+		return fmt.Errorf("位置 %d（%s）: %s", tok.pos, tok.text, msg)
+	}
+	return fmt.Errorf("语句不完整: %s", msg)
+}
+
+func (p *stageParser) expectPunct(text string) error {
+	if !p.punct(text) {
+		return p.errorf("缺少 %s", text)
+	}
+	return nil
+}
+
+func (p *stageParser) ident(what string) (string, error) {
+	tok := p.peek()
+	if tok == nil || tok.kind != tokenIdent {
+		return "", p.errorf("需要%s", what)
+	}
+	p.pos++
+	return tok.text, nil
+}
+
+// identList 解析逗号分隔的列名
+func (p *stageParser) identList() ([]string, error) {
+	var names []string
+	for {
+		name, err := p.ident("列名")
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.punct(",") {
+			return names, nil
+		}
+	}
+}
+
+func (p *stageParser) parse() (*Stage, error) {
+	opTok := p.next()
+	if opTok.kind != tokenIdent {
+		p.pos--
+		return nil, p.errorf("需要操作名，支持: filter, select, groupby, agg, sort, head, pivot")
+	}
+
+	stage := &Stage{Op: strings.ToLower(opTok.text)}
+	var err error
+	switch stage.Op {
+	case OpFilter, "where":
+		stage.Op = OpFilter
+		stage.Conditions, err = p.conditions()
+	case OpSelect:
+		stage.Columns, err = p.identList()
+	case OpGroupBy, "group_by":
+		stage.Op = OpGroupBy
+		stage.Columns, err = p.identList()
+	case OpAgg, "aggregate":
+		stage.Op = OpAgg
+		stage.Aggs, err = p.aggs()
+	case OpSort, "order_by", "orderby":
+		stage.Op = OpSort
+		stage.Orders, err = p.orders()
+	case OpHead, "limit":
+		stage.Op = OpHead
+		stage.Limit, err = p.limit()
+	case OpPivot:
+		stage.Pivot, err = p.pivot()
+	default:
+		p.pos--
+		return nil, p.errorf("未知操作 %s，支持: filter, select, groupby, agg, sort, head, pivot", opTok.text)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek() != nil {
+		return nil, p.errorf("多余的内容")
+	}
+	return stage, nil
+}
+
+// conditions 解析用 and 连接的过滤条件
+func (p *stageParser) conditions() ([]types.FilterCondition, error) {
+	var conditions []types.FilterCondition
+	for {
+		cond, err := p.condition()
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, cond)
+
+		if tok := p.peek(); tok != nil && tok.kind == tokenOp && tok.text == "&&" {
+			p.pos++
+			continue
+		}
+		if p.keyword("and") {
+			continue
+		}
+		if tok := p.peek(); tok != nil && tok.kind == tokenIdent && strings.EqualFold(tok.text, "or") {
+			return nil, p.errorf("不支持 or，请改用 in 或拆分为多个查询")
+		}
+		return conditions, nil
+	}
+}
+
+func (p *stageParser) condition() (types.FilterCondition, error) {
+	column, err := p.ident("列名")
+	if err != nil {
+		return types.FilterCondition{}, err
+	}
+	cond := types.FilterCondition{Column: column}
+
+	negate := p.keyword("not")
+	switch {
+	case p.keyword("in"):
+		cond.Operator = "IN"
+		values, err := p.valueList()
+		if err != nil {
+			return cond, err
+		}
+		cond.Value = values
+	case p.keyword("like"):
+		cond.Operator = "LIKE"
+		tok := p.peek()
+		if tok == nil || tok.kind != tokenString {
+			return cond, p.errorf("like 需要字符串模式")
+		}
+		p.pos++
+		cond.Value = tok.text
+	case negate:
+		return cond, p.errorf("not 之后需要 in 或 like")
+	default:
+		tok := p.peek()
+		if tok == nil || tok.kind != tokenOp || tok.text == "&&" {
+			return cond, p.errorf("需要比较运算符 ==, !=, >, >=, <, <=, in 或 like")
+		}
+		p.pos++
+		cond.Operator = tok.text
+		switch tok.text {
+		case "=":
+			cond.Operator = "=="
+		case "<>":
+			cond.Operator = "!="
+		}
+		if cond.Value, err = p.value(); err != nil {
+			return cond, err
+		}
+	}
+	if negate {
+		cond.Operator = "NOT " + cond.Operator
+	}
+	return cond, nil
+}
+
+// value 解析字面量：字符串、数值、true/false
+func (p *stageParser) value() (interface{}, error) {
+	tok := p.peek()
+	if tok == nil {
+		return nil, p.errorf("需要值")
+	}
+	switch tok.kind {
+	case tokenString:
+		p.pos++
+		return tok.text, nil
+	case tokenNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("无法识别的数值")
+		}
+		p.pos++
+		return f, nil
+	case tokenIdent:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			p.pos++
+			return true, nil
+		case "false":
+			p.pos++
+			return false, nil
+		}
+		return nil, p.errorf("字符串值需要加引号")
+	}
+	return nil, p.errorf("需要值")
+}
+
+// valueList 解析 [值1, 值2] 形式的列表
+func (p *stageParser) valueList() ([]interface{}, error) {
+	if err := p.expectPunct("["); err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	for {
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if p.punct("]") {
+			return values, nil
+		}
+		if err := p.expectPunct(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// aggs 解析逗号分隔的聚合表达式
+func (p *stageParser) aggs() ([]AggSpec, error) {
+	var specs []AggSpec
+	for {
+		fnTok := p.peek()
+		name, err := p.ident("聚合函数")
+		if err != nil {
+			return nil, err
+		}
+		fn, ok := aggAliases[strings.ToLower(name)]
+		if !ok {
+			p.pos--
+			return nil, p.errorf("不支持的聚合函数 %s，支持: sum, avg, min, max, count, count_distinct, median", fnTok.text)
+		}
+
+		spec := AggSpec{Func: fn}
+		if err := p.expectPunct("("); err != nil {
+			return nil, err
+		}
+		if !p.punct("*") {
+			if spec.Column, err = p.ident("列名或 *"); err != nil {
+				return nil, err
+			}
+		} else if fn != aggCount {
+			p.pos--
+			return nil, p.errorf("%s 需要指定列", fn)
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+
+		if p.keyword("as") {
+			if spec.Alias, err = p.ident("别名"); err != nil {
+				return nil, err
+			}
+		}
+		specs = append(specs, spec)
+
+		if !p.punct(",") {
+			return specs, nil
+		}
+	}
+}
+
+// orders 解析排序字段
+func (p *stageParser) orders() ([]types.OrderCondition, error) {
+	var orders []types.OrderCondition
+	for {
+		column, err := p.ident("排序列")
+		if err != nil {
+			return nil, err
+		}
+		order := types.OrderCondition{Column: column, Direction: "ASC"}
+		if p.keyword("desc") {
+			order.Direction = "DESC"
+		} else {
+			p.keyword("asc")
+		}
+		orders = append(orders, order)
+
+		if !p.punct(",") {
+			return orders, nil
+		}
+	}
+}
+
+func (p *stageParser) limit() (int, error) {
+	tok := p.peek()
+	if tok == nil || tok.kind != tokenNumber {
+		return 0, p.errorf("head 需要正整数")
+	}
+	n, err := strconv.Atoi(tok.text)
+	if err != nil || n <= 0 {
+		return 0, p.errorf("head 需要正整数")
+	}
+	p.pos++
+	return n, nil
+}
+
+// pivot 解析 key=value 形式的透视表参数
+func (p *stageParser) pivot() (*PivotSpec, error) {
+	spec := &PivotSpec{}
+	for p.peek() != nil {
+		key, err := p.ident("参数名 index、columns、values 或 agg")
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok == nil || tok.kind != tokenOp || tok.text != "=" {
+			if tok != nil {
+				p.pos--
+			}
+			return nil, p.errorf("参数 %s 后需要 =", key)
+		}
+		value, err := p.ident(key + " 的取值")
+		if err != nil {
+			return nil, err
+		}
+
+		switch strings.ToLower(key) {
+		case "index":
+			spec.Index = value
+		case "columns":
+			spec.Columns = value
+		case "values":
+			spec.Values = value
+		case "agg", "aggfunc":
+			fn, ok := aggAliases[strings.ToLower(value)]
+			if !ok {
+				p.pos--
+				return nil, p.errorf("不支持的聚合函数 %s", value)
+			}
+			spec.Func = fn
+		default:
+			p.pos -= 3
+			return nil, p.errorf("未知参数 %s，支持: index, columns, values, agg", key)
+		}
+		p.punct(",")
+	}
+
+	if spec.Index == "" || spec.Columns == "" {
+		return nil, p.errorf("pivot 需要 index 和 columns")
+	}
+	if spec.Func == "" {
+		spec.Func = aggSum
+		if spec.Values == "" {
+			spec.Func = aggCount
+		}
+	}
+	if spec.Values == "" && spec.Func != aggCount {
+		return nil, p.errorf("pivot 使用 %s 时需要 values", spec.Func)
+	}
+	return spec, nil
+}
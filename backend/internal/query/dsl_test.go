@@ -0,0 +1,152 @@
+package query
+
+import (
+	"encoding/json"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"smart-analysis/internal/types"
+)
+
+func TestPipeline_Execute(t *testing.T) {
+	p, err := ParsePipeline(`filter region in ["华东", "华北"] and sales >= 100 | groupby region | agg sum(sales) as total, count(*) | sort total desc, region | head 5`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := p.Execute(salesTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]interface{}{{"华东", 300.0, 2}, {"华北", 300.0, 1}}
+	if !reflect.DeepEqual(result.Columns, []string{"region", "total", "count"}) || !reflect.DeepEqual(result.Rows, want) {
+		t.Errorf("管道查询结果不正确: %v %v", result.Columns, result.Rows)
+	}
+}
+
+func TestPipeline_Pivot(t *testing.T) {
+	p, err := ParsePipeline("select region, product, sales | pivot index=region columns=product values=sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := p.Execute(salesTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantColumns := []string{"region", "手机", "电脑", "耳机"}
+	wantRows := [][]interface{}{
+		{"华东", 150.0, 200.0, nil},
+		{"华北", 0.0, 300.0, nil},
+		{"华南", nil, nil, 80.0},
+	}
+	if !reflect.DeepEqual(result.Columns, wantColumns) || !reflect.DeepEqual(result.Rows, wantRows) {
+		t.Errorf("透视表不正确: %v %v", result.Columns, result.Rows)
+	}
+}
+
+func TestParsePipeline_Errors(t *testing.T) {
+	cases := map[string]string{
+		"df.__class__":                       "位置 3",
+		"filter sales > 1 | sortt sales":     "第2步",
+		"filter region == 华东":                "字符串值需要加引号",
+		"filter region == '华东' or sales > 1": "不支持 or",
+		"agg stddev(sales)":                  "不支持的聚合函数 stddev",
+		"agg sum(*)":                         "sum 需要指定列",
+		"head -1":                            "head 需要正整数",
+		"pivot index=region":                 "需要 index 和 columns",
+		"filter region == 'x":                "未闭合",
+		"select region |":                    "第2步为空",
+		"__import__('os').system('ls')":      "无法识别的字符 '.'",
+		"eval sales":                         "未知操作 eval",
+	}
+	for query, want := range cases {
+		_, err := ParsePipeline(query)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParsePipeline(%q) 的错误应包含 %q，实际: %v", query, want, err)
+		}
+	}
+
+	p, _ := ParsePipeline("filter city == 'x'")
+	if _, err := p.Execute(salesTable()); err == nil || !strings.Contains(err.Error(), "第1步 filter") {
+		t.Errorf("列不存在时应返回带步骤的错误: %v", err)
+	}
+}
+
+func TestPipeline_Pandas(t *testing.T) {
+	p, err := ParsePipeline(`filter product like "手%" and region not in ["'); import os; ('"] | groupby region | agg avg(sales), count(*) | sort avg_sales desc | head 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		`df = df[(df["product"].astype(str).str.match("(?is)^手.*$", na=False)) & (~df["region"].isin(["'); import os; ('"]))]`,
+		`df = df.groupby(["region"], dropna=False).agg(**{"avg_sales": ("sales", "mean"), "count": ("region", "size")}).reset_index()`,
+		`df = df.sort_values(["avg_sales"], ascending=[False], na_position="last")`,
+		`df = df.head(3)`,
+	}, "\n")
+	if got := p.Pandas(); got != want {
+		t.Errorf("生成的pandas代码不正确:\n%s\n期望:\n%s", got, want)
+	}
+}
+
+// pythonLiterals 用python3解析代码，返回其中的字符串常量和引用的变量名，python3 不存在时跳过测试
+func pythonLiterals(t *testing.T, code string) (strs []string, names []string) {
+	t.Helper()
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	script := `
+import ast, json, sys
+tree = ast.parse(sys.stdin.read())
+strs = [n.value for n in ast.walk(tree) if isinstance(n, ast.Constant) and isinstance(n.value, str)]
+names = sorted({n.id for n in ast.walk(tree) if isinstance(n, ast.Name)})
+print(json.dumps({"strs": strs, "names": names}))
+`
+	cmd := exec.Command(python, "-c", script)
+	cmd.Stdin = strings.NewReader(code)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("生成的代码不是合法的Python: %v\n%s", err, code)
+	}
+	var parsed struct {
+		Strs  []string `json:"strs"`
+		Names []string `json:"names"`
+	}
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Strs, parsed.Names
+}
+
+func TestPipeline_PandasLiterals(t *testing.T) {
+	attack := `'''+str(__import__('os').system('echo INJECTED'))+'''`
+	p, err := ParsePipeline(`filter region == "` + attack + `"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 取值和列名中的三引号、反斜杠和换行都只作为字面量
+	column := "a'''b\\c\nd\"e"
+	value := "x'''\\n\\\ny\"" + attack
+	p.Stages = append(p.Stages,
+		Stage{Op: OpFilter, Conditions: []types.FilterCondition{{Column: column, Operator: "==", Value: value}}},
+		Stage{Op: OpSelect, Columns: []string{column}},
+	)
+
+	strs, names := pythonLiterals(t, p.Pandas())
+	if !reflect.DeepEqual(names, []string{"df"}) {
+		t.Errorf("生成的代码只应引用 df，实际: %v", names)
+	}
+	for _, literal := range []string{attack, column, value} {
+		found := false
+		for _, s := range strs {
+			found = found || s == literal
+		}
+		if !found {
+			t.Errorf("字面量 %q 没有原样保留，实际: %q", literal, strs)
+		}
+	}
+}
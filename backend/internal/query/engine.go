@@ -0,0 +1,417 @@
+// Package query 在进程内直接执行 types.QueryObject：过滤、时间范围、按时间粒度分桶、
+// 分组聚合、排序和限制行数，结果确定且不依赖LLM
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"smart-analysis/internal/analytics"
+	"smart-analysis/internal/types"
+)
+
+// 查询对象 Metadata 中可以显式指定的字段
+const (
+	MetadataTimeColumn  = "time_column"
+	MetadataEventColumn = "event_column"
+)
+
+// 未显式指定时按顺序匹配的时间列和事件列名
+var (
+	timeColumnCandidates  = []string{"date", "time", "timestamp", "datetime", "event_time", "created_at", "order_date", "dt"}
+	eventColumnCandidates = []string{"event", "event_name", "event_type"}
+)
+
+// dimension 分组维度
+type dimension struct {
+	Name   string
+	Index  int
+	Bucket string // 时间粒度，为空表示按原值分组
+}
+
+// group 分组聚合的中间结果
+type group struct {
+	keys []interface{}
+	accs []*accumulator
+}
+
+// Execute 对数据表执行查询对象。维度为 Dimensions 与 GroupBy 的并集；
+// 有维度或度量时按维度分组聚合（只有维度时统计行数），否则返回过滤后的明细
+func Execute(table *analytics.Table, q *types.QueryObject) (*analytics.Table, error) {
+	if q == nil {
+		q = &types.QueryObject{}
+	}
+
+	predicates, timeIdx, err := buildPredicates(table, q)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([][]interface{}, 0, len(table.Rows))
+	for _, row := range table.Rows {
+		matched := true
+		for _, p := range predicates {
+			if !p(row) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			rows = append(rows, row)
+		}
+	}
+
+	dims, err := buildDimensions(table, q, timeIdx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		result  *analytics.Table
+		metrics []*metric
+	)
+	if len(dims) == 0 && len(q.Metrics) == 0 {
+		result = &analytics.Table{Columns: table.Columns, Rows: rows}
+	} else {
+		for _, expr := range q.Metrics {
+			m, err := parseMetric(expr, table)
+			if err != nil {
+				return nil, err
+			}
+			metrics = append(metrics, m)
+		}
+		if len(metrics) == 0 {
+			metrics = []*metric{{Expr: aggCount, Func: aggCount, Name: aggCount}}
+		}
+		if result, err = aggregate(table, rows, dims, metrics); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := sortRows(result, q.OrderBy, metrics, len(dims)); err != nil {
+		return nil, err
+	}
+	if q.Limit > 0 && len(result.Rows) > q.Limit {
+		result.Rows = result.Rows[:q.Limit]
+	}
+	return result, nil
+}
+
+// buildPredicates 编译过滤条件、事件条件和时间范围，返回使用的时间列位置（未使用时为-1）
+func buildPredicates(table *analytics.Table, q *types.QueryObject) ([]predicate, int, error) {
+	var predicates []predicate
+	for _, filter := range q.Filters {
+		p, err := buildFilter(filter, table)
+		if err != nil {
+			return nil, -1, err
+		}
+		predicates = append(predicates, p)
+	}
+
+	if p, err := eventFilter(table, q); err != nil {
+		return nil, -1, err
+	} else if p != nil {
+		predicates = append(predicates, p)
+	}
+
+	timeIdx := -1
+	if tr := q.TimeRange; tr != nil && (tr.StartTime != "" || tr.EndTime != "" || tr.Granularity != "") {
+		var err error
+		if timeIdx, err = timeColumn(table, q); err != nil {
+			return nil, -1, err
+		}
+		if tr.StartTime != "" || tr.EndTime != "" {
+			p, err := timeRangeFilter(tr, timeIdx)
+			if err != nil {
+				return nil, -1, err
+			}
+			predicates = append(predicates, p)
+		}
+	}
+
+	return predicates, timeIdx, nil
+}
+
+// eventFilter 按 Events 过滤事件列。事件列未显式指定时按常见列名识别，
+// 且只有列中出现过所列事件时才过滤，避免把业务描述性的事件名当作取值
+func eventFilter(table *analytics.Table, q *types.QueryObject) (predicate, error) {
+	if len(q.Events) == 0 {
+		return nil, nil
+	}
+
+	events := make(map[string]bool, len(q.Events))
+	for _, event := range q.Events {
+		events[event] = true
+	}
+
+	idx := -1
+	if name := metadataString(q, MetadataEventColumn); name != "" {
+		if idx = table.ColumnIndex(name); idx < 0 {
+			return nil, fmt.Errorf("事件字段 %s 不存在，可用列: %v", name, table.Columns)
+		}
+	} else {
+		idx = findColumn(table, eventColumnCandidates)
+		if idx < 0 || !columnContains(table, idx, events) {
+			return nil, nil
+		}
+	}
+
+	return func(row []interface{}) bool {
+		return idx < len(row) && events[analytics.CellString(row[idx])]
+	}, nil
+}
+
+// timeColumn 确定时间列：优先使用 Metadata 中指定的列，其次按常见列名匹配，最后取首个取值为日期字符串的列
+func timeColumn(table *analytics.Table, q *types.QueryObject) (int, error) {
+	if name := metadataString(q, MetadataTimeColumn); name != "" {
+		if idx := table.ColumnIndex(name); idx >= 0 {
+			return idx, nil
+		}
+		return -1, fmt.Errorf("时间字段 %s 不存在，可用列: %v", name, table.Columns)
+	}
+
+	if idx := findColumn(table, timeColumnCandidates); idx >= 0 {
+		return idx, nil
+	}
+
+	for idx := range table.Columns {
+		for _, row := range table.Rows {
+			if idx >= len(row) || analytics.CellString(row[idx]) == "" {
+				continue
+			}
+			if isDateString(row[idx]) {
+				return idx, nil
+			}
+			break
+		}
+	}
+	return -1, fmt.Errorf("找不到时间字段，请在 metadata.%s 中指定", MetadataTimeColumn)
+}
+
+// buildDimensions 解析分组维度，设置了时间粒度时时间列按粒度分桶，时间列不在维度中时放在最前
+func buildDimensions(table *analytics.Table, q *types.QueryObject, timeIdx int) ([]dimension, error) {
+	var dims []dimension
+	seen := make(map[string]bool)
+	for _, name := range append(append([]string{}, q.Dimensions...), q.GroupBy...) {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		idx := table.ColumnIndex(name)
+		if idx < 0 {
+			return nil, fmt.Errorf("维度 %s 不存在，可用列: %v", name, table.Columns)
+		}
+		dims = append(dims, dimension{Name: name, Index: idx})
+	}
+
+	if q.TimeRange == nil || q.TimeRange.Granularity == "" {
+		return dims, nil
+	}
+	if _, err := timeBucket(time.Time{}, q.TimeRange.Granularity); err != nil {
+		return nil, err
+	}
+
+	for i := range dims {
+		if dims[i].Index == timeIdx {
+			dims[i].Bucket = q.TimeRange.Granularity
+			return dims, nil
+		}
+	}
+	bucket := dimension{Name: table.Columns[timeIdx], Index: timeIdx, Bucket: q.TimeRange.Granularity}
+	return append([]dimension{bucket}, dims...), nil
+}
+
+// aggregate 按维度分组并计算度量，分组按首次出现的顺序排列
+func aggregate(table *analytics.Table, rows [][]interface{}, dims []dimension, metrics []*metric) (*analytics.Table, error) {
+	metricIdx := make([]int, len(metrics))
+	for i, m := range metrics {
+		metricIdx[i] = -1
+		if m.Column != "" {
+			metricIdx[i] = table.ColumnIndex(m.Column)
+		}
+	}
+
+	groups := make(map[string]*group)
+	var order []string
+	for _, row := range rows {
+		keys := make([]interface{}, len(dims))
+		parts := make([]string, len(dims))
+		skip := false
+		for i, dim := range dims {
+			var value interface{}
+			if dim.Index < len(row) {
+				value = row[dim.Index]
+			}
+			if dim.Bucket != "" {
+				t, ok := analytics.ParseTime(value)
+				if !ok {
+					skip = true
+					break
+				}
+				value, _ = timeBucket(t, dim.Bucket)
+			}
+			keys[i] = value
+			parts[i] = analytics.CellString(value)
+		}
+		if skip {
+			continue
+		}
+
+		key := strings.Join(parts, "\x00")
+		g, ok := groups[key]
+		if !ok {
+			g = &group{keys: keys, accs: make([]*accumulator, len(metrics))}
+			for i, m := range metrics {
+				g.accs[i] = newAccumulator(m.Func)
+			}
+			groups[key] = g
+			order = append(order, key)
+		}
+
+		for i, idx := range metricIdx {
+			var value interface{}
+			if idx >= 0 && idx < len(row) {
+				value = row[idx]
+			}
+			g.accs[i].add(value, idx < 0)
+		}
+	}
+
+	result := &analytics.Table{}
+	for _, dim := range dims {
+		result.Columns = append(result.Columns, dim.Name)
+	}
+	for _, m := range metrics {
+		result.Columns = append(result.Columns, m.Name)
+	}
+
+	// 没有维度时即使没有匹配的行也返回一行汇总结果
+	if len(dims) == 0 && len(order) == 0 {
+		g := &group{accs: make([]*accumulator, len(metrics))}
+		for i, m := range metrics {
+			g.accs[i] = newAccumulator(m.Func)
+		}
+		groups[""] = g
+		order = append(order, "")
+	}
+
+	for _, key := range order {
+		g := groups[key]
+		row := append([]interface{}{}, g.keys...)
+		for _, acc := range g.accs {
+			row = append(row, acc.result())
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, nil
+}
+
+// sortRows 按排序条件排序。未指定排序时聚合结果按维度升序排列，明细保持原顺序
+func sortRows(result *analytics.Table, orderBy []types.OrderCondition, metrics []*metric, dimCount int) error {
+	type sortKey struct {
+		index int
+		desc  bool
+	}
+
+	var keys []sortKey
+	for _, order := range orderBy {
+		idx := result.ColumnIndex(order.Column)
+		if idx < 0 {
+			// 允许使用度量表达式引用结果列，如 sum(sales)
+			for _, m := range metrics {
+				if strings.EqualFold(m.Expr, order.Column) {
+					idx = result.ColumnIndex(m.Name)
+				}
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf("排序字段 %s 不在结果中，可用列: %v", order.Column, result.Columns)
+		}
+		keys = append(keys, sortKey{index: idx, desc: strings.EqualFold(order.Direction, "DESC")})
+	}
+	if len(keys) == 0 {
+		for i := 0; i < dimCount; i++ {
+			keys = append(keys, sortKey{index: i})
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(result.Rows, func(i, j int) bool {
+		for _, key := range keys {
+			c := compareSortValues(result.Rows[i][key.index], result.Rows[j][key.index])
+			if c == 0 {
+				continue
+			}
+			if key.desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	return nil
+}
+
+// compareSortValues 排序比较，空值排在最后
+func compareSortValues(a, b interface{}) int {
+	emptyA, emptyB := !nonEmpty(a), !nonEmpty(b)
+	switch {
+	case emptyA && emptyB:
+		return 0
+	case emptyA:
+		return 1
+	case emptyB:
+		return -1
+	}
+	return compareValues(a, b)
+}
+
+// findColumn 按候选列名的顺序查找列（不区分大小写），找不到时返回-1
+func findColumn(table *analytics.Table, candidates []string) int {
+	for _, candidate := range candidates {
+		for i, column := range table.Columns {
+			if strings.EqualFold(column, candidate) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// columnContains 判断列中是否出现过任一取值
+func columnContains(table *analytics.Table, idx int, values map[string]bool) bool {
+	for _, row := range table.Rows {
+		if idx < len(row) && values[analytics.CellString(row[idx])] {
+			return true
+		}
+	}
+	return false
+}
+
+// isDateString 判断值是否为日期时间字符串（纯数字不算）
+func isDateString(value interface{}) bool {
+	s, ok := value.(string)
+	if !ok {
+		return false
+	}
+	if _, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+		return false
+	}
+	_, ok = analytics.ParseTime(s)
+	return ok
+}
+
+// metadataString 读取查询对象 Metadata 中的字符串
+func metadataString(q *types.QueryObject, key string) string {
+	if q.Metadata == nil {
+		return ""
+	}
+	s, _ := q.Metadata[key].(string)
+	return s
+}
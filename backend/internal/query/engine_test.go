@@ -0,0 +1,96 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+
+	"smart-analysis/internal/analytics"
+	"smart-analysis/internal/types"
+)
+
+// salesTable 两个地区一月和二月的订单
+func salesTable() *analytics.Table {
+	return &analytics.Table{
+		Columns: []string{"order_date", "region", "product", "sales", "user_id"},
+		Rows: [][]interface{}{
+			{"2024-01-03", "华东", "手机", 100.0, "u1"},
+			{"2024-01-15", "华北", "电脑", 300.0, "u2"},
+			{"2024-01-31 18:00:00", "华东", "电脑", 200.0, "u1"},
+			{"2024-02-02", "华东", "手机", "50", "u3"},
+			{"2024-02-10", "华北", "手机", nil, "u2"},
+			{"2024-02-20", "华南", "耳机", 80.0, "u4"},
+		},
+	}
+}
+
+func TestExecute_GroupByWithTimeRange(t *testing.T) {
+	result, err := Execute(salesTable(), &types.QueryObject{
+		Dimensions: []string{"region"},
+		Metrics:    []string{"sum(sales)", "count(*) as orders", "count_distinct(user_id)"},
+		Filters:    []types.FilterCondition{{Column: "region", Operator: "IN", Value: []interface{}{"华东", "华北"}}},
+		TimeRange:  &types.TimeRange{StartTime: "2024-01-01", EndTime: "2024-01-31"},
+		OrderBy:    []types.OrderCondition{{Column: "sum(sales)", Direction: "DESC"}, {Column: "orders", Direction: "DESC"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantColumns := []string{"region", "sum_sales", "orders", "count_distinct_user_id"}
+	wantRows := [][]interface{}{
+		{"华东", 300.0, 2, 1},
+		{"华北", 300.0, 1, 1},
+	}
+	if !reflect.DeepEqual(result.Columns, wantColumns) || !reflect.DeepEqual(result.Rows, wantRows) {
+		t.Errorf("期望 %v %v，实际 %v %v", wantColumns, wantRows, result.Columns, result.Rows)
+	}
+}
+
+func TestExecute_Granularity(t *testing.T) {
+	result, err := Execute(salesTable(), &types.QueryObject{
+		Metrics:   []string{"sales", "avg(sales)"},
+		TimeRange: &types.TimeRange{Granularity: "month"},
+		OrderBy:   []types.OrderCondition{{Column: "order_date", Direction: "desc"}},
+		Limit:     1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]interface{}{{"2024-02", 130.0, 65.0}}
+	if !reflect.DeepEqual(result.Columns, []string{"order_date", "sales", "avg_sales"}) || !reflect.DeepEqual(result.Rows, want) {
+		t.Errorf("按月聚合结果不正确: %v %v", result.Columns, result.Rows)
+	}
+}
+
+func TestExecute_DetailRows(t *testing.T) {
+	result, err := Execute(salesTable(), &types.QueryObject{
+		Filters: []types.FilterCondition{{Column: "product", Operator: "like", Value: "手%"}, {Column: "sales", Operator: ">", Value: 60}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0] != "2024-01-03" {
+		t.Errorf("明细过滤结果不正确: %v", result.Rows)
+	}
+}
+
+func TestExecute_Errors(t *testing.T) {
+	cases := map[string]*types.QueryObject{
+		"维度不存在":  {Dimensions: []string{"city"}},
+		"度量列不存在": {Metrics: []string{"sum(profit)"}},
+		"聚合函数":   {Metrics: []string{"stddev(sales)"}},
+		"运算符":    {Filters: []types.FilterCondition{{Column: "sales", Operator: "BETWEEN", Value: 1}}},
+		"时间粒度":   {TimeRange: &types.TimeRange{Granularity: "fortnight"}},
+		"排序字段":   {Dimensions: []string{"region"}, OrderBy: []types.OrderCondition{{Column: "sales"}}},
+	}
+	for name, q := range cases {
+		if _, err := Execute(salesTable(), q); err == nil {
+			t.Errorf("%s: 期望返回错误", name)
+		}
+	}
+
+	noTime := &analytics.Table{Columns: []string{"region", "sales"}, Rows: [][]interface{}{{"华东", 1.0}}}
+	if _, err := Execute(noTime, &types.QueryObject{TimeRange: &types.TimeRange{StartTime: "2024-01-01"}}); err == nil {
+		t.Error("没有时间字段时应返回错误")
+	}
+}
@@ -0,0 +1,190 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"smart-analysis/internal/analytics"
+	"smart-analysis/internal/types"
+)
+
+// predicate 行过滤条件
+type predicate func(row []interface{}) bool
+
+// buildFilter 将过滤条件编译为行过滤函数
+func buildFilter(filter types.FilterCondition, table *analytics.Table) (predicate, error) {
+	idx := table.ColumnIndex(filter.Column)
+	if idx < 0 {
+		return nil, fmt.Errorf("过滤字段 %s 不存在，可用列: %v", filter.Column, table.Columns)
+	}
+	cell := func(row []interface{}) interface{} {
+		if idx < len(row) {
+			return row[idx]
+		}
+		return nil
+	}
+	// ordered 大小比较，空值不参与比较
+	ordered := func(test func(int) bool) predicate {
+		return func(row []interface{}) bool {
+			v := cell(row)
+			return nonEmpty(v) && test(compareValues(v, filter.Value))
+		}
+	}
+
+	op := strings.ToUpper(strings.Join(strings.Fields(filter.Operator), " "))
+	switch op {
+	case "=", "==", "EQ":
+		return func(row []interface{}) bool { return compareValues(cell(row), filter.Value) == 0 }, nil
+	case "!=", "<>", "NE":
+		return func(row []interface{}) bool { return compareValues(cell(row), filter.Value) != 0 }, nil
+	case ">", "GT":
+		return ordered(func(c int) bool { return c > 0 }), nil
+	case ">=", "GTE":
+		return ordered(func(c int) bool { return c >= 0 }), nil
+	case "<", "LT":
+		return ordered(func(c int) bool { return c < 0 }), nil
+	case "<=", "LTE":
+		return ordered(func(c int) bool { return c <= 0 }), nil
+	case "IN", "NOT IN":
+		values, ok := filter.Value.([]interface{})
+		if !ok {
+			values = []interface{}{filter.Value}
+		}
+		negate := op == "NOT IN"
+		return func(row []interface{}) bool {
+			v := cell(row)
+			for _, candidate := range values {
+				if compareValues(v, candidate) == 0 {
+					return !negate
+				}
+			}
+			return negate
+		}, nil
+	case "LIKE", "NOT LIKE":
+		pattern, ok := filter.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("LIKE 的值必须是字符串: %v", filter.Value)
+		}
+		re, err := likePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		negate := op == "NOT LIKE"
+		return func(row []interface{}) bool {
+			return re.MatchString(analytics.CellString(cell(row))) != negate
+		}, nil
+	default:
+		return nil, fmt.Errorf("不支持的过滤运算符: %s", filter.Operator)
+	}
+}
+
+// likePattern 将SQL LIKE模式（% 和 _ 通配符）转换为正则表达式，不区分大小写
+func likePattern(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// nonEmpty 判断单元格是否有值
+func nonEmpty(value interface{}) bool {
+	return analytics.CellString(value) != ""
+}
+
+// compareValues 比较两个值：都能解析为数值时按数值比较，否则按字符串比较
+func compareValues(a, b interface{}) int {
+	if fa, ok := analytics.CellFloat(a); ok {
+		if fb, ok := analytics.CellFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	return strings.Compare(analytics.CellString(a), analytics.CellString(b))
+}
+
+// timeRangeFilter 按时间范围过滤，结束时间只有日期时包含当天
+func timeRangeFilter(tr *types.TimeRange, idx int) (predicate, error) {
+	var start, end time.Time
+	if tr.StartTime != "" {
+		t, ok := analytics.ParseTime(tr.StartTime)
+		if !ok {
+			return nil, fmt.Errorf("无法解析开始时间: %s", tr.StartTime)
+		}
+		start = t
+	}
+	if tr.EndTime != "" {
+		t, ok := analytics.ParseTime(tr.EndTime)
+		if !ok {
+			return nil, fmt.Errorf("无法解析结束时间: %s", tr.EndTime)
+		}
+		end = t
+		if isDateOnly(tr.EndTime) {
+			end = end.Add(24*time.Hour - time.Nanosecond)
+		}
+	}
+
+	return func(row []interface{}) bool {
+		if idx >= len(row) {
+			return false
+		}
+		t, ok := analytics.ParseTime(row[idx])
+		if !ok {
+			return false
+		}
+		if !start.IsZero() && t.Before(start) {
+			return false
+		}
+		return end.IsZero() || !t.After(end)
+	}, nil
+}
+
+// isDateOnly 判断时间字符串是否只包含日期
+func isDateOnly(s string) bool {
+	_, err := time.Parse("2006-01-02", strings.TrimSpace(s))
+	if err == nil {
+		return true
+	}
+	_, err = time.Parse("2006/01/02", strings.TrimSpace(s))
+	return err == nil
+}
+
+// timeBucket 按粒度截断时间并返回分组标签
+func timeBucket(t time.Time, granularity string) (string, error) {
+	switch strings.ToLower(granularity) {
+	case "minute":
+		return t.Format("2006-01-02 15:04"), nil
+	case "hour":
+		return t.Format("2006-01-02 15:00"), nil
+	case "day", "date":
+		return t.Format("2006-01-02"), nil
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)).Format("2006-01-02"), nil
+	case "month":
+		return t.Format("2006-01"), nil
+	case "quarter":
+		return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/3+1), nil
+	case "year":
+		return t.Format("2006"), nil
+	default:
+		return "", fmt.Errorf("不支持的时间粒度: %s", granularity)
+	}
+}
@@ -0,0 +1,191 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"smart-analysis/internal/analytics"
+)
+
+// 聚合函数
+const (
+	aggSum           = "sum"
+	aggAvg           = "avg"
+	aggMin           = "min"
+	aggMax           = "max"
+	aggCount         = "count"
+	aggCountDistinct = "count_distinct"
+	aggMedian        = "median"
+)
+
+// aggAliases 聚合函数的别名
+var aggAliases = map[string]string{
+	"sum":            aggSum,
+	"avg":            aggAvg,
+	"mean":           aggAvg,
+	"average":        aggAvg,
+	"min":            aggMin,
+	"max":            aggMax,
+	"count":          aggCount,
+	"count_distinct": aggCountDistinct,
+	"distinct_count": aggCountDistinct,
+	"nunique":        aggCountDistinct,
+	"median":         aggMedian,
+}
+
+var (
+	metricCallPattern  = regexp.MustCompile(`^(\w+)\s*\(\s*(\*|[^()]*?)\s*\)$`)
+	metricAliasPattern = regexp.MustCompile(`(?i)^(.+?)\s+as\s+(.+)$`)
+)
+
+// metric 解析后的度量
+type metric struct {
+	Expr   string // 原始表达式
+	Func   string // 聚合函数
+	Column string // 聚合的列，count(*) 为空
+	Name   string // 结果列名
+}
+
+// parseMetric 解析度量表达式，支持 sum(sales)、count(*)、count_distinct(user_id) as users，
+// 直接给出数值列名时按求和处理，单独的 count 表示行数
+func parseMetric(expr string, table *analytics.Table) (*metric, error) {
+	m := &metric{Expr: strings.TrimSpace(expr)}
+	body := m.Expr
+	if match := metricAliasPattern.FindStringSubmatch(body); match != nil {
+		body, m.Name = strings.TrimSpace(match[1]), strings.TrimSpace(match[2])
+	}
+
+	if match := metricCallPattern.FindStringSubmatch(body); match != nil {
+		fn, ok := aggAliases[strings.ToLower(match[1])]
+		if !ok {
+			return nil, fmt.Errorf("不支持的聚合函数: %s", match[1])
+		}
+		m.Func = fn
+		if match[2] != "*" && match[2] != "" {
+			m.Column = match[2]
+		}
+		if m.Column == "" && fn != aggCount {
+			return nil, fmt.Errorf("聚合函数 %s 需要指定列", match[1])
+		}
+	} else if strings.EqualFold(body, aggCount) {
+		m.Func = aggCount
+	} else {
+		m.Func, m.Column = aggSum, body
+	}
+
+	if err := m.resolve(table); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// aggMetric 由管道查询中的聚合表达式构建度量
+func aggMetric(spec AggSpec, table *analytics.Table) (*metric, error) {
+	m := &metric{Expr: spec.String(), Func: spec.Func, Column: spec.Column, Name: spec.Alias}
+	if err := m.resolve(table); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// resolve 检查引用的列是否存在，未指定别名时生成结果列名
+func (m *metric) resolve(table *analytics.Table) error {
+	if m.Column != "" && table.ColumnIndex(m.Column) < 0 {
+		return fmt.Errorf("度量 %s 引用的列 %s 不存在，可用列: %v", m.Expr, m.Column, table.Columns)
+	}
+
+	if m.Name == "" {
+		switch {
+		case m.Column == "":
+			m.Name = aggCount
+		case m.Expr == m.Column:
+			m.Name = m.Column
+		default:
+			m.Name = m.Func + "_" + m.Column
+		}
+	}
+	return nil
+}
+
+// accumulator 聚合计算的中间状态
+type accumulator struct {
+	fn       string
+	count    int
+	sum      float64
+	min, max float64
+	values   []float64
+	distinct map[string]bool
+}
+
+func newAccumulator(fn string) *accumulator {
+	acc := &accumulator{fn: fn, min: math.Inf(1), max: math.Inf(-1)}
+	if fn == aggCountDistinct {
+		acc.distinct = make(map[string]bool)
+	}
+	return acc
+}
+
+// add 累加一个值，count(*) 传入的值为nil时也计数
+func (acc *accumulator) add(value interface{}, countRow bool) {
+	switch acc.fn {
+	case aggCount:
+		if countRow || analytics.CellString(value) != "" {
+			acc.count++
+		}
+		return
+	case aggCountDistinct:
+		if s := analytics.CellString(value); s != "" {
+			acc.distinct[s] = true
+		}
+		return
+	}
+
+	v, ok := analytics.CellFloat(value)
+	if !ok {
+		return
+	}
+	acc.count++
+	acc.sum += v
+	acc.min = math.Min(acc.min, v)
+	acc.max = math.Max(acc.max, v)
+	if acc.fn == aggMedian {
+		acc.values = append(acc.values, v)
+	}
+}
+
+// result 返回聚合结果，没有有效值时返回nil
+func (acc *accumulator) result() interface{} {
+	switch acc.fn {
+	case aggCount:
+		return acc.count
+	case aggCountDistinct:
+		return len(acc.distinct)
+	}
+
+	if acc.count == 0 {
+		if acc.fn == aggSum {
+			return 0.0
+		}
+		return nil
+	}
+	switch acc.fn {
+	case aggAvg:
+		return acc.sum / float64(acc.count)
+	case aggMin:
+		return acc.min
+	case aggMax:
+		return acc.max
+	case aggMedian:
+		sort.Float64s(acc.values)
+		mid := len(acc.values) / 2
+		if len(acc.values)%2 == 1 {
+			return acc.values[mid]
+		}
+		return (acc.values[mid-1] + acc.values[mid]) / 2
+	default:
+		return acc.sum
+	}
+}
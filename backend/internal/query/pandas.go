@@ -0,0 +1,182 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"smart-analysis/internal/types"
+)
+
+// pandasAggFuncs 聚合函数对应的pandas函数名
+var pandasAggFuncs = map[string]string{
+	aggSum:           "sum",
+	aggAvg:           "mean",
+	aggMin:           "min",
+	aggMax:           "max",
+	aggCount:         "count",
+	aggCountDistinct: "nunique",
+	aggMedian:        "median",
+}
+
+// Pandas 将管道查询翻译为操作 DataFrame 变量 df 的pandas代码。
+// 列名和取值都以字符串字面量写入，生成的代码不包含任何来自查询文本的表达式。
+// 代码应作为独立的源码执行（沙箱以 base64 传入），不能再嵌入其他Python字符串字面量中
+func (p *Pipeline) Pandas() string {
+	var lines []string
+	for i := 0; i < len(p.Stages); i++ {
+		stage := p.Stages[i]
+		switch stage.Op {
+		case OpFilter:
+			masks := make([]string, len(stage.Conditions))
+			for j, cond := range stage.Conditions {
+				masks[j] = "(" + pandasCondition(cond) + ")"
+			}
+			lines = append(lines, fmt.Sprintf("df = df[%s]", strings.Join(masks, " & ")))
+		case OpSelect:
+			lines = append(lines, fmt.Sprintf("df = df[%s]", pyList(stage.Columns)))
+		case OpGroupBy, OpAgg:
+			var dims []string
+			specs := stage.Aggs
+			if stage.Op == OpGroupBy {
+				dims, specs = stage.Columns, nil
+				if i+1 < len(p.Stages) && p.Stages[i+1].Op == OpAgg {
+					i++
+					specs = p.Stages[i].Aggs
+				}
+			}
+			lines = append(lines, pandasAggregate(dims, specs))
+		case OpSort:
+			columns := make([]string, len(stage.Orders))
+			ascending := make([]string, len(stage.Orders))
+			for j, order := range stage.Orders {
+				columns[j] = order.Column
+				ascending[j] = "True"
+				if strings.EqualFold(order.Direction, "DESC") {
+					ascending[j] = "False"
+				}
+			}
+			lines = append(lines, fmt.Sprintf("df = df.sort_values(%s, ascending=[%s], na_position=\"last\")",
+				pyList(columns), strings.Join(ascending, ", ")))
+		case OpHead:
+			lines = append(lines, fmt.Sprintf("df = df.head(%d)", stage.Limit))
+		case OpPivot:
+			lines = append(lines, pandasPivot(stage.Pivot))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// pandasCondition 生成单个过滤条件的布尔掩码表达式
+func pandasCondition(cond types.FilterCondition) string {
+	column := "df[" + pyLiteral(cond.Column) + "]"
+	switch cond.Operator {
+	case "IN", "NOT IN":
+		values, _ := cond.Value.([]interface{})
+		items := make([]string, len(values))
+		for i, v := range values {
+			items[i] = pyLiteral(v)
+		}
+		mask := fmt.Sprintf("%s.isin([%s])", column, strings.Join(items, ", "))
+		if cond.Operator == "NOT IN" {
+			return "~" + mask
+		}
+		return mask
+	case "LIKE", "NOT LIKE":
+		pattern, _ := cond.Value.(string)
+		re, _ := likePattern(pattern)
+		mask := fmt.Sprintf("%s.astype(str).str.match(%s, na=False)", column, pyLiteral(re.String()))
+		if cond.Operator == "NOT LIKE" {
+			return "~" + mask
+		}
+		return mask
+	default:
+		return fmt.Sprintf("%s %s %s", column, cond.Operator, pyLiteral(cond.Value))
+	}
+}
+
+// pandasAggregate 生成分组聚合代码，没有维度时输出单行汇总
+func pandasAggregate(dims []string, specs []AggSpec) string {
+	if len(specs) == 0 {
+		specs = []AggSpec{{Func: aggCount}}
+	}
+
+	if len(dims) == 0 {
+		items := make([]string, len(specs))
+		for i, spec := range specs {
+			var expr string
+			if spec.Column == "" {
+				expr = "len(df)"
+			} else {
+				expr = fmt.Sprintf("df[%s].%s()", pyLiteral(spec.Column), pandasAggFuncs[spec.Func])
+			}
+			items[i] = fmt.Sprintf("%s: %s", pyLiteral(aggName(spec)), expr)
+		}
+		return fmt.Sprintf("df = pd.DataFrame([{%s}])", strings.Join(items, ", "))
+	}
+
+	items := make([]string, len(specs))
+	for i, spec := range specs {
+		column, fn := spec.Column, pandasAggFuncs[spec.Func]
+		if column == "" {
+			column, fn = dims[0], "size"
+		}
+		items[i] = fmt.Sprintf("%s: (%s, %s)", pyLiteral(aggName(spec)), pyLiteral(column), pyLiteral(fn))
+	}
+	return fmt.Sprintf("df = df.groupby(%s, dropna=False).agg(**{%s}).reset_index()", pyList(dims), strings.Join(items, ", "))
+}
+
+// pandasPivot 生成透视表代码
+func pandasPivot(spec *PivotSpec) string {
+	if spec.Values == "" {
+		return fmt.Sprintf("df = pd.crosstab(df[%s], df[%s]).reset_index()", pyLiteral(spec.Index), pyLiteral(spec.Columns))
+	}
+	return fmt.Sprintf("df = df.pivot_table(index=%s, columns=%s, values=%s, aggfunc=%s).reset_index()",
+		pyLiteral(spec.Index), pyLiteral(spec.Columns), pyLiteral(spec.Values), pyLiteral(pandasAggFuncs[spec.Func]))
+}
+
+// aggName 聚合结果的列名，与内置引擎一致
+func aggName(spec AggSpec) string {
+	switch {
+	case spec.Alias != "":
+		return spec.Alias
+	case spec.Column == "":
+		return aggCount
+	default:
+		return spec.Func + "_" + spec.Column
+	}
+}
+
+// pyList 生成字符串列表字面量
+func pyList(values []string) string {
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = pyLiteral(v)
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+// pyLiteral 生成Python字面量。字符串使用JSON转义：引号、反斜杠和控制字符都被转义，
+// JSON的转义序列在Python中含义相同，字面量的值与原字符串一致
+func pyLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "None"
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case string:
+		data, _ := json.Marshal(v)
+		return string(data)
+	default:
+		data, _ := json.Marshal(fmt.Sprintf("%v", v))
+		return string(data)
+	}
+}
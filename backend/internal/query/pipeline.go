@@ -0,0 +1,210 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+
+	"smart-analysis/internal/analytics"
+)
+
+// Execute 在数据表上按顺序执行管道查询的各个步骤，不修改输入的数据表
+func (p *Pipeline) Execute(table *analytics.Table) (*analytics.Table, error) {
+	current := &analytics.Table{Columns: table.Columns, Rows: append([][]interface{}{}, table.Rows...)}
+	var metrics []*metric
+
+	for i := 0; i < len(p.Stages); i++ {
+		stage := p.Stages[i]
+		n := i + 1
+
+		var err error
+		switch stage.Op {
+		case OpFilter:
+			current, err = filterTable(current, stage)
+		case OpSelect:
+			current, err = selectColumns(current, stage.Columns)
+		case OpGroupBy, OpAgg:
+			var dims []string
+			specs := stage.Aggs
+			if stage.Op == OpGroupBy {
+				dims, specs = stage.Columns, nil
+				// groupby 与紧随其后的 agg 合并执行
+				if i+1 < len(p.Stages) && p.Stages[i+1].Op == OpAgg {
+					i++
+					specs = p.Stages[i].Aggs
+				}
+			}
+			current, metrics, err = groupAggregate(current, dims, specs)
+		case OpSort:
+			err = sortRows(current, stage.Orders, metrics, 0)
+		case OpHead:
+			if len(current.Rows) > stage.Limit {
+				current.Rows = current.Rows[:stage.Limit]
+			}
+		case OpPivot:
+			current, err = pivotTable(current, stage.Pivot)
+		default:
+			err = fmt.Errorf("未知操作")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("第%d步 %s: %w", n, stage.Op, err)
+		}
+	}
+	return current, nil
+}
+
+// filterTable 保留满足全部条件的行
+func filterTable(table *analytics.Table, stage Stage) (*analytics.Table, error) {
+	predicates := make([]predicate, 0, len(stage.Conditions))
+	for _, cond := range stage.Conditions {
+		p, err := buildFilter(cond, table)
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, p)
+	}
+
+	result := &analytics.Table{Columns: table.Columns, Rows: make([][]interface{}, 0, len(table.Rows))}
+	for _, row := range table.Rows {
+		matched := true
+		for _, p := range predicates {
+			if !p(row) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result.Rows = append(result.Rows, row)
+		}
+	}
+	return result, nil
+}
+
+// selectColumns 按顺序保留指定列
+func selectColumns(table *analytics.Table, columns []string) (*analytics.Table, error) {
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		if indexes[i] = table.ColumnIndex(column); indexes[i] < 0 {
+			return nil, fmt.Errorf("列 %s 不存在，可用列: %v", column, table.Columns)
+		}
+	}
+
+	result := &analytics.Table{Columns: columns, Rows: make([][]interface{}, 0, len(table.Rows))}
+	for _, row := range table.Rows {
+		projected := make([]interface{}, len(indexes))
+		for i, idx := range indexes {
+			if idx < len(row) {
+				projected[i] = row[idx]
+			}
+		}
+		result.Rows = append(result.Rows, projected)
+	}
+	return result, nil
+}
+
+// groupAggregate 按维度分组计算聚合，未指定聚合时统计行数，结果按维度升序排列
+func groupAggregate(table *analytics.Table, columns []string, specs []AggSpec) (*analytics.Table, []*metric, error) {
+	dims := make([]dimension, len(columns))
+	for i, column := range columns {
+		idx := table.ColumnIndex(column)
+		if idx < 0 {
+			return nil, nil, fmt.Errorf("分组列 %s 不存在，可用列: %v", column, table.Columns)
+		}
+		dims[i] = dimension{Name: column, Index: idx}
+	}
+
+	metrics := make([]*metric, 0, len(specs))
+	for _, spec := range specs {
+		m, err := aggMetric(spec, table)
+		if err != nil {
+			return nil, nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	if len(metrics) == 0 {
+		metrics = []*metric{{Expr: "count(*)", Func: aggCount, Name: aggCount}}
+	}
+
+	result, err := aggregate(table, table.Rows, dims, metrics)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := sortRows(result, nil, metrics, len(dims)); err != nil {
+		return nil, nil, err
+	}
+	return result, metrics, nil
+}
+
+// pivotTable 生成透视表：index 列的取值为行，columns 列的取值为列，单元格为 values 列的聚合值
+func pivotTable(table *analytics.Table, spec *PivotSpec) (*analytics.Table, error) {
+	indexIdx := table.ColumnIndex(spec.Index)
+	if indexIdx < 0 {
+		return nil, fmt.Errorf("index 列 %s 不存在，可用列: %v", spec.Index, table.Columns)
+	}
+	columnIdx := table.ColumnIndex(spec.Columns)
+	if columnIdx < 0 {
+		return nil, fmt.Errorf("columns 列 %s 不存在，可用列: %v", spec.Columns, table.Columns)
+	}
+	valueIdx := -1
+	if spec.Values != "" {
+		if valueIdx = table.ColumnIndex(spec.Values); valueIdx < 0 {
+			return nil, fmt.Errorf("values 列 %s 不存在，可用列: %v", spec.Values, table.Columns)
+		}
+	}
+
+	type pivotRow struct {
+		key   interface{}
+		cells map[string]*accumulator
+	}
+	rows := make(map[string]*pivotRow)
+	var rowKeys []string
+	headerSet := make(map[string]bool)
+	var headers []string
+
+	cell := func(row []interface{}, idx int) interface{} {
+		if idx >= 0 && idx < len(row) {
+			return row[idx]
+		}
+		return nil
+	}
+	for _, row := range table.Rows {
+		key := analytics.CellString(cell(row, indexIdx))
+		header := analytics.CellString(cell(row, columnIdx))
+		if !headerSet[header] {
+			headerSet[header] = true
+			headers = append(headers, header)
+		}
+
+		r, ok := rows[key]
+		if !ok {
+			r = &pivotRow{key: cell(row, indexIdx), cells: make(map[string]*accumulator)}
+			rows[key] = r
+			rowKeys = append(rowKeys, key)
+		}
+		acc, ok := r.cells[header]
+		if !ok {
+			acc = newAccumulator(spec.Func)
+			r.cells[header] = acc
+		}
+		acc.add(cell(row, valueIdx), valueIdx < 0)
+	}
+
+	sort.Slice(headers, func(i, j int) bool { return compareValues(headers[i], headers[j]) < 0 })
+	result := &analytics.Table{Columns: append([]string{spec.Index}, headers...)}
+	for _, key := range rowKeys {
+		r := rows[key]
+		values := []interface{}{r.key}
+		for _, header := range headers {
+			var value interface{}
+			if acc, ok := r.cells[header]; ok {
+				value = acc.result()
+			}
+			values = append(values, value)
+		}
+		result.Rows = append(result.Rows, values)
+	}
+
+	if err := sortRows(result, nil, nil, 1); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"smart-analysis/internal/config"
+)
+
+// 未初始化全局调度器时使用的默认并发上限
+const (
+	defaultWorkers        = 8
+	defaultSandboxSlots   = 4
+	defaultLLMConcurrency = 4
+)
+
+var (
+	globalScheduler     *Scheduler
+	globalSchedulerOnce sync.Once
+	globalSchedulerMu   sync.RWMutex
+)
+
+// GetGlobal 获取全局调度器，未初始化时使用默认并发上限
+func GetGlobal() *Scheduler {
+	globalSchedulerOnce.Do(func() {
+		globalSchedulerMu.Lock()
+		defer globalSchedulerMu.Unlock()
+		if globalScheduler == nil {
+			globalScheduler = New(Limits{
+				Workers:        defaultWorkers,
+				SandboxSlots:   defaultSandboxSlots,
+				LLMConcurrency: defaultLLMConcurrency,
+			})
+		}
+	})
+
+	globalSchedulerMu.RLock()
+	defer globalSchedulerMu.RUnlock()
+	return globalScheduler
+}
+
+// InitializeGlobal 根据应用配置初始化全局调度器
+func InitializeGlobal(appConfig *config.Config) error {
+	providers, err := ParseProviderLimits(appConfig.LLMProviderConcurrency)
+	if err != nil {
+		return err
+	}
+
+	s := New(Limits{
+		Workers:        appConfig.SchedulerWorkers,
+		SandboxSlots:   appConfig.SandboxSlots,
+		LLMConcurrency: appConfig.LLMConcurrency,
+		LLMProviders:   providers,
+	})
+
+	globalSchedulerMu.Lock()
+	globalScheduler = s
+	globalSchedulerMu.Unlock()
+
+	return nil
+}
+
+// ParseProviderLimits 解析 "openai=8,hunyuan=2" 格式的提供商并发配置
+func ParseProviderLimits(spec string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		provider, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid provider concurrency %q, expected provider=limit", item)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid provider concurrency %q: %w", item, err)
+		}
+		limits[strings.TrimSpace(provider)] = n
+	}
+	return limits, nil
+}
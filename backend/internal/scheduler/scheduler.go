@@ -0,0 +1,359 @@
+// Package scheduler 限制专家任务、Python沙箱和LLM调用的并发数，并在用户之间公平调度
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 受调度的资源
+const (
+	ResourceWorker  = "worker"  // 专家任务执行槽位
+	ResourceSandbox = "sandbox" // Python沙箱进程槽位
+
+	llmResourcePrefix = "llm:"
+	anonymousUser     = "anonymous"
+)
+
+// LLMResource 返回指定LLM提供商的资源名
+func LLMResource(provider string) string {
+	return llmResourcePrefix + provider
+}
+
+// Limits 各类资源的并发上限，小于等于0表示不限制
+type Limits struct {
+	Workers        int            `json:"workers"`
+	SandboxSlots   int            `json:"sandbox_slots"`
+	LLMConcurrency int            `json:"llm_concurrency"` // 未单独配置的LLM提供商使用该上限
+	LLMProviders   map[string]int `json:"llm_providers,omitempty"`
+}
+
+// limit 返回资源的并发上限
+func (l Limits) limit(resource string) int {
+	switch {
+	case resource == ResourceWorker:
+		return l.Workers
+	case resource == ResourceSandbox:
+		return l.SandboxSlots
+	case strings.HasPrefix(resource, llmResourcePrefix):
+		if n, ok := l.LLMProviders[strings.TrimPrefix(resource, llmResourcePrefix)]; ok {
+			return n
+		}
+		return l.LLMConcurrency
+	}
+	return 0
+}
+
+// Scheduler 资源调度器。每个用户的请求按资源先进先出排队：前面的请求资源不足时，
+// 后面不需要这些资源的请求可以先执行，避免持有执行槽位的任务等不到LLM或沙箱槽位而死锁。
+// 不同用户之间轮转分配空闲资源，避免单个用户的大计划占满所有槽位
+type Scheduler struct {
+	mu     sync.Mutex
+	limits Limits
+	inUse  map[string]int
+	queues map[string][]*waiter // user -> 等待中的请求
+	users  []string             // 有等待请求的用户，按轮转顺序排列
+	next   int
+	usage  map[string]*resourceUsage
+}
+
+// waiter 等待资源的请求
+type waiter struct {
+	user      string
+	resources []string
+	enqueued  time.Time
+	ready     chan struct{}
+	granted   bool
+}
+
+// resourceUsage 资源的累计使用情况
+type resourceUsage struct {
+	acquired  int64
+	cancelled int64
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+// New 创建调度器
+func New(limits Limits) *Scheduler {
+	return &Scheduler{
+		limits: limits,
+		inUse:  make(map[string]int),
+		queues: make(map[string][]*waiter),
+		usage:  make(map[string]*resourceUsage),
+	}
+}
+
+// Limits 返回调度器的并发上限
+func (s *Scheduler) Limits() Limits {
+	return s.limits
+}
+
+// Acquire 获取一组资源，资源不足时按用户公平排队等待，直到获取成功或上下文结束。
+// 调用方必须在使用完毕后调用返回的 release；nil 调度器不做任何限制
+func (s *Scheduler) Acquire(ctx context.Context, resources ...string) (func(), error) {
+	if s == nil || len(resources) == 0 {
+		return func() {}, nil
+	}
+
+	w := &waiter{
+		user:      UserFromContext(ctx),
+		resources: resources,
+		enqueued:  time.Now(),
+		ready:     make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	if len(s.queues[w.user]) == 0 {
+		s.users = append(s.users, w.user)
+	}
+	s.queues[w.user] = append(s.queues[w.user], w)
+	s.dispatchLocked()
+	s.mu.Unlock()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for _, resource := range w.resources {
+				s.inUse[resource]--
+			}
+			s.dispatchLocked()
+		})
+	}
+
+	select {
+	case <-w.ready:
+		return release, nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	if w.granted {
+		// 取消与分配同时发生，归还已分配的资源
+		s.mu.Unlock()
+		release()
+	} else {
+		s.removeLocked(w)
+		for _, resource := range w.resources {
+			s.usageLocked(resource).cancelled++
+		}
+		s.dispatchLocked()
+		s.mu.Unlock()
+	}
+	return nil, ctx.Err()
+}
+
+// dispatchLocked 从上次分配的用户之后开始轮转，每次为一个用户分配一个可以执行的请求。
+// 用户没有可以执行的请求时跳过该用户，其它用户的请求仍可继续执行
+func (s *Scheduler) dispatchLocked() {
+	for len(s.users) > 0 {
+		granted := false
+		for i := 0; i < len(s.users); i++ {
+			idx := (s.next + i) % len(s.users)
+			user := s.users[idx]
+			queue := s.queues[user]
+			pos := s.runnableLocked(queue)
+			if pos < 0 {
+				continue
+			}
+
+			s.grantLocked(queue[pos])
+			s.queues[user] = append(queue[:pos], queue[pos+1:]...)
+			if len(s.queues[user]) == 0 {
+				delete(s.queues, user)
+				s.users = append(s.users[:idx], s.users[idx+1:]...)
+				s.next = idx
+			} else {
+				s.next = idx + 1
+			}
+			if len(s.users) > 0 {
+				s.next %= len(s.users)
+			}
+			granted = true
+			break
+		}
+		if !granted {
+			return
+		}
+	}
+}
+
+// runnableLocked 返回队列中第一个可以执行的请求的位置，没有时返回-1。
+// 排在前面且资源不足的请求会阻塞后面需要相同资源的请求，保证同一资源按先进先出分配
+func (s *Scheduler) runnableLocked(queue []*waiter) int {
+	blocked := make(map[string]bool)
+	for i, w := range queue {
+		waiting := false
+		for _, resource := range w.resources {
+			if blocked[resource] {
+				waiting = true
+				break
+			}
+		}
+		if !waiting && s.availableLocked(w.resources) {
+			return i
+		}
+		for _, resource := range w.resources {
+			blocked[resource] = true
+		}
+	}
+	return -1
+}
+
+// availableLocked 判断资源是否都有空闲槽位
+func (s *Scheduler) availableLocked(resources []string) bool {
+	need := make(map[string]int, len(resources))
+	for _, resource := range resources {
+		need[resource]++
+	}
+	for resource, n := range need {
+		if limit := s.limits.limit(resource); limit > 0 && s.inUse[resource]+n > limit {
+			return false
+		}
+	}
+	return true
+}
+
+// grantLocked 为请求分配资源并唤醒等待方
+func (s *Scheduler) grantLocked(w *waiter) {
+	wait := time.Since(w.enqueued)
+	for _, resource := range w.resources {
+		s.inUse[resource]++
+		usage := s.usageLocked(resource)
+		usage.acquired++
+		usage.totalWait += wait
+		if wait > usage.maxWait {
+			usage.maxWait = wait
+		}
+	}
+	w.granted = true
+	close(w.ready)
+}
+
+// removeLocked 从等待队列中移除请求
+func (s *Scheduler) removeLocked(w *waiter) {
+	queue := s.queues[w.user]
+	for i, queued := range queue {
+		if queued == w {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) > 0 {
+		s.queues[w.user] = queue
+		return
+	}
+
+	delete(s.queues, w.user)
+	for i, user := range s.users {
+		if user == w.user {
+			s.users = append(s.users[:i], s.users[i+1:]...)
+			if i < s.next {
+				s.next--
+			}
+			break
+		}
+	}
+	if len(s.users) == 0 || s.next >= len(s.users) {
+		s.next = 0
+	}
+}
+
+// usageLocked 返回资源的使用统计，不存在时创建
+func (s *Scheduler) usageLocked(resource string) *resourceUsage {
+	usage, ok := s.usage[resource]
+	if !ok {
+		usage = &resourceUsage{}
+		s.usage[resource] = usage
+	}
+	return usage
+}
+
+// Stats 调度器运行指标
+type Stats struct {
+	Queued       int                      `json:"queued"`         // 等待中的请求总数
+	QueuedByUser map[string]int           `json:"queued_by_user"` // 各用户等待中的请求数
+	Resources    map[string]ResourceStats `json:"resources"`
+}
+
+// ResourceStats 单个资源的运行指标
+type ResourceStats struct {
+	Limit     int     `json:"limit"` // 0表示不限制
+	InUse     int     `json:"in_use"`
+	Waiting   int     `json:"waiting"` // 等待该资源的请求数（队列深度）
+	Acquired  int64   `json:"acquired"`
+	Cancelled int64   `json:"cancelled"` // 排队期间被取消的请求数
+	AvgWaitMs float64 `json:"avg_wait_ms"`
+	MaxWaitMs float64 `json:"max_wait_ms"`
+}
+
+// Stats 返回调度器当前的队列深度和资源使用情况
+func (s *Scheduler) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := Stats{
+		QueuedByUser: make(map[string]int, len(s.queues)),
+		Resources:    make(map[string]ResourceStats),
+	}
+
+	waiting := make(map[string]int)
+	for user, queue := range s.queues {
+		stats.Queued += len(queue)
+		stats.QueuedByUser[user] = len(queue)
+		for _, w := range queue {
+			for _, resource := range w.resources {
+				waiting[resource]++
+			}
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, resource := range []string{ResourceWorker, ResourceSandbox} {
+		names[resource] = true
+	}
+	for resource := range s.usage {
+		names[resource] = true
+	}
+	for resource := range waiting {
+		names[resource] = true
+	}
+
+	for resource := range names {
+		rs := ResourceStats{
+			Limit:   max(s.limits.limit(resource), 0),
+			InUse:   s.inUse[resource],
+			Waiting: waiting[resource],
+		}
+		if usage, ok := s.usage[resource]; ok {
+			rs.Acquired = usage.acquired
+			rs.Cancelled = usage.cancelled
+			rs.MaxWaitMs = float64(usage.maxWait) / float64(time.Millisecond)
+			if usage.acquired > 0 {
+				rs.AvgWaitMs = float64(usage.totalWait) / float64(usage.acquired) / float64(time.Millisecond)
+			}
+		}
+		stats.Resources[resource] = rs
+	}
+
+	return stats
+}
+
+type userKey struct{}
+
+// WithUser 在上下文中记录发起请求的用户，调度器按用户公平分配资源
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext 获取上下文中的用户，不存在时返回 anonymous
+func UserFromContext(ctx context.Context) string {
+	if user, ok := ctx.Value(userKey{}).(string); ok && user != "" {
+		return user
+	}
+	return anonymousUser
+}
@@ -0,0 +1,156 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitQueued 等待队列深度达到 n
+func waitQueued(t *testing.T, s *Scheduler, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for s.Stats().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("队列深度未达到 %d，当前 %d", n, s.Stats().Queued)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScheduler_FairAcrossUsers(t *testing.T) {
+	s := New(Limits{Workers: 1})
+	holder, err := s.Acquire(WithUser(context.Background(), "alice"), ResourceWorker)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	enqueue := func(user, name string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := s.Acquire(WithUser(context.Background(), user), ResourceWorker)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			release()
+		}()
+	}
+
+	// alice 先提交了3个任务，bob 随后提交1个，bob 不应排在 alice 全部任务之后
+	for i, name := range []string{"a1", "a2", "a3"} {
+		enqueue("alice", name)
+		waitQueued(t, s, i+1)
+	}
+	enqueue("bob", "b1")
+	waitQueued(t, s, 4)
+
+	stats := s.Stats()
+	if stats.QueuedByUser["alice"] != 3 || stats.Resources[ResourceWorker].Waiting != 4 || stats.Resources[ResourceWorker].InUse != 1 {
+		t.Errorf("队列指标不正确: %+v", stats)
+	}
+
+	holder()
+	wg.Wait()
+
+	if got := strings.Join(order, ","); got != "a1,b1,a2,a3" {
+		t.Errorf("调度顺序不公平: %s", got)
+	}
+	if stats := s.Stats(); stats.Queued != 0 || stats.Resources[ResourceWorker].InUse != 0 || stats.Resources[ResourceWorker].Acquired != 5 {
+		t.Errorf("执行结束后指标不正确: %+v", stats)
+	}
+}
+
+func TestScheduler_NestedAcquireNotBlockedByQueuedWorker(t *testing.T) {
+	s := New(Limits{Workers: 1, LLMConcurrency: 1})
+	ctx := WithUser(context.Background(), "alice")
+
+	// alice 的一个任务持有执行槽位，另一个任务在排队等待执行槽位
+	worker, err := s.Acquire(ctx, ResourceWorker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued := make(chan error, 1)
+	go func() {
+		release, err := s.Acquire(ctx, ResourceWorker)
+		if err == nil {
+			release()
+		}
+		queued <- err
+	}()
+	waitQueued(t, s, 1)
+
+	// 持有执行槽位的任务调用LLM，不应排在等待执行槽位的请求之后
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	llm, err := s.Acquire(timeout, LLMResource("openai"))
+	if err != nil {
+		t.Fatalf("LLM槽位空闲时不应等待执行槽位: %v", err)
+	}
+
+	// 同一用户对同一资源的请求仍按先进先出分配
+	second, cancelSecond := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancelSecond()
+	if _, err := s.Acquire(second, LLMResource("openai")); err == nil {
+		t.Fatal("LLM槽位已满时应等待")
+	}
+
+	llm()
+	worker()
+	if err := <-queued; err != nil {
+		t.Fatal(err)
+	}
+	if stats := s.Stats(); stats.Queued != 0 || stats.Resources[ResourceWorker].InUse != 0 || stats.Resources[LLMResource("openai")].InUse != 0 {
+		t.Errorf("执行结束后指标不正确: %+v", stats)
+	}
+}
+
+func TestScheduler_PerResourceLimits(t *testing.T) {
+	s := New(Limits{SandboxSlots: 1, LLMProviders: map[string]int{"openai": 2}})
+	ctx := context.Background()
+
+	sandbox, _ := s.Acquire(ctx, ResourceSandbox)
+	defer sandbox()
+
+	// 沙箱已满时，仅需要LLM槽位的请求不受影响
+	for i := 0; i < 2; i++ {
+		if _, err := s.Acquire(ctx, LLMResource("openai")); err != nil {
+			t.Fatalf("LLM槽位应可用: %v", err)
+		}
+	}
+
+	// 未配置上限的提供商不受限制
+	if _, err := s.Acquire(ctx, LLMResource("hunyuan")); err != nil {
+		t.Fatalf("未配置的提供商不应受限: %v", err)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.Acquire(timeout, LLMResource("openai")); err == nil {
+		t.Fatal("超过提供商并发上限时应等待直到超时")
+	}
+
+	stats := s.Stats()
+	if stats.Queued != 0 || stats.Resources[LLMResource("openai")].Cancelled != 1 || stats.Resources[LLMResource("openai")].InUse != 2 {
+		t.Errorf("取消后指标不正确: %+v", stats)
+	}
+}
+
+func TestParseProviderLimits(t *testing.T) {
+	limits, err := ParseProviderLimits("openai=8, hunyuan=2")
+	if err != nil || limits["openai"] != 8 || limits["hunyuan"] != 2 {
+		t.Errorf("解析结果不正确: %v %v", limits, err)
+	}
+	if _, err := ParseProviderLimits("openai"); err == nil {
+		t.Error("缺少并发数时应返回错误")
+	}
+}
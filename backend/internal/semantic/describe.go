@@ -0,0 +1,174 @@
+package semantic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"smart-analysis/internal/types"
+)
+
+// 术语类型
+const (
+	TermTable  = "table"
+	TermColumn = "column"
+	TermMetric = "metric"
+)
+
+// Term 问题中出现的业务术语及其对应的表、列或指标
+type Term struct {
+	Phrase     string `json:"phrase"`
+	Kind       string `json:"kind"`
+	Target     string `json:"target"`               // 表名、表.列 或指标名
+	Definition string `json:"definition,omitempty"` // 指标公式或字段说明
+}
+
+// Resolve 找出问题中出现的表、列、指标名称及其同义词，按出现位置排序。
+// 较长的术语优先，被更长术语覆盖的部分不再重复匹配，如 "退货率" 不会再匹配 "退货"
+func Resolve(m *types.SemanticModel, question string) []Term {
+	if m == nil || strings.TrimSpace(question) == "" {
+		return nil
+	}
+
+	var candidates []Term
+	add := func(term Term, words ...string) {
+		for _, word := range append([]string{term.Phrase}, words...) {
+			if word = strings.TrimSpace(word); word != "" {
+				term.Phrase = word
+				candidates = append(candidates, term)
+			}
+		}
+	}
+	for _, metric := range m.Metrics {
+		add(Term{Phrase: metric.Name, Kind: TermMetric, Target: metric.Name, Definition: metric.Formula}, metric.Synonyms...)
+	}
+	for _, table := range m.Tables {
+		add(Term{Phrase: table.Name, Kind: TermTable, Target: table.Name, Definition: table.Description}, table.Synonyms...)
+		for _, column := range table.Columns {
+			add(Term{Phrase: column.Name, Kind: TermColumn, Target: table.Name + "." + column.Name, Definition: column.Description}, column.Synonyms...)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return len(candidates[i].Phrase) > len(candidates[j].Phrase)
+	})
+
+	lower := strings.ToLower(question)
+	covered := make([]bool, len(lower))
+	type match struct {
+		pos  int
+		term Term
+	}
+	var matches []match
+	seen := make(map[string]bool)
+	for _, term := range candidates {
+		phrase := strings.ToLower(term.Phrase)
+		for offset := 0; offset < len(lower); {
+			i := strings.Index(lower[offset:], phrase)
+			if i < 0 {
+				break
+			}
+			start, end := offset+i, offset+i+len(phrase)
+			offset = end
+			if isCovered(covered, start, end) || !wordBoundary(lower, start, end) {
+				continue
+			}
+			for k := start; k < end; k++ {
+				covered[k] = true
+			}
+			key := term.Kind + "|" + term.Target + "|" + phrase
+			if !seen[key] {
+				seen[key] = true
+				matches = append(matches, match{pos: start, term: term})
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].pos < matches[j].pos })
+	terms := make([]Term, len(matches))
+	for i, m := range matches {
+		terms[i] = m.term
+	}
+	return terms
+}
+
+// Describe 生成注入提示词的语义说明，question 非空时先列出问题中识别到的术语。模型为空时返回空字符串
+func Describe(m *types.SemanticModel, question string) string {
+	if IsEmpty(m) {
+		return ""
+	}
+
+	var sb strings.Builder
+	if terms := Resolve(m, question); len(terms) > 0 {
+		sb.WriteString("问题中的业务术语:\n")
+		for _, term := range terms {
+			switch term.Kind {
+			case TermMetric:
+				sb.WriteString(fmt.Sprintf("- %s → 指标 %s = %s\n", term.Phrase, term.Target, term.Definition))
+			case TermColumn:
+				sb.WriteString(fmt.Sprintf("- %s → 列 %s\n", term.Phrase, term.Target))
+			default:
+				sb.WriteString(fmt.Sprintf("- %s → 表 %s\n", term.Phrase, term.Target))
+			}
+		}
+	}
+
+	if len(m.Tables) > 0 {
+		sb.WriteString("字段释义:\n")
+		for _, table := range m.Tables {
+			sb.WriteString("- " + table.Name)
+			if description := describeTerm(table.Description, table.Synonyms); description != "" {
+				sb.WriteString("：" + description)
+			}
+			sb.WriteString("\n")
+			for _, column := range table.Columns {
+				sb.WriteString(fmt.Sprintf("    %s: %s\n", column.Name, describeTerm(column.Description, column.Synonyms)))
+			}
+		}
+	}
+
+	if len(m.Metrics) > 0 {
+		sb.WriteString("指标口径:\n")
+		for _, metric := range m.Metrics {
+			sb.WriteString(fmt.Sprintf("- %s = %s", metric.Name, metric.Formula))
+			if description := describeTerm(metric.Description, metric.Synonyms); description != "" {
+				sb.WriteString("：" + description)
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	if len(m.Joins) > 0 {
+		sb.WriteString("关联路径:\n")
+		for _, join := range m.Joins {
+			sb.WriteString(fmt.Sprintf("- %s = %s", join.From, join.To))
+			if join.Description != "" {
+				sb.WriteString("：" + join.Description)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func isCovered(covered []bool, start, end int) bool {
+	for i := start; i < end; i++ {
+		if covered[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// wordBoundary 英文术语需要完整匹配单词，避免 id 匹配到 paid；中文没有单词边界
+func wordBoundary(text string, start, end int) bool {
+	isWord := func(c byte) bool {
+		return c == '_' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+	}
+	if isWord(text[start]) && start > 0 && isWord(text[start-1]) {
+		return false
+	}
+	if isWord(text[end-1]) && end < len(text) && isWord(text[end]) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,427 @@
+// Package semantic 维护数据集的业务语义模型：校验模型引用的表、列和指标公式，合并LLM生成的建议，
+// 为表结构补充字段释义，并生成注入提示词的语义说明，使问题中的业务术语能对应到正确的列和指标口径
+package semantic
+
+import (
+	"fmt"
+	"strings"
+
+	"smart-analysis/internal/sqlguard"
+	"smart-analysis/internal/types"
+)
+
+// catalog 按小写名称索引的表结构
+type catalog map[string]map[string]bool
+
+func newCatalog(tables []types.DataSchema) catalog {
+	c := make(catalog, len(tables))
+	for _, table := range tables {
+		columns := make(map[string]bool, len(table.Columns))
+		for _, column := range table.Columns {
+			columns[strings.ToLower(column.Name)] = true
+		}
+		c[strings.ToLower(table.TableName)] = columns
+	}
+	return c
+}
+
+// hasColumn 判断表中是否有该列，table 为空时在所有表中查找
+func (c catalog) hasColumn(table, column string) bool {
+	column = strings.ToLower(column)
+	if table != "" {
+		return c[strings.ToLower(table)][column]
+	}
+	for _, columns := range c {
+		if columns[column] {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 校验语义模型，返回发现的所有问题。tables 为数据集的表结构，dialect 用于解析指标公式
+func Validate(m *types.SemanticModel, tables []types.DataSchema, dialect string) []string {
+	if m == nil {
+		return nil
+	}
+	c := newCatalog(tables)
+
+	var issues []string
+	seenTables := make(map[string]bool)
+	for i, table := range m.Tables {
+		name := strings.ToLower(table.Name)
+		if seenTables[name] {
+			issues = append(issues, fmt.Sprintf("tables[%d]: 表 %s 重复定义", i, table.Name))
+			continue
+		}
+		seenTables[name] = true
+		if err := c.tableIssue(table.Name); err != "" {
+			issues = append(issues, fmt.Sprintf("tables[%d]: %s", i, err))
+			continue
+		}
+
+		seenColumns := make(map[string]bool)
+		for j, column := range table.Columns {
+			if seenColumns[strings.ToLower(column.Name)] {
+				issues = append(issues, fmt.Sprintf("tables[%d].columns[%d]: 列 %s 重复定义", i, j, column.Name))
+				continue
+			}
+			seenColumns[strings.ToLower(column.Name)] = true
+			if !c.hasColumn(table.Name, column.Name) {
+				issues = append(issues, fmt.Sprintf("tables[%d].columns[%d]: 表 %s 中没有列 %s", i, j, table.Name, column.Name))
+			}
+		}
+	}
+
+	seenMetrics := make(map[string]bool)
+	for i, metric := range m.Metrics {
+		name := strings.ToLower(strings.TrimSpace(metric.Name))
+		if name == "" {
+			issues = append(issues, fmt.Sprintf("metrics[%d]: 指标名称不能为空", i))
+			continue
+		}
+		if seenMetrics[name] {
+			issues = append(issues, fmt.Sprintf("metrics[%d]: 指标 %s 重复定义", i, metric.Name))
+			continue
+		}
+		seenMetrics[name] = true
+		if err := c.formulaIssue(metric.Formula, dialect); err != "" {
+			issues = append(issues, fmt.Sprintf("metrics[%d]: 指标 %s 的公式 %s", i, metric.Name, err))
+		}
+	}
+
+	for i, join := range m.Joins {
+		for _, end := range []string{join.From, join.To} {
+			if err := c.columnPathIssue(end); err != "" {
+				issues = append(issues, fmt.Sprintf("joins[%d]: %s", i, err))
+			}
+		}
+	}
+	return issues
+}
+
+// Clean 去掉语义模型中无效的条目并整理同义词，用于处理LLM生成的建议
+func Clean(m *types.SemanticModel, tables []types.DataSchema, dialect string) *types.SemanticModel {
+	cleaned := &types.SemanticModel{}
+	if m == nil {
+		return cleaned
+	}
+	c := newCatalog(tables)
+
+	seenTables := make(map[string]bool)
+	for _, table := range m.Tables {
+		name := strings.ToLower(table.Name)
+		if seenTables[name] || c.tableIssue(table.Name) != "" {
+			continue
+		}
+		seenTables[name] = true
+
+		kept := types.SemanticTable{
+			Name:        table.Name,
+			Description: strings.TrimSpace(table.Description),
+			Synonyms:    cleanSynonyms(table.Synonyms, table.Name),
+		}
+		seenColumns := make(map[string]bool)
+		for _, column := range table.Columns {
+			if seenColumns[strings.ToLower(column.Name)] || !c.hasColumn(table.Name, column.Name) {
+				continue
+			}
+			seenColumns[strings.ToLower(column.Name)] = true
+			kept.Columns = append(kept.Columns, types.SemanticColumn{
+				Name:        column.Name,
+				Description: strings.TrimSpace(column.Description),
+				Synonyms:    cleanSynonyms(column.Synonyms, column.Name),
+			})
+		}
+		cleaned.Tables = append(cleaned.Tables, kept)
+	}
+
+	seenMetrics := make(map[string]bool)
+	for _, metric := range m.Metrics {
+		name := strings.TrimSpace(metric.Name)
+		if name == "" || seenMetrics[strings.ToLower(name)] || c.formulaIssue(metric.Formula, dialect) != "" {
+			continue
+		}
+		seenMetrics[strings.ToLower(name)] = true
+		cleaned.Metrics = append(cleaned.Metrics, types.SemanticMetric{
+			Name:        name,
+			Formula:     strings.TrimSpace(metric.Formula),
+			Description: strings.TrimSpace(metric.Description),
+			Synonyms:    cleanSynonyms(metric.Synonyms, name),
+		})
+	}
+
+	seenJoins := make(map[string]bool)
+	for _, join := range m.Joins {
+		key := joinKey(join)
+		if seenJoins[key] || c.columnPathIssue(join.From) != "" || c.columnPathIssue(join.To) != "" {
+			continue
+		}
+		seenJoins[key] = true
+		cleaned.Joins = append(cleaned.Joins, join)
+	}
+	return cleaned
+}
+
+// Merge 以 base 为准合并 suggestion：已有条目保持不变，只补充缺失的表、列、指标、关联、同义词和空白的说明
+func Merge(base, suggestion *types.SemanticModel) *types.SemanticModel {
+	merged := Copy(base)
+	if suggestion == nil {
+		return merged
+	}
+
+	for _, table := range suggestion.Tables {
+		i := findTable(merged, table.Name)
+		if i < 0 {
+			merged.Tables = append(merged.Tables, table)
+			continue
+		}
+		target := &merged.Tables[i]
+		if target.Description == "" {
+			target.Description = table.Description
+		}
+		target.Synonyms = appendSynonyms(target.Synonyms, table.Synonyms)
+		for _, column := range table.Columns {
+			j := findColumn(target, column.Name)
+			if j < 0 {
+				target.Columns = append(target.Columns, column)
+				continue
+			}
+			if target.Columns[j].Description == "" {
+				target.Columns[j].Description = column.Description
+			}
+			target.Columns[j].Synonyms = appendSynonyms(target.Columns[j].Synonyms, column.Synonyms)
+		}
+	}
+
+	for _, metric := range suggestion.Metrics {
+		if findMetric(merged, metric.Name) < 0 {
+			merged.Metrics = append(merged.Metrics, metric)
+		}
+	}
+
+	existing := make(map[string]bool, len(merged.Joins))
+	for _, join := range merged.Joins {
+		existing[joinKey(join)] = true
+	}
+	for _, join := range suggestion.Joins {
+		if !existing[joinKey(join)] {
+			existing[joinKey(join)] = true
+			merged.Joins = append(merged.Joins, join)
+		}
+	}
+	return merged
+}
+
+// Copy 深拷贝语义模型，m 为空时返回空模型
+func Copy(m *types.SemanticModel) *types.SemanticModel {
+	copied := &types.SemanticModel{}
+	if m == nil {
+		return copied
+	}
+	for _, table := range m.Tables {
+		table.Synonyms = append([]string(nil), table.Synonyms...)
+		columns := make([]types.SemanticColumn, len(table.Columns))
+		for i, column := range table.Columns {
+			column.Synonyms = append([]string(nil), column.Synonyms...)
+			columns[i] = column
+		}
+		table.Columns = columns
+		copied.Tables = append(copied.Tables, table)
+	}
+	for _, metric := range m.Metrics {
+		metric.Synonyms = append([]string(nil), metric.Synonyms...)
+		copied.Metrics = append(copied.Metrics, metric)
+	}
+	copied.Joins = append(copied.Joins, m.Joins...)
+	return copied
+}
+
+// IsEmpty 判断语义模型是否没有任何条目
+func IsEmpty(m *types.SemanticModel) bool {
+	return m == nil || len(m.Tables) == 0 && len(m.Metrics) == 0 && len(m.Joins) == 0
+}
+
+// Annotate 返回补充了业务说明的表结构副本：没有注释的表和列使用语义模型中的说明，同义词附在说明后
+func Annotate(tables []types.DataSchema, m *types.SemanticModel) []types.DataSchema {
+	annotated := make([]types.DataSchema, len(tables))
+	for i, table := range tables {
+		annotated[i] = table
+		if m == nil {
+			continue
+		}
+		k := findTable(m, table.TableName)
+		if k < 0 {
+			continue
+		}
+		semanticTable := &m.Tables[k]
+
+		if description := describeTerm(semanticTable.Description, semanticTable.Synonyms); description != "" {
+			metadata := make(map[string]interface{}, len(table.Metadata)+1)
+			for key, value := range table.Metadata {
+				metadata[key] = value
+			}
+			if comment, _ := metadata["comment"].(string); comment == "" {
+				metadata["comment"] = description
+			}
+			annotated[i].Metadata = metadata
+		}
+
+		columns := make([]types.ColumnInfo, len(table.Columns))
+		for j, column := range table.Columns {
+			columns[j] = column
+			if n := findColumn(semanticTable, column.Name); n >= 0 && column.Description == "" {
+				columns[j].Description = describeTerm(semanticTable.Columns[n].Description, semanticTable.Columns[n].Synonyms)
+			}
+		}
+		annotated[i].Columns = columns
+	}
+	return annotated
+}
+
+// tableIssue 检查表是否存在，表结构为空时不检查
+func (c catalog) tableIssue(name string) string {
+	if strings.TrimSpace(name) == "" {
+		return "表名不能为空"
+	}
+	if len(c) > 0 && c[strings.ToLower(name)] == nil {
+		return fmt.Sprintf("表 %s 不存在", name)
+	}
+	return ""
+}
+
+// columnPathIssue 检查 "表.列" 是否存在
+func (c catalog) columnPathIssue(path string) string {
+	table, column, ok := strings.Cut(strings.TrimSpace(path), ".")
+	if !ok || table == "" || column == "" {
+		return fmt.Sprintf("%q 应为 表.列 的形式", path)
+	}
+	if err := c.tableIssue(table); err != "" {
+		return err
+	}
+	if len(c) > 0 && !c.hasColumn(table, column) {
+		return fmt.Sprintf("表 %s 中没有列 %s", table, column)
+	}
+	return ""
+}
+
+// formulaIssue 检查指标公式是否为单个只读SQL表达式，引用的表和列是否存在
+func (c catalog) formulaIssue(formula, dialect string) string {
+	if strings.TrimSpace(formula) == "" {
+		return "不能为空"
+	}
+
+	var allowed []string
+	for table := range c {
+		allowed = append(allowed, table)
+	}
+	checked, err := sqlguard.Check("SELECT "+formula, sqlguard.Options{Dialect: dialect, AllowedTables: allowed})
+	if err != nil {
+		return fmt.Sprintf("无效: %v", err)
+	}
+	sel, ok := checked.Query.Body.(*sqlguard.Select)
+	if !ok || checked.Query.With != nil || checked.Query.Limit != nil || len(checked.Query.OrderBy) > 0 ||
+		len(sel.Columns) != 1 || sel.Columns[0].Star || len(sel.From) > 0 || sel.Where != nil || len(sel.GroupBy) > 0 {
+		return "必须是单个SQL表达式"
+	}
+	if len(c) == 0 {
+		return ""
+	}
+
+	// 子查询中的列可能引用子查询的别名，只检查最外层表达式
+	var issue string
+	sqlguard.Walk(sel.Columns[0].Expr, func(n sqlguard.Node) bool {
+		switch n := n.(type) {
+		case *sqlguard.Query:
+			return false
+		case *sqlguard.ColumnRef:
+			switch len(n.Parts) {
+			case 1:
+				if !c.hasColumn("", n.Parts[0]) {
+					issue = fmt.Sprintf("引用的列 %s 不存在", n.Parts[0])
+				}
+			case 2:
+				if c.columnPathIssue(n.Parts[0]+"."+n.Parts[1]) != "" {
+					issue = fmt.Sprintf("引用的列 %s.%s 不存在", n.Parts[0], n.Parts[1])
+				}
+			}
+		}
+		return issue == ""
+	})
+	return issue
+}
+
+func findTable(m *types.SemanticModel, name string) int {
+	for i, table := range m.Tables {
+		if strings.EqualFold(table.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func findColumn(table *types.SemanticTable, name string) int {
+	for i, column := range table.Columns {
+		if strings.EqualFold(column.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func findMetric(m *types.SemanticModel, name string) int {
+	for i, metric := range m.Metrics {
+		if strings.EqualFold(metric.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// joinKey 关联路径的无方向标识
+func joinKey(join types.SemanticJoin) string {
+	from, to := strings.ToLower(strings.TrimSpace(join.From)), strings.ToLower(strings.TrimSpace(join.To))
+	if from > to {
+		from, to = to, from
+	}
+	return from + "=" + to
+}
+
+// cleanSynonyms 去掉空白、重复以及与名称相同的同义词
+func cleanSynonyms(synonyms []string, name string) []string {
+	return appendSynonyms(nil, synonyms, name)
+}
+
+// appendSynonyms 追加不重复的同义词，exclude 中的词不会被加入
+func appendSynonyms(base, extra []string, exclude ...string) []string {
+	seen := make(map[string]bool, len(base)+len(exclude))
+	for _, word := range exclude {
+		seen[strings.ToLower(strings.TrimSpace(word))] = true
+	}
+	result := base
+	for _, word := range base {
+		seen[strings.ToLower(word)] = true
+	}
+	for _, word := range extra {
+		word = strings.TrimSpace(word)
+		if word == "" || seen[strings.ToLower(word)] {
+			continue
+		}
+		seen[strings.ToLower(word)] = true
+		result = append(result, word)
+	}
+	return result
+}
+
+// describeTerm 将说明和同义词组合为一句描述，与说明相同的同义词不再重复
+func describeTerm(description string, synonyms []string) string {
+	synonyms = appendSynonyms(nil, synonyms, description)
+	if len(synonyms) == 0 {
+		return description
+	}
+	aliases := "又称 " + strings.Join(synonyms, "、")
+	if description == "" {
+		return aliases
+	}
+	return description + "（" + aliases + "）"
+}
@@ -0,0 +1,136 @@
+package semantic
+
+import (
+	"strings"
+	"testing"
+
+	"smart-analysis/internal/types"
+)
+
+var testTables = []types.DataSchema{
+	{TableName: "orders", Columns: []types.ColumnInfo{{Name: "id"}, {Name: "user_id"}, {Name: "sales_amount"}, {Name: "returned"}}},
+	{TableName: "users", Columns: []types.ColumnInfo{{Name: "id"}, {Name: "city", Description: "所在城市"}}},
+}
+
+func TestValidate(t *testing.T) {
+	m := &types.SemanticModel{
+		Tables: []types.SemanticTable{
+			{Name: "orders", Columns: []types.SemanticColumn{{Name: "sales_amount"}, {Name: "profit"}}},
+			{Name: "refunds"},
+		},
+		Metrics: []types.SemanticMetric{
+			{Name: "退货率", Formula: "SUM(orders.returned) * 1.0 / COUNT(orders.id)"},
+			{Name: "客单价", Formula: "SUM(sales_amount) / COUNT(DISTINCT user_id)"},
+			{Name: "注入", Formula: "1; DROP TABLE orders"},
+			{Name: "子查询", Formula: "(SELECT COUNT(*) FROM secrets)"},
+			{Name: "整条查询", Formula: "COUNT(*) FROM orders"},
+			{Name: "拼写错误", Formula: "SUM(orders.amount)"},
+		},
+		Joins: []types.SemanticJoin{{From: "orders.user_id", To: "users.id"}, {From: "orders", To: "users.id"}},
+	}
+
+	issues := strings.Join(Validate(m, testTables, "sqlite"), "\n")
+	for _, want := range []string{"没有列 profit", "表 refunds 不存在", "注入", "子查询", "整条查询", "orders.amount 不存在", `"orders" 应为 表.列`} {
+		if !strings.Contains(issues, want) {
+			t.Errorf("缺少问题 %q:\n%s", want, issues)
+		}
+	}
+	for _, unexpected := range []string{"退货率", "客单价", "joins[0]"} {
+		if strings.Contains(issues, unexpected) {
+			t.Errorf("不应报告 %q:\n%s", unexpected, issues)
+		}
+	}
+
+	cleaned := Clean(m, testTables, "sqlite")
+	if len(cleaned.Tables) != 1 || len(cleaned.Tables[0].Columns) != 1 || len(cleaned.Metrics) != 2 || len(cleaned.Joins) != 1 {
+		t.Errorf("清理结果不正确: %+v", cleaned)
+	}
+	if issues := Validate(cleaned, testTables, "sqlite"); len(issues) != 0 {
+		t.Errorf("清理后不应有问题: %v", issues)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := &types.SemanticModel{
+		Tables:  []types.SemanticTable{{Name: "orders", Columns: []types.SemanticColumn{{Name: "sales_amount", Description: "销售额", Synonyms: []string{"成交额"}}}}},
+		Metrics: []types.SemanticMetric{{Name: "退货率", Formula: "SUM(returned) * 1.0 / COUNT(id)"}},
+		Joins:   []types.SemanticJoin{{From: "orders.user_id", To: "users.id"}},
+	}
+	suggestion := &types.SemanticModel{
+		Tables: []types.SemanticTable{
+			{Name: "ORDERS", Description: "订单表", Columns: []types.SemanticColumn{{Name: "sales_amount", Description: "GMV", Synonyms: []string{"成交额", "GMV"}}}},
+			{Name: "users", Description: "用户表"},
+		},
+		Metrics: []types.SemanticMetric{{Name: "退货率", Formula: "0"}},
+		Joins:   []types.SemanticJoin{{From: "users.id", To: "orders.user_id"}},
+	}
+
+	merged := Merge(base, suggestion)
+	column := merged.Tables[0].Columns[0]
+	if merged.Tables[0].Description != "订单表" || column.Description != "销售额" || strings.Join(column.Synonyms, ",") != "成交额,GMV" {
+		t.Errorf("表合并不正确: %+v", merged.Tables[0])
+	}
+	if len(merged.Tables) != 2 || len(merged.Metrics) != 1 || merged.Metrics[0].Formula != base.Metrics[0].Formula || len(merged.Joins) != 1 {
+		t.Errorf("合并结果不正确: %+v", merged)
+	}
+	if len(base.Tables[0].Columns[0].Synonyms) != 1 || base.Tables[0].Description != "" {
+		t.Error("合并不应修改原模型")
+	}
+}
+
+func TestResolveAndDescribe(t *testing.T) {
+	m := &types.SemanticModel{
+		Tables: []types.SemanticTable{{
+			Name:     "orders",
+			Synonyms: []string{"订单"},
+			Columns: []types.SemanticColumn{
+				{Name: "sales_amount", Description: "销售额", Synonyms: []string{"销售额", "GMV"}},
+				{Name: "returned", Synonyms: []string{"退货"}},
+				{Name: "id"},
+			},
+		}},
+		Metrics: []types.SemanticMetric{{Name: "退货率", Formula: "SUM(returned) * 1.0 / COUNT(id)", Synonyms: []string{"return rate"}}},
+		Joins:   []types.SemanticJoin{{From: "orders.user_id", To: "users.id", Description: "下单用户"}},
+	}
+
+	terms := Resolve(m, "上个月各城市的GMV和退货率，按订单 paid 统计")
+	var got []string
+	for _, term := range terms {
+		got = append(got, term.Phrase+"→"+term.Target)
+	}
+	// 退货率 优先于 退货，paid 中的 id 不算
+	if strings.Join(got, " ") != "GMV→orders.sales_amount 退货率→退货率 订单→orders" {
+		t.Errorf("术语识别不正确: %v", got)
+	}
+
+	description := Describe(m, "Return Rate by city")
+	for _, want := range []string{
+		"- return rate → 指标 退货率 = SUM(returned) * 1.0 / COUNT(id)",
+		"sales_amount: 销售额（又称 GMV）",
+		"- orders.user_id = users.id：下单用户",
+	} {
+		if !strings.Contains(description, want) {
+			t.Errorf("语义说明缺少 %q:\n%s", want, description)
+		}
+	}
+	if Describe(nil, "x") != "" || Describe(&types.SemanticModel{}, "x") != "" {
+		t.Error("空模型的说明应为空")
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	m := &types.SemanticModel{Tables: []types.SemanticTable{{
+		Name:        "users",
+		Description: "用户表",
+		Columns:     []types.SemanticColumn{{Name: "city", Description: "城市"}, {Name: "id", Synonyms: []string{"用户ID"}}},
+	}}}
+
+	annotated := Annotate(testTables, m)
+	users := annotated[1]
+	if users.Metadata["comment"] != "用户表" || users.Columns[0].Description != "又称 用户ID" || users.Columns[1].Description != "所在城市" {
+		t.Errorf("释义补充不正确: %+v", users)
+	}
+	if testTables[1].Metadata != nil || testTables[1].Columns[0].Description != "" {
+		t.Error("不应修改原表结构")
+	}
+}
@@ -0,0 +1,595 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"smart-analysis/internal/access"
+	"smart-analysis/internal/analytics"
+	"smart-analysis/internal/audit"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/scheduler"
+	"smart-analysis/internal/sqlengine"
+	"smart-analysis/internal/types"
+)
+
+// SetTeamResolver 设置用户所属团队的查询函数，用于匹配授权给团队的访问策略
+func (s *FileService) SetTeamResolver(teams func(userID int) []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.teams = teams
+	s.revision++
+}
+
+// SetWorkspaces 设置团队空间服务：共享到团队空间的文件对空间成员完全可见，
+// 访问策略也可以用 workspace:<ID> 授权给整个团队空间
+func (s *FileService) SetWorkspaces(workspaces *WorkspaceService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workspaces = workspaces
+	s.teams = workspaces.Teams
+	s.revision++
+}
+
+// ShareFile 将文件共享到团队空间，workspaceID 为0时取消共享。
+// 只有文件所有者可以共享，且需要是空间的 editor 或 owner
+func (s *FileService) ShareFile(userID, fileID, workspaceID int) (*model.File, error) {
+	file, err := s.ownedFile(userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if workspaceID != 0 {
+		if s.workspaces == nil {
+			return nil, errors.New("workspaces are not configured")
+		}
+		if err := s.workspaces.Authorize(userID, workspaceID, model.RoleEditor); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, exists := s.files[file.ID]
+	if !exists {
+		return nil, errors.New("file not found")
+	}
+	stored.WorkspaceID = workspaceID
+	s.revision++
+	copied := *stored
+	return &copied, nil
+}
+
+// WorkspaceFiles 返回共享到团队空间的文件，需要是空间成员
+func (s *FileService) WorkspaceFiles(userID, workspaceID int) ([]*model.File, error) {
+	if s.workspaces == nil {
+		return nil, errors.New("workspaces are not configured")
+	}
+	if err := s.workspaces.Authorize(userID, workspaceID, model.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	files := make([]*model.File, 0)
+	for _, file := range s.files {
+		if file.WorkspaceID == workspaceID {
+			copied := *file
+			files = append(files, &copied)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+	return files, nil
+}
+
+// SetViewPath 设置数据视图的保存目录，目录不应位于上传目录中
+func (s *FileService) SetViewPath(path string) {
+	s.viewPath = path
+}
+
+// ViewPath 返回数据视图的保存目录，每个用户的视图在其中单独的子目录中
+func (s *FileService) ViewPath() string {
+	return s.viewPath
+}
+
+// UserViewDir 返回用户的数据视图所在的目录，Python沙箱只对该用户开放这个目录
+func (s *FileService) UserViewDir(userID int) string {
+	return filepath.Join(s.viewPath, strconv.Itoa(userID))
+}
+
+// ListAccessPolicies 返回文件的访问策略，只有文件所有者可以查看
+func (s *FileService) ListAccessPolicies(userID, fileID int) ([]*model.AccessPolicy, error) {
+	if _, err := s.ownedFile(userID, fileID); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	policies := make([]*model.AccessPolicy, 0)
+	for _, policy := range s.policies {
+		if policy.FileID == fileID {
+			copied := *policy
+			policies = append(policies, &copied)
+		}
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].ID < policies[j].ID })
+	return policies, nil
+}
+
+// CreateAccessPolicy 为文件添加访问策略，授权其他用户或团队查看部分行和列
+func (s *FileService) CreateAccessPolicy(userID, fileID int, req *model.AccessPolicyRequest) (*model.AccessPolicy, error) {
+	file, err := s.ownedFile(userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validatePolicy(file, req); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	policy := &model.AccessPolicy{
+		ID:        s.nextPolicyID,
+		FileID:    fileID,
+		UserID:    req.UserID,
+		Team:      strings.TrimSpace(req.Team),
+		RowFilter: strings.TrimSpace(req.RowFilter),
+		Columns:   append([]string{}, req.Columns...),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.policies[policy.ID] = policy
+	s.nextPolicyID++
+	s.revision++
+
+	copied := *policy
+	return &copied, nil
+}
+
+// UpdateAccessPolicy 修改文件的访问策略
+func (s *FileService) UpdateAccessPolicy(userID, fileID, policyID int, req *model.AccessPolicyRequest) (*model.AccessPolicy, error) {
+	file, err := s.ownedFile(userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validatePolicy(file, req); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	policy, exists := s.policies[policyID]
+	if !exists || policy.FileID != fileID {
+		return nil, errors.New("access policy not found")
+	}
+	policy.UserID = req.UserID
+	policy.Team = strings.TrimSpace(req.Team)
+	policy.RowFilter = strings.TrimSpace(req.RowFilter)
+	policy.Columns = append([]string{}, req.Columns...)
+	policy.UpdatedAt = time.Now()
+	s.revision++
+
+	copied := *policy
+	return &copied, nil
+}
+
+// DeleteAccessPolicy 删除文件的访问策略，被授权的用户随即失去访问权限
+func (s *FileService) DeleteAccessPolicy(userID, fileID, policyID int) error {
+	if _, err := s.ownedFile(userID, fileID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	policy, exists := s.policies[policyID]
+	if !exists || policy.FileID != fileID {
+		return errors.New("access policy not found")
+	}
+	delete(s.policies, policyID)
+	s.revision++
+	return nil
+}
+
+// SharedFiles 返回其他用户通过访问策略或团队空间共享给该用户的文件，按ID排序
+func (s *FileService) SharedFiles(userID int) []*model.File {
+	s.mu.Lock()
+	shared := make(map[int]bool)
+	teams := s.userTeams(userID)
+	for _, policy := range s.policies {
+		if policyApplies(policy, userID, teams) {
+			shared[policy.FileID] = true
+		}
+	}
+	for _, file := range s.files {
+		if file.WorkspaceID != 0 && teams[WorkspaceTeam(file.WorkspaceID)] {
+			shared[file.ID] = true
+		}
+	}
+
+	var files []*model.File
+	for id := range shared {
+		if file, exists := s.files[id]; exists && file.UserID != userID {
+			copied := *file
+			files = append(files, &copied)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+	return files
+}
+
+// accessible 检查用户对文件的访问权限，返回文件和需要应用的访问限制。
+// 文件所有者和文件所在团队空间的成员不受限制（返回 nil），其他用户需要至少一条匹配的访问策略。
+// 返回的是文件的副本
+func (s *FileService) accessible(userID, fileID int) (*model.File, *access.Restriction, error) {
+	file, err := s.GetFileByID(fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if file.UserID == userID {
+		return file, nil, nil
+	}
+	if file.WorkspaceID != 0 && s.workspaces != nil && s.workspaces.Role(userID, file.WorkspaceID) != "" {
+		return file, nil, nil
+	}
+
+	s.mu.Lock()
+	teams := s.userTeams(userID)
+	var rules []access.Rule
+	for _, policy := range s.policies {
+		if policy.FileID == fileID && policyApplies(policy, userID, teams) {
+			rules = append(rules, access.Rule{RowFilter: policy.RowFilter, Columns: policy.Columns})
+		}
+	}
+	s.mu.Unlock()
+
+	if len(rules) == 0 {
+		return nil, nil, errors.New("permission denied")
+	}
+	restriction, err := access.Combine(rules)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, restriction, nil
+}
+
+// accessRevision 返回访问策略、敏感列策略和团队空间成员的版本
+func (s *FileService) accessRevision() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.workspaces != nil {
+		return s.revision + s.workspaces.Revision()
+	}
+	return s.revision
+}
+
+// userTeams 返回用户所属的团队，调用方需持有 s.mu
+func (s *FileService) userTeams(userID int) map[string]bool {
+	teams := make(map[string]bool)
+	if s.teams != nil {
+		for _, team := range s.teams(userID) {
+			teams[team] = true
+		}
+	}
+	return teams
+}
+
+// deletePolicies 删除文件的全部访问策略和已生成的数据视图
+func (s *FileService) deletePolicies(fileID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, policy := range s.policies {
+		if policy.FileID == fileID {
+			delete(s.policies, id)
+		}
+	}
+	for key, view := range s.views {
+		if key.fileID == fileID {
+			os.RemoveAll(filepath.Dir(view.path))
+			delete(s.views, key)
+		}
+	}
+	s.revision++
+}
+
+// validatePolicy 校验访问策略：必须指定被授权的用户或团队，行过滤条件和列必须引用文件中存在的列
+func (s *FileService) validatePolicy(file *model.File, req *model.AccessPolicyRequest) error {
+	team := strings.TrimSpace(req.Team)
+	if (req.UserID > 0) == (team != "") {
+		return errors.New("exactly one of user_id and team is required")
+	}
+	if req.UserID == file.UserID {
+		return errors.New("the file owner always has full access")
+	}
+
+	var filterColumns []string
+	if strings.TrimSpace(req.RowFilter) != "" {
+		filter, err := access.ParseFilter(req.RowFilter)
+		if err != nil {
+			return err
+		}
+		filterColumns = filter.Columns()
+	}
+
+	// 文件解析完成后校验引用的列，避免策略因列名拼写错误而无法生效
+	if file.Status != "ready" {
+		return nil
+	}
+	table, err := analytics.LoadTable(file.Path)
+	if err != nil {
+		return nil
+	}
+	headers := make(map[string]bool, len(table.Columns))
+	for _, column := range table.Columns {
+		headers[strings.ToLower(strings.TrimSpace(column))] = true
+	}
+	for _, column := range append(filterColumns, req.Columns...) {
+		if !headers[strings.ToLower(strings.TrimSpace(column))] {
+			return fmt.Errorf("column %s not found in file", column)
+		}
+	}
+	return nil
+}
+
+// policyApplies 判断策略是否授权给该用户或其所在的团队
+func policyApplies(p *model.AccessPolicy, userID int, teams map[string]bool) bool {
+	return p.UserID == userID || p.Team != "" && teams[p.Team]
+}
+
+// viewKey 数据视图按用户和文件缓存
+type viewKey struct {
+	userID int
+	fileID int
+}
+
+// dataView 已写出的数据视图，文件内容或策略版本变化后重新生成
+type dataView struct {
+	path     string
+	updated  time.Time
+	revision int
+}
+
+// ForUser 返回限定在单个用户范围内的数据集解析器，供智能体和工具读取上传文件
+func (s *FileService) ForUser(userID int) *UserDatasets {
+	return &UserDatasets{service: s, userID: userID}
+}
+
+// Datasets 返回按上下文中的用户解析文件引用的数据集解析器，供所有用户共用的多智能体系统使用。
+// 上下文中没有用户时拒绝访问
+func (s *FileService) Datasets() types.DatasetResolver {
+	return contextDatasets{service: s}
+}
+
+// contextDatasets 按上下文中的用户访问数据集
+type contextDatasets struct {
+	service *FileService
+}
+
+// ResolveDataset 使用上下文中的用户的 UserDatasets 解析文件引用
+func (d contextDatasets) ResolveDataset(ctx context.Context, ref string) (string, error) {
+	datasets, err := d.forContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return datasets.ResolveDataset(ctx, ref)
+}
+
+// ResolveUpload 使用上下文中的用户的 UserDatasets 解析上传文件的引用
+func (d contextDatasets) ResolveUpload(ctx context.Context, ref string) (string, error) {
+	datasets, err := d.forContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return datasets.ResolveUpload(ctx, ref)
+}
+
+// forContext 返回上下文中的用户的数据集
+func (d contextDatasets) forContext(ctx context.Context) (*UserDatasets, error) {
+	userID, err := strconv.Atoi(scheduler.UserFromContext(ctx))
+	if err != nil {
+		return nil, errors.New("permission denied")
+	}
+	return d.service.ForUser(userID), nil
+}
+
+// UserDatasets 限定在单个用户范围内的数据集访问
+type UserDatasets struct {
+	service *FileService
+	userID  int
+}
+
+// ResolveDataset 将文件引用（file:ID、原始文件名、存储文件名或上传路径）解析为用户可见的数据视图。
+// 用户自己的数据视图和当前计划执行的产物目录中的文件原样返回；
+// 无法识别或无权访问的文件、其他用户的数据视图和其他路径返回错误
+func (u *UserDatasets) ResolveDataset(ctx context.Context, ref string) (string, error) {
+	return u.resolve(ctx, ref, false)
+}
+
+// ResolveUpload 与 ResolveDataset 相同，但计划执行的产物也返回错误，
+// 供只允许读取用户数据的工具（如SQL查询工具）使用
+func (u *UserDatasets) ResolveUpload(ctx context.Context, ref string) (string, error) {
+	return u.resolve(ctx, ref, true)
+}
+
+// resolve 解析文件引用，uploadsOnly 为 true 时拒绝上传文件和数据视图以外的路径
+func (u *UserDatasets) resolve(ctx context.Context, ref string, uploadsOnly bool) (string, error) {
+	if inDir(ref, u.service.viewPath) {
+		if u.service.ownsView(u.userID, ref) {
+			return ref, nil
+		}
+		return "", errors.New("permission denied")
+	}
+
+	file, err := u.service.findFile(u.userID, ref)
+	if err == nil && file == nil {
+		if dir := types.ArtifactDirFromContext(ctx); !uploadsOnly && dir != "" && inDir(ref, dir) {
+			return ref, nil
+		}
+		return "", fmt.Errorf("%s is not an uploaded file", ref)
+	}
+
+	// 智能体读取上传文件（包括被拒绝的读取）记录在审计日志中
+	event := audit.Event{UserID: u.userID, Action: audit.ActionFileRead, Detail: map[string]interface{}{"purpose": "agent", "ref": ref}}
+	path := ""
+	if err == nil {
+		event.Resource = fmt.Sprintf("file:%d", file.ID)
+		path, err = u.service.view(u.userID, file.ID)
+	}
+	audit.Record(ctx, event.WithError(err))
+	return path, err
+}
+
+// ownsView 判断路径是否为用户当前的数据视图
+func (s *FileService) ownsView(userID int, path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, view := range s.views {
+		if key.userID == userID && samePath(view.path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// findFile 按引用查找用户可访问的文件，优先匹配用户自己的文件。引用不是上传文件时返回 nil
+func (s *FileService) findFile(userID int, ref string) (*model.File, error) {
+	ref = strings.TrimSpace(ref)
+	if id, ok := strings.CutPrefix(ref, "file:"); ok {
+		fileID, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file reference: %s", ref)
+		}
+		file, _, err := s.accessible(userID, fileID)
+		return file, err
+	}
+
+	candidates := append(s.GetFilesByUserID(userID), s.SharedFiles(userID)...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		owned := candidates[i].UserID == userID
+		if owned != (candidates[j].UserID == userID) {
+			return owned
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+	for _, file := range candidates {
+		if ref == file.OrigName || ref == file.Name || samePath(ref, file.Path) {
+			return file, nil
+		}
+	}
+
+	if inDir(ref, s.basePath) {
+		return nil, errors.New("permission denied")
+	}
+	return nil, nil
+}
+
+// view 返回用户对文件的数据视图，按需重新生成。
+// 视图是按访问策略过滤、按敏感列策略脱敏后的CSV，写在用户视图目录下的随机目录中，
+// Excel 文件只包含第一个工作表
+func (s *FileService) view(userID, fileID int) (string, error) {
+	file, restriction, err := s.accessible(userID, fileID)
+	if err != nil {
+		return "", err
+	}
+	if file.Status != "ready" {
+		return "", fmt.Errorf("file is not ready, current status: %s", file.Status)
+	}
+
+	key := viewKey{userID: userID, fileID: fileID}
+	revision := s.accessRevision()
+	s.mu.Lock()
+	cached, ok := s.views[key]
+	s.mu.Unlock()
+	if ok && cached.revision == revision && cached.updated.Equal(file.UpdatedAt) {
+		return cached.path, nil
+	}
+
+	table, err := analytics.LoadTable(file.Path)
+	if err != nil {
+		return "", err
+	}
+	if table, err = restriction.Table(table); err != nil {
+		return "", err
+	}
+	columns, rows, masked := s.privacy.Masker().Values(table.Columns, table.Rows, firstSheetColumns(file))
+
+	if err := os.MkdirAll(s.UserViewDir(userID), 0700); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(s.UserViewDir(userID), "view_*")
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimSuffix(file.OrigName, filepath.Ext(file.OrigName))
+	path := filepath.Join(dir, sqlengine.TableName(name)+".csv")
+	if err := writeCSV(path, columns, rows); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	s.privacy.Record(userID, fmt.Sprintf("file:%d", file.ID), PurposePrompt, masked)
+
+	s.mu.Lock()
+	if old, ok := s.views[key]; ok {
+		os.RemoveAll(filepath.Dir(old.path))
+	}
+	s.views[key] = &dataView{path: path, updated: file.UpdatedAt, revision: revision}
+	s.mu.Unlock()
+	return path, nil
+}
+
+// writeCSV 写出带表头的CSV文件
+func writeCSV(path string, columns []string, rows [][]interface{}) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write(columns); err != nil {
+		return err
+	}
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i := range record {
+			record[i] = ""
+			if i < len(row) {
+				record[i] = analytics.CellString(row[i])
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// samePath 判断两个路径是否指向同一位置
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// inDir 判断路径是否位于目录中
+func inDir(path, dir string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/tools"
+	"smart-analysis/internal/types"
+	"smart-analysis/internal/utils"
+)
+
+func TestFileService_AccessPolicies(t *testing.T) {
+	uploads := t.TempDir()
+	path := filepath.Join(uploads, "20240101_sales.csv")
+	content := "region,product,amount,cost\nEast,A,100,60\nWest,B,200,150\nEast,C,300,100\nNorth,D,400,300\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := NewFileService()
+	files.basePath = uploads
+	files.SetViewPath(t.TempDir())
+	files.SetTeamResolver(func(userID int) []string {
+		if userID == 3 {
+			return []string{"analysts"}
+		}
+		return nil
+	})
+	file := &model.File{ID: 1, UserID: 1, Name: "20240101_sales.csv", OrigName: "sales.csv", Path: path}
+	files.files[file.ID] = file
+	files.processFile(file)
+	sqlService := NewSQLService(files)
+
+	if _, err := files.PreviewFile(2, 1, 50); err == nil {
+		t.Fatal("没有访问策略时不应允许预览")
+	}
+
+	invalid := []model.AccessPolicyRequest{
+		{UserID: 2, RowFilter: "region = 'East' UNION SELECT * FROM sales"},
+		{UserID: 2, RowFilter: "city = 'Shanghai'"},
+		{UserID: 2, Columns: []string{"profit"}},
+		{UserID: 2, Team: "analysts"},
+		{UserID: 1},
+	}
+	for _, req := range invalid {
+		if _, err := files.CreateAccessPolicy(1, 1, &req); err == nil {
+			t.Errorf("应拒绝访问策略: %+v", req)
+		}
+	}
+	if _, err := files.CreateAccessPolicy(2, 1, &model.AccessPolicyRequest{UserID: 2}); err == nil {
+		t.Error("只有文件所有者可以添加访问策略")
+	}
+
+	policy, err := files.CreateAccessPolicy(1, 1, &model.AccessPolicyRequest{
+		UserID: 2, RowFilter: "region = 'East'", Columns: []string{"region", "product", "amount"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := files.CreateAccessPolicy(1, 1, &model.AccessPolicyRequest{Team: "analysts", RowFilter: "region = 'West'"}); err != nil {
+		t.Fatal(err)
+	}
+	if shared := files.SharedFiles(2); len(shared) != 1 || shared[0].ID != 1 {
+		t.Errorf("共享文件不正确: %v", shared)
+	}
+
+	// 预览只返回授权的行和列
+	preview, err := files.PreviewFile(2, 1, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := preview.(*utils.CSVData)
+	if strings.Join(data.Headers, ",") != "region,product,amount" || len(data.Rows) != 2 || data.Summary["total_rows"] != 2 {
+		t.Errorf("预览结果不正确: %v %v %v", data.Headers, data.Rows, data.Summary)
+	}
+	if preview, err := files.PreviewFile(3, 1, 50); err != nil || len(preview.(*utils.CSVData).Rows) != 1 {
+		t.Errorf("团队成员的预览结果不正确: %v %v", preview, err)
+	}
+
+	// SQL查询的表已按策略过滤，聚合也无法看到其他区域，隐藏的列不存在
+	resp, err := sqlService.Query(2, &model.SQLQueryRequest{SQL: "SELECT COUNT(*), SUM(amount) FROM sales"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row := resp.Result.Rows[0]; row[0] != int64(2) || row[1] != int64(400) {
+		t.Errorf("SQL结果未按策略过滤: %v", row)
+	}
+	if _, err := sqlService.Query(2, &model.SQLQueryRequest{SQL: "SELECT cost FROM sales"}); err == nil {
+		t.Error("不应能查询未授权的列")
+	}
+	if resp, err := sqlService.Query(1, &model.SQLQueryRequest{SQL: "SELECT COUNT(*) FROM sales"}); err != nil || resp.Result.Rows[0][0] != int64(4) {
+		t.Errorf("文件所有者不受访问策略限制: %v %v", resp, err)
+	}
+
+	// 智能体和沙箱只能读取数据视图，原始上传文件无法绕过策略
+	ctx := context.Background()
+	for _, ref := range []string{"sales.csv", path, "file:1"} {
+		view, err := files.ForUser(2).ResolveDataset(ctx, ref)
+		if err != nil {
+			t.Fatalf("%s: %v", ref, err)
+		}
+		if strings.HasPrefix(view, uploads) || !strings.HasPrefix(view, files.UserViewDir(2)+string(filepath.Separator)) {
+			t.Fatalf("数据视图应位于用户自己的视图目录: %s", view)
+		}
+		viewData, _ := os.ReadFile(view)
+		if string(viewData) != "region,product,amount\nEast,A,100\nEast,C,300\n" {
+			t.Errorf("数据视图不正确: %q", viewData)
+		}
+	}
+	for _, ref := range []string{path, "file:1", filepath.Join(uploads, "other.csv")} {
+		if _, err := files.ForUser(4).ResolveDataset(ctx, ref); err == nil {
+			t.Errorf("无权访问的用户不应解析到数据: %s", ref)
+		}
+	}
+
+	// 非上传文件只允许读取当前计划执行的产物，其他用户的视图目录和计划存储等路径被拒绝
+	artifacts := t.TempDir()
+	artifact := filepath.Join(artifacts, "t1.json")
+	runCtx := types.WithArtifactDir(ctx, artifacts)
+	if resolved, err := files.ForUser(4).ResolveDataset(runCtx, artifact); err != nil || resolved != artifact {
+		t.Errorf("当前计划执行的产物应原样返回: %s %v", resolved, err)
+	}
+	if _, err := files.ForUser(4).ResolveUpload(runCtx, artifact); err == nil {
+		t.Error("ResolveUpload 不应解析计划执行的产物")
+	}
+	if _, err := files.ForUser(4).ResolveDataset(ctx, artifact); err == nil {
+		t.Error("计划执行以外不应解析产物目录中的文件")
+	}
+	for _, ref := range []string{
+		filepath.Join(files.UserViewDir(2), "view", "sales.csv"),
+		filepath.Join(t.TempDir(), "plans", "plan_1.json"),
+		filepath.Join(artifacts, "..", "other", "t1.json"),
+		"/etc/passwd",
+	} {
+		if _, err := files.ForUser(4).ResolveDataset(runCtx, ref); err == nil {
+			t.Errorf("不应解析上传文件和本次执行产物以外的路径: %s", ref)
+		}
+	}
+
+	// 删除策略后立即失去访问权限，SQL表随策略版本重建
+	if err := files.DeleteAccessPolicy(1, 1, policy.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := files.PreviewFile(2, 1, 50); err == nil {
+		t.Error("删除策略后不应允许预览")
+	}
+	if _, err := sqlService.Query(2, &model.SQLQueryRequest{SQL: "SELECT * FROM sales"}); err == nil {
+		t.Error("删除策略后不应允许SQL查询")
+	}
+	if _, err := files.ForUser(2).ResolveDataset(ctx, "file:1"); err == nil {
+		t.Error("删除策略后不应能读取数据视图")
+	}
+}
+
+func TestAgentTools_ReadDataViews(t *testing.T) {
+	uploads := t.TempDir()
+	path := filepath.Join(uploads, "20240101_sales.csv")
+	content := "region,联系电话,amount,cost\nEast,13812345678,100,60\nWest,13900001111,200,150\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := NewFileService()
+	files.basePath = uploads
+	files.SetViewPath(t.TempDir())
+	files.SetPrivacy(NewPrivacyService("secret"))
+	file := &model.File{ID: 1, UserID: 1, Name: "20240101_sales.csv", OrigName: "sales.csv", Path: path}
+	files.files[file.ID] = file
+	files.processFile(file)
+	if _, err := files.CreateAccessPolicy(1, 1, &model.AccessPolicyRequest{
+		UserID: 2, RowFilter: "region = 'East'", Columns: []string{"region", "联系电话", "amount"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(userID int, name, args string) string {
+		t.Helper()
+		registry := tools.NewToolRegistry(nil)
+		registry.SetDatasets(files.ForUser(userID))
+		registry.RegisterAllTools()
+		found, _ := registry.GetTool(name)
+		out, err := found.(tool.InvokableTool).InvokableRun(context.Background(), args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	// Go实现的工具传入原始上传路径时读取的也是按策略过滤、脱敏后的数据视图
+	for name, args := range map[string]string{
+		"data_query": `{"query": "head 10", "file_path": "` + path + `"}`,
+		"sql_query":  `{"sql": "SELECT * FROM sales", "files": ["` + path + `"]}`,
+	} {
+		out := run(2, name, args)
+		if !strings.Contains(out, "East") || strings.Contains(out, "West") || strings.Contains(out, "cost") || strings.Contains(out, "13812345678") {
+			t.Errorf("%s 读取到了受限或未脱敏的数据:\n%s", name, out)
+		}
+		if out := run(3, name, args); !strings.Contains(out, "无法读取数据文件") {
+			t.Errorf("%s 不应读取无权访问的文件:\n%s", name, out)
+		}
+	}
+
+	// SQL工具只能查询用户的上传文件和数据视图
+	other := filepath.Join(t.TempDir(), "other.csv")
+	if err := os.WriteFile(other, []byte("token\nabc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out := run(1, "sql_query", `{"sql": "SELECT * FROM other", "files": ["`+other+`"]}`); !strings.Contains(out, "无法读取数据文件") {
+		t.Errorf("SQL工具不应读取非上传文件:\n%s", out)
+	}
+
+	// 其他用户的数据视图不能直接读取
+	view, err := files.ForUser(2).ResolveDataset(context.Background(), "file:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved, err := files.ForUser(2).ResolveDataset(context.Background(), view); err != nil || resolved != view {
+		t.Errorf("用户自己的数据视图应原样返回: %s %v", resolved, err)
+	}
+	if out := run(3, "sql_query", `{"sql": "SELECT * FROM sales", "files": ["`+view+`"]}`); !strings.Contains(out, "无法读取数据文件") {
+		t.Errorf("不应读取其他用户的数据视图:\n%s", out)
+	}
+}
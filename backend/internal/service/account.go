@@ -0,0 +1,426 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/notify"
+)
+
+// 邮件令牌和短信验证码的有效期
+const (
+	emailVerifyTTL    = 24 * time.Hour
+	passwordResetTTL  = 30 * time.Minute
+	phoneCodeTTL      = 5 * time.Minute
+	phoneCodeInterval = time.Minute // 同一手机号同一用途的最短发送间隔
+	maxPhoneCodeTries = 5           // 验证码输错次数达到上限后作废
+)
+
+// 邮件令牌的用途
+const (
+	tokenVerifyEmail   = "verify_email"
+	tokenResetPassword = "reset_password"
+)
+
+// phonePattern 规范化后的手机号：+ 加国家码和号码
+var phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// UserDataDeleter 保存用户数据的服务，注销账号时删除用户的数据
+type UserDataDeleter interface {
+	DeleteUserData(userID int) error
+}
+
+// accountToken 邮件中的一次性令牌，服务端只保存令牌的哈希
+type accountToken struct {
+	userID    int
+	purpose   string
+	email     string // 待验证的邮箱
+	expiresAt time.Time
+}
+
+// phoneCode 发送到手机的验证码
+type phoneCode struct {
+	codeHash  string
+	sentAt    time.Time
+	expiresAt time.Time
+	attempts  int
+}
+
+// AccountService 账号安全相关流程：邮箱验证、找回密码、手机号注册和登录、注销账号。
+// 邮件和短信通过可替换的 notify.Sender 发送，默认输出到控制台
+type AccountService struct {
+	users    *UserService
+	mailer   notify.Sender
+	sms      notify.Sender
+	appURL   string
+	deleters []UserDataDeleter
+
+	mu            sync.Mutex
+	tokens        map[string]*accountToken // 令牌哈希 -> 令牌
+	codes         map[string]*phoneCode    // 用途:手机号 -> 验证码
+	now           func() time.Time
+	generateToken func() (string, error)
+	generateCode  func() (string, error)
+}
+
+// NewAccountService 创建账号服务，appURL 为前端地址，用于生成邮件中的链接
+func NewAccountService(users *UserService, appURL string) *AccountService {
+	return &AccountService{
+		users:         users,
+		mailer:        notify.ConsoleSender{Channel: "email"},
+		sms:           notify.ConsoleSender{Channel: "sms"},
+		appURL:        strings.TrimRight(appURL, "/"),
+		tokens:        make(map[string]*accountToken),
+		codes:         make(map[string]*phoneCode),
+		now:           time.Now,
+		generateToken: invitationToken,
+		generateCode:  randomDigits,
+	}
+}
+
+// SetMailer 设置邮件发送方式
+func (s *AccountService) SetMailer(sender notify.Sender) {
+	s.mailer = sender
+}
+
+// SetSMS 设置短信发送方式
+func (s *AccountService) SetSMS(sender notify.Sender) {
+	s.sms = sender
+}
+
+// SetCascade 设置注销账号时删除用户数据的服务，按顺序调用，任一失败时停止注销。
+// 可能拒绝注销的服务（如团队空间）应放在最前面
+func (s *AccountService) SetCascade(deleters ...UserDataDeleter) {
+	s.deleters = deleters
+}
+
+// SendEmailVerification 向用户当前的邮箱发送验证邮件
+func (s *AccountService) SendEmailVerification(ctx context.Context, userID int) error {
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return errors.New("account has no email")
+	}
+	if user.EmailVerified {
+		return errors.New("email already verified")
+	}
+	return s.sendVerification(ctx, user, user.Email)
+}
+
+// RequestEmailChange 修改邮箱，向新邮箱发送验证邮件，验证后才替换账号邮箱
+func (s *AccountService) RequestEmailChange(ctx context.Context, userID int, email string) error {
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if existing, err := s.users.GetUserByEmail(email); err == nil && existing.ID != userID {
+		return errors.New("email already exists")
+	}
+	if strings.EqualFold(user.Email, email) && user.EmailVerified {
+		return errors.New("email already verified")
+	}
+	return s.sendVerification(ctx, user, email)
+}
+
+// VerifyEmail 使用验证邮件中的令牌验证邮箱
+func (s *AccountService) VerifyEmail(token string) (*model.User, error) {
+	stored, err := s.consumeToken(token, tokenVerifyEmail)
+	if err != nil {
+		return nil, err
+	}
+	return s.users.SetVerifiedEmail(stored.userID, stored.email)
+}
+
+// RequestPasswordReset 按邮箱发送重置密码链接，或按手机号发送验证码。
+// 邮箱或手机号未注册时同样返回成功，避免泄露账号是否存在
+func (s *AccountService) RequestPasswordReset(ctx context.Context, req *model.ForgotPasswordRequest) error {
+	if req.Phone != "" {
+		return s.SendPhoneCode(ctx, &model.PhoneCodeRequest{Phone: req.Phone, Purpose: model.OTPPurposeResetPassword})
+	}
+	if req.Email == "" {
+		return errors.New("email or phone is required")
+	}
+	user, err := s.users.GetUserByEmail(req.Email)
+	if err != nil || user.IsService {
+		return nil
+	}
+
+	token, err := s.issueToken(user.ID, tokenResetPassword, user.Email, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "重置密码",
+		Body: fmt.Sprintf("你好 %s，\n\n请在 %d 分钟内打开以下链接重置密码：\n%s\n\n如果不是你本人操作，请忽略此邮件。",
+			user.Username, int(passwordResetTTL.Minutes()), s.link("/reset-password", token)),
+	})
+}
+
+// ResetPassword 使用重置链接中的令牌，或手机号和验证码设置新密码，并解除账号锁定。
+// 调用方应随后撤销该用户已签发的令牌
+func (s *AccountService) ResetPassword(req *model.ResetPasswordRequest) (*model.User, error) {
+	var user *model.User
+	switch {
+	case req.Token != "":
+		stored, err := s.consumeToken(req.Token, tokenResetPassword)
+		if err != nil {
+			return nil, err
+		}
+		if user, err = s.users.GetUserByID(stored.userID); err != nil {
+			return nil, err
+		}
+	case req.Phone != "" && req.Code != "":
+		var err error
+		if user, err = s.phoneUser(req.Phone, req.Code, model.OTPPurposeResetPassword); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("token, or phone and code are required")
+	}
+
+	if err := s.users.SetPassword(user.ID, req.NewPassword); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SendPhoneCode 发送短信验证码。注册和绑定时手机号不能已被使用；
+// 登录、重置密码和注销时手机号未注册则不发送，同样返回成功
+func (s *AccountService) SendPhoneCode(ctx context.Context, req *model.PhoneCodeRequest) error {
+	phone, err := NormalizePhone(req.Phone)
+	if err != nil {
+		return err
+	}
+	_, lookupErr := s.users.GetUserByPhone(phone)
+	switch req.Purpose {
+	case model.OTPPurposeRegister, model.OTPPurposeBindPhone:
+		if lookupErr == nil {
+			return errors.New("phone already exists")
+		}
+	case model.OTPPurposeLogin, model.OTPPurposeResetPassword, model.OTPPurposeDeleteAccount:
+		if lookupErr != nil {
+			return nil
+		}
+	default:
+		return fmt.Errorf("invalid purpose: %s", req.Purpose)
+	}
+
+	code, err := s.generateCode()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	now := s.now()
+	key := req.Purpose + ":" + phone
+	if existing, exists := s.codes[key]; exists && now.Sub(existing.sentAt) < phoneCodeInterval {
+		s.mu.Unlock()
+		return errors.New("code already sent, please wait before requesting another one")
+	}
+	s.pruneCodes()
+	s.codes[key] = &phoneCode{codeHash: hashSecret(code), sentAt: now, expiresAt: now.Add(phoneCodeTTL)}
+	s.mu.Unlock()
+
+	return s.sms.Send(ctx, notify.Message{
+		To:   phone,
+		Body: fmt.Sprintf("【智能分析】验证码 %s，%d 分钟内有效，请勿泄露给他人。", code, int(phoneCodeTTL.Minutes())),
+	})
+}
+
+// RegisterPhone 验证短信验证码后使用手机号注册
+func (s *AccountService) RegisterPhone(req *model.PhoneRegisterRequest) (*model.User, error) {
+	phone, err := NormalizePhone(req.Phone)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCode(phone, req.Code, model.OTPPurposeRegister); err != nil {
+		return nil, err
+	}
+	return s.users.RegisterPhone(req.Username, phone, req.Password)
+}
+
+// LoginPhone 使用手机号和短信验证码登录，成功后解除账号锁定
+func (s *AccountService) LoginPhone(req *model.PhoneCodeLoginRequest) (*model.User, error) {
+	user, err := s.phoneUser(req.Phone, req.Code, model.OTPPurposeLogin)
+	if err != nil {
+		return nil, err
+	}
+	s.users.Unlock(user.ID)
+	return user, nil
+}
+
+// BindPhone 验证短信验证码后为当前用户绑定手机号
+func (s *AccountService) BindPhone(userID int, req *model.PhoneCodeLoginRequest) (*model.User, error) {
+	phone, err := NormalizePhone(req.Phone)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCode(phone, req.Code, model.OTPPurposeBindPhone); err != nil {
+		return nil, err
+	}
+	return s.users.SetPhone(userID, phone)
+}
+
+// DeleteAccount 验证密码或短信验证码后注销账号，删除用户的文件、会话等数据。
+// 调用方应随后撤销该用户已签发的令牌
+func (s *AccountService) DeleteAccount(userID int, req *model.DeleteAccountRequest) error {
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case req.Password != "":
+		if !s.users.CheckPassword(userID, req.Password) {
+			return errors.New("invalid password")
+		}
+	case req.Code != "" && user.Phone != "":
+		if err := s.checkCode(user.Phone, req.Code, model.OTPPurposeDeleteAccount); err != nil {
+			return err
+		}
+	default:
+		return errors.New("password or phone code is required")
+	}
+
+	for _, deleter := range s.deleters {
+		if err := deleter.DeleteUserData(userID); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	for hash, token := range s.tokens {
+		if token.userID == userID {
+			delete(s.tokens, hash)
+		}
+	}
+	s.mu.Unlock()
+	return s.users.DeleteUser(userID)
+}
+
+// NormalizePhone 规范化手机号：去掉空格和连字符，11位的中国大陆手机号补全 +86
+func NormalizePhone(phone string) (string, error) {
+	phone = strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(phone))
+	if len(phone) == 11 && strings.HasPrefix(phone, "1") {
+		phone = "+86" + phone
+	}
+	if !phonePattern.MatchString(phone) {
+		return "", errors.New("invalid phone number")
+	}
+	return phone, nil
+}
+
+// sendVerification 签发邮箱验证令牌并发送验证邮件
+func (s *AccountService) sendVerification(ctx context.Context, user *model.User, email string) error {
+	token, err := s.issueToken(user.ID, tokenVerifyEmail, email, emailVerifyTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, notify.Message{
+		To:      email,
+		Subject: "验证邮箱",
+		Body: fmt.Sprintf("你好 %s，\n\n请在 %d 小时内打开以下链接验证邮箱：\n%s\n\n如果不是你本人操作，请忽略此邮件。",
+			user.Username, int(emailVerifyTTL.Hours()), s.link("/verify-email", token)),
+	})
+}
+
+// issueToken 签发邮件令牌，同一用户同一用途的旧令牌作废
+func (s *AccountService) issueToken(userID int, purpose, email string, ttl time.Duration) (string, error) {
+	token, err := s.generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for hash, stored := range s.tokens {
+		if (stored.userID == userID && stored.purpose == purpose) || !now.Before(stored.expiresAt) {
+			delete(s.tokens, hash)
+		}
+	}
+	s.tokens[hashSecret(token)] = &accountToken{userID: userID, purpose: purpose, email: email, expiresAt: now.Add(ttl)}
+	return token, nil
+}
+
+// consumeToken 校验并作废邮件令牌
+func (s *AccountService) consumeToken(token, purpose string) (*accountToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := hashSecret(token)
+	stored, exists := s.tokens[hash]
+	if !exists || stored.purpose != purpose || !s.now().Before(stored.expiresAt) {
+		return nil, errors.New("invalid or expired token")
+	}
+	delete(s.tokens, hash)
+	return stored, nil
+}
+
+// phoneUser 校验验证码并返回手机号对应的用户
+func (s *AccountService) phoneUser(phone, code, purpose string) (*model.User, error) {
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCode(phone, code, purpose); err != nil {
+		return nil, err
+	}
+	return s.users.GetUserByPhone(phone)
+}
+
+// checkCode 校验短信验证码，验证成功或输错次数达到上限后验证码作废
+func (s *AccountService) checkCode(phone, code, purpose string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := purpose + ":" + phone
+	stored, exists := s.codes[key]
+	if !exists || !s.now().Before(stored.expiresAt) {
+		return errors.New("invalid or expired code")
+	}
+	if subtle.ConstantTimeCompare([]byte(stored.codeHash), []byte(hashSecret(code))) != 1 {
+		stored.attempts++
+		if stored.attempts >= maxPhoneCodeTries {
+			delete(s.codes, key)
+		}
+		return errors.New("invalid or expired code")
+	}
+	delete(s.codes, key)
+	return nil
+}
+
+// pruneCodes 清理过期的验证码，调用方需持有锁
+func (s *AccountService) pruneCodes() {
+	now := s.now()
+	for key, code := range s.codes {
+		if !now.Before(code.expiresAt) {
+			delete(s.codes, key)
+		}
+	}
+}
+
+// link 生成邮件中指向前端页面的链接
+func (s *AccountService) link(path, token string) string {
+	return s.appURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// randomDigits 生成6位数字验证码
+func randomDigits() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/notify"
+)
+
+// recordingSender 记录发送的消息
+type recordingSender struct {
+	mu       sync.Mutex
+	messages []notify.Message
+}
+
+func (r *recordingSender) Send(_ context.Context, msg notify.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func (r *recordingSender) last(t *testing.T) notify.Message {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.messages) == 0 {
+		t.Fatal("no message sent")
+	}
+	return r.messages[len(r.messages)-1]
+}
+
+var linkToken = regexp.MustCompile(`\?token=(\S+)`)
+
+func (r *recordingSender) token(t *testing.T) string {
+	t.Helper()
+	match := linkToken.FindStringSubmatch(r.last(t).Body)
+	if match == nil {
+		t.Fatalf("no link in message: %q", r.last(t).Body)
+	}
+	return match[1]
+}
+
+func newTestAccountService(users *UserService) (*AccountService, *recordingSender, *recordingSender) {
+	mailer, sms := &recordingSender{}, &recordingSender{}
+	service := NewAccountService(users, "http://app.example/")
+	service.SetMailer(mailer)
+	service.SetSMS(sms)
+	service.generateCode = func() (string, error) { return "123456", nil }
+	return service, mailer, sms
+}
+
+func TestAccountService_EmailVerificationAndReset(t *testing.T) {
+	users := NewUserService()
+	users.SetAdminEmails("alice@example.com")
+	alice, _ := users.Register(&model.RegisterRequest{Username: "alice", Email: "alice@example.com", Password: "secret1"})
+	service, mailer, _ := newTestAccountService(users)
+	ctx := context.Background()
+
+	if users.IsAdmin(alice.ID) {
+		t.Fatal("unverified email should not grant admin")
+	}
+	if err := service.SendEmailVerification(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	first := mailer.token(t)
+	if err := service.SendEmailVerification(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.VerifyEmail(first); err == nil {
+		t.Fatal("resending should invalidate the previous link")
+	}
+	token := mailer.token(t)
+	if _, err := service.VerifyEmail(token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.VerifyEmail(token); err == nil {
+		t.Fatal("token should be single use")
+	}
+	if !users.IsAdmin(alice.ID) {
+		t.Fatal("verified admin email should grant admin")
+	}
+
+	// 修改邮箱在验证前不生效
+	if err := service.RequestEmailChange(ctx, alice.ID, "alice@new.example"); err != nil {
+		t.Fatal(err)
+	}
+	if mailer.last(t).To != "alice@new.example" {
+		t.Fatalf("verification sent to %q", mailer.last(t).To)
+	}
+	if user, _ := users.GetUserByID(alice.ID); user.Email != "alice@example.com" {
+		t.Fatalf("email changed before verification: %q", user.Email)
+	}
+	user, err := service.VerifyEmail(mailer.token(t))
+	if err != nil || user.Email != "alice@new.example" || !user.EmailVerified {
+		t.Fatalf("VerifyEmail = %+v, %v", user, err)
+	}
+
+	// 未注册的邮箱不发送邮件，也不返回错误
+	sent := len(mailer.messages)
+	if err := service.RequestPasswordReset(ctx, &model.ForgotPasswordRequest{Email: "nobody@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.messages) != sent {
+		t.Fatal("reset mail sent to unknown address")
+	}
+	if err := service.RequestPasswordReset(ctx, &model.ForgotPasswordRequest{Email: "alice@new.example"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.ResetPassword(&model.ResetPasswordRequest{Token: mailer.token(t), NewPassword: "newpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Login(&model.LoginRequest{Email: "alice@new.example", Password: "newpass"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccountService_PhoneCodes(t *testing.T) {
+	users := NewUserService()
+	service, _, sms := newTestAccountService(users)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	register := &model.PhoneCodeRequest{Phone: "138 0013 8000", Purpose: model.OTPPurposeRegister}
+	if err := service.SendPhoneCode(ctx, register); err != nil {
+		t.Fatal(err)
+	}
+	if sms.last(t).To != "+8613800138000" {
+		t.Fatalf("code sent to %q", sms.last(t).To)
+	}
+	if err := service.SendPhoneCode(ctx, register); err == nil {
+		t.Fatal("resend within the interval should fail")
+	}
+	bob, err := service.RegisterPhone(&model.PhoneRegisterRequest{Phone: "13800138000", Code: "123456", Username: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bob.Phone != "+8613800138000" {
+		t.Fatalf("phone = %q", bob.Phone)
+	}
+
+	// 输错次数达到上限后验证码作废
+	now = now.Add(phoneCodeInterval)
+	if err := service.SendPhoneCode(ctx, &model.PhoneCodeRequest{Phone: "13800138000", Purpose: model.OTPPurposeLogin}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxPhoneCodeTries; i++ {
+		if _, err := service.LoginPhone(&model.PhoneCodeLoginRequest{Phone: "13800138000", Code: "000000"}); err == nil {
+			t.Fatal("wrong code accepted")
+		}
+	}
+	if _, err := service.LoginPhone(&model.PhoneCodeLoginRequest{Phone: "13800138000", Code: "123456"}); err == nil {
+		t.Fatal("code should be invalidated after too many attempts")
+	}
+
+	// 密码登录连续失败后锁定，短信验证码登录后解除锁定
+	users.now = func() time.Time { return now }
+	if _, err := users.SetVerifiedEmail(bob.ID, "bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetPassword(bob.ID, "secret1"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxFailedLogins; i++ {
+		users.Login(&model.LoginRequest{Email: "bob@example.com", Password: "wrong"})
+	}
+	if _, err := users.Login(&model.LoginRequest{Email: "bob@example.com", Password: "secret1"}); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Login error = %v, want ErrAccountLocked", err)
+	}
+	now = now.Add(phoneCodeInterval)
+	if err := service.SendPhoneCode(ctx, &model.PhoneCodeRequest{Phone: "13800138000", Purpose: model.OTPPurposeLogin}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.LoginPhone(&model.PhoneCodeLoginRequest{Phone: "13800138000", Code: "123456"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Login(&model.LoginRequest{Email: "bob@example.com", Password: "secret1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未注册的手机号登录时不发送验证码
+	sent := len(sms.messages)
+	if err := service.SendPhoneCode(ctx, &model.PhoneCodeRequest{Phone: "+14155550100", Purpose: model.OTPPurposeLogin}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sms.messages) != sent {
+		t.Fatal("code sent to unknown phone")
+	}
+}
+
+func TestAccountService_DeleteAccount(t *testing.T) {
+	users := NewUserService()
+	alice, _ := users.Register(&model.RegisterRequest{Username: "alice", Email: "alice@example.com", Password: "secret1"})
+	bob, _ := users.Register(&model.RegisterRequest{Username: "bob", Email: "bob@example.com", Password: "secret1"})
+	workspaces := NewWorkspaceService(users)
+	org, _ := workspaces.CreateOrganization(alice.ID, &model.OrganizationRequest{Name: "Corp"})
+	ws, _ := workspaces.CreateWorkspace(alice.ID, org.ID, &model.WorkspaceRequest{Name: "Team"})
+	if _, err := workspaces.AddMember(ws.ID, bob.ID, model.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	analysis := NewAnalysisService()
+	session, _ := analysis.CreateSession(alice.ID, &model.CreateSessionRequest{Name: "s"})
+
+	service, _, _ := newTestAccountService(users)
+	service.SetCascade(workspaces, analysis)
+
+	if err := service.DeleteAccount(alice.ID, &model.DeleteAccountRequest{Password: "wrong"}); err == nil {
+		t.Fatal("wrong password accepted")
+	}
+	if err := service.DeleteAccount(alice.ID, &model.DeleteAccountRequest{Password: "secret1"}); err == nil {
+		t.Fatal("sole owner of a shared workspace should not be deleted")
+	}
+	if _, err := analysis.GetSession(alice.ID, session.ID); err != nil {
+		t.Fatal("refused deletion should keep the data")
+	}
+
+	if err := workspaces.RemoveMember(alice.ID, ws.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.DeleteAccount(alice.ID, &model.DeleteAccountRequest{Password: "secret1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.GetUserByID(alice.ID); err == nil {
+		t.Fatal("user should be deleted")
+	}
+	if _, err := analysis.GetSession(alice.ID, session.ID); err == nil {
+		t.Fatal("sessions should be deleted")
+	}
+}
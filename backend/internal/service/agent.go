@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/scheduler"
+)
+
+// QueryAgent 使用多智能体系统回答查询，由 manager.AgentManager 实现。
+// 上下文中带有用户和查询ID，规划智能体据此把执行计划关联到查询
+type QueryAgent interface {
+	ProcessQuery(ctx context.Context, query string) (*schema.Message, error)
+}
+
+// workspaceKey 上下文中团队空间ID的键
+type workspaceKey struct{}
+
+// SetAgentSystem 设置回答查询的多智能体系统，未设置时查询直接调用LLM
+func (s *AnalysisService) SetAgentSystem(agents QueryAgent) {
+	s.agents = agents
+}
+
+// ChatModel 返回供多智能体系统使用的聊天模型。每次调用使用上下文中的用户（和团队空间）的LLM配置，
+// 与直接调用LLM一样检查团队空间预算并记录使用量
+func (s *AnalysisService) ChatModel() einomodel.BaseChatModel {
+	return &analysisChatModel{service: s}
+}
+
+// answer 回答查询：配置了多智能体系统时由它规划并执行，否则直接调用LLM
+func (s *AnalysisService) answer(ctx context.Context, userID, workspaceID int, req *model.QueryRequest, fileData interface{}) (string, error) {
+	if s.agents == nil {
+		return s.callLLM(userID, workspaceID, req.Question, fileData)
+	}
+
+	question := req.Question
+	if req.FileID != nil {
+		question = fmt.Sprintf("%s\n\n数据文件: file:%d", question, *req.FileID)
+	}
+	ctx = context.WithValue(scheduler.WithUser(ctx, strconv.Itoa(userID)), workspaceKey{}, workspaceID)
+	response, err := s.agents.ProcessQuery(ctx, question)
+	if err != nil {
+		return "", err
+	}
+	return response.Content, nil
+}
+
+// analysisChatModel 通过 AnalysisService 的LLM配置调用LLM的聊天模型
+type analysisChatModel struct {
+	service *AnalysisService
+}
+
+// Generate 将消息合并为提示词，使用上下文中的用户的LLM配置生成响应
+func (m *analysisChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...einomodel.Option) (*schema.Message, error) {
+	userID, err := strconv.Atoi(scheduler.UserFromContext(ctx))
+	if err != nil {
+		return nil, errors.New("no user in context")
+	}
+	workspaceID, _ := ctx.Value(workspaceKey{}).(int)
+
+	parts := make([]string, 0, len(input))
+	for _, msg := range input {
+		if msg != nil && msg.Content != "" {
+			parts = append(parts, msg.Content)
+		}
+	}
+	answer, err := m.service.callLLM(userID, workspaceID, strings.Join(parts, "\n\n"), nil)
+	if err != nil {
+		return nil, err
+	}
+	return schema.AssistantMessage(answer, nil), nil
+}
+
+// Stream 生成完整响应后作为单条消息的流返回
+func (m *analysisChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...einomodel.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
@@ -2,25 +2,40 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
+	"smart-analysis/internal/audit"
 	"smart-analysis/internal/model"
+	"smart-analysis/internal/planstore"
+	"smart-analysis/internal/prompts"
+	"smart-analysis/internal/types"
 	"strings"
+	"sync"
 	"time"
 )
 
 type AnalysisService struct {
+	mu            sync.Mutex // 保护会话、查询、图表、LLM配置、使用量和编号，不在持有时调用LLM
 	sessions      map[int]*model.Session
 	queries       map[int]*model.Query
 	llmConfigs    map[int][]*model.LLMConfig
+	wsLLMConfigs  map[int][]*model.LLMConfig // 团队空间共享的LLM配置
 	usage         map[int][]*model.Usage
 	nextSessionID int
 	nextQueryID   int
 	nextConfigID  int
 	nextUsageID   int
+	prompts       *prompts.Library
+	planStore     types.PlanStore
+	planRunner    PlanRunner
+	agents        QueryAgent
+	workspaces    *WorkspaceService
+	charts        map[int]*model.Chart
+	nextChartID   int
+	chartRenderer ChartRenderer
 }
 
 func NewAnalysisService() *AnalysisService {
@@ -28,23 +43,37 @@ func NewAnalysisService() *AnalysisService {
 		sessions:      make(map[int]*model.Session),
 		queries:       make(map[int]*model.Query),
 		llmConfigs:    make(map[int][]*model.LLMConfig),
+		wsLLMConfigs:  make(map[int][]*model.LLMConfig),
 		usage:         make(map[int][]*model.Usage),
 		nextSessionID: 1,
 		nextQueryID:   1,
 		nextConfigID:  1,
 		nextUsageID:   1,
+		charts:        make(map[int]*model.Chart),
+		nextChartID:   1,
+		prompts:       prompts.GetGlobalLibrary(),
+		planStore:     planstore.NewMemoryStore(),
 	}
 }
 
 // CreateSession 创建会话
 func (s *AnalysisService) CreateSession(userID int, req *model.CreateSessionRequest) (*model.Session, error) {
+	if req.WorkspaceID != 0 {
+		if err := s.authorizeWorkspace(userID, req.WorkspaceID, model.RoleEditor); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	session := &model.Session{
-		ID:        s.nextSessionID,
-		UserID:    userID,
-		Name:      req.Name,
-		FileID:    req.FileID,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:          s.nextSessionID,
+		UserID:      userID,
+		WorkspaceID: req.WorkspaceID,
+		Name:        req.Name,
+		FileID:      req.FileID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
 	}
 
 	s.sessions[s.nextSessionID] = session
@@ -53,24 +82,38 @@ func (s *AnalysisService) CreateSession(userID int, req *model.CreateSessionRequ
 	return session, nil
 }
 
-// GetSession 获取会话
+// GetSession 获取会话，团队空间中的会话对空间成员可见
 func (s *AnalysisService) GetSession(userID, sessionID int) (*model.Session, error) {
-	session, exists := s.sessions[sessionID]
-	if !exists {
-		return nil, errors.New("session not found")
-	}
+	return s.session(userID, sessionID, model.RoleViewer)
+}
 
-	if session.UserID != userID {
-		return nil, errors.New("permission denied")
+// DeleteUserData 删除用户的会话、查询记录、图表和个人LLM配置，用于注销账号。使用量记录保留用于团队空间预算统计
+func (s *AnalysisService) DeleteUserData(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, id)
+		}
 	}
-
-	return session, nil
+	for id, query := range s.queries {
+		if query.UserID == userID {
+			delete(s.queries, id)
+		}
+	}
+	for id, chart := range s.charts {
+		if chart.UserID == userID {
+			delete(s.charts, id)
+		}
+	}
+	delete(s.llmConfigs, userID)
+	return nil
 }
 
-// Query 处理查询请求
-func (s *AnalysisService) Query(userID int, req *model.QueryRequest, fileService *FileService) (*model.QueryResponse, error) {
-	// 获取会话
-	session, err := s.GetSession(userID, req.SessionID)
+// Query 处理查询请求，配置了多智能体系统时执行计划关联到本次查询，可以查看、恢复和重新执行
+func (s *AnalysisService) Query(ctx context.Context, userID int, req *model.QueryRequest, fileService *FileService) (*model.QueryResponse, error) {
+	// 获取会话，在团队空间的会话中提问需要 editor 权限
+	session, err := s.session(userID, req.SessionID, model.RoleEditor)
 	if err != nil {
 		return nil, err
 	}
@@ -78,24 +121,15 @@ func (s *AnalysisService) Query(userID int, req *model.QueryRequest, fileService
 	// 获取文件数据（如果指定了文件）
 	var fileData interface{}
 	if req.FileID != nil {
-		file, err := fileService.GetFileByID(*req.FileID)
-		if err != nil {
-			return nil, err
-		}
-
-		if file.UserID != userID {
-			return nil, errors.New("permission denied")
-		}
-
-		fileData, err = fileService.PreviewFile(userID, *req.FileID, 100) // 获取前100行
+		// 访问权限由文件服务统一检查，共享文件按访问策略过滤
+		fileData, err = fileService.FileData(userID, *req.FileID, 100, PurposePrompt) // 获取前100行
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	// 创建查询记录
+	// 创建查询记录，多智能体系统使用模板库中的提示词，直接调用LLM时问题原样发送，不记录提示词版本
 	query := &model.Query{
-		ID:        s.nextQueryID,
 		SessionID: session.ID,
 		UserID:    userID,
 		Question:  req.Question,
@@ -103,21 +137,20 @@ func (s *AnalysisService) Query(userID int, req *model.QueryRequest, fileService
 		Status:    "processing",
 		CreatedAt: time.Now(),
 	}
+	if s.agents != nil {
+		query.PromptVersion = s.prompts.Version()
+	}
+	s.addQuery(query)
 
-	s.queries[s.nextQueryID] = query
-	s.nextQueryID++
-
-	// 调用LLM进行分析
-	answer, err := s.callLLM(userID, req.Question, fileData)
+	// 调用多智能体系统或LLM进行分析
+	answer, err := s.answer(types.WithQueryID(ctx, query.ID), userID, session.WorkspaceID, req, fileData)
+	s.finishQuery(query, answer, err)
 	if err != nil {
-		query.Status = "error"
 		return nil, err
 	}
 
-	query.Answer = answer
-	query.Status = "completed"
-
 	return &model.QueryResponse{
+		QueryID:   query.ID,
 		Answer:    answer,
 		Data:      fileData,
 		QueryType: "analysis",
@@ -125,105 +158,42 @@ func (s *AnalysisService) Query(userID int, req *model.QueryRequest, fileService
 	}, nil
 }
 
-// Visualize 生成可视化图表
-func (s *AnalysisService) Visualize(userID int, req *model.VisualizationRequest, fileService *FileService) (*model.VisualizationResponse, error) {
-	// 获取文件数据
-	file, err := fileService.GetFileByID(req.FileID)
-	if err != nil {
-		return nil, err
-	}
-
-	if file.UserID != userID {
-		return nil, errors.New("permission denied")
-	}
-
-	fileData, err := fileService.PreviewFile(userID, req.FileID, -1) // 获取所有数据
-	if err != nil {
-		return nil, err
-	}
-
-	// 调用LLM生成图表代码
-	prompt := fmt.Sprintf("基于以下数据生成%s图表的配置: %s\n数据: %v",
-		req.ChartType, req.Query, fileData)
-
-	chartConfig, err := s.callLLM(userID, prompt, fileData)
-	if err != nil {
-		return nil, err
-	}
-
-	// 创建查询记录
-	query := &model.Query{
-		ID:        s.nextQueryID,
-		SessionID: req.SessionID,
-		UserID:    userID,
-		Question:  req.Query,
-		Answer:    chartConfig,
-		QueryType: "visualization",
-		Status:    "completed",
-		CreatedAt: time.Now(),
-	}
-
-	s.queries[s.nextQueryID] = query
-	s.nextQueryID++
-
-	return &model.VisualizationResponse{
-		ChartData: chartConfig,
-		ChartType: req.ChartType,
-		Title:     "数据可视化图表",
-	}, nil
-}
-
 // GenerateReport 生成报告
 func (s *AnalysisService) GenerateReport(userID int, req *model.ReportRequest, fileService *FileService) (*model.ReportResponse, error) {
 	// 获取文件数据
-	file, err := fileService.GetFileByID(req.FileID)
+	fileData, err := fileService.FileData(userID, req.FileID, -1, PurposePrompt)
 	if err != nil {
 		return nil, err
 	}
 
-	if file.UserID != userID {
-		return nil, errors.New("permission denied")
-	}
-
-	fileData, err := fileService.PreviewFile(userID, req.FileID, -1)
+	// 构建报告提示
+	prompt, err := s.prompts.Render(prompts.TemplateReport, map[string]interface{}{
+		"Description": req.Description,
+		"Dimensions":  req.Dimensions,
+		"Data":        fileData,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// 构建报告提示
-	prompt := fmt.Sprintf(`
-请基于以下数据生成一份详细的分析报告：
-数据描述: %s
-分析维度: %s
-数据内容: %v
-
-请生成包含以下部分的报告：
-1. 数据概览
-2. 关键指标分析
-3. 趋势分析
-4. 结论和建议
-`, req.Description, strings.Join(req.Dimensions, ", "), fileData)
-
 	// 调用LLM生成报告
-	reportContent, err := s.callLLM(userID, prompt, fileData)
+	reportContent, err := s.callLLM(userID, s.sessionWorkspace(userID, req.SessionID), prompt, fileData)
 	if err != nil {
 		return nil, err
 	}
 
 	// 创建查询记录
 	query := &model.Query{
-		ID:        s.nextQueryID,
-		SessionID: req.SessionID,
-		UserID:    userID,
-		Question:  prompt,
-		Answer:    reportContent,
-		QueryType: "report",
-		Status:    "completed",
-		CreatedAt: time.Now(),
+		SessionID:     req.SessionID,
+		UserID:        userID,
+		Question:      prompt,
+		Answer:        reportContent,
+		QueryType:     "report",
+		Status:        "completed",
+		PromptVersion: s.prompts.Version(),
+		CreatedAt:     time.Now(),
 	}
-
-	s.queries[s.nextQueryID] = query
-	s.nextQueryID++
+	s.addQuery(query)
 
 	return &model.ReportResponse{
 		Content: reportContent,
@@ -232,56 +202,108 @@ func (s *AnalysisService) GenerateReport(userID int, req *model.ReportRequest, f
 	}, nil
 }
 
-// GetHistory 获取查询历史
+// GetHistory 获取查询历史，指定团队空间中的会话时返回会话中所有成员的查询
 func (s *AnalysisService) GetHistory(userID int, sessionID *int) ([]*model.Query, error) {
+	shared := false
+	if sessionID != nil {
+		if session, err := s.GetSession(userID, *sessionID); err == nil && session.WorkspaceID != 0 {
+			shared = true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	var history []*model.Query
 	for _, query := range s.queries {
-		if query.UserID == userID {
+		if query.UserID == userID || shared {
 			if sessionID == nil || query.SessionID == *sessionID {
-				history = append(history, query)
+				copied := *query
+				history = append(history, &copied)
 			}
 		}
 	}
 	return history, nil
 }
 
-// ConfigLLM 配置LLM
+// addQuery 为查询分配ID并保存
+func (s *AnalysisService) addQuery(query *model.Query) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	query.ID = s.nextQueryID
+	s.queries[query.ID] = query
+	s.nextQueryID++
+}
+
+// finishQuery 记录查询的回答，err 不为空时将查询标记为失败
+func (s *AnalysisService) finishQuery(query *model.Query, answer string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		query.Status = "error"
+		return
+	}
+	query.Answer = answer
+	query.Status = "completed"
+}
+
+// ConfigLLM 配置LLM，指定团队空间时添加空间共享的配置，需要空间所有者权限
 func (s *AnalysisService) ConfigLLM(userID int, req *model.LLMConfigRequest) (*model.LLMConfig, error) {
+	if req.WorkspaceID != 0 {
+		if err := s.authorizeWorkspace(userID, req.WorkspaceID, model.RoleOwner); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	configs, key := s.llmConfigs, userID
+	if req.WorkspaceID != 0 {
+		configs, key = s.wsLLMConfigs, req.WorkspaceID
+	}
+
 	// 如果设置为默认，先取消其他默认配置
 	if req.IsDefault {
-		for _, config := range s.llmConfigs[userID] {
+		for _, config := range configs[key] {
 			config.IsDefault = false
 		}
 	}
 
 	config := &model.LLMConfig{
-		ID:        s.nextConfigID,
-		UserID:    userID,
-		Provider:  req.Provider,
-		APIKey:    req.APIKey,
-		Model:     req.Model,
-		IsDefault: req.IsDefault,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	if s.llmConfigs[userID] == nil {
-		s.llmConfigs[userID] = make([]*model.LLMConfig, 0)
-	}
-	s.llmConfigs[userID] = append(s.llmConfigs[userID], config)
+		ID:          s.nextConfigID,
+		UserID:      userID,
+		WorkspaceID: req.WorkspaceID,
+		Provider:    req.Provider,
+		APIKey:      req.APIKey,
+		Model:       req.Model,
+		IsDefault:   req.IsDefault,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	configs[key] = append(configs[key], config)
 	s.nextConfigID++
 
-	return config, nil
+	copied := *config
+	return &copied, nil
 }
 
 // GetLLMConfig 获取LLM配置
 func (s *AnalysisService) GetLLMConfig(userID int) ([]*model.LLMConfig, error) {
-	return s.llmConfigs[userID], nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	configs := make([]*model.LLMConfig, 0, len(s.llmConfigs[userID]))
+	for _, config := range s.llmConfigs[userID] {
+		copied := *config
+		configs = append(configs, &copied)
+	}
+	return configs, nil
 }
 
 // GetUsage 获取使用量统计
 func (s *AnalysisService) GetUsage(userID int) (*model.UsageResponse, error) {
-	userUsage := s.usage[userID]
+	s.mu.Lock()
+	userUsage := append([]*model.Usage(nil), s.usage[userID]...)
+	s.mu.Unlock()
 	if userUsage == nil {
 		return &model.UsageResponse{
 			TotalTokens: 0,
@@ -304,35 +326,79 @@ func (s *AnalysisService) GetUsage(userID int) (*model.UsageResponse, error) {
 	}, nil
 }
 
-// callLLM 调用LLM API（模拟实现）
-func (s *AnalysisService) callLLM(userID int, prompt string, data interface{}) (string, error) {
-	// 获取用户的LLM配置
-	var config *model.LLMConfig
-	for _, cfg := range s.llmConfigs[userID] {
-		if cfg.IsDefault {
-			config = cfg
-			break
-		}
-	}
-
-	if config == nil && len(s.llmConfigs[userID]) > 0 {
-		config = s.llmConfigs[userID][0] // 使用第一个配置
-	}
-
+// callLLM 调用LLM API（模拟实现）。
+// workspaceID 不为0时优先使用团队空间的LLM配置，调用前检查空间的每月预算，使用量计入空间
+func (s *AnalysisService) callLLM(userID, workspaceID int, prompt string, data interface{}) (string, error) {
+	config := s.llmConfig(userID, workspaceID)
 	if config == nil {
 		return "", errors.New("no LLM configuration found")
 	}
+	if err := s.checkBudget(workspaceID); err != nil {
+		return "", err
+	}
 
 	// 根据提供商调用不同的API
+	var answer string
+	var err error
 	switch config.Provider {
 	case "openai":
-		return s.callOpenAI(config, prompt)
+		answer, err = s.callOpenAI(config, prompt)
 	case "hunyuan":
-		return s.callHunyuan(config, prompt)
+		answer, err = s.callHunyuan(config, prompt)
 	default:
 		// 默认返回模拟响应
-		return s.getMockResponse(prompt, data), nil
+		answer = s.getMockResponse(prompt, data)
+	}
+	event := audit.Event{
+		UserID:   userID,
+		Action:   audit.ActionLLMCall,
+		Resource: config.Provider,
+		Detail: map[string]interface{}{
+			"model":         config.Model,
+			"workspace_id":  workspaceID,
+			"prompt_sha256": audit.Digest(prompt),
+		},
+	}
+	if err != nil {
+		audit.Record(context.Background(), event.WithError(err))
+		return "", err
+	}
+	event.Detail["response_sha256"] = audit.Digest(answer)
+	audit.Record(context.Background(), event)
+
+	s.recordUsage(userID, workspaceID, config, estimateTokens(prompt)+estimateTokens(answer))
+	return answer, nil
+}
+
+// llmConfig 返回调用LLM使用的配置的副本：优先使用团队空间的配置，没有时使用用户的配置
+func (s *AnalysisService) llmConfig(userID, workspaceID int) *model.LLMConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var config *model.LLMConfig
+	if workspaceID != 0 {
+		config = defaultLLMConfig(s.wsLLMConfigs[workspaceID])
+	}
+	if config == nil {
+		config = defaultLLMConfig(s.llmConfigs[userID])
+	}
+	if config == nil {
+		return nil
+	}
+	copied := *config
+	return &copied
+}
+
+// defaultLLMConfig 返回默认配置，没有默认配置时使用第一个配置
+func defaultLLMConfig(configs []*model.LLMConfig) *model.LLMConfig {
+	for _, cfg := range configs {
+		if cfg.IsDefault {
+			return cfg
+		}
+	}
+	if len(configs) > 0 {
+		return configs[0]
 	}
+	return nil
 }
 
 // callOpenAI 调用OpenAI API
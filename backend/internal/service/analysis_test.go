@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/pii"
+)
+
+// TestAnalysisService_Concurrent 并发提问、生成图表、修改敏感列和注销账号，配合 go test -race 检查数据竞争
+func TestAnalysisService_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sales.csv")
+	if err := os.WriteFile(path, []byte("region,sales\n华东,100\n华北,50\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := NewFileService()
+	files.SetViewPath(t.TempDir())
+	file := &model.File{ID: 1, UserID: 1, Name: "sales.csv", OrigName: "sales.csv", Path: path}
+	files.files[file.ID] = file
+	files.processFile(file)
+
+	analysis := NewAnalysisService()
+	analysis.SetChartRenderer(&fakeRenderer{})
+	if _, err := analysis.ConfigLLM(1, &model.LLMConfigRequest{Provider: "mock", APIKey: "sk-test", Model: "m"}); err != nil {
+		t.Fatal(err)
+	}
+	session, _ := analysis.CreateSession(1, &model.CreateSessionRequest{Name: "s"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			analysis.Query(context.Background(), 1, &model.QueryRequest{SessionID: session.ID, Question: "各地区销售额", FileID: &file.ID}, files)
+		}()
+		go func() {
+			defer wg.Done()
+			analysis.Visualize(context.Background(), 1, &model.VisualizationRequest{SessionID: session.ID, FileID: file.ID, Query: "各地区销售额"}, files)
+		}()
+		go func() {
+			defer wg.Done()
+			files.UpdateSensitiveColumns(1, file.ID, []pii.Column{{Name: "region", Policy: pii.PolicyAllow}})
+			analysis.GetHistory(1, nil)
+			analysis.GetUsage(1)
+		}()
+		go func() {
+			defer wg.Done()
+			analysis.CreateSession(2, &model.CreateSessionRequest{Name: "s"})
+			analysis.DeleteUserData(2)
+			files.DeleteUserData(2)
+		}()
+	}
+	wg.Wait()
+
+	history, _ := analysis.GetHistory(1, nil)
+	if len(history) != 8 {
+		t.Errorf("期望8条查询记录，实际 %d", len(history))
+	}
+	for _, query := range history {
+		if query.Status != "completed" {
+			t.Errorf("查询 %d 未完成: %s", query.ID, query.Status)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"smart-analysis/internal/model"
+)
+
+// SetWorkspaces 设置团队空间服务，未设置时会话和LLM配置只能在个人空间中使用
+func (s *AnalysisService) SetWorkspaces(workspaces *WorkspaceService) {
+	s.workspaces = workspaces
+}
+
+// GetWorkspaceLLMConfig 获取团队空间共享的LLM配置，非空间所有者看不到完整的 API Key
+func (s *AnalysisService) GetWorkspaceLLMConfig(userID, workspaceID int) ([]*model.LLMConfig, error) {
+	if err := s.authorizeWorkspace(userID, workspaceID, model.RoleViewer); err != nil {
+		return nil, err
+	}
+	owner := s.workspaces.Role(userID, workspaceID) == model.RoleOwner
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	configs := make([]*model.LLMConfig, 0, len(s.wsLLMConfigs[workspaceID]))
+	for _, config := range s.wsLLMConfigs[workspaceID] {
+		copied := *config
+		if !owner {
+			copied.APIKey = maskAPIKey(copied.APIKey)
+		}
+		configs = append(configs, &copied)
+	}
+	return configs, nil
+}
+
+// GetWorkspaceUsage 获取团队空间本月的LLM使用量和预算，需要是空间成员
+func (s *AnalysisService) GetWorkspaceUsage(userID, workspaceID int) (*model.UsageResponse, error) {
+	if err := s.authorizeWorkspace(userID, workspaceID, model.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	response := &model.UsageResponse{
+		TokenBudget: s.workspaces.TokenBudget(workspaceID),
+		Usage:       s.workspaceUsage(workspaceID, monthStart(time.Now())),
+	}
+	for _, usage := range response.Usage {
+		response.TotalTokens += usage.Tokens
+		response.TotalCost += usage.Cost
+	}
+	return response, nil
+}
+
+// authorizeWorkspace 检查用户在团队空间中的角色
+func (s *AnalysisService) authorizeWorkspace(userID, workspaceID int, minRole string) error {
+	if s.workspaces == nil {
+		return errors.New("workspaces are not configured")
+	}
+	return s.workspaces.Authorize(userID, workspaceID, minRole)
+}
+
+// session 获取会话并检查权限：个人会话只有创建者可以访问，团队空间的会话需要不低于 minRole 的角色
+func (s *AnalysisService) session(userID, sessionID int, minRole string) (*model.Session, error) {
+	s.mu.Lock()
+	session, exists := s.sessions[sessionID]
+	s.mu.Unlock()
+	if !exists {
+		return nil, errors.New("session not found")
+	}
+
+	if session.WorkspaceID != 0 {
+		if err := s.authorizeWorkspace(userID, session.WorkspaceID, minRole); err != nil {
+			return nil, err
+		}
+		return session, nil
+	}
+	if session.UserID != userID {
+		return nil, errors.New("permission denied")
+	}
+	return session, nil
+}
+
+// sessionWorkspace 返回会话所属的团队空间，会话不存在或无权在其中提问时为0
+func (s *AnalysisService) sessionWorkspace(userID, sessionID int) int {
+	session, err := s.session(userID, sessionID, model.RoleEditor)
+	if err != nil {
+		return 0
+	}
+	return session.WorkspaceID
+}
+
+// checkBudget 检查团队空间本月的LLM使用量是否已达到预算
+func (s *AnalysisService) checkBudget(workspaceID int) error {
+	if workspaceID == 0 || s.workspaces == nil {
+		return nil
+	}
+	budget := s.workspaces.TokenBudget(workspaceID)
+	if budget <= 0 {
+		return nil
+	}
+
+	used := 0
+	for _, usage := range s.workspaceUsage(workspaceID, monthStart(time.Now())) {
+		used += usage.Tokens
+	}
+	if used >= budget {
+		return errors.New("workspace LLM budget exceeded")
+	}
+	return nil
+}
+
+// recordUsage 记录一次LLM调用的使用量
+func (s *AnalysisService) recordUsage(userID, workspaceID int, config *model.LLMConfig, tokens int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usage[userID] = append(s.usage[userID], &model.Usage{
+		ID:          s.nextUsageID,
+		UserID:      userID,
+		WorkspaceID: workspaceID,
+		Provider:    config.Provider,
+		Model:       config.Model,
+		Tokens:      tokens,
+		CreatedAt:   time.Now(),
+	})
+	s.nextUsageID++
+}
+
+// workspaceUsage 返回团队空间自 since 起的使用记录
+func (s *AnalysisService) workspaceUsage(workspaceID int, since time.Time) []*model.Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	usages := make([]*model.Usage, 0)
+	for _, userUsage := range s.usage {
+		for _, usage := range userUsage {
+			if usage.WorkspaceID == workspaceID && !usage.CreatedAt.Before(since) {
+				usages = append(usages, usage)
+			}
+		}
+	}
+	return usages
+}
+
+// monthStart 返回 t 所在月份的第一天零点
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// estimateTokens 估算文本的token数：ASCII字符约4个一个token，其他字符（如中文）每个字符一个token
+func estimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// maskAPIKey 只保留 API Key 的最后4位
+func maskAPIKey(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
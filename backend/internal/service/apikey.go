@@ -0,0 +1,256 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"smart-analysis/internal/auth"
+	"smart-analysis/internal/model"
+)
+
+// API Key 每分钟请求数上限
+const (
+	defaultAPIKeyRateLimit = 60
+	maxAPIKeyRateLimit     = 6000
+)
+
+// apiKeyPrefixLength 返回给客户端用于识别 Key 的前缀长度
+const apiKeyPrefixLength = 10
+
+// rateWindow API Key 当前一分钟内的请求数
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// APIKeyService 管理用于程序访问的 API Key。个人 Key 以创建者的身份访问；
+// 服务 Key 属于团队空间，以创建时生成的服务账号身份访问，撤销后服务账号离开团队空间
+type APIKeyService struct {
+	users      *UserService
+	workspaces *WorkspaceService
+
+	mu            sync.Mutex
+	keys          map[int]*model.APIKey
+	byHash        map[string]*model.APIKey
+	windows       map[int]*rateWindow
+	nextID        int
+	now           func() time.Time
+	generateToken func() (string, error)
+}
+
+// NewAPIKeyService 创建 API Key 服务
+func NewAPIKeyService(users *UserService) *APIKeyService {
+	return &APIKeyService{
+		users:         users,
+		keys:          make(map[int]*model.APIKey),
+		byHash:        make(map[string]*model.APIKey),
+		windows:       make(map[int]*rateWindow),
+		nextID:        1,
+		now:           time.Now,
+		generateToken: invitationToken,
+	}
+}
+
+// SetWorkspaces 设置团队空间服务，用于创建服务 Key
+func (s *APIKeyService) SetWorkspaces(workspaces *WorkspaceService) {
+	s.workspaces = workspaces
+}
+
+// Create 创建 API Key，返回的完整 Key 只在创建时可见
+func (s *APIKeyService) Create(userID int, req *model.APIKeyRequest) (*model.APIKeyResponse, error) {
+	rateLimit := req.RateLimit
+	if rateLimit == 0 {
+		rateLimit = defaultAPIKeyRateLimit
+	}
+	if rateLimit > maxAPIKeyRateLimit {
+		return nil, errors.New("rate limit is too high")
+	}
+	keyType := req.Type
+	if keyType == "" {
+		keyType = model.APIKeyPersonal
+	}
+
+	creator, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if creator.IsService {
+		return nil, errors.New("service accounts cannot create API keys")
+	}
+	principal := creator
+	if keyType == model.APIKeyService {
+		if principal, err = s.serviceAccount(userID, req); err != nil {
+			return nil, err
+		}
+	} else if req.WorkspaceID != 0 {
+		return nil, errors.New("personal API keys cannot be bound to a workspace")
+	}
+
+	token, err := s.generateToken()
+	if err != nil {
+		return nil, err
+	}
+	raw := model.APIKeyPrefix + token
+	now := s.now()
+	key := &model.APIKey{
+		UserID:        userID,
+		PrincipalID:   principal.ID,
+		PrincipalName: principal.Username,
+		WorkspaceID:   req.WorkspaceID,
+		Type:          keyType,
+		Name:          req.Name,
+		Prefix:        raw[:apiKeyPrefixLength],
+		KeyHash:       hashSecret(raw),
+		Scopes:        append([]string(nil), req.Scopes...),
+		RateLimit:     rateLimit,
+		CreatedAt:     now,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key.ID = s.nextID
+	s.nextID++
+	s.keys[key.ID] = key
+	s.byHash[key.KeyHash] = key
+
+	copied := *key
+	return &model.APIKeyResponse{APIKey: &copied, Key: raw}, nil
+}
+
+// List 返回用户创建的 Key，以及用户作为所有者的团队空间的服务 Key
+func (s *APIKeyService) List(userID int) []*model.APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]*model.APIKey, 0)
+	for _, key := range s.keys {
+		if s.canManage(userID, key) {
+			copied := *key
+			keys = append(keys, &copied)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// Revoke 撤销 API Key，服务 Key 的服务账号同时离开团队空间
+func (s *APIKeyService) Revoke(userID, keyID int) error {
+	s.mu.Lock()
+	key, exists := s.keys[keyID]
+	if !exists || !s.canManage(userID, key) {
+		s.mu.Unlock()
+		return errors.New("API key not found")
+	}
+	if key.RevokedAt != nil {
+		s.mu.Unlock()
+		return errors.New("API key already revoked")
+	}
+	now := s.now()
+	key.RevokedAt = &now
+	delete(s.byHash, key.KeyHash)
+	delete(s.windows, key.ID)
+	s.mu.Unlock()
+
+	// 服务账号自行离开团队空间；已被所有者移出时忽略错误
+	if key.Type == model.APIKeyService && s.workspaces != nil {
+		_ = s.workspaces.RemoveMember(key.PrincipalID, key.WorkspaceID, key.PrincipalID)
+	}
+	return nil
+}
+
+// DeleteUserData 撤销用户创建的全部 Key，用于注销账号
+func (s *APIKeyService) DeleteUserData(userID int) error {
+	s.mu.Lock()
+	var ids []int
+	for id, key := range s.keys {
+		if key.UserID == userID && key.RevokedAt == nil {
+			ids = append(ids, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, id := range ids {
+		if err := s.Revoke(userID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Authenticate 校验 API Key 并计入请求数，超出每分钟请求数上限时返回 auth.ErrRateLimited
+func (s *APIKeyService) Authenticate(raw string) (*model.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, exists := s.byHash[hashSecret(raw)]
+	now := s.now()
+	if !exists || key.RevokedAt != nil {
+		return nil, errors.New("invalid API key")
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, errors.New("API key expired")
+	}
+
+	window, exists := s.windows[key.ID]
+	if !exists || now.Sub(window.start) >= time.Minute {
+		window = &rateWindow{start: now}
+		s.windows[key.ID] = window
+	}
+	if window.count >= key.RateLimit {
+		return nil, auth.ErrRateLimited
+	}
+	window.count++
+	key.RequestCount++
+	key.LastUsedAt = &now
+
+	copied := *key
+	return &copied, nil
+}
+
+// serviceAccount 为服务 Key 创建服务账号并加入团队空间。只需要读取文件时为 viewer，否则为 editor
+func (s *APIKeyService) serviceAccount(userID int, req *model.APIKeyRequest) (*model.User, error) {
+	if s.workspaces == nil || req.WorkspaceID == 0 {
+		return nil, errors.New("service API keys require a workspace")
+	}
+	if err := s.workspaces.Authorize(userID, req.WorkspaceID, model.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	role := model.RoleViewer
+	for _, scope := range req.Scopes {
+		if scope != model.ScopeFilesRead {
+			role = model.RoleEditor
+		}
+	}
+	account, err := s.users.CreateServiceAccount(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.workspaces.AddMember(req.WorkspaceID, account.ID, role); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// canManage 判断用户能否查看和撤销 Key：创建者，或服务 Key 所属团队空间的所有者。调用方需持有锁
+func (s *APIKeyService) canManage(userID int, key *model.APIKey) bool {
+	if key.UserID == userID {
+		return true
+	}
+	return key.Type == model.APIKeyService && s.workspaces != nil &&
+		s.workspaces.Role(userID, key.WorkspaceID) == model.RoleOwner
+}
+
+// hashSecret 返回 API Key、邮件令牌等凭据的哈希，服务端不保存原文
+func hashSecret(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"smart-analysis/internal/auth"
+	"smart-analysis/internal/model"
+)
+
+func TestAPIKeyService(t *testing.T) {
+	users := NewUserService()
+	alice, _ := users.Register(&model.RegisterRequest{Username: "alice", Email: "alice@example.com", Password: "secret1"})
+	bob, _ := users.Register(&model.RegisterRequest{Username: "bob", Email: "bob@example.com", Password: "secret1"})
+	workspaces := NewWorkspaceService(users)
+	org, _ := workspaces.CreateOrganization(alice.ID, &model.OrganizationRequest{Name: "Corp"})
+	ws, _ := workspaces.CreateWorkspace(alice.ID, org.ID, &model.WorkspaceRequest{Name: "Analytics"})
+
+	service := NewAPIKeyService(users)
+	service.SetWorkspaces(workspaces)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	// 个人 Key 以创建者身份访问，完整的 Key 只在创建时返回
+	personal, err := service.Create(alice.ID, &model.APIKeyRequest{Name: "script", Scopes: []string{model.ScopeFilesRead}, RateLimit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := service.Authenticate(personal.Key)
+	if err != nil || key.PrincipalID != alice.ID || key.RequestCount != 1 {
+		t.Fatalf("个人 Key 校验不正确: %+v %v", key, err)
+	}
+	if listed := service.List(alice.ID); len(listed) != 1 || listed[0].KeyHash == "" || listed[0].Prefix != personal.Key[:len(listed[0].Prefix)] {
+		t.Errorf("Key 列表不正确: %+v", listed)
+	}
+	if _, err := service.Authenticate(personal.Key + "x"); err == nil {
+		t.Error("错误的 Key 应被拒绝")
+	}
+
+	// 超出每分钟请求数上限，下一分钟恢复
+	service.Authenticate(personal.Key)
+	if _, err := service.Authenticate(personal.Key); !errors.Is(err, auth.ErrRateLimited) {
+		t.Errorf("超出请求数上限时应返回 ErrRateLimited: %v", err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := service.Authenticate(personal.Key); err != nil {
+		t.Errorf("下一分钟应恢复: %v", err)
+	}
+
+	// 服务 Key 需要空间所有者权限，以服务账号身份加入团队空间
+	if _, err := service.Create(bob.ID, &model.APIKeyRequest{Name: "etl", Type: model.APIKeyService, WorkspaceID: ws.ID, Scopes: []string{model.ScopeQueriesRun}}); err == nil {
+		t.Error("非所有者不能创建服务 Key")
+	}
+	serviceKey, err := service.Create(alice.ID, &model.APIKeyRequest{Name: "etl", Type: model.APIKeyService, WorkspaceID: ws.ID, Scopes: []string{model.ScopeQueriesRun}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := serviceKey.APIKey.PrincipalID
+	if account == alice.ID || workspaces.Role(account, ws.ID) != model.RoleEditor {
+		t.Errorf("服务账号不正确: principal=%d role=%s", account, workspaces.Role(account, ws.ID))
+	}
+	if _, err := service.Create(account, &model.APIKeyRequest{Name: "nested", Scopes: []string{model.ScopeFilesRead}}); err == nil {
+		t.Error("服务账号不能创建 Key")
+	}
+
+	// 撤销后 Key 失效，服务账号离开团队空间
+	if err := service.Revoke(bob.ID, serviceKey.APIKey.ID); err == nil {
+		t.Error("其他用户不能撤销 Key")
+	}
+	if err := service.Revoke(alice.ID, serviceKey.APIKey.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Authenticate(serviceKey.Key); err == nil {
+		t.Error("撤销的 Key 应被拒绝")
+	}
+	if role := workspaces.Role(account, ws.ID); role != "" {
+		t.Errorf("撤销后服务账号应离开团队空间: %s", role)
+	}
+
+	// 过期的 Key 被拒绝
+	expiring, _ := service.Create(bob.ID, &model.APIKeyRequest{Name: "tmp", Scopes: []string{model.ScopeFilesRead}, ExpiresInDays: 1})
+	now = now.Add(48 * time.Hour)
+	if _, err := service.Authenticate(expiring.Key); err == nil {
+		t.Error("过期的 Key 应被拒绝")
+	}
+}
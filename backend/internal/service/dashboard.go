@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"smart-analysis/internal/model"
+)
+
+// dashboardChartTypes 看板支持的图表类型
+var dashboardChartTypes = map[string]bool{"bar": true, "line": true, "pie": true, "scatter": true, "table": true}
+
+// DashboardService 看板管理。个人看板只有创建者可以访问，
+// 团队空间的看板对空间成员可见，editor 及以上角色可以修改
+type DashboardService struct {
+	workspaces *WorkspaceService
+
+	mu         sync.RWMutex
+	dashboards map[int]*model.Dashboard
+	nextID     int
+}
+
+// NewDashboardService 创建看板服务，workspaces 为空时只能创建个人看板
+func NewDashboardService(workspaces *WorkspaceService) *DashboardService {
+	return &DashboardService{
+		workspaces: workspaces,
+		dashboards: make(map[int]*model.Dashboard),
+		nextID:     1,
+	}
+}
+
+// Create 创建看板，在团队空间中创建需要 editor 权限
+func (s *DashboardService) Create(userID int, req *model.DashboardRequest) (*model.Dashboard, error) {
+	if err := validateDashboard(req); err != nil {
+		return nil, err
+	}
+	if req.WorkspaceID != 0 {
+		if err := s.authorize(userID, req.WorkspaceID, model.RoleEditor); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	dashboard := &model.Dashboard{
+		ID:          s.nextID,
+		UserID:      userID,
+		WorkspaceID: req.WorkspaceID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		Widgets:     append([]model.DashboardWidget{}, req.Widgets...),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	s.dashboards[dashboard.ID] = dashboard
+	s.nextID++
+
+	copied := *dashboard
+	return &copied, nil
+}
+
+// List 返回个人看板（workspaceID 为0）或团队空间的看板，按ID排序
+func (s *DashboardService) List(userID, workspaceID int) ([]*model.Dashboard, error) {
+	if workspaceID != 0 {
+		if err := s.authorize(userID, workspaceID, model.RoleViewer); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	dashboards := make([]*model.Dashboard, 0)
+	for _, dashboard := range s.dashboards {
+		if dashboard.WorkspaceID != workspaceID || workspaceID == 0 && dashboard.UserID != userID {
+			continue
+		}
+		copied := *dashboard
+		dashboards = append(dashboards, &copied)
+	}
+	sort.Slice(dashboards, func(i, j int) bool { return dashboards[i].ID < dashboards[j].ID })
+	return dashboards, nil
+}
+
+// Get 获取看板
+func (s *DashboardService) Get(userID, dashboardID int) (*model.Dashboard, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	dashboard, err := s.dashboard(userID, dashboardID, model.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	copied := *dashboard
+	return &copied, nil
+}
+
+// Update 修改看板的名称、描述和图表，不能修改所属的团队空间
+func (s *DashboardService) Update(userID, dashboardID int, req *model.DashboardRequest) (*model.Dashboard, error) {
+	if err := validateDashboard(req); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dashboard, err := s.dashboard(userID, dashboardID, model.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	dashboard.Name = strings.TrimSpace(req.Name)
+	dashboard.Description = req.Description
+	dashboard.Widgets = append([]model.DashboardWidget{}, req.Widgets...)
+	dashboard.UpdatedAt = time.Now()
+
+	copied := *dashboard
+	return &copied, nil
+}
+
+// Delete 删除看板，团队空间的看板只能由创建者或空间所有者删除
+func (s *DashboardService) Delete(userID, dashboardID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dashboard, err := s.dashboard(userID, dashboardID, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+	if dashboard.UserID != userID && s.workspaces.Role(userID, dashboard.WorkspaceID) != model.RoleOwner {
+		return errors.New("permission denied")
+	}
+	delete(s.dashboards, dashboardID)
+	return nil
+}
+
+// DeleteUserData 删除用户的个人看板，用于注销账号。团队空间的看板属于空间，保留
+func (s *DashboardService) DeleteUserData(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, dashboard := range s.dashboards {
+		if dashboard.UserID == userID && dashboard.WorkspaceID == 0 {
+			delete(s.dashboards, id)
+		}
+	}
+	return nil
+}
+
+// dashboard 查找看板并检查权限，调用方需持有 s.mu
+func (s *DashboardService) dashboard(userID, dashboardID int, minRole string) (*model.Dashboard, error) {
+	dashboard, exists := s.dashboards[dashboardID]
+	if !exists {
+		return nil, errors.New("dashboard not found")
+	}
+	if dashboard.WorkspaceID != 0 {
+		if err := s.authorize(userID, dashboard.WorkspaceID, minRole); err != nil {
+			return nil, err
+		}
+		return dashboard, nil
+	}
+	if dashboard.UserID != userID {
+		return nil, errors.New("permission denied")
+	}
+	return dashboard, nil
+}
+
+// authorize 检查用户在团队空间中的角色
+func (s *DashboardService) authorize(userID, workspaceID int, minRole string) error {
+	if s.workspaces == nil {
+		return errors.New("workspaces are not configured")
+	}
+	return s.workspaces.Authorize(userID, workspaceID, minRole)
+}
+
+// validateDashboard 校验看板名称和图表：图表类型必须受支持，数据来自查询记录或SQL
+func validateDashboard(req *model.DashboardRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return errors.New("dashboard name is required")
+	}
+	for i, widget := range req.Widgets {
+		if !dashboardChartTypes[widget.ChartType] {
+			return fmt.Errorf("widget %d: unsupported chart type %q", i+1, widget.ChartType)
+		}
+		if widget.QueryID == 0 && strings.TrimSpace(widget.SQL) == "" {
+			return fmt.Errorf("widget %d: query_id or sql is required", i+1)
+		}
+	}
+	return nil
+}
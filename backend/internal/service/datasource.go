@@ -0,0 +1,567 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"smart-analysis/internal/audit"
+	"smart-analysis/internal/datasource"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/pii"
+	"smart-analysis/internal/semantic"
+	"smart-analysis/internal/sqlengine"
+	"smart-analysis/internal/types"
+	"smart-analysis/internal/utils"
+)
+
+// 数据源状态
+const (
+	DataSourcePending = "pending"
+	DataSourceReady   = "ready"
+	DataSourceError   = "error"
+)
+
+// 数据源扫描和查询超时
+const (
+	dataSourceScanTimeout  = 2 * time.Minute
+	dataSourceQueryTimeout = 30 * time.Second
+)
+
+// dataSourceCheckInterval 定时扫描的检查周期
+const dataSourceCheckInterval = time.Minute
+
+// DataSourceService 管理用户注册的数据库数据源。密码加密保存，
+// 工具只能通过数据源ID访问当前用户的数据源，拿不到连接信息
+type DataSourceService struct {
+	mu        sync.RWMutex
+	sources   map[int]*model.DataSource
+	conns     map[int]*sql.DB
+	nextID    int
+	secret    string
+	sqliteDir string
+	suggester SemanticSuggester
+	privacy   *PrivacyService
+}
+
+// NewDataSourceService 创建数据源服务，secret 用于加密密码，SQLite 数据源只允许位于 sqliteDir 下
+func NewDataSourceService(secret, sqliteDir string) *DataSourceService {
+	return &DataSourceService{
+		sources:   make(map[int]*model.DataSource),
+		conns:     make(map[int]*sql.DB),
+		nextID:    1,
+		secret:    secret,
+		sqliteDir: sqliteDir,
+		privacy:   NewPrivacyService(""),
+	}
+}
+
+// SetPrivacy 设置脱敏服务，未设置时使用随机哈希密钥的服务
+func (s *DataSourceService) SetPrivacy(privacy *PrivacyService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.privacy = privacy
+}
+
+// Create 注册数据源并立即扫描表结构，连接失败时数据源仍会保存，状态为 error
+func (s *DataSourceService) Create(userID int, req *model.DataSourceRequest) (*model.DataSource, error) {
+	ds := &model.DataSource{UserID: userID}
+	if err := s.apply(ds, req); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ds.Status = DataSourcePending
+	ds.CreatedAt = now
+	ds.UpdatedAt = now
+
+	s.mu.Lock()
+	ds.ID = s.nextID
+	s.nextID++
+	s.sources[ds.ID] = ds
+	s.mu.Unlock()
+
+	s.scan(ds.ID)
+	return s.Get(userID, ds.ID)
+}
+
+// Update 修改数据源配置并重新扫描，Password 为空时保留原密码
+func (s *DataSourceService) Update(userID, id int, req *model.DataSourceRequest) (*model.DataSource, error) {
+	s.mu.Lock()
+	ds, err := s.owned(userID, id)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	updated := *ds
+	if err := s.apply(&updated, req); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	updated.Status = DataSourcePending
+	updated.UpdatedAt = time.Now()
+	s.sources[id] = &updated
+	s.closeConn(id)
+	s.mu.Unlock()
+
+	s.scan(id)
+	return s.Get(userID, id)
+}
+
+// Delete 删除数据源
+func (s *DataSourceService) Delete(userID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.owned(userID, id); err != nil {
+		return err
+	}
+	s.closeConn(id)
+	delete(s.sources, id)
+	return nil
+}
+
+// DeleteUserData 删除用户注册的全部数据源并关闭连接，用于注销账号
+func (s *DataSourceService) DeleteUserData(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, ds := range s.sources {
+		if ds.UserID == userID {
+			s.closeConn(id)
+			delete(s.sources, id)
+		}
+	}
+	return nil
+}
+
+// List 返回用户的数据源，不包含表结构
+func (s *DataSourceService) List(userID int) []*model.DataSource {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sources []*model.DataSource
+	for _, ds := range s.sources {
+		if ds.UserID == userID {
+			item := *ds
+			item.Tables = nil
+			item.Semantic = nil
+			item.Sensitive = nil
+			sources = append(sources, &item)
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].ID < sources[j].ID })
+	return sources
+}
+
+// Get 返回数据源及其表结构
+func (s *DataSourceService) Get(userID, id int) (*model.DataSource, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ds, err := s.owned(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	item := *ds
+	return &item, nil
+}
+
+// Scan 立即重新扫描数据源的表结构
+func (s *DataSourceService) Scan(userID, id int) (*model.DataSource, error) {
+	if _, err := s.Get(userID, id); err != nil {
+		return nil, err
+	}
+	s.scan(id)
+	return s.Get(userID, id)
+}
+
+// Schema 返回数据源最近一次扫描得到的表结构，没有注释的表和列使用语义模型中的业务说明
+func (s *DataSourceService) Schema(userID, id int) ([]types.DataSchema, error) {
+	ds, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if ds.Status != DataSourceReady {
+		return nil, fmt.Errorf("data source is not ready, current status: %s", ds.Status)
+	}
+	return semantic.Annotate(ds.Tables, ds.Semantic), nil
+}
+
+// Query 在数据源上执行只读查询，只能访问扫描到的表。查询结果供智能体放入提示词，
+// 敏感列按策略脱敏，未配置策略的列（如使用别名）按值自动识别
+func (s *DataSourceService) Query(ctx context.Context, userID, id int, query string, maxRows int) (*sqlengine.Result, error) {
+	ds, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if ds.Status != DataSourceReady {
+		return nil, fmt.Errorf("data source is not ready, current status: %s", ds.Status)
+	}
+	tables := make([]string, len(ds.Tables))
+	for i, table := range ds.Tables {
+		tables[i] = table.TableName
+	}
+
+	db, err := s.conn(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dataSourceQueryTimeout)
+	defer cancel()
+	result, err := datasource.Query(ctx, db, ds.Type, tables, query, maxRows)
+	detail := map[string]interface{}{"sql": query}
+	if result != nil {
+		detail["rows"] = len(result.Rows)
+	}
+	audit.Record(ctx, audit.Event{UserID: userID, Action: audit.ActionDataSourceQuery, Resource: fmt.Sprintf("datasource:%d", id), Detail: detail}.WithError(err))
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	privacy := s.privacy
+	s.mu.RUnlock()
+	var masked []pii.Masked
+	result.Columns, result.Rows, masked = privacy.Masker().Values(result.Columns, result.Rows, ds.Sensitive)
+	privacy.Record(userID, fmt.Sprintf("datasource:%d", id), PurposePrompt, masked)
+	return result, nil
+}
+
+// Dialect 返回数据源的SQL方言
+func (s *DataSourceService) Dialect(userID, id int) (string, error) {
+	ds, err := s.Get(userID, id)
+	if err != nil {
+		return "", err
+	}
+	return ds.Type, nil
+}
+
+// SensitiveColumns 返回数据源的敏感列策略
+func (s *DataSourceService) SensitiveColumns(userID, id int) ([]pii.Column, error) {
+	ds, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return append([]pii.Column{}, ds.Sensitive...), nil
+}
+
+// UpdateSensitiveColumns 替换数据源的敏感列策略，每条策略必须指向扫描到的表和列。
+// 重新扫描时保留这些策略，只为新出现的敏感列补充默认策略
+func (s *DataSourceService) UpdateSensitiveColumns(userID, id int, columns []pii.Column) ([]pii.Column, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ds, err := s.owned(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if ds.Status != DataSourceReady {
+		return nil, fmt.Errorf("data source is not ready, current status: %s", ds.Status)
+	}
+	if err := pii.Validate(columns); err != nil {
+		return nil, err
+	}
+	for _, column := range columns {
+		if !hasColumn(ds.Tables, column.Table, column.Name) {
+			return nil, fmt.Errorf("column %s.%s not found", column.Table, column.Name)
+		}
+	}
+
+	updated := *ds
+	updated.Sensitive = append([]pii.Column{}, columns...)
+	updated.UpdatedAt = time.Now()
+	s.sources[id] = &updated
+	return columns, nil
+}
+
+// StartScheduler 按各数据源配置的间隔定时重新扫描，ctx 取消后停止
+func (s *DataSourceService) StartScheduler(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(dataSourceCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				for _, id := range s.dueForScan(now) {
+					s.scan(id)
+				}
+			}
+		}
+	}()
+}
+
+// ForUser 返回只能访问指定用户数据源的视图，供数据库工具使用
+func (s *DataSourceService) ForUser(userID int) *UserDataSources {
+	return &UserDataSources{service: s, userID: userID}
+}
+
+// UserDataSources 限定在单个用户范围内的数据源访问
+type UserDataSources struct {
+	service *DataSourceService
+	userID  int
+}
+
+// Tables 返回数据源的表结构
+func (u *UserDataSources) Tables(ctx context.Context, id int) ([]types.DataSchema, error) {
+	return u.service.Schema(u.userID, id)
+}
+
+// Dialect 返回数据源的SQL方言
+func (u *UserDataSources) Dialect(ctx context.Context, id int) (string, error) {
+	return u.service.Dialect(u.userID, id)
+}
+
+// Semantic 返回数据源的业务语义模型
+func (u *UserDataSources) Semantic(ctx context.Context, id int) (*types.SemanticModel, error) {
+	m, err := u.service.Semantic(u.userID, id)
+	if err != nil || semantic.IsEmpty(m) {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Query 在数据源上执行只读查询
+func (u *UserDataSources) Query(ctx context.Context, id int, query string, maxRows int) (*sqlengine.Result, error) {
+	return u.service.Query(ctx, u.userID, id, query, maxRows)
+}
+
+// dueForScan 返回到达扫描间隔的数据源
+func (s *DataSourceService) dueForScan(now time.Time) []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ids []int
+	for id, ds := range s.sources {
+		if ds.ScanInterval <= 0 {
+			continue
+		}
+		interval := time.Duration(ds.ScanInterval) * time.Minute
+		if ds.LastScannedAt == nil || now.Sub(*ds.LastScannedAt) >= interval {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// scan 扫描数据源表结构并更新状态
+func (s *DataSourceService) scan(id int) {
+	ctx, cancel := context.WithTimeout(context.Background(), dataSourceScanTimeout)
+	defer cancel()
+
+	var tables []types.DataSchema
+	db, err := s.conn(ctx, id)
+	if err == nil {
+		s.mu.RLock()
+		dbType := ""
+		if ds, ok := s.sources[id]; ok {
+			dbType = ds.Type
+		}
+		s.mu.RUnlock()
+		tables, err = datasource.Scan(ctx, db, dbType)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ds, ok := s.sources[id]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	updated := *ds
+	updated.LastScannedAt = &now
+	if err != nil {
+		log.Printf("扫描数据源 %d 失败: %v", id, err)
+		updated.Status = DataSourceError
+		updated.LastError = err.Error()
+		s.closeConn(id)
+	} else {
+		updated.Status = DataSourceReady
+		updated.LastError = ""
+		updated.Tables = tables
+		updated.Sensitive = pii.Reconcile(ds.Sensitive, detectSensitive(tables), tableSet(tables))
+	}
+	s.sources[id] = &updated
+}
+
+// conn 返回数据源的连接池，首次使用时建立连接
+func (s *DataSourceService) conn(ctx context.Context, id int) (*sql.DB, error) {
+	s.mu.RLock()
+	db, ok := s.conns[id]
+	ds, exists := s.sources[id]
+	s.mu.RUnlock()
+	if ok {
+		return db, nil
+	}
+	if !exists {
+		return nil, errors.New("data source not found")
+	}
+
+	password := ""
+	if ds.Password != "" {
+		var err error
+		password, err = utils.DecryptString(s.secret, ds.Password)
+		if err != nil {
+			return nil, fmt.Errorf("解密数据源密码失败: %w", err)
+		}
+	}
+	db, err := datasource.Open(ctx, ds, password)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.conns[id]; ok {
+		db.Close()
+		return existing, nil
+	}
+	if current, ok := s.sources[id]; !ok || current.UpdatedAt != ds.UpdatedAt {
+		// 建立连接期间数据源被修改或删除
+		db.Close()
+		return nil, errors.New("data source changed, please retry")
+	}
+	s.conns[id] = db
+	return db, nil
+}
+
+// closeConn 关闭并移除缓存的连接，调用方需持有写锁
+func (s *DataSourceService) closeConn(id int) {
+	if db, ok := s.conns[id]; ok {
+		db.Close()
+		delete(s.conns, id)
+	}
+}
+
+// owned 返回属于用户的数据源，调用方需持有锁
+func (s *DataSourceService) owned(userID, id int) (*model.DataSource, error) {
+	ds, exists := s.sources[id]
+	if !exists {
+		return nil, errors.New("data source not found")
+	}
+	if ds.UserID != userID {
+		return nil, errors.New("permission denied")
+	}
+	return ds, nil
+}
+
+// apply 校验请求并写入数据源配置，密码加密保存
+func (s *DataSourceService) apply(ds *model.DataSource, req *model.DataSourceRequest) error {
+	database := strings.TrimSpace(req.Database)
+	switch req.Type {
+	case datasource.TypeSQLite:
+		path, err := s.sqlitePath(database)
+		if err != nil {
+			return err
+		}
+		database = path
+	case datasource.TypeMySQL, datasource.TypePostgres:
+		if strings.TrimSpace(req.Host) == "" {
+			return errors.New("host is required")
+		}
+	default:
+		return fmt.Errorf("unsupported data source type: %s", req.Type)
+	}
+
+	if req.Password != "" {
+		encrypted, err := utils.EncryptString(s.secret, req.Password)
+		if err != nil {
+			return err
+		}
+		ds.Password = encrypted
+	} else if req.Type == datasource.TypeSQLite {
+		ds.Password = ""
+	}
+
+	ds.Name = req.Name
+	ds.Type = req.Type
+	ds.Host = strings.TrimSpace(req.Host)
+	ds.Port = req.Port
+	ds.Database = database
+	ds.Username = req.Username
+	ds.SSLMode = req.SSLMode
+	ds.SSLRootCert = req.SSLRootCert
+	ds.ScanInterval = req.ScanInterval
+	return nil
+}
+
+// sqlitePath 解析 SQLite 数据库路径，只允许访问配置目录下的文件
+func (s *DataSourceService) sqlitePath(path string) (string, error) {
+	if s.sqliteDir == "" {
+		return "", errors.New("sqlite data sources are disabled")
+	}
+	base, err := filepath.Abs(s.sqliteDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	path = filepath.Clean(path)
+
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if resolvedBase, err := filepath.EvalSymlinks(base); err == nil {
+		base = resolvedBase
+	}
+	rel, err := filepath.Rel(base, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("sqlite database must be inside %s", s.sqliteDir)
+	}
+	return path, nil
+}
+
+// detectSensitive 根据列名和列注释识别扫描到的敏感列
+func detectSensitive(tables []types.DataSchema) []pii.Column {
+	var columns []pii.Column
+	for _, table := range tables {
+		for _, column := range table.Columns {
+			kind := pii.DetectHeader(column.Name)
+			if kind == "" && column.Description != "" {
+				kind = pii.DetectHeader(column.Description)
+			}
+			if kind != "" {
+				columns = append(columns, pii.Column{Table: table.TableName, Name: column.Name, Kind: kind, Policy: pii.DefaultPolicy})
+			}
+		}
+	}
+	return columns
+}
+
+// tableSet 返回表名集合
+func tableSet(tables []types.DataSchema) map[string]bool {
+	set := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		set[table.TableName] = true
+	}
+	return set
+}
+
+// hasColumn 判断表结构中是否存在指定的表和列
+func hasColumn(tables []types.DataSchema, table, column string) bool {
+	for _, t := range tables {
+		if t.TableName != table {
+			continue
+		}
+		for _, c := range t.Columns {
+			if c.Name == column {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"smart-analysis/internal/model"
+)
+
+func TestDataSourceService(t *testing.T) {
+	dir := t.TempDir()
+	setup, err := sql.Open("sqlite", filepath.Join(dir, "shop.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = setup.Exec(`CREATE TABLE orders (id INTEGER PRIMARY KEY, amount REAL); INSERT INTO orders VALUES (1, 10), (2, 20);`)
+	setup.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewDataSourceService("secret", dir)
+
+	if _, err := s.Create(1, &model.DataSourceRequest{Name: "escape", Type: "sqlite", Database: "../other.db"}); err == nil {
+		t.Error("应拒绝目录之外的SQLite文件")
+	}
+
+	ds, err := s.Create(1, &model.DataSourceRequest{Name: "shop", Type: "sqlite", Database: "shop.db", Password: "p@ss"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Status != DataSourceReady || len(ds.Tables) != 1 || ds.Tables[0].TableName != "orders" {
+		t.Fatalf("扫描结果不正确: %+v", ds)
+	}
+	if ds.Password == "" || ds.Password == "p@ss" {
+		t.Error("密码应加密保存")
+	}
+	if list := s.List(1); len(list) != 1 || list[0].Tables != nil {
+		t.Errorf("列表不应包含表结构: %+v", list)
+	}
+
+	ctx := context.Background()
+	result, err := s.ForUser(1).Query(ctx, ds.ID, "SELECT SUM(amount) FROM orders", 0)
+	if err != nil || result.RowCount != 1 {
+		t.Fatalf("查询失败: %v %+v", err, result)
+	}
+
+	// 其他用户不能访问
+	if _, err := s.ForUser(2).Tables(ctx, ds.ID); err == nil {
+		t.Error("其他用户不应访问该数据源")
+	}
+	if _, err := s.ForUser(2).Query(ctx, ds.ID, "SELECT 1", 0); err == nil {
+		t.Error("其他用户不应查询该数据源")
+	}
+
+	if err := s.Delete(1, ds.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(1, ds.ID); err == nil {
+		t.Error("删除后不应再能获取")
+	}
+}
@@ -1,33 +1,66 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"slices"
+	"smart-analysis/internal/audit"
 	"smart-analysis/internal/model"
+	"smart-analysis/internal/pii"
 	"smart-analysis/internal/utils"
+	"sync"
 	"time"
 )
 
 type FileService struct {
-	files    map[int]*model.File
-	nextID   int
 	basePath string
+	privacy  *PrivacyService
+
+	mu           sync.Mutex          // 保护文件记录及其状态、敏感列策略和团队空间，以及访问策略和数据视图
+	files        map[int]*model.File // 文件记录，返回给调用方的是副本
+	nextID       int
+	policies     map[int]*model.AccessPolicy
+	nextPolicyID int
+	revision     int                       // 访问策略或敏感列策略的版本，变化时重建SQL表和数据视图
+	teams        func(userID int) []string // 返回用户所属的团队，为空时只按用户授权
+	viewPath     string
+	views        map[viewKey]*dataView
+	workspaces   *WorkspaceService // 团队空间服务，为空时文件不能共享到团队空间
 }
 
 func NewFileService() *FileService {
 	return &FileService{
-		files:    make(map[int]*model.File),
-		nextID:   1,
-		basePath: "./uploads",
+		files:        make(map[int]*model.File),
+		nextID:       1,
+		basePath:     "./uploads",
+		privacy:      NewPrivacyService(""),
+		policies:     make(map[int]*model.AccessPolicy),
+		nextPolicyID: 1,
+		viewPath:     filepath.Join(os.TempDir(), "smart-analysis-views"),
+		views:        make(map[viewKey]*dataView),
 	}
 }
 
+// SetPrivacy 设置脱敏服务，未设置时使用随机哈希密钥的服务
+func (s *FileService) SetPrivacy(privacy *PrivacyService) {
+	s.privacy = privacy
+}
+
 // Upload 上传文件
-func (s *FileService) Upload(userID int, fileHeader *multipart.FileHeader) (*model.File, error) {
+func (s *FileService) Upload(userID int, fileHeader *multipart.FileHeader) (file *model.File, err error) {
+	defer func() {
+		event := audit.Event{UserID: userID, Action: audit.ActionFileUpload, Detail: map[string]interface{}{"name": fileHeader.Filename, "size": fileHeader.Size}}
+		if file != nil {
+			event.Resource = fmt.Sprintf("file:%d", file.ID)
+		}
+		audit.Record(context.Background(), event.WithError(err))
+	}()
+
 	// 检查文件大小 500MB
 	if fileHeader.Size > 500*1024*1024 { // 500MB
 		return nil, errors.New("file size exceeds limit")
@@ -66,7 +99,9 @@ func (s *FileService) Upload(userID int, fileHeader *multipart.FileHeader) (*mod
 	}
 
 	// 创建文件记录
-	file := &model.File{
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := &model.File{
 		ID:        s.nextID,
 		UserID:    userID,
 		Name:      filename,
@@ -79,51 +114,82 @@ func (s *FileService) Upload(userID int, fileHeader *multipart.FileHeader) (*mod
 		UpdatedAt: time.Now(),
 	}
 
-	s.files[s.nextID] = file
+	s.files[s.nextID] = stored
 	s.nextID++
 
 	// 异步处理文件（解析数据结构等）
-	go s.processFile(file)
+	copied := *stored
+	go s.processFile(stored)
 
-	return file, nil
+	return &copied, nil
 }
 
 // processFile 处理文件（解析数据结构）
 func (s *FileService) processFile(file *model.File) {
-	file.Status = "processing"
+	s.setFileStatus(file, "processing", nil, nil)
 
-	// 根据文件类型解析
+	// 根据文件类型解析，并识别表格中的敏感列。Excel 文件的每个工作表都作为SQL表提供，分别识别
+	var sheets []string
+	var sensitive []pii.Column
 	switch utils.GetFileType(file.Name) {
 	case utils.CSV:
-		_, err := utils.ParseCSV(file.Path)
+		data, err := utils.ParseCSV(file.Path)
 		if err != nil {
-			file.Status = "error"
+			s.setFileStatus(file, "error", nil, nil)
 			return
 		}
+		sensitive = pii.DetectColumns("", data.Headers, data.Rows)
 	case utils.Excel:
-		_, err := utils.ParseExcel(file.Path)
+		parsed, err := utils.ParseExcelSheets(file.Path)
 		if err != nil {
-			file.Status = "error"
+			s.setFileStatus(file, "error", nil, nil)
 			return
 		}
+		for _, sheet := range parsed {
+			sheets = append(sheets, sheet.Name)
+			sensitive = append(sensitive, pii.DetectColumns(sheet.Name, sheet.Data.Headers, sheet.Data.Rows)...)
+		}
 	case utils.JSON:
 		_, err := utils.ParseJSON(file.Path)
 		if err != nil {
-			file.Status = "error"
+			s.setFileStatus(file, "error", nil, nil)
 			return
 		}
 	}
 
-	file.Status = "ready"
-	file.UpdatedAt = time.Now()
+	s.setFileStatus(file, "ready", sheets, sensitive)
+}
+
+// setFileStatus 更新文件的处理状态，处理完成时同时保存工作表和识别出的敏感列
+func (s *FileService) setFileStatus(file *model.File, status string, sheets []string, sensitive []pii.Column) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file.Status = status
+	if status == "ready" {
+		file.Sheets = sheets
+		file.Sensitive = sensitive
+		file.UpdatedAt = time.Now()
+	}
+}
+
+// firstSheetColumns 返回只读取文件第一个工作表（预览、数据视图）时适用的敏感列策略
+func firstSheetColumns(file *model.File) []pii.Column {
+	sheet := ""
+	if len(file.Sheets) > 0 {
+		sheet = file.Sheets[0]
+	}
+	return pii.ForTable(file.Sensitive, sheet)
 }
 
 // GetFilesByUserID 获取用户的文件列表
 func (s *FileService) GetFilesByUserID(userID int) []*model.File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	var userFiles []*model.File
 	for _, file := range s.files {
 		if file.UserID == userID {
-			userFiles = append(userFiles, file)
+			copied := *file
+			userFiles = append(userFiles, &copied)
 		}
 	}
 	return userFiles
@@ -131,43 +197,69 @@ func (s *FileService) GetFilesByUserID(userID int) []*model.File {
 
 // GetFileByID 根据ID获取文件
 func (s *FileService) GetFileByID(fileID int) (*model.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	file, exists := s.files[fileID]
 	if !exists {
 		return nil, errors.New("file not found")
 	}
-	return file, nil
+	copied := *file
+	return &copied, nil
 }
 
 // DeleteFile 删除文件
 func (s *FileService) DeleteFile(userID, fileID int) error {
-	file, exists := s.files[fileID]
-	if !exists {
-		return errors.New("file not found")
-	}
-
-	if file.UserID != userID {
-		return errors.New("permission denied")
+	file, err := s.ownedFile(userID, fileID)
+	if err != nil {
+		return err
 	}
 
-	// 删除物理文件
-	if err := os.Remove(file.Path); err != nil {
+	// 删除物理文件，文件已不存在时只删除记录
+	if err := os.Remove(file.Path); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
-	// 删除记录
+	// 删除记录及其访问策略
+	s.mu.Lock()
 	delete(s.files, fileID)
+	s.mu.Unlock()
+	s.deletePolicies(fileID)
+	audit.Record(context.Background(), audit.Event{UserID: userID, Action: audit.ActionFileDelete, Resource: fmt.Sprintf("file:%d", fileID), Detail: map[string]interface{}{"name": file.OrigName}})
 	return nil
 }
 
-// PreviewFile 预览文件数据
+// DeleteUserData 删除用户上传的全部文件，包括共享到团队空间的文件，用于注销账号
+func (s *FileService) DeleteUserData(userID int) error {
+	for _, file := range s.GetFilesByUserID(userID) {
+		if err := s.DeleteFile(userID, file.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PreviewFile 预览文件数据，敏感列按策略脱敏后返回
 func (s *FileService) PreviewFile(userID, fileID int, limit int) (interface{}, error) {
-	file, exists := s.files[fileID]
-	if !exists {
-		return nil, errors.New("file not found")
+	return s.FileData(userID, fileID, limit, PurposePreview)
+}
+
+// FileData 读取用户可见的文件数据：按访问策略过滤行和列，再按敏感列策略脱敏，
+// purpose 为数据用途，记录在脱敏审计和审计日志中
+func (s *FileService) FileData(userID, fileID int, limit int, purpose string) (interface{}, error) {
+	data, err := s.fileData(userID, fileID, limit, purpose)
+	action := audit.ActionFileRead
+	if purpose == PurposePreview {
+		action = audit.ActionFilePreview
 	}
+	audit.Record(context.Background(), audit.Event{UserID: userID, Action: action, Resource: fmt.Sprintf("file:%d", fileID), Detail: map[string]interface{}{"purpose": purpose, "limit": limit}}.WithError(err))
+	return data, err
+}
 
-	if file.UserID != userID {
-		return nil, errors.New("permission denied")
+// fileData 读取并处理文件数据
+func (s *FileService) fileData(userID, fileID int, limit int, purpose string) (interface{}, error) {
+	file, restriction, err := s.accessible(userID, fileID)
+	if err != nil {
+		return nil, err
 	}
 
 	if file.Status != "ready" {
@@ -175,30 +267,97 @@ func (s *FileService) PreviewFile(userID, fileID int, limit int) (interface{}, e
 	}
 
 	// 根据文件类型返回预览数据
+	var data *utils.CSVData
 	switch utils.GetFileType(file.Name) {
 	case utils.CSV:
-		data, err := utils.ParseCSV(file.Path)
+		data, err = utils.ParseCSV(file.Path)
+	case utils.Excel:
+		data, err = utils.ParseExcel(file.Path)
+	case utils.JSON:
+		records, err := utils.ParseJSON(file.Path)
 		if err != nil {
 			return nil, err
 		}
-		// 限制返回行数
-		if limit > 0 && len(data.Rows) > limit {
-			data.Rows = data.Rows[:limit]
+		items, ok := records.([]interface{})
+		if !ok {
+			if restriction != nil {
+				return nil, errors.New("access policies only support JSON arrays")
+			}
+			return records, nil
 		}
-		return data, nil
-	case utils.Excel:
-		data, err := utils.ParseExcel(file.Path)
-		if err != nil {
+		items = restriction.Records(items)
+		s.privacy.Record(userID, fmt.Sprintf("file:%d", file.ID), purpose, s.privacy.Masker().Records(items, firstSheetColumns(file)))
+		return items, nil
+	default:
+		return nil, errors.New("unsupported file type")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if restriction != nil {
+		if data.Headers, data.Rows, err = restriction.Strings(data.Headers, data.Rows); err != nil {
 			return nil, err
 		}
-		// 限制返回行数
-		if limit > 0 && len(data.Rows) > limit {
-			data.Rows = data.Rows[:limit]
+		data.Summary["total_rows"] = len(data.Rows)
+	}
+
+	// 限制返回行数
+	if limit > 0 && len(data.Rows) > limit {
+		data.Rows = data.Rows[:limit]
+	}
+
+	var masked []pii.Masked
+	data.Headers, data.Rows, masked = s.privacy.Masker().Strings(data.Headers, data.Rows, firstSheetColumns(file))
+	data.Summary["total_cols"] = len(data.Headers)
+	s.privacy.Record(userID, fmt.Sprintf("file:%d", file.ID), purpose, masked)
+	return data, nil
+}
+
+// SensitiveColumns 返回文件的敏感列策略
+func (s *FileService) SensitiveColumns(userID, fileID int) ([]pii.Column, error) {
+	file, err := s.ownedFile(userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]pii.Column{}, file.Sensitive...), nil
+}
+
+// UpdateSensitiveColumns 替换文件的敏感列策略，未列出的列仍会按值自动识别，
+// 不需要脱敏的列应显式设置为 allow。Excel 文件的策略可以通过 table 指定工作表，为空时适用于所有工作表
+func (s *FileService) UpdateSensitiveColumns(userID, fileID int, columns []pii.Column) ([]pii.Column, error) {
+	file, err := s.ownedFile(userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range columns {
+		if column.Table != "" && !slices.Contains(file.Sheets, column.Table) {
+			return nil, fmt.Errorf("sheet %s not found", column.Table)
 		}
-		return data, nil
-	case utils.JSON:
-		return utils.ParseJSON(file.Path)
 	}
+	if err := pii.Validate(columns); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if file, exists := s.files[file.ID]; exists {
+		file.Sensitive = append([]pii.Column{}, columns...)
+	}
+	s.revision++
+	return columns, nil
+}
 
-	return nil, errors.New("unsupported file type")
+// ownedFile 返回用户自己的文件的副本
+func (s *FileService) ownedFile(userID, fileID int) (*model.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, exists := s.files[fileID]
+	if !exists {
+		return nil, errors.New("file not found")
+	}
+	if file.UserID != userID {
+		return nil, errors.New("permission denied")
+	}
+	copied := *file
+	return &copied, nil
 }
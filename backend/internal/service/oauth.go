@@ -0,0 +1,341 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/oauth"
+)
+
+// oauthStateTTL 发起第三方登录到回调之间允许的最长时间
+const oauthStateTTL = 10 * time.Minute
+
+// oauthProvider 已配置的第三方登录提供商
+type oauthProvider struct {
+	config   oauth.Config
+	provider oauth.Provider
+}
+
+// oauthState 一次进行中的第三方登录，state 只能使用一次
+type oauthState struct {
+	provider   string
+	nonce      string
+	verifier   string // PKCE code_verifier
+	linkUserID int    // 不为0时为已登录用户关联第三方身份，而不是登录
+	expiresAt  time.Time
+}
+
+// OAuthService 第三方登录（OAuth2/OIDC）。回调时按第三方身份查找关联的账号，
+// 没有关联时按已验证的邮箱关联已有账号，或按提供商配置自动创建账号并加入团队空间
+type OAuthService struct {
+	users      *UserService
+	workspaces *WorkspaceService
+
+	mu            sync.Mutex
+	providers     map[string]*oauthProvider
+	states        map[string]*oauthState
+	identities    map[int]*model.ExternalIdentity
+	nextID        int
+	now           func() time.Time
+	generateToken func() (string, error)
+}
+
+// NewOAuthService 创建第三方登录服务
+func NewOAuthService(users *UserService) *OAuthService {
+	return &OAuthService{
+		users:         users,
+		providers:     make(map[string]*oauthProvider),
+		states:        make(map[string]*oauthState),
+		identities:    make(map[int]*model.ExternalIdentity),
+		nextID:        1,
+		now:           time.Now,
+		generateToken: invitationToken,
+	}
+}
+
+// SetWorkspaces 设置团队空间服务，自动创建的账号按提供商配置加入团队空间
+func (s *OAuthService) SetWorkspaces(workspaces *WorkspaceService) {
+	s.workspaces = workspaces
+}
+
+// AddProvider 添加第三方登录提供商
+func (s *OAuthService) AddProvider(cfg oauth.Config) error {
+	if cfg.WorkspaceID != 0 {
+		if cfg.WorkspaceRole == "" {
+			cfg.WorkspaceRole = model.RoleViewer
+		}
+		if _, valid := roleLevels[cfg.WorkspaceRole]; !valid {
+			return fmt.Errorf("invalid workspace role for provider %s: %s", cfg.Name, cfg.WorkspaceRole)
+		}
+	}
+	provider, err := oauth.New(cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.providers[cfg.Name]; exists {
+		return fmt.Errorf("duplicate OAuth provider: %s", cfg.Name)
+	}
+	s.providers[cfg.Name] = &oauthProvider{config: cfg, provider: provider}
+	return nil
+}
+
+// Providers 返回可用的第三方登录提供商
+func (s *OAuthService) Providers() []model.OAuthProvider {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	providers := make([]model.OAuthProvider, 0, len(s.providers))
+	for _, p := range s.providers {
+		displayName := p.config.DisplayName
+		if displayName == "" {
+			displayName = p.config.Name
+		}
+		providers = append(providers, model.OAuthProvider{Name: p.config.Name, Type: p.config.Type, DisplayName: displayName})
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name < providers[j].Name })
+	return providers
+}
+
+// Authorize 发起第三方登录，返回授权页地址和 state。linkUserID 不为0时回调会把第三方身份关联到该用户
+func (s *OAuthService) Authorize(ctx context.Context, providerName string, linkUserID int) (*model.OAuthAuthorizeResponse, error) {
+	p, err := s.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	var values [3]string
+	for i := range values {
+		if values[i], err = s.generateToken(); err != nil {
+			return nil, err
+		}
+	}
+	state := &oauthState{
+		provider:   providerName,
+		nonce:      values[1],
+		verifier:   values[2],
+		linkUserID: linkUserID,
+		expiresAt:  s.now().Add(oauthStateTTL),
+	}
+	authURL, err := p.provider.AuthCodeURL(ctx, values[0], state.nonce, state.verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneStates()
+	s.states[values[0]] = state
+	return &model.OAuthAuthorizeResponse{AuthURL: authURL, State: values[0]}, nil
+}
+
+// Callback 完成第三方登录，返回登录的用户。callerID 为回调请求已认证的用户，未认证时为0；
+// 关联账号的 state 只能由发起关联的用户完成，否则拿到链接的人可以把自己的第三方身份关联到他人账号
+func (s *OAuthService) Callback(ctx context.Context, providerName string, req *model.OAuthCallbackRequest, callerID int) (*model.User, error) {
+	p, err := s.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	state, exists := s.states[req.State]
+	delete(s.states, req.State)
+	s.mu.Unlock()
+	if !exists || state.provider != providerName || !s.now().Before(state.expiresAt) {
+		return nil, errors.New("invalid or expired login state")
+	}
+	if state.linkUserID != 0 && state.linkUserID != callerID {
+		return nil, errors.New("account linking must be completed by the user who started it")
+	}
+
+	identity, err := p.provider.Exchange(ctx, req.Code, state.nonce, state.verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	linked := s.identity(providerName, identity.Subject)
+	if state.linkUserID != 0 {
+		return s.link(state.linkUserID, linked, identity)
+	}
+	if linked != nil {
+		user, err := s.users.GetUserByID(linked.UserID)
+		if err != nil {
+			return nil, err
+		}
+		s.touch(linked, identity)
+		return user, nil
+	}
+
+	// 只有提供商和本系统都验证过的邮箱才能关联到已有账号，否则任何人都可以用他人的邮箱注册第三方账号，
+	// 或先用他人的邮箱注册本系统账号，来接管账号
+	if identity.Email != "" {
+		if user, err := s.users.GetUserByEmail(identity.Email); err == nil {
+			if !identity.EmailVerified || !user.EmailVerified {
+				return nil, errors.New("email already registered, sign in with password and link this account")
+			}
+			return s.link(user.ID, nil, identity)
+		}
+	}
+	if !p.config.AutoProvision {
+		return nil, errors.New("no account is linked to this identity")
+	}
+	return s.provision(p.config, identity)
+}
+
+// Identities 返回用户关联的第三方身份
+func (s *OAuthService) Identities(userID int) []*model.ExternalIdentity {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var identities []*model.ExternalIdentity
+	for _, identity := range s.identities {
+		if identity.UserID == userID {
+			copied := *identity
+			identities = append(identities, &copied)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].ID < identities[j].ID })
+	return identities
+}
+
+// Unlink 取消关联用户在某个提供商的第三方身份
+func (s *OAuthService) Unlink(userID int, providerName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, identity := range s.identities {
+		if identity.UserID == userID && identity.Provider == providerName {
+			delete(s.identities, id)
+			return nil
+		}
+	}
+	return errors.New("identity not found")
+}
+
+// DeleteUserData 删除用户关联的全部第三方身份，用于注销账号
+func (s *OAuthService) DeleteUserData(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, identity := range s.identities {
+		if identity.UserID == userID {
+			delete(s.identities, id)
+		}
+	}
+	return nil
+}
+
+// provider 按名称查找提供商
+func (s *OAuthService) provider(name string) (*oauthProvider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, exists := s.providers[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown OAuth provider: %s", name)
+	}
+	return p, nil
+}
+
+// link 将第三方身份关联到用户，每个用户在每个提供商只能关联一个身份，调用方需持有锁
+func (s *OAuthService) link(userID int, linked *model.ExternalIdentity, identity *oauth.Identity) (*model.User, error) {
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if linked != nil {
+		if linked.UserID != userID {
+			return nil, errors.New("identity is already linked to another account")
+		}
+		s.touch(linked, identity)
+		return user, nil
+	}
+	for _, existing := range s.identities {
+		if existing.UserID == userID && existing.Provider == identity.Provider {
+			return nil, fmt.Errorf("account is already linked to another %s identity", identity.Provider)
+		}
+	}
+
+	now := s.now()
+	s.identities[s.nextID] = &model.ExternalIdentity{
+		ID:          s.nextID,
+		UserID:      userID,
+		Provider:    identity.Provider,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		Name:        identity.Name,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	}
+	s.nextID++
+	return user, nil
+}
+
+// provision 为第三方身份创建账号，并按配置加入团队空间，调用方需持有锁
+func (s *OAuthService) provision(cfg oauth.Config, identity *oauth.Identity) (*model.User, error) {
+	if len(cfg.AllowedDomains) > 0 {
+		_, domain, _ := strings.Cut(identity.Email, "@")
+		allowed := false
+		for _, d := range cfg.AllowedDomains {
+			allowed = allowed || (identity.EmailVerified && strings.EqualFold(domain, d))
+		}
+		if !allowed {
+			return nil, errors.New("email domain is not allowed to sign up")
+		}
+	}
+
+	email := identity.Email
+	if !identity.EmailVerified {
+		// 未验证的邮箱不保存为账号邮箱，避免占用他人的邮箱
+		email = ""
+	}
+	user, err := s.users.CreateExternalUser(identity.Name, email)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.link(user.ID, nil, identity); err != nil {
+		return nil, err
+	}
+	if cfg.WorkspaceID != 0 && s.workspaces != nil {
+		if _, err := s.workspaces.AddMember(cfg.WorkspaceID, user.ID, cfg.WorkspaceRole); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// identity 按提供商和用户标识查找已关联的身份，调用方需持有锁
+func (s *OAuthService) identity(providerName, subject string) *model.ExternalIdentity {
+	for _, identity := range s.identities {
+		if identity.Provider == providerName && identity.Subject == subject {
+			return identity
+		}
+	}
+	return nil
+}
+
+// touch 更新身份的最近登录时间和资料，调用方需持有锁
+func (s *OAuthService) touch(linked *model.ExternalIdentity, identity *oauth.Identity) {
+	linked.LastLoginAt = s.now()
+	linked.Email = identity.Email
+	linked.Name = identity.Name
+}
+
+// pruneStates 清理过期的登录状态，调用方需持有锁
+func (s *OAuthService) pruneStates() {
+	now := s.now()
+	for key, state := range s.states {
+		if !now.Before(state.expiresAt) {
+			delete(s.states, key)
+		}
+	}
+}
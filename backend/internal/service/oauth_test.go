@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/oauth"
+	"smart-analysis/internal/oauth/oauthtest"
+)
+
+func TestOAuthService_LinkAndProvision(t *testing.T) {
+	server, err := oauthtest.NewServer("client", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	users := NewUserService()
+	alice, _ := users.Register(&model.RegisterRequest{Username: "alice", Email: "alice@corp.example", Password: "secret1"})
+	unverified, _ := users.Register(&model.RegisterRequest{Username: "dave", Email: "dave@corp.example", Password: "secret1"})
+	workspaces := NewWorkspaceService(users)
+	org, _ := workspaces.CreateOrganization(alice.ID, &model.OrganizationRequest{Name: "Corp"})
+	ws, _ := workspaces.CreateWorkspace(alice.ID, org.ID, &model.WorkspaceRequest{Name: "All hands"})
+
+	service := NewOAuthService(users)
+	service.SetWorkspaces(workspaces)
+	err = service.AddProvider(oauth.Config{
+		Name:           "corp",
+		Type:           oauth.TypeOIDC,
+		ClientID:       "client",
+		ClientSecret:   "secret",
+		RedirectURL:    "http://localhost:3000/oauth/callback",
+		Issuer:         server.Issuer(),
+		AutoProvision:  true,
+		AllowedDomains: []string{"corp.example"},
+		WorkspaceID:    ws.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	login := func(linkUserID int, user oauthtest.User) (*model.User, error) {
+		resp, err := service.Authorize(ctx, "corp", linkUserID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, state, err := server.Authorize(resp.AuthURL, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return service.Callback(ctx, "corp", &model.OAuthCallbackRequest{Code: code, State: state}, linkUserID)
+	}
+
+	// 账号邮箱未验证时不能通过邮箱关联，避免先用他人邮箱注册的账号接管第三方身份
+	if _, err := login(0, oauthtest.User{Subject: "s-dave", Email: unverified.Email, EmailVerified: true, Name: "Dave"}); err == nil {
+		t.Error("未验证邮箱的账号不应被关联")
+	}
+
+	// 已验证的邮箱关联到已有账号，之后按第三方身份登录
+	users.SetVerifiedEmail(alice.ID, alice.Email)
+	user, err := login(0, oauthtest.User{Subject: "s-alice", Email: "alice@corp.example", EmailVerified: true, Name: "Alice"})
+	if err != nil || user.ID != alice.ID {
+		t.Fatalf("应关联到已有账号: %v %v", user, err)
+	}
+	if user, err := login(0, oauthtest.User{Subject: "s-alice", Name: "Alice"}); err != nil || user.ID != alice.ID {
+		t.Errorf("关联后应按身份登录: %v %v", user, err)
+	}
+
+	// 未验证的邮箱不能接管已有账号
+	if _, err := login(0, oauthtest.User{Subject: "s-mallory", Email: "alice@corp.example", Name: "Mallory"}); err == nil {
+		t.Error("未验证的邮箱不应关联到已有账号")
+	}
+
+	// 首次登录自动创建账号并加入团队空间，只允许配置的邮箱域名
+	bob, err := login(0, oauthtest.User{Subject: "s-bob", Email: "bob@corp.example", EmailVerified: true, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bob.Username != "alice-2" || bob.Email != "bob@corp.example" || workspaces.Role(bob.ID, ws.ID) != model.RoleViewer {
+		t.Errorf("自动创建的账号不正确: %+v role=%s", bob, workspaces.Role(bob.ID, ws.ID))
+	}
+	if _, err := login(0, oauthtest.User{Subject: "s-eve", Email: "eve@other.example", EmailVerified: true, Name: "Eve"}); err == nil {
+		t.Error("不允许的邮箱域名不应自动创建账号")
+	}
+
+	// 已登录用户关联第三方身份；已关联到其他账号的身份不能再关联
+	carol, _ := users.Register(&model.RegisterRequest{Username: "carol", Email: "carol@example.com", Password: "secret1"})
+	if user, err := login(carol.ID, oauthtest.User{Subject: "s-carol", Name: "Carol"}); err != nil || user.ID != carol.ID {
+		t.Fatalf("关联身份失败: %v %v", user, err)
+	}
+	if _, err := login(carol.ID, oauthtest.User{Subject: "s-bob", Name: "Bob"}); err == nil {
+		t.Error("已关联到其他账号的身份不能再关联")
+	}
+
+	// 关联账号的回调必须由发起关联的用户完成，匿名或其他用户提交的回调不能把身份关联到该用户
+	for _, callerID := range []int{0, bob.ID} {
+		resp, _ := service.Authorize(ctx, "corp", carol.ID)
+		code, state, _ := server.Authorize(resp.AuthURL, oauthtest.User{Subject: "s-mallory", Name: "Mallory"})
+		if _, err := service.Callback(ctx, "corp", &model.OAuthCallbackRequest{Code: code, State: state}, callerID); err == nil {
+			t.Errorf("用户 %d 不应完成他人发起的关联", callerID)
+		}
+	}
+	if identities := service.Identities(carol.ID); len(identities) != 1 || identities[0].Subject != "s-carol" {
+		t.Errorf("关联的身份不正确: %v", identities)
+	}
+
+	// state 只能使用一次
+	resp, _ := service.Authorize(ctx, "corp", 0)
+	code, state, _ := server.Authorize(resp.AuthURL, oauthtest.User{Subject: "s-bob", Name: "Bob"})
+	if _, err := service.Callback(ctx, "corp", &model.OAuthCallbackRequest{Code: code, State: state}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Callback(ctx, "corp", &model.OAuthCallbackRequest{Code: code, State: state}, 0); err == nil {
+		t.Error("state 不能重复使用")
+	}
+
+	if err := service.Unlink(carol.ID, "corp"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := login(0, oauthtest.User{Subject: "s-carol", Name: "Carol"}); err == nil {
+		t.Error("取消关联后没有邮箱的身份不应登录到原账号")
+	}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/scheduler"
+	"smart-analysis/internal/types"
+)
+
+// ErrNoQueryPlan 查询没有关联的执行计划
+var ErrNoQueryPlan = errors.New("no execution plan for this query")
+
+// PlanRunner 恢复和重新执行计划，由多智能体系统实现
+type PlanRunner interface {
+	ResumePlan(ctx context.Context, planID string) (*types.ExecutionPlan, error)
+	RerunTask(ctx context.Context, planID, taskID string, input interface{}) (*types.ExecutionPlan, error)
+}
+
+// SetPlanStore 设置执行计划存储，应与多智能体系统使用同一个存储。
+// 查询记录只保存在内存中，新查询的ID从存储中已有计划的查询ID之后分配，避免重启后关联到旧的计划
+func (s *AnalysisService) SetPlanStore(store types.PlanStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.planStore = store
+	if last := store.LastQueryID(); last >= s.nextQueryID {
+		s.nextQueryID = last + 1
+	}
+}
+
+// SetPlanRunner 设置执行计划的执行器
+func (s *AnalysisService) SetPlanRunner(runner PlanRunner) {
+	s.planRunner = runner
+}
+
+// GetQueryPlan 获取查询的执行计划图
+func (s *AnalysisService) GetQueryPlan(userID, queryID int) (*model.PlanGraphResponse, error) {
+	plan, err := s.queryPlan(userID, queryID)
+	if err != nil {
+		return nil, err
+	}
+	return buildPlanGraph(plan), nil
+}
+
+// ResumeQueryPlan 从中断处继续执行查询的计划
+func (s *AnalysisService) ResumeQueryPlan(ctx context.Context, userID, queryID int) (*model.PlanGraphResponse, error) {
+	plan, err := s.queryPlan(userID, queryID)
+	if err != nil {
+		return nil, err
+	}
+	if s.planRunner == nil {
+		return nil, errors.New("plan execution is not configured")
+	}
+
+	plan, err = s.planRunner.ResumePlan(scheduler.WithUser(ctx, strconv.Itoa(userID)), plan.ID)
+	if plan == nil {
+		return nil, err
+	}
+	return buildPlanGraph(plan), err
+}
+
+// RerunQueryTask 使用新的输入重新执行计划中的单个任务及其下游任务
+func (s *AnalysisService) RerunQueryTask(ctx context.Context, userID, queryID int, taskID string, req *model.RerunTaskRequest) (*model.PlanGraphResponse, error) {
+	plan, err := s.queryPlan(userID, queryID)
+	if err != nil {
+		return nil, err
+	}
+	if s.planRunner == nil {
+		return nil, errors.New("plan execution is not configured")
+	}
+
+	plan, err = s.planRunner.RerunTask(scheduler.WithUser(ctx, strconv.Itoa(userID)), plan.ID, taskID, req.Input)
+	if plan == nil {
+		return nil, err
+	}
+	return buildPlanGraph(plan), err
+}
+
+// queryPlan 校验查询归属并获取其执行计划。
+// 重启后查询记录不再存在，此时只按计划中保存的用户校验，以便恢复中断的计划
+func (s *AnalysisService) queryPlan(userID, queryID int) (*types.ExecutionPlan, error) {
+	s.mu.Lock()
+	query, exists := s.queries[queryID]
+	s.mu.Unlock()
+	if exists && query.UserID != userID {
+		return nil, errors.New("permission denied")
+	}
+
+	plan, err := s.planStore.GetPlanByQuery(queryID)
+	if errors.Is(err, types.ErrPlanNotFound) {
+		if !exists {
+			return nil, errors.New("query not found")
+		}
+		return nil, ErrNoQueryPlan
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if plan.UserID != userID {
+		if !exists {
+			return nil, errors.New("query not found")
+		}
+		return nil, errors.New("permission denied")
+	}
+	return plan, nil
+}
+
+// buildPlanGraph 将执行计划转换为节点和依赖边
+func buildPlanGraph(plan *types.ExecutionPlan) *model.PlanGraphResponse {
+	graph := &model.PlanGraphResponse{
+		PlanID:        plan.ID,
+		QueryID:       plan.QueryID,
+		FailurePolicy: plan.FailurePolicy,
+		Nodes:         make([]model.PlanNode, 0, len(plan.Tasks)),
+		Edges:         []model.PlanEdge{},
+		UpdatedAt:     plan.UpdatedAt,
+	}
+
+	counts := make(map[types.TaskStatus]int)
+	for _, task := range plan.Tasks {
+		graph.Nodes = append(graph.Nodes, model.PlanNode{
+			ID:          task.ID,
+			Type:        task.Type,
+			Description: task.Description,
+			AgentType:   task.AgentType,
+			Status:      task.Status,
+			Input:       task.Input,
+			Result:      task.Result,
+		})
+		for _, depID := range task.Dependencies {
+			graph.Edges = append(graph.Edges, model.PlanEdge{From: depID, To: task.ID})
+		}
+
+		// 被重新规划替代的任务不影响整体状态
+		if task.IsReplaced() {
+			continue
+		}
+		counts[task.Status]++
+	}
+
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].To != graph.Edges[j].To {
+			return graph.Edges[i].To < graph.Edges[j].To
+		}
+		return graph.Edges[i].From < graph.Edges[j].From
+	})
+
+	switch {
+	case counts[types.TaskStatusRunning] > 0:
+		graph.Status = "running"
+	case counts[types.TaskStatusFailed]+counts[types.TaskStatusSkipped]+counts[types.TaskStatusCancelled] > 0:
+		graph.Status = "failed"
+	case counts[types.TaskStatusPending] > 0 || counts[""] > 0:
+		graph.Status = "pending"
+	default:
+		graph.Status = "completed"
+	}
+
+	return graph
+}
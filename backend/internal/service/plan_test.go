@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"smart-analysis/internal/manager"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/planstore"
+	"smart-analysis/internal/utils/sanbox"
+)
+
+func TestAnalysisService_QueryPlan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sales.csv")
+	if err := os.WriteFile(path, []byte("region,sales\n华东,100\n华北,50\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := NewFileService()
+	files.SetViewPath(t.TempDir())
+	file := &model.File{ID: 1, UserID: 1, Name: "sales.csv", OrigName: "sales.csv", Path: path}
+	files.files[file.ID] = file
+	files.processFile(file)
+
+	// 与 main 相同的方式构建多智能体系统，共用计划存储
+	analysis := NewAnalysisService()
+	store := planstore.NewMemoryStore()
+	analysis.SetPlanStore(store)
+	sandbox := sanbox.NewPythonSandbox("")
+	sandbox.SetPythonPath(filepath.Join(t.TempDir(), "python"))
+	system, err := manager.NewAgentSystemBuilder().
+		WithChatModel(analysis.ChatModel()).
+		WithPythonSandbox(sandbox).
+		WithPlanStore(store).
+		WithDatasets(files.Datasets()).
+		Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	analysis.SetAgentSystem(system)
+	analysis.SetPlanRunner(system)
+
+	session, _ := analysis.CreateSession(1, &model.CreateSessionRequest{Name: "s"})
+	if _, err := analysis.ConfigLLM(1, &model.LLMConfigRequest{Provider: "mock", APIKey: "sk-test", Model: "m"}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := analysis.Query(context.Background(), 1, &model.QueryRequest{SessionID: session.ID, Question: "各地区销售额", FileID: &file.ID}, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 查询的执行计划保存在计划存储中，可以查看和恢复
+	graph, err := analysis.GetQueryPlan(1, resp.QueryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if graph.QueryID != resp.QueryID || len(graph.Nodes) == 0 {
+		t.Errorf("执行计划不正确: %+v", graph)
+	}
+	if analysis.queries[resp.QueryID].PromptVersion != analysis.prompts.Version() {
+		t.Error("多智能体系统回答的查询应记录提示词版本")
+	}
+	if _, err := analysis.GetQueryPlan(2, resp.QueryID); err == nil {
+		t.Error("其他用户不应查看执行计划")
+	}
+	if graph, err := analysis.ResumeQueryPlan(context.Background(), 1, resp.QueryID); graph == nil || graph.PlanID == "" {
+		t.Errorf("恢复执行计划失败: %+v, %v", graph, err)
+	}
+
+	// 模拟重启：查询记录丢失，新查询不复用已有计划的查询ID，计划所有者仍可以恢复执行
+	restarted := NewAnalysisService()
+	restarted.SetPlanStore(store)
+	restarted.SetPlanRunner(system)
+	if restarted.nextQueryID <= resp.QueryID {
+		t.Errorf("重启后的查询ID %d 与已有计划冲突", restarted.nextQueryID)
+	}
+	if graph, err := restarted.ResumeQueryPlan(context.Background(), 1, resp.QueryID); graph == nil || graph.PlanID == "" {
+		t.Errorf("重启后恢复执行计划失败: %+v, %v", graph, err)
+	}
+	if _, err := restarted.GetQueryPlan(2, resp.QueryID); err == nil {
+		t.Error("重启后其他用户不应查看执行计划")
+	}
+
+	// 没有执行计划的查询返回 ErrNoQueryPlan
+	analysis.SetAgentSystem(nil)
+	resp, err = analysis.Query(context.Background(), 1, &model.QueryRequest{SessionID: session.ID, Question: "各地区销售额"}, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := analysis.GetQueryPlan(1, resp.QueryID); !errors.Is(err, ErrNoQueryPlan) {
+		t.Errorf("期望 ErrNoQueryPlan，实际 %v", err)
+	}
+	if version := analysis.queries[resp.QueryID].PromptVersion; version != "" {
+		t.Errorf("直接调用LLM的查询没有使用提示词模板，不应记录版本: %s", version)
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"smart-analysis/internal/config"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/pii"
+)
+
+// 脱敏数据的用途
+const (
+	PurposePreview = "preview" // 文件预览接口
+	PurposeQuery   = "query"   // SQL查询接口
+	PurposePrompt  = "prompt"  // 发送给LLM的提示词
+)
+
+// maxMaskingRecords 保留的脱敏审计记录上限，超出后丢弃最早的记录
+const maxMaskingRecords = 10000
+
+// PrivacyService 敏感数据脱敏和审计，由文件、SQL和数据源服务共享
+type PrivacyService struct {
+	masker  *pii.Masker
+	mu      sync.RWMutex
+	records []*model.MaskingRecord
+	nextID  int
+}
+
+// NewPrivacyService 创建脱敏服务，secret 为哈希脱敏的密钥，为空时使用随机密钥
+func NewPrivacyService(secret string) *PrivacyService {
+	if secret == "" {
+		secret, _ = config.RandomSecret()
+	}
+	return &PrivacyService{
+		masker: pii.NewMasker(secret),
+		nextID: 1,
+	}
+}
+
+// Masker 返回脱敏器
+func (s *PrivacyService) Masker() *pii.Masker {
+	return s.masker
+}
+
+// Record 记录一次脱敏，没有列被处理时不记录
+func (s *PrivacyService) Record(userID int, source, purpose string, columns []pii.Masked) {
+	if len(columns) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, &model.MaskingRecord{
+		ID:        s.nextID,
+		UserID:    userID,
+		Source:    source,
+		Purpose:   purpose,
+		Columns:   columns,
+		CreatedAt: time.Now(),
+	})
+	s.nextID++
+	if len(s.records) > maxMaskingRecords {
+		s.records = s.records[len(s.records)-maxMaskingRecords:]
+	}
+}
+
+// Records 返回用户最近的脱敏记录，按时间倒序，limit <= 0 时返回全部
+func (s *PrivacyService) Records(userID, limit int) []*model.MaskingRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []*model.MaskingRecord
+	for i := len(s.records) - 1; i >= 0; i-- {
+		if s.records[i].UserID != userID {
+			continue
+		}
+		records = append(records, s.records[i])
+		if limit > 0 && len(records) >= limit {
+			break
+		}
+	}
+	return records
+}
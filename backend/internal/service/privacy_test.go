@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/pii"
+	"smart-analysis/internal/utils"
+
+	"github.com/tealeg/xlsx/v3"
+)
+
+func TestFileService_Masking(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "customers.csv")
+	content := "name,联系电话,证件,city,amount\n张三,13812345678,11010519491231002X,上海,100\n李四,13900001111,440301199003071230,北京,50\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	privacy := NewPrivacyService("secret")
+	files := NewFileService()
+	files.SetPrivacy(privacy)
+	file := &model.File{ID: 1, UserID: 1, Name: "customers.csv", OrigName: "customers.csv", Path: path}
+	files.files[file.ID] = file
+	files.processFile(file)
+
+	// 解析时识别出按列名和按值的敏感列，普通的 name 列不视为姓名
+	var detected []string
+	for _, column := range file.Sensitive {
+		detected = append(detected, column.Name+":"+string(column.Kind))
+	}
+	if strings.Join(detected, ",") != "联系电话:phone,证件:id_card" {
+		t.Fatalf("敏感列识别不正确: %v", detected)
+	}
+
+	if _, err := files.UpdateSensitiveColumns(1, 1, []pii.Column{{Name: "证件", Policy: "encrypt"}}); err == nil {
+		t.Error("应拒绝未知策略")
+	}
+	if _, err := files.UpdateSensitiveColumns(2, 1, nil); err == nil {
+		t.Error("其他用户不应修改策略")
+	}
+	if _, err := files.UpdateSensitiveColumns(1, 1, []pii.Column{
+		{Name: "联系电话", Kind: pii.KindPhone, Policy: pii.PolicyMask},
+		{Name: "证件", Kind: pii.KindIDCard, Policy: pii.PolicyDrop},
+		{Name: "name", Kind: pii.KindName, Policy: pii.PolicyHash},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	preview, err := files.PreviewFile(1, 1, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := preview.(*utils.CSVData)
+	if strings.Join(data.Headers, ",") != "name,联系电话,city,amount" || data.Summary["total_cols"] != 4 {
+		t.Errorf("预览表头不正确: %v %v", data.Headers, data.Summary)
+	}
+	if row := data.Rows[0]; !strings.HasPrefix(row[0], "h_") || row[1] != "138****5678" || row[2] != "上海" {
+		t.Errorf("预览数据未脱敏: %v", row)
+	}
+
+	// SQL查询的表在加载时按文件策略脱敏，别名和表达式无法读取原始值
+	sqlService := NewSQLService(files)
+	resp, err := sqlService.Query(1, &model.SQLQueryRequest{SQL: "SELECT 联系电话 AS p, city FROM customers ORDER BY amount DESC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result.Rows[0][0] != "138****5678" || resp.Result.Rows[0][1] != "上海" {
+		t.Errorf("SQL结果未脱敏: %v", resp.Result.Rows)
+	}
+	resp, err = sqlService.Query(1, &model.SQLQueryRequest{SQL: "SELECT name AS c, upper(name) AS u, substr(联系电话, 4, 4) AS s FROM customers ORDER BY amount DESC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row := resp.Result.Rows[0]; !strings.HasPrefix(row[0].(string), "h_") || row[1] != strings.ToUpper(row[0].(string)) || row[2] != "****" {
+		t.Errorf("别名和表达式绕过了脱敏: %v", row)
+	}
+	if _, err := sqlService.Query(1, &model.SQLQueryRequest{SQL: "SELECT 证件 FROM customers"}); err == nil {
+		t.Error("删除的列不应可以查询")
+	}
+
+	records := privacy.Records(1, 0)
+	if len(records) != 2 || records[0].Source != "file:1" || records[0].Purpose != PurposeQuery || records[1].Purpose != PurposePreview {
+		t.Fatalf("审计记录不正确: %+v", records)
+	}
+	if columns := records[1].Columns; len(columns) != 3 || columns[2].Column != "证件" || columns[2].Policy != pii.PolicyDrop || columns[2].Values != 2 {
+		t.Errorf("审计记录的列不正确: %+v", columns)
+	}
+	if len(privacy.Records(2, 0)) != 0 {
+		t.Error("不应返回其他用户的审计记录")
+	}
+}
+
+func TestDataSourceService_Masking(t *testing.T) {
+	dir := t.TempDir()
+	setup, err := sql.Open("sqlite", filepath.Join(dir, "crm.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = setup.Exec(`CREATE TABLE customers (id INTEGER PRIMARY KEY, mobile TEXT, email TEXT, city TEXT);
+		INSERT INTO customers VALUES (1, '13812345678', 'zhang@example.com', '上海');`)
+	setup.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privacy := NewPrivacyService("secret")
+	s := NewDataSourceService("secret", dir)
+	s.SetPrivacy(privacy)
+	ds, err := s.Create(1, &model.DataSourceRequest{Name: "crm", Type: "sqlite", Database: "crm.db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columns, _ := s.SensitiveColumns(1, ds.ID)
+	if len(columns) != 2 || columns[0].Table != "customers" || columns[0].Name != "mobile" || columns[1].Kind != pii.KindEmail {
+		t.Fatalf("扫描识别的敏感列不正确: %+v", columns)
+	}
+
+	if _, err := s.UpdateSensitiveColumns(1, ds.ID, []pii.Column{{Table: "customers", Name: "phone", Policy: pii.PolicyMask}}); err == nil {
+		t.Error("应拒绝不存在的列")
+	}
+	if _, err := s.UpdateSensitiveColumns(1, ds.ID, []pii.Column{{Table: "customers", Name: "mobile", Kind: pii.KindPhone, Policy: pii.PolicyAllow}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新扫描保留手动设置的策略，补充新识别的列
+	if _, err := s.Scan(1, ds.ID); err != nil {
+		t.Fatal(err)
+	}
+	columns, _ = s.SensitiveColumns(1, ds.ID)
+	if len(columns) != 2 || columns[0].Policy != pii.PolicyAllow || columns[1].Name != "email" || columns[1].Policy != pii.PolicyMask {
+		t.Fatalf("重新扫描后的策略不正确: %+v", columns)
+	}
+
+	result, err := s.ForUser(1).Query(context.Background(), ds.ID, "SELECT mobile, email, city FROM customers", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row := result.Rows[0]; row[0] != "13812345678" || row[1] != "z***@example.com" || row[2] != "上海" {
+		t.Errorf("查询结果脱敏不正确: %v", row)
+	}
+	if records := privacy.Records(1, 1); len(records) != 1 || records[0].Source != "datasource:1" || records[0].Purpose != PurposePrompt {
+		t.Errorf("审计记录不正确: %+v", records)
+	}
+}
+
+func TestFileService_ExcelSheetMasking(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.xlsx")
+	book := xlsx.NewFile()
+	for _, sheet := range []struct {
+		name string
+		rows [][]string
+	}{
+		{"orders", [][]string{{"order_id", "备注"}, {"1", "加急"}}},
+		{"contacts", [][]string{{"客户", "手机", "备注"}, {"c1", "13812345678", "老客户"}}},
+	} {
+		s, err := book.AddSheet(sheet.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, values := range sheet.rows {
+			row := s.AddRow()
+			for _, value := range values {
+				row.AddCell().SetString(value)
+			}
+		}
+	}
+	if err := book.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	files := NewFileService()
+	files.SetPrivacy(NewPrivacyService("secret"))
+	file := &model.File{ID: 1, UserID: 1, Name: "book.xlsx", OrigName: "book.xlsx", Path: path}
+	files.files[file.ID] = file
+	files.processFile(file)
+
+	// 每个工作表分别识别敏感列，策略记录所在的工作表
+	if len(file.Sensitive) != 1 || file.Sensitive[0].Table != "contacts" || file.Sensitive[0].Name != "手机" {
+		t.Fatalf("工作表的敏感列识别不正确: %+v", file.Sensitive)
+	}
+
+	// 指定工作表的策略只作用于该工作表
+	if _, err := files.UpdateSensitiveColumns(1, 1, []pii.Column{{Table: "missing", Name: "备注", Policy: pii.PolicyDrop}}); err == nil {
+		t.Error("应拒绝不存在的工作表")
+	}
+	columns, err := files.UpdateSensitiveColumns(1, 1, append(file.Sensitive, pii.Column{Table: "contacts", Name: "备注", Policy: pii.PolicyHash}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if columns[1].Table != "contacts" {
+		t.Errorf("策略的工作表不应被清除: %+v", columns)
+	}
+
+	sqlService := NewSQLService(files)
+	resp, err := sqlService.Query(1, &model.SQLQueryRequest{SQL: "SELECT 手机, 备注 FROM book_contacts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row := resp.Result.Rows[0]; row[0] != "138****5678" || !strings.HasPrefix(row[1].(string), "h_") {
+		t.Errorf("第二个工作表未脱敏: %v", row)
+	}
+	resp, err = sqlService.Query(1, &model.SQLQueryRequest{SQL: "SELECT 备注 FROM book_orders"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result.Rows[0][0] != "加急" {
+		t.Errorf("其他工作表的同名列不应脱敏: %v", resp.Result.Rows)
+	}
+}
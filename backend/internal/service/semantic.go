@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"smart-analysis/internal/semantic"
+	"smart-analysis/internal/types"
+)
+
+// semanticSuggestTimeout 生成语义模型建议的超时时间
+const semanticSuggestTimeout = 2 * time.Minute
+
+// SemanticSuggester 根据表结构生成语义模型建议，由多智能体系统实现
+type SemanticSuggester interface {
+	Suggest(ctx context.Context, tables []types.DataSchema, existing *types.SemanticModel) (*types.SemanticModel, error)
+}
+
+// SetSemanticSuggester 设置语义模型建议的生成器
+func (s *DataSourceService) SetSemanticSuggester(suggester SemanticSuggester) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.suggester = suggester
+}
+
+// Semantic 返回数据源的业务语义模型，未配置时返回空模型
+func (s *DataSourceService) Semantic(userID, id int) (*types.SemanticModel, error) {
+	ds, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return semantic.Copy(ds.Semantic), nil
+}
+
+// UpdateSemantic 校验并保存数据源的业务语义模型，引用的表和列必须存在于最近一次扫描的表结构中
+func (s *DataSourceService) UpdateSemantic(userID, id int, m *types.SemanticModel) (*types.SemanticModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ds, err := s.owned(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if ds.Status != DataSourceReady {
+		return nil, fmt.Errorf("data source is not ready, current status: %s", ds.Status)
+	}
+	if issues := semantic.Validate(m, ds.Tables, ds.Type); len(issues) > 0 {
+		return nil, errors.New("invalid semantic model: " + strings.Join(issues, "; "))
+	}
+
+	updated := *ds
+	updated.Semantic = semantic.Copy(m)
+	updated.UpdatedAt = time.Now()
+	s.sources[id] = &updated
+	return semantic.Copy(m), nil
+}
+
+// SuggestSemantic 用LLM根据表结构生成语义模型建议并与已有模型合并，结果不会自动保存，
+// 确认后通过 UpdateSemantic 保存。已有的条目保持不变，无效的建议会被丢弃
+func (s *DataSourceService) SuggestSemantic(ctx context.Context, userID, id int) (*types.SemanticModel, error) {
+	ds, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if ds.Status != DataSourceReady {
+		return nil, fmt.Errorf("data source is not ready, current status: %s", ds.Status)
+	}
+
+	s.mu.RLock()
+	suggester := s.suggester
+	s.mu.RUnlock()
+	if suggester == nil {
+		return nil, errors.New("semantic suggestion is not configured")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, semanticSuggestTimeout)
+	defer cancel()
+	suggestion, err := suggester.Suggest(ctx, ds.Tables, ds.Semantic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to suggest semantic model: %w", err)
+	}
+	return semantic.Merge(ds.Semantic, semantic.Clean(suggestion, ds.Tables, ds.Type)), nil
+}
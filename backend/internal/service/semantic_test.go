@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/types"
+)
+
+// fakeSuggester 返回固定建议的语义模型生成器
+type fakeSuggester struct {
+	suggestion *types.SemanticModel
+}
+
+func (f *fakeSuggester) Suggest(ctx context.Context, tables []types.DataSchema, existing *types.SemanticModel) (*types.SemanticModel, error) {
+	return f.suggestion, nil
+}
+
+func TestDataSourceService_Semantic(t *testing.T) {
+	dir := t.TempDir()
+	setup, err := sql.Open("sqlite", filepath.Join(dir, "shop.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = setup.Exec(`CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER, sales_amount REAL, returned INTEGER);
+		CREATE TABLE users (id INTEGER PRIMARY KEY, city TEXT);`)
+	setup.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewDataSourceService("secret", dir)
+	ds, err := s.Create(1, &model.DataSourceRequest{Name: "shop", Type: "sqlite", Database: "shop.db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := &types.SemanticModel{Metrics: []types.SemanticMetric{{Name: "退货率", Formula: "SUM(refunds) / COUNT(*)"}}}
+	if _, err := s.UpdateSemantic(1, ds.ID, invalid); err == nil || !strings.Contains(err.Error(), "refunds") {
+		t.Errorf("应拒绝引用不存在列的指标: %v", err)
+	}
+
+	m := &types.SemanticModel{
+		Tables: []types.SemanticTable{{
+			Name:    "orders",
+			Columns: []types.SemanticColumn{{Name: "sales_amount", Description: "销售额，含税", Synonyms: []string{"GMV"}}},
+		}},
+		Metrics: []types.SemanticMetric{{Name: "退货率", Formula: "SUM(orders.returned) * 1.0 / COUNT(orders.id)"}},
+	}
+	if _, err := s.UpdateSemantic(2, ds.ID, m); err == nil {
+		t.Error("其他用户不应修改语义模型")
+	}
+	if _, err := s.UpdateSemantic(1, ds.ID, m); err != nil {
+		t.Fatal(err)
+	}
+
+	// 表结构补充字段释义
+	tables, err := s.Schema(1, ds.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, column := range tables[0].Columns {
+		if column.Name == "sales_amount" && column.Description != "销售额，含税（又称 GMV）" {
+			t.Errorf("字段释义不正确: %q", column.Description)
+		}
+	}
+
+	// 建议与已有模型合并，无效条目被丢弃，结果不自动保存
+	if _, err := s.SuggestSemantic(context.Background(), 1, ds.ID); err == nil {
+		t.Error("未配置生成器时应返回错误")
+	}
+	s.SetSemanticSuggester(&fakeSuggester{suggestion: &types.SemanticModel{
+		Tables: []types.SemanticTable{
+			{Name: "orders", Columns: []types.SemanticColumn{{Name: "sales_amount", Description: "覆盖已有说明"}, {Name: "missing"}}},
+			{Name: "users", Description: "用户表", Columns: []types.SemanticColumn{{Name: "city", Synonyms: []string{"城市"}}}},
+		},
+		Metrics: []types.SemanticMetric{{Name: "退货率", Formula: "0"}, {Name: "下单用户数", Formula: "COUNT(DISTINCT orders.user_id)"}},
+		Joins:   []types.SemanticJoin{{From: "orders.user_id", To: "users.id"}, {From: "orders.uid", To: "users.id"}},
+	}})
+	suggested, err := s.SuggestSemantic(context.Background(), 1, ds.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggested.Tables) != 2 || len(suggested.Tables[0].Columns) != 1 || suggested.Tables[0].Columns[0].Description != "销售额，含税" {
+		t.Errorf("表建议合并不正确: %+v", suggested.Tables)
+	}
+	if len(suggested.Metrics) != 2 || suggested.Metrics[0].Formula != "SUM(orders.returned) * 1.0 / COUNT(orders.id)" {
+		t.Errorf("指标建议合并不正确: %+v", suggested.Metrics)
+	}
+	if len(suggested.Joins) != 1 {
+		t.Errorf("应丢弃无效的关联路径: %+v", suggested.Joins)
+	}
+	if saved, _ := s.Semantic(1, ds.ID); len(saved.Tables) != 1 || len(saved.Joins) != 0 {
+		t.Errorf("建议不应自动保存: %+v", saved)
+	}
+
+	// 数据库工具只能看到自己数据源的语义模型
+	if got, err := s.ForUser(1).Semantic(context.Background(), ds.ID); err != nil || len(got.Metrics) != 1 {
+		t.Errorf("获取语义模型失败: %v %+v", err, got)
+	}
+	if _, err := s.ForUser(2).Semantic(context.Background(), ds.ID); err == nil {
+		t.Error("其他用户不应获取语义模型")
+	}
+}
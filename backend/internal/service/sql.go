@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"smart-analysis/internal/access"
+	"smart-analysis/internal/analytics"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/pii"
+	"smart-analysis/internal/scheduler"
+	"smart-analysis/internal/sqlengine"
+)
+
+// sqlQueryTimeout 单次SQL查询的超时时间
+const sqlQueryTimeout = 30 * time.Second
+
+// 用户内存数据库的缓存上限：超过空闲时间或数量上限时关闭最久未使用的数据库
+const (
+	sqlDatabaseIdleTTL    = 10 * time.Minute
+	maxCachedSQLDatabases = 32
+)
+
+// SQLService 将用户已就绪的上传文件和共享给用户的文件作为表提供SQL查询，
+// 共享文件在加载时按访问策略过滤行和列
+type SQLService struct {
+	fileService *FileService
+	mu          sync.Mutex // 保护 databases，不在持有时加载文件或执行查询
+	databases   map[int]*userDatabase
+	now         func() time.Time
+}
+
+// userDatabase 用户的内存数据库，文件变化时重建。
+// 正在执行的查询持有引用，数据库被替换或淘汰后等最后一个查询结束再关闭
+type userDatabase struct {
+	fingerprint string
+	db          *sqlengine.DB
+	refs        int
+	lastUsed    time.Time
+	evicted     bool
+}
+
+func NewSQLService(fileService *FileService) *SQLService {
+	return &SQLService{
+		fileService: fileService,
+		databases:   make(map[int]*userDatabase),
+		now:         time.Now,
+	}
+}
+
+// Query 在用户可访问的文件上执行只读SQL，敏感列在加载时已按文件的策略脱敏
+func (s *SQLService) Query(userID int, req *model.SQLQueryRequest) (*model.SQLQueryResponse, error) {
+	ctx, cancel := context.WithTimeout(scheduler.WithUser(context.Background(), strconv.Itoa(userID)), sqlQueryTimeout)
+	defer cancel()
+
+	cached, err := s.acquire(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(cached)
+
+	result, err := cached.db.Query(ctx, req.SQL, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &model.SQLQueryResponse{Result: result, Tables: cached.db.Tables()}, nil
+}
+
+// Tables 返回用户可查询的表
+func (s *SQLService) Tables(userID int) ([]sqlengine.TableInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlQueryTimeout)
+	defer cancel()
+
+	cached, err := s.acquire(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(cached)
+	return cached.db.Tables(), nil
+}
+
+// acquire 返回用户的内存数据库并增加引用，文件列表、文件内容或访问策略有变化时重新加载。
+// 表在加载时按访问策略过滤、按敏感列策略脱敏，与数据视图一致，SQL中的别名和表达式无法读取原始值。
+// 调用方用完后必须调用 release
+func (s *SQLService) acquire(ctx context.Context, userID int) (*userDatabase, error) {
+	files := s.readyFiles(userID)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no ready files to query")
+	}
+
+	parts := make([]string, len(files)+1)
+	for i, file := range files {
+		parts[i] = fmt.Sprintf("%d:%d", file.ID, file.UpdatedAt.UnixNano())
+	}
+	parts[len(files)] = fmt.Sprintf("rev:%d", s.fileService.accessRevision())
+	fingerprint := strings.Join(parts, ",")
+
+	if cached := s.cached(userID, fingerprint); cached != nil {
+		return cached, nil
+	}
+
+	// 在锁外加载文件，加载期间其他用户的查询不受影响
+	db, err := s.open(ctx, userID, files)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.databases[userID]; ok {
+		// 同一用户的并发请求可能已经加载了相同的数据库
+		if current.fingerprint == fingerprint {
+			db.Close()
+			return s.use(current), nil
+		}
+		s.evict(userID, current)
+	}
+	cached := s.use(&userDatabase{fingerprint: fingerprint, db: db})
+	s.databases[userID] = cached
+	s.evictIdle()
+	return cached, nil
+}
+
+// cached 返回指纹一致的缓存数据库并增加引用，没有时返回 nil
+func (s *SQLService) cached(userID int, fingerprint string) *userDatabase {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cached, ok := s.databases[userID]
+	if !ok || cached.fingerprint != fingerprint {
+		return nil
+	}
+	s.use(cached)
+	s.evictIdle()
+	return cached
+}
+
+// use 增加引用并更新使用时间，调用方需持有 s.mu
+func (s *SQLService) use(cached *userDatabase) *userDatabase {
+	cached.refs++
+	cached.lastUsed = s.now()
+	return cached
+}
+
+// release 释放引用，已被淘汰的数据库在最后一个引用释放后关闭
+func (s *SQLService) release(cached *userDatabase) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cached.refs--
+	if cached.evicted && cached.refs == 0 {
+		cached.db.Close()
+	}
+}
+
+// evict 从缓存中移除数据库，没有查询在使用时立即关闭。调用方需持有 s.mu
+func (s *SQLService) evict(userID int, cached *userDatabase) {
+	delete(s.databases, userID)
+	cached.evicted = true
+	if cached.refs == 0 {
+		cached.db.Close()
+	}
+}
+
+// evictIdle 淘汰空闲超时的数据库，数量仍超过上限时按最近使用时间淘汰。调用方需持有 s.mu
+func (s *SQLService) evictIdle() {
+	now := s.now()
+	for userID, cached := range s.databases {
+		if cached.refs == 0 && now.Sub(cached.lastUsed) > sqlDatabaseIdleTTL {
+			s.evict(userID, cached)
+		}
+	}
+
+	for len(s.databases) > maxCachedSQLDatabases {
+		oldest := -1
+		for userID, cached := range s.databases {
+			if oldest == -1 || cached.lastUsed.Before(s.databases[oldest].lastUsed) {
+				oldest = userID
+			}
+		}
+		s.evict(oldest, s.databases[oldest])
+	}
+}
+
+// open 加载用户的文件，创建新的内存数据库
+func (s *SQLService) open(ctx context.Context, userID int, files []*model.File) (*sqlengine.DB, error) {
+	sources := make([]sqlengine.Source, len(files))
+	for i, file := range files {
+		_, restriction, err := s.fileService.accessible(userID, file.ID)
+		if err != nil {
+			return nil, err
+		}
+		sources[i] = sqlengine.Source{
+			Name:      strings.TrimSuffix(file.OrigName, filepath.Ext(file.OrigName)),
+			Path:      file.Path,
+			Transform: s.transform(userID, file, restriction),
+		}
+	}
+	return sqlengine.Open(ctx, sources)
+}
+
+// transform 返回加载文件时的处理：先按访问策略过滤行和列，再按工作表的敏感列策略脱敏
+func (s *SQLService) transform(userID int, file *model.File, restriction *access.Restriction) func(string, *analytics.Table) (*analytics.Table, error) {
+	return func(sheet string, table *analytics.Table) (*analytics.Table, error) {
+		table, err := restriction.Table(table)
+		if err != nil {
+			return nil, err
+		}
+		columns, rows, masked := s.fileService.privacy.Masker().Values(table.Columns, table.Rows, pii.ForTable(file.Sensitive, sheet))
+		s.fileService.privacy.Record(userID, fmt.Sprintf("file:%d", file.ID), PurposeQuery, masked)
+		return &analytics.Table{Columns: columns, Rows: rows}, nil
+	}
+}
+
+// readyFiles 返回用户自己和共享给用户的已处理完成的文件，按ID排序以保证表名稳定
+func (s *SQLService) readyFiles(userID int) []*model.File {
+	var files []*model.File
+	for _, file := range append(s.fileService.GetFilesByUserID(userID), s.fileService.SharedFiles(userID)...) {
+		if file.Status == "ready" {
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+	return files
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"smart-analysis/internal/model"
+)
+
+// newSQLTestFiles 为 users 个用户各创建一个已就绪的CSV文件
+func newSQLTestFiles(t *testing.T, users int) *FileService {
+	t.Helper()
+	files := NewFileService()
+	files.SetViewPath(t.TempDir())
+	dir := t.TempDir()
+	for id := 1; id <= users; id++ {
+		path := filepath.Join(dir, fmt.Sprintf("sales_%d.csv", id))
+		if err := os.WriteFile(path, []byte("region,amount\n华东,100\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		file := &model.File{ID: id, UserID: id, OrigName: "sales.csv", Path: path}
+		files.files[file.ID] = file
+		files.processFile(file)
+	}
+	return files
+}
+
+func TestSQLService_EvictsDatabases(t *testing.T) {
+	files := newSQLTestFiles(t, maxCachedSQLDatabases+2)
+	sqlService := NewSQLService(files)
+	now := time.Now()
+	sqlService.now = func() time.Time { return now }
+
+	// 空闲超时的数据库在下一次访问时关闭
+	if _, err := sqlService.Tables(1); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(sqlDatabaseIdleTTL + time.Second)
+	if _, err := sqlService.Tables(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sqlService.databases[1]; ok || len(sqlService.databases) != 1 {
+		t.Errorf("空闲超时的数据库应被淘汰，实际缓存 %d 个", len(sqlService.databases))
+	}
+
+	// 超过数量上限时淘汰最久未使用的数据库
+	for id := 2; id <= maxCachedSQLDatabases+2; id++ {
+		now = now.Add(time.Second)
+		if _, err := sqlService.Tables(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sqlService.databases) != maxCachedSQLDatabases {
+		t.Errorf("缓存的数据库应不超过 %d 个，实际 %d 个", maxCachedSQLDatabases, len(sqlService.databases))
+	}
+	if _, ok := sqlService.databases[2]; ok {
+		t.Error("最久未使用的数据库应被淘汰")
+	}
+}
+
+func TestSQLService_ReleasesReplacedDatabase(t *testing.T) {
+	files := newSQLTestFiles(t, 1)
+	sqlService := NewSQLService(files)
+
+	// 查询进行中时文件发生变化，旧数据库在查询结束后才关闭
+	inUse, err := sqlService.acquire(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files.files[1].UpdatedAt = time.Now().Add(time.Minute)
+	if _, err := sqlService.Tables(1); err != nil {
+		t.Fatal(err)
+	}
+	if !inUse.evicted {
+		t.Fatal("文件变化后旧数据库应被替换")
+	}
+	if _, err := inUse.db.Query(context.Background(), "SELECT region FROM sales", 0); err != nil {
+		t.Errorf("替换前开始的查询应仍可执行: %v", err)
+	}
+	sqlService.release(inUse)
+	if _, err := inUse.db.Query(context.Background(), "SELECT region FROM sales", 0); err == nil {
+		t.Error("最后一个引用释放后旧数据库应关闭")
+	}
+}
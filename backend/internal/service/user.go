@@ -1,90 +1,155 @@
 package service
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"smart-analysis/internal/model"
 	"smart-analysis/internal/utils"
+	"strings"
+	"sync"
+	"time"
 )
 
+// 连续登录失败达到上限后锁定账号，锁定期间正确的密码也无法登录
+const (
+	maxFailedLogins   = 5
+	failedLoginWindow = 15 * time.Minute
+	lockoutDuration   = 15 * time.Minute
+)
+
+// ErrAccountLocked 连续登录失败次数过多，账号暂时锁定
+var ErrAccountLocked = errors.New("account is temporarily locked due to too many failed logins")
+
+// loginFailures 账号最近的登录失败记录
+type loginFailures struct {
+	count       int
+	firstAt     time.Time
+	lockedUntil time.Time
+}
+
 type UserService struct {
 	// 这里应该有数据库连接，为简化先用内存存储
-	users  map[int]*model.User
-	nextID int
+	mu          sync.RWMutex // 保护 users、nextID、adminEmails 和 failures，不在持有时计算密码哈希
+	users       map[int]*model.User
+	nextID      int
+	adminEmails map[string]bool
+	failures    map[int]*loginFailures
+	now         func() time.Time
 }
 
 func NewUserService() *UserService {
 	return &UserService{
-		users:  make(map[int]*model.User),
-		nextID: 1,
+		users:    make(map[int]*model.User),
+		nextID:   1,
+		failures: make(map[int]*loginFailures),
+		now:      time.Now,
 	}
 }
 
-// Register 用户注册
+// Register 用户注册，邮箱不区分大小写
 func (s *UserService) Register(req *model.RegisterRequest) (*model.User, error) {
-	// 检查用户是否已存在
-	for _, user := range s.users {
-		if user.Email == req.Email {
-			return nil, errors.New("email already exists")
-		}
-		if user.Username == req.Username {
-			return nil, errors.New("username already exists")
-		}
-	}
-
 	// 加密密码
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
 		return nil, err
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 检查用户是否已存在
+	if s.userByEmail(req.Email) != nil {
+		return nil, errors.New("email already exists")
+	}
+	if s.usernameTaken(req.Username) {
+		return nil, errors.New("username already exists")
+	}
+
 	// 创建用户
-	user := &model.User{
-		ID:       s.nextID,
+	return s.addUser(&model.User{
 		Username: req.Username,
 		Email:    req.Email,
 		Password: hashedPassword,
-	}
-
-	s.users[s.nextID] = user
-	s.nextID++
-
-	return user, nil
+		IsAdmin:  s.adminEmails[strings.ToLower(req.Email)],
+	}), nil
 }
 
-// Login 用户登录
+// Login 用户登录，邮箱不区分大小写
 func (s *UserService) Login(req *model.LoginRequest) (*model.User, error) {
 	// 查找用户
-	var user *model.User
-	for _, u := range s.users {
-		if u.Email == req.Email {
-			user = u
-			break
-		}
+	user, err := s.GetUserByEmail(req.Email)
+	if err != nil {
+		return nil, err
 	}
-
-	if user == nil {
-		return nil, errors.New("user not found")
+	if s.locked(user.ID) {
+		return user, ErrAccountLocked
 	}
 
 	// 验证密码
 	if !utils.CheckPassword(req.Password, user.Password) {
-		return nil, errors.New("invalid password")
+		if s.recordFailure(user.ID) {
+			return user, ErrAccountLocked
+		}
+		return user, errors.New("invalid password")
 	}
 
+	s.Unlock(user.ID)
 	return user, nil
 }
 
-// GetUserByID 根据ID获取用户
+// locked 判断账号是否处于锁定期
+func (s *UserService) locked(userID int) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	failures, exists := s.failures[userID]
+	return exists && s.now().Before(failures.lockedUntil)
+}
+
+// recordFailure 记录一次登录失败，窗口期内失败次数达到上限时锁定账号并返回 true
+func (s *UserService) recordFailure(userID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	failures, exists := s.failures[userID]
+	if !exists || now.Sub(failures.firstAt) > failedLoginWindow {
+		failures = &loginFailures{firstAt: now}
+		s.failures[userID] = failures
+	}
+	failures.count++
+	if failures.count < maxFailedLogins {
+		return false
+	}
+	failures.count = 0
+	failures.firstAt = now
+	failures.lockedUntil = now.Add(lockoutDuration)
+	return true
+}
+
+// Unlock 清除账号的登录失败记录并解除锁定，用于登录成功或重置密码后
+func (s *UserService) Unlock(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, userID)
+}
+
+// GetUserByID 根据ID获取用户，返回的是副本
 func (s *UserService) GetUserByID(id int) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	user, exists := s.users[id]
 	if !exists {
 		return nil, errors.New("user not found")
 	}
-	return user, nil
+	return copyUser(user), nil
 }
 
-// UpdateProfile 更新用户资料
+// UpdateProfile 更新用户资料。修改邮箱需要验证新邮箱，由 AccountService.RequestEmailChange 完成
 func (s *UserService) UpdateProfile(userID int, req *model.UpdateProfileRequest) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	user, exists := s.users[userID]
 	if !exists {
 		return nil, errors.New("user not found")
@@ -92,23 +157,277 @@ func (s *UserService) UpdateProfile(userID int, req *model.UpdateProfileRequest)
 
 	// 检查用户名是否重复
 	if req.Username != "" && req.Username != user.Username {
-		for _, u := range s.users {
-			if u.Username == req.Username && u.ID != userID {
-				return nil, errors.New("username already exists")
-			}
+		if s.usernameTaken(req.Username) {
+			return nil, errors.New("username already exists")
 		}
 		user.Username = req.Username
 	}
 
-	// 检查邮箱是否重复
-	if req.Email != "" && req.Email != user.Email {
-		for _, u := range s.users {
-			if u.Email == req.Email && u.ID != userID {
-				return nil, errors.New("email already exists")
-			}
+	return copyUser(user), nil
+}
+
+// ChangePassword 修改密码，需要验证原密码。调用方应随后撤销该用户已签发的令牌
+func (s *UserService) ChangePassword(userID int, req *model.ChangePasswordRequest) error {
+	if !s.CheckPassword(userID, req.OldPassword) {
+		if _, err := s.GetUserByID(userID); err != nil {
+			return err
 		}
-		user.Email = req.Email
+		return errors.New("invalid password")
 	}
 
-	return user, nil
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	return s.setPassword(userID, hashedPassword)
+}
+
+// CheckPassword 验证用户的密码
+func (s *UserService) CheckPassword(userID int, password string) bool {
+	user, err := s.GetUserByID(userID)
+	return err == nil && password != "" && utils.CheckPassword(password, user.Password)
+}
+
+// SetPassword 通过重置流程设置新密码并解除账号锁定。调用方应随后撤销该用户已签发的令牌
+func (s *UserService) SetPassword(userID int, password string) error {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := s.setPassword(userID, hashedPassword); err != nil {
+		return err
+	}
+	s.Unlock(userID)
+	return nil
+}
+
+// setPassword 保存已加密的密码
+func (s *UserService) setPassword(userID int, hashedPassword string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, exists := s.users[userID]
+	if !exists {
+		return errors.New("user not found")
+	}
+	user.Password = hashedPassword
+	return nil
+}
+
+// RegisterPhone 使用已验证的手机号注册，密码为空时随机生成，用户只能通过短信验证码登录
+func (s *UserService) RegisterPhone(username, phone, password string) (*model.User, error) {
+	if password == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		password = hex.EncodeToString(random)
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userByPhone(phone) != nil {
+		return nil, errors.New("phone already exists")
+	}
+	if s.usernameTaken(username) {
+		return nil, errors.New("username already exists")
+	}
+
+	return s.addUser(&model.User{
+		Username: username,
+		Password: hashedPassword,
+		Phone:    phone,
+	}), nil
+}
+
+// GetUserByPhone 根据手机号获取用户，空手机号不匹配任何用户
+func (s *UserService) GetUserByPhone(phone string) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user := s.userByPhone(phone)
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	return copyUser(user), nil
+}
+
+// SetPhone 绑定已验证的手机号
+func (s *UserService) SetPhone(userID int, phone string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, exists := s.users[userID]
+	if !exists {
+		return nil, errors.New("user not found")
+	}
+	if existing := s.userByPhone(phone); existing != nil && existing.ID != userID {
+		return nil, errors.New("phone already exists")
+	}
+	user.Phone = phone
+	return copyUser(user), nil
+}
+
+// SetVerifiedEmail 将已验证的邮箱设为账号邮箱，邮箱对应管理员时同时成为管理员
+func (s *UserService) SetVerifiedEmail(userID int, email string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, exists := s.users[userID]
+	if !exists {
+		return nil, errors.New("user not found")
+	}
+	if existing := s.userByEmail(email); existing != nil && existing.ID != userID {
+		return nil, errors.New("email already exists")
+	}
+	user.Email = email
+	user.EmailVerified = true
+	user.IsAdmin = s.adminEmails[strings.ToLower(email)]
+	return copyUser(user), nil
+}
+
+// DeleteUser 删除账号。用户的文件、会话等数据由 AccountService.DeleteAccount 先行删除
+func (s *UserService) DeleteUser(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.users[userID]; !exists {
+		return errors.New("user not found")
+	}
+	delete(s.users, userID)
+	delete(s.failures, userID)
+	return nil
+}
+
+// CreateExternalUser 为第三方登录创建账号，用户名取自显示名或邮箱，重复时追加序号，邮箱须已由提供商验证。
+// 账号的密码随机生成，用户只能通过第三方登录，除非之后设置密码
+func (s *UserService) CreateExternalUser(name, email string) (*model.User, error) {
+	return s.createExternalUser(name, email, false)
+}
+
+// CreateServiceAccount 创建服务账号，服务账号没有邮箱，只能通过服务 API Key 访问
+func (s *UserService) CreateServiceAccount(name string) (*model.User, error) {
+	return s.createExternalUser("svc-"+name, "", true)
+}
+
+// createExternalUser 创建使用随机密码的账号
+func (s *UserService) createExternalUser(name, email string, isService bool) (*model.User, error) {
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := utils.HashPassword(hex.EncodeToString(password))
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userByEmail(email) != nil {
+		return nil, errors.New("email already exists")
+	}
+
+	base := strings.TrimSpace(name)
+	if base == "" {
+		base, _, _ = strings.Cut(email, "@")
+	}
+	if base == "" {
+		base = "user"
+	}
+	username := base
+	for n := 2; s.usernameTaken(username); n++ {
+		username = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	return s.addUser(&model.User{
+		Username:      username,
+		Email:         email,
+		EmailVerified: email != "",
+		Password:      hashedPassword,
+		IsAdmin:       email != "" && s.adminEmails[strings.ToLower(email)],
+		IsService:     isService,
+	}), nil
+}
+
+// addUser 为用户分配ID并保存，返回副本。调用方需持有 s.mu
+func (s *UserService) addUser(user *model.User) *model.User {
+	user.ID = s.nextID
+	s.users[s.nextID] = user
+	s.nextID++
+	return copyUser(user)
+}
+
+// usernameTaken 判断用户名是否已被使用，调用方需持有 s.mu
+func (s *UserService) usernameTaken(username string) bool {
+	for _, user := range s.users {
+		if user.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
+// userByEmail 按邮箱（不区分大小写）查找用户，空邮箱不匹配任何用户。调用方需持有 s.mu
+func (s *UserService) userByEmail(email string) *model.User {
+	if email == "" {
+		return nil
+	}
+	for _, user := range s.users {
+		if strings.EqualFold(user.Email, email) {
+			return user
+		}
+	}
+	return nil
+}
+
+// userByPhone 按手机号查找用户，空手机号不匹配任何用户。调用方需持有 s.mu
+func (s *UserService) userByPhone(phone string) *model.User {
+	if phone == "" {
+		return nil
+	}
+	for _, user := range s.users {
+		if user.Phone == phone {
+			return user
+		}
+	}
+	return nil
+}
+
+// GetUserByEmail 根据邮箱获取用户，邮箱不区分大小写；第三方登录创建的账号可能没有邮箱，空邮箱不匹配任何用户
+func (s *UserService) GetUserByEmail(email string) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user := s.userByEmail(email)
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	return copyUser(user), nil
+}
+
+// SetAdminEmails 设置管理员邮箱（逗号分隔，不区分大小写），已注册和之后注册的对应用户成为管理员
+func (s *UserService) SetAdminEmails(emails string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adminEmails = make(map[string]bool)
+	for _, email := range strings.Split(emails, ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			s.adminEmails[email] = true
+		}
+	}
+	for _, user := range s.users {
+		user.IsAdmin = s.adminEmails[strings.ToLower(user.Email)]
+	}
+}
+
+// IsAdmin 判断用户是否为管理员。管理员邮箱需要先完成验证，避免他人抢先用管理员邮箱注册
+func (s *UserService) IsAdmin(userID int) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, exists := s.users[userID]
+	return exists && user.IsAdmin && user.EmailVerified
+}
+
+// copyUser 返回用户的副本，避免调用方在锁外修改存储中的用户
+func copyUser(user *model.User) *model.User {
+	copied := *user
+	return &copied
 }
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"smart-analysis/internal/model"
+)
+
+func TestUserService_EmailIsCaseInsensitive(t *testing.T) {
+	users := NewUserService()
+	if _, err := users.Register(&model.RegisterRequest{Username: "admin", Email: "admin@example.com", Password: "secret1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 只有大小写不同的邮箱不能重复注册
+	if _, err := users.Register(&model.RegisterRequest{Username: "other", Email: "Admin@Example.com", Password: "secret1"}); err == nil {
+		t.Error("邮箱只有大小写不同时不应允许注册")
+	}
+	if _, err := users.Login(&model.LoginRequest{Email: "ADMIN@example.com", Password: "secret1"}); err != nil {
+		t.Errorf("登录邮箱应不区分大小写: %v", err)
+	}
+}
+
+func TestUserService_ReturnsCopies(t *testing.T) {
+	users := NewUserService()
+	user, err := users.Register(&model.RegisterRequest{Username: "alice", Email: "alice@example.com", Password: "secret1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user.IsAdmin = true
+	user.EmailVerified = true
+	if users.IsAdmin(user.ID) {
+		t.Error("修改返回的用户不应影响存储中的用户")
+	}
+}
+
+func TestUserService_ConcurrentAccess(t *testing.T) {
+	users := NewUserService()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user, err := users.CreateExternalUser(fmt.Sprintf("user%d", i), fmt.Sprintf("user%d@example.com", i))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			users.SetPhone(user.ID, fmt.Sprintf("1380000000%d", i))
+			users.GetUserByID(user.ID)
+			users.IsAdmin(user.ID)
+		}(i)
+	}
+	wg.Wait()
+
+	// 并发创建的账号ID不重复
+	seen := make(map[int]bool)
+	for i := 0; i < 8; i++ {
+		user, err := users.GetUserByEmail(fmt.Sprintf("user%d@example.com", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[user.ID] {
+			t.Errorf("账号ID %d 重复", user.ID)
+		}
+		seen[user.ID] = true
+	}
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"smart-analysis/internal/analytics"
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/prompts"
+	"smart-analysis/internal/scheduler"
+	"smart-analysis/internal/sqlengine"
+	"smart-analysis/internal/types"
+)
+
+// chartSampleRows 选择图表时提供给LLM的示例行数
+const chartSampleRows = 5
+
+// chartMaxSeries 默认图表最多使用的数值列数
+const chartMaxSeries = 6
+
+// ChartRenderer 按图表参数读取数据文件，生成经过校验的ECharts配置，由 tools.EChartsVisualizationTool 实现
+type ChartRenderer interface {
+	Render(ctx context.Context, spec *types.ChartSpec) (*types.EChartsConfig, error)
+}
+
+// chartChoice LLM选择的图表类型和数据列
+type chartChoice struct {
+	ChartType string   `json:"chart_type"`
+	Columns   []string `json:"data_columns"`
+	Title     string   `json:"title"`
+}
+
+// SetChartRenderer 设置图表生成器，未设置时无法生成图表
+func (s *AnalysisService) SetChartRenderer(renderer ChartRenderer) {
+	s.chartRenderer = renderer
+}
+
+// Visualize 按自然语言描述生成图表：LLM根据推断出的表结构选择图表类型和数据列，
+// 选择的列不存在或类型不匹配时按列类型选择默认的图表。
+// 图表在会话中记录为一次可视化查询，并保存为关联到该查询的图表
+func (s *AnalysisService) Visualize(ctx context.Context, userID int, req *model.VisualizationRequest, fileService *FileService) (*model.Chart, error) {
+	// 在团队空间的会话中生成图表需要 editor 权限
+	session, err := s.session(userID, req.SessionID, model.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if s.chartRenderer == nil {
+		return nil, errors.New("visualization is not available")
+	}
+	ctx = scheduler.WithUser(ctx, strconv.Itoa(userID))
+
+	// 使用按访问策略过滤并脱敏后的数据视图，沙箱无法读取原始文件
+	path, err := fileService.ForUser(userID).ResolveDataset(ctx, fmt.Sprintf("file:%d", req.FileID))
+	if err != nil {
+		return nil, err
+	}
+	table, err := analytics.LoadTable(path)
+	if err != nil {
+		return nil, err
+	}
+	columns := sqlengine.InferColumns(table)
+	if len(table.Rows) == 0 {
+		return nil, errors.New("file has no data to visualize")
+	}
+
+	query := &model.Query{
+		SessionID:     session.ID,
+		UserID:        userID,
+		Question:      req.Query,
+		QueryType:     "visualization",
+		Status:        "processing",
+		PromptVersion: s.prompts.Version(),
+		CreatedAt:     time.Now(),
+	}
+	s.addQuery(query)
+
+	prompt, err := s.prompts.Render(prompts.TemplateChartSelect, map[string]interface{}{
+		"Schema":    chartSchema(columns),
+		"Sample":    chartSample(columns, table.Rows),
+		"Question":  req.Query,
+		"ChartType": req.ChartType,
+	})
+	if err != nil {
+		s.finishQuery(query, "", err)
+		return nil, err
+	}
+	answer, err := s.callLLM(userID, session.WorkspaceID, prompt, nil)
+	if err != nil {
+		s.finishQuery(query, "", err)
+		return nil, err
+	}
+
+	spec, err := chooseChart(answer, columns, req.ChartType)
+	if err != nil {
+		s.finishQuery(query, "", err)
+		return nil, err
+	}
+	if spec.Title == "" {
+		spec.Title = req.Query
+	}
+	spec.FilePath = path
+	config, err := s.chartRenderer.Render(ctx, spec)
+	if err != nil {
+		s.finishQuery(query, "", err)
+		return nil, fmt.Errorf("failed to render chart: %w", err)
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		s.finishQuery(query, "", err)
+		return nil, err
+	}
+	s.finishQuery(query, string(data), nil)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chart := &model.Chart{
+		ID:        s.nextChartID,
+		SessionID: session.ID,
+		QueryID:   query.ID,
+		UserID:    userID,
+		FileID:    req.FileID,
+		Title:     config.Title,
+		ChartType: config.Type,
+		Columns:   spec.Columns,
+		ChartData: config,
+		CreatedAt: time.Now(),
+	}
+	s.charts[s.nextChartID] = chart
+	s.nextChartID++
+	return chart, nil
+}
+
+// GetChart 获取图表，可以查看所在会话的用户都可以查看
+func (s *AnalysisService) GetChart(userID, chartID int) (*model.Chart, error) {
+	s.mu.Lock()
+	chart, exists := s.charts[chartID]
+	s.mu.Unlock()
+	if !exists {
+		return nil, errors.New("chart not found")
+	}
+	if _, err := s.session(userID, chart.SessionID, model.RoleViewer); err != nil {
+		return nil, err
+	}
+	return chart, nil
+}
+
+// chooseChart 校验LLM选择的图表，无效时按列类型选择默认图表。
+// 用户指定了图表类型时只使用该类型，数据无法绘制该类型时返回错误
+func chooseChart(answer string, columns []sqlengine.Column, chartType string) (*types.ChartSpec, error) {
+	var choice chartChoice
+	if start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}"); start != -1 && end > start {
+		if err := json.Unmarshal([]byte(answer[start:end+1]), &choice); err != nil {
+			choice = chartChoice{}
+		}
+	}
+	if chartType != "" {
+		choice.ChartType = chartType
+	}
+	title := strings.TrimSpace(choice.Title)
+
+	if validChartColumns(choice.ChartType, choice.Columns, columns) {
+		return &types.ChartSpec{ChartType: choice.ChartType, Columns: choice.Columns, Title: title}, nil
+	}
+	spec := defaultChart(chartType, columns)
+	if spec == nil {
+		if chartType != "" {
+			return nil, fmt.Errorf("cannot draw a %s chart from this file", chartType)
+		}
+		return nil, errors.New("file has no numeric columns to visualize")
+	}
+	spec.Title = title
+	return spec, nil
+}
+
+// validChartColumns 检查数据列存在、不重复，并且数量和类型符合图表类型的要求
+func validChartColumns(chartType string, selected []string, columns []sqlengine.Column) bool {
+	colTypes := make(map[string]string, len(columns))
+	for _, column := range columns {
+		colTypes[column.Name] = column.Type
+	}
+	seen := make(map[string]bool, len(selected))
+	for _, name := range selected {
+		if _, exists := colTypes[name]; !exists || seen[name] {
+			return false
+		}
+		seen[name] = true
+	}
+
+	n := len(selected)
+	switch chartType {
+	case types.ChartBar, types.ChartLine, types.ChartArea:
+		// 第一列为分类列，可以是任意类型
+		return n >= 2 && allNumeric(colTypes, selected[1:])
+	case types.ChartPie:
+		return n == 2 && allNumeric(colTypes, selected[1:])
+	case types.ChartScatter:
+		return n == 2 && allNumeric(colTypes, selected)
+	case types.ChartRadar:
+		return n >= 3 && allNumeric(colTypes, selected)
+	case types.ChartHeatmap:
+		return n >= 2 && allNumeric(colTypes, selected)
+	}
+	return false
+}
+
+// defaultChart 按列类型选择默认图表，以第一个文本列为分类列，chartType 为空时使用柱状图。
+// 数据无法绘制该类型的图表时返回 nil
+func defaultChart(chartType string, columns []sqlengine.Column) *types.ChartSpec {
+	var category string
+	var numeric []string
+	for _, column := range columns {
+		if isNumericColumn(column.Type) {
+			numeric = append(numeric, column.Name)
+		} else if category == "" {
+			category = column.Name
+		}
+	}
+	if len(numeric) > chartMaxSeries {
+		numeric = numeric[:chartMaxSeries]
+	}
+	if chartType == "" {
+		chartType = types.ChartBar
+	}
+
+	spec := &types.ChartSpec{ChartType: chartType}
+	switch chartType {
+	case types.ChartBar, types.ChartLine, types.ChartArea:
+		if category != "" && len(numeric) >= 1 {
+			spec.Columns = append([]string{category}, numeric...)
+		} else if len(numeric) >= 2 {
+			spec.Columns = numeric
+		}
+	case types.ChartPie:
+		if category != "" && len(numeric) >= 1 {
+			spec.Columns = []string{category, numeric[0]}
+		}
+	case types.ChartScatter:
+		if len(numeric) >= 2 {
+			spec.Columns = numeric[:2]
+		}
+	case types.ChartRadar:
+		if len(numeric) >= 3 {
+			spec.Columns = numeric
+		}
+	case types.ChartHeatmap:
+		if len(numeric) >= 2 {
+			spec.Columns = numeric
+		}
+	}
+	if len(spec.Columns) == 0 {
+		return nil
+	}
+	return spec
+}
+
+// allNumeric 判断列是否都是数值列
+func allNumeric(colTypes map[string]string, names []string) bool {
+	for _, name := range names {
+		if !isNumericColumn(colTypes[name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNumericColumn 判断推断出的列类型是否为数值
+func isNumericColumn(colType string) bool {
+	return colType == sqlengine.TypeInteger || colType == sqlengine.TypeReal
+}
+
+// chartSchema 将列信息格式化为提示词中的表结构
+func chartSchema(columns []sqlengine.Column) string {
+	lines := make([]string, len(columns))
+	for i, column := range columns {
+		lines[i] = fmt.Sprintf("- %s %s", column.Name, column.Type)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// chartSample 将前几行数据格式化为 Markdown 表格
+func chartSample(columns []sqlengine.Column, rows [][]interface{}) string {
+	names := make([]string, len(columns))
+	separators := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.Name
+		separators[i] = "---"
+	}
+	lines := []string{
+		"| " + strings.Join(names, " | ") + " |",
+		"| " + strings.Join(separators, " | ") + " |",
+	}
+	for i, row := range rows {
+		if i == chartSampleRows {
+			break
+		}
+		cells := make([]string, len(columns))
+		for j := range columns {
+			if j < len(row) {
+				cells[j] = strings.ReplaceAll(analytics.CellString(row[j]), "|", "/")
+			}
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/sqlengine"
+	"smart-analysis/internal/types"
+)
+
+// fakeRenderer 记录收到的图表参数，返回只包含一个系列的柱状图
+type fakeRenderer struct {
+	mu   sync.Mutex
+	spec *types.ChartSpec
+}
+
+func (r *fakeRenderer) Render(_ context.Context, spec *types.ChartSpec) (*types.EChartsConfig, error) {
+	r.mu.Lock()
+	r.spec = spec
+	r.mu.Unlock()
+	config := &types.EChartsConfig{
+		Type:   spec.ChartType,
+		Title:  spec.Title,
+		XAxis:  []string{"华东", "华北"},
+		Series: []types.EChartsSeries{{Name: spec.Columns[1], Type: spec.ChartType, Data: []float64{100, 50}}},
+	}
+	return config, config.Validate()
+}
+
+func TestChooseChart(t *testing.T) {
+	columns := []sqlengine.Column{
+		{Name: "region", Type: sqlengine.TypeText},
+		{Name: "sales", Type: sqlengine.TypeReal},
+		{Name: "orders", Type: sqlengine.TypeInteger},
+	}
+
+	spec, err := chooseChart("```json\n{\"chart_type\": \"line\", \"data_columns\": [\"region\", \"orders\"], \"title\": \"订单趋势\"}\n```", columns, "")
+	if err != nil || spec.ChartType != types.ChartLine || strings.Join(spec.Columns, ",") != "region,orders" || spec.Title != "订单趋势" {
+		t.Fatalf("chooseChart = %+v, %v", spec, err)
+	}
+
+	// 选择的列不存在时按列类型选择默认图表
+	spec, err = chooseChart(`{"chart_type": "pie", "data_columns": ["city", "sales"]}`, columns, "")
+	if err != nil || spec.ChartType != types.ChartBar || strings.Join(spec.Columns, ",") != "region,sales,orders" {
+		t.Fatalf("默认图表不正确: %+v, %v", spec, err)
+	}
+
+	// 用户指定的图表类型优先于LLM的选择
+	spec, err = chooseChart(`{"chart_type": "bar", "data_columns": ["region", "sales"]}`, columns, types.ChartScatter)
+	if err != nil || spec.ChartType != types.ChartScatter || strings.Join(spec.Columns, ",") != "sales,orders" {
+		t.Fatalf("指定类型的图表不正确: %+v, %v", spec, err)
+	}
+	if _, err := chooseChart("", columns, types.ChartRadar); err == nil {
+		t.Error("数值列不足时不应生成雷达图")
+	}
+	if _, err := chooseChart("", []sqlengine.Column{{Name: "region", Type: sqlengine.TypeText}}, ""); err == nil {
+		t.Error("没有数值列时应返回错误")
+	}
+}
+
+func TestAnalysisService_Visualize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sales.csv")
+	if err := os.WriteFile(path, []byte("region,sales\n华东,100\n华北,50\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := NewFileService()
+	file := &model.File{ID: 1, UserID: 1, Name: "sales.csv", OrigName: "sales.csv", Path: path}
+	files.files[file.ID] = file
+	files.processFile(file)
+
+	analysis := NewAnalysisService()
+	session, _ := analysis.CreateSession(1, &model.CreateSessionRequest{Name: "s"})
+	req := &model.VisualizationRequest{SessionID: session.ID, FileID: file.ID, Query: "各地区销售额"}
+	ctx := context.Background()
+
+	if _, err := analysis.Visualize(ctx, 1, req, files); err == nil {
+		t.Fatal("未设置图表生成器时应返回错误")
+	}
+	renderer := &fakeRenderer{}
+	analysis.SetChartRenderer(renderer)
+	if _, err := analysis.Visualize(ctx, 1, req, files); err == nil {
+		t.Fatal("未配置LLM时应返回错误")
+	}
+	if _, err := analysis.ConfigLLM(1, &model.LLMConfigRequest{Provider: "mock", APIKey: "sk-test", Model: "m"}); err != nil {
+		t.Fatal(err)
+	}
+
+	chart, err := analysis.Visualize(ctx, 1, req, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renderer.spec.FilePath == "" || strings.Join(renderer.spec.Columns, ",") != "region,sales" {
+		t.Errorf("图表参数不正确: %+v", renderer.spec)
+	}
+	if chart.SessionID != session.ID || chart.ChartType != types.ChartBar || chart.Title != req.Query || chart.ChartData == nil {
+		t.Errorf("图表不正确: %+v", chart)
+	}
+	query := analysis.queries[chart.QueryID]
+	if query == nil || query.SessionID != session.ID || query.QueryType != "visualization" || query.Status != "completed" {
+		t.Errorf("可视化查询记录不正确: %+v", query)
+	}
+
+	if got, err := analysis.GetChart(1, chart.ID); err != nil || got != chart {
+		t.Errorf("GetChart = %+v, %v", got, err)
+	}
+	if _, err := analysis.GetChart(2, chart.ID); err == nil {
+		t.Error("其他用户不应查看图表")
+	}
+	if _, err := analysis.Visualize(ctx, 2, req, files); err == nil {
+		t.Error("其他用户不应在会话中生成图表")
+	}
+}
@@ -0,0 +1,601 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"smart-analysis/internal/model"
+)
+
+// invitationTTL 邀请的有效期
+const invitationTTL = 7 * 24 * time.Hour
+
+// 邀请状态
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+)
+
+// roleLevels 角色的权限等级，等级高的角色拥有等级低的角色的全部权限
+var roleLevels = map[string]int{
+	model.RoleViewer: 1,
+	model.RoleEditor: 2,
+	model.RoleOwner:  3,
+}
+
+// WorkspaceService 组织、团队空间、成员和邀请管理。
+// 文件、会话、看板和LLM配置服务通过它检查用户在团队空间中的角色
+type WorkspaceService struct {
+	users *UserService
+
+	mu            sync.RWMutex
+	orgs          map[int]*model.Organization
+	workspaces    map[int]*model.Workspace
+	members       map[int]map[int]*model.WorkspaceMember // 团队空间ID -> 用户ID -> 成员
+	invitations   map[int]*model.Invitation
+	nextOrgID     int
+	nextID        int
+	nextInviteID  int
+	revision      int // 成员或角色的版本，变化时共享文件的SQL表和数据视图随之重建
+	now           func() time.Time
+	generateToken func() (string, error)
+}
+
+// NewWorkspaceService 创建团队空间服务，users 用于按邮箱匹配邀请和返回成员信息
+func NewWorkspaceService(users *UserService) *WorkspaceService {
+	return &WorkspaceService{
+		users:         users,
+		orgs:          make(map[int]*model.Organization),
+		workspaces:    make(map[int]*model.Workspace),
+		members:       make(map[int]map[int]*model.WorkspaceMember),
+		invitations:   make(map[int]*model.Invitation),
+		nextOrgID:     1,
+		nextID:        1,
+		nextInviteID:  1,
+		now:           time.Now,
+		generateToken: invitationToken,
+	}
+}
+
+// CreateOrganization 创建组织，创建者为组织所有者
+func (s *WorkspaceService) CreateOrganization(userID int, req *model.OrganizationRequest) (*model.Organization, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("organization name is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	org := &model.Organization{ID: s.nextOrgID, Name: name, OwnerID: userID, CreatedAt: now, UpdatedAt: now}
+	s.orgs[org.ID] = org
+	s.nextOrgID++
+
+	copied := *org
+	return &copied, nil
+}
+
+// ListOrganizations 返回用户拥有的组织和其所在团队空间所属的组织，按ID排序
+func (s *WorkspaceService) ListOrganizations(userID int) []*model.Organization {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	visible := make(map[int]bool)
+	for _, org := range s.orgs {
+		if org.OwnerID == userID {
+			visible[org.ID] = true
+		}
+	}
+	for id, members := range s.members {
+		if _, ok := members[userID]; ok {
+			visible[s.workspaces[id].OrgID] = true
+		}
+	}
+
+	orgs := make([]*model.Organization, 0, len(visible))
+	for id := range visible {
+		copied := *s.orgs[id]
+		orgs = append(orgs, &copied)
+	}
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].ID < orgs[j].ID })
+	return orgs
+}
+
+// CreateWorkspace 在组织下创建团队空间，只有组织所有者可以创建，创建者成为空间所有者
+func (s *WorkspaceService) CreateWorkspace(userID, orgID int, req *model.WorkspaceRequest) (*model.Workspace, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("workspace name is required")
+	}
+	if req.TokenBudget < 0 {
+		return nil, errors.New("token budget must not be negative")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	org, exists := s.orgs[orgID]
+	if !exists {
+		return nil, errors.New("organization not found")
+	}
+	if org.OwnerID != userID {
+		return nil, errors.New("permission denied")
+	}
+
+	now := s.now()
+	workspace := &model.Workspace{
+		ID:          s.nextID,
+		OrgID:       orgID,
+		Name:        name,
+		Description: strings.TrimSpace(req.Description),
+		TokenBudget: req.TokenBudget,
+		CreatedBy:   userID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	s.workspaces[workspace.ID] = workspace
+	s.members[workspace.ID] = map[int]*model.WorkspaceMember{
+		userID: {WorkspaceID: workspace.ID, UserID: userID, Role: model.RoleOwner, JoinedAt: now},
+	}
+	s.nextID++
+	s.revision++
+
+	return s.withRole(workspace, model.RoleOwner), nil
+}
+
+// ListWorkspaces 返回用户所在的团队空间及其角色，按ID排序
+func (s *WorkspaceService) ListWorkspaces(userID int) []*model.Workspace {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	workspaces := make([]*model.Workspace, 0)
+	for id, members := range s.members {
+		if member, ok := members[userID]; ok {
+			workspaces = append(workspaces, s.withRole(s.workspaces[id], member.Role))
+		}
+	}
+	sort.Slice(workspaces, func(i, j int) bool { return workspaces[i].ID < workspaces[j].ID })
+	return workspaces
+}
+
+// GetWorkspace 返回团队空间，需要是空间成员
+func (s *WorkspaceService) GetWorkspace(userID, workspaceID int) (*model.Workspace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	role, err := s.authorize(userID, workspaceID, model.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	return s.withRole(s.workspaces[workspaceID], role), nil
+}
+
+// UpdateWorkspace 修改团队空间的名称、描述和LLM预算，需要空间所有者权限
+func (s *WorkspaceService) UpdateWorkspace(userID, workspaceID int, req *model.WorkspaceRequest) (*model.Workspace, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("workspace name is required")
+	}
+	if req.TokenBudget < 0 {
+		return nil, errors.New("token budget must not be negative")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	role, err := s.authorize(userID, workspaceID, model.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace := s.workspaces[workspaceID]
+	workspace.Name = name
+	workspace.Description = strings.TrimSpace(req.Description)
+	workspace.TokenBudget = req.TokenBudget
+	workspace.UpdatedAt = s.now()
+	return s.withRole(workspace, role), nil
+}
+
+// ListMembers 返回团队空间的成员，需要是空间成员
+func (s *WorkspaceService) ListMembers(userID, workspaceID int) ([]*model.WorkspaceMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, err := s.authorize(userID, workspaceID, model.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	members := make([]*model.WorkspaceMember, 0, len(s.members[workspaceID]))
+	for _, member := range s.members[workspaceID] {
+		copied := *member
+		if user, err := s.users.GetUserByID(member.UserID); err == nil {
+			copied.Username = user.Username
+			copied.Email = user.Email
+		}
+		members = append(members, &copied)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].UserID < members[j].UserID })
+	return members, nil
+}
+
+// UpdateMemberRole 修改成员的角色，需要空间所有者权限，空间至少保留一名所有者
+func (s *WorkspaceService) UpdateMemberRole(userID, workspaceID, memberID int, role string) (*model.WorkspaceMember, error) {
+	if _, ok := roleLevels[role]; !ok {
+		return nil, fmt.Errorf("invalid role: %s", role)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.authorize(userID, workspaceID, model.RoleOwner); err != nil {
+		return nil, err
+	}
+	member, exists := s.members[workspaceID][memberID]
+	if !exists {
+		return nil, errors.New("member not found")
+	}
+	if member.Role == model.RoleOwner && role != model.RoleOwner && s.ownerCount(workspaceID) == 1 {
+		return nil, errors.New("a workspace must keep at least one owner")
+	}
+
+	member.Role = role
+	s.revision++
+	copied := *member
+	return &copied, nil
+}
+
+// RemoveMember 移除成员，空间所有者可以移除任何成员，其他成员只能移除自己（退出空间）
+func (s *WorkspaceService) RemoveMember(userID, workspaceID, memberID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	minRole := model.RoleOwner
+	if userID == memberID {
+		minRole = model.RoleViewer
+	}
+	if _, err := s.authorize(userID, workspaceID, minRole); err != nil {
+		return err
+	}
+	member, exists := s.members[workspaceID][memberID]
+	if !exists {
+		return errors.New("member not found")
+	}
+	if member.Role == model.RoleOwner && s.ownerCount(workspaceID) == 1 {
+		return errors.New("a workspace must keep at least one owner")
+	}
+
+	delete(s.members[workspaceID], memberID)
+	s.revision++
+	return nil
+}
+
+// DeleteUserData 将用户移出全部团队空间，用于注销账号。
+// 用户是仍有其他成员的团队空间的唯一所有者时返回错误，需要先转让所有权
+func (s *WorkspaceService) DeleteUserData(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for workspaceID, members := range s.members {
+		member, exists := members[userID]
+		if exists && member.Role == model.RoleOwner && s.ownerCount(workspaceID) == 1 && len(members) > 1 {
+			return fmt.Errorf("transfer ownership of workspace %q before deleting the account", s.workspaces[workspaceID].Name)
+		}
+	}
+	for _, members := range s.members {
+		if _, exists := members[userID]; exists {
+			delete(members, userID)
+			s.revision++
+		}
+	}
+	return nil
+}
+
+// Invite 邀请用户加入团队空间，需要空间所有者权限。
+// 同一邮箱已有待处理的邀请时撤销旧邀请，被邀请人可以在注册后接受邀请
+func (s *WorkspaceService) Invite(userID, workspaceID int, req *model.InvitationRequest) (*model.Invitation, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+	role := req.Role
+	if role == "" {
+		role = model.RoleViewer
+	}
+	if _, ok := roleLevels[role]; !ok {
+		return nil, fmt.Errorf("invalid role: %s", role)
+	}
+	token, err := s.generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.authorize(userID, workspaceID, model.RoleOwner); err != nil {
+		return nil, err
+	}
+	if user, err := s.users.GetUserByEmail(email); err == nil {
+		if _, member := s.members[workspaceID][user.ID]; member {
+			return nil, errors.New("user is already a member of this workspace")
+		}
+	}
+
+	now := s.now()
+	for _, invitation := range s.invitations {
+		if invitation.WorkspaceID == workspaceID && invitation.Email == email && invitation.Status == InvitationPending {
+			invitation.Status = InvitationRevoked
+			invitation.RespondedAt = &now
+		}
+	}
+	invitation := &model.Invitation{
+		ID:          s.nextInviteID,
+		WorkspaceID: workspaceID,
+		Email:       email,
+		Role:        role,
+		Token:       token,
+		Status:      InvitationPending,
+		InvitedBy:   userID,
+		ExpiresAt:   now.Add(invitationTTL),
+		CreatedAt:   now,
+	}
+	s.invitations[invitation.ID] = invitation
+	s.nextInviteID++
+
+	copied := *invitation
+	return &copied, nil
+}
+
+// ListInvitations 返回团队空间的邀请，需要空间所有者权限
+func (s *WorkspaceService) ListInvitations(userID, workspaceID int) ([]*model.Invitation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, err := s.authorize(userID, workspaceID, model.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	invitations := make([]*model.Invitation, 0)
+	for _, invitation := range s.invitations {
+		if invitation.WorkspaceID == workspaceID {
+			copied := *invitation
+			invitations = append(invitations, &copied)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool { return invitations[i].ID < invitations[j].ID })
+	return invitations, nil
+}
+
+// RevokeInvitation 撤销待处理的邀请，需要空间所有者权限
+func (s *WorkspaceService) RevokeInvitation(userID, workspaceID, invitationID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.authorize(userID, workspaceID, model.RoleOwner); err != nil {
+		return err
+	}
+	invitation, exists := s.invitations[invitationID]
+	if !exists || invitation.WorkspaceID != workspaceID {
+		return errors.New("invitation not found")
+	}
+	if invitation.Status != InvitationPending {
+		return fmt.Errorf("invitation is already %s", invitation.Status)
+	}
+
+	now := s.now()
+	invitation.Status = InvitationRevoked
+	invitation.RespondedAt = &now
+	return nil
+}
+
+// PendingInvitations 返回发给该用户邮箱且未过期的待处理邀请
+func (s *WorkspaceService) PendingInvitations(userID int) ([]*model.Invitation, error) {
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := s.now()
+	invitations := make([]*model.Invitation, 0)
+	for _, invitation := range s.invitations {
+		if invitation.Status == InvitationPending && now.Before(invitation.ExpiresAt) && strings.EqualFold(invitation.Email, user.Email) {
+			copied := *invitation
+			invitations = append(invitations, &copied)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool { return invitations[i].ID < invitations[j].ID })
+	return invitations, nil
+}
+
+// AcceptInvitation 接受邀请并加入团队空间，用户邮箱必须与邀请的邮箱一致
+func (s *WorkspaceService) AcceptInvitation(userID int, token string) (*model.WorkspaceMember, error) {
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invitation, err := s.pendingInvitation(user, token)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	invitation.Status = InvitationAccepted
+	invitation.RespondedAt = &now
+	member, exists := s.members[invitation.WorkspaceID][userID]
+	if !exists {
+		member = &model.WorkspaceMember{WorkspaceID: invitation.WorkspaceID, UserID: userID, Role: invitation.Role, JoinedAt: now}
+		s.members[invitation.WorkspaceID][userID] = member
+		s.revision++
+	}
+
+	copied := *member
+	copied.Username = user.Username
+	copied.Email = user.Email
+	return &copied, nil
+}
+
+// AddMember 将用户直接加入团队空间，用于第三方登录自动创建账号等系统操作，不检查操作者权限。
+// 用户已是成员时保留原角色
+func (s *WorkspaceService) AddMember(workspaceID, userID int, role string) (*model.WorkspaceMember, error) {
+	if _, valid := roleLevels[role]; !valid {
+		return nil, fmt.Errorf("invalid role: %s", role)
+	}
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.workspaces[workspaceID]; !exists {
+		return nil, errors.New("workspace not found")
+	}
+	member, exists := s.members[workspaceID][userID]
+	if !exists {
+		member = &model.WorkspaceMember{WorkspaceID: workspaceID, UserID: userID, Role: role, JoinedAt: s.now()}
+		s.members[workspaceID][userID] = member
+		s.revision++
+	}
+
+	copied := *member
+	copied.Username = user.Username
+	copied.Email = user.Email
+	return &copied, nil
+}
+
+// DeclineInvitation 拒绝邀请
+func (s *WorkspaceService) DeclineInvitation(userID int, token string) error {
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invitation, err := s.pendingInvitation(user, token)
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	invitation.Status = InvitationDeclined
+	invitation.RespondedAt = &now
+	return nil
+}
+
+// Role 返回用户在团队空间中的角色，不是成员时返回空字符串
+func (s *WorkspaceService) Role(userID, workspaceID int) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if member, ok := s.members[workspaceID][userID]; ok {
+		return member.Role
+	}
+	return ""
+}
+
+// Authorize 检查用户在团队空间中的角色不低于 minRole
+func (s *WorkspaceService) Authorize(userID, workspaceID int, minRole string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, err := s.authorize(userID, workspaceID, minRole)
+	return err
+}
+
+// TokenBudget 返回团队空间每月的LLM token预算，0表示不限
+func (s *WorkspaceService) TokenBudget(workspaceID int) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if workspace, exists := s.workspaces[workspaceID]; exists {
+		return workspace.TokenBudget
+	}
+	return 0
+}
+
+// Teams 返回用户所在团队空间对应的团队名（workspace:<ID>），文件访问策略可以授权给整个团队空间
+func (s *WorkspaceService) Teams(userID int) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var teams []string
+	for id, members := range s.members {
+		if _, ok := members[userID]; ok {
+			teams = append(teams, WorkspaceTeam(id))
+		}
+	}
+	sort.Strings(teams)
+	return teams
+}
+
+// Revision 返回成员和角色的版本
+func (s *WorkspaceService) Revision() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.revision
+}
+
+// WorkspaceTeam 返回团队空间在文件访问策略中的团队名
+func WorkspaceTeam(workspaceID int) string {
+	return fmt.Sprintf("workspace:%d", workspaceID)
+}
+
+// authorize 检查角色并返回用户的角色，调用方需持有 s.mu
+func (s *WorkspaceService) authorize(userID, workspaceID int, minRole string) (string, error) {
+	if _, exists := s.workspaces[workspaceID]; !exists {
+		return "", errors.New("workspace not found")
+	}
+	member, ok := s.members[workspaceID][userID]
+	if !ok || roleLevels[member.Role] < roleLevels[minRole] {
+		return "", errors.New("permission denied")
+	}
+	return member.Role, nil
+}
+
+// ownerCount 返回团队空间的所有者人数，调用方需持有 s.mu
+func (s *WorkspaceService) ownerCount(workspaceID int) int {
+	count := 0
+	for _, member := range s.members[workspaceID] {
+		if member.Role == model.RoleOwner {
+			count++
+		}
+	}
+	return count
+}
+
+// pendingInvitation 按令牌查找发给该用户的待处理邀请，调用方需持有 s.mu
+func (s *WorkspaceService) pendingInvitation(user *model.User, token string) (*model.Invitation, error) {
+	for _, invitation := range s.invitations {
+		if invitation.Token != token || token == "" {
+			continue
+		}
+		if !strings.EqualFold(invitation.Email, user.Email) {
+			return nil, errors.New("invitation was sent to a different email")
+		}
+		if invitation.Status != InvitationPending {
+			return nil, fmt.Errorf("invitation is already %s", invitation.Status)
+		}
+		if !s.now().Before(invitation.ExpiresAt) {
+			return nil, errors.New("invitation has expired")
+		}
+		return invitation, nil
+	}
+	return nil, errors.New("invitation not found")
+}
+
+// withRole 复制团队空间并填入用户的角色
+func (s *WorkspaceService) withRole(workspace *model.Workspace, role string) *model.Workspace {
+	copied := *workspace
+	copied.Role = role
+	return &copied
+}
+
+// invitationToken 生成随机的邀请令牌
+func invitationToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
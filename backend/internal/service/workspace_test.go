@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"smart-analysis/internal/model"
+	"smart-analysis/internal/utils"
+)
+
+func TestWorkspaceService_MembersAndInvitations(t *testing.T) {
+	users := NewUserService()
+	for _, name := range []string{"alice", "bob", "carol"} {
+		if _, err := users.Register(&model.RegisterRequest{Username: name, Email: name + "@example.com", Password: "secret1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	workspaces := NewWorkspaceService(users)
+	now := time.Now()
+	workspaces.now = func() time.Time { return now }
+
+	org, err := workspaces.CreateOrganization(1, &model.OrganizationRequest{Name: "Acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := workspaces.CreateWorkspace(2, org.ID, &model.WorkspaceRequest{Name: "Sales"}); err == nil {
+		t.Error("只有组织所有者可以创建团队空间")
+	}
+	ws, err := workspaces.CreateWorkspace(1, org.ID, &model.WorkspaceRequest{Name: "Sales", TokenBudget: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws.Role != model.RoleOwner {
+		t.Errorf("创建者应为空间所有者: %s", ws.Role)
+	}
+
+	// 邀请按邮箱匹配，只有被邀请人可以接受
+	if _, err := workspaces.Invite(2, ws.ID, &model.InvitationRequest{Email: "bob@example.com"}); err == nil {
+		t.Error("非所有者不能邀请成员")
+	}
+	invitation, err := workspaces.Invite(1, ws.ID, &model.InvitationRequest{Email: "Bob@example.com", Role: model.RoleEditor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := workspaces.PendingInvitations(2); len(pending) != 1 || pending[0].Token != invitation.Token {
+		t.Errorf("待处理邀请不正确: %v", pending)
+	}
+	if _, err := workspaces.AcceptInvitation(3, invitation.Token); err == nil {
+		t.Error("不能接受发给其他邮箱的邀请")
+	}
+	member, err := workspaces.AcceptInvitation(2, invitation.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member.Role != model.RoleEditor || workspaces.Role(2, ws.ID) != model.RoleEditor {
+		t.Errorf("成员角色不正确: %+v", member)
+	}
+	if _, err := workspaces.AcceptInvitation(2, invitation.Token); err == nil {
+		t.Error("邀请不能重复接受")
+	}
+
+	// 过期的邀请不能接受
+	expired, err := workspaces.Invite(1, ws.ID, &model.InvitationRequest{Email: "carol@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspaces.now = func() time.Time { return now.Add(invitationTTL) }
+	if _, err := workspaces.AcceptInvitation(3, expired.Token); err == nil {
+		t.Error("过期的邀请不能接受")
+	}
+	workspaces.now = func() time.Time { return now }
+
+	// 空间至少保留一名所有者
+	if _, err := workspaces.UpdateMemberRole(1, ws.ID, 1, model.RoleViewer); err == nil {
+		t.Error("不能降级唯一的所有者")
+	}
+	if err := workspaces.RemoveMember(2, ws.ID, 1); err == nil {
+		t.Error("editor 不能移除其他成员")
+	}
+	if _, err := workspaces.UpdateMemberRole(1, ws.ID, 2, model.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if err := workspaces.Authorize(2, ws.ID, model.RoleEditor); err == nil {
+		t.Error("viewer 不应具有 editor 权限")
+	}
+	if teams := workspaces.Teams(2); len(teams) != 1 || teams[0] != WorkspaceTeam(ws.ID) {
+		t.Errorf("团队不正确: %v", teams)
+	}
+	if err := workspaces.RemoveMember(2, ws.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if workspaces.Role(2, ws.ID) != "" {
+		t.Error("退出后不应再是成员")
+	}
+}
+
+func TestWorkspaceService_Sharing(t *testing.T) {
+	users := NewUserService()
+	for _, name := range []string{"alice", "bob", "carol"} {
+		if _, err := users.Register(&model.RegisterRequest{Username: name, Email: name + "@example.com", Password: "secret1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	workspaces := NewWorkspaceService(users)
+	org, _ := workspaces.CreateOrganization(1, &model.OrganizationRequest{Name: "Acme"})
+	ws, _ := workspaces.CreateWorkspace(1, org.ID, &model.WorkspaceRequest{Name: "Sales", TokenBudget: 20})
+	invitation, _ := workspaces.Invite(1, ws.ID, &model.InvitationRequest{Email: "bob@example.com", Role: model.RoleViewer})
+	if _, err := workspaces.AcceptInvitation(2, invitation.Token); err != nil {
+		t.Fatal(err)
+	}
+
+	// 共享到团队空间的文件对成员完全可见，SQL表随成员变化重建
+	uploads := t.TempDir()
+	path := filepath.Join(uploads, "20240101_sales.csv")
+	if err := os.WriteFile(path, []byte("region,amount\nEast,100\nWest,200\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := NewFileService()
+	files.basePath = uploads
+	files.SetViewPath(t.TempDir())
+	files.SetWorkspaces(workspaces)
+	file := &model.File{ID: 1, UserID: 1, Name: "20240101_sales.csv", OrigName: "sales.csv", Path: path}
+	files.files[file.ID] = file
+	files.processFile(file)
+	sqlService := NewSQLService(files)
+
+	if _, err := files.PreviewFile(2, 1, 50); err == nil {
+		t.Fatal("未共享的文件不应可见")
+	}
+	if _, err := files.ShareFile(2, 1, ws.ID); err == nil {
+		t.Error("只有文件所有者可以共享文件")
+	}
+	if _, err := files.ShareFile(1, 1, ws.ID); err != nil {
+		t.Fatal(err)
+	}
+	if preview, err := files.PreviewFile(2, 1, 50); err != nil || len(preview.(*utils.CSVData).Rows) != 2 {
+		t.Errorf("空间成员应能预览全部数据: %v %v", preview, err)
+	}
+	if resp, err := sqlService.Query(2, &model.SQLQueryRequest{SQL: "SELECT SUM(amount) FROM sales"}); err != nil || resp.Result.Rows[0][0] != int64(300) {
+		t.Errorf("空间成员应能查询共享文件: %v %v", resp, err)
+	}
+	if listed, err := files.WorkspaceFiles(3, ws.ID); err == nil {
+		t.Errorf("非成员不能查看空间文件: %v", listed)
+	}
+	if err := workspaces.RemoveMember(1, ws.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sqlService.Query(2, &model.SQLQueryRequest{SQL: "SELECT * FROM sales"}); err == nil {
+		t.Error("移除成员后不应能查询共享文件")
+	}
+	invitation, _ = workspaces.Invite(1, ws.ID, &model.InvitationRequest{Email: "bob@example.com", Role: model.RoleViewer})
+	if _, err := workspaces.AcceptInvitation(2, invitation.Token); err != nil {
+		t.Fatal(err)
+	}
+
+	// 团队空间的会话：viewer 可以查看，不能提问；LLM配置由所有者设置，成员看不到完整的 API Key
+	analysis := NewAnalysisService()
+	analysis.SetWorkspaces(workspaces)
+	if _, err := analysis.CreateSession(2, &model.CreateSessionRequest{Name: "s", WorkspaceID: ws.ID}); err == nil {
+		t.Error("viewer 不能在团队空间创建会话")
+	}
+	session, err := analysis.CreateSession(1, &model.CreateSessionRequest{Name: "s", WorkspaceID: ws.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := analysis.GetSession(2, session.ID); err != nil {
+		t.Errorf("成员应能查看团队空间的会话: %v", err)
+	}
+	if _, err := analysis.GetSession(3, session.ID); err == nil {
+		t.Error("非成员不能查看团队空间的会话")
+	}
+	if _, err := analysis.Query(context.Background(), 2, &model.QueryRequest{SessionID: session.ID, Question: "销量"}, files); err == nil {
+		t.Error("viewer 不能在团队空间提问")
+	}
+	if _, err := analysis.ConfigLLM(2, &model.LLMConfigRequest{Provider: "mock", APIKey: "sk-member", Model: "m", WorkspaceID: ws.ID}); err == nil {
+		t.Error("只有空间所有者可以设置LLM配置")
+	}
+	if _, err := analysis.ConfigLLM(1, &model.LLMConfigRequest{Provider: "mock", APIKey: "sk-workspace-1234", Model: "m", WorkspaceID: ws.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if configs, err := analysis.GetWorkspaceLLMConfig(2, ws.ID); err != nil || len(configs) != 1 || configs[0].APIKey != "****1234" {
+		t.Errorf("成员看到的LLM配置不正确: %v %v", configs, err)
+	}
+
+	// 空间的LLM配置不需要成员自己配置，使用量计入空间预算，超出预算后拒绝调用
+	if _, err := analysis.Query(context.Background(), 1, &model.QueryRequest{SessionID: session.ID, Question: "各区域销量"}, files); err != nil {
+		t.Fatal(err)
+	}
+	usage, err := analysis.GetWorkspaceUsage(2, ws.ID)
+	if err != nil || usage.TokenBudget != 20 || usage.TotalTokens == 0 {
+		t.Fatalf("空间使用量不正确: %+v %v", usage, err)
+	}
+	for i := 0; usage.TotalTokens < usage.TokenBudget && i < 10; i++ {
+		analysis.Query(context.Background(), 1, &model.QueryRequest{SessionID: session.ID, Question: "各区域销量"}, files)
+		usage, _ = analysis.GetWorkspaceUsage(1, ws.ID)
+	}
+	if _, err := analysis.Query(context.Background(), 1, &model.QueryRequest{SessionID: session.ID, Question: "销量"}, files); err == nil || !strings.Contains(err.Error(), "budget") {
+		t.Errorf("超出预算后应拒绝调用: %v", err)
+	}
+	if history, _ := analysis.GetHistory(2, &session.ID); len(history) == 0 {
+		t.Error("成员应能查看团队空间会话的历史")
+	}
+
+	// 团队空间的看板：viewer 只读，editor 可以修改
+	dashboards := NewDashboardService(workspaces)
+	req := &model.DashboardRequest{Name: "Sales", WorkspaceID: ws.ID, Widgets: []model.DashboardWidget{{Title: "by region", ChartType: "bar", SQL: "SELECT region, SUM(amount) FROM sales GROUP BY region"}}}
+	if _, err := dashboards.Create(2, req); err == nil {
+		t.Error("viewer 不能创建看板")
+	}
+	dashboard, err := dashboards.Create(1, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listed, err := dashboards.List(2, ws.ID); err != nil || len(listed) != 1 {
+		t.Errorf("成员应能查看空间的看板: %v %v", listed, err)
+	}
+	if _, err := dashboards.Update(2, dashboard.ID, req); err == nil {
+		t.Error("viewer 不能修改看板")
+	}
+	if _, err := dashboards.Get(3, dashboard.ID); err == nil {
+		t.Error("非成员不能查看看板")
+	}
+	if _, err := dashboards.Create(1, &model.DashboardRequest{Name: "bad", Widgets: []model.DashboardWidget{{ChartType: "radar", SQL: "SELECT 1"}}}); err == nil {
+		t.Error("应拒绝不支持的图表类型")
+	}
+}
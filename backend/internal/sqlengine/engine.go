@@ -0,0 +1,181 @@
+// Package sqlengine 将上传的数据文件加载为内存SQLite数据库中的表，支持用只读SQL查询和跨文件关联。
+// 使用纯Go实现的SQLite驱动，不依赖CGO
+package sqlengine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"smart-analysis/internal/analytics"
+	"smart-analysis/internal/audit"
+	"smart-analysis/internal/sqlguard"
+
+	_ "modernc.org/sqlite"
+)
+
+// 查询结果行数限制和 OFFSET 上限
+const (
+	DefaultMaxRows = 1000
+	MaxRowsLimit   = 10000
+	MaxOffset      = 100000
+)
+
+// Source 要加载为表的数据文件，Name 为期望的表名，Excel文件的每个工作表各生成一张表。
+// Transform 不为空时在建表前处理每张表的数据，如按访问策略过滤行和列，sheet 为工作表名称，非Excel文件为空
+type Source struct {
+	Name      string
+	Path      string
+	Transform func(sheet string, table *analytics.Table) (*analytics.Table, error)
+}
+
+// Column 表的列信息
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // INTEGER, REAL, TEXT
+}
+
+// TableInfo 已加载的表
+type TableInfo struct {
+	Name    string   `json:"name"`
+	Path    string   `json:"path"`
+	Sheet   string   `json:"sheet,omitempty"`
+	Columns []Column `json:"columns"`
+	Rows    int      `json:"rows"`
+}
+
+// Result 查询结果
+type Result struct {
+	Columns   []string        `json:"columns"`
+	Rows      [][]interface{} `json:"rows"`
+	RowCount  int             `json:"row_count"`
+	Truncated bool            `json:"truncated"` // 结果超过行数限制被截断
+}
+
+// DB 内存SQLite数据库，加载完成后切换为只读
+type DB struct {
+	db     *sql.DB
+	tables []TableInfo
+}
+
+// Open 创建内存数据库并加载数据文件，表名冲突时追加序号
+func Open(ctx context.Context, sources []Source) (*DB, error) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("创建内存数据库失败: %w", err)
+	}
+	// 每个连接都是独立的内存数据库，只保留一个连接
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+
+	engine := &DB{db: db}
+	used := make(map[string]bool)
+	for _, source := range sources {
+		if err := engine.load(ctx, source, used); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("设置只读模式失败: %w", err)
+	}
+	return engine, nil
+}
+
+// Tables 返回已加载的表
+func (e *DB) Tables() []TableInfo {
+	return e.tables
+}
+
+// Close 释放数据库
+func (e *DB) Close() error {
+	return e.db.Close()
+}
+
+// Query 校验并执行只读查询，只能访问已加载的表，最多返回 maxRows 行（<=0 时使用默认值）。
+// 每次查询都记录审计事件，包括被拒绝的查询
+func (e *DB) Query(ctx context.Context, query string, maxRows int) (*Result, error) {
+	result, err := e.query(ctx, query, maxRows)
+	detail := map[string]interface{}{"sql": query}
+	if result != nil {
+		detail["rows"] = len(result.Rows)
+	}
+	audit.Record(ctx, audit.Event{Action: audit.ActionSQLQuery, Detail: detail}.WithError(err))
+	return result, err
+}
+
+// query 执行查询
+func (e *DB) query(ctx context.Context, query string, maxRows int) (*Result, error) {
+	names := make([]string, len(e.tables))
+	for i, table := range e.tables {
+		names[i] = table.Name
+	}
+	// 多取一行用于判断结果是否被截断
+	checked, err := sqlguard.Check(query, sqlguard.Options{
+		Dialect:       sqlguard.DialectSQLite,
+		AllowedTables: names,
+		MaxRows:       RowLimit(maxRows) + 1,
+		MaxOffset:     MaxOffset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sqlguard.CheckCost(ctx, e.db, sqlguard.DialectSQLite, checked.SQL, 0); err != nil {
+		return nil, err
+	}
+
+	rows, err := e.db.QueryContext(ctx, checked.SQL)
+	if err != nil {
+		return nil, fmt.Errorf("SQL执行失败: %w", err)
+	}
+	defer rows.Close()
+	return ReadResult(rows, maxRows)
+}
+
+// RowLimit 返回实际使用的行数限制，<=0 时使用默认值，不超过 MaxRowsLimit
+func RowLimit(maxRows int) int {
+	if maxRows <= 0 {
+		return DefaultMaxRows
+	}
+	return min(maxRows, MaxRowsLimit)
+}
+
+// ReadResult 读取查询结果，最多 maxRows 行（<=0 时使用默认值），超出部分标记为截断
+func ReadResult(rows *sql.Rows, maxRows int) (*Result, error) {
+	maxRows = RowLimit(maxRows)
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Columns: columns, Rows: [][]interface{}{}}
+	for rows.Next() {
+		if len(result.Rows) >= maxRows {
+			result.Truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("读取查询结果失败: %w", err)
+		}
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("SQL执行失败: %w", err)
+	}
+
+	result.RowCount = len(result.Rows)
+	return result, nil
+}
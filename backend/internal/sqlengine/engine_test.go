@@ -0,0 +1,100 @@
+package sqlengine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDB_CrossFileJoin(t *testing.T) {
+	dir := t.TempDir()
+	orders := writeFile(t, dir, "orders.csv", "order_id,user_id,amount\n1,u1,10.5\n2,u2,20\n3,u1,5\n4,u3,\n")
+	users := writeFile(t, dir, "users.json", `[{"user_id":"u1","city":"上海"},{"user_id":"u2","city":"北京"}]`)
+
+	ctx := context.Background()
+	db, err := Open(ctx, []Source{{Name: "Orders 2024", Path: orders}, {Path: users}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tables := db.Tables()
+	if len(tables) != 2 || tables[0].Name != "orders_2024" || tables[1].Name != "users" {
+		t.Fatalf("表名不正确: %+v", tables)
+	}
+	wantColumns := []Column{{"order_id", TypeInteger}, {"user_id", TypeText}, {"amount", TypeReal}}
+	if !reflect.DeepEqual(tables[0].Columns, wantColumns) || tables[0].Rows != 4 {
+		t.Errorf("列类型推断不正确: %+v", tables[0])
+	}
+
+	result, err := db.Query(ctx, `SELECT u.city, SUM(o.amount) AS total, COUNT(*) AS orders
+		FROM orders_2024 o JOIN users u ON o.user_id = u.user_id
+		GROUP BY u.city ORDER BY total DESC;`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{{"北京", 20.0, int64(1)}, {"上海", 15.5, int64(2)}}
+	if !reflect.DeepEqual(result.Columns, []string{"city", "total", "orders"}) || !reflect.DeepEqual(result.Rows, want) {
+		t.Errorf("关联查询结果不正确: %v %v", result.Columns, result.Rows)
+	}
+
+	limited, err := db.Query(ctx, "SELECT * FROM orders_2024", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limited.RowCount != 2 || !limited.Truncated {
+		t.Errorf("行数限制不正确: %+v", limited)
+	}
+}
+
+func TestDB_ReadOnly(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "sales.csv", "region,sales\n华东,1\n")
+	ctx := context.Background()
+	db, err := Open(ctx, []Source{{Path: path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, query := range []string{
+		"DELETE FROM sales",
+		"DROP TABLE sales",
+		"SELECT 1; DROP TABLE sales",
+		"WITH x AS (SELECT 1) DELETE FROM sales",
+		"",
+	} {
+		if _, err := db.Query(ctx, query, 0); err == nil {
+			t.Errorf("应拒绝执行: %q", query)
+		}
+	}
+
+	// 字符串和注释中的分号不是语句分隔符
+	result, err := db.Query(ctx, "SELECT ';' AS s, sales FROM sales -- 注释;", 0)
+	if err != nil || result.RowCount != 1 {
+		t.Errorf("查询失败: %v %+v", err, result)
+	}
+}
+
+func TestTableName(t *testing.T) {
+	cases := map[string]string{
+		"Sales Report (2024)": "sales_report_2024",
+		"2024销售":              "t_2024销售",
+		"--":                  "t",
+	}
+	for input, want := range cases {
+		if got := TableName(input); got != want {
+			t.Errorf("TableName(%q) = %q，期望 %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package sqlengine
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"smart-analysis/internal/analytics"
+	"smart-analysis/internal/utils"
+)
+
+// 列类型
+const (
+	TypeInteger = "INTEGER"
+	TypeReal    = "REAL"
+	TypeText    = "TEXT"
+)
+
+// load 加载一个数据文件，Excel文件的每个工作表各生成一张表
+func (e *DB) load(ctx context.Context, source Source, used map[string]bool) error {
+	base := source.Name
+	if base == "" {
+		base = strings.TrimSuffix(filepath.Base(source.Path), filepath.Ext(source.Path))
+	}
+
+	switch strings.ToLower(filepath.Ext(source.Path)) {
+	case ".xlsx", ".xls":
+		sheets, err := utils.ParseExcelSheets(source.Path)
+		if err != nil {
+			return fmt.Errorf("解析Excel文件 %s 失败: %w", source.Path, err)
+		}
+		for _, sheet := range sheets {
+			name := base
+			if len(sheets) > 1 {
+				name = base + "_" + sheet.Name
+			}
+			table := &analytics.Table{Columns: sheet.Data.Headers, Rows: make([][]interface{}, 0, len(sheet.Data.Rows))}
+			for _, record := range sheet.Data.Rows {
+				row := make([]interface{}, len(record))
+				for i, value := range record {
+					row[i] = value
+				}
+				table.Rows = append(table.Rows, row)
+			}
+			if table, err = transform(source, sheet.Name, table); err != nil {
+				return err
+			}
+			if err := e.createTable(ctx, uniqueName(TableName(name), used), source.Path, sheet.Name, table); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		table, err := analytics.LoadTable(source.Path)
+		if err != nil {
+			return err
+		}
+		if table, err = transform(source, "", table); err != nil {
+			return err
+		}
+		return e.createTable(ctx, uniqueName(TableName(base), used), source.Path, "", table)
+	}
+}
+
+// transform 按数据源的 Transform 处理表数据
+func transform(source Source, sheet string, table *analytics.Table) (*analytics.Table, error) {
+	if source.Transform == nil {
+		return table, nil
+	}
+	transformed, err := source.Transform(sheet, table)
+	if err != nil {
+		return nil, fmt.Errorf("处理数据文件 %s 失败: %w", source.Path, err)
+	}
+	return transformed, nil
+}
+
+// createTable 按推断的列类型建表并写入数据
+func (e *DB) createTable(ctx context.Context, name, path, sheet string, table *analytics.Table) error {
+	info := TableInfo{Name: name, Path: path, Sheet: sheet, Columns: InferColumns(table), Rows: len(table.Rows)}
+	definitions := make([]string, len(info.Columns))
+	for i, col := range info.Columns {
+		definitions[i] = quoteIdent(col.Name) + " " + col.Type
+	}
+	if len(definitions) == 0 {
+		return fmt.Errorf("数据文件 %s 没有列", path)
+	}
+
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdent(name), strings.Join(definitions, ", "))); err != nil {
+		return fmt.Errorf("创建表 %s 失败: %w", name, err)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(info.Columns)), ", ")
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s VALUES (%s)", quoteIdent(name), placeholders))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, len(info.Columns))
+	for _, row := range table.Rows {
+		for i, col := range info.Columns {
+			var value interface{}
+			if i < len(row) {
+				value = row[i]
+			}
+			args[i] = convertValue(value, col.Type)
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("写入表 %s 失败: %w", name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	e.tables = append(e.tables, info)
+	return nil
+}
+
+// InferColumns 推断表的列名和列类型，列名去掉首尾空白，空列名和重名的列自动命名
+func InferColumns(table *analytics.Table) []Column {
+	used := make(map[string]bool)
+	columns := make([]Column, len(table.Columns))
+	for i, column := range table.Columns {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			column = fmt.Sprintf("column_%d", i+1)
+		}
+		columns[i] = Column{Name: uniqueName(column, used), Type: inferType(table, i)}
+	}
+	return columns
+}
+
+// inferType 推断列类型：非空值都是整数时为 INTEGER，都是数值时为 REAL，否则为 TEXT
+func inferType(table *analytics.Table, idx int) string {
+	colType := ""
+	for _, row := range table.Rows {
+		if idx >= len(row) {
+			continue
+		}
+		s := strings.TrimSpace(analytics.CellString(row[idx]))
+		if s == "" {
+			continue
+		}
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			if colType == "" {
+				colType = TypeInteger
+			}
+			continue
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			colType = TypeReal
+			continue
+		}
+		return TypeText
+	}
+	if colType == "" {
+		return TypeText
+	}
+	return colType
+}
+
+// convertValue 按列类型转换单元格的值，空值写入NULL
+func convertValue(value interface{}, colType string) interface{} {
+	s := strings.TrimSpace(analytics.CellString(value))
+	if s == "" {
+		return nil
+	}
+	switch colType {
+	case TypeInteger:
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case TypeReal:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return analytics.CellString(value)
+}
+
+// TableName 将文件名或工作表名规范为表名：只保留字母、数字和下划线，统一小写，不以数字开头
+func TableName(name string) string {
+	var b strings.Builder
+	lastUnderscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			lastUnderscore = false
+		} else if !lastUnderscore {
+			b.WriteRune('_')
+			lastUnderscore = true
+		}
+	}
+
+	table := strings.Trim(b.String(), "_")
+	if table == "" {
+		return "t"
+	}
+	if unicode.IsDigit([]rune(table)[0]) {
+		table = "t_" + table
+	}
+	return table
+}
+
+// uniqueName 名称重复（不区分大小写）时追加序号
+func uniqueName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[strings.ToLower(unique)]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
+
+// quoteIdent 用双引号引用标识符
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
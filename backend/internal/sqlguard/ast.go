@@ -0,0 +1,366 @@
+package sqlguard
+
+// Node 语法树节点
+type Node interface {
+	children() []Node
+}
+
+// Walk 深度优先遍历语法树，fn 返回 false 时不再进入该节点的子节点
+func Walk(node Node, fn func(Node) bool) {
+	if node == nil || !fn(node) {
+		return
+	}
+	for _, child := range node.children() {
+		if child != nil {
+			Walk(child, fn)
+		}
+	}
+}
+
+// Query 完整的查询：WITH 子句、查询体（SELECT 或集合运算）、ORDER BY 和 LIMIT
+type Query struct {
+	With    *With
+	Body    Node // *Select、*SetOp 或 *Query
+	OrderBy []*OrderItem
+	Limit   *Limit
+	Locking string // FOR UPDATE / FOR SHARE 等加锁子句
+}
+
+// With 公用表表达式
+type With struct {
+	Recursive bool
+	CTEs      []*CTE
+}
+
+// CTE 单个公用表表达式
+type CTE struct {
+	Name    string
+	Columns []string
+	Query   *Query
+}
+
+// SetOp 集合运算：UNION、INTERSECT、EXCEPT
+type SetOp struct {
+	Op    string
+	All   bool
+	Left  Node
+	Right Node
+}
+
+// Select 单个 SELECT
+type Select struct {
+	Distinct bool
+	Columns  []*SelectItem
+	From     []Node
+	Where    Node
+	GroupBy  []Node
+	Having   Node
+	Windows  []*WindowDef
+}
+
+// SelectItem 查询列，Star 为真时表示 * 或 t.*
+type SelectItem struct {
+	Expr  Node
+	Star  bool
+	Table string
+	Alias string
+}
+
+// WindowDef WINDOW 子句中的命名窗口
+type WindowDef struct {
+	Name string
+	Spec *WindowSpec
+}
+
+// TableName 表引用
+type TableName struct {
+	Schema string
+	Name   string
+	Alias  string
+}
+
+// QualifiedName 返回带 schema 的表名
+func (t *TableName) QualifiedName() string {
+	if t.Schema != "" {
+		return t.Schema + "." + t.Name
+	}
+	return t.Name
+}
+
+// DerivedTable FROM 中的子查询
+type DerivedTable struct {
+	Query   *Query
+	Alias   string
+	Lateral bool
+}
+
+// TableFunc FROM 中的表函数，如 generate_series、json_each
+type TableFunc struct {
+	Func  *FuncCall
+	Alias string
+}
+
+// Join 表连接
+type Join struct {
+	Kind  string // JOIN, LEFT JOIN, CROSS JOIN 等
+	Left  Node
+	Right Node
+	On    Node
+	Using []string
+}
+
+// OrderItem 排序项
+type OrderItem struct {
+	Expr Node
+	Desc bool
+}
+
+// Limit 行数限制。Count 为空表示不限制（LIMIT ALL），countStart/countEnd 和 offsetStart/offsetEnd
+// 为行数和偏移量在原始SQL中的位置
+type Limit struct {
+	Count       Node
+	Offset      Node
+	countStart  int
+	countEnd    int
+	offsetStart int
+	offsetEnd   int
+}
+
+// ColumnRef 列引用，可能带表名
+type ColumnRef struct {
+	Parts []string
+}
+
+// Literal 常量
+type Literal struct {
+	Kind  string // number, string, null, bool
+	Value string
+}
+
+// Param 占位符
+type Param struct {
+	Name string
+}
+
+// FuncCall 函数调用
+type FuncCall struct {
+	Schema   string
+	Name     string
+	Distinct bool
+	Star     bool
+	Args     []Node
+	OrderBy  []*OrderItem
+	Filter   Node
+	Over     *WindowSpec
+}
+
+// WindowSpec 窗口定义
+type WindowSpec struct {
+	Name        string
+	PartitionBy []Node
+	OrderBy     []*OrderItem
+	Frame       string
+}
+
+// BinaryExpr 二元运算
+type BinaryExpr struct {
+	Op    string
+	Left  Node
+	Right Node
+}
+
+// UnaryExpr 一元运算
+type UnaryExpr struct {
+	Op   string
+	Expr Node
+}
+
+// IsExpr IS [NOT] NULL / TRUE / FALSE / DISTINCT FROM
+type IsExpr struct {
+	Expr  Node
+	Not   bool
+	Value string
+	Right Node
+}
+
+// InExpr IN 列表或子查询
+type InExpr struct {
+	Expr     Node
+	Not      bool
+	List     []Node
+	Subquery *Query
+}
+
+// BetweenExpr BETWEEN 范围
+type BetweenExpr struct {
+	Expr Node
+	Not  bool
+	Low  Node
+	High Node
+}
+
+// LikeExpr LIKE、ILIKE、GLOB、REGEXP 等模式匹配
+type LikeExpr struct {
+	Op      string
+	Expr    Node
+	Not     bool
+	Pattern Node
+	Escape  Node
+}
+
+// CaseExpr CASE 表达式
+type CaseExpr struct {
+	Operand Node
+	Whens   []*When
+	Else    Node
+}
+
+// When CASE 的分支
+type When struct {
+	Cond   Node
+	Result Node
+}
+
+// CastExpr 类型转换：CAST(x AS type) 或 x::type
+type CastExpr struct {
+	Expr Node
+	Type string
+}
+
+// SubqueryExpr 标量子查询或 EXISTS 子查询
+type SubqueryExpr struct {
+	Exists bool
+	Query  *Query
+}
+
+// ListExpr 括号中的表达式列表（行构造器）
+type ListExpr struct {
+	Items []Node
+}
+
+// TypedLiteral 带类型的常量，如 DATE '2024-01-01'、INTERVAL '1 day'
+type TypedLiteral struct {
+	Type  string
+	Value string
+	Unit  string
+}
+
+func (q *Query) children() []Node {
+	nodes := []Node{}
+	if q.With != nil {
+		nodes = append(nodes, q.With)
+	}
+	nodes = append(nodes, q.Body)
+	nodes = appendOrder(nodes, q.OrderBy)
+	if q.Limit != nil {
+		nodes = append(nodes, q.Limit)
+	}
+	return nodes
+}
+
+func (w *With) children() []Node {
+	nodes := make([]Node, len(w.CTEs))
+	for i, cte := range w.CTEs {
+		nodes[i] = cte
+	}
+	return nodes
+}
+
+func (c *CTE) children() []Node { return []Node{c.Query} }
+
+func (s *SetOp) children() []Node { return []Node{s.Left, s.Right} }
+
+func (s *Select) children() []Node {
+	var nodes []Node
+	for _, item := range s.Columns {
+		nodes = append(nodes, item)
+	}
+	nodes = append(nodes, s.From...)
+	nodes = append(nodes, s.Where)
+	nodes = append(nodes, s.GroupBy...)
+	nodes = append(nodes, s.Having)
+	for _, w := range s.Windows {
+		nodes = append(nodes, w.Spec)
+	}
+	return nodes
+}
+
+func (s *SelectItem) children() []Node { return []Node{s.Expr} }
+
+func (w *WindowDef) children() []Node { return []Node{w.Spec} }
+
+func (t *TableName) children() []Node { return nil }
+
+func (d *DerivedTable) children() []Node { return []Node{d.Query} }
+
+func (t *TableFunc) children() []Node { return []Node{t.Func} }
+
+func (j *Join) children() []Node { return []Node{j.Left, j.Right, j.On} }
+
+func (o *OrderItem) children() []Node { return []Node{o.Expr} }
+
+func (l *Limit) children() []Node { return []Node{l.Count, l.Offset} }
+
+func (c *ColumnRef) children() []Node { return nil }
+
+func (l *Literal) children() []Node { return nil }
+
+func (p *Param) children() []Node { return nil }
+
+func (f *FuncCall) children() []Node {
+	nodes := append([]Node{}, f.Args...)
+	nodes = appendOrder(nodes, f.OrderBy)
+	nodes = append(nodes, f.Filter)
+	if f.Over != nil {
+		nodes = append(nodes, f.Over)
+	}
+	return nodes
+}
+
+func (w *WindowSpec) children() []Node {
+	nodes := append([]Node{}, w.PartitionBy...)
+	return appendOrder(nodes, w.OrderBy)
+}
+
+func (b *BinaryExpr) children() []Node { return []Node{b.Left, b.Right} }
+
+func (u *UnaryExpr) children() []Node { return []Node{u.Expr} }
+
+func (i *IsExpr) children() []Node { return []Node{i.Expr, i.Right} }
+
+func (i *InExpr) children() []Node {
+	nodes := append([]Node{i.Expr}, i.List...)
+	if i.Subquery != nil {
+		nodes = append(nodes, i.Subquery)
+	}
+	return nodes
+}
+
+func (b *BetweenExpr) children() []Node { return []Node{b.Expr, b.Low, b.High} }
+
+func (l *LikeExpr) children() []Node { return []Node{l.Expr, l.Pattern, l.Escape} }
+
+func (c *CaseExpr) children() []Node {
+	nodes := []Node{c.Operand}
+	for _, w := range c.Whens {
+		nodes = append(nodes, w)
+	}
+	return append(nodes, c.Else)
+}
+
+func (w *When) children() []Node { return []Node{w.Cond, w.Result} }
+
+func (c *CastExpr) children() []Node { return []Node{c.Expr} }
+
+func (s *SubqueryExpr) children() []Node { return []Node{s.Query} }
+
+func (l *ListExpr) children() []Node { return l.Items }
+
+func (t *TypedLiteral) children() []Node { return nil }
+
+func appendOrder(nodes []Node, items []*OrderItem) []Node {
+	for _, item := range items {
+		nodes = append(nodes, item)
+	}
+	return nodes
+}
@@ -0,0 +1,219 @@
+package sqlguard
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxCost 默认的代价上限。PostgreSQL 和 MySQL 为优化器估算的代价，
+// SQLite 为全表扫描行数在嵌套循环中的乘积
+const DefaultMaxCost = 1e8
+
+// Querier 可执行查询的连接、连接池或事务
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Plan EXPLAIN 的估算结果
+type Plan struct {
+	Cost    float64  `json:"cost"`
+	Rows    float64  `json:"rows,omitempty"`
+	Details []string `json:"details,omitempty"`
+}
+
+// Explain 用 EXPLAIN 估算查询代价，不执行查询本身
+func Explain(ctx context.Context, db Querier, dialect, query string) (*Plan, error) {
+	switch dialect {
+	case DialectPostgres:
+		return explainPostgres(ctx, db, query)
+	case DialectMySQL:
+		return explainMySQL(ctx, db, query)
+	case DialectSQLite:
+		return explainSQLite(ctx, db, query)
+	default:
+		return nil, fmt.Errorf("不支持的SQL方言: %s", dialect)
+	}
+}
+
+// CheckCost 估算查询代价，超过 maxCost（<=0 时使用默认值）时返回错误
+func CheckCost(ctx context.Context, db Querier, dialect, query string, maxCost float64) (*Plan, error) {
+	if maxCost <= 0 {
+		maxCost = DefaultMaxCost
+	}
+	plan, err := Explain(ctx, db, dialect, query)
+	if err != nil {
+		return nil, fmt.Errorf("EXPLAIN 失败: %w", err)
+	}
+	if plan.Cost > maxCost {
+		return plan, fmt.Errorf("查询估算代价 %.0f 超过上限 %.0f，请增加过滤条件、避免笛卡尔积或先聚合再关联", plan.Cost, maxCost)
+	}
+	return plan, nil
+}
+
+func explainPostgres(ctx context.Context, db Querier, query string) (*Plan, error) {
+	var output string
+	if err := queryOne(ctx, db, "EXPLAIN (FORMAT JSON) "+query, &output); err != nil {
+		return nil, err
+	}
+	var plans []struct {
+		Plan struct {
+			NodeType  string  `json:"Node Type"`
+			TotalCost float64 `json:"Total Cost"`
+			PlanRows  float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(output), &plans); err != nil || len(plans) == 0 {
+		return nil, fmt.Errorf("无法解析执行计划: %s", output)
+	}
+	root := plans[0].Plan
+	return &Plan{Cost: root.TotalCost, Rows: root.PlanRows, Details: []string{root.NodeType}}, nil
+}
+
+func explainMySQL(ctx context.Context, db Querier, query string) (*Plan, error) {
+	var output string
+	if err := queryOne(ctx, db, "EXPLAIN FORMAT=JSON "+query, &output); err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	if err := json.Unmarshal([]byte(output), &tree); err != nil {
+		return nil, fmt.Errorf("无法解析执行计划: %w", err)
+	}
+
+	// UNION 等查询有多个查询块，代价累加
+	plan := &Plan{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				switch key {
+				case "query_cost":
+					plan.Cost += parseFloat(child)
+				case "rows_produced_per_join":
+					plan.Rows = max(plan.Rows, parseFloat(child))
+				case "table_name":
+					if name, ok := child.(string); ok {
+						plan.Details = append(plan.Details, name)
+					}
+				}
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(tree)
+	return plan, nil
+}
+
+// explainSQLite 解析 EXPLAIN QUERY PLAN。SQLite 不提供代价估算，同一层的全表扫描按嵌套循环相乘，
+// 不同层（子查询、UNION 的各部分）相加
+func explainSQLite(ctx context.Context, db Querier, query string) (*Plan, error) {
+	rows, err := db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query)
+	if err != nil {
+		return nil, err
+	}
+	type step struct {
+		parent int
+		detail string
+	}
+	var steps []step
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		steps = append(steps, step{parent: parent, detail: detail})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	groups := make(map[int]float64)
+	counts := make(map[string]float64)
+	for _, s := range steps {
+		plan.Details = append(plan.Details, s.detail)
+		table, ok := fullScanTable(s.detail)
+		if !ok {
+			continue
+		}
+		n, cached := counts[table]
+		if !cached {
+			n = sqliteRowCount(ctx, db, table)
+			counts[table] = n
+		}
+		if _, exists := groups[s.parent]; !exists {
+			groups[s.parent] = 1
+		}
+		groups[s.parent] *= max(n, 1)
+	}
+	for _, cost := range groups {
+		plan.Cost += cost
+	}
+	plan.Rows = plan.Cost
+	return plan, nil
+}
+
+// fullScanTable 从 "SCAN t"、"SCAN TABLE t AS x" 中提取全表扫描的表名，使用索引的扫描不计入
+func fullScanTable(detail string) (string, bool) {
+	fields := strings.Fields(detail)
+	if len(fields) < 2 || fields[0] != "SCAN" || strings.Contains(detail, " USING ") {
+		return "", false
+	}
+	name := fields[1]
+	if name == "TABLE" && len(fields) > 2 {
+		name = fields[2]
+	}
+	if name == "CONSTANT" || strings.HasPrefix(name, "(") {
+		return "", false
+	}
+	return name, true
+}
+
+// sqliteRowCount 统计表行数，统计失败（如扫描的是CTE或子查询）时返回1
+func sqliteRowCount(ctx context.Context, db Querier, table string) float64 {
+	var n float64
+	if err := queryOne(ctx, db, `SELECT COUNT(*) FROM "`+strings.ReplaceAll(table, `"`, `""`)+`"`, &n); err != nil {
+		return 1
+	}
+	return n
+}
+
+func queryOne(ctx context.Context, db Querier, query string, dest interface{}) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err := rows.Scan(dest); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+func parseFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	return 0
+}
@@ -0,0 +1,668 @@
+package sqlguard
+
+import (
+	"strings"
+)
+
+// 比较运算符
+var comparisonOps = map[string]bool{
+	"=": true, "<>": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true, "<=>": true,
+	"~": true, "~*": true, "!~": true, "!~*": true, "->": true, "->>": true, "&&": true,
+}
+
+// typeNameWords 多个单词组成的类型名中第一个单词之后可能出现的单词
+var typeNameWords = []string{"PRECISION", "VARYING", "WITH", "WITHOUT", "TIME", "ZONE", "UNSIGNED", "SIGNED", "INTEGER", "INT"}
+
+// 类型常量前缀，如 DATE '2024-01-01'
+var typedLiteralTypes = map[string]bool{
+	"DATE": true, "TIME": true, "TIMESTAMP": true, "TIMESTAMPTZ": true, "INTERVAL": true,
+}
+
+func (p *parser) parseExprList() ([]Node, error) {
+	var exprs []Node
+	for {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		if !p.acceptOp(",") {
+			return exprs, nil
+		}
+	}
+}
+
+// parseExpr 按优先级从低到高解析：OR、AND、NOT、比较、位运算和拼接、加减、乘除、一元运算、后缀
+func (p *parser) parseExpr() (Node, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("OR", "XOR") || p.dialect == DialectMySQL && p.peekOp("||") {
+		op := strings.ToUpper(p.advance().text)
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("AND") || p.peekOp("&&") && p.dialect == DialectMySQL {
+		p.advance()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "AND", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Node, error) {
+	if p.acceptKeyword("NOT") {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "NOT", Expr: expr}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	left, err := p.parseBitwise()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		switch {
+		case tok.kind == tokenOp && comparisonOps[tok.text] && !(tok.text == "&&" && p.dialect == DialectMySQL):
+			p.advance()
+			// 子查询比较：= ANY (SELECT ...)
+			p.acceptKeyword("ANY", "ALL", "SOME")
+			right, err := p.parseBitwise()
+			if err != nil {
+				return nil, err
+			}
+			left = &BinaryExpr{Op: tok.text, Left: left, Right: right}
+
+		case tok.keyword("IS"):
+			p.advance()
+			is := &IsExpr{Expr: left, Not: p.acceptKeyword("NOT")}
+			switch {
+			case p.acceptKeyword("NULL", "TRUE", "FALSE", "UNKNOWN"):
+				is.Value = strings.ToUpper(p.prev().text)
+			case p.acceptKeywords("DISTINCT", "FROM"):
+				is.Value = "DISTINCT FROM"
+				if is.Right, err = p.parseBitwise(); err != nil {
+					return nil, err
+				}
+			default:
+				// SQLite 允许 IS 比较任意表达式
+				is.Value = "EXPR"
+				if is.Right, err = p.parseBitwise(); err != nil {
+					return nil, err
+				}
+			}
+			left = is
+
+		case tok.keyword("ISNULL", "NOTNULL"):
+			p.advance()
+			left = &IsExpr{Expr: left, Not: tok.keyword("NOTNULL"), Value: "NULL"}
+
+		default:
+			not := false
+			if tok.keyword("NOT") && p.peekAt(1).keyword("IN", "BETWEEN", "LIKE", "ILIKE", "GLOB", "REGEXP", "RLIKE", "SIMILAR", "MATCH") {
+				p.advance()
+				not = true
+				tok = p.peek()
+			}
+			switch {
+			case tok.keyword("IN"):
+				p.advance()
+				if left, err = p.parseIn(left, not); err != nil {
+					return nil, err
+				}
+			case tok.keyword("BETWEEN"):
+				p.advance()
+				p.acceptKeyword("SYMMETRIC")
+				low, err := p.parseBitwise()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expectKeyword("AND"); err != nil {
+					return nil, err
+				}
+				high, err := p.parseBitwise()
+				if err != nil {
+					return nil, err
+				}
+				left = &BetweenExpr{Expr: left, Not: not, Low: low, High: high}
+			case tok.keyword("LIKE", "ILIKE", "GLOB", "REGEXP", "RLIKE", "SIMILAR", "MATCH"):
+				p.advance()
+				op := strings.ToUpper(tok.text)
+				if op == "SIMILAR" {
+					if err := p.expectKeyword("TO"); err != nil {
+						return nil, err
+					}
+					op = "SIMILAR TO"
+				}
+				like := &LikeExpr{Op: op, Expr: left, Not: not}
+				if like.Pattern, err = p.parseBitwise(); err != nil {
+					return nil, err
+				}
+				if p.acceptKeyword("ESCAPE") {
+					if like.Escape, err = p.parseBitwise(); err != nil {
+						return nil, err
+					}
+				}
+				left = like
+			default:
+				if not {
+					return nil, p.errorf(tok, "NOT 后缺少 IN、BETWEEN 或 LIKE")
+				}
+				return left, nil
+			}
+		}
+	}
+}
+
+func (p *parser) parseIn(left Node, not bool) (Node, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	in := &InExpr{Expr: left, Not: not}
+	if p.peek().keyword("SELECT", "WITH") {
+		query, err := p.parseQuery()
+		if err != nil {
+			return nil, err
+		}
+		in.Subquery = query
+	} else if !p.peekOp(")") {
+		list, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+		in.List = list
+	}
+	return in, p.expectOp(")")
+}
+
+func (p *parser) parseBitwise() (Node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != tokenOp || !(tok.text == "|" || tok.text == "&" || tok.text == "<<" || tok.text == ">>" || tok.text == "^" ||
+			tok.text == "||" && p.dialect != DialectMySQL) {
+			return left, nil
+		}
+		p.advance()
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: tok.text, Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseAdditive() (Node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOp("+") || p.peekOp("-") {
+		op := p.advance().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOp("*") || p.peekOp("/") || p.peekOp("%") || p.peek().keyword("DIV", "MOD") {
+		op := strings.ToUpper(p.advance().text)
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.peekOp("-") || p.peekOp("+") || p.peekOp("~") || p.peekOp("!") {
+		op := p.advance().text
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: op, Expr: expr}, nil
+	}
+	return p.parsePostfix()
+}
+
+// parsePostfix 解析 x::type、x COLLATE name 和数组下标
+func (p *parser) parsePostfix() (Node, error) {
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.acceptOp("::"):
+			typeName, err := p.parseTypeName()
+			if err != nil {
+				return nil, err
+			}
+			expr = &CastExpr{Expr: expr, Type: typeName}
+		case p.acceptKeyword("COLLATE"):
+			if _, err := p.anyIdentifier(); err != nil {
+				if p.peek().kind != tokenString {
+					return nil, err
+				}
+				p.advance()
+			}
+		case p.dialect == DialectPostgres && p.acceptOp("["):
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp("]"); err != nil {
+				return nil, err
+			}
+			expr = &BinaryExpr{Op: "[]", Left: expr, Right: index}
+		default:
+			return expr, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokenNumber:
+		p.advance()
+		return &Literal{Kind: "number", Value: tok.text}, nil
+	case tokenString:
+		p.advance()
+		return &Literal{Kind: "string", Value: tok.text}, nil
+	case tokenParam:
+		p.advance()
+		return &Param{Name: tok.text}, nil
+	case tokenOp:
+		if tok.text == "(" {
+			return p.parseParen()
+		}
+		return nil, p.errorf(tok, "表达式中不应出现 %s", tok.describe())
+	case tokenEOF:
+		return nil, p.errorf(tok, "表达式不完整")
+	}
+
+	if tok.kind == tokenIdent {
+		switch upper := strings.ToUpper(tok.text); {
+		case upper == "NULL":
+			p.advance()
+			return &Literal{Kind: "null", Value: "NULL"}, nil
+		case upper == "TRUE" || upper == "FALSE":
+			p.advance()
+			return &Literal{Kind: "bool", Value: upper}, nil
+		case upper == "CASE":
+			return p.parseCase()
+		case upper == "EXISTS":
+			p.advance()
+			if err := p.expectOp("("); err != nil {
+				return nil, err
+			}
+			query, err := p.parseQuery()
+			if err != nil {
+				return nil, err
+			}
+			return &SubqueryExpr{Exists: true, Query: query}, p.expectOp(")")
+		case (upper == "CAST" || upper == "TRY_CAST") && p.peekAt(1).kind == tokenOp && p.peekAt(1).text == "(":
+			return p.parseCast()
+		case typedLiteralTypes[upper] && p.peekAt(1).kind == tokenString:
+			p.advance()
+			lit := &TypedLiteral{Type: upper, Value: p.advance().text}
+			if upper == "INTERVAL" && p.peek().kind == tokenIdent && !reserved[strings.ToUpper(p.peek().text)] {
+				lit.Unit = strings.ToUpper(p.advance().text)
+			}
+			return lit, nil
+		case upper == "INTERVAL" && p.dialect == DialectMySQL:
+			// MySQL: INTERVAL expr unit
+			p.advance()
+			value, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			unit, err := p.anyIdentifier()
+			if err != nil {
+				return nil, err
+			}
+			return &CastExpr{Expr: value, Type: "INTERVAL " + strings.ToUpper(unit)}, nil
+		case upper == "ARRAY" && p.peekAt(1).kind == tokenOp && p.peekAt(1).text == "[":
+			p.pos += 2
+			var items []Node
+			if !p.peekOp("]") {
+				var err error
+				if items, err = p.parseExprList(); err != nil {
+					return nil, err
+				}
+			}
+			return &ListExpr{Items: items}, p.expectOp("]")
+		}
+	}
+
+	// 标识符：列引用或函数调用
+	if tok.kind == tokenIdent && reserved[strings.ToUpper(tok.text)] {
+		return nil, p.errorf(tok, "表达式中不应出现关键字 %s", strings.ToUpper(tok.text))
+	}
+	parts := []string{p.advance().text}
+	for p.peekOp(".") {
+		p.advance()
+		if p.peekOp("*") {
+			return nil, p.errorf(p.peek(), "此处不能使用 *")
+		}
+		part, err := p.anyIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	if p.peekOp("(") && tok.kind == tokenIdent {
+		return p.parseFuncArgs(parts)
+	}
+	return &ColumnRef{Parts: parts}, nil
+}
+
+// parseParen 解析括号表达式、行构造器或标量子查询
+func (p *parser) parseParen() (Node, error) {
+	if p.startsQueryAfterParen() {
+		p.advance()
+		query, err := p.parseQuery()
+		if err != nil {
+			return nil, err
+		}
+		return &SubqueryExpr{Query: query}, p.expectOp(")")
+	}
+
+	p.advance()
+	items, err := p.parseExprList()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+	if len(items) == 1 {
+		return items[0], nil
+	}
+	return &ListExpr{Items: items}, nil
+}
+
+// startsQueryAfterParen 判断当前括号开始的是否为子查询
+func (p *parser) startsQueryAfterParen() bool {
+	for i := p.pos + 1; i < len(p.tokens); i++ {
+		tok := p.tokens[i]
+		if tok.kind == tokenOp && tok.text == "(" {
+			continue
+		}
+		return tok.keyword("SELECT", "WITH")
+	}
+	return false
+}
+
+func (p *parser) parseCase() (Node, error) {
+	p.advance() // CASE
+	expr := &CaseExpr{}
+	var err error
+	if !p.peek().keyword("WHEN") {
+		if expr.Operand, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	for p.acceptKeyword("WHEN") {
+		when := &When{}
+		if when.Cond, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("THEN"); err != nil {
+			return nil, err
+		}
+		if when.Result, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		expr.Whens = append(expr.Whens, when)
+	}
+	if len(expr.Whens) == 0 {
+		return nil, p.errorf(p.peek(), "CASE 缺少 WHEN")
+	}
+	if p.acceptKeyword("ELSE") {
+		if expr.Else, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	return expr, p.expectKeyword("END")
+}
+
+func (p *parser) parseCast() (Node, error) {
+	p.pos += 2 // CAST (
+	value, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("AS"); err != nil {
+		return nil, err
+	}
+	typeName, err := p.parseTypeName()
+	if err != nil {
+		return nil, err
+	}
+	return &CastExpr{Expr: value, Type: typeName}, p.expectOp(")")
+}
+
+// parseTypeName 解析类型名，如 INTEGER、VARCHAR(20)、DOUBLE PRECISION、NUMERIC(10, 2)、TEXT[]
+func (p *parser) parseTypeName() (string, error) {
+	first, err := p.anyIdentifier()
+	if err != nil {
+		return "", p.errorf(p.peek(), "期望类型名，实际为 %s", p.peek().describe())
+	}
+	name := strings.ToUpper(first)
+	for p.acceptOp(".") {
+		part, err := p.anyIdentifier()
+		if err != nil {
+			return "", err
+		}
+		name += "." + strings.ToUpper(part)
+	}
+	for p.peek().keyword(typeNameWords...) {
+		name += " " + strings.ToUpper(p.advance().text)
+	}
+	if p.acceptOp("(") {
+		var args []string
+		for !p.peekOp(")") {
+			tok := p.advance()
+			if tok.kind != tokenNumber && tok.kind != tokenIdent && !(tok.kind == tokenOp && tok.text == ",") {
+				return "", p.errorf(tok, "类型参数不正确")
+			}
+			args = append(args, tok.text)
+		}
+		p.advance()
+		name += "(" + strings.Join(args, "") + ")"
+	}
+	for p.dialect == DialectPostgres && p.peekOp("[") && p.peekAt(1).kind == tokenOp && p.peekAt(1).text == "]" {
+		p.pos += 2
+		name += "[]"
+	}
+	return name, nil
+}
+
+// parseFuncArgs 解析函数参数及 FILTER、WITHIN GROUP、OVER 子句
+func (p *parser) parseFuncArgs(parts []string) (*FuncCall, error) {
+	call := &FuncCall{Name: parts[len(parts)-1]}
+	if len(parts) > 1 {
+		call.Schema = strings.Join(parts[:len(parts)-1], ".")
+	}
+	p.advance() // (
+
+	switch {
+	case p.acceptOp("*"):
+		call.Star = true
+	case p.peekOp(")"):
+	case strings.EqualFold(call.Name, "EXTRACT") && p.peekAt(1).keyword("FROM"):
+		// EXTRACT(field FROM expr)
+		field := p.advance()
+		p.advance()
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = []Node{&Literal{Kind: "string", Value: field.text}, value}
+	default:
+		if p.acceptKeyword("DISTINCT") {
+			call.Distinct = true
+		} else {
+			p.acceptKeyword("ALL")
+		}
+		args, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = args
+		if p.acceptKeywords("ORDER", "BY") {
+			if call.OrderBy, err = p.parseOrderList(); err != nil {
+				return nil, err
+			}
+		}
+		if p.acceptKeyword("SEPARATOR") {
+			// MySQL GROUP_CONCAT(x SEPARATOR ',')
+			sep, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, sep)
+		}
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+
+	if p.acceptKeywords("WITHIN", "GROUP") {
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("ORDER"); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		order, err := p.parseOrderList()
+		if err != nil {
+			return nil, err
+		}
+		call.OrderBy = append(call.OrderBy, order...)
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("FILTER") {
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("WHERE"); err != nil {
+			return nil, err
+		}
+		filter, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.Filter = filter
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("OVER") {
+		if p.isIdentifier() {
+			name, _ := p.identifier()
+			call.Over = &WindowSpec{Name: name}
+		} else {
+			spec, err := p.parseWindowSpec()
+			if err != nil {
+				return nil, err
+			}
+			call.Over = spec
+		}
+	}
+	return call, nil
+}
+
+// parseWindowSpec 解析 (PARTITION BY ... ORDER BY ... 窗口范围)
+func (p *parser) parseWindowSpec() (*WindowSpec, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	spec := &WindowSpec{}
+	var err error
+	if p.isIdentifier() && !p.peek().keyword("PARTITION", "ORDER", "ROWS", "RANGE", "GROUPS") {
+		spec.Name, _ = p.identifier()
+	}
+	if p.acceptKeywords("PARTITION", "BY") {
+		if spec.PartitionBy, err = p.parseExprList(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeywords("ORDER", "BY") {
+		if spec.OrderBy, err = p.parseOrderList(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek().keyword("ROWS", "RANGE", "GROUPS") {
+		// 窗口范围只允许关键字和数字常量
+		var words []string
+		for !p.peekOp(")") {
+			tok := p.advance()
+			if tok.kind != tokenIdent && tok.kind != tokenNumber {
+				return nil, p.errorf(tok, "窗口范围中不应出现 %s", tok.describe())
+			}
+			words = append(words, strings.ToUpper(tok.text))
+		}
+		spec.Frame = strings.Join(words, " ")
+	}
+	return spec, p.expectOp(")")
+}
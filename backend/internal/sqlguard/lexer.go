@@ -0,0 +1,316 @@
+package sqlguard
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 词法单元类型
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenQuoted // 加引号的标识符
+	tokenString
+	tokenNumber
+	tokenOp
+	tokenParam
+)
+
+// token 词法单元，start/end 为在原始SQL中的字节偏移
+type token struct {
+	kind  int
+	text  string // 标识符为原文，字符串为解码后的内容
+	start int
+	end   int
+}
+
+// keyword 判断是否为指定关键字（不区分大小写，引号标识符不算关键字）
+func (t token) keyword(words ...string) bool {
+	if t.kind != tokenIdent {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(t.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// describe 返回用于错误信息的描述
+func (t token) describe() string {
+	if t.kind == tokenEOF {
+		return "语句结尾"
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+// 多字符运算符，按长度从长到短匹配
+var operators = []string{
+	"!~*", "->>", "<=>",
+	"<>", "!=", "<=", ">=", "||", "::", "->", "<<", ">>", "~*", "!~", "&&",
+	"=", "<", ">", "+", "-", "*", "/", "%", "(", ")", ",", ".", ";", "&", "|", "^", "~", "!", "[", "]",
+}
+
+// lexer 按方言切分SQL
+type lexer struct {
+	src     string
+	dialect string
+	pos     int
+}
+
+// tokenize 切分SQL，注释被跳过
+func tokenize(src, dialect string) ([]token, error) {
+	l := &lexer{src: src, dialect: dialect}
+	var tokens []token
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.kind == tokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) errorf(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("位置 %d: %s", pos+1, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpaceAndComments(); err != nil {
+		return token{}, err
+	}
+	start := l.pos
+	if start >= len(l.src) {
+		return token{kind: tokenEOF, start: start, end: start}, nil
+	}
+
+	c := l.src[start]
+	switch {
+	case c == '\'':
+		text, err := l.quoted('\'', l.dialect == DialectMySQL)
+		return token{kind: tokenString, text: text, start: start, end: l.pos}, err
+	case (c == 'E' || c == 'e') && l.dialect == DialectPostgres && l.peek(1) == '\'':
+		l.pos++
+		text, err := l.quoted('\'', true)
+		return token{kind: tokenString, text: text, start: start, end: l.pos}, err
+	case (c == 'X' || c == 'x' || c == 'B' || c == 'b' || c == 'N' || c == 'n') && l.peek(1) == '\'':
+		// 十六进制、位串和国家字符集字符串
+		l.pos++
+		text, err := l.quoted('\'', l.dialect == DialectMySQL)
+		return token{kind: tokenString, text: text, start: start, end: l.pos}, err
+	case c == '"':
+		if l.dialect == DialectMySQL {
+			// MySQL 默认把双引号当作字符串
+			text, err := l.quoted('"', true)
+			return token{kind: tokenString, text: text, start: start, end: l.pos}, err
+		}
+		text, err := l.quoted('"', false)
+		return token{kind: tokenQuoted, text: text, start: start, end: l.pos}, err
+	case c == '`' && l.dialect != DialectPostgres:
+		text, err := l.quoted('`', false)
+		return token{kind: tokenQuoted, text: text, start: start, end: l.pos}, err
+	case c == '[' && l.dialect == DialectSQLite:
+		end := strings.IndexByte(l.src[start+1:], ']')
+		if end < 0 {
+			return token{}, l.errorf(start, "标识符缺少结束的 ]")
+		}
+		l.pos = start + end + 2
+		return token{kind: tokenQuoted, text: l.src[start+1 : start+end+1], start: start, end: l.pos}, nil
+	case c == '$' && l.dialect == DialectPostgres:
+		if tag, ok := l.dollarTag(); ok {
+			return l.dollarString(start, tag)
+		}
+		return l.param(start)
+	case c == '?' || c == ':' && isIdentStart(l.peekRune(1)) || c == '@' || c == '$':
+		return l.param(start)
+	case isDigit(c) || c == '.' && isDigit(l.peek(1)):
+		return l.number(start), nil
+	}
+
+	if r, size := utf8.DecodeRuneInString(l.src[start:]); isIdentStart(r) {
+		l.pos += size
+		for l.pos < len(l.src) {
+			r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			if !isIdentPart(r) {
+				break
+			}
+			l.pos += size
+		}
+		return token{kind: tokenIdent, text: l.src[start:l.pos], start: start, end: l.pos}, nil
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[start:], op) {
+			l.pos += len(op)
+			return token{kind: tokenOp, text: op, start: start, end: l.pos}, nil
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[start:])
+	return token{}, l.errorf(start, "无法识别的字符 %q", r)
+}
+
+// skipSpaceAndComments 跳过空白和注释。MySQL 的 /*! */ 注释会被当作SQL执行，直接拒绝
+func (l *lexer) skipSpaceAndComments() error {
+	for l.pos < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		switch {
+		case unicode.IsSpace(r):
+			l.pos += size
+		case strings.HasPrefix(l.src[l.pos:], "--"), r == '#' && l.dialect == DialectMySQL:
+			end := strings.IndexByte(l.src[l.pos:], '\n')
+			if end < 0 {
+				l.pos = len(l.src)
+			} else {
+				l.pos += end + 1
+			}
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			if l.dialect == DialectMySQL && strings.HasPrefix(l.src[l.pos:], "/*!") {
+				return l.errorf(l.pos, "不允许使用 /*! */ 可执行注释")
+			}
+			if err := l.blockComment(); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// blockComment 跳过块注释，PostgreSQL 支持嵌套
+func (l *lexer) blockComment() error {
+	start := l.pos
+	depth := 0
+	for l.pos < len(l.src) {
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			if depth > 0 && l.dialect != DialectPostgres {
+				l.pos += 2
+				continue
+			}
+			depth++
+			l.pos += 2
+		case strings.HasPrefix(l.src[l.pos:], "*/"):
+			depth--
+			l.pos += 2
+			if depth == 0 {
+				return nil
+			}
+		default:
+			l.pos++
+		}
+	}
+	return l.errorf(start, "注释缺少结束的 */")
+}
+
+// quoted 读取引号包围的内容，连续两个引号表示引号本身，backslash 为真时支持反斜杠转义
+func (l *lexer) quoted(quote byte, backslash bool) (string, error) {
+	start := l.pos
+	l.pos++
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case backslash && c == '\\' && l.pos+1 < len(l.src):
+			b.WriteByte(l.src[l.pos+1])
+			l.pos += 2
+		case c == quote && l.peek(1) == quote:
+			b.WriteByte(quote)
+			l.pos += 2
+		case c == quote:
+			l.pos++
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return "", l.errorf(start, "缺少结束的引号 %c", quote)
+}
+
+// dollarTag 识别 PostgreSQL 的 $tag$ 字符串起始标记
+func (l *lexer) dollarTag() (string, bool) {
+	i := l.pos + 1
+	for i < len(l.src) && (isDigit(l.src[i]) && i > l.pos+1 || l.src[i] == '_' || l.src[i] >= 'a' && l.src[i] <= 'z' || l.src[i] >= 'A' && l.src[i] <= 'Z') {
+		i++
+	}
+	if i < len(l.src) && l.src[i] == '$' {
+		return l.src[l.pos : i+1], true
+	}
+	return "", false
+}
+
+func (l *lexer) dollarString(start int, tag string) (token, error) {
+	body := start + len(tag)
+	end := strings.Index(l.src[body:], tag)
+	if end < 0 {
+		return token{}, l.errorf(start, "字符串缺少结束的 %s", tag)
+	}
+	l.pos = body + end + len(tag)
+	return token{kind: tokenString, text: l.src[body : body+end], start: start, end: l.pos}, nil
+}
+
+// param 读取占位符，占位符在校验时会被拒绝
+func (l *lexer) param(start int) (token, error) {
+	l.pos++
+	for l.pos < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		if !isIdentPart(r) {
+			break
+		}
+		l.pos += size
+	}
+	return token{kind: tokenParam, text: l.src[start:l.pos], start: start, end: l.pos}, nil
+}
+
+func (l *lexer) number(start int) token {
+	seenDot, seenExp := false, false
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case isDigit(c):
+		case c == '.' && !seenDot && !seenExp:
+			seenDot = true
+		case (c == 'e' || c == 'E') && !seenExp && (isDigit(l.peek(1)) || (l.peek(1) == '+' || l.peek(1) == '-') && isDigit(l.peek(2))):
+			seenExp = true
+			l.pos++
+		default:
+			return token{kind: tokenNumber, text: l.src[start:l.pos], start: start, end: l.pos}
+		}
+		l.pos++
+	}
+	return token{kind: tokenNumber, text: l.src[start:l.pos], start: start, end: l.pos}
+}
+
+func (l *lexer) peek(offset int) byte {
+	if l.pos+offset < len(l.src) {
+		return l.src[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *lexer) peekRune(offset int) rune {
+	if l.pos+offset < len(l.src) {
+		r, _ := utf8.DecodeRuneInString(l.src[l.pos+offset:])
+		return r
+	}
+	return 0
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,785 @@
+package sqlguard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// reserved 不能作为省略 AS 的别名的关键字
+var reserved = map[string]bool{
+	"ALL": true, "AND": true, "AS": true, "ASC": true, "BETWEEN": true, "BY": true, "CASE": true, "CROSS": true,
+	"DESC": true, "DISTINCT": true, "ELSE": true, "END": true, "EXCEPT": true, "EXISTS": true, "FETCH": true,
+	"FOR": true, "FROM": true, "FULL": true, "GROUP": true, "HAVING": true, "IN": true, "INNER": true,
+	"INTERSECT": true, "INTO": true, "IS": true, "JOIN": true, "LATERAL": true, "LEFT": true, "LIKE": true,
+	"LIMIT": true, "MINUS": true, "NATURAL": true, "NOT": true, "NULL": true, "OFFSET": true, "ON": true,
+	"OR": true, "ORDER": true, "OUTER": true, "RIGHT": true, "SELECT": true, "STRAIGHT_JOIN": true,
+	"THEN": true, "UNION": true, "USING": true, "WHEN": true, "WHERE": true, "WINDOW": true, "WITH": true,
+	"ILIKE": true, "GLOB": true, "REGEXP": true, "RLIKE": true, "SIMILAR": true, "ISNULL": true, "NOTNULL": true,
+	"QUALIFY": true, "RETURNING": true,
+}
+
+// errSelectInto SELECT ... INTO 会创建表或写文件
+var errSelectInto = errors.New("不允许使用 SELECT ... INTO 写入数据")
+
+// Parse 将SQL解析为语法树，只接受单条 SELECT 或 WITH 查询
+func Parse(sql, dialect string) (*Query, error) {
+	stmt, err := parse(sql, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.query, nil
+}
+
+// statement 解析结果，end 为最后一个有效词法单元的结束位置（不含末尾分号和注释）
+type statement struct {
+	query *Query
+	end   int
+}
+
+func parse(sql, dialect string) (*statement, error) {
+	tokens, err := tokenize(sql, dialect)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, dialect: dialect}
+
+	first := p.peek()
+	if first.kind == tokenEOF {
+		return nil, fmt.Errorf("SQL语句为空")
+	}
+	if first.kind == tokenIdent && !first.keyword("SELECT", "WITH") {
+		return nil, fmt.Errorf("只允许执行 SELECT 查询，不支持 %s 语句", strings.ToUpper(first.text))
+	}
+
+	query, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	end := p.prev().end
+
+	for p.peekOp(";") {
+		p.advance()
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		if tok.keyword("SELECT", "WITH", "INSERT", "UPDATE", "DELETE", "DROP", "CREATE", "ALTER", "TRUNCATE", "GRANT", "SET", "CALL", "EXEC", "EXECUTE", "COPY", "ATTACH", "PRAGMA") {
+			return nil, fmt.Errorf("只允许执行单条SQL语句")
+		}
+		return nil, p.errorf(tok, "无法解析 %s", tok.describe())
+	}
+	return &statement{query: query, end: end}, nil
+}
+
+// parser 递归下降解析器
+type parser struct {
+	tokens  []token
+	pos     int
+	dialect string
+	depth   int
+}
+
+// maxDepth 最大嵌套深度，避免恶意构造的深层嵌套耗尽栈空间
+const maxDepth = 200
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset < len(p.tokens) {
+		return p.tokens[p.pos+offset]
+	}
+	return p.tokens[len(p.tokens)-1]
+}
+
+func (p *parser) prev() token {
+	if p.pos == 0 {
+		return p.tokens[0]
+	}
+	return p.tokens[p.pos-1]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) peekOp(op string) bool {
+	tok := p.peek()
+	return tok.kind == tokenOp && tok.text == op
+}
+
+func (p *parser) acceptOp(op string) bool {
+	if p.peekOp(op) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+func (p *parser) acceptKeyword(words ...string) bool {
+	if p.peek().keyword(words...) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+// acceptKeywords 依次匹配多个关键字，全部匹配时才消费
+func (p *parser) acceptKeywords(words ...string) bool {
+	for i, w := range words {
+		if !p.peekAt(i).keyword(w) {
+			return false
+		}
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.acceptOp(op) {
+		return p.errorf(p.peek(), "期望 '%s'，实际为 %s", op, p.peek().describe())
+	}
+	return nil
+}
+
+func (p *parser) expectKeyword(word string) error {
+	if !p.acceptKeyword(word) {
+		return p.errorf(p.peek(), "期望 %s，实际为 %s", word, p.peek().describe())
+	}
+	return nil
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return fmt.Errorf("位置 %d: %s", tok.start+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > maxDepth {
+		return p.errorf(p.peek(), "嵌套层数过多")
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+// identifier 读取标识符，关键字只有在非保留时才可作为标识符
+func (p *parser) identifier() (string, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == tokenQuoted:
+		p.advance()
+		return tok.text, nil
+	case tok.kind == tokenIdent && !reserved[strings.ToUpper(tok.text)]:
+		p.advance()
+		return tok.text, nil
+	}
+	return "", p.errorf(tok, "期望标识符，实际为 %s", tok.describe())
+}
+
+// isIdentifier 判断下一个词法单元能否作为标识符
+func (p *parser) isIdentifier() bool {
+	tok := p.peek()
+	return tok.kind == tokenQuoted || tok.kind == tokenIdent && !reserved[strings.ToUpper(tok.text)]
+}
+
+// parseQuery 解析 [WITH ...] 查询体 [ORDER BY] [LIMIT/OFFSET/FETCH] [FOR UPDATE]
+func (p *parser) parseQuery() (*Query, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	query := &Query{}
+	if p.acceptKeyword("WITH") {
+		with, err := p.parseWith()
+		if err != nil {
+			return nil, err
+		}
+		query.With = with
+	}
+
+	body, err := p.parseSetExpr()
+	if err != nil {
+		return nil, err
+	}
+	query.Body = body
+
+	if p.acceptKeywords("ORDER", "BY") {
+		if query.OrderBy, err = p.parseOrderList(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.parseLimit(query); err != nil {
+		return nil, err
+	}
+	if p.peek().keyword("FOR", "LOCK") {
+		start := p.advance()
+		words := []string{strings.ToUpper(start.text)}
+		for p.peek().kind == tokenIdent && !p.peek().keyword("SELECT") {
+			words = append(words, strings.ToUpper(p.advance().text))
+		}
+		query.Locking = strings.Join(words, " ")
+	}
+	return query, nil
+}
+
+func (p *parser) parseWith() (*With, error) {
+	with := &With{Recursive: p.acceptKeyword("RECURSIVE")}
+	for {
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		cte := &CTE{Name: name}
+		if p.peekOp("(") {
+			if cte.Columns, err = p.parseIdentList(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expectKeyword("AS"); err != nil {
+			return nil, err
+		}
+		p.acceptKeywords("NOT", "MATERIALIZED")
+		p.acceptKeyword("MATERIALIZED")
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		if cte.Query, err = p.parseQuery(); err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		with.CTEs = append(with.CTEs, cte)
+		if !p.acceptOp(",") {
+			return with, nil
+		}
+	}
+}
+
+// parseSetExpr 解析 UNION / EXCEPT（左结合）以及优先级更高的 INTERSECT
+func (p *parser) parseSetExpr() (Node, error) {
+	left, err := p.parseIntersect()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("UNION", "EXCEPT", "MINUS") {
+		op := strings.ToUpper(p.advance().text)
+		all := p.acceptKeyword("ALL")
+		if !all {
+			p.acceptKeyword("DISTINCT")
+		}
+		right, err := p.parseIntersect()
+		if err != nil {
+			return nil, err
+		}
+		left = &SetOp{Op: op, All: all, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseIntersect() (Node, error) {
+	left, err := p.parseSetPrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("INTERSECT") {
+		p.advance()
+		all := p.acceptKeyword("ALL")
+		if !all {
+			p.acceptKeyword("DISTINCT")
+		}
+		right, err := p.parseSetPrimary()
+		if err != nil {
+			return nil, err
+		}
+		left = &SetOp{Op: "INTERSECT", All: all, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseSetPrimary 解析 SELECT 或括号中的查询
+func (p *parser) parseSetPrimary() (Node, error) {
+	if p.acceptOp("(") {
+		query, err := p.parseQuery()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return query, nil
+	}
+	if p.peek().keyword("SELECT") {
+		return p.parseSelect()
+	}
+	tok := p.peek()
+	if tok.kind == tokenIdent {
+		return nil, p.errorf(tok, "只允许 SELECT 查询，不支持 %s", strings.ToUpper(tok.text))
+	}
+	return nil, p.errorf(tok, "期望 SELECT，实际为 %s", tok.describe())
+}
+
+func (p *parser) parseSelect() (*Select, error) {
+	p.advance() // SELECT
+	sel := &Select{}
+
+	// MySQL 的查询修饰符
+	for p.acceptKeyword("HIGH_PRIORITY", "SQL_NO_CACHE", "SQL_CALC_FOUND_ROWS", "SQL_SMALL_RESULT", "SQL_BIG_RESULT", "SQL_BUFFER_RESULT", "STRAIGHT_JOIN") {
+	}
+	if p.acceptKeyword("DISTINCT", "DISTINCTROW") {
+		sel.Distinct = true
+		if p.acceptKeyword("ON") {
+			// PostgreSQL DISTINCT ON (...)，表达式并入分组字段参与校验
+			if err := p.expectOp("("); err != nil {
+				return nil, err
+			}
+			exprs, err := p.parseExprList()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			sel.GroupBy = append(sel.GroupBy, exprs...)
+		}
+	} else {
+		p.acceptKeyword("ALL")
+	}
+
+	for {
+		item, err := p.parseSelectItem()
+		if err != nil {
+			return nil, err
+		}
+		sel.Columns = append(sel.Columns, item)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+
+	if p.peek().keyword("INTO") {
+		return nil, errSelectInto
+	}
+
+	var err error
+	if p.acceptKeyword("FROM") {
+		for {
+			ref, err := p.parseTableRef()
+			if err != nil {
+				return nil, err
+			}
+			sel.From = append(sel.From, ref)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	if p.acceptKeyword("WHERE") {
+		if sel.Where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeywords("GROUP", "BY") {
+		exprs, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+		sel.GroupBy = append(sel.GroupBy, exprs...)
+		p.acceptKeywords("WITH", "ROLLUP")
+	}
+	if p.acceptKeyword("HAVING") {
+		if sel.Having, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("WINDOW") {
+		for {
+			name, err := p.identifier()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectKeyword("AS"); err != nil {
+				return nil, err
+			}
+			spec, err := p.parseWindowSpec()
+			if err != nil {
+				return nil, err
+			}
+			sel.Windows = append(sel.Windows, &WindowDef{Name: name, Spec: spec})
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	if p.peek().keyword("INTO") {
+		return nil, errSelectInto
+	}
+	return sel, nil
+}
+
+func (p *parser) parseSelectItem() (*SelectItem, error) {
+	if p.acceptOp("*") {
+		return &SelectItem{Star: true}, nil
+	}
+	// t.* 形式
+	if (p.peek().kind == tokenIdent || p.peek().kind == tokenQuoted) && p.peekAt(1).kind == tokenOp && p.peekAt(1).text == "." &&
+		p.peekAt(2).kind == tokenOp && p.peekAt(2).text == "*" {
+		table := p.advance().text
+		p.pos += 2
+		return &SelectItem{Star: true, Table: table}, nil
+	}
+
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	item := &SelectItem{Expr: expr}
+	if item.Alias, err = p.parseAlias(); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// parseAlias 解析可选的 [AS] 别名
+func (p *parser) parseAlias() (string, error) {
+	if p.acceptKeyword("AS") {
+		if p.peek().kind == tokenString {
+			return p.advance().text, nil
+		}
+		return p.identifier()
+	}
+	if p.isIdentifier() {
+		return p.identifier()
+	}
+	if p.peek().kind == tokenString && p.dialect == DialectMySQL {
+		return p.advance().text, nil
+	}
+	return "", nil
+}
+
+// parseTableRef 解析表引用及其后的连接
+func (p *parser) parseTableRef() (Node, error) {
+	left, err := p.parseTablePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		kind, ok := p.parseJoinKind()
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseTablePrimary()
+		if err != nil {
+			return nil, err
+		}
+		join := &Join{Kind: kind, Left: left, Right: right}
+		switch {
+		case p.acceptKeyword("ON"):
+			if join.On, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+		case p.acceptKeyword("USING"):
+			if join.Using, err = p.parseIdentList(); err != nil {
+				return nil, err
+			}
+		}
+		left = join
+	}
+}
+
+// parseJoinKind 解析连接类型，不是连接时返回 false
+func (p *parser) parseJoinKind() (string, bool) {
+	var words []string
+	start := p.pos
+	if p.acceptKeyword("NATURAL") {
+		words = append(words, "NATURAL")
+	}
+	switch {
+	case p.acceptKeyword("INNER"):
+		words = append(words, "INNER")
+	case p.acceptKeyword("CROSS"):
+		words = append(words, "CROSS")
+	case p.peek().keyword("LEFT", "RIGHT", "FULL"):
+		words = append(words, strings.ToUpper(p.advance().text))
+		if p.acceptKeyword("OUTER") {
+			words = append(words, "OUTER")
+		}
+	}
+	if p.acceptKeyword("STRAIGHT_JOIN") {
+		return "STRAIGHT_JOIN", true
+	}
+	if !p.acceptKeyword("JOIN") {
+		p.pos = start
+		return "", false
+	}
+	return strings.Join(append(words, "JOIN"), " "), true
+}
+
+func (p *parser) parseTablePrimary() (Node, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	lateral := p.acceptKeyword("LATERAL")
+	if p.acceptOp("(") {
+		if p.peek().keyword("SELECT", "WITH") || p.peekOp("(") && p.startsQuery() {
+			query, err := p.parseQuery()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			derived := &DerivedTable{Query: query, Lateral: lateral}
+			if derived.Alias, err = p.parseTableAlias(); err != nil {
+				return nil, err
+			}
+			return derived, nil
+		}
+		// 括号中的连接
+		ref, err := p.parseTableRef()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return ref, nil
+	}
+
+	first, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	parts := []string{first}
+	for p.acceptOp(".") {
+		part, err := p.anyIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) > 2 {
+		return nil, p.errorf(p.prev(), "不支持跨数据库引用表 %s", strings.Join(parts, "."))
+	}
+
+	if p.peekOp("(") {
+		// 表函数
+		call, err := p.parseFuncArgs(parts)
+		if err != nil {
+			return nil, err
+		}
+		fn := &TableFunc{Func: call}
+		if fn.Alias, err = p.parseTableAlias(); err != nil {
+			return nil, err
+		}
+		return fn, nil
+	}
+
+	table := &TableName{Name: parts[len(parts)-1]}
+	if len(parts) == 2 {
+		table.Schema = parts[0]
+	}
+	if table.Alias, err = p.parseTableAlias(); err != nil {
+		return nil, err
+	}
+	// MySQL 索引提示和 SQLite INDEXED BY
+	for p.peek().keyword("USE", "FORCE", "IGNORE") && p.peekAt(1).keyword("INDEX", "KEY") {
+		p.pos += 2
+		if p.acceptKeyword("FOR") {
+			p.acceptKeyword("JOIN")
+			p.acceptKeywords("ORDER", "BY")
+			p.acceptKeywords("GROUP", "BY")
+		}
+		if _, err := p.parseIdentList(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeywords("INDEXED", "BY") {
+		if _, err := p.identifier(); err != nil {
+			return nil, err
+		}
+	} else {
+		p.acceptKeywords("NOT", "INDEXED")
+	}
+	return table, nil
+}
+
+// startsQuery 判断括号后是否为查询（跳过多层括号）
+func (p *parser) startsQuery() bool {
+	for i := p.pos; i < len(p.tokens); i++ {
+		tok := p.tokens[i]
+		if tok.kind == tokenOp && tok.text == "(" {
+			continue
+		}
+		return tok.keyword("SELECT", "WITH")
+	}
+	return false
+}
+
+// parseTableAlias 解析表别名和可选的列别名
+func (p *parser) parseTableAlias() (string, error) {
+	var alias string
+	var err error
+	if p.acceptKeyword("AS") {
+		alias, err = p.identifier()
+	} else if p.isIdentifier() && !p.peek().keyword("USE", "FORCE", "IGNORE", "INDEXED", "NOT") {
+		alias, err = p.identifier()
+	}
+	if err != nil {
+		return "", err
+	}
+	if alias != "" && p.peekOp("(") {
+		if _, err := p.parseIdentList(); err != nil {
+			return "", err
+		}
+	}
+	return alias, nil
+}
+
+// anyIdentifier 读取点号后的标识符，此处允许关键字
+func (p *parser) anyIdentifier() (string, error) {
+	tok := p.peek()
+	if tok.kind == tokenIdent || tok.kind == tokenQuoted {
+		p.advance()
+		return tok.text, nil
+	}
+	return "", p.errorf(tok, "期望标识符，实际为 %s", tok.describe())
+}
+
+// parseIdentList 解析 (a, b, c)
+func (p *parser) parseIdentList() ([]string, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	var names []string
+	for {
+		name, err := p.anyIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	return names, p.expectOp(")")
+}
+
+func (p *parser) parseOrderList() ([]*OrderItem, error) {
+	var items []*OrderItem
+	for {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		item := &OrderItem{Expr: expr}
+		if p.acceptKeyword("DESC") {
+			item.Desc = true
+		} else {
+			p.acceptKeyword("ASC")
+		}
+		if p.acceptKeyword("NULLS") {
+			if !p.acceptKeyword("FIRST", "LAST") {
+				return nil, p.errorf(p.peek(), "期望 FIRST 或 LAST")
+			}
+		}
+		items = append(items, item)
+		if !p.acceptOp(",") {
+			return items, nil
+		}
+	}
+}
+
+// parseLimit 解析 LIMIT n [OFFSET m]、LIMIT m, n、OFFSET m [ROWS] 和 FETCH FIRST n ROWS ONLY
+func (p *parser) parseLimit(query *Query) error {
+	for {
+		switch {
+		case p.peek().keyword("LIMIT"):
+			if query.Limit != nil && query.Limit.countEnd > 0 {
+				return p.errorf(p.peek(), "重复的 LIMIT")
+			}
+			p.advance()
+			limit := p.limitNode(query)
+			if p.peek().keyword("ALL") {
+				tok := p.advance()
+				limit.countStart, limit.countEnd = tok.start, tok.end
+				continue
+			}
+			start := p.peek().start
+			count, err := p.parseExpr()
+			if err != nil {
+				return err
+			}
+			end := p.prev().end
+			if p.acceptOp(",") {
+				// LIMIT offset, count
+				limit.Offset = count
+				limit.offsetStart, limit.offsetEnd = start, end
+				start = p.peek().start
+				if count, err = p.parseExpr(); err != nil {
+					return err
+				}
+			}
+			limit.Count = count
+			limit.countStart, limit.countEnd = start, p.prev().end
+
+		case p.peek().keyword("OFFSET"):
+			p.advance()
+			limit := p.limitNode(query)
+			start := p.peek().start
+			offset, err := p.parseExpr()
+			if err != nil {
+				return err
+			}
+			limit.Offset = offset
+			limit.offsetStart, limit.offsetEnd = start, p.prev().end
+			p.acceptKeyword("ROW", "ROWS")
+
+		case p.peek().keyword("FETCH"):
+			p.advance()
+			if !p.acceptKeyword("FIRST", "NEXT") {
+				return p.errorf(p.peek(), "期望 FIRST 或 NEXT")
+			}
+			limit := p.limitNode(query)
+			if p.peek().keyword("ROW", "ROWS") {
+				tok := p.peek()
+				limit.Count = &Literal{Kind: "number", Value: "1"}
+				limit.countStart, limit.countEnd = tok.start, tok.start
+			} else {
+				start := p.peek().start
+				count, err := p.parseExpr()
+				if err != nil {
+					return err
+				}
+				limit.Count = count
+				limit.countStart, limit.countEnd = start, p.prev().end
+			}
+			if !p.acceptKeyword("ROW", "ROWS") {
+				return p.errorf(p.peek(), "期望 ROWS")
+			}
+			if !p.acceptKeyword("ONLY") {
+				return p.errorf(p.peek(), "只支持 FETCH ... ROWS ONLY")
+			}
+
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *parser) limitNode(query *Query) *Limit {
+	if query.Limit == nil {
+		query.Limit = &Limit{}
+	}
+	return query.Limit
+}
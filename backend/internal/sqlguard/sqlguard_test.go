@@ -0,0 +1,189 @@
+package sqlguard
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestCheckAcceptsReadOnlyQueries(t *testing.T) {
+	cases := []struct {
+		dialect string
+		sql     string
+	}{
+		{DialectSQLite, "SELECT * FROM orders"},
+		{DialectSQLite, "select o.id, u.name from orders o join users u using (user_id) where o.amount > 10 order by 1 desc"},
+		{DialectSQLite, `WITH t AS (SELECT user_id, SUM(amount) AS total FROM orders GROUP BY user_id)
+			SELECT users.name, t.total FROM t LEFT OUTER JOIN users ON users.user_id = t.user_id WHERE t.total BETWEEN 1 AND 100`},
+		{DialectSQLite, "SELECT region, COUNT(DISTINCT user_id) FILTER (WHERE amount > 0) FROM orders GROUP BY region HAVING COUNT(*) > 1"},
+		{DialectSQLite, "SELECT id, ROW_NUMBER() OVER (PARTITION BY region ORDER BY amount DESC ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) rn FROM orders"},
+		{DialectSQLite, "SELECT CASE WHEN amount IS NULL THEN 'n/a' ELSE CAST(amount AS TEXT) END, date('now', '-7 day') FROM orders WHERE name NOT LIKE '%;DROP%'"},
+		{DialectSQLite, "SELECT 1 UNION ALL SELECT 2 EXCEPT SELECT 3"},
+		{DialectSQLite, "SELECT * FROM orders WHERE user_id IN (SELECT user_id FROM users WHERE EXISTS (SELECT 1 FROM orders))"},
+		{DialectSQLite, "SELECT * FROM [orders] -- 注释;"},
+		{DialectMySQL, "SELECT `region`, GROUP_CONCAT(DISTINCT name ORDER BY name SEPARATOR ',') FROM orders WHERE created_at > NOW() - INTERVAL 7 DAY GROUP BY region LIMIT 5, 10"},
+		{DialectMySQL, `SELECT "a\'b" AS s, 'it\'s' FROM orders # 注释`},
+		{DialectPostgres, "SELECT amount::numeric(10,2), created_at::timestamp with time zone, EXTRACT(YEAR FROM created_at) FROM orders WHERE name ILIKE 'a%' FETCH FIRST 5 ROWS ONLY"},
+		{DialectPostgres, "SELECT percentile_cont(0.5) WITHIN GROUP (ORDER BY amount), DATE '2024-01-01' + INTERVAL '1 day' FROM orders"},
+		{DialectPostgres, "SELECT $$a;b$$, E'x\\'y' FROM orders"},
+	}
+	for _, c := range cases {
+		if _, err := Check(c.sql, Options{Dialect: c.dialect}); err != nil {
+			t.Errorf("[%s] %s\n应通过校验: %v", c.dialect, c.sql, err)
+		}
+	}
+}
+
+func TestCheckRejectsUnsafeQueries(t *testing.T) {
+	cases := []struct {
+		dialect string
+		sql     string
+		want    string
+	}{
+		{DialectSQLite, "DELETE FROM orders", "不支持 DELETE"},
+		{DialectSQLite, "DROP TABLE orders", "不支持 DROP"},
+		{DialectSQLite, "SELECT 1; DROP TABLE orders", "单条SQL"},
+		{DialectSQLite, "WITH x AS (SELECT 1) DELETE FROM orders", "只允许 SELECT"},
+		{DialectSQLite, "", "为空"},
+		{DialectSQLite, "SELECT * FROM sqlite_master", "系统表"},
+		{DialectSQLite, "SELECT load_extension('/tmp/x.so')", "load_extension"},
+		{DialectSQLite, "SELECT * FROM secrets", "不允许访问表 secrets"},
+		{DialectSQLite, "SELECT * FROM orders WHERE user_id IN (SELECT id FROM secrets)", "secrets"},
+		{DialectSQLite, "WITH secrets AS (SELECT * FROM secrets) SELECT * FROM secrets", "secrets"},
+		{DialectSQLite, "SELECT * FROM orders WHERE id = ?", "占位符"},
+		{DialectSQLite, "SELECT * FROM main.orders", "前缀"},
+		{DialectMySQL, "SELECT * INTO OUTFILE '/tmp/x' FROM orders", "INTO"},
+		{DialectMySQL, "SELECT * FROM orders FOR UPDATE", "FOR UPDATE"},
+		{DialectMySQL, "SELECT SLEEP(10)", "SLEEP"},
+		{DialectMySQL, "SELECT 1 /*!50000 ; DROP TABLE orders */", "可执行注释"},
+		{DialectMySQL, `SELECT 'a\'; DROP TABLE orders; -- ' FROM orders; DELETE FROM orders`, "单条SQL"},
+		{DialectMySQL, "SELECT * FROM mysql.user", "系统表"},
+		{DialectPostgres, "SELECT pg_sleep(10)", "pg_sleep"},
+		{DialectPostgres, "SELECT * FROM pg_catalog.pg_user", "系统表"},
+		{DialectPostgres, "SELECT query_to_xml('delete from orders', true, true, '')", "query_to_xml"},
+		{DialectPostgres, "SELECT * FROM orders LIMIT (SELECT 10)", "LIMIT"},
+		{DialectSQLite, "SELECT * FROM pragma_table_info('secrets')", "pragma_table_info"},
+		{DialectSQLite, "SELECT o.id FROM orders o JOIN pragma_table_list() t", "pragma_table_list"},
+		{DialectPostgres, "SELECT count(*) FROM generate_series(1, 1000000000)", "generate_series"},
+		{DialectPostgres, "SELECT * FROM orders OFFSET (SELECT count(*) FROM users)", "OFFSET"},
+		{DialectMySQL, "SELECT * FROM orders LIMIT -1, 10", "OFFSET"},
+	}
+	for _, c := range cases {
+		opts := Options{Dialect: c.dialect, AllowedTables: []string{"orders", "users"}, MaxRows: 100, MaxOffset: 1000}
+		_, err := Check(c.sql, opts)
+		if err == nil {
+			t.Errorf("[%s] %s\n应被拒绝", c.dialect, c.sql)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.want) {
+			t.Errorf("[%s] %s\n错误信息应包含 %q，实际: %v", c.dialect, c.sql, c.want, err)
+		}
+	}
+}
+
+func TestCheckRowLimit(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM orders;":                          "SELECT * FROM orders LIMIT 100",
+		"SELECT * FROM orders -- 注释":                     "SELECT * FROM orders LIMIT 100",
+		"SELECT * FROM orders LIMIT 10":                  "SELECT * FROM orders LIMIT 10",
+		"SELECT * FROM orders LIMIT 5000 OFFSET 10":      "SELECT * FROM orders LIMIT 100 OFFSET 10",
+		"SELECT * FROM orders ORDER BY id LIMIT ALL":     "SELECT * FROM orders ORDER BY id LIMIT 100",
+		"SELECT * FROM (SELECT * FROM orders LIMIT 5) t": "SELECT * FROM (SELECT * FROM orders LIMIT 5) t LIMIT 100",
+		"SELECT 1 UNION SELECT 2":                        "SELECT 1 UNION SELECT 2 LIMIT 100",
+	}
+	for input, want := range cases {
+		checked, err := Check(input, Options{Dialect: DialectSQLite, MaxRows: 100})
+		if err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if checked.SQL != want {
+			t.Errorf("%s\n期望: %s\n实际: %s", input, want, checked.SQL)
+		}
+	}
+
+	checked, err := Check("SELECT * FROM orders LIMIT 20, 5000", Options{Dialect: DialectMySQL, MaxRows: 100})
+	if err != nil || checked.SQL != "SELECT * FROM orders LIMIT 20, 100" {
+		t.Errorf("MySQL LIMIT offset, count 未正确收紧: %v %+v", err, checked)
+	}
+
+	// OFFSET 与 LIMIT 一样收紧到上限
+	offsets := map[string]string{
+		"SELECT * FROM orders LIMIT 10 OFFSET 500":        "SELECT * FROM orders LIMIT 10 OFFSET 500",
+		"SELECT * FROM orders LIMIT 5000 OFFSET 99999999": "SELECT * FROM orders LIMIT 100 OFFSET 1000",
+		"SELECT * FROM orders LIMIT 99999999, 5000":       "SELECT * FROM orders LIMIT 1000, 100",
+		"SELECT * FROM orders LIMIT 99999999 , 5":         "SELECT * FROM orders LIMIT 1000 , 5",
+	}
+	for input, want := range offsets {
+		checked, err := Check(input, Options{Dialect: DialectMySQL, MaxRows: 100, MaxOffset: 1000})
+		if err != nil || checked.SQL != want {
+			t.Errorf("%s\n期望: %s\n实际: %v %+v", input, want, err, checked)
+		}
+	}
+}
+
+func TestCheckTableFunctions(t *testing.T) {
+	// 只展开参数的表函数不受 AllowedTables 限制，参数中的子查询仍然校验
+	opts := Options{Dialect: DialectSQLite, AllowedTables: []string{"orders"}}
+	if _, err := Check("SELECT o.id, j.value FROM orders o, json_each(o.tags) j", opts); err != nil {
+		t.Errorf("json_each 应允许使用: %v", err)
+	}
+	if _, err := Check("SELECT value FROM json_each((SELECT tags FROM users))", opts); err == nil {
+		t.Error("表函数参数中的子查询应校验可访问的表")
+	}
+	if _, err := Check("SELECT * FROM main.json_each('[1]')", opts); err == nil {
+		t.Error("带 schema 的表函数应被拒绝")
+	}
+}
+
+func TestCheckTables(t *testing.T) {
+	checked, err := Check(`WITH recent AS (SELECT * FROM Orders) SELECT * FROM recent JOIN users USING (user_id)`,
+		Options{Dialect: DialectSQLite, AllowedTables: []string{"orders", "users"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(checked.Tables, ",") != "orders,users" {
+		t.Errorf("引用的表不正确: %v", checked.Tables)
+	}
+}
+
+func TestParseDepthLimit(t *testing.T) {
+	sql := "SELECT " + strings.Repeat("(", 1000) + "1" + strings.Repeat(")", 1000)
+	if _, err := Parse(sql, DialectSQLite); err == nil || !strings.Contains(err.Error(), "嵌套") {
+		t.Errorf("应限制嵌套层数: %v", err)
+	}
+}
+
+func TestCheckCostSQLite(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("CREATE TABLE a (id INTEGER PRIMARY KEY, v INTEGER); CREATE TABLE b (id INTEGER PRIMARY KEY, a_id INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		if _, err := db.Exec(fmt.Sprintf("INSERT INTO a VALUES (%d, %d); INSERT INTO b VALUES (%d, %d)", i, i%7, i, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plan, err := CheckCost(ctx, db, DialectSQLite, "SELECT * FROM a WHERE id = 3", 1000)
+	if err != nil {
+		t.Fatalf("主键查询不应超过代价上限: %v %+v", err, plan)
+	}
+
+	// 笛卡尔积 200 * 200 超过上限
+	plan, err = CheckCost(ctx, db, DialectSQLite, "SELECT * FROM a, b WHERE a.v < b.a_id", 1000)
+	if err == nil || plan.Cost < 40000 {
+		t.Errorf("笛卡尔积应超过代价上限: %v %+v", err, plan)
+	}
+}
@@ -0,0 +1,301 @@
+// Package sqlguard 在执行前把SQL解析为语法树并做安全校验：只允许单条只读 SELECT，
+// 拦截写操作、加锁、危险函数和系统表，限制可访问的表并强制行数上限，执行前用 EXPLAIN 估算代价
+package sqlguard
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 支持的SQL方言
+const (
+	DialectSQLite   = "sqlite"
+	DialectMySQL    = "mysql"
+	DialectPostgres = "postgres"
+)
+
+// Options 校验选项
+type Options struct {
+	Dialect string
+	// AllowedTables 允许访问的表（不区分大小写），为空时不限制，但系统表始终禁止访问
+	AllowedTables []string
+	// MaxRows 结果行数上限，>0 时没有 LIMIT 的查询会追加 LIMIT，超过上限的 LIMIT 会被改小
+	MaxRows int
+	// MaxOffset OFFSET 上限，>0 时 OFFSET 只能是整数常量，超过上限的 OFFSET 会被改小，避免扫描大量被跳过的行
+	MaxOffset int
+}
+
+// Checked 通过校验的查询
+type Checked struct {
+	Query  *Query
+	SQL    string   // 可以直接执行的SQL，已去掉末尾分号并应用行数上限
+	Tables []string // 查询引用的表（不含CTE），已去重排序
+}
+
+// deniedFunctions 有副作用、可读写服务器文件、可执行任意SQL或可用于拖慢数据库的函数
+var deniedFunctions = map[string]bool{
+	// MySQL
+	"sleep": true, "benchmark": true, "load_file": true, "get_lock": true, "release_lock": true,
+	"release_all_locks": true, "master_pos_wait": true, "source_pos_wait": true, "sys_exec": true, "sys_eval": true,
+	// PostgreSQL
+	"pg_sleep": true, "pg_sleep_for": true, "pg_sleep_until": true, "pg_read_file": true, "pg_read_binary_file": true,
+	"pg_ls_dir": true, "pg_stat_file": true, "pg_ls_logdir": true, "pg_ls_waldir": true, "lo_import": true,
+	"lo_export": true, "lo_get": true, "lo_put": true, "lo_from_bytea": true, "lo_create": true, "lo_unlink": true,
+	"dblink": true, "dblink_exec": true, "dblink_connect": true, "dblink_send_query": true,
+	"pg_terminate_backend": true, "pg_cancel_backend": true, "pg_reload_conf": true, "pg_rotate_logfile": true,
+	"set_config": true, "nextval": true, "setval": true, "pg_advisory_lock": true, "pg_advisory_xact_lock": true,
+	"pg_try_advisory_lock": true, "pg_notify": true, "query_to_xml": true, "query_to_xmlschema": true,
+	"query_to_xml_and_xmlschema": true, "cursor_to_xml": true, "table_to_xml": true, "schema_to_xml": true,
+	"database_to_xml": true, "pg_logical_emit_message": true, "pg_switch_wal": true, "pg_create_restore_point": true,
+	// SQLite
+	"load_extension": true, "readfile": true, "writefile": true, "edit": true, "fts3_tokenizer": true,
+}
+
+// allowedTableFunctions 允许在 FROM 中使用的表函数，只展开参数中给出的值。
+// 其他表函数可能读取表结构（如 SQLite 的 pragma_table_info）或生成任意多行（如 generate_series），
+// 也无法按 AllowedTables 限制，一律拒绝
+var allowedTableFunctions = map[string]bool{
+	// SQLite
+	"json_each": true, "json_tree": true,
+	// PostgreSQL
+	"unnest": true, "json_array_elements": true, "json_array_elements_text": true, "jsonb_array_elements": true,
+	"jsonb_array_elements_text": true, "json_each_text": true, "jsonb_each": true, "jsonb_each_text": true,
+}
+
+// systemSchemas 系统库，禁止访问
+var systemSchemas = map[string]bool{
+	"information_schema": true, "pg_catalog": true, "pg_toast": true, "mysql": true,
+	"performance_schema": true, "sys": true,
+}
+
+// isSystemTable 判断是否为系统表
+func isSystemTable(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasPrefix(name, "sqlite_") || strings.HasPrefix(name, "pg_")
+}
+
+// Check 解析并校验SQL，通过后返回可执行的SQL
+func Check(sql string, opts Options) (*Checked, error) {
+	stmt, err := parse(sql, opts.Dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &validator{allowed: make(map[string]bool), tables: make(map[string]bool)}
+	for _, table := range opts.AllowedTables {
+		v.allowed[strings.ToLower(table)] = true
+	}
+	v.restrict = len(opts.AllowedTables) > 0
+	if err := v.query(stmt.query, nil); err != nil {
+		return nil, err
+	}
+
+	checked := &Checked{Query: stmt.query, SQL: strings.TrimSpace(sql[:stmt.end])}
+	for table := range v.tables {
+		checked.Tables = append(checked.Tables, table)
+	}
+	sort.Strings(checked.Tables)
+
+	if opts.MaxRows > 0 || opts.MaxOffset > 0 {
+		if checked.SQL, err = applyRowLimit(sql, stmt, opts.MaxRows, opts.MaxOffset); err != nil {
+			return nil, err
+		}
+	}
+	return checked, nil
+}
+
+// validator 按作用域校验语法树，scope 为可见的CTE名称
+type validator struct {
+	allowed  map[string]bool
+	restrict bool
+	tables   map[string]bool
+}
+
+func (v *validator) query(q *Query, scope map[string]bool) error {
+	if q.Locking != "" {
+		return fmt.Errorf("不允许使用 %s 加锁", q.Locking)
+	}
+
+	if q.With != nil {
+		// 非递归的CTE只能引用在它之前定义的CTE，同名引用指向真实的表
+		inner := copyScope(scope)
+		if q.With.Recursive {
+			for _, cte := range q.With.CTEs {
+				inner[strings.ToLower(cte.Name)] = true
+			}
+		}
+		for _, cte := range q.With.CTEs {
+			if err := v.query(cte.Query, copyScope(inner)); err != nil {
+				return err
+			}
+			inner[strings.ToLower(cte.Name)] = true
+		}
+		scope = inner
+	}
+
+	if err := v.node(q.Body, scope); err != nil {
+		return err
+	}
+	for _, item := range q.OrderBy {
+		if err := v.node(item, scope); err != nil {
+			return err
+		}
+	}
+	if q.Limit != nil {
+		if err := v.node(q.Limit, scope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// node 遍历节点，遇到子查询时以当前作用域递归校验
+func (v *validator) node(root Node, scope map[string]bool) error {
+	var err error
+	Walk(root, func(n Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *Query:
+			err = v.query(n, scope)
+			return false
+		case *TableName:
+			err = v.table(n, scope)
+		case *TableFunc:
+			err = v.tableFunction(n)
+		case *FuncCall:
+			err = v.function(n)
+		case *Param:
+			err = fmt.Errorf("不支持参数占位符 %s", n.Name)
+		case *ColumnRef:
+			if len(n.Parts) > 1 && systemSchemas[strings.ToLower(n.Parts[0])] {
+				err = fmt.Errorf("不允许访问系统库 %s", n.Parts[0])
+			}
+		}
+		return err == nil
+	})
+	return err
+}
+
+func (v *validator) table(t *TableName, scope map[string]bool) error {
+	name := strings.ToLower(t.Name)
+	if t.Schema == "" && scope[name] {
+		return nil
+	}
+	if t.Schema != "" && systemSchemas[strings.ToLower(t.Schema)] || isSystemTable(name) {
+		return fmt.Errorf("不允许访问系统表 %s", t.QualifiedName())
+	}
+
+	qualified := strings.ToLower(t.QualifiedName())
+	if v.restrict && !v.allowed[qualified] {
+		if t.Schema != "" && v.allowed[name] {
+			return fmt.Errorf("表 %s 请不要带库名或 schema 前缀，直接使用 %s", t.QualifiedName(), t.Name)
+		}
+		return fmt.Errorf("不允许访问表 %s，可访问的表: %s", t.QualifiedName(), strings.Join(v.allowedList(), ", "))
+	}
+	v.tables[qualified] = true
+	return nil
+}
+
+func (v *validator) tableFunction(t *TableFunc) error {
+	if t.Func.Schema != "" || !allowedTableFunctions[strings.ToLower(t.Func.Name)] {
+		return fmt.Errorf("不允许在 FROM 中使用表函数 %s", t.Func.Name)
+	}
+	return nil
+}
+
+func (v *validator) function(f *FuncCall) error {
+	name := strings.ToLower(f.Name)
+	if deniedFunctions[name] {
+		return fmt.Errorf("不允许调用函数 %s", f.Name)
+	}
+	if f.Schema != "" && systemSchemas[strings.ToLower(f.Schema)] && strings.HasPrefix(name, "pg_") {
+		return fmt.Errorf("不允许调用函数 %s.%s", f.Schema, f.Name)
+	}
+	return nil
+}
+
+func copyScope(scope map[string]bool) map[string]bool {
+	copied := make(map[string]bool, len(scope))
+	for name := range scope {
+		copied[name] = true
+	}
+	return copied
+}
+
+func (v *validator) allowedList() []string {
+	names := make([]string, 0, len(v.allowed))
+	for name := range v.allowed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyRowLimit 为最外层查询追加或收紧 LIMIT，maxOffset>0 时收紧超过上限的 OFFSET。LIMIT 和 OFFSET 只接受整数常量
+func applyRowLimit(sql string, stmt *statement, maxRows, maxOffset int) (string, error) {
+	body := strings.TrimSpace(sql[:stmt.end])
+	limit := stmt.query.Limit
+	if limit == nil {
+		if maxRows > 0 {
+			body = fmt.Sprintf("%s LIMIT %d", body, maxRows)
+		}
+		return body, nil
+	}
+
+	// 按位置从后往前替换，先替换的部分不影响之前的位置
+	type replacement struct {
+		start, end int
+		value      int
+	}
+	var replacements []replacement
+	if maxRows > 0 && limit.countEnd > 0 {
+		n := maxRows + 1
+		if limit.Count != nil {
+			var err error
+			if n, err = intConstant("LIMIT", limit.Count); err != nil {
+				return "", err
+			}
+		}
+		// LIMIT ALL 或超过上限的 LIMIT 替换为上限
+		if n > maxRows {
+			replacements = append(replacements, replacement{limit.countStart, limit.countEnd, maxRows})
+		}
+	}
+	if maxOffset > 0 && limit.Offset != nil {
+		n, err := intConstant("OFFSET", limit.Offset)
+		if err != nil {
+			return "", err
+		}
+		if n > maxOffset {
+			replacements = append(replacements, replacement{limit.offsetStart, limit.offsetEnd, maxOffset})
+		}
+	}
+	sort.Slice(replacements, func(i, j int) bool { return replacements[i].start > replacements[j].start })
+
+	result := sql[:stmt.end]
+	for _, r := range replacements {
+		result = result[:r.start] + strconv.Itoa(r.value) + result[r.end:]
+	}
+	result = strings.TrimSpace(result)
+	if maxRows > 0 && limit.countEnd == 0 {
+		result = fmt.Sprintf("%s LIMIT %d", result, maxRows)
+	}
+	return result, nil
+}
+
+// intConstant 返回 LIMIT 或 OFFSET 的非负整数常量
+func intConstant(clause string, node Node) (int, error) {
+	lit, ok := node.(*Literal)
+	if !ok || lit.Kind != "number" {
+		return 0, fmt.Errorf("%s 只能是整数常量", clause)
+	}
+	n, err := strconv.Atoi(lit.Value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s 只能是非负整数: %s", clause, lit.Value)
+	}
+	return n, nil
+}
@@ -4,13 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"strings"
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/analytics"
+	"smart-analysis/internal/query"
 	"smart-analysis/internal/utils/sanbox"
 )
 
-// DataQueryTool 数据查询工具
+// dataQueryPreviewRows 查询结果中返回的最大行数
+const dataQueryPreviewRows = 20
+
+// nativeQueryFormats 内置查询引擎可以直接读取的文件格式，其余格式翻译为pandas代码在沙盒中执行
+var nativeQueryFormats = map[string]bool{".csv": true, ".json": true, ".xlsx": true}
+
+// DataQueryTool 数据查询工具，查询语句先在Go中解析校验，不会把查询文本当作代码执行
 type DataQueryTool struct {
 	sandbox *sanbox.PythonSandbox
 	name    string
@@ -22,7 +32,7 @@ func NewDataQueryTool(sandbox *sanbox.PythonSandbox) *DataQueryTool {
 	return &DataQueryTool{
 		sandbox: sandbox,
 		name:    "data_query",
-		desc:    "使用SQL样式的查询语法对数据进行筛选、聚合和分析。",
+		desc:    "使用管道查询语法对数据文件进行筛选、选列、分组聚合、排序、取前N行和透视。\n" + query.PipelineSyntax,
 	}
 }
 
@@ -35,13 +45,13 @@ func (t *DataQueryTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
 			map[string]*schema.ParameterInfo{
 				"query": {
 					Type:     schema.String,
-					Desc:     "查询语句，支持pandas查询语法",
+					Desc:     "管道查询语句，例如: filter sales > 100 | groupby region | agg sum(sales) as total | sort total desc",
 					Required: true,
 				},
 				"file_path": {
 					Type:     schema.String,
 					Desc:     "数据文件路径",
-					Required: false,
+					Required: true,
 				},
 			}),
 	}, nil
@@ -58,60 +68,80 @@ func (t *DataQueryTool) InvokableRun(ctx context.Context, argumentsInJSON string
 		return "", err
 	}
 
+	pipeline, err := query.ParsePipeline(args.Query)
+	if err != nil {
+		// 返回语法说明，便于智能体修正查询
+		return fmt.Sprintf("查询语法错误: %v\n\n%s", err, query.PipelineSyntax), nil
+	}
+	if args.FilePath == "" {
+		return "查询执行失败: 未指定数据文件", nil
+	}
+
+	if nativeQueryFormats[strings.ToLower(filepath.Ext(args.FilePath))] {
+		return t.runNative(pipeline, args.FilePath), nil
+	}
+	return t.runPandas(ctx, pipeline, args.FilePath)
+}
+
+// runNative 使用内置查询引擎执行
+func (t *DataQueryTool) runNative(pipeline *query.Pipeline, filePath string) string {
+	table, err := analytics.LoadTable(filePath)
+	if err != nil {
+		return "查询执行失败: " + err.Error()
+	}
+	result, err := pipeline.Execute(table)
+	if err != nil {
+		return "查询执行失败: " + err.Error()
+	}
+
+	records := make([]map[string]interface{}, 0, min(len(result.Rows), dataQueryPreviewRows))
+	for _, row := range result.Rows[:min(len(result.Rows), dataQueryPreviewRows)] {
+		record := make(map[string]interface{}, len(result.Columns))
+		for i, column := range result.Columns {
+			if i < len(row) {
+				record[column] = row[i]
+			}
+		}
+		records = append(records, record)
+	}
+
+	data, _ := json.MarshalIndent(map[string]interface{}{
+		"type":    "dataframe",
+		"shape":   []int{len(result.Rows), len(result.Columns)},
+		"data":    records,
+		"columns": result.Columns,
+	}, "", "  ")
+	return "查询执行成功:\n" + string(data)
+}
+
+// runPandas 将查询翻译为pandas代码在沙盒中执行
+func (t *DataQueryTool) runPandas(ctx context.Context, pipeline *query.Pipeline, filePath string) (string, error) {
+	if t.sandbox == nil {
+		return "查询执行失败: Python沙盒未配置，无法读取该格式的文件", nil
+	}
+
 	code := fmt.Sprintf(`
 import pandas as pd
-import numpy as np
 import json
 
 %s
 
-# 执行查询
 try:
-    # 安全的查询执行
-    query = '''%s'''
-    
-    # 支持的查询类型
-    if 'groupby(' in query.lower():
-        # 分组查询
-        result_df = eval(f"df.{query}")
-    elif 'query(' in query.lower():
-        # 条件查询
-        result_df = eval(f"df.{query}")
-    elif any(agg in query.lower() for agg in ['sum()', 'mean()', 'count()', 'max()', 'min()']):
-        # 聚合查询
-        result_df = eval(f"df.{query}")
-    else:
-        # 其他pandas操作
-        result_df = eval(f"df.{query}")
-    
-    # 转换结果
-    if isinstance(result_df, pd.DataFrame):
-        result = {
-            "type": "dataframe",
-            "shape": result_df.shape,
-            "data": result_df.head(20).to_dict("records"),
-            "columns": result_df.columns.tolist()
-        }
-    elif isinstance(result_df, pd.Series):
-        result = {
-            "type": "series",
-            "data": result_df.head(20).to_dict(),
-            "name": result_df.name
-        }
-    else:
-        result = {
-            "type": "value",
-            "data": str(result_df)
-        }
-    
+%s
+
+    result = {
+        "type": "dataframe",
+        "shape": df.shape,
+        "data": df.head(%d).to_dict("records"),
+        "columns": [str(c) for c in df.columns]
+    }
     print("查询执行成功:")
-    print(json.dumps(result, ensure_ascii=False, indent=2))
-    
+    print(json.dumps(result, ensure_ascii=False, indent=2, default=str))
 except Exception as e:
     print(f"查询执行失败: {str(e)}")
-`, t.getDataLoadCode(args.FilePath), args.Query)
+`, t.getDataLoadCode(filePath), indent(pipeline.Pandas(), "    "), dataQueryPreviewRows)
 
-	result, err := t.sandbox.ExecutePython(code)
+	result, err := t.sandbox.ExecuteCodeContext(ctx, code)
 	if err != nil {
 		return "", err
 	}
@@ -123,10 +153,26 @@ except Exception as e:
 	return result.Stdout, nil
 }
 
-// getDataLoadCode 获取数据加载代码
+// getDataLoadCode 获取数据加载代码，路径以JSON字符串写入
 func (t *DataQueryTool) getDataLoadCode(filePath string) string {
-	if filePath != "" {
-		return fmt.Sprintf("df = pd.read_csv('%s')", filePath)
+	path, _ := json.Marshal(filePath)
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".xlsx", ".xls":
+		return fmt.Sprintf("df = pd.read_excel(%s)", path)
+	case ".json":
+		return fmt.Sprintf("df = pd.read_json(%s)", path)
+	case ".parquet":
+		return fmt.Sprintf("df = pd.read_parquet(%s)", path)
+	default:
+		return fmt.Sprintf("df = pd.read_csv(%s)", path)
+	}
+}
+
+// indent 为多行代码添加缩进
+func indent(code, prefix string) string {
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		lines[i] = prefix + line
 	}
-	return "# 假设数据已经加载到df变量中"
+	return strings.Join(lines, "\n")
 }
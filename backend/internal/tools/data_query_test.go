@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDataQueryTool(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sales.csv")
+	if err := os.WriteFile(path, []byte("region,sales\n华东,100\n华北,300\n华东,50\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	queryTool := NewDataQueryTool(nil)
+
+	run := func(query string) string {
+		t.Helper()
+		out, err := queryTool.InvokableRun(context.Background(), fmt.Sprintf(`{"query": %q, "file_path": %q}`, query, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	out := run("groupby region | agg sum(sales) as total | sort total desc")
+	if !strings.HasPrefix(out, "查询执行成功") || !strings.Contains(out, `"shape": [`) || strings.Index(out, "华北") > strings.Index(out, "华东") {
+		t.Errorf("查询结果不正确:\n%s", out)
+	}
+
+	// 任意Python表达式不会被执行，而是返回语法错误和语法说明
+	out = run("__import__('os').system('touch /tmp/pwned')")
+	if !strings.HasPrefix(out, "查询语法错误") || !strings.Contains(out, "groupby 列1") {
+		t.Errorf("应返回语法错误:\n%s", out)
+	}
+
+	out = run("filter city == 'x'")
+	if !strings.Contains(out, "查询执行失败: 第1步 filter: 过滤字段 city 不存在") {
+		t.Errorf("应返回执行错误:\n%s", out)
+	}
+}
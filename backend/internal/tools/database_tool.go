@@ -4,25 +4,30 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
-	"smart-analysis/internal/utils/sanbox"
+	"smart-analysis/internal/sqlengine"
+	"smart-analysis/internal/types"
 )
 
-// DatabaseTool 数据库连接工具
+// DataSourceProvider 按ID访问当前用户已注册的数据源，连接信息和凭据不会暴露给工具
+type DataSourceProvider = types.DataSourceProvider
+
+// DatabaseTool 数据库查询工具，只能通过数据源ID查询用户注册的数据源
 type DatabaseTool struct {
-	sandbox *sanbox.PythonSandbox
+	sources DataSourceProvider
 	name    string
 	desc    string
 }
 
-// NewDatabaseTool 创建数据库连接工具
-func NewDatabaseTool(sandbox *sanbox.PythonSandbox) *DatabaseTool {
+// NewDatabaseTool 创建数据库查询工具
+func NewDatabaseTool(sources DataSourceProvider) *DatabaseTool {
 	return &DatabaseTool{
-		sandbox: sandbox,
+		sources: sources,
 		name:    "database_tool",
-		desc:    "数据库连接和查询工具，支持MySQL、PostgreSQL、SQLite等常见数据库的连接和数据提取。",
+		desc:    "查询用户已注册的数据库数据源（MySQL、PostgreSQL、SQLite）。通过数据源ID指定数据库，只允许单条 SELECT/WITH 查询；query 为空时返回表、列、注释和主外键。",
 	}
 }
 
@@ -33,24 +38,19 @@ func (t *DatabaseTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
 		Desc: t.desc,
 		ParamsOneOf: schema.NewParamsOneOfByParams(
 			map[string]*schema.ParameterInfo{
-				"db_type": {
-					Type:     schema.String,
-					Desc:     "数据库类型: mysql, postgresql, sqlite, oracle",
-					Required: true,
-				},
-				"connection_string": {
-					Type:     schema.String,
-					Desc:     "数据库连接字符串",
+				"data_source_id": {
+					Type:     schema.Integer,
+					Desc:     "数据源ID",
 					Required: true,
 				},
 				"query": {
 					Type:     schema.String,
-					Desc:     "SQL查询语句",
-					Required: true,
+					Desc:     "SQL查询语句（使用数据源对应的SQL方言），为空时只返回表结构",
+					Required: false,
 				},
 				"limit": {
-					Type:     schema.Number,
-					Desc:     "查询结果限制行数（默认1000）",
+					Type:     schema.Integer,
+					Desc:     fmt.Sprintf("查询结果限制行数（默认%d，最多%d）", sqlengine.DefaultMaxRows, sqlengine.MaxRowsLimit),
 					Required: false,
 				},
 			}),
@@ -60,124 +60,68 @@ func (t *DatabaseTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
 // InvokableRun 执行工具
 func (t *DatabaseTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
 	var args struct {
-		DbType           string `json:"db_type"`
-		ConnectionString string `json:"connection_string"`
-		Query            string `json:"query"`
-		Limit            int    `json:"limit,omitempty"`
+		DataSourceID int    `json:"data_source_id"`
+		Query        string `json:"query,omitempty"`
+		Limit        int    `json:"limit,omitempty"`
 	}
 
 	if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
 		return "", err
 	}
+	if t.sources == nil {
+		return "数据库查询失败: 未配置数据源", nil
+	}
+	if args.DataSourceID <= 0 {
+		return "数据库查询失败: 未指定数据源ID", nil
+	}
 
-	if args.Limit <= 0 {
-		args.Limit = 1000
+	tables, err := t.sources.Tables(ctx, args.DataSourceID)
+	if err != nil {
+		return "数据库查询失败: " + err.Error(), nil
+	}
+	if strings.TrimSpace(args.Query) == "" {
+		return "数据源中的表:\n" + DescribeSchemas(tables), nil
 	}
 
-	code := t.generateDatabaseCode(args.DbType, args.ConnectionString, args.Query, args.Limit)
+	result, err := t.sources.Query(ctx, args.DataSourceID, args.Query, args.Limit)
+	if err != nil {
+		// 附上表结构便于修正SQL
+		return fmt.Sprintf("数据库查询失败: %v\n\n数据源中的表:\n%s", err, DescribeSchemas(tables)), nil
+	}
 
-	result, err := t.sandbox.ExecutePython(code)
+	data, err := json.Marshal(result)
 	if err != nil {
 		return "", err
 	}
-
-	if !result.Success {
-		return "数据库查询失败: " + result.Error, nil
+	summary := fmt.Sprintf("查询返回 %d 行", result.RowCount)
+	if result.Truncated {
+		summary += "（已达到行数上限，结果被截断）"
 	}
-
-	return result.Stdout, nil
+	return fmt.Sprintf("%s\n\n结果:\n%s", summary, data), nil
 }
 
-// generateDatabaseCode 生成数据库连接代码
-func (t *DatabaseTool) generateDatabaseCode(dbType, connectionString, query string, limit int) string {
-	code := fmt.Sprintf(`
-import pandas as pd
-import json
-
-# 数据库连接配置
-db_type = "%s"
-connection_string = "%s"
-query = """
-%s
-"""
-limit = %d
-
-try:
-    # 根据数据库类型选择连接方式
-    if db_type.lower() == "sqlite":
-        import sqlite3
-        conn = sqlite3.connect(connection_string)
-        df = pd.read_sql_query(query, conn)
-        conn.close()
-        
-    elif db_type.lower() == "mysql":
-        try:
-            import pymysql
-            # 解析连接字符串（简化版本）
-            if connection_string.startswith('mysql://'):
-                import sqlalchemy
-                engine = sqlalchemy.create_engine(connection_string)
-                df = pd.read_sql_query(query, engine)
-                engine.dispose()
-            else:
-                print(json.dumps({"error": "MySQL连接需要完整的连接字符串"}, ensure_ascii=False))
-                exit()
-        except ImportError:
-            print(json.dumps({"error": "缺少pymysql或sqlalchemy库"}, ensure_ascii=False))
-            exit()
-            
-    elif db_type.lower() == "postgresql":
-        try:
-            import psycopg2
-            import sqlalchemy
-            if connection_string.startswith('postgresql://'):
-                engine = sqlalchemy.create_engine(connection_string)
-                df = pd.read_sql_query(query, engine)
-                engine.dispose()
-            else:
-                print(json.dumps({"error": "PostgreSQL连接需要完整的连接字符串"}, ensure_ascii=False))
-                exit()
-        except ImportError:
-            print(json.dumps({"error": "缺少psycopg2或sqlalchemy库"}, ensure_ascii=False))
-            exit()
-            
-    else:
-        print(json.dumps({"error": f"不支持的数据库类型: {db_type}"}, ensure_ascii=False))
-        exit()
-    
-    # 限制结果行数
-    if len(df) > limit:
-        df = df.head(limit)
-        truncated = True
-    else:
-        truncated = False
-    
-    # 格式化结果
-    result = {
-        "success": True,
-        "db_type": db_type,
-        "query": query,
-        "data": {
-            "shape": df.shape,
-            "columns": df.columns.tolist(),
-            "dtypes": df.dtypes.astype(str).to_dict(),
-            "records": df.to_dict("records"),
-            "truncated": truncated,
-            "total_rows": len(df)
-        }
-    }
-    
-    print(json.dumps(result, ensure_ascii=False, indent=2, default=str))
-    
-except Exception as e:
-    error_result = {
-        "success": False,
-        "error": str(e),
-        "db_type": db_type
-    }
-    print(json.dumps(error_result, ensure_ascii=False, indent=2))
-
-`, dbType, connectionString, query, limit)
-
-	return code
+// DescribeSchemas 生成数据源表结构描述
+func DescribeSchemas(tables []types.DataSchema) string {
+	if len(tables) == 0 {
+		return "（无）"
+	}
+	var sb strings.Builder
+	for _, table := range tables {
+		sb.WriteString("- " + table.TableName)
+		if comment, ok := table.Metadata["comment"].(string); ok && comment != "" {
+			sb.WriteString("（" + comment + "）")
+		}
+		sb.WriteString(":\n")
+		for _, column := range table.Columns {
+			sb.WriteString(fmt.Sprintf("    %s %s", column.Name, column.Type))
+			if column.Description != "" {
+				sb.WriteString(" -- " + column.Description)
+			}
+			sb.WriteString("\n")
+		}
+		for _, constraint := range table.Constraints {
+			sb.WriteString("    " + constraint + "\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
 }
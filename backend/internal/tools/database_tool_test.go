@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"smart-analysis/internal/sqlengine"
+	"smart-analysis/internal/types"
+)
+
+// fakeDataSources 只包含ID为1的数据源
+type fakeDataSources struct {
+	queries []string
+}
+
+func (f *fakeDataSources) Tables(ctx context.Context, id int) ([]types.DataSchema, error) {
+	if id != 1 {
+		return nil, errors.New("data source not found")
+	}
+	return []types.DataSchema{{
+		TableName:   "orders",
+		Columns:     []types.ColumnInfo{{Name: "id", Type: "INTEGER", IsKey: true}, {Name: "amount", Type: "REAL", Description: "订单金额"}},
+		Constraints: []string{"PRIMARY KEY (id)"},
+	}}, nil
+}
+
+func (f *fakeDataSources) Dialect(ctx context.Context, id int) (string, error) {
+	return "sqlite", nil
+}
+
+func (f *fakeDataSources) Semantic(ctx context.Context, id int) (*types.SemanticModel, error) {
+	return nil, nil
+}
+
+func (f *fakeDataSources) Query(ctx context.Context, id int, query string, maxRows int) (*sqlengine.Result, error) {
+	f.queries = append(f.queries, query)
+	return &sqlengine.Result{Columns: []string{"total"}, Rows: [][]interface{}{{30}}, RowCount: 1}, nil
+}
+
+func TestDatabaseTool(t *testing.T) {
+	sources := &fakeDataSources{}
+	dbTool := NewDatabaseTool(sources)
+	ctx := context.Background()
+
+	out, err := dbTool.InvokableRun(ctx, `{"data_source_id": 1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "amount REAL -- 订单金额") || !strings.Contains(out, "PRIMARY KEY (id)") {
+		t.Errorf("表结构描述不正确:\n%s", out)
+	}
+
+	out, _ = dbTool.InvokableRun(ctx, `{"data_source_id": 1, "query": "SELECT SUM(amount) AS total FROM orders"}`)
+	if !strings.Contains(out, "查询返回 1 行") || len(sources.queries) != 1 {
+		t.Errorf("查询结果不正确:\n%s", out)
+	}
+
+	// 连接串等额外参数不会被使用，未知数据源直接失败
+	out, _ = dbTool.InvokableRun(ctx, `{"data_source_id": 2, "connection_string": "mysql://root@evil/db", "query": "SELECT 1"}`)
+	if !strings.HasPrefix(out, "数据库查询失败: data source not found") || len(sources.queries) != 1 {
+		t.Errorf("应拒绝未知数据源:\n%s", out)
+	}
+
+	out, _ = NewDatabaseTool(nil).InvokableRun(ctx, `{"data_source_id": 1}`)
+	if out != "数据库查询失败: 未配置数据源" {
+		t.Errorf("未配置数据源时应返回提示: %s", out)
+	}
+}
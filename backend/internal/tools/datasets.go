@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cloudwego/eino/components/tool"
+	"smart-analysis/internal/types"
+)
+
+// DatasetResolver 将上传文件的引用解析为当前用户可见的数据视图
+type DatasetResolver = types.DatasetResolver
+
+// datasetTool 在调用工具前将参数中的 file_path 和 files 解析为数据视图，
+// 使Python和Go实现的工具读取到的都是按访问策略过滤、按敏感列策略脱敏后的数据
+type datasetTool struct {
+	tool.InvokableTool
+	datasets DatasetResolver
+}
+
+// WithDatasets 为读取数据文件的工具包装数据集解析，datasets 为空或工具不可调用时原样返回
+func WithDatasets(t tool.BaseTool, datasets DatasetResolver) tool.BaseTool {
+	if _, wrapped := t.(*datasetTool); wrapped || datasets == nil {
+		return t
+	}
+	invokable, ok := t.(tool.InvokableTool)
+	if !ok {
+		return t
+	}
+	return &datasetTool{InvokableTool: invokable, datasets: datasets}
+}
+
+// InvokableRun 解析参数中的数据文件后执行工具，无权访问时返回失败说明
+func (t *datasetTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+		return t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+	}
+
+	resolve := func(value interface{}) (interface{}, error) {
+		path, ok := value.(string)
+		if !ok || path == "" {
+			return value, nil
+		}
+		return t.datasets.ResolveDataset(ctx, path)
+	}
+
+	changed := false
+	if value, ok := args["file_path"]; ok {
+		path, err := resolve(value)
+		if err != nil {
+			return fmt.Sprintf("无法读取数据文件 %v: %v", value, err), nil
+		}
+		args["file_path"] = path
+		changed = true
+	}
+	if files, ok := args["files"].([]interface{}); ok {
+		for i, value := range files {
+			path, err := resolve(value)
+			if err != nil {
+				return fmt.Sprintf("无法读取数据文件 %v: %v", value, err), nil
+			}
+			files[i] = path
+		}
+		changed = true
+	}
+	if !changed {
+		return t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+	}
+
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	return t.InvokableTool.InvokableRun(ctx, string(data), opts...)
+}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// fakeDatasets 将 sales.csv 解析为数据视图，拒绝读取上传目录中的原始文件
+type fakeDatasets struct {
+	view    string
+	uploads string
+}
+
+func (d *fakeDatasets) ResolveDataset(ctx context.Context, ref string) (string, error) {
+	switch {
+	case ref == "sales.csv":
+		return d.view, nil
+	case strings.HasPrefix(ref, d.uploads):
+		return "", errors.New("permission denied")
+	}
+	return ref, nil
+}
+
+func (d *fakeDatasets) ResolveUpload(ctx context.Context, ref string) (string, error) {
+	if ref == "sales.csv" || ref == d.view {
+		return d.view, nil
+	}
+	return "", errors.New("permission denied")
+}
+
+func TestToolRegistry_Datasets(t *testing.T) {
+	uploads := t.TempDir()
+	raw := filepath.Join(uploads, "sales.csv")
+	if err := os.WriteFile(raw, []byte("region,sales\nEast,100\nWest,300\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	view := filepath.Join(t.TempDir(), "sales.csv")
+	if err := os.WriteFile(view, []byte("region,sales\nEast,100\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewToolRegistry(nil)
+	registry.SetDatasets(&fakeDatasets{view: view, uploads: uploads})
+	registry.RegisterCoreToolsOnly()
+
+	run := func(name, args string) string {
+		t.Helper()
+		found, _ := registry.GetTool(name)
+		out, err := found.(tool.InvokableTool).InvokableRun(context.Background(), args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	// 文件名解析为数据视图，只能看到授权的行
+	out := run("sql_query", `{"sql": "SELECT region FROM sales", "files": ["sales.csv"]}`)
+	if !strings.Contains(out, "East") || strings.Contains(out, "West") {
+		t.Errorf("SQL工具应读取数据视图:\n%s", out)
+	}
+	out = run("data_query", `{"query": "filter region == 'West'", "file_path": "sales.csv"}`)
+	if strings.Contains(out, "300") {
+		t.Errorf("数据查询工具应读取数据视图:\n%s", out)
+	}
+
+	// 直接给出原始上传文件的路径会被拒绝
+	for _, args := range []string{
+		fmt.Sprintf(`{"sql": "SELECT * FROM sales", "files": [%q]}`, raw),
+		fmt.Sprintf(`{"query": "head 10", "file_path": %q}`, raw),
+	} {
+		name := "sql_query"
+		if strings.Contains(args, "file_path") {
+			name = "data_query"
+		}
+		if out := run(name, args); !strings.HasPrefix(out, "无法读取数据文件") || strings.Contains(out, "West") {
+			t.Errorf("应拒绝读取原始文件:\n%s", out)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package tools
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -12,6 +14,9 @@ import (
 	"smart-analysis/internal/utils/sanbox"
 )
 
+// echartsMaxPoints 图表中的最大类别数或数据点数
+const echartsMaxPoints = 1000
+
 // EChartsVisualizationTool ECharts数据可视化工具
 type EChartsVisualizationTool struct {
 	sandbox *sanbox.PythonSandbox
@@ -24,7 +29,7 @@ func NewEChartsVisualizationTool(sandbox *sanbox.PythonSandbox) *EChartsVisualiz
 	return &EChartsVisualizationTool{
 		sandbox: sandbox,
 		name:    "echarts_visualization",
-		desc:    "创建ECharts格式的交互式数据可视化图表，返回可在前端直接渲染的图表配置。支持柱状图、折线图、饼图、散点图、热力图等多种图表类型。",
+		desc:    "创建ECharts格式的交互式数据可视化图表，返回可在前端直接渲染的图表配置。支持柱状图、折线图、面积图、饼图、散点图、热力图和雷达图。",
 	}
 }
 
@@ -42,7 +47,7 @@ func (t *EChartsVisualizationTool) Info(ctx context.Context) (*schema.ToolInfo,
 				},
 				"data_columns": {
 					Type: schema.Array,
-					Desc: "用于可视化的数据列名",
+					Desc: "用于可视化的数据列名。柱状图/折线图/面积图：分类列加一个或多个数值列；饼图：名称列和数值列；散点图：两个数值列；雷达图：至少三个数值列；热力图：参与相关性计算的数值列",
 					ElemInfo: &schema.ParameterInfo{
 						Type: schema.String,
 					},
@@ -56,11 +61,11 @@ func (t *EChartsVisualizationTool) Info(ctx context.Context) (*schema.ToolInfo,
 				"file_path": {
 					Type:     schema.String,
 					Desc:     "数据文件路径",
-					Required: false,
+					Required: true,
 				},
 				"custom_options": {
 					Type:     schema.String,
-					Desc:     "自定义ECharts配置选项（JSON格式）",
+					Desc:     "自定义ECharts配置选项（JSON对象），合并到图表配置的 options 中",
 					Required: false,
 				},
 				"fallback_to_image": {
@@ -86,466 +91,294 @@ func (t *EChartsVisualizationTool) InvokableRun(ctx context.Context, argumentsIn
 	if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
 		return "", err
 	}
+	if !types.IsChartType(args.ChartType) {
+		args.ChartType = types.ChartBar // 默认柱状图
+	}
 
-	// 生成ECharts配置代码
-	code := t.generateEChartsCode(args.ChartType, args.DataColumns, args.Title, args.FilePath, args.CustomOptions)
-
-	// 执行Python代码
-	result, err := t.sandbox.ExecutePython(code)
+	spec := &types.ChartSpec{
+		ChartType: args.ChartType,
+		Columns:   args.DataColumns,
+		Title:     args.Title,
+		FilePath:  args.FilePath,
+	}
+	config, err := t.render(ctx, spec, args.CustomOptions)
 	if err != nil {
 		if args.FallbackToImage {
 			// 回退到静态图片生成
-			return t.generateStaticImageFallback(args.ChartType, args.DataColumns, args.Title, args.FilePath)
+			return t.generateStaticImageFallback(ctx, spec)
 		}
+		return "图表生成失败: " + err.Error(), nil
+	}
+
+	configJSON, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
 		return "", err
 	}
+	return fmt.Sprintf("ECharts图表配置生成成功:\n```json\n%s\n```", configJSON), nil
+}
 
-	if !result.Success {
-		if args.FallbackToImage {
-			// 回退到静态图片生成
-			return t.generateStaticImageFallback(args.ChartType, args.DataColumns, args.Title, args.FilePath)
+// Render 读取数据文件生成图表配置，返回的配置已通过校验
+func (t *EChartsVisualizationTool) Render(ctx context.Context, spec *types.ChartSpec) (*types.EChartsConfig, error) {
+	return t.render(ctx, spec, "")
+}
+
+// render 检查图表参数，在沙箱中生成图表配置并校验
+func (t *EChartsVisualizationTool) render(ctx context.Context, spec *types.ChartSpec, customOptions string) (*types.EChartsConfig, error) {
+	if err := checkChartColumns(spec); err != nil {
+		return nil, err
+	}
+	if spec.FilePath == "" {
+		return nil, errors.New("缺少数据文件路径")
+	}
+	options := map[string]interface{}{}
+	if customOptions != "" {
+		if err := json.Unmarshal([]byte(customOptions), &options); err != nil {
+			return nil, fmt.Errorf("自定义配置解析失败: %w", err)
 		}
-		return "图表生成失败: " + result.Error, nil
 	}
 
-	// 格式化结果为ECharts配置
-	return t.formatEChartsResult(result), nil
+	code, err := t.generateEChartsCode(spec, options)
+	if err != nil {
+		return nil, err
+	}
+	result, err := t.sandbox.ExecuteCodeContext(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, errors.New(strings.TrimSpace(result.Error))
+	}
+	return parseEChartsResult(result)
+}
+
+// checkChartColumns 检查图表类型和数据列数量是否匹配
+func checkChartColumns(spec *types.ChartSpec) error {
+	n := len(spec.Columns)
+	switch spec.ChartType {
+	case types.ChartBar, types.ChartLine, types.ChartArea:
+		if n < 2 {
+			return fmt.Errorf("%s 图需要一个分类列和至少一个数值列", spec.ChartType)
+		}
+	case types.ChartPie, types.ChartScatter:
+		if n != 2 {
+			return fmt.Errorf("%s 图需要两列数据", spec.ChartType)
+		}
+	case types.ChartRadar:
+		if n < 3 {
+			return errors.New("雷达图需要至少三个数值列")
+		}
+	case types.ChartHeatmap:
+		if n == 1 {
+			return errors.New("热力图需要至少两个数值列")
+		}
+	default:
+		return fmt.Errorf("不支持的图表类型: %s", spec.ChartType)
+	}
+	return nil
 }
 
 // generateEChartsCode 生成ECharts配置的Python代码
-func (t *EChartsVisualizationTool) generateEChartsCode(chartType string, columns []string, title, filePath, customOptions string) string {
+func (t *EChartsVisualizationTool) generateEChartsCode(spec *types.ChartSpec, options map[string]interface{}) (string, error) {
+	code, err := chartDataCode(spec, options)
+	if err != nil {
+		return "", err
+	}
+	code += echartsConfigCode
+	switch spec.ChartType {
+	case types.ChartBar, types.ChartLine, types.ChartArea:
+		code += echartsCategoryCode
+	case types.ChartPie:
+		code += echartsPieCode
+	case types.ChartScatter:
+		code += echartsScatterCode
+	case types.ChartHeatmap:
+		code += echartsHeatmapCode
+	case types.ChartRadar:
+		code += echartsRadarCode
+	}
+	return code + echartsOutputCode, nil
+}
+
+// chartDataCode 生成读取参数和数据的Python代码。
+// 参数以 base64 编码的 JSON 传入，列名、标题和路径不会被解释为代码
+func chartDataCode(spec *types.ChartSpec, options map[string]interface{}) (string, error) {
+	title := spec.Title
 	if title == "" {
 		title = "数据可视化图表"
 	}
+	params, err := json.Marshal(map[string]interface{}{
+		"chart_type":   spec.ChartType,
+		"data_columns": spec.Columns,
+		"title":        title,
+		"file_path":    spec.FilePath,
+		"options":      options,
+		"max_points":   echartsMaxPoints,
+	})
+	if err != nil {
+		return "", err
+	}
 
-	code := fmt.Sprintf(`
+	return fmt.Sprintf(echartsDataCode, base64.StdEncoding.EncodeToString(params)), nil
+}
+
+// 沙箱执行代码时局部变量与全局变量分开，
+// 因此以下代码不使用函数定义和引用局部变量的推导式
+
+// echartsDataCode 读取参数和数据，检查数据列
+const echartsDataCode = `
+import base64
+import json
 import pandas as pd
 import numpy as np
-import json
 
-# 读取数据
-%s
+params = json.loads(base64.b64decode("%s").decode("utf-8"))
+chart_type = params["chart_type"]
+columns = params["data_columns"]
+file_path = params["file_path"]
+max_points = params["max_points"]
 
-# 数据验证
-if 'df' not in locals():
-    print("ERROR: 数据未加载成功")
-    exit()
+if file_path.lower().endswith((".xlsx", ".xls")):
+    df = pd.read_excel(file_path)
+elif file_path.lower().endswith(".json"):
+    df = pd.read_json(file_path)
+else:
+    df = pd.read_csv(file_path)
+
+missing = []
+for col in columns:
+    if col not in df.columns:
+        missing.append(str(col))
+if missing:
+    raise ValueError("列 " + ", ".join(missing) + " 在数据中不存在")
+`
 
-# 基础图表配置
+// echartsConfigCode 初始化图表配置
+const echartsConfigCode = `
 chart_config = {
-    "type": "echarts",
-    "chartType": "%s",
-    "title": "%s",
+    "type": chart_type,
+    "title": params["title"],
     "data": [],
     "xAxis": [],
-    "yAxis": [],
     "series": [],
-    "legend": {},
-    "tooltip": {
-        "trigger": "axis",
-        "axisPointer": {
-            "type": "shadow"
-        }
-    },
-    "grid": {
-        "left": "3%%",
-        "right": "4%%",
-        "bottom": "3%%",
-        "containLabel": True
-    }
+    "options": params["options"],
 }
-
-`, t.getDataLoadCode(filePath), chartType, title)
-
-	switch chartType {
-	case "bar", "line":
-		code += t.generateBarLineChartCode(columns, chartType)
-	case "pie":
-		code += t.generatePieChartCode(columns)
-	case "scatter":
-		code += t.generateScatterChartCode(columns)
-	case "heatmap":
-		code += t.generateHeatmapChartCode(columns)
-	case "area":
-		code += t.generateAreaChartCode(columns)
-	case "radar":
-		code += t.generateRadarChartCode(columns)
-	default:
-		code += t.generateBarLineChartCode(columns, "bar") // 默认柱状图
-	}
-
-	// 添加自定义选项
-	if customOptions != "" {
-		code += fmt.Sprintf(`
-# 合并自定义配置
-try:
-    custom_opts = json.loads('''%s''')
-    chart_config.update(custom_opts)
-except Exception as e:
-    print(f"自定义配置解析失败: {e}")
-
-`, customOptions)
-	}
-
-	code += `
-# 输出结果
-print("ECHARTS_CONFIG_START")
-print(json.dumps(chart_config, ensure_ascii=False, indent=2))
-print("ECHARTS_CONFIG_END")
 `
 
-	return code
-}
-
-// getDataLoadCode 获取数据加载代码
-func (t *EChartsVisualizationTool) getDataLoadCode(filePath string) string {
-	if filePath != "" {
-		return fmt.Sprintf("df = pd.read_csv('%s')", filePath)
-	}
-	return "# 假设数据已经加载到df变量中"
-}
-
-// generateBarLineChartCode 生成柱状图/折线图代码
-func (t *EChartsVisualizationTool) generateBarLineChartCode(columns []string, chartType string) string {
-	if len(columns) < 2 {
-		return `
-# 数据列不足
-chart_config["data"] = []
-print("WARNING: 需要至少两列数据用于柱状图/折线图")
+// echartsCategoryCode 柱状图、折线图和面积图：按分类列汇总数值列，每个数值列一个系列
+const echartsCategoryCode = `
+x_col = columns[0]
+y_cols = columns[1:]
+plot_data = df[columns].copy()
+for col in y_cols:
+    plot_data[col] = pd.to_numeric(plot_data[col], errors="coerce")
+plot_data = plot_data.dropna()
+if plot_data.empty:
+    raise ValueError("数值列没有可用的数据")
+plot_data = plot_data.groupby(x_col, sort=(chart_type != "bar"))[y_cols].sum().reset_index().head(max_points)
+
+series_type = chart_type
+if chart_type == "area":
+    series_type = "line"
+    chart_config["options"]["areaStyle"] = {}
+chart_config["xAxis"] = plot_data[x_col].astype(str).tolist()
+for col in y_cols:
+    chart_config["series"].append({"name": str(col), "type": series_type, "data": plot_data[col].astype(float).tolist()})
 `
-	}
-
-	return fmt.Sprintf(`
-# 柱状图/折线图配置
-x_col = "%s"
-y_col = "%s"
-
-if x_col in df.columns and y_col in df.columns:
-    # 数据预处理
-    plot_data = df[[x_col, y_col]].dropna()
-    
-    chart_config["xAxis"] = {
-        "type": "category",
-        "data": plot_data[x_col].astype(str).tolist()
-    }
-    chart_config["yAxis"] = {
-        "type": "value"
-    }
-    chart_config["series"] = [{
-        "name": y_col,
-        "type": "%s",
-        "data": plot_data[y_col].tolist(),
-        "itemStyle": {
-            "color": "#5470c6"
-        }
-    }]
-    chart_config["legend"] = {
-        "data": [y_col]
-    }
-else:
-    print(f"ERROR: 列 {x_col} 或 {y_col} 在数据中不存在")
-`, columns[0], columns[1], chartType)
-}
 
-// generatePieChartCode 生成饼图代码
-func (t *EChartsVisualizationTool) generatePieChartCode(columns []string) string {
-	if len(columns) < 2 {
-		return `
-# 数据列不足
-chart_config["data"] = []
-print("WARNING: 需要至少两列数据用于饼图")
+// echartsPieCode 饼图：按名称列汇总数值列，只保留正值
+const echartsPieCode = `
+name_col = columns[0]
+value_col = columns[1]
+pie_data = df[[name_col, value_col]].copy()
+pie_data[value_col] = pd.to_numeric(pie_data[value_col], errors="coerce")
+pie_data = pie_data.dropna().groupby(name_col)[value_col].sum()
+pie_data = pie_data[pie_data > 0].sort_values(ascending=False).head(max_points)
+if pie_data.empty:
+    raise ValueError("数值列没有大于0的数据")
+for name, value in pie_data.items():
+    chart_config["data"].append({"name": str(name), "value": float(value)})
 `
-	}
-
-	return fmt.Sprintf(`
-# 饼图配置
-name_col = "%s"
-value_col = "%s"
-
-if name_col in df.columns and value_col in df.columns:
-    # 数据预处理和聚合
-    pie_data = df.groupby(name_col)[value_col].sum().reset_index()
-    
-    chart_config["series"] = [{
-        "name": "数据分布",
-        "type": "pie",
-        "radius": ["40%%", "70%%"],
-        "avoidLabelOverlap": False,
-        "data": [
-            {"name": str(name), "value": float(value)} 
-            for name, value in zip(pie_data[name_col], pie_data[value_col])
-            if pd.notna(name) and pd.notna(value) and value > 0
-        ],
-        "emphasis": {
-            "itemStyle": {
-                "shadowBlur": 10,
-                "shadowOffsetX": 0,
-                "shadowColor": "rgba(0, 0, 0, 0.5)"
-            }
-        }
-    }]
-    chart_config["tooltip"] = {
-        "trigger": "item",
-        "formatter": "{a} <br/>{b}: {c} ({d}%%)"
-    }
-else:
-    print(f"ERROR: 列 {name_col} 或 {value_col} 在数据中不存在")
-`, columns[0], columns[1])
-}
 
-// generateScatterChartCode 生成散点图代码
-func (t *EChartsVisualizationTool) generateScatterChartCode(columns []string) string {
-	if len(columns) < 2 {
-		return `
-# 数据列不足
-chart_config["data"] = []
-print("WARNING: 需要至少两列数据用于散点图")
+// echartsScatterCode 散点图：两个数值列
+const echartsScatterCode = `
+scatter_data = df[columns].apply(pd.to_numeric, errors="coerce").dropna().head(max_points)
+if scatter_data.empty:
+    raise ValueError("数值列没有可用的数据")
+for x, y in zip(scatter_data[columns[0]], scatter_data[columns[1]]):
+    chart_config["data"].append({"value": [float(x), float(y)]})
+chart_config["options"]["xAxisName"] = str(columns[0])
+chart_config["options"]["yAxisName"] = str(columns[1])
 `
-	}
 
-	return fmt.Sprintf(`
-# 散点图配置
-x_col = "%s"
-y_col = "%s"
-
-if x_col in df.columns and y_col in df.columns:
-    # 数据预处理
-    scatter_data = df[[x_col, y_col]].dropna()
-    
-    chart_config["xAxis"] = {
-        "type": "value",
-        "name": x_col
-    }
-    chart_config["yAxis"] = {
-        "type": "value", 
-        "name": y_col
-    }
-    chart_config["series"] = [{
-        "name": f"{x_col} vs {y_col}",
-        "type": "scatter",
-        "data": [[float(x), float(y)] for x, y in zip(scatter_data[x_col], scatter_data[y_col])],
-        "symbolSize": 8,
-        "itemStyle": {
-            "color": "#ff7f50"
-        }
-    }]
-    chart_config["tooltip"] = {
-        "trigger": "item",
-        "formatter": f"{x_col}: {{c[0]}}<br/>{y_col}: {{c[1]}}"
-    }
+// echartsHeatmapCode 热力图：数值列的相关性矩阵
+const echartsHeatmapCode = `
+if columns:
+    numeric_data = df[columns].apply(pd.to_numeric, errors="coerce")
 else:
-    print(f"ERROR: 列 {x_col} 或 {y_col} 在数据中不存在")
-`, columns[0], columns[1])
-}
-
-// generateAreaChartCode 生成面积图代码
-func (t *EChartsVisualizationTool) generateAreaChartCode(columns []string) string {
-	if len(columns) < 2 {
-		return `
-# 数据列不足
-chart_config["data"] = []
-print("WARNING: 需要至少两列数据用于面积图")
+    numeric_data = df.select_dtypes(include=[np.number])
+numeric_data = numeric_data.dropna(axis=1, how="all")
+if numeric_data.shape[1] < 2:
+    raise ValueError("热力图需要至少两个数值列")
+corr_matrix = numeric_data.corr().fillna(0)
+labels = corr_matrix.columns.astype(str).tolist()
+chart_config["xAxis"] = labels
+chart_config["options"]["yAxis"] = labels
+for i in range(len(labels)):
+    for j in range(len(labels)):
+        chart_config["data"].append({"name": labels[i] + "-" + labels[j], "value": [j, i, round(float(corr_matrix.iloc[i, j]), 4)]})
 `
-	}
 
-	return fmt.Sprintf(`
-# 面积图配置
-x_col = "%s"
-y_col = "%s"
-
-if x_col in df.columns and y_col in df.columns:
-    # 数据预处理
-    area_data = df[[x_col, y_col]].dropna().sort_values(x_col)
-    
-    chart_config["xAxis"] = {
-        "type": "category",
-        "data": area_data[x_col].astype(str).tolist()
-    }
-    chart_config["yAxis"] = {
-        "type": "value"
-    }
-    chart_config["series"] = [{
-        "name": y_col,
-        "type": "line",
-        "data": area_data[y_col].tolist(),
-        "areaStyle": {},
-        "smooth": True,
-        "itemStyle": {
-            "color": "#91cc75"
-        }
-    }]
-else:
-    print(f"ERROR: 列 {x_col} 或 {y_col} 在数据中不存在")
-`, columns[0], columns[1])
-}
-
-// generateRadarChartCode 生成雷达图代码
-func (t *EChartsVisualizationTool) generateRadarChartCode(columns []string) string {
-	if len(columns) < 3 {
-		return `
-# 数据列不足
-chart_config["data"] = []
-print("WARNING: 雷达图需要至少3列数据")
+// echartsRadarCode 雷达图：前5行样本，每行一个系列
+const echartsRadarCode = `
+radar_data = df[columns].apply(pd.to_numeric, errors="coerce").dropna().head(5)
+if radar_data.empty:
+    raise ValueError("数值列没有可用的数据")
+indicator = []
+for col in columns:
+    indicator.append({"name": str(col), "max": float(radar_data[col].max()) * 1.2})
+chart_config["xAxis"] = [str(col) for col in columns]
+chart_config["options"]["indicator"] = indicator
+sample = 0
+for _, row in radar_data.iterrows():
+    sample += 1
+    chart_config["series"].append({"name": "样本 " + str(sample), "type": "radar", "data": row.astype(float).tolist()})
 `
-	}
-
-	return fmt.Sprintf(`
-# 雷达图配置
-indicator_cols = %s
-
-# 验证列是否存在
-missing_cols = [col for col in indicator_cols if col not in df.columns]
-if missing_cols:
-    print(f"ERROR: 列 {missing_cols} 在数据中不存在")
-else:
-    # 数据预处理
-    radar_data = df[indicator_cols].dropna()
-    
-    # 计算每个指标的最大值用于雷达图范围
-    max_values = radar_data.max()
-    
-    chart_config["radar"] = {
-        "indicator": [
-            {"name": col, "max": float(max_values[col]) * 1.2}
-            for col in indicator_cols
-        ]
-    }
-    
-    chart_config["series"] = [{
-        "name": "雷达图数据",
-        "type": "radar",
-        "data": [
-            {
-                "value": row.tolist(),
-                "name": f"样本 {idx+1}"
-            }
-            for idx, (_, row) in enumerate(radar_data.head(5).iterrows())
-        ]
-    }]
-`, fmt.Sprintf("%q", columns))
-}
 
-// generateHeatmapChartCode 生成热力图代码
-func (t *EChartsVisualizationTool) generateHeatmapChartCode(columns []string) string {
-	return `
-# 热力图配置（使用数值列的相关性矩阵）
-numeric_cols = df.select_dtypes(include=[np.number]).columns.tolist()
-if len(numeric_cols) >= 2:
-    corr_matrix = df[numeric_cols].corr()
-    
-    data = []
-    for i, row_name in enumerate(corr_matrix.index):
-        for j, col_name in enumerate(corr_matrix.columns):
-            data.append({"name": f"{row_name}-{col_name}", "value": [i, j, float(corr_matrix.iloc[i, j])]})
-    
-    chart_config["data"] = data
-    chart_config["xAxis"] = corr_matrix.columns.tolist()
-    chart_config["options"]["yAxis"] = corr_matrix.index.tolist()
+// echartsOutputCode 输出图表配置
+const echartsOutputCode = `
+print("ECHARTS_CONFIG_START")
+print(json.dumps(chart_config, ensure_ascii=False))
+print("ECHARTS_CONFIG_END")
 `
-}
-
-// formatEChartsResult 格式化ECharts结果
-func (t *EChartsVisualizationTool) formatEChartsResult(result *sanbox.PythonExecutionResult) string {
-	if result.Stdout == "" {
-		return "未生成图表配置"
-	}
 
-	// 提取ECharts配置
+// parseEChartsResult 从沙箱输出中提取并校验ECharts配置
+func parseEChartsResult(result *sanbox.PythonExecutionResult) (*types.EChartsConfig, error) {
 	output := result.Stdout
 	startIdx := strings.Index(output, "ECHARTS_CONFIG_START")
 	endIdx := strings.Index(output, "ECHARTS_CONFIG_END")
-
-	if startIdx == -1 || endIdx == -1 {
-		return "图表配置解析失败: " + output
+	if startIdx == -1 || endIdx < startIdx {
+		return nil, fmt.Errorf("未生成图表配置: %s", strings.TrimSpace(output))
 	}
 
-	configJSON := output[startIdx+len("ECHARTS_CONFIG_START") : endIdx]
-	configJSON = strings.TrimSpace(configJSON)
-
-	// 验证JSON格式
+	configJSON := strings.TrimSpace(output[startIdx+len("ECHARTS_CONFIG_START") : endIdx])
 	var config types.EChartsConfig
 	if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
-		return "图表配置JSON格式错误: " + err.Error()
+		return nil, fmt.Errorf("图表配置JSON格式错误: %w", err)
 	}
-
-	return fmt.Sprintf("ECharts图表配置生成成功:\n```json\n%s\n```", configJSON)
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("图表配置无效: %w", err)
+	}
+	return &config, nil
 }
 
 // generateStaticImageFallback 生成静态图片作为回退方案
-func (t *EChartsVisualizationTool) generateStaticImageFallback(chartType string, columns []string, title, filePath string) (string, error) {
-	if title == "" {
-		title = "数据可视化图表"
-	}
-
-	code := fmt.Sprintf(`
-import pandas as pd
-import numpy as np
-import matplotlib.pyplot as plt
-import seaborn as sns
-
-# 设置中文字体
-plt.rcParams['font.sans-serif'] = ['SimHei', 'Arial Unicode MS', 'DejaVu Sans']
-plt.rcParams['axes.unicode_minus'] = False
-
-# 读取数据
-%s
-
-if 'df' not in locals():
-    print("ERROR: 数据未加载成功")
-    exit()
-
-# 创建图表
-plt.figure(figsize=(10, 6))
-plt.title("%s")
-
-`, t.getDataLoadCode(filePath), title)
-
-	switch chartType {
-	case "bar":
-		if len(columns) >= 2 {
-			code += fmt.Sprintf(`
-if "%s" in df.columns and "%s" in df.columns:
-    plt.bar(df["%s"], df["%s"])
-    plt.xlabel("%s")
-    plt.ylabel("%s")
-`, columns[0], columns[1], columns[0], columns[1], columns[0], columns[1])
-		}
-	case "line":
-		if len(columns) >= 2 {
-			code += fmt.Sprintf(`
-if "%s" in df.columns and "%s" in df.columns:
-    plt.plot(df["%s"], df["%s"], marker='o')
-    plt.xlabel("%s")
-    plt.ylabel("%s")
-`, columns[0], columns[1], columns[0], columns[1], columns[0], columns[1])
-		}
-	case "scatter":
-		if len(columns) >= 2 {
-			code += fmt.Sprintf(`
-if "%s" in df.columns and "%s" in df.columns:
-    plt.scatter(df["%s"], df["%s"])
-    plt.xlabel("%s")
-    plt.ylabel("%s")
-`, columns[0], columns[1], columns[0], columns[1], columns[0], columns[1])
-		}
-	case "pie":
-		if len(columns) >= 2 {
-			code += fmt.Sprintf(`
-if "%s" in df.columns and "%s" in df.columns:
-    pie_data = df.groupby("%s")["%s"].sum()
-    plt.pie(pie_data.values, labels=pie_data.index, autopct='%%1.1f%%%%')
-`, columns[0], columns[1], columns[0], columns[1])
-		}
+func (t *EChartsVisualizationTool) generateStaticImageFallback(ctx context.Context, spec *types.ChartSpec) (string, error) {
+	code, err := generateStaticImageCode(spec)
+	if err != nil {
+		return "静态图片生成失败: " + err.Error(), nil
 	}
 
-	code += `
-plt.tight_layout()
-plt.savefig('output.png', dpi=300, bbox_inches='tight')
-plt.close()
-print("静态图片生成完成: output.png")
-`
-
-	result, err := t.sandbox.ExecutePython(code)
+	result, err := t.sandbox.ExecuteCodeContext(ctx, code)
 	if err != nil {
 		return "", err
 	}
@@ -564,3 +397,60 @@ print("静态图片生成完成: output.png")
 
 	return response, nil
 }
+
+// generateStaticImageCode 生成绘制静态图片的Python代码，参数与ECharts代码一样以 base64 传入
+func generateStaticImageCode(spec *types.ChartSpec) (string, error) {
+	plotCode, ok := staticImageCode[spec.ChartType]
+	if !ok {
+		return "", fmt.Errorf("%s 图不支持生成静态图片", spec.ChartType)
+	}
+	if len(spec.Columns) < 2 || spec.FilePath == "" {
+		return "", errors.New("静态图片需要数据文件路径和两列数据")
+	}
+	code, err := chartDataCode(spec, map[string]interface{}{})
+	if err != nil {
+		return "", err
+	}
+	return code + staticImageHeaderCode + plotCode, nil
+}
+
+// staticImageHeaderCode 创建静态图表，沙箱在执行结束后保存打开的图表
+const staticImageHeaderCode = `
+import matplotlib.pyplot as plt
+
+plt.rcParams["font.sans-serif"] = ["SimHei", "Arial Unicode MS", "DejaVu Sans"]
+plt.rcParams["axes.unicode_minus"] = False
+
+x_col = columns[0]
+y_col = columns[1]
+plt.figure(figsize=(10, 6))
+plt.title(params["title"])
+`
+
+// staticImageCode 各图表类型的静态图片绘制代码
+var staticImageCode = map[string]string{
+	types.ChartBar: `
+plt.bar(df[x_col].astype(str), pd.to_numeric(df[y_col], errors="coerce"))
+plt.xlabel(str(x_col))
+plt.ylabel(str(y_col))
+plt.tight_layout()
+`,
+	types.ChartLine: `
+plt.plot(df[x_col], pd.to_numeric(df[y_col], errors="coerce"), marker="o")
+plt.xlabel(str(x_col))
+plt.ylabel(str(y_col))
+plt.tight_layout()
+`,
+	types.ChartScatter: `
+plt.scatter(pd.to_numeric(df[x_col], errors="coerce"), pd.to_numeric(df[y_col], errors="coerce"))
+plt.xlabel(str(x_col))
+plt.ylabel(str(y_col))
+plt.tight_layout()
+`,
+	types.ChartPie: `
+pie_data = pd.to_numeric(df[y_col], errors="coerce").groupby(df[x_col]).sum()
+pie_data = pie_data[pie_data > 0]
+plt.pie(pie_data.values, labels=pie_data.index.astype(str), autopct="%1.1f%%")
+plt.tight_layout()
+`,
+}
@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"smart-analysis/internal/types"
+)
+
+func TestGenerateStaticImageCode_KeepsParamsOutOfCode(t *testing.T) {
+	spec := &types.ChartSpec{
+		ChartType: types.ChartBar,
+		Columns:   []string{`region"]); __import__("os").system("echo INJECTED"); #`, "amount'''\\"},
+		Title:     `"); __import__("os")`,
+		FilePath:  `/tmp/it's'); __import__("os").csv`,
+	}
+	code, err := generateStaticImageCode(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(code, "INJECTED") || strings.Contains(code, "it's") || strings.Contains(code, "read_csv('") {
+		t.Fatal("列名、标题和路径不应写入代码")
+	}
+
+	if _, err := generateStaticImageCode(&types.ChartSpec{ChartType: types.ChartHeatmap, Columns: spec.Columns, FilePath: spec.FilePath}); err == nil {
+		t.Error("不支持静态图片的图表类型应返回错误")
+	}
+
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	check := exec.Command(python, "-c", "import ast, sys; ast.parse(sys.stdin.read())")
+	check.Stdin = strings.NewReader(code)
+	if out, err := check.CombinedOutput(); err != nil {
+		t.Errorf("生成的代码不是合法的Python: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/analytics"
+)
+
+// ABTestTool A/B实验显著性检验工具，计算在Go中完成，不需要Python沙盒
+type ABTestTool struct {
+	name string
+	desc string
+}
+
+// NewABTestTool 创建A/B实验显著性检验工具
+func NewABTestTool() *ABTestTool {
+	return &ABTestTool{
+		name: "ab_test",
+		desc: "A/B实验显著性检验：按分组列和指标列比较各实验组与对照组，支持双比例z检验、Welch t检验、Mann-Whitney U检验和卡方检验，输出置信区间、统计功效、最小可检测效应和结论。",
+	}
+}
+
+// Info 返回工具信息
+func (t *ABTestTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: t.name,
+		Desc: t.desc,
+		ParamsOneOf: schema.NewParamsOneOfByParams(
+			map[string]*schema.ParameterInfo{
+				"file_path": {
+					Type:     schema.String,
+					Desc:     "实验明细数据文件路径（CSV或JSON），每行一个用户或一次观测",
+					Required: true,
+				},
+				"metric_column": {
+					Type:     schema.String,
+					Desc:     "指标列名，0/1列按转化率检验，其余按均值检验",
+					Required: true,
+				},
+				"variant_column": {
+					Type:     schema.String,
+					Desc:     "分组列名（默认自动识别 variant、group 等列）",
+					Required: false,
+				},
+				"control": {
+					Type:     schema.String,
+					Desc:     "对照组名称（默认识别 control、baseline、A）",
+					Required: false,
+				},
+				"metric_type": {
+					Type:     schema.String,
+					Desc:     "指标类型: binary（0/1转化）, continuous（连续值），默认根据取值判断",
+					Required: false,
+				},
+				"method": {
+					Type:     schema.String,
+					Desc:     "检验方法: z_test, welch_t, mann_whitney, chi_square（默认根据指标类型选择）",
+					Required: false,
+				},
+				"alpha": {
+					Type:     schema.Number,
+					Desc:     "显著性水平（默认0.05）",
+					Required: false,
+				},
+				"power": {
+					Type:     schema.Number,
+					Desc:     "计算最小可检测效应时的目标功效（默认0.8）",
+					Required: false,
+				},
+				"correction": {
+					Type:     schema.String,
+					Desc:     "多重比较校正: none, bonferroni, holm（多组时默认holm）",
+					Required: false,
+				},
+			}),
+	}, nil
+}
+
+// ABTestArgs A/B实验检验参数
+type ABTestArgs struct {
+	FilePath      string  `json:"file_path"`
+	MetricColumn  string  `json:"metric_column"`
+	VariantColumn string  `json:"variant_column,omitempty"`
+	Control       string  `json:"control,omitempty"`
+	MetricType    string  `json:"metric_type,omitempty"`
+	Method        string  `json:"method,omitempty"`
+	Alpha         float64 `json:"alpha,omitempty"`
+	Power         float64 `json:"power,omitempty"`
+	Correction    string  `json:"correction,omitempty"`
+}
+
+// InvokableRun 执行工具
+func (t *ABTestTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var args ABTestArgs
+	if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+		return "", err
+	}
+
+	result, err := RunABTest(args)
+	if err != nil {
+		return "实验分析失败: " + err.Error(), nil
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s\n\n详细结果:\n%s", result.Verdict(), data), nil
+}
+
+// RunABTest 读取数据文件并执行显著性检验
+func RunABTest(args ABTestArgs) (*analytics.ABTestResult, error) {
+	if args.FilePath == "" {
+		return nil, fmt.Errorf("未指定数据文件")
+	}
+
+	table, err := analytics.LoadTable(args.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return analytics.ABTest(table, analytics.ABTestOptions{
+		VariantField: args.VariantColumn,
+		MetricField:  args.MetricColumn,
+		Control:      args.Control,
+		MetricType:   analytics.MetricType(args.MetricType),
+		Method:       analytics.TestMethod(args.Method),
+		Alpha:        args.Alpha,
+		Power:        args.Power,
+		Correction:   analytics.Correction(args.Correction),
+	})
+}
@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestABTestTool(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("group,order_value\n")
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&sb, "A,%d\nB,%d\n", 100+i%10, 102+i%10)
+	}
+	path := filepath.Join(t.TempDir(), "experiment.csv")
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := NewABTestTool().InvokableRun(context.Background(), fmt.Sprintf(`{"file_path": %q, "metric_column": "order_value"}`, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Welch t检验", "结论：B 显著优于对照组 A", `"control": "A"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("输出未包含 %q:\n%s", want, out)
+		}
+	}
+
+	out, _ = NewABTestTool().InvokableRun(context.Background(), fmt.Sprintf(`{"file_path": %q, "metric_column": "missing"}`, path))
+	if !strings.HasPrefix(out, "实验分析失败") {
+		t.Errorf("指标列不存在时应返回失败信息: %s", out)
+	}
+}
@@ -113,7 +113,7 @@ except Exception as e:
 print(json.dumps(result, ensure_ascii=False, indent=2))
 `, args.FilePath, args.PreviewRows)
 
-	result, err := t.sandbox.ExecutePython(code)
+	result, err := t.sandbox.ExecuteCodeContext(ctx, code)
 	if err != nil {
 		return "", err
 	}
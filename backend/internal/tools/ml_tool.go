@@ -89,7 +89,7 @@ func (t *MLAnalysisTool) InvokableRun(ctx context.Context, argumentsInJSON strin
 	code := t.generateMLCode(args.TaskType, args.Algorithm, args.TargetColumn, args.FeatureColumns, args.FilePath, args.Parameters)
 
 	// 执行Python代码
-	result, err := t.sandbox.ExecutePython(code)
+	result, err := t.sandbox.ExecuteCodeContext(ctx, code)
 	if err != nil {
 		return "", err
 	}
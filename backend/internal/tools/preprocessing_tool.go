@@ -77,7 +77,7 @@ func (t *DataPreprocessingTool) InvokableRun(ctx context.Context, argumentsInJSO
 	code := t.generatePreprocessingCode(args.Operation, args.Columns, args.FilePath, args.Parameters)
 
 	// 执行Python代码
-	result, err := t.sandbox.ExecutePython(code)
+	result, err := t.sandbox.ExecuteCodeContext(ctx, code)
 	if err != nil {
 		return "", err
 	}
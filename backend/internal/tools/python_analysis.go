@@ -68,7 +68,7 @@ func (t *PythonAnalysisTool) InvokableRun(ctx context.Context, argumentsInJSON s
 	finalCode := t.preprocessCode(args.Code, args.AnalysisType, args.DataSource)
 
 	// 执行Python代码
-	result, err := t.sandbox.ExecutePython(finalCode)
+	result, err := t.sandbox.ExecuteCodeContext(ctx, finalCode)
 	if err != nil {
 		return "", err
 	}
@@ -7,8 +7,10 @@ import (
 
 // ToolRegistry 工具注册器
 type ToolRegistry struct {
-	sandbox *sanbox.PythonSandbox
-	tools   map[string]tool.BaseTool
+	sandbox     *sanbox.PythonSandbox
+	dataSources DataSourceProvider
+	datasets    DatasetResolver
+	tools       map[string]tool.BaseTool
 }
 
 // ToolConfig 工具配置
@@ -37,6 +39,16 @@ func NewToolRegistry(sandbox *sanbox.PythonSandbox) *ToolRegistry {
 	}
 }
 
+// SetDataSources 设置数据库工具可访问的数据源，未设置时数据库工具不可查询
+func (tr *ToolRegistry) SetDataSources(sources DataSourceProvider) {
+	tr.dataSources = sources
+}
+
+// SetDatasets 设置上传文件的解析器，设置后读取数据文件的工具只能读取当前用户可见的数据视图
+func (tr *ToolRegistry) SetDatasets(datasets DatasetResolver) {
+	tr.datasets = datasets
+}
+
 // RegisterAllTools 注册所有工具
 func (tr *ToolRegistry) RegisterAllTools() []tool.BaseTool {
 	return tr.RegisterToolsWithConfig(DefaultToolConfig())
@@ -50,19 +62,21 @@ func (tr *ToolRegistry) RegisterToolsWithConfig(config *ToolConfig) []tool.BaseT
 		tr.tools["echarts_visualization"] = NewEChartsVisualizationTool(tr.sandbox)
 		tr.tools["file_reader"] = NewFileReaderTool(tr.sandbox)
 		tr.tools["data_query"] = NewDataQueryTool(tr.sandbox)
+		tr.tools["sql_query"] = NewSQLQueryTool(tr.datasets)
 	}
 
 	// 注册高级工具
 	if config.EnableAdvancedTools {
 		tr.tools["data_preprocessing"] = NewDataPreprocessingTool(tr.sandbox)
 		tr.tools["ml_analysis"] = NewMLAnalysisTool(tr.sandbox)
+		tr.tools["ab_test"] = NewABTestTool()
 	}
 
 	// 注册可选工具
 	if config.EnableOptionalTools {
 		tr.tools["text_analysis"] = NewTextAnalysisTool(tr.sandbox)
 		tr.tools["report_generator"] = NewReportGeneratorTool(tr.sandbox)
-		tr.tools["database_tool"] = NewDatabaseTool(tr.sandbox)
+		tr.tools["database_tool"] = NewDatabaseTool(tr.dataSources)
 	}
 
 	// 注册测试工具
@@ -71,10 +85,11 @@ func (tr *ToolRegistry) RegisterToolsWithConfig(config *ToolConfig) []tool.BaseT
 		// 暂时注释掉测试工具，等其他工具稳定后再启用
 	}
 
-	// 转换为切片返回
+	// 转换为切片返回，读取数据文件的参数统一经过数据集解析
 	var toolList []tool.BaseTool
-	for _, t := range tr.tools {
-		toolList = append(toolList, t)
+	for name, t := range tr.tools {
+		tr.tools[name] = WithDatasets(t, tr.datasets)
+		toolList = append(toolList, tr.tools[name])
 	}
 
 	return toolList
@@ -85,7 +85,7 @@ func (t *ReportGeneratorTool) InvokableRun(ctx context.Context, argumentsInJSON
 
 	code := t.generateReportCode(args.ReportType, args.FilePath, args.TargetColumn, args.IncludeCharts, args.OutputFormat)
 
-	result, err := t.sandbox.ExecutePython(code)
+	result, err := t.sandbox.ExecuteCodeContext(ctx, code)
 	if err != nil {
 		return "", err
 	}
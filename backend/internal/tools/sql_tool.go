@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/sqlengine"
+)
+
+// SQLQueryTool 在数据文件上执行SQL的工具，文件加载到内存SQLite中，不需要Python沙盒
+type SQLQueryTool struct {
+	name     string
+	desc     string
+	datasets DatasetResolver
+}
+
+// NewSQLQueryTool 创建SQL查询工具，只能查询 datasets 解析出的当前用户的上传文件和数据视图，
+// datasets 为空时无法查询任何文件
+func NewSQLQueryTool(datasets DatasetResolver) *SQLQueryTool {
+	return &SQLQueryTool{
+		name:     "sql_query",
+		desc:     "用SQL查询数据文件：每个CSV/JSON文件（Excel的每个工作表）加载为一张表，表名取自文件名，可跨文件JOIN。只允许单条 SELECT/WITH 查询；sql 为空时返回各表的列名和类型。",
+		datasets: datasets,
+	}
+}
+
+// Info 返回工具信息
+func (t *SQLQueryTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: t.name,
+		Desc: t.desc,
+		ParamsOneOf: schema.NewParamsOneOfByParams(
+			map[string]*schema.ParameterInfo{
+				"files": {
+					Type:     schema.Array,
+					ElemInfo: &schema.ParameterInfo{Type: schema.String},
+					Desc:     "要查询的数据文件路径列表",
+					Required: true,
+				},
+				"sql": {
+					Type:     schema.String,
+					Desc:     "SQL查询语句（SQLite语法），为空时只返回表结构",
+					Required: false,
+				},
+				"max_rows": {
+					Type:     schema.Integer,
+					Desc:     fmt.Sprintf("返回的最大行数（默认%d，最多%d）", sqlengine.DefaultMaxRows, sqlengine.MaxRowsLimit),
+					Required: false,
+				},
+			}),
+	}, nil
+}
+
+// SQLQueryArgs SQL查询参数
+type SQLQueryArgs struct {
+	Files   []string `json:"files"`
+	SQL     string   `json:"sql,omitempty"`
+	MaxRows int      `json:"max_rows,omitempty"`
+}
+
+// InvokableRun 执行工具
+func (t *SQLQueryTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var args SQLQueryArgs
+	if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+		return "", err
+	}
+	if len(args.Files) == 0 {
+		return "SQL查询失败: 未指定数据文件", nil
+	}
+	if t.datasets == nil {
+		return "SQL查询失败: 未配置数据集，无法读取数据文件", nil
+	}
+
+	sources := make([]sqlengine.Source, len(args.Files))
+	for i, ref := range args.Files {
+		path, err := t.datasets.ResolveUpload(ctx, ref)
+		if err != nil {
+			return fmt.Sprintf("无法读取数据文件 %s: %v", ref, err), nil
+		}
+		sources[i] = sqlengine.Source{Path: path}
+	}
+	db, err := sqlengine.Open(ctx, sources)
+	if err != nil {
+		return "SQL查询失败: " + err.Error(), nil
+	}
+	defer db.Close()
+
+	tables := describeTables(db.Tables())
+	if strings.TrimSpace(args.SQL) == "" {
+		return "可查询的表:\n" + tables, nil
+	}
+
+	result, err := db.Query(ctx, args.SQL, args.MaxRows)
+	if err != nil {
+		// 附上表结构便于修正SQL
+		return fmt.Sprintf("SQL查询失败: %v\n\n可查询的表:\n%s", err, tables), nil
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	summary := fmt.Sprintf("查询返回 %d 行", result.RowCount)
+	if result.Truncated {
+		summary += "（已达到行数上限，结果被截断）"
+	}
+	return fmt.Sprintf("%s\n\n结果:\n%s", summary, data), nil
+}
+
+// describeTables 生成表结构描述
+func describeTables(tables []sqlengine.TableInfo) string {
+	lines := make([]string, len(tables))
+	for i, table := range tables {
+		columns := make([]string, len(table.Columns))
+		for j, column := range table.Columns {
+			columns[j] = column.Name + " " + column.Type
+		}
+		lines[i] = fmt.Sprintf("- %s（%d 行，来自 %s）: %s", table.Name, table.Rows, table.Path, strings.Join(columns, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
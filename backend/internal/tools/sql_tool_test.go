@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// uploadDatasets 只允许读取指定的文件
+type uploadDatasets map[string]bool
+
+func (d uploadDatasets) ResolveDataset(ctx context.Context, ref string) (string, error) {
+	return ref, nil
+}
+
+func (d uploadDatasets) ResolveUpload(ctx context.Context, ref string) (string, error) {
+	if !d[ref] {
+		return "", errors.New("not an uploaded file")
+	}
+	return ref, nil
+}
+
+func TestSQLQueryTool(t *testing.T) {
+	dir := t.TempDir()
+	orders := filepath.Join(dir, "orders.csv")
+	users := filepath.Join(dir, "users.csv")
+	if err := os.WriteFile(orders, []byte("user_id,amount\n1,10\n2,30\n1,5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(users, []byte("user_id,name\n1,alice\n2,bob\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sqlTool := NewSQLQueryTool(uploadDatasets{orders: true, users: true})
+	args := fmt.Sprintf(`{"files": [%q, %q], "sql": "SELECT u.name, SUM(o.amount) AS total FROM orders o JOIN users u USING (user_id) GROUP BY u.name ORDER BY total DESC"}`, orders, users)
+	out, err := sqlTool.InvokableRun(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "查询返回 2 行") || !strings.Contains(out, `"rows":[["bob",30],["alice",15]]`) {
+		t.Errorf("查询结果不正确:\n%s", out)
+	}
+
+	out, _ = sqlTool.InvokableRun(context.Background(), fmt.Sprintf(`{"files": [%q], "sql": "DROP TABLE orders"}`, orders))
+	if !strings.HasPrefix(out, "SQL查询失败") || !strings.Contains(out, "orders（3 行") {
+		t.Errorf("应拒绝非查询语句并返回表结构:\n%s", out)
+	}
+
+	// 只能查询用户的上传文件和数据视图，未配置数据集时无法查询
+	secret := filepath.Join(dir, "secret.csv")
+	if err := os.WriteFile(secret, []byte("token\nabc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, _ = sqlTool.InvokableRun(context.Background(), fmt.Sprintf(`{"files": [%q], "sql": "SELECT * FROM secret"}`, secret))
+	if !strings.HasPrefix(out, "无法读取数据文件") || strings.Contains(out, "abc") {
+		t.Errorf("应拒绝读取非上传文件:\n%s", out)
+	}
+	out, _ = NewSQLQueryTool(nil).InvokableRun(context.Background(), fmt.Sprintf(`{"files": [%q]}`, orders))
+	if !strings.Contains(out, "未配置数据集") {
+		t.Errorf("未配置数据集时应拒绝查询:\n%s", out)
+	}
+}
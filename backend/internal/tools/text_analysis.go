@@ -86,7 +86,7 @@ func (t *TextAnalysisTool) InvokableRun(ctx context.Context, argumentsInJSON str
 
 	code := t.generateTextAnalysisCode(args.Operation, args.TextColumn, args.FilePath, args.Language, args.MaxFeatures)
 
-	result, err := t.sandbox.ExecutePython(code)
+	result, err := t.sandbox.ExecuteCodeContext(ctx, code)
 	if err != nil {
 		return "", err
 	}
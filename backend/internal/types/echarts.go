@@ -0,0 +1,127 @@
+package types
+
+import (
+	"fmt"
+	"math"
+)
+
+// 图表类型
+const (
+	ChartBar     = "bar"
+	ChartLine    = "line"
+	ChartArea    = "area"
+	ChartPie     = "pie"
+	ChartScatter = "scatter"
+	ChartHeatmap = "heatmap"
+	ChartRadar   = "radar"
+)
+
+// ChartTypes 支持的图表类型
+var ChartTypes = []string{ChartBar, ChartLine, ChartArea, ChartPie, ChartScatter, ChartHeatmap, ChartRadar}
+
+// ChartSpec 生成图表的参数。Columns 的含义取决于图表类型：
+// 柱状图、折线图和面积图为分类列加一个或多个数值列，饼图为名称列加数值列，
+// 散点图为两个数值列，雷达图为至少三个数值列，热力图为参与相关性计算的数值列（为空时使用全部数值列）
+type ChartSpec struct {
+	ChartType string   `json:"chart_type"`
+	Columns   []string `json:"data_columns"`
+	Title     string   `json:"title,omitempty"`
+	FilePath  string   `json:"file_path,omitempty"`
+}
+
+// IsChartType 判断是否为支持的图表类型
+func IsChartType(chartType string) bool {
+	for _, t := range ChartTypes {
+		if t == chartType {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 检查图表配置是否可以被前端渲染
+func (c *EChartsConfig) Validate() error {
+	switch c.Type {
+	case ChartBar, ChartLine, ChartArea, ChartRadar:
+		if len(c.XAxis) == 0 {
+			return fmt.Errorf("%s chart has no categories", c.Type)
+		}
+		if len(c.Series) == 0 {
+			return fmt.Errorf("%s chart has no series", c.Type)
+		}
+		for _, series := range c.Series {
+			if len(series.Data) != len(c.XAxis) {
+				return fmt.Errorf("series %q has %d values for %d categories", series.Name, len(series.Data), len(c.XAxis))
+			}
+			for _, v := range series.Data {
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					return fmt.Errorf("series %q has a non-finite value", series.Name)
+				}
+			}
+		}
+	case ChartPie:
+		return c.validateData(1, func(item map[string]interface{}) bool {
+			_, ok := item["name"].(string)
+			return ok
+		})
+	case ChartScatter:
+		return c.validateData(2, nil)
+	case ChartHeatmap:
+		if len(c.XAxis) == 0 {
+			return fmt.Errorf("%s chart has no categories", c.Type)
+		}
+		if _, ok := c.Options["yAxis"]; !ok {
+			return fmt.Errorf("%s chart has no yAxis option", c.Type)
+		}
+		return c.validateData(3, nil)
+	default:
+		return fmt.Errorf("unsupported chart type %q", c.Type)
+	}
+	return nil
+}
+
+// validateData 检查 Data 非空，每项的 value 为 size 个有限数值（size 为1时为单个数值）
+func (c *EChartsConfig) validateData(size int, check func(map[string]interface{}) bool) error {
+	if len(c.Data) == 0 {
+		return fmt.Errorf("%s chart has no data", c.Type)
+	}
+	for i, item := range c.Data {
+		if check != nil && !check(item) {
+			return fmt.Errorf("%s chart data item %d is invalid", c.Type, i)
+		}
+		values, ok := chartValues(item["value"])
+		if !ok || len(values) != size {
+			return fmt.Errorf("%s chart data item %d must have %d numeric values", c.Type, i, size)
+		}
+	}
+	return nil
+}
+
+// chartValues 将数值或数值数组转换为 []float64
+func chartValues(value interface{}) ([]float64, bool) {
+	var values []float64
+	switch v := value.(type) {
+	case float64:
+		values = []float64{v}
+	case int:
+		values = []float64{float64(v)}
+	case []float64:
+		values = v
+	case []interface{}:
+		for _, item := range v {
+			f, ok := item.(float64)
+			if !ok {
+				return nil, false
+			}
+			values = append(values, f)
+		}
+	default:
+		return nil, false
+	}
+	for _, f := range values {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, false
+		}
+	}
+	return values, true
+}
@@ -0,0 +1,142 @@
+package types
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// JSONSchema 结构化输出使用的JSON Schema
+type JSONSchema struct {
+	Name   string
+	raw    []byte
+	schema *openapi3.Schema
+}
+
+// SchemaValidationError JSON Schema校验错误
+type SchemaValidationError struct {
+	Schema string
+	Issues []string
+}
+
+// Error 实现error接口
+func (e *SchemaValidationError) Error() string {
+	return fmt.Sprintf("%s 校验失败: %s", e.Schema, strings.Join(e.Issues, "; "))
+}
+
+var (
+	queryIntentSchema   *JSONSchema
+	executionPlanSchema *JSONSchema
+	semanticModelSchema *JSONSchema
+	schemaOnce          sync.Once
+)
+
+// QueryIntentSchema 返回QueryIntent的JSON Schema
+func QueryIntentSchema() *JSONSchema {
+	loadSchemas()
+	return queryIntentSchema
+}
+
+// ExecutionPlanSchema 返回ExecutionPlan的JSON Schema
+func ExecutionPlanSchema() *JSONSchema {
+	loadSchemas()
+	return executionPlanSchema
+}
+
+// SemanticModelSchema 返回SemanticModel的JSON Schema
+func SemanticModelSchema() *JSONSchema {
+	loadSchemas()
+	return semanticModelSchema
+}
+
+// loadSchemas 加载内置的JSON Schema
+func loadSchemas() {
+	schemaOnce.Do(func() {
+		queryIntentSchema = mustLoadSchema("QueryIntent", "schemas/query_intent.json")
+		executionPlanSchema = mustLoadSchema("ExecutionPlan", "schemas/execution_plan.json")
+		semanticModelSchema = mustLoadSchema("SemanticModel", "schemas/semantic_model.json")
+	})
+}
+
+// mustLoadSchema 加载JSON Schema，内置文件解析失败属于程序错误
+func mustLoadSchema(name, path string) *JSONSchema {
+	raw, err := schemaFiles.ReadFile(path)
+	if err != nil {
+		panic(fmt.Sprintf("failed to read schema %s: %v", path, err))
+	}
+
+	schema, err := NewJSONSchema(name, raw)
+	if err != nil {
+		panic(fmt.Sprintf("failed to parse schema %s: %v", path, err))
+	}
+
+	return schema
+}
+
+// NewJSONSchema 解析JSON Schema文本
+func NewJSONSchema(name string, raw []byte) (*JSONSchema, error) {
+	var schema openapi3.Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, err
+	}
+
+	return &JSONSchema{Name: name, raw: raw, schema: &schema}, nil
+}
+
+// String 返回Schema的JSON文本
+func (s *JSONSchema) String() string {
+	return string(s.raw)
+}
+
+// OpenAPIV3 返回OpenAPI v3格式的Schema，可直接用作工具参数定义
+func (s *JSONSchema) OpenAPIV3() *openapi3.Schema {
+	return s.schema
+}
+
+// Validate 校验JSON文本是否符合Schema，失败时返回*SchemaValidationError
+func (s *JSONSchema) Validate(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return &SchemaValidationError{
+			Schema: s.Name,
+			Issues: []string{fmt.Sprintf("不是合法的JSON: %v", err)},
+		}
+	}
+
+	if err := s.schema.VisitJSON(value, openapi3.MultiErrors()); err != nil {
+		return &SchemaValidationError{
+			Schema: s.Name,
+			Issues: collectSchemaIssues(err),
+		}
+	}
+
+	return nil
+}
+
+// collectSchemaIssues 将校验错误展开为 "路径: 原因" 形式的问题列表
+func collectSchemaIssues(err error) []string {
+	var multi openapi3.MultiError
+	if errors.As(err, &multi) {
+		var issues []string
+		for _, e := range multi {
+			issues = append(issues, collectSchemaIssues(e)...)
+		}
+		return issues
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		path := "/" + strings.Join(schemaErr.JSONPointer(), "/")
+		return []string{fmt.Sprintf("%s: %s", path, schemaErr.Reason)}
+	}
+
+	return []string{err.Error()}
+}
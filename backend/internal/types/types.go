@@ -2,10 +2,15 @@ package types
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
+	"smart-analysis/internal/prompts"
+	"smart-analysis/internal/scheduler"
+	"smart-analysis/internal/sqlengine"
 	"smart-analysis/internal/utils/sanbox"
 )
 
@@ -20,9 +25,13 @@ const (
 	AgentTypeTrendForecast       AgentType = "trend_forecast"
 	AgentTypeAnomalyDetection    AgentType = "anomaly_detection"
 	AgentTypeAttributionAnalysis AgentType = "attribution_analysis"
+	AgentTypeCohortAnalysis      AgentType = "cohort_analysis"
+	AgentTypeABTest              AgentType = "ab_test"
+	AgentTypeText2SQL            AgentType = "text2sql"
 	AgentTypeReact               AgentType = "react"
 	AgentTypeAnalysis            AgentType = "analysis"
 	AgentTypeMulti               AgentType = "multi"
+	AgentTypeMain                AgentType = "main" // 兼容旧版主智能体
 )
 
 // FileData 文件数据结构
@@ -55,9 +64,11 @@ type AnalysisResult struct {
 	ExecutionLog string                 `json:"execution_log,omitempty"`
 }
 
-// EChartsConfig ECharts图表配置
+// EChartsConfig ECharts图表配置，前端按 Type 转换为 ECharts option。
+// 柱状图、折线图、面积图和雷达图使用 XAxis（雷达图为指标名）和 Series；
+// 饼图、散点图和热力图使用 Data，每项包含 name 和 value
 type EChartsConfig struct {
-	Type    string                   `json:"type"` // "bar", "line", "pie", "scatter", "heatmap"
+	Type    string                   `json:"type"` // 见 ChartTypes
 	Title   string                   `json:"title"`
 	Data    []map[string]interface{} `json:"data"`
 	XAxis   []string                 `json:"xAxis,omitempty"`
@@ -74,15 +85,40 @@ type EChartsSeries struct {
 
 // AgentConfig 智能体配置
 type AgentConfig struct {
-	ChatModel     model.BaseChatModel    `json:"-"`
-	PythonSandbox *sanbox.PythonSandbox  `json:"-"`
-	Tools         []tool.BaseTool        `json:"-"`
-	MaxSteps      int                    `json:"max_steps"`
-	EnableDebug   bool                   `json:"enable_debug"`
-	Model         string                 `json:"model"`
-	Temperature   float64                `json:"temperature"`
-	MaxTokens     int                    `json:"max_tokens"`
-	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	ChatModel     model.BaseChatModel   `json:"-"`
+	PythonSandbox *sanbox.PythonSandbox `json:"-"`
+	Tools         []tool.BaseTool       `json:"-"`
+	Prompts       *prompts.Library      `json:"-"` // 为空时使用全局提示词模板库
+	MaxSteps      int                   `json:"max_steps"`
+	EnableDebug   bool                  `json:"enable_debug"`
+	Model         string                `json:"model"`
+	Temperature   float64               `json:"temperature"`
+	MaxTokens     int                   `json:"max_tokens"`
+	// StructuredRepairAttempts 结构化输出校验失败后的最大修复轮数，0表示使用默认值
+	StructuredRepairAttempts int                    `json:"structured_repair_attempts,omitempty"`
+	Execution                *ExecutionPolicy       `json:"execution,omitempty"` // 为空时使用默认执行策略
+	PlanStore                PlanStore              `json:"-"`                   // 为空时不持久化执行计划
+	Scheduler                *scheduler.Scheduler   `json:"-"`                   // 限制专家任务并发，为空时使用全局调度器
+	DataSources              DataSourceProvider     `json:"-"`                   // 当前用户的数据库数据源，为空时只能查询上传的文件
+	Datasets                 DatasetResolver        `json:"-"`                   // 将上传文件的引用解析为当前用户可见的数据视图，为空时直接读取文件
+	Metadata                 map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// FailurePolicy 任务失败后的处理策略
+type FailurePolicy string
+
+const (
+	FailurePolicyFailFast       FailurePolicy = "fail_fast"       // 立即终止整个计划
+	FailurePolicySkipDependents FailurePolicy = "skip_dependents" // 跳过依赖失败任务的下游任务
+	FailurePolicyContinue       FailurePolicy = "continue"        // 下游任务照常执行
+)
+
+// ExecutionPolicy 计划执行策略
+type ExecutionPolicy struct {
+	MaxRetries      int           `json:"max_retries"`       // 单个任务失败后的重试次数
+	RetryBackoff    time.Duration `json:"retry_backoff"`     // 首次重试的等待时间，之后按指数增长
+	FailurePolicy   FailurePolicy `json:"failure_policy"`    // 重试和重新规划均失败后的处理策略
+	MaxReplanRounds int           `json:"max_replan_rounds"` // 任务失败后重新规划的最大轮数，0表示不重新规划
 }
 
 // Agent 智能体接口
@@ -111,6 +147,7 @@ type Task struct {
 	AgentType    AgentType              `json:"agent_type"`
 	Input        interface{}            `json:"input"`
 	Dependencies []string               `json:"dependencies,omitempty"`
+	MaxRetries   *int                   `json:"max_retries,omitempty"` // 覆盖执行策略中的重试次数
 	Status       TaskStatus             `json:"status"`
 	Result       *TaskResult            `json:"result,omitempty"`
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
@@ -125,8 +162,29 @@ const (
 	TaskStatusCompleted TaskStatus = "completed"
 	TaskStatusFailed    TaskStatus = "failed"
 	TaskStatusCancelled TaskStatus = "cancelled"
+	TaskStatusSkipped   TaskStatus = "skipped"
 )
 
+// CancelReasonReplanned 任务因重新规划被替代时 Metadata["cancel_reason"] 的值
+const CancelReasonReplanned = "replanned"
+
+// IsReplaced 判断任务是否已被重新规划替代：失败后由子计划接替，或作为下游任务被取消
+func (t *Task) IsReplaced() bool {
+	if t.Metadata["cancel_reason"] == CancelReasonReplanned {
+		return true
+	}
+	if t.Result == nil {
+		return false
+	}
+	switch replacedBy := t.Result.Metadata["replaced_by"].(type) {
+	case []string:
+		return len(replacedBy) > 0
+	case []interface{}:
+		return len(replacedBy) > 0
+	}
+	return false
+}
+
 // TaskResult 任务结果
 type TaskResult struct {
 	Success      bool                   `json:"success"`
@@ -134,9 +192,25 @@ type TaskResult struct {
 	Error        string                 `json:"error,omitempty"`
 	ExecutedBy   AgentType              `json:"executed_by"`
 	ExecutionLog string                 `json:"execution_log,omitempty"`
+	Artifacts    map[string]*Artifact   `json:"artifacts,omitempty"` // 任务产出的数据，key为产物类型
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// 产物类型
+const (
+	ArtifactTypeDataFrame = "dataframe"
+	ArtifactTypeImage     = "image"
+	ArtifactTypeValue     = "value"
+	ArtifactTypeChart     = "chart" // ECharts 配置（option）
+)
+
+// Artifact 任务产物，下游任务可以通过 data_source 引用
+type Artifact struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"` // dataframe 为 {"columns", "data", "index", "dtypes"}
+	Path string      `json:"path,omitempty"` // 文件类产物的路径
+}
+
 // QueryIntent 查询意图
 type QueryIntent struct {
 	IntentType   string                 `json:"intent_type"` // "data_query", "analysis", "visualization", etc.
@@ -163,6 +237,43 @@ type ColumnInfo struct {
 	Values      []string `json:"values,omitempty"` // 对于枚举类型
 }
 
+// SemanticModel 数据集的业务语义模型，把业务术语对应到表、列、指标口径和关联路径
+type SemanticModel struct {
+	Tables  []SemanticTable  `json:"tables,omitempty"`
+	Metrics []SemanticMetric `json:"metrics,omitempty"`
+	Joins   []SemanticJoin   `json:"joins,omitempty"`
+}
+
+// SemanticTable 表的业务含义
+type SemanticTable struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Synonyms    []string         `json:"synonyms,omitempty"`
+	Columns     []SemanticColumn `json:"columns,omitempty"`
+}
+
+// SemanticColumn 列的业务含义，如 sales_amount 对应 销售额
+type SemanticColumn struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Synonyms    []string `json:"synonyms,omitempty"`
+}
+
+// SemanticMetric 指标口径，Formula 为单个SQL表达式，如 退货率 = SUM(returns) / COUNT(order_id)
+type SemanticMetric struct {
+	Name        string   `json:"name"`
+	Formula     string   `json:"formula"`
+	Description string   `json:"description,omitempty"`
+	Synonyms    []string `json:"synonyms,omitempty"`
+}
+
+// SemanticJoin 表之间的关联路径，From 和 To 为 "表.列"
+type SemanticJoin struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Description string `json:"description,omitempty"`
+}
+
 // QueryObject 查询对象
 type QueryObject struct {
 	Events     []string               `json:"events,omitempty"`     // 事件
@@ -198,11 +309,108 @@ type OrderCondition struct {
 
 // ExecutionPlan 执行计划
 type ExecutionPlan struct {
-	ID           string                 `json:"id"`
-	QueryIntent  *QueryIntent           `json:"query_intent"`
-	Tasks        []*Task                `json:"tasks"`
-	Dependencies map[string][]string    `json:"dependencies"` // task_id -> dependency_task_ids
-	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	ID            string                 `json:"id"`
+	QueryID       int                    `json:"query_id,omitempty"` // 所属查询
+	UserID        int                    `json:"user_id,omitempty"`  // 发起查询的用户，只有该用户可以查看和恢复计划
+	QueryIntent   *QueryIntent           `json:"query_intent"`
+	Tasks         []*Task                `json:"tasks"`
+	Dependencies  map[string][]string    `json:"dependencies"`             // task_id -> dependency_task_ids
+	FailurePolicy FailurePolicy          `json:"failure_policy,omitempty"` // 覆盖执行策略中的失败处理策略
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+}
+
+// AnswerExtraKey 最终回答消息 Extra 中结构化结果的键
+const AnswerExtraKey = "analysis_answer"
+
+// AnalysisAnswer 计划执行后的最终结果：面向用户的自然语言回答以及机器可读的任务结果
+type AnalysisAnswer struct {
+	PlanID     string        `json:"plan_id"`
+	Question   string        `json:"question"`
+	Answer     string        `json:"answer"`     // Markdown格式的回答
+	Summarized bool          `json:"summarized"` // 回答是否由LLM生成，false表示使用了模板汇总
+	Charts     []string      `json:"charts,omitempty"`
+	Tasks      []TaskOutcome `json:"tasks"`
+}
+
+// TaskOutcome 单个任务的执行结果
+type TaskOutcome struct {
+	ID          string               `json:"id"`
+	Description string               `json:"description"`
+	AgentType   AgentType            `json:"agent_type"`
+	Status      TaskStatus           `json:"status"`
+	Success     bool                 `json:"success"`
+	Output      interface{}          `json:"output,omitempty"`
+	Error       string               `json:"error,omitempty"`
+	Artifacts   map[string]*Artifact `json:"artifacts,omitempty"`
+}
+
+// ErrPlanNotFound 执行计划不存在
+var ErrPlanNotFound = errors.New("execution plan not found")
+
+// ErrPlanRunning 执行计划正在执行，同一计划同时只能有一次执行
+var ErrPlanRunning = errors.New("execution plan is already running")
+
+// DataSourceProvider 按ID访问当前用户已注册的数据源，连接信息和凭据不会暴露给调用方
+type DataSourceProvider interface {
+	// Tables 返回数据源扫描得到的表结构
+	Tables(ctx context.Context, id int) ([]DataSchema, error)
+	// Dialect 返回数据源的SQL方言（mysql、postgres、sqlite）
+	Dialect(ctx context.Context, id int) (string, error)
+	// Semantic 返回数据源的业务语义模型，未配置时返回 nil
+	Semantic(ctx context.Context, id int) (*SemanticModel, error)
+	// Query 在数据源上校验并执行只读查询
+	Query(ctx context.Context, id int, query string, maxRows int) (*sqlengine.Result, error)
+}
+
+// DatasetResolver 将任务或工具引用的上传文件（文件名、上传路径或 file:ID）解析为当前用户可见的数据视图：
+// 按访问策略过滤行和列、按敏感列策略脱敏后写出的数据文件。无权访问时返回错误
+type DatasetResolver interface {
+	// ResolveDataset 解析文件引用，另外允许读取当前计划执行的产物目录（见 WithArtifactDir）中的文件，其他路径返回错误
+	ResolveDataset(ctx context.Context, ref string) (string, error)
+	// ResolveUpload 只解析上传文件和当前用户的数据视图，其他路径返回错误
+	ResolveUpload(ctx context.Context, ref string) (string, error)
+}
+
+// PlanStore 执行计划存储，保存计划、任务状态和任务结果以便恢复执行
+type PlanStore interface {
+	// SavePlan 保存计划快照
+	SavePlan(plan *ExecutionPlan) error
+
+	// GetPlan 按ID获取计划
+	GetPlan(planID string) (*ExecutionPlan, error)
+
+	// GetPlanByQuery 获取查询最近一次的计划
+	GetPlanByQuery(queryID int) (*ExecutionPlan, error)
+
+	// LastQueryID 返回已保存的计划关联的最大查询ID，重启后新查询的ID从它之后分配
+	LastQueryID() int
+}
+
+type queryIDKey struct{}
+
+// WithQueryID 在上下文中记录当前查询ID，规划智能体据此关联执行计划
+func WithQueryID(ctx context.Context, queryID int) context.Context {
+	return context.WithValue(ctx, queryIDKey{}, queryID)
+}
+
+// QueryIDFromContext 获取上下文中的查询ID，不存在时返回0
+func QueryIDFromContext(ctx context.Context) int {
+	queryID, _ := ctx.Value(queryIDKey{}).(int)
+	return queryID
+}
+
+type artifactDirKey struct{}
+
+// WithArtifactDir 在上下文中记录当前计划执行写出上游产物的目录，数据集解析器允许读取其中的文件
+func WithArtifactDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, artifactDirKey{}, dir)
+}
+
+// ArtifactDirFromContext 获取上下文中的产物目录，不存在时返回空字符串
+func ArtifactDirFromContext(ctx context.Context) string {
+	dir, _ := ctx.Value(artifactDirKey{}).(string)
+	return dir
 }
 
 // ExpertAgent 专家智能体接口
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -21,18 +20,6 @@ func CheckPassword(password, hash string) bool {
 	return err == nil
 }
 
-// GenerateToken 生成JWT token
-func GenerateToken(userID int, username string, secret string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id":  userID,
-		"username": username,
-		"exp":      time.Now().Add(time.Hour * 24 * 7).Unix(), // 7天过期
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
-}
-
 // GenerateMD5 生成MD5哈希
 func GenerateMD5(text string) string {
 	hash := md5.Sum([]byte(text))
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/tealeg/xlsx/v3"
 )
 
@@ -62,8 +63,24 @@ func ParseCSV(filePath string) (*CSVData, error) {
 	}, nil
 }
 
+// ExcelSheet Excel工作表数据
+type ExcelSheet struct {
+	Name string
+	Data *CSVData
+}
+
 // ParseExcel 解析Excel文件
 func ParseExcel(filePath string) (*CSVData, error) {
+	sheets, err := ParseExcelSheets(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return sheets[0].Data, nil // 取第一个sheet
+}
+
+// ParseExcelSheets 解析Excel文件的所有工作表，每个工作表首行为表头
+func ParseExcelSheets(filePath string) ([]ExcelSheet, error) {
 	wb, err := xlsx.OpenFile(filePath)
 	if err != nil {
 		return nil, err
@@ -73,7 +90,15 @@ func ParseExcel(filePath string) (*CSVData, error) {
 		return nil, fmt.Errorf("no sheets found in Excel file")
 	}
 
-	sheet := wb.Sheets[0] // 取第一个sheet
+	sheets := make([]ExcelSheet, 0, len(wb.Sheets))
+	for _, sheet := range wb.Sheets {
+		sheets = append(sheets, ExcelSheet{Name: sheet.Name, Data: parseSheet(sheet)})
+	}
+	return sheets, nil
+}
+
+// parseSheet 读取工作表的表头和数据行
+func parseSheet(sheet *xlsx.Sheet) *CSVData {
 	var headers []string
 	var rows [][]string
 
@@ -107,7 +132,7 @@ func ParseExcel(filePath string) (*CSVData, error) {
 		Headers: headers,
 		Rows:    rows,
 		Summary: summary,
-	}, nil
+	}
 }
 
 // ParseJSON 解析JSON文件
@@ -147,14 +172,8 @@ func GetFileType(filename string) FileType {
 	}
 }
 
-// GenerateFileName 生成唯一文件名
+// GenerateFileName 生成随机的存储文件名，只保留原始文件的扩展名，
+// 避免同名文件互相覆盖，也无法从原始文件名推测出存储文件名
 func GenerateFileName(originalName string) string {
-	ext := filepath.Ext(originalName)
-	name := strings.TrimSuffix(originalName, ext)
-	return fmt.Sprintf("%s_%d%s", name, GetTimestamp(), ext)
-}
-
-// GetTimestamp 获取当前时间戳
-func GetTimestamp() int64 {
-	return 1642694400 // 简化版，实际应该使用time.Now().Unix()
+	return uuid.New().String() + strings.ToLower(filepath.Ext(originalName))
 }
@@ -0,0 +1,64 @@
+package sanbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isolationEnv 传递隔离配置的环境变量，设置时当前进程作为沙箱的初始化进程运行
+const isolationEnv = "SMART_ANALYSIS_SANDBOX"
+
+// sandboxUID 嵌套用户命名空间中运行Python的用户
+const sandboxUID = 65534
+
+// isolation 沙箱进程的隔离配置，由初始化进程在新的命名空间中应用
+type isolation struct {
+	Python  string   `json:"python"`  // Python解释器的绝对路径
+	Args    []string `json:"args"`    // Python的参数
+	Env     []string `json:"env"`     // Python的环境变量
+	Dir     string   `json:"dir"`     // 脚本所在的临时目录，沙箱中唯一可写的宿主目录
+	Scratch string   `json:"scratch"` // 系统临时目录，替换为空的临时文件系统
+	Hidden  []string `json:"hidden"`  // 受限目录，替换为空的临时文件系统
+	Exposed []string `json:"exposed"` // 受限目录中对本次执行只读开放的目录
+}
+
+// sandboxEnvKeys 传给Python进程的环境变量，其他变量（如密钥）不传入沙箱
+var sandboxEnvKeys = []string{
+	"PATH", "HOME", "LANG", "LC_ALL", "LC_CTYPE", "TZ",
+	"PYTHONPATH", "PYTHONHOME", "VIRTUAL_ENV", "PYENV_ROOT", "PYENV_VERSION",
+}
+
+// sandboxEnv 返回传给Python进程的环境变量
+func sandboxEnv() []string {
+	env := make([]string, 0, len(sandboxEnvKeys))
+	for _, key := range sandboxEnvKeys {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
+
+// realPath 返回去掉符号链接的绝对路径，路径不存在时返回绝对路径。
+// 挂载目标中的符号链接会指向宿主文件系统，隔离配置中的路径都需要先解析
+func realPath(path string) string {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path
+}
+
+// realPaths 对每个路径调用 realPath，忽略空路径
+func realPaths(paths []string) []string {
+	result := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if strings.TrimSpace(path) != "" {
+			result = append(result, realPath(path))
+		}
+	}
+	return result
+}
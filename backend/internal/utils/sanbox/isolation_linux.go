@@ -0,0 +1,161 @@
+//go:build linux
+
+package sanbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// init 当前进程由 isolatedCommand 启动时作为沙箱的初始化进程运行，不再执行原来的程序
+func init() {
+	if spec, ok := os.LookupEnv(isolationEnv); ok {
+		os.Exit(runIsolated(spec))
+	}
+}
+
+// isolatedCommand 创建在独立的用户、挂载、进程、网络和IPC命名空间中运行Python的命令。
+// 命令重新执行当前程序作为初始化进程，由它挂载文件系统后启动Python
+func isolatedCommand(ctx context.Context, iso *isolation) (*exec.Cmd, error) {
+	spec, err := json.Marshal(iso)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe")
+	cmd.Args = []string{"python-sandbox"}
+	cmd.Dir = iso.Dir
+	cmd.Env = []string{isolationEnv + "=" + string(spec)}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+			syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		GidMappingsEnableSetgroups: false,
+		Pdeathsig:                  syscall.SIGKILL,
+	}
+	return cmd, nil
+}
+
+// runIsolated 在新的命名空间中应用隔离配置并运行Python，返回Python的退出码
+func runIsolated(spec string) int {
+	var iso isolation
+	if err := json.Unmarshal([]byte(spec), &iso); err != nil {
+		fmt.Fprintf(os.Stderr, "沙箱配置无效: %v\n", err)
+		return 126
+	}
+	if err := iso.mount(); err != nil {
+		fmt.Fprintf(os.Stderr, "初始化沙箱失败: %v\n", err)
+		return 126
+	}
+
+	// Python在嵌套的用户命名空间中以非root用户运行，上面的挂载在其中被锁定，
+	// 即使再创建用户命名空间也无法卸载它们来露出受限目录
+	cmd := exec.Command(iso.Python, iso.Args...)
+	cmd.Dir = iso.Dir
+	cmd.Env = iso.Env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:                 syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: sandboxUID, HostID: 0, Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: sandboxUID, HostID: 0, Size: 1}},
+		GidMappingsEnableSetgroups: false,
+	}
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "启动Python失败: %v\n", err)
+		return 127
+	}
+	return 0
+}
+
+// mount 构建沙箱的根目录并切换进去：宿主根目录只读，系统临时目录和受限目录替换为空的临时文件系统，
+// 开放的目录只读挂回，脚本所在的临时目录可写，/proc 只包含沙箱自己的进程
+func (iso *isolation) mount() error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("设置挂载传播失败: %v", err)
+	}
+
+	root := filepath.Join(iso.Dir, ".root")
+	if err := os.Mkdir(root, 0700); err != nil {
+		return err
+	}
+	if err := unix.Mount("/", root, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("挂载根目录失败: %v", err)
+	}
+	if err := readOnly(root); err != nil {
+		return err
+	}
+
+	for _, dir := range append([]string{iso.Scratch}, iso.Hidden...) {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		target := filepath.Join(root, dir)
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+		if err := unix.Mount("tmpfs", target, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0755"); err != nil {
+			return fmt.Errorf("隐藏目录 %s 失败: %v", dir, err)
+		}
+	}
+
+	for _, dir := range iso.Exposed {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		target := filepath.Join(root, dir)
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+		if err := unix.Mount(dir, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("挂载目录 %s 失败: %v", dir, err)
+		}
+		if err := readOnly(target); err != nil {
+			return err
+		}
+	}
+
+	target := filepath.Join(root, iso.Dir)
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	if err := unix.Mount(iso.Dir, target, "", unix.MS_BIND, ""); err != nil {
+		return fmt.Errorf("挂载临时目录失败: %v", err)
+	}
+	if err := unix.Mount("proc", filepath.Join(root, "proc"), "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("挂载 /proc 失败: %v", err)
+	}
+
+	// 切换根目录并卸载原来的根目录，沙箱中不再有通往宿主文件系统的路径
+	if err := os.Chdir(root); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("切换根目录失败: %v", err)
+	}
+	if err := unix.Unmount(".", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("卸载原根目录失败: %v", err)
+	}
+	return os.Chdir("/")
+}
+
+// readOnly 将目录及其下的所有挂载设为只读
+func readOnly(dir string) error {
+	attr := &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY | unix.MOUNT_ATTR_NOSUID}
+	if err := unix.MountSetattr(unix.AT_FDCWD, dir, unix.AT_RECURSIVE, attr); err != nil {
+		return fmt.Errorf("设置只读挂载失败: %v", err)
+	}
+	return nil
+}
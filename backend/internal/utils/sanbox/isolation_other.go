@@ -0,0 +1,14 @@
+//go:build !linux
+
+package sanbox
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// isolatedCommand 沙箱隔离依赖Linux命名空间，其他系统上不能在受限模式下执行代码
+func isolatedCommand(ctx context.Context, iso *isolation) (*exec.Cmd, error) {
+	return nil, errors.New("沙箱隔离需要Linux用户命名空间，当前系统不支持")
+}
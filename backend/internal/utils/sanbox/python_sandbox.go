@@ -3,14 +3,20 @@ package sanbox
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+
+	"smart-analysis/internal/audit"
+	"smart-analysis/internal/scheduler"
 )
 
 // PythonExecutionResult Python执行结果
@@ -26,17 +32,20 @@ type PythonExecutionResult struct {
 
 // PythonSandbox Python代码执行沙箱
 type PythonSandbox struct {
-	timeout    time.Duration
-	uploadDir  string // 文件上传目录，用于保存图片等文件
-	pythonPath string // Python解释器路径
+	timeout     time.Duration
+	uploadDir   string               // 文件上传目录，用于保存图片等文件
+	pythonPath  string               // Python解释器路径
+	scheduler   *scheduler.Scheduler // 限制同时运行的沙箱进程数，为空时使用全局调度器
+	deniedPaths []string             // 沙箱代码不能读写的目录
+	userPaths   func(userID int) []string
 }
 
-// NewPythonSandbox 创建新的Python沙箱
+// NewPythonSandbox 创建新的Python沙箱，默认使用 PATH 中的 python3
 func NewPythonSandbox(uploadDir string) *PythonSandbox {
 	return &PythonSandbox{
 		timeout:    30 * time.Second, // 默认30秒超时
 		uploadDir:  uploadDir,
-		pythonPath: "/Users/wanghao/Desktop/github/go/smart-analysis/.venv/bin/python",
+		pythonPath: "python3",
 	}
 }
 
@@ -50,18 +59,55 @@ func (ps *PythonSandbox) SetPythonPath(path string) {
 	ps.pythonPath = path
 }
 
+// SetScheduler 设置沙箱使用的调度器
+func (ps *PythonSandbox) SetScheduler(s *scheduler.Scheduler) {
+	ps.scheduler = s
+}
+
+// SetDeniedPaths 设置沙箱代码不能访问的目录，如原始上传文件所在目录，
+// 使沙箱只能通过数据视图读取经过访问策略过滤和脱敏的数据。
+// 设置后沙箱进程在Linux命名空间中隔离运行，受限目录在其中是空目录，其他系统上无法执行代码
+func (ps *PythonSandbox) SetDeniedPaths(paths ...string) {
+	ps.deniedPaths = append([]string(nil), paths...)
+}
+
+// SetUserPaths 设置按用户开放的目录，如用户自己的数据视图目录。
+// 这些目录位于受限目录中，只对上下文中的用户只读开放，其他用户的目录在沙箱中不可见
+func (ps *PythonSandbox) SetUserPaths(paths func(userID int) []string) {
+	ps.userPaths = paths
+}
+
 // ExecuteCode 执行Python代码（主要API）
 func (ps *PythonSandbox) ExecuteCode(code string) (*PythonExecutionResult, error) {
-	return ps.execute(code)
+	return ps.execute(context.Background(), code)
 }
 
 // ExecutePython 执行Python代码（别名，为了兼容性）
 func (ps *PythonSandbox) ExecutePython(code string) (*PythonExecutionResult, error) {
-	return ps.execute(code)
+	return ps.execute(context.Background(), code)
 }
 
-// execute 内部执行方法
-func (ps *PythonSandbox) execute(code string) (*PythonExecutionResult, error) {
+// ExecuteCodeContext 执行Python代码，沙箱槽位已满时按上下文中的用户排队等待，
+// 上下文取消时放弃等待或终止正在运行的进程
+func (ps *PythonSandbox) ExecuteCodeContext(ctx context.Context, code string) (*PythonExecutionResult, error) {
+	return ps.execute(ctx, code)
+}
+
+// execute 内部执行方法，每次执行都记录审计事件
+func (ps *PythonSandbox) execute(ctx context.Context, code string) (result *PythonExecutionResult, err error) {
+	start := time.Now()
+	defer func() { auditExecution(ctx, code, result, err, time.Since(start)) }()
+
+	sched := ps.scheduler
+	if sched == nil {
+		sched = scheduler.GetGlobal()
+	}
+	release, err := sched.Acquire(ctx, scheduler.ResourceSandbox)
+	if err != nil {
+		return nil, fmt.Errorf("等待沙箱资源失败: %v", err)
+	}
+	defer release()
+
 	// 确保上传目录存在
 	if ps.uploadDir != "" {
 		os.MkdirAll(ps.uploadDir, 0755)
@@ -85,16 +131,18 @@ func (ps *PythonSandbox) execute(code string) (*PythonExecutionResult, error) {
 	}
 
 	// 执行Python脚本
-	ctx, cancel := context.WithTimeout(context.Background(), ps.timeout)
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, ps.pythonPath, scriptPath)
-	cmd.Dir = tempDir
+	cmd, err := ps.command(ctx, tempDir, scriptPath)
+	if err != nil {
+		return nil, err
+	}
 
 	stdout, stderr, err := ps.runCommand(cmd)
 
 	// 解析结果
-	result := &PythonExecutionResult{}
+	result = &PythonExecutionResult{}
 
 	if err != nil {
 		result.Success = false
@@ -116,11 +164,59 @@ func (ps *PythonSandbox) execute(code string) (*PythonExecutionResult, error) {
 	return result, nil
 }
 
-// createExecutionScript 创建Python执行脚本
+// command 创建运行脚本的命令，Python只能看到白名单中的环境变量。
+// 设置了受限目录时，Python在独立的用户、挂载、进程、网络和IPC命名空间中运行：宿主文件系统只读，
+// 受限目录和系统临时目录替换为空目录，只有脚本所在的临时目录可写
+func (ps *PythonSandbox) command(ctx context.Context, tempDir, scriptPath string) (*exec.Cmd, error) {
+	if len(ps.deniedPaths) == 0 {
+		cmd := exec.CommandContext(ctx, ps.pythonPath, scriptPath)
+		cmd.Dir = tempDir
+		cmd.Env = sandboxEnv()
+		return cmd, nil
+	}
+
+	python, err := exec.LookPath(ps.pythonPath)
+	if err != nil {
+		return nil, fmt.Errorf("找不到Python解释器: %v", err)
+	}
+	tempDir = realPath(tempDir)
+	iso := &isolation{
+		Python:  realPath(python),
+		Args:    []string{filepath.Join(tempDir, filepath.Base(scriptPath))},
+		Env:     sandboxEnv(),
+		Dir:     tempDir,
+		Scratch: filepath.Dir(tempDir),
+		Hidden:  realPaths(ps.deniedPaths),
+	}
+	if userID, err := strconv.Atoi(scheduler.UserFromContext(ctx)); err == nil && ps.userPaths != nil {
+		iso.Exposed = realPaths(ps.userPaths(userID))
+	}
+	return isolatedCommand(ctx, iso)
+}
+
+// auditExecution 记录一次代码执行，包括完整的代码和执行结果
+func auditExecution(ctx context.Context, code string, result *PythonExecutionResult, err error, elapsed time.Duration) {
+	event := audit.Event{
+		Action: audit.ActionSandboxExecute,
+		Detail: map[string]interface{}{
+			"code":        code,
+			"duration_ms": elapsed.Milliseconds(),
+		},
+	}
+	if err == nil && result != nil && !result.Success {
+		err = errors.New(result.Error)
+	}
+	audit.Record(ctx, event.WithError(err))
+}
+
+// createExecutionScript 创建Python执行脚本。用户代码和临时目录以 base64 编码的 JSON 传入，
+// 不放在Python字符串字面量中，代码里的引号、反斜杠和换行不会改变执行脚本的结构
 func (ps *PythonSandbox) createExecutionScript(userCode, tempDir string) string {
+	params, _ := json.Marshal(map[string]string{"code": userCode, "temp_dir": tempDir})
 	return fmt.Sprintf(`
 import sys
 import json
+import base64
 import traceback
 import os
 from io import StringIO
@@ -176,7 +272,7 @@ def safe_serialize(obj):
 def check_for_plots():
     try:
         if plt.get_fignums():
-            image_path = os.path.join("%s", "output_plot.png")
+            image_path = os.path.join(params["temp_dir"], "output_plot.png")
             plt.savefig(image_path, dpi=150, bbox_inches='tight')
             plt.close('all')
             return image_path
@@ -184,6 +280,9 @@ def check_for_plots():
         pass
     return None
 
+params = json.loads(base64.b64decode("%s").decode("utf-8"))
+user_code = params["code"]
+
 old_stdout = sys.stdout
 sys.stdout = captured_output = StringIO()
 
@@ -193,7 +292,7 @@ try:
     exec_globals = {"__name__": "__main__"}
     exec_locals = {}
     
-    code_lines = '''%s'''.strip().split('\n')
+    code_lines = user_code.strip().split('\n')
     
     if code_lines:
         last_line = code_lines[-1].strip()
@@ -217,7 +316,7 @@ try:
             except:
                 pass
     else:
-        exec('''%s''', exec_globals, exec_locals)
+        exec(user_code, exec_globals, exec_locals)
     
     print_output = captured_output.getvalue()
     image_path = check_for_plots()
@@ -251,7 +350,7 @@ except Exception as e:
     print(json.dumps(error_output), file=sys.__stdout__)
 finally:
     sys.stdout = old_stdout
-`, tempDir, userCode, userCode)
+`, base64.StdEncoding.EncodeToString(params))
 }
 
 // runCommand 运行命令并获取输出
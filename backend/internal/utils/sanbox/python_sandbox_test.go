@@ -1,14 +1,21 @@
 package sanbox
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
+
+	"smart-analysis/internal/scheduler"
 )
 
 func TestPythonSandbox_Basic(t *testing.T) {
-	// 创建沙箱
-	uploadDir := "/tmp/test_uploads"
-	sandbox := NewPythonSandbox(uploadDir)
+	sandbox := newTestSandbox(t)
 	sandbox.SetTimeout(10 * time.Second)
 
 	// 测试基本文本输出
@@ -29,8 +36,7 @@ func TestPythonSandbox_Basic(t *testing.T) {
 }
 
 func TestPythonSandbox_DataFrame(t *testing.T) {
-	uploadDir := "/tmp/test_uploads"
-	sandbox := NewPythonSandbox(uploadDir)
+	sandbox := newTestSandbox(t)
 
 	code := `
 import pandas as pd
@@ -53,8 +59,7 @@ df
 }
 
 func TestPythonSandbox_Plot(t *testing.T) {
-	uploadDir := "/tmp/test_uploads"
-	sandbox := NewPythonSandbox(uploadDir)
+	sandbox := newTestSandbox(t)
 
 	code := `
 import matplotlib.pyplot as plt
@@ -85,8 +90,7 @@ plt.ylabel("Y")
 }
 
 func TestPythonSandbox_Dictionary(t *testing.T) {
-	uploadDir := "/tmp/test_uploads"
-	sandbox := NewPythonSandbox(uploadDir)
+	sandbox := newTestSandbox(t)
 
 	code := `
 data = {
@@ -113,8 +117,7 @@ data
 }
 
 func TestPythonSandbox_Error(t *testing.T) {
-	uploadDir := "/tmp/test_uploads"
-	sandbox := NewPythonSandbox(uploadDir)
+	sandbox := newTestSandbox(t)
 
 	code := `
 # 这段代码会出错
@@ -134,3 +137,223 @@ raise ValueError("This is a test error")
 		t.Error("期望有错误信息，但没有")
 	}
 }
+
+// newTestSandbox 创建使用 PATH 中的 python3 的沙箱，图片保存到临时目录，缺少 python3 或所需模块时跳过测试
+func newTestSandbox(t *testing.T) *PythonSandbox {
+	python := testPython(t, "pandas", "matplotlib", "numpy")
+	sandbox := NewPythonSandbox(t.TempDir())
+	sandbox.SetPythonPath(python)
+	return sandbox
+}
+
+// testPython 返回测试使用的Python解释器，不支持命名空间隔离或没有Python时跳过测试
+func testPython(t *testing.T, modules ...string) string {
+	if runtime.GOOS != "linux" {
+		t.Skip("沙箱隔离需要Linux")
+	}
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	if len(modules) > 0 {
+		if err := exec.Command(python, "-c", "import "+strings.Join(modules, ", ")).Run(); err != nil {
+			t.Skipf("缺少Python模块 %v", modules)
+		}
+	}
+	return python
+}
+
+func TestIsolatedCommand(t *testing.T) {
+	python := testPython(t)
+	base := t.TempDir()
+	uploads := filepath.Join(base, "uploads")
+	raw := filepath.Join(uploads, "sales.csv")
+	dir := filepath.Join(base, "tmp", "run")
+	for _, d := range []string{uploads, dir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(raw, []byte("region,amount\nEast,1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JWT_SECRET", "s3cret")
+
+	// 读取、列目录、shell 命令、卸载挂载和写入宿主文件都应失败，只有临时目录可写
+	code := fmt.Sprintf(`
+import ctypes, os, subprocess
+def attempt(fn):
+    try:
+        return "allowed" if fn() is not False else "denied"
+    except OSError:
+        return "denied"
+def unmount(new_ns):
+    libc = ctypes.CDLL(None, use_errno=True)
+    if new_ns and libc.unshare(0x10000000 | 0x00020000) != 0:
+        raise OSError(ctypes.get_errno(), "unshare")
+    if libc.umount2(%[1]q.encode(), 2) != 0:
+        raise OSError(ctypes.get_errno(), "umount2")
+    return open(%[2]q).read()
+print(attempt(lambda: open(%[2]q).read()))
+print(attempt(lambda: os.listdir(%[1]q) != [] or False))
+print(attempt(lambda: subprocess.run(["cat", %[2]q], capture_output=True).returncode == 0 or False))
+print(attempt(lambda: unmount(False)))
+print(attempt(lambda: unmount(True)))
+print(attempt(lambda: open(%[3]q, "w").write("x")))
+print(attempt(lambda: open(%[4]q, "w").write("x")))
+print(os.environ.get("JWT_SECRET"))
+`, uploads, raw, filepath.Join(base, "host.txt"), filepath.Join(dir, "out.txt"))
+
+	cmd, err := isolatedCommand(context.Background(), &isolation{
+		Python:  realPath(python),
+		Args:    []string{"-c", code},
+		Env:     sandboxEnv(),
+		Dir:     realPath(dir),
+		Scratch: realPath(filepath.Dir(dir)),
+		Hidden:  realPaths([]string{uploads}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("执行失败: %v\n%s", err, output)
+	}
+	want := "denied\ndenied\ndenied\ndenied\ndenied\ndenied\nallowed\nNone\n"
+	if string(output) != want {
+		t.Errorf("期望\n%s实际\n%s", want, output)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out.txt")); err != nil {
+		t.Errorf("临时目录中的输出应写回宿主: %v", err)
+	}
+}
+
+func TestPythonSandbox_AuditHookBypass(t *testing.T) {
+	python := testPython(t, "pandas", "matplotlib", "numpy")
+	base := t.TempDir()
+	uploads := filepath.Join(base, "uploads")
+	raw := filepath.Join(uploads, "sales.csv")
+	if err := os.MkdirAll(uploads, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(raw, []byte("region,amount\nEast,1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	scratch := filepath.Join(base, "tmp")
+	if err := os.Mkdir(scratch, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TMPDIR", scratch)
+
+	sandbox := NewPythonSandbox("")
+	sandbox.SetPythonPath(python)
+	sandbox.SetDeniedPaths(uploads)
+
+	// 通过 gc 找到审计钩子闭包中的列表并写入，曾经可以跳过所有检查；隔离后仍然无法读取原始文件
+	code := fmt.Sprintf(`
+import gc, sys
+for obj in gc.get_objects():
+    for cell in getattr(obj, "__closure__", None) or []:
+        try:
+            if isinstance(cell.cell_contents, list):
+                cell.cell_contents.append(1)
+        except ValueError:
+            pass
+try:
+    result = open(%q).read()
+except OSError:
+    result = "denied"
+result
+`, raw)
+	result, err := sandbox.ExecuteCodeContext(context.Background(), code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || result.Output != "denied" {
+		t.Errorf("沙箱不应读取原始文件: %+v", result)
+	}
+}
+
+func TestPythonSandbox_OtherUsersViews(t *testing.T) {
+	python := testPython(t, "pandas", "matplotlib", "numpy")
+	base := t.TempDir()
+	views := filepath.Join(base, "views")
+	for _, user := range []string{"1", "2"} {
+		if err := os.MkdirAll(filepath.Join(views, user), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(views, user, "sales.csv"), []byte("region\n"+user+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scratch := filepath.Join(base, "tmp")
+	if err := os.Mkdir(scratch, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TMPDIR", scratch)
+
+	sandbox := NewPythonSandbox("")
+	sandbox.SetPythonPath(python)
+	sandbox.SetDeniedPaths(views)
+	sandbox.SetUserPaths(func(userID int) []string {
+		return []string{filepath.Join(views, fmt.Sprint(userID))}
+	})
+
+	// 用户1只能看到和读取自己的数据视图，不能读取或修改其他用户的视图
+	code := fmt.Sprintf(`
+import os
+def attempt(fn):
+    try:
+        return fn()
+    except OSError:
+        return "denied"
+result = [sorted(os.listdir(%[1]q)), attempt(lambda: open(%[2]q).read()), attempt(lambda: open(%[3]q).read()), attempt(lambda: open(%[2]q, "a").write("x"))]
+result
+`, views, filepath.Join(views, "1", "sales.csv"), filepath.Join(views, "2", "sales.csv"))
+	result, err := sandbox.ExecuteCodeContext(scheduler.WithUser(context.Background(), "1"), code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[['1'] region\n1\n denied denied]"
+	if !result.Success || fmt.Sprint(result.Output) != want {
+		t.Errorf("期望 %s，实际 %+v", want, result)
+	}
+
+	// 没有用户的上下文看不到任何数据视图
+	result, err = sandbox.ExecuteCodeContext(context.Background(), fmt.Sprintf("import os\nos.listdir(%q)", views))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || fmt.Sprint(result.Output) != "[]" {
+		t.Errorf("匿名执行不应看到数据视图: %+v", result)
+	}
+}
+
+func TestPythonSandbox_ScriptKeepsCodeOutOfLiterals(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	sandbox := NewPythonSandbox(t.TempDir())
+	code := "x = '''a\\b'''\nprint(x)\n'''+__import__('os').system('echo INJECTED')+'''"
+	script := sandbox.createExecutionScript(code, "/tmp/'''\\dir")
+	if strings.Contains(script, "INJECTED") || strings.Contains(script, "'''a") {
+		t.Fatal("用户代码不应原样写入执行脚本")
+	}
+
+	// 执行脚本本身是合法的Python，用户代码解码后与原文一致
+	check := exec.Command(python, "-c", `
+import ast, base64, json, sys
+tree = ast.parse(sys.stdin.read())
+calls = [n for n in ast.walk(tree) if isinstance(n, ast.Call) and getattr(n.func, "attr", "") == "b64decode"]
+print(json.loads(base64.b64decode(calls[0].args[0].value))["code"], end="")
+`)
+	check.Stdin = strings.NewReader(script)
+	out, err := check.Output()
+	if err != nil {
+		t.Fatalf("执行脚本不是合法的Python: %v", err)
+	}
+	if string(out) != code {
+		t.Errorf("解码后的用户代码 = %q, want %q", out, code)
+	}
+}
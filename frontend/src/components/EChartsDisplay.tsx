@@ -4,7 +4,7 @@ import { Card, Alert, Spin } from 'antd';
 
 // ECharts配置接口
 interface EChartsConfig {
-  type: 'bar' | 'line' | 'pie' | 'scatter' | 'heatmap';
+  type: 'bar' | 'line' | 'area' | 'pie' | 'scatter' | 'heatmap' | 'radar';
   title: string;
   data: Array<{
     name: string;
@@ -151,6 +151,27 @@ const EChartsDisplay: React.FC<EChartsDisplayProps> = ({
           }]
         };
 
+      case 'area':
+        return {
+          ...baseOption,
+          tooltip: {
+            trigger: 'axis'
+          },
+          xAxis: {
+            type: 'category',
+            boundaryGap: false,
+            data: config.xAxis || []
+          },
+          yAxis: {
+            type: 'value'
+          },
+          series: (config.series || []).map(item => ({
+            ...item,
+            type: 'line',
+            areaStyle: {}
+          }))
+        };
+
       case 'pie':
         return {
           ...baseOption,
@@ -250,6 +271,21 @@ const EChartsDisplay: React.FC<EChartsDisplayProps> = ({
           }]
         };
 
+      case 'radar':
+        return {
+          ...baseOption,
+          radar: {
+            indicator: config.options?.indicator || (config.xAxis || []).map(name => ({ name }))
+          },
+          series: [{
+            type: 'radar',
+            data: (config.series || []).map(item => ({
+              name: item.name,
+              value: item.data
+            }))
+          }]
+        };
+
       default:
         return baseOption;
     }
@@ -25,7 +25,7 @@ import { fileService } from '../services/file';
 import { analysisService } from '../services/analysis';
 import { FileInfo, Session, Query, QueryRequest, VisualizationRequest } from '../services/types';
 import ChatMessage from '../components/ChatMessage';
-import ChartDisplay from '../components/ChartDisplay';
+import EChartsDisplay from '../components/EChartsDisplay';
 
 const { Option } = Select;
 const { TextArea } = Input;
@@ -359,7 +359,7 @@ const Analysis: React.FC = () => {
         width={800}
         footer={null}
       >
-        {chartData && <ChartDisplay data={chartData} />}
+        {chartData && <EChartsDisplay config={chartData.chart_data} />}
       </Modal>
     </div>
   );
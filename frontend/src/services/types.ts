@@ -31,9 +31,7 @@ export interface ApiResponse<T> {
 export interface FileInfo {
   id: number;
   user_id: number;
-  name: string;
   orig_name: string;
-  path: string;
   size: number;
   type: string;
   status: string;
@@ -77,6 +75,7 @@ export interface QueryRequest {
 }
 
 export interface QueryResponse {
+  query_id: number;
   answer: string;
   data?: any;
   query_type: string;
@@ -90,10 +89,17 @@ export interface VisualizationRequest {
   chart_type: string;
 }
 
+// 图表，chart_data 为 EChartsDisplay 使用的图表配置
 export interface VisualizationResponse {
-  chart_data: any;
-  chart_type: string;
+  id: number;
+  session_id: number;
+  query_id: number;
+  file_id: number;
   title: string;
+  chart_type: string;
+  columns: string[];
+  chart_data: any;
+  created_at: string;
 }
 
 export interface ReportRequest {